@@ -0,0 +1,365 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/docopt/docopt-go"
+	"golang.org/x/term"
+)
+
+const doc = `G-Node Infrastructure Authentication Provider - Administration
+
+Usage:
+  gin-auth-admin bootstrap [--res <dir>] [--conf <dir>]
+  gin-auth-admin backup <file> [--secrets <mode>] [--store] [--res <dir>] [--conf <dir>]
+  gin-auth-admin restore <file> [--res <dir>] [--conf <dir>]
+  gin-auth-admin retention [--dry-run] [--res <dir>] [--conf <dir>]
+  gin-auth-admin verify [--repair] [--res <dir>] [--conf <dir>]
+  gin-auth-admin row-audit (on | off | status) [--res <dir>] [--conf <dir>]
+  gin-auth-admin service-tokens [--revoke | --enable] [<job>] [--res <dir>] [--conf <dir>]
+  gin-auth-admin -h | --help
+
+Options:
+  --secrets <mode>  Handling of password hashes and client secrets in the
+                    backup: include, exclude or encrypt. Encrypted secrets
+                    use a key derived from the passphrase in the environment
+                    variable GIN_AUTH_BACKUP_PASSPHRASE. Backups with
+                    included secrets can't be stored [default: exclude].
+  --store           Upload the backup to the configured blob storage with
+                    <file> as key (e.g. backups/2016-11-03.json) and print
+                    a download URL.
+  --dry-run         Only report what would be purged by the retention
+                    policies.
+  --repair          Remove the inconsistencies found by verify, where
+                    this is possible without manual decisions.
+  --revoke          Revoke the service tokens of <job> or of all jobs and
+                    disable the jobs, they skip their runs until enabled.
+  --enable          Enable <job> or all jobs after a revocation, the jobs
+                    mint new tokens on their next run.
+  --res <dir>       Path to the resources directory. By default
+                    gin-auth-admin will use GOPATH to find the directory.
+  --conf <dir>      Path to the configuration files directory. By default
+                    gin-auth-admin will use the resources/conf directory.
+  -h --help         Show this screen.
+
+Commands:
+  bootstrap         Interactively create an initial account if the
+                    database does not contain any accounts.
+  backup            Write accounts, ssh keys, clients and client approvals
+                    to a JSON file.
+  restore           Load a backup into a database without accounts.
+  retention         Purge data according to the configured retention
+                    policies.
+  verify            Check the consistency of the data, e.g. tokens and
+                    approvals of missing accounts or clients and e-mail
+                    addresses which only differ in case.
+  row-audit         Switch the database triggers on or off, which record
+                    all changes of accounts, clients, approvals, ssh keys
+                    and group members in the table RowAudit.
+  service-tokens    List the tokens with which background jobs like the
+                    cleaner act and the disabled jobs.
+`
+
+// Environment variable containing the passphrase for encrypted secrets
+const envPassphrase = "GIN_AUTH_BACKUP_PASSPHRASE"
+
+// bootstrap asks for the login, e-mail address and password of the initial account and creates it.
+// The password is not echoed if stdin is a terminal.
+func bootstrap() error {
+	if data.HasAccounts() {
+		return fmt.Errorf("The database already contains accounts")
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	prompt := func(name string) (string, error) {
+		fmt.Printf("%s: ", name)
+		line, err := in.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+	promptPassword := func(name string) (string, error) {
+		fd := int(os.Stdin.Fd())
+		if !term.IsTerminal(fd) {
+			return prompt(name)
+		}
+		fmt.Printf("%s: ", name)
+		password, err := term.ReadPassword(fd)
+		fmt.Println()
+		return string(password), err
+	}
+
+	login, err := prompt("Login")
+	if err != nil {
+		return err
+	}
+	email, err := prompt("E-mail")
+	if err != nil {
+		return err
+	}
+	password, err := promptPassword("Password")
+	if err != nil {
+		return err
+	}
+	repeated, err := promptPassword("Repeat password")
+	if err != nil {
+		return err
+	}
+	if password != repeated {
+		return fmt.Errorf("The passwords do not match")
+	}
+
+	acc, err := data.BootstrapAccount(login, email, password)
+	if err != nil {
+		if valErr, ok := err.(*util.ValidationError); ok {
+			for field, msg := range valErr.FieldErrors {
+				err = fmt.Errorf("%s (%s: %s)", err.Error(), field, msg)
+			}
+		}
+		return err
+	}
+
+	fmt.Printf("Created initial account '%s'\n", acc.Login)
+	return nil
+}
+
+func backup(file, secrets string, store bool) error {
+	if store && secrets == data.SecretsInclude {
+		return errors.New("Backups with plain text secrets can't be uploaded, use --secrets encrypt or exclude")
+	}
+
+	b, err := data.CreateBackup(secrets, os.Getenv(envPassphrase))
+	if err != nil {
+		return err
+	}
+
+	content := &bytes.Buffer{}
+	enc := json.NewEncoder(content)
+	enc.SetIndent("", "  ")
+	err = enc.Encode(b)
+	if err != nil {
+		return err
+	}
+
+	if store {
+		err = storeBackup(file, content)
+	} else {
+		err = writeBackup(file, content)
+	}
+	if err != nil {
+		return err
+	}
+
+	for name, rows := range b.Tables {
+		fmt.Printf("Saved %d rows of table '%s'\n", len(rows), name)
+	}
+	return nil
+}
+
+func writeBackup(file string, content *bytes.Buffer) error {
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = content.WriteTo(f)
+	return err
+}
+
+func storeBackup(key string, content *bytes.Buffer) error {
+	store, err := util.GetBlobStore()
+	if err != nil {
+		return err
+	}
+	err = store.Put(key, content, "application/json")
+	if err != nil {
+		return err
+	}
+
+	url, err := store.SignedURL(key, conf.GetBlobStorage().URLLifeTime)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Uploaded backup to the blob storage, download URL: %s\n", url)
+	return nil
+}
+
+func restore(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	b, err := data.ReadBackup(f)
+	if err != nil {
+		return err
+	}
+
+	err = data.RestoreBackup(b, os.Getenv(envPassphrase))
+	if err != nil {
+		return err
+	}
+
+	for name, rows := range b.Tables {
+		fmt.Printf("Restored %d rows of table '%s'\n", len(rows), name)
+	}
+	if b.Secrets == data.SecretsExclude {
+		fmt.Println("The backup contains no passwords, all accounts need a password reset")
+	}
+	return nil
+}
+
+func retention(dryRun bool) error {
+	reports, err := data.ApplyRetention(dryRun)
+	if err != nil {
+		return err
+	}
+
+	action := "Purged"
+	if dryRun {
+		action = "Would purge"
+	}
+	for _, report := range reports {
+		fmt.Printf("%s %d rows by policy '%s' (keep %d days, cutoff %s)\n", action, report.Rows,
+			report.Policy, int(report.Keep.Hours()/24), report.Cutoff.Format("2006-01-02 15:04"))
+	}
+	if len(reports) == 0 {
+		fmt.Println("No retention policies configured, all data is kept")
+	}
+	return nil
+}
+
+func verify(repair bool) error {
+	reports, err := data.CheckConsistency(repair)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, report := range reports {
+		status := "ok"
+		switch {
+		case len(report.Violations) == 0:
+		case report.Repaired:
+			status = "repaired"
+		case report.Repairable:
+			status = "failed, use --repair"
+			failed++
+		default:
+			status = "failed, repair manually"
+			failed++
+		}
+		fmt.Printf("%s (%s): %s\n", report.Description, report.Check, status)
+		for _, violation := range report.Violations {
+			fmt.Printf("  %s\n", violation)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d consistency checks failed", failed)
+	}
+	return nil
+}
+
+func rowAudit(args map[string]interface{}) error {
+	if args["on"].(bool) || args["off"].(bool) {
+		err := data.SetRowAudit(args["on"].(bool))
+		if err != nil {
+			return err
+		}
+	}
+
+	if data.IsRowAuditEnabled() {
+		fmt.Println("Row audit triggers are on")
+	} else {
+		fmt.Println("Row audit triggers are off")
+	}
+	return nil
+}
+
+func serviceTokens(revoke, enable bool, job string) error {
+	if revoke {
+		count, err := data.RevokeServiceTokens(job)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Revoked %d service tokens, the jobs are disabled until enabled with --enable\n", count)
+		return nil
+	}
+	if enable {
+		err := data.EnableServiceJobs(job)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Enabled background jobs, they mint new tokens on their next run")
+		return nil
+	}
+
+	for _, disabled := range data.ListDisabledServiceJobs() {
+		if job == "" || disabled == job {
+			fmt.Printf("Job %s is disabled\n", disabled)
+		}
+	}
+
+	for _, tok := range data.ListServiceTokens() {
+		if job == "" || tok.Job == job {
+			fmt.Printf("%s  %-16s  %-16s  expires %s\n", tok.UUID, tok.Job, strings.Join(tok.Scope.Strings(), ","),
+				tok.Expires.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+func main() {
+	args, _ := docopt.Parse(doc, nil, true, "", false)
+	if res, ok := args["--res"]; ok && res != nil {
+		conf.SetResourcesPath(res.(string))
+	}
+	if config, ok := args["--conf"]; ok && config != nil {
+		conf.SetConfigPath(config.(string))
+	}
+
+	data.InitDb(conf.GetDbConfig())
+
+	var err error
+	if cmd, ok := args["bootstrap"]; ok && cmd.(bool) {
+		err = bootstrap()
+	} else if cmd, ok := args["backup"]; ok && cmd.(bool) {
+		err = backup(args["<file>"].(string), args["--secrets"].(string), args["--store"].(bool))
+	} else if cmd, ok := args["retention"]; ok && cmd.(bool) {
+		err = retention(args["--dry-run"].(bool))
+	} else if cmd, ok := args["verify"]; ok && cmd.(bool) {
+		err = verify(args["--repair"].(bool))
+	} else if cmd, ok := args["row-audit"]; ok && cmd.(bool) {
+		err = rowAudit(args)
+	} else if cmd, ok := args["service-tokens"]; ok && cmd.(bool) {
+		job, _ := args["<job>"].(string)
+		err = serviceTokens(args["--revoke"].(bool), args["--enable"].(bool), job)
+	} else {
+		err = restore(args["<file>"].(string))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
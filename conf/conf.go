@@ -9,11 +9,13 @@
 package conf
 
 import (
+	"crypto/rand"
 	"crypto/tls"
 	"fmt"
 	"gopkg.in/yaml.v2"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"net/smtp"
 	"os"
 	"path/filepath"
@@ -32,11 +34,35 @@ const (
 	defaultCleanerInterval       = 15
 	defaultMailQueueInterval     = 1
 	defaultTmpSshKeyLifeTime     = 5
+	defaultMaintenanceRetryAfter = 10
+	defaultReadOnlyRetryAfter    = 5
+)
+
+// Default maintenance banner
+const (
+	defaultMaintenanceMessage = "GIN is currently undergoing maintenance. Some functions are temporarily unavailable."
+	defaultReadOnlyMessage    = "GIN is currently in read-only mode. Changes are temporarily not possible."
+)
+
+// Default session cookie settings
+const (
+	defaultCookieName = "session"
+	defaultCookiePath = "/"
+)
+
+// Default rate limit settings: per-account limits are raised by defaultInternalRateFactor for requests
+// from internal networks, IPv6 addresses are rate limited per network with defaultIPv6RateLimitPrefix
+const (
+	defaultInternalRateFactor  = 10
+	defaultIPv6RateLimitPrefix = 64
 )
 
 // Default smtp settings
 const (
-	defaultPort = 587
+	defaultPort            = 587
+	defaultSmtpStartTLS    = "auto"
+	defaultSmtpPoolSize    = 2
+	defaultSmtpIdleTimeout = 30
 )
 
 var (
@@ -45,6 +71,7 @@ var (
 	serverConfigFile  = "server.yml"
 	dbConfigFile      = "dbconf.yml"
 	clientsConfigFile = "clients.yml"
+	useTestConfig     bool
 )
 
 func init() {
@@ -67,11 +94,62 @@ func SetConfigPath(config string) {
 	configPath = config
 }
 
+// UseTestConfig overrides settings of server.yml with the settings in fixtures/server.yml,
+// which contains fixed secrets and administrators for tests. Those settings must never be
+// used in production. This function should only be used in tests before other helpers from
+// the conf package are used.
+func UseTestConfig() {
+	useTestConfig = true
+}
+
+// readServerConfig reads server.yml and applies the test settings if UseTestConfig was called.
+func readServerConfig() ([]byte, error) {
+	content, err := ioutil.ReadFile(filepath.Join(configPath, serverConfigFile))
+	if err != nil || !useTestConfig {
+		return content, err
+	}
+
+	overlay, err := ioutil.ReadFile(filepath.Join(resourcesPath, "fixtures", serverConfigFile))
+	if err != nil {
+		return nil, err
+	}
+
+	base := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(content, &base)
+	if err != nil {
+		return nil, err
+	}
+	test := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(overlay, &test)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeConfig(base, test)
+	return yaml.Marshal(base)
+}
+
+// mergeConfig recursively replaces the values in base by the values in overlay.
+func mergeConfig(base, overlay map[interface{}]interface{}) {
+	for key, value := range overlay {
+		baseMap, isBaseMap := base[key].(map[interface{}]interface{})
+		overlayMap, isOverlayMap := value.(map[interface{}]interface{})
+		if isBaseMap && isOverlayMap {
+			mergeConfig(baseMap, overlayMap)
+		} else {
+			base[key] = value
+		}
+	}
+}
+
 // ServerConfig provides several general configuration parameters for gin-auth
 type ServerConfig struct {
 	Host                  string
 	Port                  int
+	Socket                string
+	TrustSocketPeer       bool
 	BaseURL               string
+	PathPrefix            string
 	SessionLifeTime       time.Duration
 	TokenLifeTime         time.Duration
 	GrantReqLifeTime      time.Duration
@@ -79,6 +157,17 @@ type ServerConfig struct {
 	TmpSshKeyLifeTime     time.Duration
 	CleanerInterval       time.Duration
 	MailQueueInterval     time.Duration
+	CookieName            string
+	CookiePath            string
+	CookieDomain          string
+	CookieSecure          bool
+	CookieHttpOnly        bool
+	CookieSameSite        http.SameSite
+	TrustedProxies        []*net.IPNet
+	InternalNetworks      []*net.IPNet
+	InternalRateFactor    int
+	IPv6RateLimitPrefix   int
+	TestMode              bool
 }
 
 var serverConfig *ServerConfig
@@ -100,22 +189,38 @@ var dbConfigLock = sync.Mutex{}
 // Supported values of Mode are: print and skip; print will write the content of
 // any e-mail to the commandline / log, skip will skip over any e-mail sending process.
 // For any other value of "Mode" e-mails will be sent.
+// StartTLS is one of auto (use STARTTLS if offered), require or disable. Up to PoolSize
+// connections are kept open for reuse for IdleTimeout. If DKIMKeyFile is set, outgoing
+// e-mails are DKIM signed for DKIMDomain using DKIMSelector.
 type SmtpCredentials struct {
-	From     string
-	Username string
-	Password string
-	Host     string
-	Port     int
-	Mode     string
+	From          string
+	Username      string
+	Password      string
+	Host          string
+	Port          int
+	Mode          string
+	StartTLS      string
+	TLSSkipVerify bool
+	PoolSize      int
+	IdleTimeout   time.Duration
+	DKIMDomain    string
+	DKIMSelector  string
+	DKIMKeyFile   string
+}
+
+// TLSConfig returns the TLS configuration used for STARTTLS.
+func (cred *SmtpCredentials) TLSConfig() *tls.Config {
+	return &tls.Config{ServerName: cred.Host, InsecureSkipVerify: cred.TLSSkipVerify}
 }
 
 var smtpCred *SmtpCredentials
 var smtpCredLock = sync.Mutex{}
 
-// LogLocations contains paths to the Access and Error log files.
+// LogLocations contains paths to the Access, Error and Audit log files.
 type LogLocations struct {
 	Access string
 	Error  string
+	Audit  string
 }
 
 var logLoc *LogLocations
@@ -128,23 +233,37 @@ func GetServerConfig() *ServerConfig {
 	defer serverConfigLock.Unlock()
 
 	if serverConfig == nil {
-		content, err := ioutil.ReadFile(filepath.Join(configPath, serverConfigFile))
+		content, err := readServerConfig()
 		if err != nil {
 			panic(err)
 		}
 
 		config := &struct {
 			Http struct {
-				Host                  string `yaml:"Host"`
-				Port                  int    `yaml:"Port"`
-				BaseURL               string `yaml:"BaseURL"`
-				SessionLifeTime       int    `yaml:"SessionLifeTime"`
-				TokenLifeTime         int    `yaml:"TokenLifeTime"`
-				GrantReqLifeTime      int    `yaml:"GrantReqLifeTime"`
-				UnusedAccountLifeTime int    `yaml:"UnusedAccountLifeTime"`
-				TmpSshKeyLifeTime     int    `yaml:"TmpSshKeyLifeTime"`
-				CleanerInterval       int    `yaml:"CleanerInterval"`
-				MailQueueInterval     int    `yaml:"MailQueueInterval"`
+				Host                  string   `yaml:"Host"`
+				Port                  int      `yaml:"Port"`
+				Socket                string   `yaml:"Socket"`
+				TrustSocketPeer       bool     `yaml:"TrustSocketPeer"`
+				BaseURL               string   `yaml:"BaseURL"`
+				PathPrefix            string   `yaml:"PathPrefix"`
+				SessionLifeTime       int      `yaml:"SessionLifeTime"`
+				TokenLifeTime         int      `yaml:"TokenLifeTime"`
+				GrantReqLifeTime      int      `yaml:"GrantReqLifeTime"`
+				UnusedAccountLifeTime int      `yaml:"UnusedAccountLifeTime"`
+				TmpSshKeyLifeTime     int      `yaml:"TmpSshKeyLifeTime"`
+				CleanerInterval       int      `yaml:"CleanerInterval"`
+				MailQueueInterval     int      `yaml:"MailQueueInterval"`
+				CookieName            string   `yaml:"CookieName"`
+				CookiePath            string   `yaml:"CookiePath"`
+				CookieDomain          string   `yaml:"CookieDomain"`
+				CookieSecure          bool     `yaml:"CookieSecure"`
+				CookieHttpOnly        *bool    `yaml:"CookieHttpOnly"`
+				CookieSameSite        string   `yaml:"CookieSameSite"`
+				TrustedProxies        []string `yaml:"TrustedProxies"`
+				InternalNetworks      []string `yaml:"InternalNetworks"`
+				InternalRateFactor    int      `yaml:"InternalRateFactor"`
+				IPv6RateLimitPrefix   int      `yaml:"IPv6RateLimitPrefix"`
+				TestMode              bool     `yaml:"TestMode"`
 			}
 		}{}
 		err = yaml.Unmarshal(content, config)
@@ -153,6 +272,7 @@ func GetServerConfig() *ServerConfig {
 		}
 
 		// set defaults
+		config.Http.PathPrefix = normalizePathPrefix(config.Http.PathPrefix)
 		if config.Http.BaseURL == "" {
 			if config.Http.Port == 80 {
 				config.Http.BaseURL = fmt.Sprintf("http://%s", config.Http.Host)
@@ -160,6 +280,10 @@ func GetServerConfig() *ServerConfig {
 				config.Http.BaseURL = fmt.Sprintf("http://%s:%d", config.Http.Host, config.Http.Port)
 			}
 		}
+		config.Http.BaseURL = strings.TrimSuffix(config.Http.BaseURL, "/")
+		if !strings.HasSuffix(config.Http.BaseURL, config.Http.PathPrefix) {
+			config.Http.BaseURL += config.Http.PathPrefix
+		}
 		if config.Http.SessionLifeTime == 0 {
 			config.Http.SessionLifeTime = defaultSessionLifeTime
 		}
@@ -181,11 +305,45 @@ func GetServerConfig() *ServerConfig {
 		if config.Http.MailQueueInterval == 0 {
 			config.Http.MailQueueInterval = defaultMailQueueInterval
 		}
+		if config.Http.CookieName == "" {
+			config.Http.CookieName = defaultCookieName
+		}
+		if config.Http.CookiePath == "" {
+			config.Http.CookiePath = defaultCookiePath
+			if config.Http.PathPrefix != "" {
+				config.Http.CookiePath = config.Http.PathPrefix
+			}
+		}
+		httpOnly := true
+		if config.Http.CookieHttpOnly != nil {
+			httpOnly = *config.Http.CookieHttpOnly
+		}
+		sameSite, err := parseSameSite(config.Http.CookieSameSite)
+		if err != nil {
+			panic(err)
+		}
+		proxies, err := parseNetworks(config.Http.TrustedProxies)
+		if err != nil {
+			panic(err)
+		}
+		internal, err := parseNetworks(config.Http.InternalNetworks)
+		if err != nil {
+			panic(err)
+		}
+		if config.Http.InternalRateFactor < 1 {
+			config.Http.InternalRateFactor = defaultInternalRateFactor
+		}
+		if config.Http.IPv6RateLimitPrefix < 1 || config.Http.IPv6RateLimitPrefix > 128 {
+			config.Http.IPv6RateLimitPrefix = defaultIPv6RateLimitPrefix
+		}
 
 		serverConfig = &ServerConfig{
 			Host:                  config.Http.Host,
 			Port:                  config.Http.Port,
+			Socket:                config.Http.Socket,
+			TrustSocketPeer:       config.Http.TrustSocketPeer,
 			BaseURL:               config.Http.BaseURL,
+			PathPrefix:            config.Http.PathPrefix,
 			SessionLifeTime:       time.Duration(config.Http.SessionLifeTime) * time.Minute,
 			TokenLifeTime:         time.Duration(config.Http.TokenLifeTime) * time.Minute,
 			GrantReqLifeTime:      time.Duration(config.Http.GrantReqLifeTime) * time.Minute,
@@ -193,12 +351,105 @@ func GetServerConfig() *ServerConfig {
 			TmpSshKeyLifeTime:     time.Duration(config.Http.TmpSshKeyLifeTime) * time.Minute,
 			CleanerInterval:       time.Duration(config.Http.CleanerInterval) * time.Minute,
 			MailQueueInterval:     time.Duration(config.Http.MailQueueInterval) * time.Minute,
+			CookieName:            config.Http.CookieName,
+			CookiePath:            config.Http.CookiePath,
+			CookieDomain:          config.Http.CookieDomain,
+			CookieSecure:          config.Http.CookieSecure,
+			CookieHttpOnly:        httpOnly,
+			CookieSameSite:        sameSite,
+			TrustedProxies:        proxies,
+			InternalNetworks:      internal,
+			InternalRateFactor:    config.Http.InternalRateFactor,
+			IPv6RateLimitPrefix:   config.Http.IPv6RateLimitPrefix,
+			TestMode:              config.Http.TestMode,
 		}
 	}
 
 	return serverConfig
 }
 
+// normalizePathPrefix ensures that a non empty path prefix starts with a slash
+// and does not end with a slash.
+func normalizePathPrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return "/" + prefix
+}
+
+// parseSameSite converts the SameSite attribute of the server configuration.
+// Supported values are: lax, strict, none and empty for the browser default.
+func parseSameSite(value string) (http.SameSite, error) {
+	switch strings.ToLower(value) {
+	case "":
+		return http.SameSiteDefaultMode, nil
+	case "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return http.SameSiteDefaultMode, fmt.Errorf("Invalid value for CookieSameSite: '%s'", value)
+	}
+}
+
+// parseNetworks converts a list of networks in CIDR notation. Single IP addresses
+// are converted into networks containing only this address.
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("Invalid network: '%s'", v)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid network: '%s'", v)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// IsTrustedProxy checks whether an IP address belongs to one of the configured trusted proxies.
+// Peers on a unix domain socket have no IP address (nil), they are trusted if gin-auth listens
+// on a socket and TrustSocketPeer is set.
+func (config *ServerConfig) IsTrustedProxy(ip net.IP) bool {
+	if ip == nil {
+		return config.Socket != "" && config.TrustSocketPeer
+	}
+	return containsIP(config.TrustedProxies, ip)
+}
+
+// IsInternal checks whether an IP address belongs to one of the configured internal networks.
+// Requests from internal networks are exempt from rate limiting per address.
+func (config *ServerConfig) IsInternal(ip net.IP) bool {
+	return containsIP(config.InternalNetworks, ip)
+}
+
+// containsIP checks whether an IP address belongs to one of the networks.
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetDbConfig loads a database configuration from a yaml file when called the first time.
 // Returns a struct with configuration information.
 func GetDbConfig() *DbConfig {
@@ -244,19 +495,26 @@ func GetSmtpCredentials() *SmtpCredentials {
 	defer smtpCredLock.Unlock()
 
 	if smtpCred == nil {
-		content, err := ioutil.ReadFile(filepath.Join(configPath, serverConfigFile))
+		content, err := readServerConfig()
 		if err != nil {
 			panic(err)
 		}
 
 		credentials := &struct {
 			Smtp struct {
-				From     string `yaml:"From"`
-				Username string `yaml:"Username"`
-				Password string `yaml:"Password"`
-				Host     string `yaml:"Host"`
-				Port     int    `yaml:"Port"`
-				Mode     string `yaml:"Mode"`
+				From          string `yaml:"From"`
+				Username      string `yaml:"Username"`
+				Password      string `yaml:"Password"`
+				Host          string `yaml:"Host"`
+				Port          int    `yaml:"Port"`
+				Mode          string `yaml:"Mode"`
+				StartTLS      string `yaml:"StartTLS"`
+				TLSSkipVerify bool   `yaml:"TLSSkipVerify"`
+				PoolSize      int    `yaml:"PoolSize"`
+				IdleTimeout   int    `yaml:"IdleTimeout"`
+				DKIMDomain    string `yaml:"DKIMDomain"`
+				DKIMSelector  string `yaml:"DKIMSelector"`
+				DKIMKeyFile   string `yaml:"DKIMKeyFile"`
 			}
 		}{}
 		err = yaml.Unmarshal(content, credentials)
@@ -267,14 +525,34 @@ func GetSmtpCredentials() *SmtpCredentials {
 		if credentials.Smtp.Port == 0 {
 			credentials.Smtp.Port = defaultPort
 		}
+		if credentials.Smtp.StartTLS == "" {
+			credentials.Smtp.StartTLS = defaultSmtpStartTLS
+		}
+		if credentials.Smtp.PoolSize == 0 {
+			credentials.Smtp.PoolSize = defaultSmtpPoolSize
+		}
+		if credentials.Smtp.IdleTimeout == 0 {
+			credentials.Smtp.IdleTimeout = defaultSmtpIdleTimeout
+		}
+		keyFile := credentials.Smtp.DKIMKeyFile
+		if keyFile != "" && !filepath.IsAbs(keyFile) {
+			keyFile = filepath.Join(configPath, keyFile)
+		}
 
 		smtpCred = &SmtpCredentials{
-			From:     credentials.Smtp.From,
-			Username: credentials.Smtp.Username,
-			Password: credentials.Smtp.Password,
-			Host:     credentials.Smtp.Host,
-			Port:     credentials.Smtp.Port,
-			Mode:     credentials.Smtp.Mode,
+			From:          credentials.Smtp.From,
+			Username:      credentials.Smtp.Username,
+			Password:      credentials.Smtp.Password,
+			Host:          credentials.Smtp.Host,
+			Port:          credentials.Smtp.Port,
+			Mode:          credentials.Smtp.Mode,
+			StartTLS:      strings.ToLower(credentials.Smtp.StartTLS),
+			TLSSkipVerify: credentials.Smtp.TLSSkipVerify,
+			PoolSize:      credentials.Smtp.PoolSize,
+			IdleTimeout:   time.Duration(credentials.Smtp.IdleTimeout) * time.Second,
+			DKIMDomain:    credentials.Smtp.DKIMDomain,
+			DKIMSelector:  credentials.Smtp.DKIMSelector,
+			DKIMKeyFile:   keyFile,
 		}
 	}
 
@@ -294,6 +572,22 @@ func (a *NoAuth) Next(fromServer []byte, more bool) (toServer []byte, err error)
 	return nil, nil
 }
 
+// SmtpStartTLS upgrades the connection of the smtp client according to the StartTLS
+// setting. With 'auto' STARTTLS is used if the server offers it, with 'require'
+// an error is returned if the server does not offer it.
+func SmtpStartTLS(c *smtp.Client, cred *SmtpCredentials) error {
+	if cred.StartTLS == "disable" {
+		return nil
+	}
+	if ok, _ := c.Extension("STARTTLS"); !ok {
+		if cred.StartTLS == "require" {
+			return fmt.Errorf("Smtp server %s does not support STARTTLS", cred.Host)
+		}
+		return nil
+	}
+	return c.StartTLS(cred.TLSConfig())
+}
+
 // SmtpCheck tests whether a connection to the specified smtp server can be established
 // with the provided credentials and will panic if it cannot.
 func SmtpCheck() error {
@@ -314,17 +608,11 @@ func SmtpCheck() error {
 		return err
 	}
 
-	var auth smtp.Auth
-	if cred.Username == "" && cred.Password == "" {
-		auth = &NoAuth{}
-	} else {
-		auth = smtp.PlainAuth("", cred.Username, cred.Password, cred.Host)
-		if ok, _ := c.Extension("STARTTLS"); ok {
-			config := &tls.Config{ServerName: cred.Host}
-			if err = c.StartTLS(config); err != nil {
-				return err
-			}
-		}
+	if err = SmtpStartTLS(c, cred); err != nil {
+		return err
+	}
+	if cred.Username != "" || cred.Password != "" {
+		auth := smtp.PlainAuth("", cred.Username, cred.Password, cred.Host)
 		if err = c.Auth(auth); err != nil {
 			return err
 		}
@@ -343,7 +631,7 @@ func GetLogLocation() *LogLocations {
 	defer logLocLock.Unlock()
 
 	if logLoc == nil {
-		fc, err := ioutil.ReadFile(filepath.Join(configPath, serverConfigFile))
+		fc, err := readServerConfig()
 		if err != nil {
 			panic(err)
 		}
@@ -352,6 +640,7 @@ func GetLogLocation() *LogLocations {
 			Log struct {
 				Access string `yaml:"Access"`
 				Error  string `yaml:"Error"`
+				Audit  string `yaml:"Audit"`
 			}
 		}{}
 		err = yaml.Unmarshal(fc, cont)
@@ -361,6 +650,7 @@ func GetLogLocation() *LogLocations {
 		logLoc = &LogLocations{
 			Access: cont.Log.Access,
 			Error:  cont.Log.Error,
+			Audit:  cont.Log.Audit,
 		}
 	}
 
@@ -382,7 +672,7 @@ func GetExternals() *Externals {
 	defer externalsLock.Unlock()
 
 	if externals == nil {
-		content, err := ioutil.ReadFile(filepath.Join(configPath, serverConfigFile))
+		content, err := readServerConfig()
 		if err != nil {
 			panic(err)
 		}
@@ -406,3 +696,2151 @@ func GetExternals() *Externals {
 
 	return externals
 }
+
+// Maintenance describes whether gin-auth is in maintenance mode. While in maintenance mode
+// read access is possible but all writes and logins are rejected. Message is shown as
+// banner on all html pages and RetryAfter is used as hint for rejected requests.
+type Maintenance struct {
+	Enabled    bool
+	Message    string
+	RetryAfter time.Duration
+}
+
+var maintenance *Maintenance
+var maintenanceLock = sync.Mutex{}
+
+// GetMaintenance returns the current maintenance state. When called the first time the default
+// message and retry interval are loaded from a yaml file and maintenance mode is disabled.
+func GetMaintenance() Maintenance {
+	maintenanceLock.Lock()
+	defer maintenanceLock.Unlock()
+
+	if maintenance == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		m := &struct {
+			Maintenance struct {
+				Message    string `yaml:"Message"`
+				RetryAfter int    `yaml:"RetryAfter"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, m)
+		if err != nil {
+			panic(err)
+		}
+
+		if m.Maintenance.Message == "" {
+			m.Maintenance.Message = defaultMaintenanceMessage
+		}
+		if m.Maintenance.RetryAfter == 0 {
+			m.Maintenance.RetryAfter = defaultMaintenanceRetryAfter
+		}
+
+		maintenance = &Maintenance{
+			Message:    m.Maintenance.Message,
+			RetryAfter: time.Duration(m.Maintenance.RetryAfter) * time.Minute,
+		}
+	}
+
+	return *maintenance
+}
+
+// SetMaintenance changes the current maintenance state. Empty values for message
+// and retry interval are replaced by the configured defaults.
+func SetMaintenance(m Maintenance) {
+	current := GetMaintenance()
+	if m.Message == "" {
+		m.Message = current.Message
+	}
+	if m.RetryAfter <= 0 {
+		m.RetryAfter = current.RetryAfter
+	}
+
+	maintenanceLock.Lock()
+	maintenance = &m
+	maintenanceLock.Unlock()
+}
+
+// ReadOnly describes whether gin-auth is in read-only mode, e.g. while serving from a replica
+// during the failover of the primary database. In read-only mode tokens are validated and data
+// can be read, but all writes are rejected and background jobs which write to the database are
+// paused. Logins are rejected as well, unless AllowLogins is set. Message is shown as banner on all
+// html pages and RetryAfter is used as hint for rejected requests.
+type ReadOnly struct {
+	Enabled     bool
+	AllowLogins bool
+	Message     string
+	RetryAfter  time.Duration
+}
+
+var readOnly *ReadOnly
+var readOnlyLock = sync.Mutex{}
+
+// GetReadOnly returns the current read-only state. When called the first time the state
+// is loaded from a yaml file.
+func GetReadOnly() ReadOnly {
+	readOnlyLock.Lock()
+	defer readOnlyLock.Unlock()
+
+	if readOnly == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		ro := &struct {
+			ReadOnly struct {
+				Enabled     bool   `yaml:"Enabled"`
+				AllowLogins bool   `yaml:"AllowLogins"`
+				Message     string `yaml:"Message"`
+				RetryAfter  int    `yaml:"RetryAfter"`
+			} `yaml:"readonly"`
+		}{}
+		err = yaml.Unmarshal(content, ro)
+		if err != nil {
+			panic(err)
+		}
+
+		if ro.ReadOnly.Message == "" {
+			ro.ReadOnly.Message = defaultReadOnlyMessage
+		}
+		if ro.ReadOnly.RetryAfter == 0 {
+			ro.ReadOnly.RetryAfter = defaultReadOnlyRetryAfter
+		}
+
+		readOnly = &ReadOnly{
+			Enabled:     ro.ReadOnly.Enabled,
+			AllowLogins: ro.ReadOnly.AllowLogins,
+			Message:     ro.ReadOnly.Message,
+			RetryAfter:  time.Duration(ro.ReadOnly.RetryAfter) * time.Minute,
+		}
+	}
+
+	return *readOnly
+}
+
+// SetReadOnly changes the current read-only state. Empty values for message
+// and retry interval are replaced by the configured defaults.
+func SetReadOnly(ro ReadOnly) {
+	current := GetReadOnly()
+	if ro.Message == "" {
+		ro.Message = current.Message
+	}
+	if ro.RetryAfter <= 0 {
+		ro.RetryAfter = current.RetryAfter
+	}
+
+	readOnlyLock.Lock()
+	readOnly = &ro
+	readOnlyLock.Unlock()
+}
+
+// ErrorReporting contains the settings for reporting errors like recovered panics to an
+// external service. SentryDSN is the DSN of a Sentry compatible service, reporting is
+// disabled if it is empty.
+type ErrorReporting struct {
+	SentryDSN   string
+	Environment string
+}
+
+var errorReporting *ErrorReporting
+var errorReportingLock = sync.Mutex{}
+
+// GetErrorReporting loads the error reporting settings from a yaml file when called the first time.
+func GetErrorReporting() *ErrorReporting {
+	errorReportingLock.Lock()
+	defer errorReportingLock.Unlock()
+
+	if errorReporting == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		e := &struct {
+			Reporting struct {
+				SentryDSN   string `yaml:"SentryDSN"`
+				Environment string `yaml:"Environment"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, e)
+		if err != nil {
+			panic(err)
+		}
+
+		errorReporting = &ErrorReporting{
+			SentryDSN:   e.Reporting.SentryDSN,
+			Environment: e.Reporting.Environment,
+		}
+	}
+
+	return errorReporting
+}
+
+// Default alerting settings
+const (
+	defaultAlertingWindow     = 10 // in minutes
+	defaultAlertingDedup      = 60 // in minutes
+	defaultAlertingMaxPerHour = 10
+)
+
+// Alerting contains the settings for notifying operators about anomalies. An alert is sent to
+// Email and/or Webhook when the number of events of a kind within Window reaches the threshold
+// for this kind. Alerts of the same kind are not repeated within Dedup and at most MaxPerHour
+// alerts are sent per hour.
+type Alerting struct {
+	Email      string
+	Webhook    string
+	Window     time.Duration
+	Dedup      time.Duration
+	MaxPerHour int
+	Thresholds map[string]int
+}
+
+var alerting *Alerting
+var alertingLock = sync.Mutex{}
+
+// GetAlerting loads the alerting settings from a yaml file when called the first time.
+func GetAlerting() *Alerting {
+	alertingLock.Lock()
+	defer alertingLock.Unlock()
+
+	if alerting == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		a := &struct {
+			Alerting struct {
+				Email      string         `yaml:"Email"`
+				Webhook    string         `yaml:"Webhook"`
+				Window     int            `yaml:"Window"`
+				Dedup      int            `yaml:"Dedup"`
+				MaxPerHour int            `yaml:"MaxPerHour"`
+				Thresholds map[string]int `yaml:"Thresholds"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, a)
+		if err != nil {
+			panic(err)
+		}
+
+		if a.Alerting.Window == 0 {
+			a.Alerting.Window = defaultAlertingWindow
+		}
+		if a.Alerting.Dedup == 0 {
+			a.Alerting.Dedup = defaultAlertingDedup
+		}
+		if a.Alerting.MaxPerHour == 0 {
+			a.Alerting.MaxPerHour = defaultAlertingMaxPerHour
+		}
+		if a.Alerting.Thresholds == nil {
+			a.Alerting.Thresholds = make(map[string]int)
+		}
+
+		alerting = &Alerting{
+			Email:      a.Alerting.Email,
+			Webhook:    a.Alerting.Webhook,
+			Window:     time.Duration(a.Alerting.Window) * time.Minute,
+			Dedup:      time.Duration(a.Alerting.Dedup) * time.Minute,
+			MaxPerHour: a.Alerting.MaxPerHour,
+			Thresholds: a.Alerting.Thresholds,
+		}
+	}
+
+	return alerting
+}
+
+// Default failed login burst settings
+const (
+	defaultLoginBurstWindow = 10 // in minutes
+)
+
+// LoginBursts contains the settings of the webhook which is notified when an account or an address
+// reaches AccountThreshold or IPThreshold failed logins within Window, e.g. to feed fail2ban or an
+// intrusion detection system. A threshold of zero disables the respective check. If Secret is set
+// each request is signed with it.
+type LoginBursts struct {
+	Webhook          string
+	Secret           string
+	Window           time.Duration
+	AccountThreshold int
+	IPThreshold      int
+}
+
+var loginBursts *LoginBursts
+var loginBurstsLock = sync.Mutex{}
+
+// GetLoginBursts loads the failed login burst settings from a yaml file when called the first time.
+func GetLoginBursts() *LoginBursts {
+	loginBurstsLock.Lock()
+	defer loginBurstsLock.Unlock()
+
+	if loginBursts == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			LoginBursts struct {
+				Webhook          string `yaml:"Webhook"`
+				Secret           string `yaml:"Secret"`
+				Window           int    `yaml:"Window"`
+				AccountThreshold int    `yaml:"AccountThreshold"`
+				IPThreshold      int    `yaml:"IPThreshold"`
+			} `yaml:"loginbursts"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.LoginBursts.Window <= 0 {
+			c.LoginBursts.Window = defaultLoginBurstWindow
+		}
+		if c.LoginBursts.AccountThreshold < 0 {
+			c.LoginBursts.AccountThreshold = 0
+		}
+		if c.LoginBursts.IPThreshold < 0 {
+			c.LoginBursts.IPThreshold = 0
+		}
+
+		loginBursts = &LoginBursts{
+			Webhook:          c.LoginBursts.Webhook,
+			Secret:           c.LoginBursts.Secret,
+			Window:           time.Duration(c.LoginBursts.Window) * time.Minute,
+			AccountThreshold: c.LoginBursts.AccountThreshold,
+			IPThreshold:      c.LoginBursts.IPThreshold,
+		}
+	}
+
+	return loginBursts
+}
+
+// ContentBlocks contains html snippets configured by operators, which are shown on the login,
+// consent and registration pages: an announcement on top of the page, a support contact and
+// a legal footer below the page content.
+type ContentBlocks struct {
+	Announcement string
+	Support      string
+	Footer       string
+}
+
+var contentBlocks *ContentBlocks
+var contentBlocksLock = sync.Mutex{}
+
+// GetContentBlocks loads the content blocks from a yaml file when called the first time.
+func GetContentBlocks() *ContentBlocks {
+	contentBlocksLock.Lock()
+	defer contentBlocksLock.Unlock()
+
+	if contentBlocks == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Content struct {
+				Announcement string `yaml:"Announcement"`
+				Support      string `yaml:"Support"`
+				Footer       string `yaml:"Footer"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		contentBlocks = &ContentBlocks{
+			Announcement: c.Content.Announcement,
+			Support:      c.Content.Support,
+			Footer:       c.Content.Footer,
+		}
+	}
+
+	return contentBlocks
+}
+
+// Default minimum time between two activation e-mails for the same account in minutes
+const defaultActivationResendInterval = 15
+
+// Registration contains settings concerning self-registered accounts. If RequireApproval is true,
+// new accounts can only be used after one of the Administrators (account logins) approved them.
+// NotifyEmail receives a notification about each account waiting for approval.
+// ElevatedScopes maps scopes, which accounts may request and Administrators may grant, to
+// their descriptions. Only accounts holding such a scope may authorize clients to use it.
+// The activation e-mail of an account can be resent at most once per ActivationResendInterval.
+type Registration struct {
+	RequireApproval          bool
+	Administrators           []string
+	NotifyEmail              string
+	ElevatedScopes           map[string]string
+	ActivationResendInterval time.Duration
+}
+
+var registration *Registration
+var registrationLock = sync.Mutex{}
+
+// GetRegistration loads the registration settings from a yaml file when called the first time.
+func GetRegistration() *Registration {
+	registrationLock.Lock()
+	defer registrationLock.Unlock()
+
+	if registration == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		r := &struct {
+			Registration struct {
+				RequireApproval          bool              `yaml:"RequireApproval"`
+				Administrators           []string          `yaml:"Administrators"`
+				NotifyEmail              string            `yaml:"NotifyEmail"`
+				ElevatedScopes           map[string]string `yaml:"ElevatedScopes"`
+				ActivationResendInterval int               `yaml:"ActivationResendInterval"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, r)
+		if err != nil {
+			panic(err)
+		}
+
+		if r.Registration.ActivationResendInterval <= 0 {
+			r.Registration.ActivationResendInterval = defaultActivationResendInterval
+		}
+
+		registration = &Registration{
+			RequireApproval:          r.Registration.RequireApproval,
+			Administrators:           r.Registration.Administrators,
+			NotifyEmail:              r.Registration.NotifyEmail,
+			ElevatedScopes:           r.Registration.ElevatedScopes,
+			ActivationResendInterval: time.Duration(r.Registration.ActivationResendInterval) * time.Minute,
+		}
+		if registration.ElevatedScopes == nil {
+			registration.ElevatedScopes = make(map[string]string)
+		}
+	}
+
+	return registration
+}
+
+// IsAdministrator checks whether the account with the given login is allowed to approve accounts.
+func (r *Registration) IsAdministrator(login string) bool {
+	for _, admin := range r.Administrators {
+		if admin == login {
+			return true
+		}
+	}
+	return false
+}
+
+// IsElevated checks whether the scope is an elevated scope, which has to be granted by an administrator.
+func (r *Registration) IsElevated(scope string) bool {
+	_, ok := r.ElevatedScopes[scope]
+	return ok
+}
+
+// Default grant request garbage collection settings
+const (
+	defaultGrantReqGCInterval   = 1 // in minutes
+	defaultGrantReqLoadLifeTime = 3 // in minutes
+	defaultGrantReqMaxPending   = 1000
+)
+
+// GrantRequestGC contains the settings for removing abandoned grant requests. Grant requests
+// are removed every Interval after the GrantReqLifeTime of the server config. If more than
+// MaxPending grant requests are waiting to be completed, the shorter LoadLifeTime is used.
+type GrantRequestGC struct {
+	Interval     time.Duration
+	LoadLifeTime time.Duration
+	MaxPending   int
+}
+
+var grantRequestGC *GrantRequestGC
+var grantRequestGCLock = sync.Mutex{}
+
+// GetGrantRequestGC loads the grant request garbage collection settings from a yaml file
+// when called the first time.
+func GetGrantRequestGC() *GrantRequestGC {
+	grantRequestGCLock.Lock()
+	defer grantRequestGCLock.Unlock()
+
+	if grantRequestGC == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		g := &struct {
+			GrantRequests struct {
+				Interval     int `yaml:"Interval"`
+				LoadLifeTime int `yaml:"LoadLifeTime"`
+				MaxPending   int `yaml:"MaxPending"`
+			} `yaml:"grantrequests"`
+		}{}
+		err = yaml.Unmarshal(content, g)
+		if err != nil {
+			panic(err)
+		}
+
+		if g.GrantRequests.Interval == 0 {
+			g.GrantRequests.Interval = defaultGrantReqGCInterval
+		}
+		if g.GrantRequests.LoadLifeTime == 0 {
+			g.GrantRequests.LoadLifeTime = defaultGrantReqLoadLifeTime
+		}
+		if g.GrantRequests.MaxPending == 0 {
+			g.GrantRequests.MaxPending = defaultGrantReqMaxPending
+		}
+
+		grantRequestGC = &GrantRequestGC{
+			Interval:     time.Duration(g.GrantRequests.Interval) * time.Minute,
+			LoadLifeTime: time.Duration(g.GrantRequests.LoadLifeTime) * time.Minute,
+			MaxPending:   g.GrantRequests.MaxPending,
+		}
+	}
+
+	return grantRequestGC
+}
+
+// Default password expiry settings
+const (
+	defaultPasswordExpiryWarn = 14 // in days
+)
+
+// PasswordExpiry contains the password expiry policy. Passwords expire MaxAge after they were
+// changed; MaxAge zero disables expiry. Policies define a different max age for accounts carrying
+// the respective label. Users are warned Warn before their password expires.
+type PasswordExpiry struct {
+	MaxAge   time.Duration
+	Warn     time.Duration
+	Policies map[string]time.Duration
+}
+
+// MaxAgeFor returns the max password age for an account with the given labels. If multiple
+// policies apply the shortest age is used. Returns zero if passwords of the account do not expire.
+func (p *PasswordExpiry) MaxAgeFor(labels []string) time.Duration {
+	age := p.MaxAge
+	for _, label := range labels {
+		if policy, ok := p.Policies[label]; ok && policy > 0 && (age == 0 || policy < age) {
+			age = policy
+		}
+	}
+	return age
+}
+
+// MinMaxAge returns the shortest max password age of all policies or zero if passwords never expire.
+func (p *PasswordExpiry) MinMaxAge() time.Duration {
+	age := p.MaxAge
+	for _, policy := range p.Policies {
+		if policy > 0 && (age == 0 || policy < age) {
+			age = policy
+		}
+	}
+	return age
+}
+
+var passwordExpiry *PasswordExpiry
+var passwordExpiryLock = sync.Mutex{}
+
+// GetPasswordExpiry loads the password expiry policy from a yaml file when called the first time.
+func GetPasswordExpiry() *PasswordExpiry {
+	passwordExpiryLock.Lock()
+	defer passwordExpiryLock.Unlock()
+
+	if passwordExpiry == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		p := &struct {
+			Passwords struct {
+				MaxAge   int            `yaml:"MaxAge"`
+				Warn     int            `yaml:"Warn"`
+				Policies map[string]int `yaml:"Policies"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, p)
+		if err != nil {
+			panic(err)
+		}
+
+		if p.Passwords.Warn == 0 {
+			p.Passwords.Warn = defaultPasswordExpiryWarn
+		}
+
+		const day = 24 * time.Hour
+		passwordExpiry = &PasswordExpiry{
+			MaxAge:   time.Duration(p.Passwords.MaxAge) * day,
+			Warn:     time.Duration(p.Passwords.Warn) * day,
+			Policies: make(map[string]time.Duration),
+		}
+		for label, age := range p.Passwords.Policies {
+			passwordExpiry.Policies[label] = time.Duration(age) * day
+		}
+	}
+
+	return passwordExpiry
+}
+
+// Default password strength settings
+const (
+	defaultPasswordMinLength = 6
+)
+
+// PasswordStrength contains the password strength policy: new passwords must have at least
+// MinLength characters and an estimated strength score (0 to 4) of at least MinScore.
+type PasswordStrength struct {
+	MinLength int
+	MinScore  int
+}
+
+var passwordStrength *PasswordStrength
+var passwordStrengthLock = sync.Mutex{}
+
+// GetPasswordStrength loads the password strength policy from a yaml file when called the first time.
+func GetPasswordStrength() *PasswordStrength {
+	passwordStrengthLock.Lock()
+	defer passwordStrengthLock.Unlock()
+
+	if passwordStrength == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		p := &struct {
+			Passwords struct {
+				MinLength int `yaml:"MinLength"`
+				MinScore  int `yaml:"MinScore"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, p)
+		if err != nil {
+			panic(err)
+		}
+
+		if p.Passwords.MinLength <= 0 {
+			p.Passwords.MinLength = defaultPasswordMinLength
+		}
+		if p.Passwords.MinScore < 0 || p.Passwords.MinScore > 4 {
+			panic(fmt.Sprintf("Invalid password MinScore %d, expected a value from 0 to 4", p.Passwords.MinScore))
+		}
+
+		passwordStrength = &PasswordStrength{
+			MinLength: p.Passwords.MinLength,
+			MinScore:  p.Passwords.MinScore,
+		}
+	}
+
+	return passwordStrength
+}
+
+// Modes of the breached password check
+const (
+	BreachCheckOff    = "off"
+	BreachCheckWarn   = "warn"
+	BreachCheckReject = "reject"
+)
+
+// Default breached password check settings
+const (
+	defaultBreachAPIURL    = "https://api.pwnedpasswords.com/range/"
+	defaultBreachTimeout   = 3  // in seconds
+	defaultBreachCacheTime = 60 // in minutes
+)
+
+// BreachedPasswords contains the settings for looking up new passwords in the HaveIBeenPwned
+// dataset. Mode is one of off, warn or reject. The range API at APIURL is queried with the first
+// five characters of the SHA-1 hash of a password; if Dir is set, an offline copy of the dataset
+// with one file per range is used instead. Ranges are cached for CacheTime.
+type BreachedPasswords struct {
+	Mode      string
+	APIURL    string
+	Dir       string
+	Timeout   time.Duration
+	CacheTime time.Duration
+}
+
+var breachedPasswords *BreachedPasswords
+var breachedPasswordsLock = sync.Mutex{}
+
+// GetBreachedPasswords loads the breached password check settings from a yaml file when called the first time.
+func GetBreachedPasswords() *BreachedPasswords {
+	breachedPasswordsLock.Lock()
+	defer breachedPasswordsLock.Unlock()
+
+	if breachedPasswords == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		b := &struct {
+			BreachedPasswords struct {
+				Mode      string `yaml:"Mode"`
+				APIURL    string `yaml:"APIURL"`
+				Dir       string `yaml:"Dir"`
+				Timeout   int    `yaml:"Timeout"`
+				CacheTime int    `yaml:"CacheTime"`
+			} `yaml:"breachedpasswords"`
+		}{}
+		err = yaml.Unmarshal(content, b)
+		if err != nil {
+			panic(err)
+		}
+
+		config := b.BreachedPasswords
+		switch config.Mode {
+		case "":
+			config.Mode = BreachCheckOff
+		case BreachCheckOff, BreachCheckWarn, BreachCheckReject:
+		default:
+			panic(fmt.Sprintf("Invalid breached password Mode '%s', expected off, warn or reject", config.Mode))
+		}
+		if config.APIURL == "" {
+			config.APIURL = defaultBreachAPIURL
+		}
+		if config.Dir != "" && !filepath.IsAbs(config.Dir) {
+			config.Dir = filepath.Join(configPath, config.Dir)
+		}
+		if config.Timeout <= 0 {
+			config.Timeout = defaultBreachTimeout
+		}
+		if config.CacheTime <= 0 {
+			config.CacheTime = defaultBreachCacheTime
+		}
+
+		breachedPasswords = &BreachedPasswords{
+			Mode:      config.Mode,
+			APIURL:    config.APIURL,
+			Dir:       config.Dir,
+			Timeout:   time.Duration(config.Timeout) * time.Second,
+			CacheTime: time.Duration(config.CacheTime) * time.Minute,
+		}
+	}
+
+	return breachedPasswords
+}
+
+// Default number of soft bounces after which an e-mail address is suppressed
+const defaultBounceSoftLimit = 3
+
+// EmailBounces contains the settings for processing bounces reported by the mail provider.
+// The bounce webhook is enabled if Secret is set. An address is suppressed after SoftLimit
+// soft bounces or a single hard bounce or complaint.
+type EmailBounces struct {
+	Secret    string
+	SoftLimit int
+}
+
+var emailBounces *EmailBounces
+var emailBouncesLock = sync.Mutex{}
+
+// GetEmailBounces loads the bounce processing settings from a yaml file when called the first time.
+func GetEmailBounces() *EmailBounces {
+	emailBouncesLock.Lock()
+	defer emailBouncesLock.Unlock()
+
+	if emailBounces == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		b := &struct {
+			Bounces struct {
+				Secret    string `yaml:"Secret"`
+				SoftLimit int    `yaml:"SoftLimit"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, b)
+		if err != nil {
+			panic(err)
+		}
+
+		if b.Bounces.SoftLimit == 0 {
+			b.Bounces.SoftLimit = defaultBounceSoftLimit
+		}
+
+		emailBounces = &EmailBounces{
+			Secret:    b.Bounces.Secret,
+			SoftLimit: b.Bounces.SoftLimit,
+		}
+	}
+
+	return emailBounces
+}
+
+// Default validity of issued ssh certificates in minutes
+const defaultSSHCertValidity = 60
+
+// SSHCertificateAuthority contains the settings for issuing ssh certificates. Certificates are
+// only issued if KeyFile (PEM encoded private key of the CA) is set and are valid for Validity.
+// Extensions are added to each certificate, e.g. 'permit-pty'.
+type SSHCertificateAuthority struct {
+	KeyFile    string
+	Validity   time.Duration
+	Extensions []string
+}
+
+var sshCA *SSHCertificateAuthority
+var sshCALock = sync.Mutex{}
+
+// GetSSHCertificateAuthority loads the ssh certificate authority settings from a yaml file
+// when called the first time.
+func GetSSHCertificateAuthority() *SSHCertificateAuthority {
+	sshCALock.Lock()
+	defer sshCALock.Unlock()
+
+	if sshCA == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			SSHCA struct {
+				KeyFile    string   `yaml:"KeyFile"`
+				Validity   int      `yaml:"Validity"`
+				Extensions []string `yaml:"Extensions"`
+			} `yaml:"sshca"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.SSHCA.Validity == 0 {
+			c.SSHCA.Validity = defaultSSHCertValidity
+		}
+		keyFile := c.SSHCA.KeyFile
+		if keyFile != "" && !filepath.IsAbs(keyFile) {
+			keyFile = filepath.Join(configPath, keyFile)
+		}
+
+		sshCA = &SSHCertificateAuthority{
+			KeyFile:    keyFile,
+			Validity:   time.Duration(c.SSHCA.Validity) * time.Minute,
+			Extensions: c.SSHCA.Extensions,
+		}
+	}
+
+	return sshCA
+}
+
+// Retention contains the data retention policy: the cleaner purges data of each policy
+// (e.g. 'refresh_tokens' or 'disabled_accounts') which is older than the time configured
+// for the policy. Policies without an explicit setting use Default; zero keeps data forever.
+type Retention struct {
+	Default  time.Duration
+	Policies map[string]time.Duration
+}
+
+// KeepFor returns how long data of the given policy is kept or zero if it is kept forever.
+func (r *Retention) KeepFor(policy string) time.Duration {
+	if keep, ok := r.Policies[policy]; ok {
+		return keep
+	}
+	return r.Default
+}
+
+var retention *Retention
+var retentionLock = sync.Mutex{}
+
+// GetRetention loads the data retention policy from a yaml file when called the first time.
+func GetRetention() *Retention {
+	retentionLock.Lock()
+	defer retentionLock.Unlock()
+
+	if retention == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		r := &struct {
+			Retention struct {
+				Default  int            `yaml:"Default"`
+				Policies map[string]int `yaml:"Policies"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, r)
+		if err != nil {
+			panic(err)
+		}
+
+		const day = 24 * time.Hour
+		retention = &Retention{
+			Default:  time.Duration(r.Retention.Default) * day,
+			Policies: make(map[string]time.Duration),
+		}
+		for policy, days := range r.Retention.Policies {
+			retention.Policies[policy] = time.Duration(days) * day
+		}
+	}
+
+	return retention
+}
+
+// Default account dormancy settings
+const (
+	defaultDormancyWarn         = 30  // in days
+	defaultDormancyArchiveAfter = 180 // in days
+)
+
+// Dormancy contains the account dormancy policy: accounts without login for Months months
+// are warned by e-mail, disabled Warn after the warning and archived ArchiveAfter after they
+// were disabled. Months zero disables the policy. Accounts carrying one of the ExemptLabels
+// (e.g. bot or machine accounts) are never considered dormant.
+type Dormancy struct {
+	Months       int
+	Warn         time.Duration
+	ArchiveAfter time.Duration
+	ExemptLabels []string
+}
+
+// IsExempt returns true if an account with the given labels is exempt from the policy.
+func (d *Dormancy) IsExempt(labels []string) bool {
+	for _, label := range labels {
+		for _, exempt := range d.ExemptLabels {
+			if label == exempt {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Cutoff returns the time before which the last login of an account must lie at the given time
+// for the account to be dormant.
+func (d *Dormancy) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, -d.Months, 0)
+}
+
+var dormancy *Dormancy
+var dormancyLock = sync.Mutex{}
+
+// GetDormancy loads the account dormancy policy from a yaml file when called the first time.
+func GetDormancy() *Dormancy {
+	dormancyLock.Lock()
+	defer dormancyLock.Unlock()
+
+	if dormancy == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		d := &struct {
+			Dormancy struct {
+				Months       int      `yaml:"Months"`
+				Warn         int      `yaml:"Warn"`
+				ArchiveAfter int      `yaml:"ArchiveAfter"`
+				ExemptLabels []string `yaml:"ExemptLabels"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, d)
+		if err != nil {
+			panic(err)
+		}
+
+		if d.Dormancy.Warn == 0 {
+			d.Dormancy.Warn = defaultDormancyWarn
+		}
+		if d.Dormancy.ArchiveAfter == 0 {
+			d.Dormancy.ArchiveAfter = defaultDormancyArchiveAfter
+		}
+
+		const day = 24 * time.Hour
+		dormancy = &Dormancy{
+			Months:       d.Dormancy.Months,
+			Warn:         time.Duration(d.Dormancy.Warn) * day,
+			ArchiveAfter: time.Duration(d.Dormancy.ArchiveAfter) * day,
+			ExemptLabels: d.Dormancy.ExemptLabels,
+		}
+	}
+
+	return dormancy
+}
+
+// Default login CAPTCHA settings
+const (
+	defaultLoginCaptchaWindow = 15 // in minutes
+)
+
+// LoginCaptcha contains the thresholds after which the login form requires a CAPTCHA: IPFailures
+// failed logins from an address or AccountFailures failed logins for an account within Window.
+// A threshold of zero disables the respective check.
+type LoginCaptcha struct {
+	IPFailures      int
+	AccountFailures int
+	Window          time.Duration
+}
+
+var loginCaptcha *LoginCaptcha
+var loginCaptchaLock = sync.Mutex{}
+
+// GetLoginCaptcha loads the login CAPTCHA settings from a yaml file when called the first time.
+func GetLoginCaptcha() *LoginCaptcha {
+	loginCaptchaLock.Lock()
+	defer loginCaptchaLock.Unlock()
+
+	if loginCaptcha == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Login struct {
+				CaptchaIPFailures      int `yaml:"CaptchaIPFailures"`
+				CaptchaAccountFailures int `yaml:"CaptchaAccountFailures"`
+				CaptchaWindow          int `yaml:"CaptchaWindow"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.Login.CaptchaWindow == 0 {
+			c.Login.CaptchaWindow = defaultLoginCaptchaWindow
+		}
+
+		loginCaptcha = &LoginCaptcha{
+			IPFailures:      c.Login.CaptchaIPFailures,
+			AccountFailures: c.Login.CaptchaAccountFailures,
+			Window:          time.Duration(c.Login.CaptchaWindow) * time.Minute,
+		}
+	}
+
+	return loginCaptcha
+}
+
+// Default consent settings
+const (
+	defaultConsentPolicyVersion = "1"
+)
+
+// Consent contains the version of the privacy policy users agree to when approving a client,
+// which is recorded in every consent receipt, and an optional URL of the policy.
+type Consent struct {
+	PolicyVersion string
+	PolicyURL     string
+}
+
+var consent *Consent
+var consentLock = sync.Mutex{}
+
+// GetConsent loads the consent settings from a yaml file when called the first time.
+func GetConsent() *Consent {
+	consentLock.Lock()
+	defer consentLock.Unlock()
+
+	if consent == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Consent struct {
+				PolicyVersion string `yaml:"PolicyVersion"`
+				PolicyURL     string `yaml:"PolicyURL"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.Consent.PolicyVersion == "" {
+			c.Consent.PolicyVersion = defaultConsentPolicyVersion
+		}
+
+		consent = &Consent{
+			PolicyVersion: c.Consent.PolicyVersion,
+			PolicyURL:     c.Consent.PolicyURL,
+		}
+	}
+
+	return consent
+}
+
+// Available session store backends
+const (
+	SessionStorePostgres = "postgres"
+	SessionStoreRedis    = "redis"
+	SessionStoreMemory   = "memory"
+)
+
+// SessionStore selects where login sessions are kept. Sessions in Redis can be shared by
+// several gin-auth instances, sessions in memory are lost when gin-auth is stopped.
+// If MaxPerAccount is greater than zero, a login which exceeds this number of simultaneous
+// sessions of an account ends the oldest sessions.
+type SessionStore struct {
+	Backend       string
+	RedisAddress  string
+	RedisPassword string
+	RedisDB       int
+	RedisPrefix   string
+	MaxPerAccount int
+}
+
+var sessionStore *SessionStore
+var sessionStoreLock = sync.Mutex{}
+
+// GetSessionStore loads the session store settings from a yaml file when called the first time.
+func GetSessionStore() *SessionStore {
+	sessionStoreLock.Lock()
+	defer sessionStoreLock.Unlock()
+
+	if sessionStore == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Sessions struct {
+				Backend       string `yaml:"Backend"`
+				RedisAddress  string `yaml:"RedisAddress"`
+				RedisPassword string `yaml:"RedisPassword"`
+				RedisDB       int    `yaml:"RedisDB"`
+				RedisPrefix   string `yaml:"RedisPrefix"`
+				MaxPerAccount int    `yaml:"MaxPerAccount"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		switch c.Sessions.Backend {
+		case "":
+			c.Sessions.Backend = SessionStorePostgres
+		case SessionStorePostgres, SessionStoreRedis, SessionStoreMemory:
+		default:
+			panic(fmt.Sprintf("Unknown session store backend '%s'", c.Sessions.Backend))
+		}
+		if c.Sessions.RedisAddress == "" {
+			c.Sessions.RedisAddress = "localhost:6379"
+		}
+		if c.Sessions.RedisPrefix == "" {
+			c.Sessions.RedisPrefix = "gin-auth:"
+		}
+		if c.Sessions.MaxPerAccount < 0 {
+			c.Sessions.MaxPerAccount = 0
+		}
+
+		sessionStore = &SessionStore{
+			Backend:       c.Sessions.Backend,
+			RedisAddress:  c.Sessions.RedisAddress,
+			RedisPassword: c.Sessions.RedisPassword,
+			RedisDB:       c.Sessions.RedisDB,
+			RedisPrefix:   c.Sessions.RedisPrefix,
+			MaxPerAccount: c.Sessions.MaxPerAccount,
+		}
+	}
+
+	return sessionStore
+}
+
+// AccountHooks contains the settings of the webhook which is notified about created and deleted
+// accounts and changed passwords. If Secret is set each request is signed with it.
+type AccountHooks struct {
+	Webhook string
+	Secret  string
+}
+
+var accountHooks *AccountHooks
+var accountHooksLock = sync.Mutex{}
+
+// GetAccountHooks loads the account hook settings from a yaml file when called the first time.
+func GetAccountHooks() *AccountHooks {
+	accountHooksLock.Lock()
+	defer accountHooksLock.Unlock()
+
+	if accountHooks == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Hooks struct {
+				Webhook string `yaml:"Webhook"`
+				Secret  string `yaml:"Secret"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		accountHooks = &AccountHooks{
+			Webhook: c.Hooks.Webhook,
+			Secret:  c.Hooks.Secret,
+		}
+	}
+
+	return accountHooks
+}
+
+// Default account code settings
+const (
+	defaultResetCodeLifeTime        = 24  // in hours
+	defaultVerificationCodeLifeTime = 168 // in hours
+	defaultRecoveryCodeLifeTime     = 168 // in hours
+)
+
+// AccountCodes contains the settings for codes sent by e-mail to activate accounts, reset passwords,
+// verify e-mail addresses and revert changes of e-mail addresses and passwords. Codes are stored as
+// HMAC with Secret. Activation codes are valid as long as unused accounts are kept (see
+// UnusedAccountLifeTime).
+type AccountCodes struct {
+	Secret               []byte
+	ResetLifeTime        time.Duration
+	VerificationLifeTime time.Duration
+	RecoveryLifeTime     time.Duration
+	IsSecretGenerated    bool
+}
+
+var accountCodes *AccountCodes
+var accountCodesLock = sync.Mutex{}
+
+// GetAccountCodes loads the account code settings from a yaml file when called the first time.
+// If no secret is configured a random secret is used, thus codes are only valid until restart.
+func GetAccountCodes() *AccountCodes {
+	accountCodesLock.Lock()
+	defer accountCodesLock.Unlock()
+
+	if accountCodes == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Codes struct {
+				Secret               string `yaml:"Secret"`
+				ResetLifeTime        int    `yaml:"ResetLifeTime"`
+				VerificationLifeTime int    `yaml:"VerificationLifeTime"`
+				RecoveryLifeTime     int    `yaml:"RecoveryLifeTime"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.Codes.ResetLifeTime <= 0 {
+			c.Codes.ResetLifeTime = defaultResetCodeLifeTime
+		}
+		if c.Codes.VerificationLifeTime <= 0 {
+			c.Codes.VerificationLifeTime = defaultVerificationCodeLifeTime
+		}
+		if c.Codes.RecoveryLifeTime <= 0 {
+			c.Codes.RecoveryLifeTime = defaultRecoveryCodeLifeTime
+		}
+
+		accountCodes = &AccountCodes{
+			Secret:               []byte(c.Codes.Secret),
+			ResetLifeTime:        time.Duration(c.Codes.ResetLifeTime) * time.Hour,
+			VerificationLifeTime: time.Duration(c.Codes.VerificationLifeTime) * time.Hour,
+			RecoveryLifeTime:     time.Duration(c.Codes.RecoveryLifeTime) * time.Hour,
+		}
+		if c.Codes.Secret == "" {
+			accountCodes.Secret = make([]byte, 32)
+			_, err = rand.Read(accountCodes.Secret)
+			if err != nil {
+				panic(err)
+			}
+			accountCodes.IsSecretGenerated = true
+		}
+	}
+
+	return accountCodes
+}
+
+// Default token validation settings
+const (
+	defaultValidationMaxBatchSize   = 100
+	defaultValidationRevocationWait = 30
+)
+
+// TokenValidation contains the settings for caching token validation responses, the maximum
+// number of tokens which can be validated at once and how long requests to the revocation feed
+// wait for new revocations.
+type TokenValidation struct {
+	MaxAge               time.Duration
+	StaleWhileRevalidate time.Duration
+	MaxBatchSize         int
+	RevocationWait       time.Duration
+}
+
+var tokenValidation *TokenValidation
+var tokenValidationLock = sync.Mutex{}
+
+// GetTokenValidation loads the token validation settings from a yaml file when called the first time.
+func GetTokenValidation() *TokenValidation {
+	tokenValidationLock.Lock()
+	defer tokenValidationLock.Unlock()
+
+	if tokenValidation == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Validation struct {
+				MaxAge               int `yaml:"MaxAge"`
+				StaleWhileRevalidate int `yaml:"StaleWhileRevalidate"`
+				MaxBatchSize         int `yaml:"MaxBatchSize"`
+				RevocationWait       int `yaml:"RevocationWait"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.Validation.MaxAge < 0 {
+			c.Validation.MaxAge = 0
+		}
+		if c.Validation.StaleWhileRevalidate < 0 {
+			c.Validation.StaleWhileRevalidate = 0
+		}
+		if c.Validation.MaxBatchSize <= 0 {
+			c.Validation.MaxBatchSize = defaultValidationMaxBatchSize
+		}
+		if c.Validation.RevocationWait <= 0 {
+			c.Validation.RevocationWait = defaultValidationRevocationWait
+		}
+
+		tokenValidation = &TokenValidation{
+			MaxAge:               time.Duration(c.Validation.MaxAge) * time.Second,
+			StaleWhileRevalidate: time.Duration(c.Validation.StaleWhileRevalidate) * time.Second,
+			MaxBatchSize:         c.Validation.MaxBatchSize,
+			RevocationWait:       time.Duration(c.Validation.RevocationWait) * time.Second,
+		}
+	}
+
+	return tokenValidation
+}
+
+// Default number of announcement e-mails queued per mail queue interval
+const defaultAnnouncementBatchSize = 50
+
+// Announcements contains the settings for sending announcements to many accounts.
+type Announcements struct {
+	BatchSize int
+}
+
+var announcements *Announcements
+var announcementsLock = sync.Mutex{}
+
+// GetAnnouncements loads the announcement settings from a yaml file when called the first time.
+func GetAnnouncements() *Announcements {
+	announcementsLock.Lock()
+	defer announcementsLock.Unlock()
+
+	if announcements == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Announcements struct {
+				BatchSize int `yaml:"BatchSize"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.Announcements.BatchSize <= 0 {
+			c.Announcements.BatchSize = defaultAnnouncementBatchSize
+		}
+
+		announcements = &Announcements{BatchSize: c.Announcements.BatchSize}
+	}
+
+	return announcements
+}
+
+// CookieKeys contains the keys for signing session cookies. The first key is used for
+// signing, all keys are accepted.
+type CookieKeys struct {
+	Keys           [][]byte
+	IsKeyGenerated bool
+}
+
+var cookieKeys *CookieKeys
+var cookieKeysLock = sync.Mutex{}
+
+// GetCookieKeys loads the cookie keys from a yaml file when called the first time.
+// If no key is configured a random key is used, thus cookies are only valid until restart.
+func GetCookieKeys() *CookieKeys {
+	cookieKeysLock.Lock()
+	defer cookieKeysLock.Unlock()
+
+	if cookieKeys == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Cookies struct {
+				Keys []string `yaml:"Keys"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		cookieKeys = &CookieKeys{Keys: make([][]byte, 0, len(c.Cookies.Keys))}
+		for _, key := range c.Cookies.Keys {
+			if key != "" {
+				cookieKeys.Keys = append(cookieKeys.Keys, []byte(key))
+			}
+		}
+		if len(cookieKeys.Keys) == 0 {
+			key := make([]byte, 32)
+			_, err = rand.Read(key)
+			if err != nil {
+				panic(err)
+			}
+			cookieKeys.Keys = append(cookieKeys.Keys, key)
+			cookieKeys.IsKeyGenerated = true
+		}
+	}
+
+	return cookieKeys
+}
+
+// ClientHistory contains the settings for former names and redirect URIs of clients. Former names are
+// accepted for GracePeriod and former redirect URIs for RedirectURIGracePeriod, zero disables either.
+type ClientHistory struct {
+	GracePeriod            time.Duration
+	RedirectURIGracePeriod time.Duration
+}
+
+var clientHistory *ClientHistory
+var clientHistoryLock = sync.Mutex{}
+
+// GetClientHistory loads the client history settings from a yaml file when called the first time.
+func GetClientHistory() *ClientHistory {
+	clientHistoryLock.Lock()
+	defer clientHistoryLock.Unlock()
+
+	if clientHistory == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			ClientHistory struct {
+				GracePeriod            int `yaml:"GracePeriod"`
+				RedirectURIGracePeriod int `yaml:"RedirectURIGracePeriod"`
+			} `yaml:"clienthistory"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.ClientHistory.GracePeriod < 0 {
+			c.ClientHistory.GracePeriod = 0
+		}
+		if c.ClientHistory.RedirectURIGracePeriod < 0 {
+			c.ClientHistory.RedirectURIGracePeriod = 0
+		}
+
+		clientHistory = &ClientHistory{
+			GracePeriod:            time.Duration(c.ClientHistory.GracePeriod) * 24 * time.Hour,
+			RedirectURIGracePeriod: time.Duration(c.ClientHistory.RedirectURIGracePeriod) * 24 * time.Hour,
+		}
+	}
+
+	return clientHistory
+}
+
+// Defaults for request limits
+const (
+	defaultMaxBodySize        = 1 << 20 // in bytes
+	defaultMaxJSONDepth       = 32
+	defaultMaxJSONArrayLength = 1000
+)
+
+// RequestLimits contains the limits for request bodies. Routes maps path templates of routes
+// (e.g. '/api/accounts/{account}') to a body size which replaces MaxBodySize for this route.
+type RequestLimits struct {
+	MaxBodySize         int64
+	Routes              map[string]int64
+	MaxJSONDepth        int
+	MaxJSONArrayLength  int
+	RejectUnknownFields bool
+}
+
+// BodySize returns the maximum body size in bytes for a route given by its path template.
+func (limits *RequestLimits) BodySize(route string) int64 {
+	if size, ok := limits.Routes[route]; ok {
+		return size
+	}
+	return limits.MaxBodySize
+}
+
+var requestLimits *RequestLimits
+var requestLimitsLock = sync.Mutex{}
+
+// GetRequestLimits loads the request limits from a yaml file when called the first time.
+func GetRequestLimits() *RequestLimits {
+	requestLimitsLock.Lock()
+	defer requestLimitsLock.Unlock()
+
+	if requestLimits == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			RequestLimits struct {
+				MaxBodySize         int64            `yaml:"MaxBodySize"`
+				Routes              map[string]int64 `yaml:"Routes"`
+				MaxJSONDepth        int              `yaml:"MaxJSONDepth"`
+				MaxJSONArrayLength  int              `yaml:"MaxJSONArrayLength"`
+				RejectUnknownFields bool             `yaml:"RejectUnknownFields"`
+			} `yaml:"requestlimits"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.RequestLimits.MaxBodySize <= 0 {
+			c.RequestLimits.MaxBodySize = defaultMaxBodySize
+		}
+		if c.RequestLimits.MaxJSONDepth <= 0 {
+			c.RequestLimits.MaxJSONDepth = defaultMaxJSONDepth
+		}
+		if c.RequestLimits.MaxJSONArrayLength <= 0 {
+			c.RequestLimits.MaxJSONArrayLength = defaultMaxJSONArrayLength
+		}
+		if c.RequestLimits.Routes == nil {
+			c.RequestLimits.Routes = make(map[string]int64)
+		}
+
+		requestLimits = &RequestLimits{
+			MaxBodySize:         c.RequestLimits.MaxBodySize,
+			Routes:              c.RequestLimits.Routes,
+			MaxJSONDepth:        c.RequestLimits.MaxJSONDepth,
+			MaxJSONArrayLength:  c.RequestLimits.MaxJSONArrayLength,
+			RejectUnknownFields: c.RequestLimits.RejectUnknownFields,
+		}
+	}
+
+	return requestLimits
+}
+
+// RefreshTokens contains the expiry settings of refresh tokens. A refresh token expires LifeTime
+// after it was issued, regardless of its use, and IdleTime after it was last used. Zero disables
+// the respective expiry.
+type RefreshTokens struct {
+	LifeTime time.Duration
+	IdleTime time.Duration
+}
+
+var refreshTokens *RefreshTokens
+var refreshTokensLock = sync.Mutex{}
+
+// GetRefreshTokens loads the refresh token settings from a yaml file when called the first time.
+func GetRefreshTokens() *RefreshTokens {
+	refreshTokensLock.Lock()
+	defer refreshTokensLock.Unlock()
+
+	if refreshTokens == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			RefreshTokens struct {
+				LifeTime int `yaml:"LifeTime"`
+				IdleTime int `yaml:"IdleTime"`
+			} `yaml:"refreshtokens"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.RefreshTokens.LifeTime < 0 {
+			c.RefreshTokens.LifeTime = 0
+		}
+		if c.RefreshTokens.IdleTime < 0 {
+			c.RefreshTokens.IdleTime = 0
+		}
+
+		refreshTokens = &RefreshTokens{
+			LifeTime: time.Duration(c.RefreshTokens.LifeTime) * 24 * time.Hour,
+			IdleTime: time.Duration(c.RefreshTokens.IdleTime) * 24 * time.Hour,
+		}
+	}
+
+	return refreshTokens
+}
+
+// Clock contains the settings for expiry checks.
+type Clock struct {
+	Leeway time.Duration
+}
+
+var clock *Clock
+var clockLock = sync.Mutex{}
+
+// GetClock loads the clock settings from a yaml file when called the first time.
+func GetClock() *Clock {
+	clockLock.Lock()
+	defer clockLock.Unlock()
+
+	if clock == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Clock struct {
+				Leeway int `yaml:"Leeway"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.Clock.Leeway < 0 {
+			c.Clock.Leeway = 0
+		}
+
+		clock = &Clock{Leeway: time.Duration(c.Clock.Leeway) * time.Second}
+	}
+
+	return clock
+}
+
+// Default policy settings
+const defaultPolicyTimeout = 5 // in seconds
+
+// Policy contains the settings of an external policy engine, which is consulted for requests
+// to the admin API and for scope grants. URL is the Open Policy Agent data API endpoint of the
+// decision, e.g. http://localhost:8181/v1/data/ginauth/allow. Without URL no engine is used.
+// If FailOpen is set requests are allowed if the engine can not be reached.
+type Policy struct {
+	URL      string
+	Timeout  time.Duration
+	FailOpen bool
+}
+
+var policy *Policy
+var policyLock = sync.Mutex{}
+
+// GetPolicy loads the policy engine settings from a yaml file when called the first time.
+func GetPolicy() *Policy {
+	policyLock.Lock()
+	defer policyLock.Unlock()
+
+	if policy == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Policy struct {
+				URL      string `yaml:"URL"`
+				Timeout  int    `yaml:"Timeout"`
+				FailOpen bool   `yaml:"FailOpen"`
+			}
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.Policy.Timeout <= 0 {
+			c.Policy.Timeout = defaultPolicyTimeout
+		}
+
+		policy = &Policy{
+			URL:      c.Policy.URL,
+			Timeout:  time.Duration(c.Policy.Timeout) * time.Second,
+			FailOpen: c.Policy.FailOpen,
+		}
+	}
+
+	return policy
+}
+
+// ClientScopes contains the settings for the scopes clients may request. If Strict is set
+// clients may only request scopes of their whitelist, their allowed scopes and scopes
+// granted to them by an administrator.
+type ClientScopes struct {
+	Strict bool
+}
+
+var clientScopes *ClientScopes
+var clientScopesLock = sync.Mutex{}
+
+// GetClientScopes loads the client scope settings from a yaml file when called the first time.
+func GetClientScopes() *ClientScopes {
+	clientScopesLock.Lock()
+	defer clientScopesLock.Unlock()
+
+	if clientScopes == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			ClientScopes struct {
+				Strict bool `yaml:"Strict"`
+			} `yaml:"clientscopes"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		clientScopes = &ClientScopes{Strict: c.ClientScopes.Strict}
+	}
+
+	return clientScopes
+}
+
+// FeatureFlag is the configured default of a feature flag. An enabled flag is on for all accounts,
+// otherwise it is on for the accounts with the logins in Accounts and for Percentage percent of
+// all other accounts.
+type FeatureFlag struct {
+	Enabled    bool
+	Percentage int
+	Accounts   []string
+}
+
+var featureFlags map[string]FeatureFlag
+var featureFlagsLock = sync.Mutex{}
+
+// GetFeatureFlags loads the feature flags from a yaml file when called the first time.
+// Flags which are not configured are off.
+func GetFeatureFlags() map[string]FeatureFlag {
+	featureFlagsLock.Lock()
+	defer featureFlagsLock.Unlock()
+
+	if featureFlags == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Features map[string]struct {
+				Enabled    bool     `yaml:"Enabled"`
+				Percentage int      `yaml:"Percentage"`
+				Accounts   []string `yaml:"Accounts"`
+			} `yaml:"features"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		featureFlags = make(map[string]FeatureFlag)
+		for name, f := range c.Features {
+			if f.Percentage < 0 || f.Percentage > 100 {
+				panic(fmt.Sprintf("Percentage of feature flag '%s' must be between 0 and 100", name))
+			}
+			featureFlags[name] = FeatureFlag{Enabled: f.Enabled, Percentage: f.Percentage, Accounts: f.Accounts}
+		}
+	}
+
+	return featureFlags
+}
+
+// Targets and formats of the audit export
+const (
+	AuditExportSyslog = "syslog"
+	AuditExportHTTP   = "http"
+	AuditFormatJSON   = "json"
+	AuditFormatCEF    = "cef"
+)
+
+// Default audit export settings
+const (
+	defaultAuditExportBufferSize    = 10000
+	defaultAuditExportBatchSize     = 100
+	defaultAuditExportFlushInterval = 1 // in seconds
+	defaultAuditExportTimeout       = 5 // in seconds
+)
+
+// AuditExport contains the settings for the export of audit events to a SIEM. Target selects
+// either syslog at Address (e.g. tcp://siem.example.org:514) or an HTTP collector at URL, which
+// is authorized with Token as bearer token. Without Target audit events are not exported.
+// Events are buffered and sent in batches of BatchSize events at least every FlushInterval.
+// If the buffer is full events are dropped, unless Block is set: logging then waits until the
+// exporter catches up.
+type AuditExport struct {
+	Target        string
+	Format        string
+	Address       string
+	URL           string
+	Token         string
+	BufferSize    int
+	BatchSize     int
+	FlushInterval time.Duration
+	Timeout       time.Duration
+	Block         bool
+}
+
+var auditExport *AuditExport
+var auditExportLock = sync.Mutex{}
+
+// GetAuditExport loads the audit export settings from a yaml file when called the first time.
+func GetAuditExport() *AuditExport {
+	auditExportLock.Lock()
+	defer auditExportLock.Unlock()
+
+	if auditExport == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			AuditExport struct {
+				Target        string `yaml:"Target"`
+				Format        string `yaml:"Format"`
+				Address       string `yaml:"Address"`
+				URL           string `yaml:"URL"`
+				Token         string `yaml:"Token"`
+				BufferSize    int    `yaml:"BufferSize"`
+				BatchSize     int    `yaml:"BatchSize"`
+				FlushInterval int    `yaml:"FlushInterval"`
+				Timeout       int    `yaml:"Timeout"`
+				Block         bool   `yaml:"Block"`
+			} `yaml:"auditexport"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		switch c.AuditExport.Target {
+		case "", AuditExportSyslog, AuditExportHTTP:
+		default:
+			panic(fmt.Sprintf("Unknown audit export target '%s'", c.AuditExport.Target))
+		}
+		switch c.AuditExport.Format {
+		case "":
+			c.AuditExport.Format = AuditFormatJSON
+		case AuditFormatJSON, AuditFormatCEF:
+		default:
+			panic(fmt.Sprintf("Unknown audit export format '%s'", c.AuditExport.Format))
+		}
+		if c.AuditExport.BufferSize <= 0 {
+			c.AuditExport.BufferSize = defaultAuditExportBufferSize
+		}
+		if c.AuditExport.BatchSize <= 0 {
+			c.AuditExport.BatchSize = defaultAuditExportBatchSize
+		}
+		if c.AuditExport.FlushInterval <= 0 {
+			c.AuditExport.FlushInterval = defaultAuditExportFlushInterval
+		}
+		if c.AuditExport.Timeout <= 0 {
+			c.AuditExport.Timeout = defaultAuditExportTimeout
+		}
+
+		auditExport = &AuditExport{
+			Target:        c.AuditExport.Target,
+			Format:        c.AuditExport.Format,
+			Address:       c.AuditExport.Address,
+			URL:           c.AuditExport.URL,
+			Token:         c.AuditExport.Token,
+			BufferSize:    c.AuditExport.BufferSize,
+			BatchSize:     c.AuditExport.BatchSize,
+			FlushInterval: time.Duration(c.AuditExport.FlushInterval) * time.Second,
+			Timeout:       time.Duration(c.AuditExport.Timeout) * time.Second,
+			Block:         c.AuditExport.Block,
+		}
+	}
+
+	return auditExport
+}
+
+// Default time in seconds for which the metrics are cached
+const defaultMetricsCacheTime = 30
+
+// Metrics contains the settings of the OpenMetrics endpoint. If Token is set, scrapers have
+// to send it as bearer token, otherwise only requests from internal networks are served.
+// The gauges are computed at most once per CacheTime.
+type Metrics struct {
+	Token     string
+	CacheTime time.Duration
+}
+
+var metrics *Metrics
+var metricsLock = sync.Mutex{}
+
+// GetMetrics loads the metrics settings from a yaml file when called the first time.
+func GetMetrics() *Metrics {
+	metricsLock.Lock()
+	defer metricsLock.Unlock()
+
+	if metrics == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Metrics struct {
+				Token     string `yaml:"Token"`
+				CacheTime int    `yaml:"CacheTime"`
+			} `yaml:"metrics"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.Metrics.CacheTime <= 0 {
+			c.Metrics.CacheTime = defaultMetricsCacheTime
+		}
+
+		metrics = &Metrics{
+			Token:     c.Metrics.Token,
+			CacheTime: time.Duration(c.Metrics.CacheTime) * time.Second,
+		}
+	}
+
+	return metrics
+}
+
+// Drivers of the blob storage
+const (
+	BlobStorageLocal = "local"
+	BlobStorageS3    = "s3"
+)
+
+// Default life time of blob download URLs in minutes
+const defaultBlobStorageURLLifeTime = 15
+
+// BlobStorage contains the settings of the storage for files like backups. The local driver stores
+// files below Path, which must be a persistent directory, and signs download URLs with Secret. The
+// local storage can't be used without Secret, because anyone could forge download URLs. The s3
+// driver stores files in Bucket of an S3 compatible service at Endpoint, e.g. AWS S3, MinIO or
+// Google Cloud Storage with HMAC keys. With PathStyle the bucket is part of the path instead of
+// the host name. Download URLs are valid for URLLifeTime.
+type BlobStorage struct {
+	Driver      string
+	Path        string
+	Secret      string
+	Endpoint    string
+	Region      string
+	Bucket      string
+	AccessKey   string
+	SecretKey   string
+	PathStyle   bool
+	URLLifeTime time.Duration
+}
+
+var blobStorage *BlobStorage
+var blobStorageLock = sync.Mutex{}
+
+// GetBlobStorage loads the blob storage settings from a yaml file when called the first time.
+func GetBlobStorage() *BlobStorage {
+	blobStorageLock.Lock()
+	defer blobStorageLock.Unlock()
+
+	if blobStorage == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			BlobStorage struct {
+				Driver      string `yaml:"Driver"`
+				Path        string `yaml:"Path"`
+				Secret      string `yaml:"Secret"`
+				Endpoint    string `yaml:"Endpoint"`
+				Region      string `yaml:"Region"`
+				Bucket      string `yaml:"Bucket"`
+				AccessKey   string `yaml:"AccessKey"`
+				SecretKey   string `yaml:"SecretKey"`
+				PathStyle   bool   `yaml:"PathStyle"`
+				URLLifeTime int    `yaml:"URLLifeTime"`
+			} `yaml:"blobstorage"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.BlobStorage.Driver == "" {
+			c.BlobStorage.Driver = BlobStorageLocal
+		}
+		if c.BlobStorage.Driver != BlobStorageLocal && c.BlobStorage.Driver != BlobStorageS3 {
+			panic(fmt.Sprintf("Unknown blob storage driver '%s'", c.BlobStorage.Driver))
+		}
+		if c.BlobStorage.Driver == BlobStorageLocal && c.BlobStorage.Path == "" {
+			panic("The local blob storage requires Path")
+		}
+		if c.BlobStorage.Driver == BlobStorageS3 && (c.BlobStorage.Endpoint == "" || c.BlobStorage.Bucket == "") {
+			panic("The s3 blob storage requires Endpoint and Bucket")
+		}
+		if c.BlobStorage.Region == "" {
+			c.BlobStorage.Region = "us-east-1"
+		}
+		if c.BlobStorage.URLLifeTime <= 0 {
+			c.BlobStorage.URLLifeTime = defaultBlobStorageURLLifeTime
+		}
+
+		blobStorage = &BlobStorage{
+			Driver:      c.BlobStorage.Driver,
+			Path:        c.BlobStorage.Path,
+			Secret:      c.BlobStorage.Secret,
+			Endpoint:    strings.TrimSuffix(c.BlobStorage.Endpoint, "/"),
+			Region:      c.BlobStorage.Region,
+			Bucket:      c.BlobStorage.Bucket,
+			AccessKey:   c.BlobStorage.AccessKey,
+			SecretKey:   c.BlobStorage.SecretKey,
+			PathStyle:   c.BlobStorage.PathStyle,
+			URLLifeTime: time.Duration(c.BlobStorage.URLLifeTime) * time.Minute,
+		}
+	}
+
+	return blobStorage
+}
+
+// Default life time of service tokens in minutes
+const defaultServiceTokenLifeTime = 60
+
+// ServiceTokens contains the settings of the tokens with which background jobs like the cleaner
+// act. A token is valid for LifeTime and renewed once half of it has passed.
+type ServiceTokens struct {
+	LifeTime time.Duration
+}
+
+var serviceTokens *ServiceTokens
+var serviceTokensLock = sync.Mutex{}
+
+// GetServiceTokens loads the service token settings from a yaml file when called the first time.
+func GetServiceTokens() *ServiceTokens {
+	serviceTokensLock.Lock()
+	defer serviceTokensLock.Unlock()
+
+	if serviceTokens == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			ServiceTokens struct {
+				LifeTime int `yaml:"LifeTime"`
+			} `yaml:"servicetokens"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.ServiceTokens.LifeTime <= 0 {
+			c.ServiceTokens.LifeTime = defaultServiceTokenLifeTime
+		}
+
+		serviceTokens = &ServiceTokens{
+			LifeTime: time.Duration(c.ServiceTokens.LifeTime) * time.Minute,
+		}
+	}
+
+	return serviceTokens
+}
+
+// Default life time of group invitations in days
+const defaultGroupInvitationLifeTime = 14
+
+// Groups contains the settings of groups. Managers (account logins) may manage all groups on
+// the group pages, in addition to the owners of each group. Invited accounts become members
+// once they accepted the invitation within InvitationLifeTime.
+type Groups struct {
+	Managers           []string
+	InvitationLifeTime time.Duration
+}
+
+var groups *Groups
+var groupsLock = sync.Mutex{}
+
+// GetGroups loads the group settings from a yaml file when called the first time.
+func GetGroups() *Groups {
+	groupsLock.Lock()
+	defer groupsLock.Unlock()
+
+	if groups == nil {
+		content, err := readServerConfig()
+		if err != nil {
+			panic(err)
+		}
+
+		c := &struct {
+			Groups struct {
+				Managers           []string `yaml:"Managers"`
+				InvitationLifeTime int      `yaml:"InvitationLifeTime"`
+			} `yaml:"groups"`
+		}{}
+		err = yaml.Unmarshal(content, c)
+		if err != nil {
+			panic(err)
+		}
+
+		if c.Groups.InvitationLifeTime <= 0 {
+			c.Groups.InvitationLifeTime = defaultGroupInvitationLifeTime
+		}
+
+		groups = &Groups{
+			Managers:           c.Groups.Managers,
+			InvitationLifeTime: time.Duration(c.Groups.InvitationLifeTime) * 24 * time.Hour,
+		}
+	}
+
+	return groups
+}
+
+// IsManager checks whether the account with the given login may manage all groups.
+func (g *Groups) IsManager(login string) bool {
+	for _, manager := range g.Managers {
+		if manager == login {
+			return true
+		}
+	}
+	return false
+}
@@ -9,9 +9,18 @@
 package conf
 
 import (
+	"net"
+	"net/http"
 	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
 )
 
+func init() {
+	UseTestConfig()
+}
+
 const httpHost = "localhost"
 const httpPort = 8081
 const smtpHost = "localhost"
@@ -28,6 +37,33 @@ func TestGetServerConfig(t *testing.T) {
 	if config.BaseURL != "http://localhost:8081" {
 		t.Error("BaseURL expected to be 'http://localhost:8081'")
 	}
+	if config.CookieName != "session" {
+		t.Errorf("CookieName expected to be 'session' but was '%s'", config.CookieName)
+	}
+	if !config.CookieHttpOnly {
+		t.Error("CookieHttpOnly expected to be true")
+	}
+	if config.CookieSameSite != http.SameSiteLaxMode {
+		t.Error("CookieSameSite expected to be lax")
+	}
+	if config.TestMode {
+		t.Error("TestMode expected to be disabled")
+	}
+}
+
+func TestParseSameSite(t *testing.T) {
+	mode, err := parseSameSite("Strict")
+	if err != nil || mode != http.SameSiteStrictMode {
+		t.Error("SameSite expected to be strict")
+	}
+	mode, err = parseSameSite("")
+	if err != nil || mode != http.SameSiteDefaultMode {
+		t.Error("SameSite expected to be default")
+	}
+	_, err = parseSameSite("sometimes")
+	if err == nil {
+		t.Error("Invalid SameSite value should fail")
+	}
 }
 
 func TestGetDbConfig(t *testing.T) {
@@ -45,6 +81,15 @@ func TestGetSmtpCredentials(t *testing.T) {
 	if creds.Port != smtpPort {
 		t.Errorf("Port expected to be '%d' but was '%d'\n", smtpPort, creds.Port)
 	}
+	if creds.StartTLS != "auto" {
+		t.Errorf("StartTLS expected to be 'auto' but was '%s'\n", creds.StartTLS)
+	}
+	if creds.PoolSize != 2 || creds.IdleTimeout != 30*time.Second {
+		t.Errorf("Unexpected pool settings: %d, %s\n", creds.PoolSize, creds.IdleTimeout)
+	}
+	if creds.DKIMKeyFile != "" {
+		t.Error("DKIM signing expected to be disabled")
+	}
 }
 
 func TestSmtpCheck(t *testing.T) {
@@ -88,3 +133,526 @@ func TestGetExternals(t *testing.T) {
 		t.Error("Missing Theme URL")
 	}
 }
+
+func TestGetErrorReporting(t *testing.T) {
+	reporting := GetErrorReporting()
+	if reporting == nil {
+		t.Error("Error initializing error reporting")
+	}
+	if reporting.SentryDSN != "" {
+		t.Error("Error reporting expected to be disabled")
+	}
+	if reporting.Environment != "development" {
+		t.Error("Environment expected to be 'development'")
+	}
+}
+
+func TestGetAlerting(t *testing.T) {
+	alerting := GetAlerting()
+	if alerting == nil {
+		t.Error("Error initializing alerting")
+	}
+	if alerting.Window != 10*time.Minute || alerting.Dedup != time.Hour {
+		t.Error("Wrong alerting window or dedup interval")
+	}
+	if alerting.Thresholds["failed-login"] != 50 {
+		t.Error("Threshold for 'failed-login' expected to be 50")
+	}
+}
+
+func TestGetRegistration(t *testing.T) {
+	registration := GetRegistration()
+	if registration == nil {
+		t.Error("Error initializing registration settings")
+	}
+	if registration.RequireApproval {
+		t.Error("Approval expected to be disabled")
+	}
+	if !registration.IsAdministrator("bob") || registration.IsAdministrator("alice") {
+		t.Error("Only 'bob' expected to be administrator")
+	}
+	if !registration.IsElevated("curator") || registration.IsElevated("account-read") {
+		t.Error("Only 'curator' expected to be an elevated scope")
+	}
+	if registration.ActivationResendInterval != 15*time.Minute {
+		t.Errorf("Activation resend interval of 15 minutes expected but was %s", registration.ActivationResendInterval)
+	}
+}
+
+func TestGetGrantRequestGC(t *testing.T) {
+	gc := GetGrantRequestGC()
+	if gc.Interval != time.Minute {
+		t.Errorf("Interval expected to be 1m but was %s", gc.Interval)
+	}
+	if gc.LoadLifeTime != 3*time.Minute {
+		t.Errorf("LoadLifeTime expected to be 3m but was %s", gc.LoadLifeTime)
+	}
+	if gc.MaxPending != 1000 {
+		t.Errorf("MaxPending expected to be 1000 but was %d", gc.MaxPending)
+	}
+}
+
+func TestGetPasswordExpiry(t *testing.T) {
+	expiry := GetPasswordExpiry()
+	if expiry.MaxAge != 0 {
+		t.Error("Password expiry expected to be disabled by default")
+	}
+	if expiry.Warn != 14*24*time.Hour {
+		t.Errorf("Warn expected to be 14 days but was %s", expiry.Warn)
+	}
+	if expiry.MaxAgeFor([]string{"institutional"}) != 180*24*time.Hour {
+		t.Error("Policy 'institutional' expected to apply")
+	}
+
+	p := &PasswordExpiry{MaxAge: 10, Policies: map[string]time.Duration{"a": 5, "b": 20}}
+	if p.MaxAgeFor(nil) != 10 || p.MaxAgeFor([]string{"a", "b"}) != 5 || p.MaxAgeFor([]string{"b"}) != 10 {
+		t.Error("MaxAgeFor expected to return the shortest age")
+	}
+	if p.MinMaxAge() != 5 {
+		t.Error("MinMaxAge expected to be 5")
+	}
+}
+
+func TestGetPasswordStrength(t *testing.T) {
+	strength := GetPasswordStrength()
+	if strength.MinLength != 6 {
+		t.Errorf("MinLength expected to be 6 but was %d", strength.MinLength)
+	}
+	if strength.MinScore != 0 {
+		t.Errorf("MinScore expected to be 0 but was %d", strength.MinScore)
+	}
+}
+
+func TestGetDormancy(t *testing.T) {
+	dormancy := GetDormancy()
+	if dormancy.Months != 0 {
+		t.Error("Dormancy policy expected to be disabled by default")
+	}
+	if dormancy.Warn != 30*24*time.Hour || dormancy.ArchiveAfter != 180*24*time.Hour {
+		t.Error("Unexpected warning or archiving period")
+	}
+	if !dormancy.IsExempt([]string{"verified", "bot"}) {
+		t.Error("Accounts labeled 'bot' expected to be exempt")
+	}
+	if dormancy.IsExempt([]string{"verified"}) || dormancy.IsExempt(nil) {
+		t.Error("Accounts without exempt label expected not to be exempt")
+	}
+
+	now := time.Date(2016, 11, 3, 12, 0, 0, 0, time.UTC)
+	policy := &Dormancy{Months: 6}
+	if cutoff := policy.Cutoff(now); !cutoff.Equal(time.Date(2016, 5, 3, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("Cutoff six months before expected but was %s", cutoff)
+	}
+}
+
+func TestGetLoginCaptcha(t *testing.T) {
+	captcha := GetLoginCaptcha()
+	if captcha.IPFailures != 10 || captcha.AccountFailures != 3 {
+		t.Errorf("Unexpected thresholds %d and %d", captcha.IPFailures, captcha.AccountFailures)
+	}
+	if captcha.Window != 15*time.Minute {
+		t.Errorf("Window expected to be 15 minutes but was %s", captcha.Window)
+	}
+}
+
+func TestGetBreachedPasswords(t *testing.T) {
+	breached := GetBreachedPasswords()
+	if breached.Mode != BreachCheckOff {
+		t.Errorf("Breached password check expected to be off but was '%s'", breached.Mode)
+	}
+	if breached.APIURL != "https://api.pwnedpasswords.com/range/" || breached.Dir != "" {
+		t.Error("Breached password check expected to use the HaveIBeenPwned API")
+	}
+	if breached.Timeout != 3*time.Second || breached.CacheTime != time.Hour {
+		t.Error("Unexpected timeout or cache time")
+	}
+}
+
+func TestGetEmailBounces(t *testing.T) {
+	bounces := GetEmailBounces()
+	if bounces.Secret != "bouncesecret" {
+		t.Error("Unexpected bounce webhook secret")
+	}
+	if bounces.SoftLimit != 3 {
+		t.Errorf("SoftLimit expected to be 3 but was %d", bounces.SoftLimit)
+	}
+}
+
+func TestGetSSHCertificateAuthority(t *testing.T) {
+	ca := GetSSHCertificateAuthority()
+	if ca.KeyFile != "" {
+		t.Error("Issuing ssh certificates expected to be disabled")
+	}
+	if ca.Validity != time.Hour {
+		t.Errorf("Validity expected to be 1h but was %s", ca.Validity)
+	}
+}
+
+func TestGetRetention(t *testing.T) {
+	r := GetRetention()
+	if r.Default != 0 || r.KeepFor("account_history") != 0 {
+		t.Error("Data expected to be kept forever by default")
+	}
+
+	r.Default = 24 * time.Hour
+	defer func() { r.Default = 0 }()
+	if r.KeepFor("account_history") != 24*time.Hour {
+		t.Error("Policies without setting expected to use the default")
+	}
+	if r.KeepFor("disabled_accounts") != 0 {
+		t.Error("Policy setting expected to override the default")
+	}
+}
+
+func TestGetContentBlocks(t *testing.T) {
+	blocks := GetContentBlocks()
+	if blocks == nil {
+		t.Error("Error initializing content blocks")
+	}
+	if blocks.Announcement != "" || blocks.Support == "" || blocks.Footer != "" {
+		t.Error("Only the support content block expected to be configured")
+	}
+}
+
+func TestGetSetMaintenance(t *testing.T) {
+	m := GetMaintenance()
+	if m.Enabled {
+		t.Error("Maintenance mode expected to be disabled")
+	}
+	if m.Message == "" || m.RetryAfter <= 0 {
+		t.Error("Maintenance defaults expected to be set")
+	}
+
+	SetMaintenance(Maintenance{Enabled: true})
+	if check := GetMaintenance(); !check.Enabled || check.Message != m.Message {
+		t.Error("Maintenance mode expected to be enabled with default message")
+	}
+
+	SetMaintenance(Maintenance{Enabled: false})
+	if GetMaintenance().Enabled {
+		t.Error("Maintenance mode expected to be disabled")
+	}
+}
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := parseNetworks([]string{"10.0.0.0/8", "127.0.0.1", "::1"})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(networks) != 3 || networks[1].String() != "127.0.0.1/32" || networks[2].String() != "::1/128" {
+		t.Errorf("Unexpected networks: %v", networks)
+	}
+
+	_, err = parseNetworks([]string{"localhost"})
+	if err == nil {
+		t.Error("Invalid network should fail")
+	}
+}
+
+func TestIsTrustedProxy(t *testing.T) {
+	config := GetServerConfig()
+	if !config.IsTrustedProxy(net.ParseIP("127.0.0.1")) {
+		t.Error("Localhost expected to be a trusted proxy")
+	}
+	if config.IsTrustedProxy(net.ParseIP("192.0.2.1")) {
+		t.Error("Address expected not to be a trusted proxy")
+	}
+	if config.IsTrustedProxy(nil) {
+		t.Error("Socket peer expected not to be trusted by default")
+	}
+
+	config.Socket = "/run/gin-auth.sock"
+	config.TrustSocketPeer = true
+	defer func() { config.Socket = ""; config.TrustSocketPeer = false }()
+	if !config.IsTrustedProxy(nil) {
+		t.Error("Socket peer expected to be a trusted proxy")
+	}
+}
+
+func TestIsInternal(t *testing.T) {
+	config := GetServerConfig()
+	if len(config.InternalNetworks) != 0 || config.IsInternal(net.ParseIP("10.1.2.3")) {
+		t.Error("No internal networks expected by default")
+	}
+
+	internal, err := parseNetworks([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	configured := &ServerConfig{InternalNetworks: internal}
+	if !configured.IsInternal(net.ParseIP("10.1.2.3")) {
+		t.Error("Address expected to be internal")
+	}
+	if configured.IsInternal(net.ParseIP("192.0.2.1")) || configured.IsInternal(nil) {
+		t.Error("Address expected not to be internal")
+	}
+	if config.InternalRateFactor != 10 {
+		t.Errorf("Internal rate factor 10 expected but was %d", config.InternalRateFactor)
+	}
+	if config.IPv6RateLimitPrefix != 64 {
+		t.Errorf("IPv6 rate limit prefix 64 expected but was %d", config.IPv6RateLimitPrefix)
+	}
+}
+
+func TestNormalizePathPrefix(t *testing.T) {
+	for in, out := range map[string]string{"": "", "/": "", "auth": "/auth", "/auth/": "/auth", "/a/b": "/a/b"} {
+		if p := normalizePathPrefix(in); p != out {
+			t.Errorf("Prefix for '%s' expected to be '%s' but was '%s'", in, out, p)
+		}
+	}
+}
+
+func TestGetConsent(t *testing.T) {
+	consent := GetConsent()
+	if consent.PolicyVersion != "2016-01" {
+		t.Errorf("Policy version expected to be '2016-01' but was '%s'", consent.PolicyVersion)
+	}
+	if consent.PolicyURL != "" {
+		t.Errorf("Policy URL expected to be empty but was '%s'", consent.PolicyURL)
+	}
+}
+
+func TestGetSessionStore(t *testing.T) {
+	store := GetSessionStore()
+	if store.Backend != SessionStorePostgres {
+		t.Errorf("Backend expected to be '%s' but was '%s'", SessionStorePostgres, store.Backend)
+	}
+	if store.RedisAddress != "localhost:6379" || store.RedisPrefix != "gin-auth:" {
+		t.Errorf("Unexpected redis settings: %+v", store)
+	}
+	if store.MaxPerAccount != 0 {
+		t.Errorf("MaxPerAccount expected to be 0 but was %d", store.MaxPerAccount)
+	}
+}
+
+func TestGetLoginBursts(t *testing.T) {
+	bursts := GetLoginBursts()
+	if bursts.Webhook != "" || bursts.Secret != "" || bursts.Window != 10*time.Minute {
+		t.Errorf("Unexpected webhook settings: %+v", bursts)
+	}
+	if bursts.AccountThreshold != 10 || bursts.IPThreshold != 20 {
+		t.Errorf("Unexpected thresholds: %+v", bursts)
+	}
+}
+
+func TestGetAccountHooks(t *testing.T) {
+	hooks := GetAccountHooks()
+	if hooks.Webhook != "" || hooks.Secret != "" {
+		t.Errorf("Webhook and secret expected to be empty: %+v", hooks)
+	}
+}
+
+func TestGetAccountCodes(t *testing.T) {
+	codes := GetAccountCodes()
+	if string(codes.Secret) != "test-secret-do-not-use-in-production" || codes.IsSecretGenerated {
+		t.Errorf("Unexpected secret '%s'", string(codes.Secret))
+	}
+	if codes.ResetLifeTime != 24*time.Hour || codes.VerificationLifeTime != 168*time.Hour || codes.RecoveryLifeTime != 168*time.Hour {
+		t.Errorf("Unexpected life times: %+v", codes)
+	}
+}
+
+func TestGetTokenValidation(t *testing.T) {
+	validation := GetTokenValidation()
+	if validation.MaxAge != 0 || validation.StaleWhileRevalidate != 30*time.Second {
+		t.Errorf("Unexpected cache settings: %+v", validation)
+	}
+	if validation.MaxBatchSize != 100 {
+		t.Errorf("Max batch size expected to be 100 but was %d", validation.MaxBatchSize)
+	}
+	if validation.RevocationWait != 30*time.Second {
+		t.Errorf("Revocation wait expected to be 30s but was %v", validation.RevocationWait)
+	}
+}
+
+func TestGetAnnouncements(t *testing.T) {
+	if GetAnnouncements().BatchSize != 50 {
+		t.Errorf("Batch size expected to be 50 but was %d", GetAnnouncements().BatchSize)
+	}
+}
+
+func TestGetCookieKeys(t *testing.T) {
+	keys := GetCookieKeys()
+	if len(keys.Keys) != 2 || keys.IsKeyGenerated {
+		t.Fatalf("Two configured keys expected but got %d", len(keys.Keys))
+	}
+	if string(keys.Keys[0]) != "test-cookie-key-do-not-use-in-production" {
+		t.Errorf("Unexpected signing key '%s'", string(keys.Keys[0]))
+	}
+}
+
+func TestGetClientHistory(t *testing.T) {
+	if GetClientHistory().GracePeriod != 30*24*time.Hour {
+		t.Errorf("Grace period expected to be 30 days but was %s", GetClientHistory().GracePeriod)
+	}
+	if GetClientHistory().RedirectURIGracePeriod != 0 {
+		t.Errorf("Redirect URIs expected to have no grace period but was %s", GetClientHistory().RedirectURIGracePeriod)
+	}
+}
+
+func TestGetRequestLimits(t *testing.T) {
+	limits := GetRequestLimits()
+	if limits.BodySize("/api/accounts/{account}") != 1048576 {
+		t.Errorf("Body size expected to be 1048576 but was %d", limits.BodySize("/api/accounts/{account}"))
+	}
+	if limits.BodySize("/api/email_bounces") != 4194304 {
+		t.Errorf("Body size expected to be 4194304 but was %d", limits.BodySize("/api/email_bounces"))
+	}
+	if limits.MaxJSONDepth != 32 || limits.MaxJSONArrayLength != 1000 || limits.RejectUnknownFields {
+		t.Errorf("Unexpected JSON limits: %+v", limits)
+	}
+}
+
+func TestGetClock(t *testing.T) {
+	if GetClock().Leeway != 5*time.Second {
+		t.Errorf("Leeway expected to be 5s but was %s", GetClock().Leeway)
+	}
+}
+
+func TestGetRefreshTokens(t *testing.T) {
+	tokens := GetRefreshTokens()
+	if tokens.LifeTime != 365*24*time.Hour {
+		t.Errorf("LifeTime expected to be 365 days but was %s", tokens.LifeTime)
+	}
+	if tokens.IdleTime != 30*24*time.Hour {
+		t.Errorf("IdleTime expected to be 30 days but was %s", tokens.IdleTime)
+	}
+}
+
+func TestGetPolicy(t *testing.T) {
+	p := GetPolicy()
+	if p.URL != "" || p.Timeout != 5*time.Second || p.FailOpen {
+		t.Errorf("Unexpected policy settings: %+v", p)
+	}
+}
+
+func TestGetClientScopes(t *testing.T) {
+	if !GetClientScopes().Strict {
+		t.Error("Client scopes expected to be strict")
+	}
+}
+
+func TestGetFeatureFlags(t *testing.T) {
+	flags := GetFeatureFlags()
+	f, ok := flags["example-feature"]
+	if !ok {
+		t.Fatal("Feature flag 'example-feature' expected")
+	}
+	if f.Enabled || f.Percentage != 10 || len(f.Accounts) != 1 || f.Accounts[0] != "alice" {
+		t.Errorf("Unexpected feature flag: %+v", f)
+	}
+	if _, ok := flags["doesnotexist"]; ok {
+		t.Error("Feature flag 'doesnotexist' should not exist")
+	}
+}
+
+func TestGetAuditExport(t *testing.T) {
+	e := GetAuditExport()
+	if e.Target != "" || e.Format != AuditFormatCEF || e.Block {
+		t.Errorf("Unexpected audit export settings: %+v", e)
+	}
+	if e.BufferSize != 10000 || e.BatchSize != 100 || e.FlushInterval != time.Second || e.Timeout != 5*time.Second {
+		t.Errorf("Unexpected audit export limits: %+v", e)
+	}
+}
+
+func TestGetSetReadOnly(t *testing.T) {
+	ro := GetReadOnly()
+	if ro.Enabled || ro.AllowLogins {
+		t.Error("Read-only mode expected to be disabled")
+	}
+	if ro.Message == "" || ro.RetryAfter != 5*time.Minute {
+		t.Errorf("Unexpected read-only defaults: %+v", ro)
+	}
+
+	SetReadOnly(ReadOnly{Enabled: true, AllowLogins: true})
+	if check := GetReadOnly(); !check.Enabled || !check.AllowLogins || check.Message != ro.Message {
+		t.Error("Read-only mode expected to be enabled with default message")
+	}
+
+	SetReadOnly(ReadOnly{Enabled: false})
+	if GetReadOnly().Enabled {
+		t.Error("Read-only mode expected to be disabled")
+	}
+}
+
+func TestGetMetrics(t *testing.T) {
+	if GetMetrics().Token != "" {
+		t.Errorf("Empty metrics token expected but was '%s'", GetMetrics().Token)
+	}
+	if GetMetrics().CacheTime != 30*time.Second {
+		t.Errorf("Cache time expected to be 30s but was %v", GetMetrics().CacheTime)
+	}
+}
+
+func TestGetBlobStorage(t *testing.T) {
+	s := GetBlobStorage()
+	if s.Driver != BlobStorageLocal || s.Path != "/var/lib/gin-auth/blobs" || s.Secret == "" {
+		t.Errorf("Local blob storage with path and secret expected: %+v", s)
+	}
+	if s.Region != "us-east-1" || s.URLLifeTime != 15*time.Minute {
+		t.Errorf("Unexpected blob storage defaults: %+v", s)
+	}
+}
+
+func TestShippedConfig(t *testing.T) {
+	useTestConfig = false
+	defer func() { useTestConfig = true }()
+
+	content, err := readServerConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &struct {
+		Registration struct {
+			Administrators []string `yaml:"Administrators"`
+		} `yaml:"registration"`
+		Bounces struct {
+			Secret string `yaml:"Secret"`
+		} `yaml:"bounces"`
+		Codes struct {
+			Secret string `yaml:"Secret"`
+		} `yaml:"codes"`
+		Cookies struct {
+			Keys []string `yaml:"Keys"`
+		} `yaml:"cookies"`
+		BlobStorage struct {
+			Secret string `yaml:"Secret"`
+		} `yaml:"blobstorage"`
+	}{}
+	err = yaml.Unmarshal(content, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Registration.Administrators) > 0 {
+		t.Error("No administrators expected in the shipped configuration")
+	}
+	if c.Bounces.Secret != "" {
+		t.Error("No bounce webhook secret expected in the shipped configuration")
+	}
+	if c.Codes.Secret != "" {
+		t.Error("No secret for account codes expected in the shipped configuration")
+	}
+	if len(c.Cookies.Keys) > 0 {
+		t.Error("No cookie keys expected in the shipped configuration")
+	}
+	if c.BlobStorage.Secret != "" {
+		t.Error("No blob storage secret expected in the shipped configuration")
+	}
+}
+
+func TestGetServiceTokens(t *testing.T) {
+	if lifeTime := GetServiceTokens().LifeTime; lifeTime != time.Hour {
+		t.Errorf("Service token life time of one hour expected but was %s", lifeTime)
+	}
+}
+
+func TestGetGroups(t *testing.T) {
+	config := GetGroups()
+	if lifeTime := config.InvitationLifeTime; lifeTime != 14*24*time.Hour {
+		t.Errorf("Invitation life time of 14 days expected but was %s", lifeTime)
+	}
+	if config.IsManager("bob") {
+		t.Error("Administrators of the registration expected not to manage groups")
+	}
+}
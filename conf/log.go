@@ -18,16 +18,17 @@ import (
 
 var logEnv *LogEnv
 
-// LogEnv provides the logging environment with error and access log
+// LogEnv provides the logging environment with error, access and audit log
 // and a function to defer closing any associated files.
 type LogEnv struct {
 	Err    *logrus.Logger
 	Access *logrus.Logger
+	Audit  *logrus.Logger
 	Close  func()
 }
 
-// InitLogEnv initializes loggers for access and error.
-// Default access log directs to Stdout, default error log
+// InitLogEnv initializes loggers for access, error and audit.
+// Default access and audit log direct to Stdout, default error log
 // directs to Stderr. If log files are provided, the output
 // will be directed to the respective default and the log file.
 // Log files are opened using a logrotate compatible library.
@@ -35,14 +36,17 @@ func InitLogEnv() {
 
 	accFile := GetLogLocation().Access
 	errFile := GetLogLocation().Error
+	audFile := GetLogLocation().Audit
 
 	logEnv = &LogEnv{
 		Access: logrus.New(),
 		Err:    logrus.New(),
+		Audit:  logrus.New(),
 	}
 	logEnv.Access.Out = os.Stdout
+	logEnv.Audit.Out = os.Stdout
 
-	fs := make([]*logrotate.File, 0, 2)
+	fs := make([]*logrotate.File, 0, 3)
 
 	if accFile != "" {
 		af, err := logrotate.NewFile(accFile)
@@ -62,6 +66,15 @@ func InitLogEnv() {
 		fs = append(fs, ef)
 	}
 
+	if audFile != "" {
+		uf, err := logrotate.NewFile(audFile)
+		if err != nil {
+			panic(err)
+		}
+		logEnv.Audit.Out = io.MultiWriter(os.Stdout, uf)
+		fs = append(fs, uf)
+	}
+
 	logEnv.Close = func() {
 		for _, f := range fs {
 			f.Close()
@@ -0,0 +1,100 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package conf
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// StaticPath is the path under which static files are served.
+const StaticPath = "/static/"
+
+// Number of hex characters of the content hash used in fingerprinted file names.
+const fingerprintLength = 12
+
+var staticAssets map[string]string
+var staticAssetsLock = sync.Mutex{}
+
+// getStaticAssets scans the static resources directory when called the first time and
+// returns a map from the logical name of each file (e.g. "js/registration.js") to its
+// fingerprinted name (e.g. "js/registration.0123456789ab.js").
+func getStaticAssets() map[string]string {
+	staticAssetsLock.Lock()
+	defer staticAssetsLock.Unlock()
+
+	if staticAssets == nil {
+		assets := make(map[string]string)
+		root := filepath.Join(resourcesPath, "static")
+		err := filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			content, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, file)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			sum := sha256.Sum256(content)
+			assets[name] = fingerprint(name, hex.EncodeToString(sum[:])[:fingerprintLength])
+			return nil
+		})
+		if err != nil && !os.IsNotExist(err) {
+			panic(err)
+		}
+		staticAssets = assets
+	}
+
+	return staticAssets
+}
+
+// fingerprint inserts a hash into a file name right before the file extension.
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// StaticAssetPath returns the absolute path of a static file including path prefix and
+// content hash. Files that do not exist are linked without hash.
+func StaticAssetPath(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if fp, ok := getStaticAssets()[name]; ok {
+		name = fp
+	}
+	return MakePath(StaticPath + name)
+}
+
+// ResolveStaticAsset maps a requested static file name to the location of the file.
+// The second return value is true if the name contains the hash of the current file content
+// and the response can therefore be cached forever. Returns false as third value if no
+// such file exists or if the name contains an outdated hash.
+func ResolveStaticAsset(name string) (string, bool, bool) {
+	name = strings.TrimPrefix(name, "/")
+	assets := getStaticAssets()
+
+	if _, ok := assets[name]; ok {
+		return filepath.Join(resourcesPath, "static", filepath.FromSlash(name)), false, true
+	}
+	for logical, fp := range assets {
+		if fp == name {
+			return filepath.Join(resourcesPath, "static", filepath.FromSlash(logical)), true, true
+		}
+	}
+	return "", false, false
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package conf
+
+import (
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	if fp := fingerprint("js/app.js", "abc"); fp != "js/app.abc.js" {
+		t.Errorf("Fingerprint expected to be 'js/app.abc.js' but was '%s'", fp)
+	}
+	if fp := fingerprint("LICENSE", "abc"); fp != "LICENSE.abc" {
+		t.Errorf("Fingerprint expected to be 'LICENSE.abc' but was '%s'", fp)
+	}
+}
+
+func TestStaticAssetPath(t *testing.T) {
+	p := StaticAssetPath("js/registration.js")
+	if !regexp.MustCompile(`^/static/js/registration\.[0-9a-f]{12}\.js$`).MatchString(p) {
+		t.Errorf("Unexpected fingerprinted path '%s'", p)
+	}
+	if p != StaticAssetPath("/js/registration.js") {
+		t.Error("Leading slash should be ignored")
+	}
+
+	p = StaticAssetPath("js/doesnotexist.js")
+	if p != "/static/js/doesnotexist.js" {
+		t.Errorf("Path expected to be '/static/js/doesnotexist.js' but was '%s'", p)
+	}
+}
+
+func TestResolveStaticAsset(t *testing.T) {
+	expected := filepath.Join(resourcesPath, "static", "js", "registration.js")
+
+	name := getStaticAssets()["js/registration.js"]
+	file, immutable, ok := ResolveStaticAsset(name)
+	if !ok || !immutable || file != expected {
+		t.Errorf("Fingerprinted name '%s' not resolved properly", name)
+	}
+
+	file, immutable, ok = ResolveStaticAsset("js/registration.js")
+	if !ok || immutable || file != expected {
+		t.Error("Plain name not resolved properly")
+	}
+
+	_, _, ok = ResolveStaticAsset("js/registration.000000000000.js")
+	if ok {
+		t.Error("Outdated fingerprint should not be resolved")
+	}
+	_, _, ok = ResolveStaticAsset("../conf/server.yml")
+	if ok {
+		t.Error("Files outside the static directory should not be resolved")
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package conf
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Layout files for html and e-mail templates
+const (
+	htmlLayoutFile  = "layout.html"
+	emailLayoutFile = "emaillayout.txt"
+)
+
+var htmlTemplates map[string]*template.Template
+var emailTemplates map[string]*texttemplate.Template
+var templatesLock = sync.Mutex{}
+var templatesReload = false
+
+// SetTemplatesReload enables or disables the reload of templates. With reload enabled
+// templates are parsed from their files each time they are used, which allows to edit
+// templates without restarting the server during development.
+func SetTemplatesReload(reload bool) {
+	templatesLock.Lock()
+	defer templatesLock.Unlock()
+	templatesReload = reload
+}
+
+// LoadTemplates parses all html and e-mail templates from the resources directory.
+// Any syntax error is reported as error, thus LoadTemplates should be called on startup.
+func LoadTemplates() error {
+	templatesLock.Lock()
+	defer templatesLock.Unlock()
+	return loadTemplates()
+}
+
+// loadTemplates parses all templates into the template caches. The caller must hold templatesLock.
+func loadTemplates() error {
+	files, err := ioutil.ReadDir(filepath.Join(resourcesPath, "templates"))
+	if err != nil {
+		return err
+	}
+
+	html := make(map[string]*template.Template)
+	email := make(map[string]*texttemplate.Template)
+	for _, f := range files {
+		name := f.Name()
+		switch {
+		case f.IsDir() || name == htmlLayoutFile || name == emailLayoutFile:
+			continue
+		case strings.HasSuffix(name, ".html"):
+			html[name], err = parseTemplate(name)
+		case strings.HasSuffix(name, ".txt"):
+			email[name], err = parseEmailTemplate(name)
+		}
+		if err != nil {
+			return fmt.Errorf("Error parsing template '%s': %s", name, err.Error())
+		}
+	}
+
+	htmlTemplates = html
+	emailTemplates = email
+	return nil
+}
+
+// parseTemplate parses an html template together with the default layout.
+func parseTemplate(name string) (*template.Template, error) {
+	layout := filepath.Join(resourcesPath, "templates", htmlLayoutFile)
+	content := filepath.Join(resourcesPath, "templates", name)
+
+	funcs := template.FuncMap{
+		"asset":        StaticAssetPath,
+		"banner":       maintenanceBanner,
+		"contentblock": contentBlock,
+	}
+	tmpl, err := template.New(htmlLayoutFile).Funcs(funcs).ParseFiles(layout, content)
+	if err != nil {
+		return nil, err
+	}
+
+	// parse theme URL, gin web ui URL and path prefix from config into the layout template
+	defines := map[string]string{
+		"theme":  GetExternals().ThemeURL,
+		"ginui":  GetExternals().GinUiURL,
+		"prefix": GetServerConfig().PathPrefix,
+	}
+	for define, value := range defines {
+		s := fmt.Sprintf("{{ define \"%s\" }}%s{{ end }}", define, value)
+		tmpl, err = tmpl.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// the maintenance banner changes at runtime and is therefore rendered by a function
+	return tmpl.Parse("{{ define \"banner\" }}{{ banner }}{{ end }}")
+}
+
+// parseEmailTemplate parses an e-mail template together with the e-mail layout.
+func parseEmailTemplate(name string) (*texttemplate.Template, error) {
+	layout := filepath.Join(resourcesPath, "templates", emailLayoutFile)
+	content := filepath.Join(resourcesPath, "templates", name)
+	return texttemplate.ParseFiles(layout, content)
+}
+
+// maintenanceBanner returns the html of the maintenance or read-only banner or an empty string
+// if gin-auth is neither in maintenance nor in read-only mode.
+func maintenanceBanner() template.HTML {
+	message := ""
+	if m := GetMaintenance(); m.Enabled {
+		message = m.Message
+	} else if ro := GetReadOnly(); ro.Enabled {
+		message = ro.Message
+	} else {
+		return ""
+	}
+	return template.HTML(fmt.Sprintf("<div class=\"alert alert-warning\" role=\"alert\">%s</div>",
+		template.HTMLEscapeString(message)))
+}
+
+// contentBlock returns the html snippet of the content block with the given name
+// (announcement, support or footer). The snippets are configured by operators and
+// are therefore not escaped.
+func contentBlock(name string) template.HTML {
+	blocks := GetContentBlocks()
+	switch name {
+	case "announcement":
+		return template.HTML(blocks.Announcement)
+	case "support":
+		return template.HTML(blocks.Support)
+	case "footer":
+		return template.HTML(blocks.Footer)
+	}
+	return ""
+}
+
+// MakeTemplate returns the template for the given content template file using the default layout.
+// Templates are loaded when MakeTemplate is called the first time unless LoadTemplates was
+// called before.
+func MakeTemplate(name string) *template.Template {
+	templatesLock.Lock()
+	defer templatesLock.Unlock()
+
+	if templatesReload {
+		tmpl, err := parseTemplate(name)
+		if err != nil {
+			panic(err)
+		}
+		return tmpl
+	}
+
+	if htmlTemplates == nil {
+		err := loadTemplates()
+		if err != nil {
+			panic(err)
+		}
+	}
+	tmpl, ok := htmlTemplates[name]
+	if !ok {
+		panic(fmt.Sprintf("Template '%s' does not exist", name))
+	}
+	return tmpl
+}
+
+// MakeEmailTemplate returns the e-mail template for the given content template file
+// using the e-mail layout. Templates are cached like those returned by MakeTemplate.
+func MakeEmailTemplate(name string) *texttemplate.Template {
+	templatesLock.Lock()
+	defer templatesLock.Unlock()
+
+	if templatesReload {
+		tmpl, err := parseEmailTemplate(name)
+		if err != nil {
+			panic(err)
+		}
+		return tmpl
+	}
+
+	if emailTemplates == nil {
+		err := loadTemplates()
+		if err != nil {
+			panic(err)
+		}
+	}
+	tmpl, ok := emailTemplates[name]
+	if !ok {
+		panic(fmt.Sprintf("E-mail template '%s' does not exist", name))
+	}
+	return tmpl
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package conf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplates(t *testing.T) {
+	err := LoadTemplates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := htmlTemplates["login.html"]; !ok {
+		t.Error("Template 'login.html' expected to be loaded")
+	}
+	if _, ok := htmlTemplates[htmlLayoutFile]; ok {
+		t.Error("Layout should not be loaded as content template")
+	}
+	if _, ok := emailTemplates["emailverify.txt"]; !ok {
+		t.Error("E-mail template 'emailverify.txt' expected to be loaded")
+	}
+
+	// template with syntax error
+	dir, err := ioutil.TempDir("", "gin-auth-templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	os.Mkdir(filepath.Join(dir, "templates"), 0755)
+	ioutil.WriteFile(filepath.Join(dir, "templates", "broken.html"), []byte("{{ define \"content\" }}{{ if }}"), 0644)
+
+	res := resourcesPath
+	defer func() { resourcesPath = res }()
+	resourcesPath = dir
+
+	err = LoadTemplates()
+	if err == nil || !strings.Contains(err.Error(), "broken.html") {
+		t.Error("Syntax error in 'broken.html' expected")
+	}
+	if _, ok := htmlTemplates["login.html"]; !ok {
+		t.Error("Failed load should not replace loaded templates")
+	}
+}
+
+func TestMakeTemplate(t *testing.T) {
+	defer SetMaintenance(Maintenance{Enabled: false})
+
+	tmpl := MakeTemplate("success.html")
+	if tmpl != MakeTemplate("success.html") {
+		t.Error("Template expected to be cached")
+	}
+
+	SetMaintenance(Maintenance{Enabled: true, Message: "Down for <maintenance>"})
+	var buf bytes.Buffer
+	err := tmpl.ExecuteTemplate(&buf, "layout", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Down for &lt;maintenance&gt;") {
+		t.Error("Cached template should show the current maintenance banner")
+	}
+
+	SetTemplatesReload(true)
+	defer SetTemplatesReload(false)
+	if tmpl == MakeTemplate("success.html") {
+		t.Error("Template expected to be parsed again")
+	}
+}
+
+func TestMakeEmailTemplate(t *testing.T) {
+	tmpl := MakeEmailTemplate("emailplain.txt")
+	if tmpl != MakeEmailTemplate("emailplain.txt") {
+		t.Error("E-mail template expected to be cached")
+	}
+	if tmpl.Lookup("content") == nil {
+		t.Error("E-mail template expected to define 'content'")
+	}
+}
+
+func TestContentBlocks(t *testing.T) {
+	data := struct {
+		Login, RequestID, CaptchaId, Message string
+		MagicLink                            bool
+	}{"", "", "", "", false}
+	var buf bytes.Buffer
+	err := MakeTemplate("login.html").ExecuteTemplate(&buf, "layout", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "<a href=\"mailto:gin@g-node.org\">") {
+		t.Error("Login page should contain the unescaped support content block")
+	}
+	if strings.Contains(buf.String(), "content-footer") {
+		t.Error("Login page should not contain an empty footer content block")
+	}
+
+	buf.Reset()
+	err = MakeTemplate("success.html").ExecuteTemplate(&buf, "layout", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "content-support") {
+		t.Error("Content blocks should only be shown on login, consent and registration pages")
+	}
+}
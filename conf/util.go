@@ -10,9 +10,7 @@ package conf
 
 import (
 	"fmt"
-	"html/template"
 	"net/url"
-	"path"
 )
 
 // MakeUrl makes a URL for other web resources provided by gin-auth using
@@ -29,29 +27,8 @@ func MakeUrl(pathFormat string, param ...interface{}) string {
 	return baseUrl + pathFormat
 }
 
-// MakeTemplate loads a template using the default layout and the given content template file.
-func MakeTemplate(name string) *template.Template {
-	layout := path.Join(resourcesPath, "templates", "layout.html")
-	content := path.Join(resourcesPath, "templates", name)
-	tmpl, err := template.ParseFiles(layout, content)
-	if err != nil {
-		panic(err)
-	}
-
-	// parse theme URL from config into the layout template
-	s := fmt.Sprintf("{{ define \"theme\" }}%s{{ end }}", GetExternals().ThemeURL)
-	fmt.Printf("[DEBUG] %s\n", s)
-
-	tmpl, err = tmpl.Parse(s)
-	if err != nil {
-		panic(err)
-	}
-
-	// parse gin web ui URL from config into the layout template
-	s = fmt.Sprintf("{{ define \"ginui\" }}%s{{ end }}", GetExternals().GinUiURL)
-	tmpl, err = tmpl.Parse(s)
-	if err != nil {
-		panic(err)
-	}
-	return tmpl
+// MakePath prepends the path prefix from the server config file to an absolute path
+// of a web resource provided by gin-auth.
+func MakePath(p string) string {
+	return GetServerConfig().PathPrefix + p
 }
@@ -20,3 +20,17 @@ func TestMakeUrl(t *testing.T) {
 		t.Error("Wrong url")
 	}
 }
+
+func TestMakePath(t *testing.T) {
+	config := GetServerConfig()
+	prefix := config.PathPrefix
+	defer func() { config.PathPrefix = prefix }()
+
+	if p := MakePath("/oauth/login"); p != "/oauth/login" {
+		t.Errorf("Path expected to be '/oauth/login' but was '%s'", p)
+	}
+	config.PathPrefix = "/auth"
+	if p := MakePath("/oauth/login"); p != "/auth/oauth/login" {
+		t.Errorf("Path expected to be '/auth/oauth/login' but was '%s'", p)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"strings"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Actions on repositories which can be authorized by AuthorizeAccess
+const (
+	ActionRead  = "read"
+	ActionWrite = "write"
+	ActionAdmin = "admin"
+)
+
+// Roles of an account on the repositories of an owner
+const (
+	RoleOwner  = "owner"
+	RoleReader = "reader"
+)
+
+// Scopes required for each action if the account is identified by an access token
+var actionScopes = map[string]util.StringSet{
+	ActionRead:  util.NewStringSet("repo-read", "repo-write"),
+	ActionWrite: util.NewStringSet("repo-write"),
+	ActionAdmin: util.NewStringSet("repo-write"),
+}
+
+// AccessRequest describes an action of an account on a repository of an owner. The account
+// is identified either by an access token or by the SHA256 fingerprint of an ssh key.
+// IP is the address of the requester, which is checked for tokens bound to a network.
+// Public indicates that the repository is publicly readable.
+type AccessRequest struct {
+	Token       string
+	Fingerprint string
+	IP          string
+	Owner       string
+	Action      string
+	Public      bool
+}
+
+// AccessDecision is the result of an access request including the effective roles of the account.
+type AccessDecision struct {
+	Allow  bool
+	Login  string
+	Roles  []string
+	Reason string
+}
+
+// Validate checks that the request identifies the account by exactly one of token and
+// fingerprint and contains an owner and a known action.
+func (req *AccessRequest) Validate() *util.ValidationError {
+	valErr := &util.ValidationError{FieldErrors: make(map[string]string)}
+	if (req.Token == "") == (req.Fingerprint == "") {
+		valErr.FieldErrors["token"] = "Please provide either a token or a key fingerprint"
+	}
+	if req.Owner == "" {
+		valErr.FieldErrors["owner"] = "Please provide the repository owner"
+	}
+	if _, ok := actionScopes[req.Action]; !ok {
+		valErr.FieldErrors["action"] = "Action must be one of 'read', 'write' or 'admin'"
+	}
+	if len(valErr.FieldErrors) > 0 {
+		valErr.Message = "Invalid access request"
+		return valErr
+	}
+	return nil
+}
+
+// AuthorizeAccess decides whether the account of the request may perform the action on the
+// repositories of the owner. Owners may perform all actions, other accounts may only read
+// public repositories. Access tokens additionally need the scope required for the action.
+func AuthorizeAccess(req *AccessRequest) (*AccessDecision, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	decision := &AccessDecision{Roles: make([]string, 0)}
+
+	var accountUUID string
+	scope := actionScopes[req.Action]
+	if req.Token != "" {
+		token, ok := GetAccessToken(req.Token)
+		if !ok || !token.AccountUUID.Valid {
+			decision.Reason = "Invalid or expired access token"
+			return decision, nil
+		}
+		if !token.AllowsIP(req.IP) {
+			decision.Reason = "Token is bound to a different network"
+			return decision, nil
+		}
+		accountUUID = token.AccountUUID.String
+		scope = scope.Intersect(token.Scope)
+	} else {
+		fingerprint := strings.TrimRight(strings.TrimPrefix(req.Fingerprint, "SHA256:"), "=")
+		key, ok := GetSSHKey(fingerprint)
+		if !ok {
+			decision.Reason = "Unknown ssh key"
+			return decision, nil
+		}
+		accountUUID = key.AccountUUID
+	}
+
+	account, ok := GetAccount(accountUUID)
+	if !ok {
+		decision.Reason = "Account is not active"
+		return decision, nil
+	}
+	decision.Login = account.Login
+
+	if account.Login == req.Owner {
+		decision.Roles = append(decision.Roles, RoleOwner)
+	} else if req.Public {
+		decision.Roles = append(decision.Roles, RoleReader)
+	}
+
+	switch {
+	case scope.Len() == 0:
+		decision.Reason = "Insufficient scope"
+	case account.Login == req.Owner:
+		decision.Allow = true
+	case req.Public && req.Action == ActionRead:
+		decision.Allow = true
+	default:
+		decision.Reason = "Access to repositories of the owner forbidden"
+	}
+
+	return decision, nil
+}
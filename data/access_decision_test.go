@@ -0,0 +1,55 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestAuthorizeAccess(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	_, err := AuthorizeAccess(&AccessRequest{Owner: "alice", Action: ActionRead})
+	if err == nil {
+		t.Error("Request without token and fingerprint should fail")
+	}
+	_, err = AuthorizeAccess(&AccessRequest{Token: accessTokenAlice, Owner: "alice", Action: "delete"})
+	if err == nil {
+		t.Error("Request with unknown action should fail")
+	}
+
+	tests := []struct {
+		name  string
+		req   *AccessRequest
+		allow bool
+		roles int
+	}{
+		{"owner with token", &AccessRequest{Token: accessTokenAlice, Owner: "alice", Action: ActionWrite}, true, 1},
+		{"owner with key", &AccessRequest{Fingerprint: "SHA256:A3tkBXFQWkjU6rzhkofY55G7tPR/Lmna4B+WEGVFXOQ", Owner: "alice", Action: ActionAdmin}, true, 1},
+		{"other private", &AccessRequest{Token: accessTokenAlice, Owner: "bob", Action: ActionRead}, false, 0},
+		{"other public read", &AccessRequest{Token: accessTokenAlice, Owner: "bob", Action: ActionRead, Public: true}, true, 1},
+		{"other public write", &AccessRequest{Token: accessTokenAlice, Owner: "bob", Action: ActionWrite, Public: true}, false, 1},
+		{"expired token", &AccessRequest{Token: accessTokenBob, Owner: "bob", Action: ActionRead}, false, 0},
+		{"bound token", &AccessRequest{Token: accessTokenBound, IP: "192.168.1.1", Owner: "alice", Action: ActionRead}, false, 0},
+		{"insufficient scope", &AccessRequest{Token: accessTokenBound, IP: "10.1.1.1", Owner: "alice", Action: ActionRead}, false, 1},
+		{"unknown key", &AccessRequest{Fingerprint: "doesNotExist", Owner: "alice", Action: ActionRead}, false, 0},
+	}
+	for _, test := range tests {
+		decision, err := AuthorizeAccess(test.req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Allow != test.allow || len(decision.Roles) != test.roles {
+			t.Errorf("Unexpected decision for %s: %+v", test.name, decision)
+		}
+	}
+}
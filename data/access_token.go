@@ -10,29 +10,35 @@ package data
 
 import (
 	"database/sql"
+	"net"
 	"time"
 
 	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/util"
+	"github.com/lib/pq"
 )
 
-// AccessToken represents an OAuth access token
+// AccessToken represents an OAuth access token. Tokens with a group restriction
+// act for the account only as member of the given groups.
 type AccessToken struct {
-	Token       string // This is just a random string not the JWT token
-	Scope       util.StringSet
-	Expires     time.Time
-	ClientUUID  string
-	AccountUUID sql.NullString
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	Token            string // This is just a random string not the JWT token
+	Scope            util.StringSet
+	Expires          time.Time
+	ClientUUID       string
+	AccountUUID      sql.NullString
+	BoundNetwork     sql.NullString
+	GroupRestriction util.StringSet
+	LastUsedAt       pq.NullTime
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 // ListAccessTokens returns all access tokens sorted by creation time.
 func ListAccessTokens() []AccessToken {
-	const q = `SELECT * FROM AccessTokens WHERE expires > now() ORDER BY createdAt`
+	const q = `SELECT * FROM AccessTokens WHERE expires > $1 ORDER BY createdAt`
 
 	accessTokens := make([]AccessToken, 0)
-	err := database.Select(&accessTokens, q)
+	err := database.Select(&accessTokens, q, expiryTime())
 	if err != nil {
 		panic(err)
 	}
@@ -43,10 +49,10 @@ func ListAccessTokens() []AccessToken {
 // GetAccessToken returns a access token with a given token.
 // Returns false if no such access token exists.
 func GetAccessToken(token string) (*AccessToken, bool) {
-	const q = `SELECT * FROM AccessTokens WHERE token=$1 AND expires > now()`
+	const q = `SELECT * FROM AccessTokens WHERE token=$1 AND expires > $2`
 
 	accessToken := &AccessToken{}
-	err := database.Get(accessToken, q, token)
+	err := database.Get(accessToken, q, token, expiryTime())
 	if err != nil && err != sql.ErrNoRows {
 		panic(err)
 	}
@@ -54,19 +60,58 @@ func GetAccessToken(token string) (*AccessToken, bool) {
 	return accessToken, err == nil
 }
 
+// GetAccessTokens returns all valid access tokens matching one of the given tokens.
+func GetAccessTokens(tokens []string) []AccessToken {
+	const q = `SELECT * FROM AccessTokens WHERE token = ANY($1::varchar[]) AND expires > $2`
+
+	accessTokens := make([]AccessToken, 0)
+	if len(tokens) == 0 {
+		return accessTokens
+	}
+	err := database.Select(&accessTokens, q, util.NewStringSet(tokens...), expiryTime())
+	if err != nil {
+		panic(err)
+	}
+
+	return accessTokens
+}
+
 // Create stores a new access token in the database.
 // If the token is empty a random token will be generated.
 func (tok *AccessToken) Create() error {
-	const q = `INSERT INTO AccessTokens (token, scope, expires, clientUUID, accountUUID, createdAt, updatedAt)
-	           VALUES ($1, $2, $3, $4, $5, now(), now())
+	const q = `INSERT INTO AccessTokens (token, scope, expires, clientUUID, accountUUID, boundNetwork, groupRestriction,
+	                                     createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
 	           RETURNING *`
 
-	tok.Expires = time.Now().Add(conf.GetServerConfig().TokenLifeTime)
+	tok.Expires = util.Now().Add(conf.GetServerConfig().TokenLifeTime)
 	if tok.Token == "" {
 		tok.Token = util.RandomToken()
 	}
 
-	return database.Get(tok, q, tok.Token, tok.Scope, tok.Expires, tok.ClientUUID, tok.AccountUUID)
+	err := database.Get(tok, q, tok.Token, tok.Scope, tok.Expires, tok.ClientUUID, tok.AccountUUID, tok.BoundNetwork,
+		tok.GroupRestriction)
+	if err == nil {
+		util.RecordEvent(util.AlertTokenIssued, "client "+tok.ClientUUID)
+		RecordTokenIssued(tok)
+	}
+	return err
+}
+
+// AllowsIP checks whether the token may be used from the given IP address.
+// Tokens without a bound network can be used from everywhere.
+func (tok *AccessToken) AllowsIP(ip string) bool {
+	if !tok.BoundNetwork.Valid {
+		return true
+	}
+
+	_, network, err := net.ParseCIDR(tok.BoundNetwork.String)
+	if err != nil {
+		return false
+	}
+	addr := util.NormalizeIP(ip)
+
+	return addr != nil && network.Contains(addr)
 }
 
 // UpdateExpirationTime updates the expiration time and stores
@@ -76,7 +121,7 @@ func (tok *AccessToken) UpdateExpirationTime() error {
 	           WHERE token=$2
 	           RETURNING *`
 
-	return database.Get(tok, q, time.Now().Add(conf.GetServerConfig().TokenLifeTime), tok.Token)
+	return database.Get(tok, q, util.Now().Add(conf.GetServerConfig().TokenLifeTime), tok.Token)
 }
 
 // Delete removes an access token from the database.
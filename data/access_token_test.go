@@ -19,6 +19,7 @@ import (
 const (
 	accessTokenAlice = "3N7MP7M7"
 	accessTokenBob   = "LJ3W7ZFK" // is expired
+	accessTokenBound = "B7NDW8TX" // is bound to 10.0.0.0/8
 )
 
 func TestListAccessTokens(t *testing.T) {
@@ -26,8 +27,8 @@ func TestListAccessTokens(t *testing.T) {
 	InitTestDb(t)
 
 	accessTokens := ListAccessTokens()
-	if len(accessTokens) != 2 {
-		t.Error("Exactly two access tokens expected in slice.")
+	if len(accessTokens) != 3 {
+		t.Error("Exactly three access tokens expected in slice.")
 	}
 }
 
@@ -54,6 +55,48 @@ func TestGetAccessToken(t *testing.T) {
 	}
 }
 
+func TestGetAccessTokens(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	tokens := GetAccessTokens([]string{accessTokenAlice, accessTokenBob, "doesNotExist"})
+	if len(tokens) != 1 || tokens[0].Token != accessTokenAlice {
+		t.Errorf("Only the valid token of alice expected but got %v", tokens)
+	}
+	if len(GetAccessTokens(nil)) != 0 {
+		t.Error("No tokens expected")
+	}
+}
+
+func TestAccessTokenExpiry(t *testing.T) {
+	InitTestDb(t)
+
+	now := time.Now()
+	defer util.SetClock(util.SetClock(util.FixedClock(now)))
+
+	tok := &AccessToken{Scope: util.NewStringSet("repo-read"), ClientUUID: uuidClientGin}
+	err := tok.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lifeTime := tok.Expires.Sub(now)
+
+	util.SetClock(util.FixedClock(now.Add(lifeTime + 2*time.Second)))
+	if _, ok := GetAccessToken(tok.Token); !ok {
+		t.Error("Token expected to be valid within the leeway")
+	}
+
+	util.SetClock(util.FixedClock(now.Add(lifeTime + time.Minute)))
+	if _, ok := GetAccessToken(tok.Token); ok {
+		t.Error("Token expected to be expired")
+	}
+	RemoveExpired(testServiceToken(t, JobCleaner))
+	util.SetClock(util.FixedClock(now))
+	if _, ok := GetAccessToken(tok.Token); ok {
+		t.Error("Expired token expected to be removed")
+	}
+}
+
 func TestCreateAccessToken(t *testing.T) {
 	InitTestDb(t)
 
@@ -152,3 +195,30 @@ func TestAccessTokenDelete(t *testing.T) {
 		t.Error("Access token should not exist")
 	}
 }
+
+func TestAccessTokenAllowsIP(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	tok, _ := GetAccessToken(accessTokenAlice)
+	if !tok.AllowsIP("192.0.2.1") {
+		t.Error("Unbound token should be allowed from everywhere")
+	}
+
+	tok, ok := GetAccessToken(accessTokenBound)
+	if !ok {
+		t.Error("Access token does not exist")
+	}
+	if !tok.AllowsIP("10.1.2.3") {
+		t.Error("Bound token should be allowed from within its network")
+	}
+	if !tok.AllowsIP("::ffff:10.1.2.3") {
+		t.Error("IPv4-mapped address should be allowed from within its network")
+	}
+	if tok.AllowsIP("192.0.2.1") {
+		t.Error("Bound token should not be allowed from outside of its network")
+	}
+	if tok.AllowsIP("") {
+		t.Error("Bound token should not be allowed from an unknown address")
+	}
+}
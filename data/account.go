@@ -19,31 +19,49 @@ import (
 	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/util"
 	"github.com/G-Node/gin-core/gin"
+	"github.com/lib/pq"
 	"github.com/pborman/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Account data as stored in the database
 type Account struct {
-	UUID                string
-	Login               string
-	PWHash              string `json:"-"` // safety net
-	Email               string
-	IsEmailPublic       bool
-	Title               sql.NullString
-	FirstName           string
-	MiddleName          sql.NullString
-	LastName            string
-	Institute           string
-	Department          string
-	City                string
-	Country             string
-	IsAffiliationPublic bool
-	ActivationCode      sql.NullString
-	ResetPWCode         sql.NullString
-	IsDisabled          bool
-	CreatedAt           time.Time
-	UpdatedAt           time.Time
+	UUID                     string
+	Login                    string
+	PWHash                   string `json:"-"` // safety net
+	Email                    string
+	IsEmailPublic            bool
+	IsEmailVerified          bool
+	EmailVerificationCode    sql.NullString
+	Title                    sql.NullString
+	FirstName                string
+	MiddleName               sql.NullString
+	LastName                 string
+	Institute                string
+	Department               string
+	City                     string
+	Country                  string
+	IsAffiliationPublic      bool
+	ActivationCode           sql.NullString
+	ActivationSentAt         pq.NullTime
+	ResetPWCode              sql.NullString
+	IsDisabled               bool
+	IsApprovalPending        bool
+	PasswordChangedAt        time.Time
+	IsPasswordExpiryNotified bool
+	IsPasswordBreached       bool
+	IsEmailBouncing          bool
+	NotificationMode         string
+	IsMagicLinkEnabled       bool
+	LastLoginAt              time.Time
+	DormancyNotifiedAt       pq.NullTime
+	DormantSince             pq.NullTime
+	IsArchived               bool
+	IsEmailUnmasked          bool
+	IsAnnouncementOptOut     bool
+	Timezone                 string
+	Locale                   string
+	CreatedAt                time.Time
+	UpdatedAt                time.Time
 }
 
 // ListAccounts returns all accounts stored in the database
@@ -59,22 +77,6 @@ func ListAccounts() []Account {
 	return accounts
 }
 
-// SearchAccounts returns all accounts stored in the database where the account name (firstName, middleName, lastName
-// or login) contains the search string.
-func SearchAccounts(search string) []Account {
-	const q = `SELECT * FROM ActiveAccounts
-	           WHERE lower(firstName) LIKE $1 OR lower(middleName) LIKE $1 OR lower(lastName) LIKE $1 OR lower(login) LIKE $1
-	           ORDER BY login`
-
-	accounts := make([]Account, 0)
-	err := database.Select(&accounts, q, "%"+strings.ToLower(search)+"%")
-	if err != nil {
-		panic(err)
-	}
-
-	return accounts
-}
-
 // GetAccount returns an account with matching UUID
 // Returns false if no account with such UUID exists
 func GetAccount(uuid string) (*Account, bool) {
@@ -104,6 +106,24 @@ func GetAccountByLogin(login string) (*Account, bool) {
 	return account, err == nil
 }
 
+// GetAccountByID returns an active account identified by its UUID or its login. UUIDs take
+// precedence over logins, which may have the same syntax.
+// Returns false if no such account exists.
+func GetAccountByID(id string) (*Account, bool) {
+	return resolveAccountID(id, GetAccount, GetAccountByLogin)
+}
+
+// resolveAccountID looks up an account by UUID if id has the length of a UUID and by login
+// otherwise or if no account with the UUID exists. UUIDs are compared in lower case.
+func resolveAccountID(id string, byUUID, byLogin func(string) (*Account, bool)) (*Account, bool) {
+	if len(id) == 36 {
+		if account, ok := byUUID(strings.ToLower(id)); ok {
+			return account, true
+		}
+	}
+	return byLogin(id)
+}
+
 // GetAccountByCredential returns an active account (non disabled, no activation code,
 // no reset password code) with matching login or email address.
 // Returns false if no account with such login or email address exists.
@@ -124,13 +144,7 @@ func GetAccountByCredential(id string) (*Account, bool) {
 func GetAccountByActivationCode(code string) (*Account, bool) {
 	const q = `SELECT * FROM Accounts WHERE activationCode=$1 AND NOT isDisabled`
 
-	account := &Account{}
-	err := database.Get(account, q, code)
-	if err != nil && err != sql.ErrNoRows {
-		panic(err)
-	}
-
-	return account, err == nil
+	return getAccountByCode(q, codeActivation, code, func(acc *Account) sql.NullString { return acc.ActivationCode })
 }
 
 // GetAccountByResetPWCode returns an account with matching reset password code.
@@ -138,13 +152,15 @@ func GetAccountByActivationCode(code string) (*Account, bool) {
 func GetAccountByResetPWCode(code string) (*Account, bool) {
 	const q = `SELECT * FROM Accounts WHERE resetPWCode=$1 AND NOT isDisabled`
 
-	account := &Account{}
-	err := database.Get(account, q, code)
-	if err != nil && err != sql.ErrNoRows {
-		panic(err)
-	}
+	return getAccountByCode(q, codeResetPassword, code, func(acc *Account) sql.NullString { return acc.ResetPWCode })
+}
 
-	return account, err == nil
+// GetAccountByEmailVerificationCode returns an active account with a matching e-mail verification code.
+// Returns false if no account with the verification code can be found.
+func GetAccountByEmailVerificationCode(code string) (*Account, bool) {
+	const q = `SELECT * FROM ActiveAccounts WHERE emailVerificationCode=$1`
+
+	return getAccountByCode(q, codeEmailVerification, code, func(acc *Account) sql.NullString { return acc.EmailVerificationCode })
 }
 
 // GetAccountDisabled returns a disabled account with a matching uuid.
@@ -161,51 +177,87 @@ func GetAccountDisabled(uuid string) (*Account, bool) {
 	return account, err == nil
 }
 
-// SetPasswordReset updates the password reset code with a new token, if an
+// SetPasswordReset updates the password reset code with a new code, if an
 // account can be found, that is non disabled and has either email or login of a provided credential.
+// Previous reset codes become invalid. The account and the new code are returned.
 // Returns false, if no non-disabled account with the credential as email or login can be found.
-func SetPasswordReset(credential string) (*Account, bool) {
+func SetPasswordReset(credential string) (*Account, string, bool) {
 	const q = `UPDATE Accounts SET resetpwcode=$2
 		   WHERE NOT isdisabled AND (login=$1 OR email=$1) RETURNING *`
 
-	code := util.RandomToken()
+	code, hash := newAccountCode(codeResetPassword, conf.GetAccountCodes().ResetLifeTime)
 	account := &Account{}
-	err := database.Get(account, q, credential, code)
+	err := database.Get(account, q, credential, hash)
 	if err != nil && err != sql.ErrNoRows {
 		panic(err)
 	}
 
-	return account, err == nil
+	return account, code, err == nil
 }
 
 // SetPassword hashes the plain text password and
 // sets PWHash to the new value.
 func (acc *Account) SetPassword(plain string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	hash, err := hashPassword(plain)
 	if err == nil {
-		acc.PWHash = string(hash)
+		acc.PWHash = hash
 	}
 	return err
 }
 
-// VerifyPassword checks whether the stored hash matches the plain text password
+// VerifyPassword checks whether the stored hash matches the plain text password.
+// Hashes in a format other than bcrypt (see PasswordVerifier) are replaced by a
+// bcrypt hash of the password if it matches. Accounts without a hash, like those returned
+// by lookups of unknown accounts, take as long as accounts with a bcrypt hash.
 func (acc *Account) VerifyPassword(plain string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(acc.PWHash), []byte(plain))
-	return err == nil
+	v, ok := findPasswordVerifier(acc.PWHash)
+	if !ok {
+		verifyDummyPassword(plain)
+		return false
+	}
+	if !v.Verify(acc.PWHash, plain) {
+		return false
+	}
+
+	// a failed upgrade is not an error since the old hash remains valid
+	if _, isBcrypt := v.(*bcryptVerifier); !isBcrypt && acc.UUID != "" {
+		acc.upgradePasswordHash(plain)
+	}
+	return true
 }
 
 // UpdatePassword hashes a plain text password
 // and updates the database entry of the corresponding account.
+// The password expiry starts anew.
 func (acc *Account) UpdatePassword(plain string) error {
-	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	hash, err := hashPassword(plain)
+	if err != nil {
+		return err
+	}
+
+	const q = `UPDATE Accounts SET pwhash=$1, passwordChangedAt=now(), isPasswordExpiryNotified=FALSE,
+	           isPasswordBreached=FALSE WHERE uuid=$2 RETURNING *`
+	err = database.Get(acc, q, hash, acc.UUID)
+	if err == nil {
+		acc.PWHash = hash
+		passwordChanged(acc)
+		err = acc.completeReverificationStep(reverificationPassword)
+	}
+	return err
+}
+
+// upgradePasswordHash replaces the stored hash by a bcrypt hash of the same password
+// without affecting the password expiry.
+func (acc *Account) upgradePasswordHash(plain string) error {
+	hash, err := hashPassword(plain)
 	if err != nil {
 		return err
 	}
 
 	const q = `UPDATE Accounts SET pwhash=$1 WHERE uuid=$2 RETURNING *`
-	err = database.Get(acc, q, string(hash), acc.UUID)
+	err = database.Get(acc, q, hash, acc.UUID)
 	if err == nil {
-		acc.PWHash = string(hash)
+		acc.PWHash = hash
 	}
 	return err
 }
@@ -213,7 +265,14 @@ func (acc *Account) UpdatePassword(plain string) error {
 // UpdateEmail checks validity of a new e-mail address and updates the current account
 // with a valid new e-mail address.
 // The normal account update does not include the e-mail address for safety reasons.
+// The new address is marked as not verified and outstanding verification codes become invalid,
+// use RenewEmailVerificationCode to obtain a code for the new address.
+// The change is recorded in the account history as originated by the account itself.
 func (acc *Account) UpdateEmail(email string) error {
+	return acc.updateEmail(email, acc.UUID)
+}
+
+func (acc *Account) updateEmail(email, changedBy string) error {
 	if !(len(email) > 2) || !strings.Contains(email, "@") {
 		return &util.ValidationError{
 			Message:     "Invalid e-mail address",
@@ -239,8 +298,25 @@ func (acc *Account) UpdateEmail(email string) error {
 			FieldErrors: map[string]string{"email": "Please choose a different e-mail address"}}
 	}
 
-	const q = `UPDATE Accounts SET email=$1 WHERE uuid=$2 RETURNING *`
-	err = database.Get(acc, q, email, acc.UUID)
+	const q = `UPDATE Accounts SET (email, isEmailVerified, emailVerificationCode, isEmailBouncing) =
+	           ($1, FALSE, NULL, EXISTS (SELECT 1 FROM EmailBounces WHERE email = lower($1) AND isSuppressed))
+	           WHERE uuid=$2 RETURNING *`
+
+	oldEmail := sql.NullString{String: acc.Email, Valid: true}
+	tx := database.MustBegin()
+	err = tx.Get(acc, q, email, acc.UUID)
+	if err != nil {
+		tx.Rollback()
+		panic(err)
+	}
+
+	err = recordAccountChange(tx, acc.UUID, "email", oldEmail, sql.NullString{String: email, Valid: true}, changedBy)
+	if err != nil {
+		tx.Rollback()
+		panic(err)
+	}
+
+	err = tx.Commit()
 	if err != nil {
 		panic(err)
 	}
@@ -249,13 +325,44 @@ func (acc *Account) UpdateEmail(email string) error {
 	return nil
 }
 
+// UpdateEmailUnmasked sets whether the public e-mail address of the account is shown in full
+// or masked in public responses.
+func (acc *Account) UpdateEmailUnmasked(unmasked bool) error {
+	const q = `UPDATE Accounts SET isEmailUnmasked=$1 WHERE uuid=$2 RETURNING *`
+
+	return database.Get(acc, q, unmasked, acc.UUID)
+}
+
+// UpdateLocale sets the timezone (IANA name) and locale used to show timestamps to the account owner.
+func (acc *Account) UpdateLocale(timezone, locale string) error {
+	valErr := &util.ValidationError{Message: "Invalid locale settings", FieldErrors: make(map[string]string)}
+	if _, err := util.LoadTimezone(timezone); err != nil {
+		valErr.FieldErrors["timezone"] = "Please use a valid timezone name like 'Europe/Berlin'"
+	}
+	if !util.IsLocaleSupported(locale) {
+		valErr.FieldErrors["locale"] = "The locale is not supported"
+	}
+	if len(valErr.FieldErrors) > 0 {
+		return valErr
+	}
+
+	const q = `UPDATE Accounts SET timezone=$1, locale=$2 WHERE uuid=$3 RETURNING *`
+
+	return database.Get(acc, q, timezone, locale, acc.UUID)
+}
+
+// TimeFormat returns the format for timestamps shown to the account owner.
+func (acc *Account) TimeFormat() *util.TimeFormat {
+	return util.NewTimeFormat(acc.Timezone, acc.Locale)
+}
+
 // Create stores the account as new Account in the database.
 // If the UUID string is empty a new UUID will be generated.
 func (acc *Account) Create() error {
 	const q = `INSERT INTO Accounts (uuid, login, pwHash, email, isEmailPublic, title, firstName, middleName, lastName,
 	                                 institute, department, city, country, isAffiliationPublic, activationCode,
-	                                 createdAt, updatedAt)
-	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, now(), now())
+	                                 isApprovalPending, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, now(), now())
 	           RETURNING *`
 
 	if acc.UUID == "" {
@@ -264,9 +371,12 @@ func (acc *Account) Create() error {
 
 	err := database.Get(acc, q, acc.UUID, acc.Login, acc.PWHash, acc.Email, acc.IsEmailPublic, acc.Title, acc.FirstName,
 		acc.MiddleName, acc.LastName, acc.Institute, acc.Department, acc.City, acc.Country, acc.IsAffiliationPublic,
-		acc.ActivationCode)
+		acc.ActivationCode, acc.IsApprovalPending)
 
 	// TODO There is a lot of room for improvement here concerning errors about constraints for certain fields
+	if err == nil {
+		accountCreated(acc)
+	}
 	return err
 }
 
@@ -289,7 +399,15 @@ func (acc *Account) SSHKeys() []SSHKey {
 // Field ActivationCode is not set via this update function, since this field fulfills a special role.
 // It can only be set to a value once by account create and can only be set to null via its own function.
 // Fields password and email are not set via this update function, since they require sufficient scope to change.
+// Changes on profile fields are recorded in the account history without an originator, see UpdateBy.
 func (acc *Account) Update() error {
+	return acc.UpdateBy("")
+}
+
+// UpdateBy works like Update but records changes on profile fields in the account
+// history together with the UUID of the account that originated the update.
+func (acc *Account) UpdateBy(changedBy string) error {
+	const qOld = `SELECT * FROM Accounts WHERE uuid=$1 FOR UPDATE`
 	const q = `UPDATE Accounts
 	           SET (isemailpublic, title, firstName, middleName, lastName, institute,
 	                department, city, country, isaffiliationpublic, resetPWCode, isDisabled, updatedAt) =
@@ -297,19 +415,47 @@ func (acc *Account) Update() error {
 	           WHERE uuid=$13
 	           RETURNING *`
 
-	err := database.Get(acc, q, acc.IsEmailPublic, acc.Title, acc.FirstName, acc.MiddleName,
+	tx := database.MustBegin()
+
+	old := &Account{}
+	err := tx.Get(old, qOld, acc.UUID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	err = tx.Get(acc, q, acc.IsEmailPublic, acc.Title, acc.FirstName, acc.MiddleName,
 		acc.LastName, acc.Institute, acc.Department, acc.City, acc.Country, acc.IsAffiliationPublic,
 		acc.ResetPWCode, acc.IsDisabled, acc.UUID)
+	if err != nil {
+		// TODO There is a lot of room for improvement here concerning errors about constraints for certain fields
+		tx.Rollback()
+		return err
+	}
 
-	// TODO There is a lot of room for improvement here concerning errors about constraints for certain fields
-	return err
+	err = recordAccountChanges(tx, old, acc, changedBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if old.IsDisabled && !acc.IsDisabled {
+		err = acc.resetDormancy(tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 // RemoveActivationCode is the only way to remove an ActivationCode from an Account,
 // since this field should never be set via the Update function by accident.
+// Since the activation code is sent via e-mail, the e-mail address is marked as verified.
 func (acc *Account) RemoveActivationCode() error {
 	const q = `UPDATE Accounts
-	           SET activationcode = NULL
+	           SET (activationcode, isEmailVerified) = (NULL, TRUE)
 	           WHERE uuid=$1
 	           RETURNING *`
 
@@ -318,6 +464,77 @@ func (acc *Account) RemoveActivationCode() error {
 	return err
 }
 
+// RenewEmailVerificationCode replaces the e-mail verification code of an account
+// with a fresh one, which is returned. Previous codes become invalid.
+// Returns an error if the e-mail address is already verified.
+func (acc *Account) RenewEmailVerificationCode() (string, error) {
+	const q = `UPDATE Accounts
+	           SET emailVerificationCode = $1
+	           WHERE uuid=$2 AND NOT isEmailVerified
+	           RETURNING *`
+
+	code, hash := newAccountCode(codeEmailVerification, conf.GetAccountCodes().VerificationLifeTime)
+	err := database.Get(acc, q, hash, acc.UUID)
+	if err == sql.ErrNoRows {
+		return "", conflictError("E-mail address is already verified")
+	}
+
+	return code, err
+}
+
+// VerifyEmail marks the e-mail address of an account as verified and
+// removes the e-mail verification code. Bounces reported for the address are removed.
+func (acc *Account) VerifyEmail() error {
+	const q = `UPDATE Accounts
+	           SET (isEmailVerified, emailVerificationCode) = (TRUE, NULL)
+	           WHERE uuid=$1
+	           RETURNING *`
+
+	err := database.Get(acc, q, acc.UUID)
+	if err != nil {
+		return err
+	}
+	err = acc.completeReverificationStep(reverificationEmail)
+	if err != nil {
+		return err
+	}
+
+	// a verified address receives e-mails again
+	if bounce, ok := GetEmailBounce(acc.Email); ok {
+		err = bounce.Delete()
+		acc.IsEmailBouncing = false
+	}
+	return err
+}
+
+// Logins consist of letters, digits, hyphens and underscores
+var accountLoginRegex = regexp.MustCompile("^[a-zA-Z0-9-_]*$")
+
+// IsValidLogin checks whether the login is not empty and only contains allowed characters.
+func IsValidLogin(login string) bool {
+	return login != "" && len(login) <= 512 && accountLoginRegex.MatchString(login)
+}
+
+// AccountExists checks whether an account (including disabled and pending accounts)
+// with the given login or e-mail address exists.
+func AccountExists(login, email string) (loginExists bool, emailExists bool) {
+	exists := &struct {
+		Login bool
+		Email bool
+	}{}
+
+	const q = `SELECT
+	             (SELECT COUNT(*) FROM accounts WHERE login = $1) <> 0 AS login,
+	             (SELECT COUNT(*) FROM accounts WHERE email = $2) <> 0 AS email`
+
+	err := database.Get(exists, q, login, email)
+	if err != nil {
+		panic(err)
+	}
+
+	return exists.Login, exists.Email
+}
+
 // Validate the content of an Account.
 // First name, last name, login, email, institute, department, city and country must not be empty;
 // Title, first name, middle name last name, login, email, institute, department, city
@@ -329,8 +546,7 @@ func (acc *Account) Validate() *util.ValidationError {
 	if acc.Login == "" {
 		valErr.FieldErrors["login"] = "Please add login"
 	}
-	re := regexp.MustCompile("^[a-zA-Z0-9-_]*$")
-	if !re.MatchString(acc.Login) {
+	if !accountLoginRegex.MatchString(acc.Login) {
 		valErr.FieldErrors["login"] = "Please use only the following characters: 'a-zA-Z0-9-_'"
 	}
 
@@ -390,23 +606,11 @@ func (acc *Account) Validate() *util.ValidationError {
 		valErr.FieldErrors["country"] = lenMessage
 	}
 
-	exists := &struct {
-		Login bool
-		Email bool
-	}{}
-
-	const q = `SELECT
-	             (SELECT COUNT(*) FROM accounts WHERE login = $1) <> 0 AS login,
-	             (SELECT COUNT(*) FROM accounts WHERE email = $2) <> 0 AS email`
-
-	err := database.Get(exists, q, acc.Login, acc.Email)
-	if err != nil {
-		panic(err)
-	}
-	if exists.Login {
+	loginExists, emailExists := AccountExists(acc.Login, acc.Email)
+	if loginExists {
 		valErr.FieldErrors["login"] = "Please choose a different login"
 	}
-	if exists.Email {
+	if emailExists {
 		valErr.FieldErrors["email"] = "Please choose a different email address"
 	}
 
@@ -422,16 +626,66 @@ func (acc *Account) Validate() *util.ValidationError {
 // Fields:
 // - WithMail        If true, mail information will be serialized
 // - WithAffiliation If true, affiliation will be serialized
+// - WithAdmin       If true, fields only visible to administrators will be serialized
+// - WithLocale      If true, timezone and locale preferences will be serialized
+// - MaskMail        If true, the e-mail address will be masked (see util.MaskEmail)
+// - Groups, Labels  If not nil, they will be serialized together with the administrative fields
+// - Links           URLs of related resources by relation, serialized as "_links"
+//
+// Use NewAccountMarshaler in order to derive the fields from the scope of an access token.
 type AccountMarshaler struct {
 	WithMail        bool
 	WithAffiliation bool
+	WithAdmin       bool
+	WithLocale      bool
+	MaskMail        bool
 	Account         *Account
+	Groups          util.StringSet
+	Labels          util.StringSet
+	Links           map[string]string
+}
+
+// Scope which allows the owner of an account to read a non public e-mail address.
+const ScopeAccountReadEmail = "account-read-email"
+
+// NewAccountMarshaler returns a marshaler which serializes the fields of the account
+// visible to the given access token, which may be nil for anonymous requests:
+// - e-mail if it is public, for the owner with 'account-read-email' or 'account-write' or for admins
+// - affiliation if it is public, for the owner with 'account-read' or 'account-write' or for admins
+// - administrative fields for admins
+// - timezone and locale for the owner or for admins
+// Admins are tokens with scope 'account-admin' or 'admin-read'.
+// A public e-mail address is masked for everyone else unless the owner opted in to show the full address.
+func NewAccountMarshaler(account *Account, token *AccessToken) *AccountMarshaler {
+	scope := util.NewStringSet()
+	isOwner := false
+	if token != nil {
+		scope = token.Scope
+		isOwner = token.AccountUUID.Valid && token.AccountUUID.String == account.UUID
+	}
+	isAdmin := HasAdminScope(scope, ScopeAdminRead)
+	readMail := isAdmin || isOwner && (scope.Contains(ScopeAccountReadEmail) || scope.Contains("account-write"))
+
+	return &AccountMarshaler{
+		WithMail: account.IsEmailPublic || readMail,
+		WithAffiliation: account.IsAffiliationPublic || isAdmin ||
+			isOwner && (scope.Contains("account-read") || scope.Contains("account-write")),
+		WithAdmin:  isAdmin,
+		WithLocale: isAdmin || isOwner,
+		MaskMail:   !readMail && !account.IsEmailUnmasked,
+		Account:    account,
+	}
 }
 
-// MarshalJSON implements Marshaler for AccountMarshaler
+// MarshalJSON implements Marshaler for AccountMarshaler.
+// If mail information is serialized the verification state of the e-mail address
+// is added as field "email_verified" and a suppressed address is marked by "email_bouncing".
+// Timezone and locale preferences are added as fields "timezone" and "locale".
+// Administrative fields are added as object "admin", which contains groups and labels if they were loaded.
+// Links are added as object "_links", which contains an object with the URL in "href" for each relation.
 func (am *AccountMarshaler) MarshalJSON() ([]byte, error) {
 	jsonData := &gin.Account{
-		URL:       conf.MakeUrl("/api/accounts/%s", am.Account.Login),
+		URL:       conf.MakeUrl("/api/v1/accounts/%s", am.Account.Login),
 		UUID:      am.Account.UUID,
 		Login:     am.Account.Login,
 		FirstName: am.Account.FirstName,
@@ -445,11 +699,18 @@ func (am *AccountMarshaler) MarshalJSON() ([]byte, error) {
 	if am.Account.MiddleName.Valid {
 		jsonData.MiddleName = &am.Account.MiddleName.String
 	}
+	var emailVerified *bool
+	var emailBouncing bool
 	if am.WithMail {
 		jsonData.Email = &gin.Email{
 			Email:    am.Account.Email,
 			IsPublic: am.Account.IsEmailPublic,
 		}
+		if am.MaskMail {
+			jsonData.Email.Email = util.MaskEmail(am.Account.Email)
+		}
+		emailVerified = &am.Account.IsEmailVerified
+		emailBouncing = am.Account.IsEmailBouncing
 	}
 	if am.WithAffiliation {
 		jsonData.Affiliation = &gin.Affiliation{
@@ -460,7 +721,54 @@ func (am *AccountMarshaler) MarshalJSON() ([]byte, error) {
 			IsPublic:   am.Account.IsAffiliationPublic,
 		}
 	}
-	return json.Marshal(jsonData)
+	type adminFields struct {
+		Disabled          bool      `json:"disabled"`
+		ApprovalPending   bool      `json:"approval_pending"`
+		Activated         bool      `json:"activated"`
+		PasswordChangedAt time.Time `json:"password_changed_at"`
+		LastLoginAt       time.Time `json:"last_login_at"`
+		Groups            []string  `json:"groups,omitempty"`
+		Labels            []string  `json:"labels,omitempty"`
+	}
+	var admin *adminFields
+	if am.WithAdmin {
+		admin = &adminFields{
+			Disabled:          am.Account.IsDisabled,
+			ApprovalPending:   am.Account.IsApprovalPending,
+			Activated:         !am.Account.ActivationCode.Valid,
+			PasswordChangedAt: am.Account.PasswordChangedAt,
+			LastLoginAt:       am.Account.LastLoginAt,
+		}
+		if am.Groups != nil {
+			admin.Groups = am.Groups.Strings()
+		}
+		if am.Labels != nil {
+			admin.Labels = am.Labels.Strings()
+		}
+	}
+	var timezone, locale string
+	if am.WithLocale {
+		timezone, locale = am.Account.Timezone, am.Account.Locale
+	}
+	type link struct {
+		Href string `json:"href"`
+	}
+	var links map[string]link
+	if len(am.Links) > 0 {
+		links = make(map[string]link, len(am.Links))
+		for rel, href := range am.Links {
+			links[rel] = link{href}
+		}
+	}
+	return json.Marshal(&struct {
+		*gin.Account
+		EmailVerified *bool           `json:"email_verified,omitempty"`
+		EmailBouncing bool            `json:"email_bouncing,omitempty"`
+		Timezone      string          `json:"timezone,omitempty"`
+		Locale        string          `json:"locale,omitempty"`
+		Admin         *adminFields    `json:"admin,omitempty"`
+		Links         map[string]link `json:"_links,omitempty"`
+	}{jsonData, emailVerified, emailBouncing, timezone, locale, admin, links})
 }
 
 // UnmarshalJSON implements Unmarshaler for AccountMarshaler.
@@ -0,0 +1,83 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+)
+
+// ListPendingAccounts returns all accounts which are waiting for approval by an
+// administrator ordered by creation time.
+func ListPendingAccounts() []Account {
+	const q = `SELECT * FROM Accounts WHERE isApprovalPending AND NOT isDisabled ORDER BY createdAt, login`
+
+	accounts := make([]Account, 0)
+	err := database.Select(&accounts, q)
+	if err != nil {
+		panic(err)
+	}
+
+	return accounts
+}
+
+// GetPendingAccount returns an account with the given UUID or login which is waiting for approval.
+// Returns false if no such account exists.
+func GetPendingAccount(id string) (*Account, bool) {
+	const qUUID = `SELECT * FROM Accounts WHERE uuid=$1 AND isApprovalPending AND NOT isDisabled`
+	const qLogin = `SELECT * FROM Accounts WHERE login=$1 AND isApprovalPending AND NOT isDisabled`
+
+	get := func(q string) func(string) (*Account, bool) {
+		return func(id string) (*Account, bool) {
+			account := &Account{}
+			err := database.Get(account, q, id)
+			if err != nil && err != sql.ErrNoRows {
+				panic(err)
+			}
+			return account, err == nil
+		}
+	}
+
+	return resolveAccountID(id, get(qUUID), get(qLogin))
+}
+
+// Approve removes the pending state from an account, thus the account can be used
+// as soon as it is activated.
+func (acc *Account) Approve() error {
+	const q = `UPDATE Accounts SET (isApprovalPending, updatedAt) = (FALSE, now())
+	           WHERE uuid=$1
+	           RETURNING *`
+
+	return database.Get(acc, q, acc.UUID)
+}
+
+// Reject removes an account which is waiting for approval from the database. A dry run
+// removes the account in a transaction, which is rolled back.
+func (acc *Account) Reject(dryRun bool) error {
+	const q = `DELETE FROM Accounts WHERE uuid=$1 AND isApprovalPending`
+
+	tx := database.MustBegin()
+	res, err := tx.Exec(q, acc.UUID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		tx.Rollback()
+		return conflictError("Account is not waiting for approval")
+	}
+	if dryRun {
+		return tx.Rollback()
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	accountDeleted(acc)
+	return nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestListPendingAccounts(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	accounts := ListPendingAccounts()
+	if len(accounts) != 1 {
+		t.Fatalf("One pending account expected but was %d", len(accounts))
+	}
+	if accounts[0].Login != "pending" {
+		t.Error("Pending account expected to be 'pending'")
+	}
+	if _, ok := GetAccountByLogin("pending"); ok {
+		t.Error("Pending account should not be active")
+	}
+}
+
+func TestGetPendingAccount(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	if _, ok := GetPendingAccount("pending"); !ok {
+		t.Error("Pending account does not exist")
+	}
+	if _, ok := GetPendingAccount("test0007-1234-6789-1234-678901234567"); !ok {
+		t.Error("Pending account does not exist by UUID")
+	}
+	if _, ok := GetPendingAccount("alice"); ok {
+		t.Error("Account 'alice' is not pending")
+	}
+}
+
+func TestAccount_Approve(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	acc, _ := GetPendingAccount("pending")
+	err := acc.Approve()
+	if err != nil {
+		t.Error(err)
+	}
+	if acc.IsApprovalPending {
+		t.Error("Account should not be pending")
+	}
+	if _, ok := GetAccountByLogin("pending"); !ok {
+		t.Error("Approved account should be active")
+	}
+}
+
+func TestAccount_Reject(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	acc, _ := GetPendingAccount("pending")
+	err := acc.Reject(true)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := GetPendingAccount("pending"); !ok {
+		t.Error("Account should not be removed by a dry run")
+	}
+
+	err = acc.Reject(false)
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := GetPendingAccount("pending"); ok {
+		t.Error("Rejected account should be removed")
+	}
+
+	alice, _ := GetAccountByLogin("alice")
+	err = alice.Reject(true)
+	if err == nil {
+		t.Error("Rejecting an account which is not pending should fail")
+	}
+}
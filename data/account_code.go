@@ -0,0 +1,112 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Purposes of codes which are sent by e-mail. A code is only valid for its purpose.
+const (
+	codeActivation        = "activation"
+	codeResetPassword     = "reset-password"
+	codeEmailVerification = "email-verification"
+	codeAccountRecovery   = "account-recovery"
+)
+
+// newAccountCode creates a random code for a purpose which expires after lifeTime.
+// The code ends with its expiration time, only the HMAC of the code is stored, such that
+// codes can not be obtained from the database and their expiration time can not be altered.
+func newAccountCode(purpose string, lifeTime time.Duration) (code string, hash sql.NullString) {
+	code = util.RandomToken()[:40] + "." + strconv.FormatInt(util.Now().Add(lifeTime).Unix(), 10)
+	hash, _ = hashAccountCode(purpose, code)
+	return code, hash
+}
+
+// hashAccountCode returns the HMAC of a code for a purpose. Returns false if the code is
+// malformed or expired.
+func hashAccountCode(purpose, code string) (sql.NullString, bool) {
+	i := strings.LastIndex(code, ".")
+	if i < 1 {
+		return sql.NullString{}, false
+	}
+	expires, err := strconv.ParseInt(code[i+1:], 10, 64)
+	if err != nil || !time.Unix(expires, 0).After(expiryTime()) {
+		return sql.NullString{}, false
+	}
+
+	mac := hmac.New(sha256.New, conf.GetAccountCodes().Secret)
+	mac.Write([]byte(purpose + ":" + code))
+	return sql.NullString{String: hex.EncodeToString(mac.Sum(nil)), Valid: true}, true
+}
+
+// checkAccountCode compares a code with a stored HMAC in constant time.
+func checkAccountCode(purpose, code string, stored sql.NullString) bool {
+	hash, ok := hashAccountCode(purpose, code)
+	return ok && stored.Valid && hmac.Equal([]byte(hash.String), []byte(stored.String))
+}
+
+// getAccountByCode returns the account matching the code stored in column. The query
+// must select an account by the stored hash given as first parameter.
+func getAccountByCode(q, purpose, code string, stored func(acc *Account) sql.NullString) (*Account, bool) {
+	account := &Account{}
+	hash, ok := hashAccountCode(purpose, code)
+	if !ok {
+		return account, false
+	}
+
+	err := database.Get(account, q, hash)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return account, err == nil && checkAccountCode(purpose, code, stored(account))
+}
+
+// SetActivationCode replaces the activation code of an account which is not yet stored
+// by a new one. The code is returned, the account only contains its HMAC.
+func (acc *Account) SetActivationCode() string {
+	code, hash := newAccountCode(codeActivation, conf.GetServerConfig().UnusedAccountLifeTime)
+	acc.ActivationCode = hash
+	return code
+}
+
+// RenewActivationCode replaces the activation code of the not yet activated account with the given
+// login or e-mail address and returns the account together with the new code. The code is only
+// renewed if neither the registration nor the last renewal happened within interval, such that
+// activation e-mails are sent at most once per interval. An unknown, activated or recently registered
+// or renewed account results in a not found error, callers should not reveal which of these cases applies.
+func RenewActivationCode(credential string, interval time.Duration) (*Account, string, error) {
+	const q = `UPDATE Accounts
+	           SET (activationCode, activationSentAt, updatedAt) = ($1, now(), now())
+	           WHERE (login=$2 OR email=$2) AND activationCode IS NOT NULL AND NOT isDisabled
+	           AND COALESCE(activationSentAt, createdAt) < $3
+	           RETURNING *`
+
+	code, hash := newAccountCode(codeActivation, conf.GetServerConfig().UnusedAccountLifeTime)
+	account := &Account{}
+	err := database.Get(account, q, hash, credential, util.Now().Add(-interval))
+	if err == sql.ErrNoRows {
+		return nil, "", notFoundError("No account waiting for activation")
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return account, code, nil
+}
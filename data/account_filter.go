@@ -0,0 +1,173 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+	"github.com/lib/pq"
+)
+
+// Names of account filters consist of lower case letters, digits and hyphens, e.g. "unverified-30-days"
+var accountFilterNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// Maximum length of descriptions of account filters
+const maxAccountFilterDescriptionLength = 512
+
+// AccountFilter selects active accounts by a search string, a label, a group, whether their e-mail
+// address is verified and the days since their registration or last login. Empty values match
+// all accounts. Administrators save filters under a name, such that recurring queries like
+// "unverified for more than 30 days" can be re-run or used as recipients of announcements.
+type AccountFilter struct {
+	Name          string
+	Description   string
+	Search        string
+	Label         string
+	GroupName     string
+	EmailVerified sql.NullBool
+	CreatedDays   int // accounts registered more than this number of days ago
+	InactiveDays  int // accounts without login, or registration if never logged in, for more than this number of days
+	UpdatedBy     sql.NullString
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// condition on ActiveAccounts a which selects the accounts matching a filter,
+// $1 to $7 are the parameters returned by AccountFilter.params
+const qAccountFilterCondition = `($1 = '' OR lower(a.firstName) LIKE $2 OR lower(a.middleName) LIKE $2
+                                            OR lower(a.lastName) LIKE $2 OR lower(a.login) LIKE $2)
+                                 AND ($3 = '' OR EXISTS (SELECT 1 FROM AccountNotes n
+                                                         WHERE n.accountUUID = a.uuid AND $3 = ANY(n.labels)))
+                                 AND ($4 = '' OR EXISTS (SELECT 1 FROM GroupMembers m JOIN Groups g ON g.uuid = m.groupUUID
+                                                         WHERE m.accountUUID = a.uuid AND g.name = $4))
+                                 AND ($5::boolean IS NULL OR a.isEmailVerified = $5)
+                                 AND ($6::timestamptz IS NULL OR a.createdAt < $6)
+                                 AND ($7::timestamptz IS NULL OR COALESCE(a.lastLoginAt, a.createdAt) < $7)`
+
+// GetAccountFilter returns the saved account filter with the given name.
+// Returns false if no such filter exists.
+func GetAccountFilter(name string) (*AccountFilter, bool) {
+	const q = `SELECT * FROM AccountFilters WHERE name=$1`
+
+	filter := &AccountFilter{}
+	err := database.Get(filter, q, name)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return filter, err == nil
+}
+
+// ListAccountFilters returns all saved account filters ordered by name.
+func ListAccountFilters() []AccountFilter {
+	const q = `SELECT * FROM AccountFilters ORDER BY name`
+
+	filters := make([]AccountFilter, 0)
+	err := database.Select(&filters, q)
+	if err != nil {
+		panic(err)
+	}
+
+	return filters
+}
+
+// params returns the parameters of qAccountFilterCondition.
+func (filter *AccountFilter) params() []interface{} {
+	var createdBefore, inactiveBefore pq.NullTime
+	if filter.CreatedDays > 0 {
+		createdBefore = pq.NullTime{Time: util.Now().AddDate(0, 0, -filter.CreatedDays), Valid: true}
+	}
+	if filter.InactiveDays > 0 {
+		inactiveBefore = pq.NullTime{Time: util.Now().AddDate(0, 0, -filter.InactiveDays), Valid: true}
+	}
+
+	return []interface{}{filter.Search, "%" + strings.ToLower(filter.Search) + "%", filter.Label,
+		filter.GroupName, filter.EmailVerified, createdBefore, inactiveBefore}
+}
+
+// Count returns the number of active accounts matching the filter.
+func (filter *AccountFilter) Count() int {
+	const q = `SELECT count(*) FROM ActiveAccounts a WHERE ` + qAccountFilterCondition
+
+	var count int
+	err := database.Get(&count, q, filter.params()...)
+	if err != nil {
+		panic(err)
+	}
+
+	return count
+}
+
+// Validate checks the name, the criteria and the description of the filter.
+func (filter *AccountFilter) Validate() error {
+	fieldErrors := make(map[string]string)
+	if !accountFilterNameRegex.MatchString(filter.Name) {
+		fieldErrors["name"] = "Please use lower case letters, digits and hyphens only"
+	}
+	if len(filter.Description) > maxAccountFilterDescriptionLength {
+		fieldErrors["description"] = "Please use at most 512 characters"
+	}
+	if filter.Label != "" && !accountLabelRegex.MatchString(filter.Label) {
+		fieldErrors["label"] = "Invalid label"
+	}
+	if filter.GroupName != "" {
+		if _, ok := GetGroup(filter.GroupName); !ok {
+			fieldErrors["group"] = "The group does not exist"
+		}
+	}
+	if filter.CreatedDays < 0 {
+		fieldErrors["created_days"] = "Please use a positive number of days"
+	}
+	if filter.InactiveDays < 0 {
+		fieldErrors["inactive_days"] = "Please use a positive number of days"
+	}
+	if len(fieldErrors) > 0 {
+		return &util.ValidationError{Message: "Invalid account filter", FieldErrors: fieldErrors}
+	}
+	return nil
+}
+
+// SaveBy stores the filter, an existing filter with the same name is replaced.
+// The given UUID identifies the account of the administrator who saved the filter.
+func (filter *AccountFilter) SaveBy(changedBy string) error {
+	if err := filter.Validate(); err != nil {
+		return err
+	}
+
+	const q = `INSERT INTO AccountFilters (name, description, search, label, groupName, emailVerified,
+	                                       createdDays, inactiveDays, updatedBy, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now(), now())
+	           ON CONFLICT (name) DO UPDATE
+	           SET (description, search, label, groupName, emailVerified, createdDays, inactiveDays, updatedBy, updatedAt) =
+	               (EXCLUDED.description, EXCLUDED.search, EXCLUDED.label, EXCLUDED.groupName, EXCLUDED.emailVerified,
+	                EXCLUDED.createdDays, EXCLUDED.inactiveDays, EXCLUDED.updatedBy, now())
+	           RETURNING *`
+
+	filter.UpdatedBy = sql.NullString{String: changedBy, Valid: changedBy != ""}
+	return database.Get(filter, q, filter.Name, filter.Description, filter.Search, filter.Label, filter.GroupName,
+		filter.EmailVerified, filter.CreatedDays, filter.InactiveDays, filter.UpdatedBy)
+}
+
+// DeleteAccountFilter removes the saved filter with the given name.
+func DeleteAccountFilter(name string) error {
+	const q = `DELETE FROM AccountFilters WHERE name=$1`
+
+	res, err := database.Exec(q, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return notFoundError("Account filter '%s' does not exist", name)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestAccountFilter_EachListing(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	logins := func(filter *AccountFilter) []string {
+		result := make([]string, 0)
+		err := filter.EachListing(func(listing *AccountListing) error {
+			result = append(result, listing.Login)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	if l := logins(&AccountFilter{}); len(l) != 3 {
+		t.Errorf("Three accounts expected but was %v", l)
+	}
+	if l := logins(&AccountFilter{EmailVerified: sql.NullBool{Bool: false, Valid: true}}); len(l) != 1 || l[0] != "john" {
+		t.Errorf("Only john expected to be unverified but was %v", l)
+	}
+	if l := logins(&AccountFilter{GroupName: "lmu-neuro", Search: "bob"}); len(l) != 1 || l[0] != "bob" {
+		t.Errorf("Only bob expected but was %v", l)
+	}
+	if l := logins(&AccountFilter{CreatedDays: 36500}); len(l) != 0 {
+		t.Errorf("No accounts expected to be registered a century ago but was %v", l)
+	}
+
+	// the last login defaults to the time the fixtures were loaded, move it back to the registration
+	database.MustExec(`UPDATE Accounts SET lastLoginAt = createdAt`)
+	if l := logins(&AccountFilter{InactiveDays: 90}); len(l) != 3 {
+		t.Errorf("Three inactive accounts expected but was %v", l)
+	}
+	acc, _ := GetAccountByLogin("bob")
+	err := acc.RecordLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := logins(&AccountFilter{InactiveDays: 90}); len(l) != 2 {
+		t.Errorf("Bob expected to be active after a login but was %v", l)
+	}
+
+	filter, ok := GetAccountFilter("unverified-30-days")
+	if !ok {
+		t.Fatal("Saved filter expected")
+	}
+	if filter.Count() != 1 {
+		t.Errorf("One account expected to match the saved filter but was %d", filter.Count())
+	}
+}
+
+func TestAccountFilter_SaveBy(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	invalid := &AccountFilter{Name: "Invalid Name", GroupName: "doesnotexist", CreatedDays: -1}
+	err := invalid.SaveBy(uuidBob)
+	valErr, ok := err.(*util.ValidationError)
+	if !ok || len(valErr.FieldErrors) != 3 {
+		t.Errorf("Validation error for name, group and created days expected but was %v", err)
+	}
+
+	filter := &AccountFilter{Name: "inactive-researchers", Label: "verified researcher", InactiveDays: 90}
+	err = filter.SaveBy(uuidBob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filter.CreatedAt.IsZero() || filter.UpdatedBy.String != uuidBob {
+		t.Errorf("Unexpected filter: %+v", filter)
+	}
+	if len(ListAccountFilters()) != 2 {
+		t.Error("Two saved filters expected")
+	}
+
+	filter.Description = "Researchers without login for three months"
+	err = filter.SaveBy(uuidAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := GetAccountFilter("inactive-researchers"); saved.Description != filter.Description {
+		t.Errorf("Filter expected to be replaced: %+v", saved)
+	}
+}
+
+func TestDeleteAccountFilter(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	err := DeleteAccountFilter("unverified-30-days")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := GetAccountFilter("unverified-30-days"); ok {
+		t.Error("Filter expected to be deleted")
+	}
+	err = DeleteAccountFilter("unverified-30-days")
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Not found error expected but was %v", err)
+	}
+}
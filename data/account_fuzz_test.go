@@ -0,0 +1,53 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+//go:build go1.18
+// +build go1.18
+
+package data
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func FuzzAccountMarshaler_UnmarshalJSON(f *testing.F) {
+	f.Add([]byte(`{"login":"alice","title":"Dr.","first_name":"Alice","middle_name":null,"last_name":"Goodwin"}`))
+	f.Add([]byte(`{"email":{"email":"alice@example.com","is_public":true},"affiliation":null}`))
+	f.Add([]byte(`{"title":1,"email":[],"affiliation":"x"}`))
+	f.Add([]byte(`[{"login":"alice"}]`))
+	f.Add([]byte(`{"login":"\ud800","first_name":"\u0000"}`))
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		marshaler := &AccountMarshaler{}
+		err := json.Unmarshal(content, marshaler)
+		if err != nil {
+			return
+		}
+		if marshaler.Account == nil {
+			t.Fatal("Account expected after decoding")
+		}
+
+		// the decoded fields survive a round trip through the JSON representation
+		marshaler.WithMail, marshaler.WithAffiliation = true, true
+		encoded, err := json.Marshal(marshaler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := &AccountMarshaler{}
+		err = json.Unmarshal(encoded, decoded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Account.FirstName != marshaler.Account.FirstName ||
+			decoded.Account.Title != marshaler.Account.Title ||
+			decoded.Account.Email != marshaler.Account.Email {
+			t.Errorf("Account changed by round trip: %s", encoded)
+		}
+	})
+}
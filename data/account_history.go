@@ -0,0 +1,236 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// AccountChange is a single change of an account field as stored in the account history.
+type AccountChange struct {
+	Id             int
+	AccountUUID    string
+	Field          string
+	OldValue       sql.NullString
+	NewValue       sql.NullString
+	ChangedBy      sql.NullString
+	ChangedByLogin sql.NullString
+	CreatedAt      time.Time
+}
+
+// historyField provides access to a tracked account field.
+type historyField struct {
+	get func(acc *Account) sql.NullString
+	set func(acc *Account, value sql.NullString) error
+}
+
+func stringField(ptr func(acc *Account) *string) historyField {
+	return historyField{
+		get: func(acc *Account) sql.NullString {
+			return sql.NullString{String: *ptr(acc), Valid: true}
+		},
+		set: func(acc *Account, value sql.NullString) error {
+			*ptr(acc) = value.String
+			return nil
+		},
+	}
+}
+
+func nullStringField(ptr func(acc *Account) *sql.NullString) historyField {
+	return historyField{
+		get: func(acc *Account) sql.NullString {
+			return *ptr(acc)
+		},
+		set: func(acc *Account, value sql.NullString) error {
+			*ptr(acc) = value
+			return nil
+		},
+	}
+}
+
+func boolField(ptr func(acc *Account) *bool) historyField {
+	return historyField{
+		get: func(acc *Account) sql.NullString {
+			return sql.NullString{String: strconv.FormatBool(*ptr(acc)), Valid: true}
+		},
+		set: func(acc *Account, value sql.NullString) error {
+			b, err := strconv.ParseBool(value.String)
+			if err != nil {
+				return err
+			}
+			*ptr(acc) = b
+			return nil
+		},
+	}
+}
+
+// historyFields contains all profile fields of an account whose changes are recorded
+// by Account.UpdateBy. The e-mail address is recorded separately by Account.UpdateEmail.
+var historyFields = map[string]historyField{
+	"title":                 nullStringField(func(acc *Account) *sql.NullString { return &acc.Title }),
+	"first_name":            stringField(func(acc *Account) *string { return &acc.FirstName }),
+	"middle_name":           nullStringField(func(acc *Account) *sql.NullString { return &acc.MiddleName }),
+	"last_name":             stringField(func(acc *Account) *string { return &acc.LastName }),
+	"institute":             stringField(func(acc *Account) *string { return &acc.Institute }),
+	"department":            stringField(func(acc *Account) *string { return &acc.Department }),
+	"city":                  stringField(func(acc *Account) *string { return &acc.City }),
+	"country":               stringField(func(acc *Account) *string { return &acc.Country }),
+	"is_email_public":       boolField(func(acc *Account) *bool { return &acc.IsEmailPublic }),
+	"is_affiliation_public": boolField(func(acc *Account) *bool { return &acc.IsAffiliationPublic }),
+}
+
+// ListAccountHistory returns all recorded changes of an account ordered by creation time.
+func ListAccountHistory(accountUUID string) []AccountChange {
+	const q = `SELECT h.*, a.login AS changedByLogin FROM AccountHistory h
+	           LEFT JOIN Accounts a ON h.changedBy = a.uuid
+	           WHERE h.accountUUID = $1
+	           ORDER BY h.createdAt, h.id`
+
+	history := make([]AccountChange, 0)
+	err := database.Select(&history, q, accountUUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return history
+}
+
+// EachAccountChange works like ListAccountHistory but passes the changes one by one to fn
+// while they are read from the database. Iteration stops at the first error returned by fn.
+func EachAccountChange(accountUUID string, fn func(*AccountChange) error) error {
+	const q = `SELECT h.*, a.login AS changedByLogin FROM AccountHistory h
+	           LEFT JOIN Accounts a ON h.changedBy = a.uuid
+	           WHERE h.accountUUID = $1
+	           ORDER BY h.createdAt, h.id`
+
+	rows, err := database.Queryx(q, accountUUID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		change := &AccountChange{}
+		err = rows.StructScan(change)
+		if err != nil {
+			return err
+		}
+		err = fn(change)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetAccountChange returns a recorded account change with a given id.
+// Returns false if no change with a matching id exists.
+func GetAccountChange(id int) (*AccountChange, bool) {
+	const q = `SELECT h.*, a.login AS changedByLogin FROM AccountHistory h
+	           LEFT JOIN Accounts a ON h.changedBy = a.uuid
+	           WHERE h.id = $1`
+
+	change := &AccountChange{}
+	err := database.Get(change, q, id)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return change, err == nil
+}
+
+// recordAccountChange adds a single change to the account history.
+// An empty changedBy is stored as NULL.
+func recordAccountChange(tx *sqlx.Tx, accountUUID, field string, oldValue, newValue sql.NullString, changedBy string) error {
+	const q = `INSERT INTO AccountHistory (accountUUID, field, oldValue, newValue, changedBy, createdAt)
+	           VALUES ($1, $2, $3, $4, $5, now())`
+
+	by := sql.NullString{String: changedBy, Valid: changedBy != ""}
+	_, err := tx.Exec(q, accountUUID, field, oldValue, newValue, by)
+	return err
+}
+
+// recordAccountChanges compares all tracked profile fields of two versions of
+// an account and adds an entry to the account history for each field that differs.
+func recordAccountChanges(tx *sqlx.Tx, old, fresh *Account, changedBy string) error {
+	names := make([]string, 0, len(historyFields))
+	for name := range historyFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := historyFields[name]
+		oldValue, newValue := f.get(old), f.get(fresh)
+		if oldValue == newValue {
+			continue
+		}
+		err := recordAccountChange(tx, fresh.UUID, name, oldValue, newValue, changedBy)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevertChange sets the field of a recorded change back to its old value and stores the account.
+// The revert itself is recorded in the account history as a change originated by changedBy.
+func (acc *Account) RevertChange(change *AccountChange, changedBy string) error {
+	if change.AccountUUID != acc.UUID {
+		return notFoundError("Change does not belong to the account")
+	}
+
+	if change.Field == "email" {
+		return acc.updateEmail(change.OldValue.String, changedBy)
+	}
+
+	f, ok := historyFields[change.Field]
+	if !ok {
+		return conflictError("Field '%s' can not be reverted", change.Field)
+	}
+	err := f.set(acc, change.OldValue)
+	if err != nil {
+		return err
+	}
+
+	return acc.UpdateBy(changedBy)
+}
+
+// MarshalJSON implements Marshaler for AccountChange
+func (change *AccountChange) MarshalJSON() ([]byte, error) {
+	nullable := func(s sql.NullString) *string {
+		if s.Valid {
+			return &s.String
+		}
+		return nil
+	}
+
+	jsonData := &struct {
+		Id        int       `json:"id"`
+		Field     string    `json:"field"`
+		OldValue  *string   `json:"old_value"`
+		NewValue  *string   `json:"new_value"`
+		ChangedBy *string   `json:"changed_by"`
+		CreatedAt time.Time `json:"created_at"`
+	}{
+		Id:        change.Id,
+		Field:     change.Field,
+		OldValue:  nullable(change.OldValue),
+		NewValue:  nullable(change.NewValue),
+		ChangedBy: nullable(change.ChangedByLogin),
+		CreatedAt: change.CreatedAt,
+	}
+	return json.Marshal(jsonData)
+}
@@ -0,0 +1,175 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestListAccountHistory(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	history := ListAccountHistory(uuidAlice)
+	if len(history) != 2 {
+		t.Error("Two changes expected in history")
+	}
+	if history[0].Field != "first_name" {
+		t.Error("First change expected to be on 'first_name'")
+	}
+	if history[1].ChangedByLogin.String != "bob" {
+		t.Error("Second change expected to be changed by 'bob'")
+	}
+
+	history = ListAccountHistory(uuidBob)
+	if len(history) != 0 {
+		t.Error("No changes expected in history")
+	}
+}
+
+func TestGetAccountChange(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	id := ListAccountHistory(uuidAlice)[0].Id
+	change, ok := GetAccountChange(id)
+	if !ok {
+		t.Error("Change does not exist")
+	}
+	if change.OldValue.String != "Alicia" {
+		t.Error("Old value expected to be 'Alicia'")
+	}
+
+	_, ok = GetAccountChange(-1)
+	if ok {
+		t.Error("Change should not exist")
+	}
+}
+
+func TestAccountUpdateBy(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	acc, _ := GetAccountByLogin("alice")
+	acc.FirstName = "Alix"
+	acc.MiddleName = sql.NullString{String: "Mary", Valid: true}
+	err := acc.UpdateBy(uuidBob)
+	if err != nil {
+		t.Error(err)
+	}
+
+	history := ListAccountHistory(uuidAlice)
+	if len(history) != 4 {
+		t.Errorf("Four changes expected in history, but was %d", len(history))
+	}
+	for _, change := range history[2:] {
+		if change.ChangedBy.String != uuidBob {
+			t.Errorf("Change on '%s' expected to be changed by bob", change.Field)
+		}
+		if change.Field == "first_name" && (change.OldValue.String != "Alice" || change.NewValue.String != "Alix") {
+			t.Error("Change on 'first_name' was not properly recorded")
+		}
+		if change.Field == "middle_name" && (change.OldValue.Valid || change.NewValue.String != "Mary") {
+			t.Error("Change on 'middle_name' was not properly recorded")
+		}
+	}
+
+	// update without changes
+	err = acc.Update()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(ListAccountHistory(uuidAlice)) != 4 {
+		t.Error("Update without changes should not be recorded")
+	}
+}
+
+func TestAccountUpdateEmailHistory(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	acc, _ := GetAccountByLogin("alice")
+	err := acc.UpdateEmail("alix@example.com")
+	if err != nil {
+		t.Error(err)
+	}
+
+	history := ListAccountHistory(uuidAlice)
+	last := history[len(history)-1]
+	if last.Field != "email" || last.OldValue.String != "aclic@foo.com" || last.NewValue.String != "alix@example.com" {
+		t.Error("Change on 'email' was not properly recorded")
+	}
+	if last.ChangedBy.String != uuidAlice {
+		t.Error("Change on 'email' expected to be changed by alice")
+	}
+}
+
+func TestAccountRevertChange(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	acc, _ := GetAccountByLogin("alice")
+	history := ListAccountHistory(uuidAlice)
+
+	err := acc.RevertChange(&history[0], uuidBob)
+	if err != nil {
+		t.Error(err)
+	}
+	check, _ := GetAccountByLogin("alice")
+	if check.FirstName != "Alicia" {
+		t.Error("First name expected to be 'Alicia'")
+	}
+
+	err = acc.RevertChange(&history[1], uuidBob)
+	if err != nil {
+		t.Error(err)
+	}
+	check, _ = GetAccountByLogin("alice")
+	if check.Title.Valid {
+		t.Error("Title expected to be null")
+	}
+
+	if len(ListAccountHistory(uuidAlice)) != 4 {
+		t.Error("Reverts expected to be recorded in history")
+	}
+
+	bob, _ := GetAccountByLogin("bob")
+	err = bob.RevertChange(&history[0], uuidBob)
+	if err == nil {
+		t.Error("Revert of a change of another account should fail")
+	}
+
+	err = acc.RevertChange(&AccountChange{AccountUUID: uuidAlice, Field: "login"}, uuidBob)
+	if err == nil {
+		t.Error("Revert of an untracked field should fail")
+	}
+}
+
+func TestAccountChangeMarshalJSON(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	history := ListAccountHistory(uuidAlice)
+	bytes, err := json.Marshal(&history[1])
+	if err != nil {
+		t.Error(err)
+	}
+	str := string(bytes)
+	if !strings.Contains(str, `"old_value":null`) {
+		t.Error("Old value expected to be null")
+	}
+	if !strings.Contains(str, `"changed_by":"bob"`) {
+		t.Error("Changed by expected to be 'bob'")
+	}
+}
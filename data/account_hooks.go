@@ -0,0 +1,134 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// AccountHook is notified about the lifecycle of accounts, e.g. to provision resources of
+// an account on other systems. Deployments implement this interface and register it with
+// RegisterAccountHook in an init function of a file added at build time. Hooks are called
+// after the change was stored, errors are logged but do not affect the change.
+type AccountHook interface {
+	OnAccountCreated(acc *Account) error
+	OnAccountDeleted(acc *Account) error
+	OnPasswordChanged(acc *Account) error
+}
+
+var accountHooks = struct {
+	sync.Mutex
+	hooks []AccountHook
+}{}
+
+// RegisterAccountHook adds a hook which is called on all subsequent account lifecycle events.
+func RegisterAccountHook(hook AccountHook) {
+	accountHooks.Lock()
+	defer accountHooks.Unlock()
+
+	accountHooks.hooks = append(accountHooks.hooks, hook)
+}
+
+// runAccountHooks calls all registered hooks with an account. Panics of hooks are
+// logged as errors too.
+func runAccountHooks(event string, acc *Account, call func(hook AccountHook, acc *Account) error) {
+	accountHooks.Lock()
+	hooks := make([]AccountHook, len(accountHooks.hooks))
+	copy(hooks, accountHooks.hooks)
+	accountHooks.Unlock()
+
+	for _, hook := range hooks {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					conf.GetLogEnv().Err.Errorf("Account hook for '%s' of %s panicked: %v", event, acc.Login, r)
+				}
+			}()
+			err := call(hook, acc)
+			if err != nil {
+				conf.GetLogEnv().Err.Errorf("Account hook for '%s' of %s failed: %s", event, acc.Login, err.Error())
+			}
+		}()
+	}
+}
+
+func accountCreated(acc *Account) {
+	runAccountHooks("account-created", acc, AccountHook.OnAccountCreated)
+}
+
+func accountDeleted(acc *Account) {
+	runAccountHooks("account-deleted", acc, AccountHook.OnAccountDeleted)
+}
+
+func passwordChanged(acc *Account) {
+	runAccountHooks("password-changed", acc, AccountHook.OnPasswordChanged)
+}
+
+// WebhookAccountHook is an account hook which posts events as JSON to a URL, such that
+// provisioning can be done by external services. Events are sent in the background.
+type WebhookAccountHook struct {
+	URL    string
+	Secret string
+	client *http.Client
+}
+
+// NewWebhookAccountHook creates a hook for the webhook and secret of the server configuration.
+func NewWebhookAccountHook(config *conf.AccountHooks) *WebhookAccountHook {
+	return &WebhookAccountHook{URL: config.Webhook, Secret: config.Secret, client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// accountEvent is the JSON body sent by a WebhookAccountHook.
+type accountEvent struct {
+	Event string    `json:"event"`
+	UUID  string    `json:"uuid"`
+	Login string    `json:"login"`
+	Email string    `json:"email"`
+	Time  time.Time `json:"time"`
+}
+
+// OnAccountCreated implements AccountHook.
+func (h *WebhookAccountHook) OnAccountCreated(acc *Account) error {
+	return h.post("account-created", acc)
+}
+
+// OnAccountDeleted implements AccountHook.
+func (h *WebhookAccountHook) OnAccountDeleted(acc *Account) error {
+	return h.post("account-deleted", acc)
+}
+
+// OnPasswordChanged implements AccountHook.
+func (h *WebhookAccountHook) OnPasswordChanged(acc *Account) error {
+	return h.post("password-changed", acc)
+}
+
+func (h *WebhookAccountHook) post(event string, acc *Account) error {
+	body, err := json.Marshal(&accountEvent{event, acc.UUID, acc.Login, acc.Email, time.Now()})
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		err := h.send(body)
+		if err != nil {
+			conf.GetLogEnv().Err.Errorf("Error sending '%s' of %s to account webhook: %s", event, acc.Login, err.Error())
+		}
+	}()
+	return nil
+}
+
+// send posts a JSON body to the webhook, signed with the secret if configured (see util.PostWebhook).
+func (h *WebhookAccountHook) send(body []byte) error {
+	return util.PostWebhook(h.client, h.URL, h.Secret, body)
+}
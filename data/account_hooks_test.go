@@ -0,0 +1,97 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+type recordingHook struct {
+	events []string
+}
+
+func (h *recordingHook) OnAccountCreated(acc *Account) error {
+	h.events = append(h.events, "created:"+acc.Login)
+	return nil
+}
+
+func (h *recordingHook) OnAccountDeleted(acc *Account) error {
+	h.events = append(h.events, "deleted:"+acc.Login)
+	return nil
+}
+
+func (h *recordingHook) OnPasswordChanged(acc *Account) error {
+	h.events = append(h.events, "password:"+acc.Login)
+	panic("hooks must not break account changes")
+}
+
+func TestAccountHooks(t *testing.T) {
+	InitTestDb(t)
+
+	hook := &recordingHook{}
+	RegisterAccountHook(hook)
+	defer func() { accountHooks.hooks = nil }()
+
+	fresh := &Account{Login: "theo", Email: "theo@example.com", IsApprovalPending: true}
+	fresh.SetPassword("testtest")
+	if err := fresh.Create(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.UpdatePassword("supersecret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.Reject(false); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"created:theo", "password:theo", "deleted:theo"}
+	if len(hook.events) != len(expected) {
+		t.Fatalf("Events %v expected but was %v", expected, hook.events)
+	}
+	for i := range expected {
+		if hook.events[i] != expected[i] {
+			t.Errorf("Event '%s' expected but was '%s'", expected[i], hook.events[i])
+		}
+	}
+}
+
+func TestWebhookAccountHook_send(t *testing.T) {
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get("X-Gin-Signature")
+	}))
+	defer server.Close()
+
+	hook := NewWebhookAccountHook(&conf.AccountHooks{Webhook: server.URL, Secret: "secret"})
+	err := hook.send([]byte(`{"event":"account-created"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	event := &accountEvent{}
+	if err = json.Unmarshal(body, event); err != nil || event.Event != "account-created" {
+		t.Errorf("Unexpected body: %s", string(body))
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	if signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("Invalid signature: %s", signature)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import "github.com/G-Node/gin-auth/util"
+
+// AccountListing is an account together with the names of the groups it belongs to and its
+// labels. Listings are loaded for all accounts at once, which avoids one query per account
+// when administrators list accounts.
+type AccountListing struct {
+	Account
+	Groups util.StringSet
+	Labels util.StringSet
+}
+
+// ListAccountListings returns the listings of all active accounts ordered by login. If search is
+// not empty only accounts whose name or login contains the search string are returned, if label
+// is not empty only accounts with this label.
+func ListAccountListings(search, label string) []AccountListing {
+	listings := make([]AccountListing, 0)
+	err := EachAccountListing(search, label, func(listing *AccountListing) error {
+		listings = append(listings, *listing)
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return listings
+}
+
+// EachAccountListing works like ListAccountListings but passes the listings one by one to fn
+// while they are read from the database, such that large results are not kept in memory.
+// Iteration stops at the first error returned by fn.
+func EachAccountListing(search, label string, fn func(*AccountListing) error) error {
+	filter := &AccountFilter{Search: search, Label: label}
+	return filter.EachListing(fn)
+}
+
+// EachListing passes the listings of all active accounts matching the filter, ordered by login,
+// one by one to fn. Iteration stops at the first error returned by fn.
+func (filter *AccountFilter) EachListing(fn func(*AccountListing) error) error {
+	const q = `SELECT a.*, COALESCE(gm.names, '{}') AS groups, COALESCE(n.labels, '{}') AS labels
+	           FROM ActiveAccounts a
+	           LEFT JOIN (SELECT m.accountUUID, array_agg(g.name ORDER BY g.name) AS names
+	                      FROM GroupMembers m JOIN Groups g ON g.uuid = m.groupUUID
+	                      GROUP BY m.accountUUID) gm ON gm.accountUUID = a.uuid
+	           LEFT JOIN AccountNotes n ON n.accountUUID = a.uuid
+	           WHERE ` + qAccountFilterCondition + `
+	           ORDER BY a.login`
+
+	rows, err := database.Queryx(q, filter.params()...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		listing := &AccountListing{}
+		err = rows.StructScan(listing)
+		if err != nil {
+			return err
+		}
+		err = fn(listing)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestListAccountListings(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	listings := ListAccountListings("", "")
+	if len(listings) != 3 {
+		t.Fatalf("Three listings expected but found %d", len(listings))
+	}
+	alice := listings[0]
+	if alice.Login != "alice" || !alice.IsEmailVerified {
+		t.Error("Listing of alice expected first with verified e-mail")
+	}
+	if alice.Groups.Len() != 1 || !alice.Groups.Contains("lmu-neuro") {
+		t.Errorf("Alice expected to be member of 'lmu-neuro' but was of %v", alice.Groups.Strings())
+	}
+	if !alice.Labels.Contains("verified researcher") {
+		t.Error("Alice expected to have label 'verified researcher'")
+	}
+	bob := listings[1]
+	if bob.Groups.Len() != 1 || bob.Labels.Len() != 0 {
+		t.Error("Bob expected to be member of one group without labels")
+	}
+
+	listings = ListAccountListings("ali", "")
+	if len(listings) != 1 || listings[0].Login != "alice" {
+		t.Error("Only alice expected to match the search")
+	}
+	listings = ListAccountListings("", "spam-suspect")
+	if len(listings) != 1 || listings[0].Login != "john" || !listings[0].Groups.Contains("lmu-neuro-ephys") {
+		t.Error("Only john expected to have label 'spam-suspect'")
+	}
+	listings = ListAccountListings("ali", "spam-suspect")
+	if len(listings) != 0 {
+		t.Error("No listing expected to match search and label")
+	}
+}
+
+func TestEachAccountListing(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	stop := errors.New("stop")
+	logins := make([]string, 0)
+	err := EachAccountListing("", "", func(listing *AccountListing) error {
+		logins = append(logins, listing.Login)
+		if len(logins) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("Error of the callback expected but was '%v'", err)
+	}
+	if len(logins) != 2 || logins[0] != "alice" || logins[1] != "bob" {
+		t.Errorf("Iteration expected to stop after bob but was %v", logins)
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Maximum number of labels per account
+const maxAccountLabels = 20
+
+// Labels consist of lower case letters, digits, spaces and hyphens, e.g. "verified researcher"
+var accountLabelRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9 -]{0,63}$`)
+
+// AccountNotes contains free-text notes and labels on an account,
+// which are only visible to administrators.
+type AccountNotes struct {
+	AccountUUID string
+	Notes       string
+	Labels      util.StringSet
+	UpdatedBy   sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// GetAccountNotes returns the notes on the account with the given UUID.
+// Returns false if there are no notes on the account.
+func GetAccountNotes(accountUUID string) (*AccountNotes, bool) {
+	const q = `SELECT * FROM AccountNotes WHERE accountUUID=$1`
+
+	notes := &AccountNotes{}
+	err := database.Get(notes, q, accountUUID)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return notes, err == nil
+}
+
+// ListAccountsByLabel returns all active accounts with the given label ordered by login.
+func ListAccountsByLabel(label string) []Account {
+	const q = `SELECT a.* FROM ActiveAccounts a JOIN AccountNotes n ON n.accountUUID = a.uuid
+	           WHERE $1 = ANY(n.labels)
+	           ORDER BY a.login`
+
+	accounts := make([]Account, 0)
+	err := database.Select(&accounts, q, label)
+	if err != nil {
+		panic(err)
+	}
+
+	return accounts
+}
+
+// Validate checks the number and format of all labels.
+func (notes *AccountNotes) Validate() *util.ValidationError {
+	if notes.Labels.Len() > maxAccountLabels {
+		return &util.ValidationError{
+			Message:     "Invalid labels",
+			FieldErrors: map[string]string{"labels": fmt.Sprintf("Please use at most %d labels", maxAccountLabels)}}
+	}
+	for _, label := range notes.Labels.Strings() {
+		if !accountLabelRegex.MatchString(label) {
+			return &util.ValidationError{
+				Message:     "Invalid labels",
+				FieldErrors: map[string]string{"labels": fmt.Sprintf("Invalid label '%s'", label)}}
+		}
+	}
+	return nil
+}
+
+// SaveBy stores notes and labels of an account. Existing notes are replaced.
+// The given UUID identifies the account of the administrator who changed the notes.
+func (notes *AccountNotes) SaveBy(changedBy string) error {
+	if err := notes.Validate(); err != nil {
+		return err
+	}
+	if notes.Labels == nil {
+		notes.Labels = util.NewStringSet()
+	}
+
+	const q = `INSERT INTO AccountNotes (accountUUID, notes, labels, updatedBy, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, now(), now())
+	           ON CONFLICT (accountUUID) DO UPDATE
+	           SET (notes, labels, updatedBy, updatedAt) = (EXCLUDED.notes, EXCLUDED.labels, EXCLUDED.updatedBy, now())
+	           RETURNING *`
+
+	notes.UpdatedBy = sql.NullString{String: changedBy, Valid: changedBy != ""}
+	return database.Get(notes, q, notes.AccountUUID, notes.Notes, notes.Labels, notes.UpdatedBy)
+}
+
+// AccountNotesMarshaler converts account notes into JSON and parses
+// updatable fields (notes and labels) from JSON.
+type AccountNotesMarshaler struct {
+	Notes     *AccountNotes
+	Account   *Account
+	UpdatedBy *Account
+}
+
+// MarshalJSON implements Marshaler for AccountNotesMarshaler.
+func (nm *AccountNotesMarshaler) MarshalJSON() ([]byte, error) {
+	jsonData := &struct {
+		URL        string    `json:"url"`
+		Login      string    `json:"login"`
+		AccountURL string    `json:"account_url"`
+		Notes      string    `json:"notes"`
+		Labels     []string  `json:"labels"`
+		UpdatedBy  *string   `json:"updated_by,omitempty"`
+		CreatedAt  time.Time `json:"created_at"`
+		UpdatedAt  time.Time `json:"updated_at"`
+	}{
+		URL:        conf.MakeUrl("/api/v1/accounts/%s/notes", nm.Account.Login),
+		Login:      nm.Account.Login,
+		AccountURL: conf.MakeUrl("/api/v1/accounts/%s", nm.Account.Login),
+		Notes:      nm.Notes.Notes,
+		Labels:     nm.Notes.Labels.Strings(),
+		CreatedAt:  nm.Notes.CreatedAt,
+		UpdatedAt:  nm.Notes.UpdatedAt,
+	}
+	if nm.UpdatedBy != nil {
+		jsonData.UpdatedBy = &nm.UpdatedBy.Login
+	}
+	return json.Marshal(jsonData)
+}
+
+// UnmarshalJSON implements Unmarshaler for AccountNotesMarshaler.
+// Only parses updatable fields: Notes and Labels
+func (nm *AccountNotesMarshaler) UnmarshalJSON(bytes []byte) error {
+	jsonData := &struct {
+		Notes  string   `json:"notes"`
+		Labels []string `json:"labels"`
+	}{}
+	err := json.Unmarshal(bytes, jsonData)
+	if err != nil {
+		return err
+	}
+
+	if nm.Notes == nil {
+		nm.Notes = &AccountNotes{}
+	}
+	nm.Notes.Notes = jsonData.Notes
+	nm.Notes.Labels = util.NewStringSet(jsonData.Labels...)
+
+	return nil
+}
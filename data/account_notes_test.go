@@ -0,0 +1,85 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestGetAccountNotes(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	notes, ok := GetAccountNotes("bf431618-f696-4dca-a95d-882618ce4ef9")
+	if !ok {
+		t.Fatal("Notes on alice expected to exist")
+	}
+	if !notes.Labels.Contains("verified researcher") {
+		t.Error("Label 'verified researcher' expected")
+	}
+	if notes.UpdatedBy.String != "51f5ac36-d332-4889-8023-6e033fcd8e17" {
+		t.Error("Notes expected to be updated by bob")
+	}
+
+	_, ok = GetAccountNotes("51f5ac36-d332-4889-8023-6e033fcd8e17")
+	if ok {
+		t.Error("Notes on bob should not exist")
+	}
+}
+
+func TestListAccountsByLabel(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	accounts := ListAccountsByLabel("spam-suspect")
+	if len(accounts) != 1 || accounts[0].Login != "john" {
+		t.Error("Only account 'john' expected")
+	}
+
+	accounts = ListAccountsByLabel("doesnotexist")
+	if len(accounts) != 0 {
+		t.Error("No accounts expected")
+	}
+}
+
+func TestAccountNotesSaveBy(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	const bobUUID = "51f5ac36-d332-4889-8023-6e033fcd8e17"
+
+	notes := &AccountNotes{AccountUUID: bobUUID, Labels: util.NewStringSet("Not Valid!")}
+	err := notes.SaveBy(bobUUID)
+	if err == nil {
+		t.Error("Invalid label should not be saved")
+	}
+
+	notes = &AccountNotes{AccountUUID: bobUUID, Notes: "Administrator", Labels: util.NewStringSet("staff")}
+	err = notes.SaveBy(bobUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, ok := GetAccountNotes(bobUUID)
+	if !ok || check.Notes != "Administrator" || !check.Labels.Contains("staff") {
+		t.Error("Notes on bob expected to be saved")
+	}
+
+	notes.Notes = "Former administrator"
+	notes.Labels = util.NewStringSet()
+	err = notes.SaveBy(bobUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, _ = GetAccountNotes(bobUUID)
+	if check.Notes != "Former administrator" || check.Labels.Len() != 0 {
+		t.Error("Notes on bob expected to be replaced")
+	}
+}
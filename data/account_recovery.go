@@ -0,0 +1,145 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Changes of an account which can be reverted by its owner
+const (
+	RecoveryEmail    = "email"
+	RecoveryPassword = "password"
+)
+
+// AccountRecovery allows the owner of an account to revert a change of the e-mail address or
+// password, which was made by somebody else. The code is sent to Email, which for changes
+// of the e-mail address is the address before the change.
+type AccountRecovery struct {
+	Code        string
+	AccountUUID string
+	Field       string
+	Email       string
+	Expires     time.Time
+	CreatedAt   time.Time
+}
+
+// CreateRecovery stores a recovery for a change of the e-mail address or the password of the
+// account and returns the code, which is sent to the given address. Only the HMAC of the code
+// is stored.
+func (acc *Account) CreateRecovery(field, email string) (string, error) {
+	const q = `INSERT INTO AccountRecoveries (code, accountUUID, field, email, expires, createdAt)
+	           VALUES ($1, $2, $3, $4, $5, now())`
+
+	lifeTime := conf.GetAccountCodes().RecoveryLifeTime
+	code, hash := newAccountCode(codeAccountRecovery, lifeTime)
+	_, err := database.Exec(q, hash.String, acc.UUID, field, email, util.Now().Add(lifeTime))
+	return code, err
+}
+
+// RecoverAccount uses the recovery with the given code, reverts its change and locks the account until
+// its password is reset: a changed e-mail address is set back to the address of the recovery, all
+// sessions, tokens and recoveries of the account are removed and a password reset code is returned,
+// which must be sent to the address of the recovery. Using the code, restoring the address and locking
+// the account happen in one transaction, thus the code stays valid if any step fails.
+// Returns an error of kind ErrNotFound or ErrExpired if the recovery can not be used, of kind ErrNotFound
+// if the account does not exist or is disabled and of kind ErrConflict if the previous address belongs
+// to another account meanwhile.
+func RecoverAccount(code string) (*Account, *AccountRecovery, string, error) {
+	const qRecovery = `SELECT * FROM AccountRecoveries WHERE code=$1 FOR UPDATE`
+	const qAccount = `SELECT * FROM Accounts WHERE uuid=$1 AND NOT isDisabled FOR UPDATE`
+	const qEmailUsed = `SELECT EXISTS (SELECT 1 FROM Accounts WHERE lower(email) = lower($1) AND uuid <> $2)`
+	const qEmail = `UPDATE Accounts SET (email, isEmailVerified, emailVerificationCode, isEmailBouncing) =
+	                ($1, TRUE, NULL, EXISTS (SELECT 1 FROM EmailBounces WHERE email = lower($1) AND isSuppressed))
+	                WHERE uuid=$2 RETURNING *`
+	const qLock = `UPDATE Accounts SET (resetPWCode, updatedAt) = ($1, now()) WHERE uuid=$2 RETURNING *`
+
+	hash, ok := hashAccountCode(codeAccountRecovery, code)
+	if !ok {
+		return nil, nil, "", notFoundError("Recovery code is invalid or expired")
+	}
+
+	tx := database.MustBegin()
+	recovery := &AccountRecovery{}
+	err := tx.Get(recovery, qRecovery, hash.String)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil, "", notFoundError("Recovery code is invalid or expired")
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, "", err
+	}
+	if !recovery.Expires.After(expiryTime()) {
+		tx.Rollback()
+		return nil, nil, "", expiredError("Recovery code is expired")
+	}
+
+	account := &Account{}
+	err = tx.Get(account, qAccount, recovery.AccountUUID)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return nil, nil, "", notFoundError("Account does not exist")
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, "", err
+	}
+
+	if recovery.Field == RecoveryEmail && account.Email != recovery.Email {
+		var used bool
+		err = tx.Get(&used, qEmailUsed, recovery.Email, account.UUID)
+		if err == nil && used {
+			tx.Rollback()
+			return nil, nil, "", conflictError("The previous e-mail address is used by another account")
+		}
+		oldEmail := sql.NullString{String: account.Email, Valid: true}
+		if err == nil {
+			err = tx.Get(account, qEmail, recovery.Email, account.UUID)
+		}
+		if err == nil {
+			err = recordAccountChange(tx, account.UUID, "email", oldEmail,
+				sql.NullString{String: recovery.Email, Valid: true}, account.UUID)
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, nil, "", err
+		}
+	}
+
+	resetCode, resetHash := newAccountCode(codeResetPassword, conf.GetAccountCodes().ResetLifeTime)
+	err = tx.Get(account, qLock, resetHash, account.UUID)
+	if err != nil {
+		tx.Rollback()
+		return nil, nil, "", err
+	}
+	// removing the recoveries of the account also uses the given code
+	for _, stmt := range []string{
+		`DELETE FROM AccessTokens WHERE accountUUID=$1`,
+		`DELETE FROM RefreshTokens WHERE accountUUID=$1`,
+		`DELETE FROM AccountRecoveries WHERE accountUUID=$1`,
+	} {
+		_, err = tx.Exec(stmt, account.UUID)
+		if err != nil {
+			tx.Rollback()
+			return nil, nil, "", err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, "", err
+	}
+
+	err = sessionStore().DeleteAccount(account.UUID)
+	return account, recovery, resetCode, err
+}
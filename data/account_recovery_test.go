@@ -0,0 +1,129 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestAccountRecovery(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	account, _ := GetAccount(uuidBob)
+	err := account.UpdateEmail("mallory@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := account.CreateRecovery(RecoveryEmail, "bob@foo.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = RecoverAccount("wrongcode.4102444800")
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Unknown recovery code should not be usable: %v", err)
+	}
+
+	account, recovery, resetCode, err := RecoverAccount(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recovery.AccountUUID != uuidBob || recovery.Field != RecoveryEmail || recovery.Email != "bob@foo.com" {
+		t.Errorf("Unexpected recovery: %+v", recovery)
+	}
+	if account.Email != "bob@foo.com" || !account.IsEmailVerified {
+		t.Errorf("Previous e-mail address expected to be restored but was '%s'", account.Email)
+	}
+	if _, ok := GetAccount(uuidBob); ok {
+		t.Error("Account expected to be locked until the password is reset")
+	}
+	if _, ok := GetAccountByResetPWCode(resetCode); !ok {
+		t.Error("Reset code expected to be valid")
+	}
+	if _, ok := GetAccessToken("KDEW57D4"); ok {
+		t.Error("Access tokens of the account expected to be revoked")
+	}
+	if len(ListAccountSessions(uuidBob)) != 0 {
+		t.Error("Sessions of the account expected to be removed")
+	}
+
+	_, _, _, err = RecoverAccount(code)
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Recovery code should only be usable once: %v", err)
+	}
+}
+
+func TestRecoverAccountConflict(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	// the previous address of bob was taken by alice meanwhile
+	bob, _ := GetAccount(uuidBob)
+	previous := bob.Email
+	err := bob.UpdateEmail("mallory@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := bob.CreateRecovery(RecoveryEmail, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, _ := GetAccount(uuidAlice)
+	err = alice.UpdateEmail(previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, _, err = RecoverAccount(code)
+	if KindOf(err) != ErrConflict {
+		t.Errorf("Error of kind ErrConflict expected but was %v", err)
+	}
+	check, ok := GetAccount(uuidBob)
+	if !ok || check.Email != "mallory@example.com" || check.ResetPWCode.Valid {
+		t.Errorf("Account expected to stay unchanged: %+v", check)
+	}
+
+	// the code stays valid once the conflict is resolved
+	err = alice.UpdateEmail("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err = RecoverAccount(code); err != nil {
+		t.Errorf("Recovery code expected to stay valid after a failed recovery: %v", err)
+	}
+}
+
+func TestRecoverAccountPassword(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	account, _ := GetAccount(uuidAlice)
+	code, err := account.CreateRecovery(RecoveryPassword, account.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := account.CreateRecovery(RecoveryPassword, account.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account, _, _, err = RecoverAccount(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.Email != "aclic@foo.com" || !account.ResetPWCode.Valid {
+		t.Errorf("Account expected to be locked with unchanged e-mail address: %+v", account)
+	}
+	if _, _, _, err = RecoverAccount(other); KindOf(err) != ErrNotFound {
+		t.Errorf("Further recoveries of the account expected to be removed: %v", err)
+	}
+}
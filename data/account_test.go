@@ -10,9 +10,11 @@ package data
 
 import (
 	"database/sql"
+	"encoding/json"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/G-Node/gin-auth/util"
 )
@@ -88,6 +90,28 @@ func TestGetAccountByLogin(t *testing.T) {
 	}
 }
 
+func TestGetAccountByID(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	for _, id := range []string{"bob", uuidBob, strings.ToUpper(uuidBob)} {
+		acc, ok := GetAccountByID(id)
+		if !ok {
+			t.Errorf("Account '%s' does not exist", id)
+			continue
+		}
+		if acc.Login != "bob" {
+			t.Errorf("Login of account '%s' was expected to be 'bob'", id)
+		}
+	}
+
+	for _, id := range []string{"doesNotExist", "inact_log1", "10000000-0000-0000-0000-000000000000"} {
+		if _, ok := GetAccountByID(id); ok {
+			t.Errorf("Account '%s' should not exist", id)
+		}
+	}
+}
+
 func TestGetAccountByCredential(t *testing.T) {
 	defer util.FailOnPanic(t)
 	InitTestDb(t)
@@ -132,8 +156,8 @@ func TestGetAccountByActivationCode(t *testing.T) {
 	InitTestDb(t)
 
 	const enabledUUID = "test0001-1234-6789-1234-678901234567"
-	const enabledCode = "ac_a"
-	const disabledCode = "ac_b"
+	const enabledCode = "ac_a.4102444800"
+	const disabledCode = "ac_b.4102444800"
 
 	acc, ok := GetAccountByActivationCode(enabledCode)
 	if !ok {
@@ -159,13 +183,36 @@ func TestGetAccountByActivationCode(t *testing.T) {
 	}
 }
 
+func TestGetAccountByEmailVerificationCode(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	const code = "EV_JOHN.4102444800"
+
+	acc, ok := GetAccountByEmailVerificationCode(code)
+	if !ok {
+		t.Error("Account does not exist")
+	}
+	if acc.Login != "john" {
+		t.Errorf("Login was expected to be 'john' but was '%s'", acc.Login)
+	}
+	if acc.IsEmailVerified {
+		t.Error("E-mail address should not be verified")
+	}
+
+	_, ok = GetAccountByEmailVerificationCode("iDoNotExist")
+	if ok {
+		t.Error("Account should not exist")
+	}
+}
+
 func TestGetAccountByResetPWCode(t *testing.T) {
 	defer util.FailOnPanic(t)
 	InitTestDb(t)
 
 	const enabledUUID = "test0002-1234-6789-1234-678901234567"
-	const enabledCode = "rc_a"
-	const disabledCode = "rc_c"
+	const enabledCode = "rc_a.4102444800"
+	const disabledCode = "rc_c.4102444800"
 
 	acc, ok := GetAccountByResetPWCode(enabledCode)
 	if !ok {
@@ -222,50 +269,85 @@ func TestSetPasswordReset(t *testing.T) {
 	const enabledEmail = "email1@example.com"
 
 	// Test empty credential
-	_, ok := SetPasswordReset("")
+	_, _, ok := SetPasswordReset("")
 	if ok {
 		t.Error("Account should not have been updated using an empty credential")
 	}
 
 	// Test non existing credential
-	_, ok = SetPasswordReset("iDoNotExist")
+	_, _, ok = SetPasswordReset("iDoNotExist")
 	if ok {
 		t.Error("Account should not have been updated using non existing credential")
 	}
 
 	// Test valid login of disabled account
-	_, ok = SetPasswordReset(disabledLogin)
+	_, _, ok = SetPasswordReset(disabledLogin)
 	if ok {
 		t.Error("Account should not have been updated using disabled account login")
 	}
 
 	// Test valid email of disabled account
-	_, ok = SetPasswordReset(disabledEmail)
+	_, _, ok = SetPasswordReset(disabledEmail)
 	if ok {
 		t.Error("Account should not have been updated using disabled account email")
 	}
 
 	// Test valid update using login
-	account, ok := SetPasswordReset(enabledLogin)
+	account, old, ok := SetPasswordReset(enabledLogin)
 	if !ok {
 		t.Errorf("Account should have been updated using valid account login '%s'", enabledLogin)
 	}
-	if account.ResetPWCode.String == "" {
-		t.Errorf("Account should have reset pw code, but was empty (using login '%s')", enabledLogin)
+	if account.ResetPWCode.String == "" || account.ResetPWCode.String == old {
+		t.Errorf("Account should have hashed reset pw code (using login '%s')", enabledLogin)
+	}
+	if _, ok = GetAccountByResetPWCode(old); !ok {
+		t.Error("Reset pw code expected to be valid")
 	}
 
 	// Test valid update using email
-	old := account.ResetPWCode.String
-	account, ok = SetPasswordReset(enabledEmail)
+	account, code, ok := SetPasswordReset(enabledEmail)
 	if !ok {
 		t.Errorf("Account should have been updated using valid account email '%s'", enabledEmail)
 	}
-	if account.ResetPWCode.String == "" {
-		t.Errorf("Account should have reset pw code, but was empty (using email '%s')", enabledEmail)
-	}
-	if account.ResetPWCode.String == old {
+	if code == "" || code == old {
 		t.Errorf("Account should have new reset pw code, but was unchanged (using email '%s')", enabledEmail)
 	}
+	if _, ok = GetAccountByResetPWCode(old); ok {
+		t.Error("Previous reset pw code should be invalid")
+	}
+}
+
+func TestAccountCode(t *testing.T) {
+	code, hash := newAccountCode(codeResetPassword, time.Hour)
+	if !checkAccountCode(codeResetPassword, code, hash) {
+		t.Error("Code expected to be valid")
+	}
+	if checkAccountCode(codeActivation, code, hash) {
+		t.Error("Code should only be valid for its purpose")
+	}
+
+	// the expiration time is part of the code
+	i := strings.LastIndex(code, ".")
+	if checkAccountCode(codeResetPassword, code[:i]+".4102444800", hash) {
+		t.Error("Code with altered expiration time should be invalid")
+	}
+	code, hash = newAccountCode(codeResetPassword, -time.Minute)
+	if checkAccountCode(codeResetPassword, code, hash) {
+		t.Error("Expired code should be invalid")
+	}
+
+	// expiry with the leeway for clock skew
+	now := time.Now()
+	defer util.SetClock(util.SetClock(util.FixedClock(now)))
+	code, hash = newAccountCode(codeResetPassword, time.Hour)
+	util.SetClock(util.FixedClock(now.Add(time.Hour + 2*time.Second)))
+	if !checkAccountCode(codeResetPassword, code, hash) {
+		t.Error("Code expected to be valid within the leeway")
+	}
+	util.SetClock(util.FixedClock(now.Add(time.Hour + time.Minute)))
+	if checkAccountCode(codeResetPassword, code, hash) {
+		t.Error("Code expected to be expired")
+	}
 }
 
 func TestAccount_SetPassword(t *testing.T) {
@@ -381,6 +463,12 @@ func TestAccount_UpdateEmail(t *testing.T) {
 	if acc.Email != valid {
 		t.Errorf("Expected e-mail address to be '%s', but was '%s'", valid, acc.Email)
 	}
+	if acc.IsEmailVerified {
+		t.Error("Changed e-mail address should not be verified")
+	}
+	if !acc.EmailVerificationCode.Valid {
+		t.Error("E-mail verification code should be set")
+	}
 }
 
 func TestAccount_Create(t *testing.T) {
@@ -419,6 +507,33 @@ func TestAccount_SSHKeys(t *testing.T) {
 	}
 }
 
+func TestAccount_UpdateLocale(t *testing.T) {
+	InitTestDb(t)
+
+	acc, ok := GetAccount(uuidAlice)
+	if !ok {
+		t.Fatal("Account does not exist")
+	}
+	if acc.Timezone != util.DefaultTimezone || acc.Locale != util.DefaultLocale {
+		t.Errorf("Default timezone and locale expected but were '%s' and '%s'", acc.Timezone, acc.Locale)
+	}
+
+	err := acc.UpdateLocale("Mars/Olympus", "xx")
+	valErr, ok := err.(*util.ValidationError)
+	if !ok || valErr.FieldErrors["timezone"] == "" || valErr.FieldErrors["locale"] == "" {
+		t.Errorf("Validation error expected but was: %v", err)
+	}
+
+	err = acc.UpdateLocale("Europe/Berlin", "de")
+	if err != nil {
+		t.Fatal(err)
+	}
+	acc, _ = GetAccount(uuidAlice)
+	if acc.Timezone != "Europe/Berlin" || acc.Locale != "de" || acc.TimeFormat().Locale != "de" {
+		t.Errorf("Unexpected timezone and locale '%s' and '%s'", acc.Timezone, acc.Locale)
+	}
+}
+
 func TestAccount_Update(t *testing.T) {
 	InitTestDb(t)
 
@@ -428,7 +543,7 @@ func TestAccount_Update(t *testing.T) {
 	newFirstName := "I am actually not Alice"
 	newMiddleName := "and my last name is"
 	newLastName := "Badchild"
-	newResetPWCode := "reset password code"
+	newResetPWCode := "reset password code.4102444800"
 	newInstitute := "institute"
 	newDepartment := "department"
 	newCity := "Kierling"
@@ -501,7 +616,7 @@ func TestAccount_Update(t *testing.T) {
 		t.Error("IsAffiliationPublic was not updated")
 	}
 
-	acc.ResetPWCode = sql.NullString{String: newResetPWCode, Valid: true}
+	acc.ResetPWCode, _ = hashAccountCode(codeResetPassword, newResetPWCode)
 	err = acc.Update()
 	if err != nil {
 		t.Error(err)
@@ -510,9 +625,6 @@ func TestAccount_Update(t *testing.T) {
 	if !ok {
 		t.Error("Password reset code update failed")
 	}
-	if acc.ResetPWCode.String != newResetPWCode {
-		t.Error("Reset password code was not updated")
-	}
 
 	acc.IsDisabled = true
 	err = acc.Update()
@@ -532,7 +644,7 @@ func TestAccount_RemoveActivationCode(t *testing.T) {
 	InitTestDb(t)
 
 	const login = "inact_log1"
-	const activationCode = "ac_a"
+	const activationCode = "ac_a.4102444800"
 
 	acc, ok := GetAccountByLogin(login)
 	if ok {
@@ -556,6 +668,51 @@ func TestAccount_RemoveActivationCode(t *testing.T) {
 	}
 }
 
+func TestRenewActivationCode(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	// registered recently by the fixtures
+	_, _, err := RenewActivationCode("inact_log1", 15*time.Minute)
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Recently registered account should not get a new code: %v", err)
+	}
+
+	database.MustExec(`UPDATE Accounts SET createdAt = now() - INTERVAL '1 hour' WHERE login = 'inact_log1'`)
+	acc, code, err := RenewActivationCode("email1@example.com", 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if acc.Login != "inact_log1" {
+		t.Errorf("Account inact_log1 expected but was %s", acc.Login)
+	}
+	if _, ok := GetAccountByActivationCode(code); !ok {
+		t.Error("New activation code expected to be valid")
+	}
+	if _, ok := GetAccountByActivationCode("ac_a.4102444800"); ok {
+		t.Error("Previous activation code expected to be replaced")
+	}
+
+	if !acc.ActivationSentAt.Valid {
+		t.Error("Time of the renewal expected to be set")
+	}
+	_, _, err = RenewActivationCode("inact_log1", 15*time.Minute)
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Code should be renewed at most once per interval: %v", err)
+	}
+
+	// other changes of the account do not allow to renew the code earlier
+	database.MustExec(`UPDATE Accounts SET updatedAt = now() - INTERVAL '1 hour' WHERE login = 'inact_log1'`)
+	_, _, err = RenewActivationCode("inact_log1", 15*time.Minute)
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Code should be renewed at most once per interval: %v", err)
+	}
+	_, _, err = RenewActivationCode("alice", 0)
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Activated account should not get an activation code: %v", err)
+	}
+}
+
 func TestValidate(t *testing.T) {
 	InitTestDb(t)
 
@@ -728,3 +885,122 @@ func TestValidate(t *testing.T) {
 		t.Errorf("Expected title length error, but got: '%s'", valErr.FieldErrors["country"])
 	}
 }
+
+func TestAccount_VerifyEmail(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	acc, _ := GetAccountByLogin("john")
+	err := acc.VerifyEmail()
+	if err != nil {
+		t.Error(err)
+	}
+
+	acc, _ = GetAccountByLogin("john")
+	if !acc.IsEmailVerified {
+		t.Error("E-mail address should be verified")
+	}
+	if acc.EmailVerificationCode.Valid {
+		t.Error("E-mail verification code should be empty")
+	}
+}
+
+func TestAccount_RenewEmailVerificationCode(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	acc, _ := GetAccountByLogin("john")
+	code, err := acc.RenewEmailVerificationCode()
+	if err != nil {
+		t.Error(err)
+	}
+	if _, ok := GetAccountByEmailVerificationCode(code); !ok {
+		t.Error("E-mail verification code should have been renewed")
+	}
+
+	_, ok := GetAccountByEmailVerificationCode("EV_JOHN.4102444800")
+	if ok {
+		t.Error("Old e-mail verification code should be invalid")
+	}
+
+	acc, _ = GetAccountByLogin("alice")
+	_, err = acc.RenewEmailVerificationCode()
+	if KindOf(err) != ErrConflict {
+		t.Error("Renewal for a verified e-mail address should fail with a conflict")
+	}
+}
+
+func TestNewAccountMarshaler(t *testing.T) {
+	account := &Account{UUID: uuidAlice, Login: "alice"}
+	token := func(accountUUID string, scope ...string) *AccessToken {
+		return &AccessToken{
+			AccountUUID: sql.NullString{String: accountUUID, Valid: true},
+			Scope:       util.NewStringSet(scope...),
+		}
+	}
+
+	tests := []struct {
+		name                         string
+		token                        *AccessToken
+		mail, affiliation, withAdmin bool
+	}{
+		{"anonymous", nil, false, false, false},
+		{"owner account-read", token(uuidAlice, "account-read"), false, true, false},
+		{"owner account-read-email", token(uuidAlice, "account-read", ScopeAccountReadEmail), true, true, false},
+		{"owner account-write", token(uuidAlice, "account-write"), true, true, false},
+		{"other account-read-email", token(uuidBob, "account-read", ScopeAccountReadEmail), false, false, false},
+		{"admin", token(uuidBob, "account-admin"), true, true, true},
+	}
+	for _, test := range tests {
+		am := NewAccountMarshaler(account, test.token)
+		if am.WithMail != test.mail || am.WithAffiliation != test.affiliation || am.WithAdmin != test.withAdmin {
+			t.Errorf("Unexpected fields for %s: mail %t, affiliation %t, admin %t",
+				test.name, am.WithMail, am.WithAffiliation, am.WithAdmin)
+		}
+	}
+
+	account.IsEmailPublic = true
+	account.IsAffiliationPublic = true
+	am := NewAccountMarshaler(account, nil)
+	if !am.WithMail || !am.WithAffiliation || am.WithAdmin {
+		t.Error("Public fields expected for anonymous requests")
+	}
+	if !am.MaskMail || NewAccountMarshaler(account, token(uuidAlice, "account-write")).MaskMail {
+		t.Error("Public e-mail expected to be masked for others only")
+	}
+	account.Email = "aclic@foo.com"
+	b, _ := json.Marshal(am)
+	if !strings.Contains(string(b), `"email":"a***@f***.com"`) {
+		t.Errorf("Masked e-mail expected: %s", string(b))
+	}
+	account.IsEmailUnmasked = true
+	if NewAccountMarshaler(account, nil).MaskMail {
+		t.Error("E-mail expected not to be masked after opt-in")
+	}
+
+	b, _ = json.Marshal(NewAccountMarshaler(account, token(uuidBob, "account-admin")))
+	if !strings.Contains(string(b), `"admin":{"disabled":false`) {
+		t.Errorf("Admin fields expected: %s", string(b))
+	}
+
+	account.Timezone, account.Locale = "Europe/Berlin", "de"
+	b, _ = json.Marshal(NewAccountMarshaler(account, token(uuidAlice, "account-read")))
+	if !strings.Contains(string(b), `"timezone":"Europe/Berlin","locale":"de"`) {
+		t.Errorf("Timezone and locale expected for the owner: %s", string(b))
+	}
+	b, _ = json.Marshal(NewAccountMarshaler(account, nil))
+	if strings.Contains(string(b), `"timezone"`) {
+		t.Errorf("Timezone not expected for anonymous requests: %s", string(b))
+	}
+
+	if strings.Contains(string(b), `"_links"`) {
+		t.Errorf("Links not expected without links: %s", string(b))
+	}
+
+	am = NewAccountMarshaler(account, nil)
+	am.Links = map[string]string{"self": "http://localhost:8081/api/v1/accounts/alice"}
+	b, _ = json.Marshal(am)
+	if !strings.Contains(string(b), `"_links":{"self":{"href":"http://localhost:8081/api/v1/accounts/alice"}}`) {
+		t.Errorf("Links expected: %s", string(b))
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+	"github.com/lib/pq"
+)
+
+// Kinds of account tokens
+const (
+	AccountTokenAccess  = "access"
+	AccountTokenRefresh = "refresh"
+)
+
+// AccountToken describes a valid access or refresh token issued to an account, such that
+// administrators can review and revoke tokens without knowing their values.
+type AccountToken struct {
+	Token      string
+	Kind       string
+	ClientUUID string
+	ClientName string
+	Scope      util.StringSet
+	Expires    pq.NullTime // not set for refresh tokens without expiry
+	LastUsedAt pq.NullTime
+	CreatedAt  time.Time
+}
+
+// ListAccountTokens returns all valid access and refresh tokens of an account, newest first.
+func ListAccountTokens(accountUUID string) []AccountToken {
+	const q = `SELECT t.token, 'access' AS kind, t.clientUUID, c.name AS clientName, t.scope, t.expires,
+	                  t.lastUsedAt, t.createdAt
+	           FROM AccessTokens t JOIN Clients c ON c.uuid = t.clientUUID
+	           WHERE t.accountUUID = $1 AND t.expires > $2
+	           UNION ALL
+	           SELECT t.token, 'refresh' AS kind, t.clientUUID, c.name AS clientName, t.scope, NULL AS expires,
+	                  t.lastUsedAt, t.createdAt
+	           FROM RefreshTokens t JOIN Clients c ON c.uuid = t.clientUUID
+	           WHERE t.accountUUID = $1
+	           ORDER BY createdAt DESC, kind`
+
+	all := make([]AccountToken, 0)
+	err := database.Select(&all, q, accountUUID, expiryTime())
+	if err != nil {
+		panic(err)
+	}
+
+	tokens := make([]AccountToken, 0, len(all))
+	for _, tok := range all {
+		if tok.Kind == AccountTokenRefresh {
+			refresh := &RefreshToken{CreatedAt: tok.CreatedAt, LastUsedAt: tok.LastUsedAt}
+			if refresh.IsExpired() {
+				continue
+			}
+			expires, ok := refresh.Expires()
+			tok.Expires = pq.NullTime{Time: expires, Valid: ok}
+		}
+		tokens = append(tokens, tok)
+	}
+
+	return tokens
+}
+
+// GetAccountToken returns the valid token of an account with the given ID.
+// Returns false if no such token exists.
+func GetAccountToken(accountUUID, id string) (*AccountToken, bool) {
+	tokens := ListAccountTokens(accountUUID)
+	for i := range tokens {
+		if tokens[i].ID() == id {
+			return &tokens[i], true
+		}
+	}
+	return nil, false
+}
+
+// ID identifies the token without revealing its value.
+func (tok *AccountToken) ID() string {
+	sum := sha256.Sum256([]byte(tok.Kind + ":" + tok.Token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Revoke removes the token from the database.
+func (tok *AccountToken) Revoke() error {
+	if tok.Kind == AccountTokenRefresh {
+		return (&RefreshToken{Token: tok.Token}).Delete()
+	}
+	return (&AccessToken{Token: tok.Token}).Delete()
+}
+
+// MarshalJSON implements Marshaler for AccountToken
+func (tok *AccountToken) MarshalJSON() ([]byte, error) {
+	jsonData := &struct {
+		ID         string     `json:"id"`
+		Kind       string     `json:"kind"`
+		ClientUUID string     `json:"client_uuid"`
+		ClientName string     `json:"client_name"`
+		Scope      []string   `json:"scope"`
+		Expires    *time.Time `json:"expires"`
+		LastUsedAt *time.Time `json:"last_used_at"`
+		CreatedAt  time.Time  `json:"created_at"`
+	}{
+		ID:         tok.ID(),
+		Kind:       tok.Kind,
+		ClientUUID: tok.ClientUUID,
+		ClientName: tok.ClientName,
+		Scope:      tok.Scope.Strings(),
+		CreatedAt:  tok.CreatedAt,
+	}
+	if tok.Expires.Valid {
+		jsonData.Expires = &tok.Expires.Time
+	}
+	if tok.LastUsedAt.Valid {
+		jsonData.LastUsedAt = &tok.LastUsedAt.Time
+	}
+	return json.Marshal(jsonData)
+}
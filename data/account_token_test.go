@@ -0,0 +1,86 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestListAccountTokens(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	tokens := ListAccountTokens(uuidAlice)
+	if len(tokens) != 3 {
+		t.Fatalf("Three tokens expected but found %d", len(tokens))
+	}
+	kinds := map[string]int{}
+	for _, tok := range tokens {
+		kinds[tok.Kind]++
+		if tok.ClientName != "gin" {
+			t.Errorf("Unexpected client name '%s'", tok.ClientName)
+		}
+		if tok.Token == "B7NDW8TX" && !tok.LastUsedAt.Valid {
+			t.Error("Time of last use expected")
+		}
+		if tok.Kind == AccountTokenRefresh && (!tok.Expires.Valid || !tok.Expires.Time.Equal(tok.CreatedAt.Add(30*24*time.Hour))) {
+			t.Errorf("Refresh token expected to expire after the idle time: %v", tok.Expires)
+		}
+	}
+	if kinds[AccountTokenAccess] != 2 || kinds[AccountTokenRefresh] != 1 {
+		t.Errorf("Unexpected kinds of tokens: %v", kinds)
+	}
+
+	b, err := json.Marshal(&tokens[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), tokens[0].Token) || !strings.Contains(string(b), tokens[0].ID()) {
+		t.Errorf("Unexpected JSON '%s'", string(b))
+	}
+
+	// expired tokens are not listed
+	if len(ListAccountTokens(uuidBob)) != 2 {
+		t.Error("Two tokens of bob expected")
+	}
+}
+
+func TestAccountToken_Revoke(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	var refresh *AccountToken
+	for _, tok := range ListAccountTokens(uuidAlice) {
+		if tok.Kind == AccountTokenRefresh {
+			refresh, _ = GetAccountToken(uuidAlice, tok.ID())
+		}
+	}
+	if refresh == nil {
+		t.Fatal("Refresh token of alice expected")
+	}
+	if _, ok := GetAccountToken(uuidBob, refresh.ID()); ok {
+		t.Error("Token should not be found for another account")
+	}
+
+	err := refresh.Revoke()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := GetRefreshToken(refresh.Token); ok {
+		t.Error("Refresh token should have been removed")
+	}
+	if len(ListAccountTokens(uuidAlice)) != 2 {
+		t.Error("Two tokens of alice expected")
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import "github.com/G-Node/gin-auth/util"
+
+// Administrative scopes. The scope 'account-admin' grants full control, the other scopes grant
+// parts of it, e.g. read-only access for monitoring dashboards:
+// - 'admin-read' reads accounts, pending accounts, scope requests, usage and settings
+// - 'admin-account-write' changes other accounts and decides on pending accounts and scope requests
+// - 'token-admin' lists and revokes tokens of all accounts
+// - 'client-admin' reads statistics of clients and grant requests
+// - 'audit-read' reads the change history of accounts
+const (
+	ScopeAccountAdmin      = "account-admin"
+	ScopeAdminRead         = "admin-read"
+	ScopeAdminAccountWrite = "admin-account-write"
+	ScopeTokenAdmin        = "token-admin"
+	ScopeClientAdmin       = "client-admin"
+	ScopeAuditRead         = "audit-read"
+)
+
+// HasAdminScope checks whether the scope contains 'account-admin' or one of the given admin scopes.
+func HasAdminScope(scope util.StringSet, admin ...string) bool {
+	if scope.Contains(ScopeAccountAdmin) {
+		return true
+	}
+	for _, s := range admin {
+		if scope.Contains(s) {
+			return true
+		}
+	}
+	return false
+}
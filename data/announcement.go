@@ -0,0 +1,276 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/lib/pq"
+	"github.com/pborman/uuid"
+)
+
+// Maximum length of announcement subjects
+const maxAnnouncementSubjectLength = 512
+
+// Announcement is an e-mail sent by an administrator to all active accounts, to the accounts
+// with a label or in a group or to the accounts matching a saved AccountFilter. Accounts which
+// opted out of announcements are skipped. Recipients
+// are determined when the announcement is created, the e-mails are queued in batches by
+// DispatchAnnouncements.
+type Announcement struct {
+	UUID        string
+	Subject     string
+	Body        string
+	Label       string
+	GroupName   string
+	FilterName  string
+	Recipients  int
+	CreatedBy   sql.NullString
+	CompletedAt pq.NullTime
+	CreatedAt   time.Time
+}
+
+// recipient query of announcements, $1 to $7 are the parameters of the recipient filter
+const qAnnouncementRecipients = `SELECT a.uuid FROM ActiveAccounts a
+                                 WHERE NOT a.isAnnouncementOptOut AND ` + qAccountFilterCondition
+
+// ListAnnouncements returns all announcements, newest first.
+func ListAnnouncements() []Announcement {
+	const q = `SELECT * FROM Announcements ORDER BY createdAt DESC`
+
+	announcements := make([]Announcement, 0)
+	err := database.Select(&announcements, q)
+	if err != nil {
+		panic(err)
+	}
+
+	return announcements
+}
+
+// recipientFilter returns the saved filter of the announcement or a filter for its label and group.
+// Returns false if the saved filter does not exist.
+func (ann *Announcement) recipientFilter() (*AccountFilter, bool) {
+	if ann.FilterName != "" {
+		return GetAccountFilter(ann.FilterName)
+	}
+	return &AccountFilter{Label: ann.Label, GroupName: ann.GroupName}, true
+}
+
+// CountRecipients returns the number of accounts which would receive the announcement.
+func (ann *Announcement) CountRecipients() int {
+	filter, ok := ann.recipientFilter()
+	if !ok {
+		return 0
+	}
+	q := `SELECT count(*) FROM (` + qAnnouncementRecipients + `) r`
+
+	var count int
+	err := database.Get(&count, q, filter.params()...)
+	if err != nil {
+		panic(err)
+	}
+
+	return count
+}
+
+// Validate checks subject and body of the announcement.
+func (ann *Announcement) Validate() *util.ValidationError {
+	valErr := &util.ValidationError{FieldErrors: make(map[string]string)}
+	ann.Subject = strings.TrimSpace(ann.Subject)
+	if ann.Subject == "" {
+		valErr.FieldErrors["subject"] = "Please add a subject"
+	} else if len(ann.Subject) > maxAnnouncementSubjectLength || strings.ContainsAny(ann.Subject, "\r\n") {
+		valErr.FieldErrors["subject"] = "The subject must be a single line of at most 512 characters"
+	}
+	if strings.TrimSpace(ann.Body) == "" {
+		valErr.FieldErrors["body"] = "Please add a message"
+	}
+	if ann.GroupName != "" {
+		if _, ok := GetGroup(ann.GroupName); !ok {
+			valErr.FieldErrors["group"] = "The group does not exist"
+		}
+	}
+	if ann.FilterName != "" {
+		if ann.Label != "" || ann.GroupName != "" {
+			valErr.FieldErrors["filter"] = "Please use either a filter or label and group"
+		} else if _, ok := GetAccountFilter(ann.FilterName); !ok {
+			valErr.FieldErrors["filter"] = "The filter does not exist"
+		}
+	}
+	if len(valErr.FieldErrors) > 0 {
+		valErr.Message = "Invalid announcement"
+		return valErr
+	}
+	return nil
+}
+
+// Create stores the announcement and its recipients. The e-mails are sent later by
+// DispatchAnnouncements.
+func (ann *Announcement) Create() error {
+	if err := ann.Validate(); err != nil {
+		return err
+	}
+
+	filter, _ := ann.recipientFilter()
+
+	const q = `INSERT INTO Announcements (uuid, subject, body, label, groupName, filterName, createdBy, createdAt)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, now())
+	           RETURNING *`
+	qRecipients := `INSERT INTO AnnouncementRecipients (announcementUUID, accountUUID)
+	                SELECT $8, r.uuid FROM (` + qAnnouncementRecipients + `) r`
+	const qCount = `UPDATE Announcements SET recipients = $1 WHERE uuid = $2 RETURNING *`
+
+	if ann.UUID == "" {
+		ann.UUID = uuid.NewRandom().String()
+	}
+
+	tx := database.MustBegin()
+	err := tx.Get(ann, q, ann.UUID, ann.Subject, ann.Body, ann.Label, ann.GroupName, ann.FilterName, ann.CreatedBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	res, err := tx.Exec(qRecipients, append(filter.params(), ann.UUID)...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	count, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Get(ann, qCount, count, ann.UUID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Preview returns the e-mail of the announcement as it is sent to the given account.
+func (ann *Announcement) Preview(acc *Account) []byte {
+	tmplFields := &struct {
+		From    string
+		To      string
+		Subject string
+		Body    string
+		Login   string
+	}{
+		conf.GetSmtpCredentials().From,
+		acc.Email,
+		ann.Subject,
+		ann.Body,
+		acc.Login,
+	}
+	content := util.MakeEmailTemplate("emailannouncement.txt", tmplFields)
+	return content.Bytes()
+}
+
+// DispatchAnnouncements queues at most batchSize e-mails of pending announcements, oldest
+// announcements first. Recipients which were disabled or opted out in the meantime are skipped.
+// Announcements without remaining recipients are marked as completed.
+func DispatchAnnouncements(batchSize int) error {
+	const qPending = `SELECT * FROM Announcements WHERE completedAt IS NULL ORDER BY createdAt`
+	const qRecipients = `SELECT a.* FROM AnnouncementRecipients r JOIN Accounts a ON a.uuid = r.accountUUID
+	                     WHERE r.announcementUUID = $1
+	                     ORDER BY a.login
+	                     LIMIT $2`
+	const qActive = `SELECT EXISTS (SELECT 1 FROM ActiveAccounts WHERE uuid = $1 AND NOT isAnnouncementOptOut)`
+	const qDone = `DELETE FROM AnnouncementRecipients WHERE announcementUUID = $1 AND accountUUID = $2`
+	const qComplete = `UPDATE Announcements SET completedAt = now()
+	                   WHERE uuid = $1 AND NOT EXISTS (SELECT 1 FROM AnnouncementRecipients WHERE announcementUUID = $1)`
+
+	announcements := make([]Announcement, 0)
+	err := database.Select(&announcements, qPending)
+	if err != nil {
+		return err
+	}
+
+	for i := range announcements {
+		ann := &announcements[i]
+		if batchSize <= 0 {
+			return nil
+		}
+
+		recipients := make([]Account, 0)
+		err = database.Select(&recipients, qRecipients, ann.UUID, batchSize)
+		if err != nil {
+			return err
+		}
+		for j := range recipients {
+			acc := &recipients[j]
+			var active bool
+			err = database.Get(&active, qActive, acc.UUID)
+			if err != nil {
+				return err
+			}
+			if active {
+				email := &Email{}
+				err = email.Create(util.NewStringSet(acc.Email), ann.Preview(acc))
+				if err != nil {
+					return err
+				}
+				batchSize--
+			}
+			_, err = database.Exec(qDone, ann.UUID, acc.UUID)
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = database.Exec(qComplete, ann.UUID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateAnnouncementOptOut sets whether the account receives announcements sent by administrators.
+func (acc *Account) UpdateAnnouncementOptOut(optOut bool) error {
+	const q = `UPDATE Accounts SET isAnnouncementOptOut=$1 WHERE uuid=$2 RETURNING *`
+
+	return database.Get(acc, q, optOut, acc.UUID)
+}
+
+// MarshalJSON implements Marshaler for Announcement
+func (ann *Announcement) MarshalJSON() ([]byte, error) {
+	jsonData := &struct {
+		UUID        string     `json:"uuid"`
+		Subject     string     `json:"subject"`
+		Body        string     `json:"body"`
+		Label       string     `json:"label"`
+		Group       string     `json:"group"`
+		Filter      string     `json:"filter"`
+		Recipients  int        `json:"recipients"`
+		CompletedAt *time.Time `json:"completed_at"`
+		CreatedAt   time.Time  `json:"created_at"`
+	}{
+		UUID:       ann.UUID,
+		Subject:    ann.Subject,
+		Body:       ann.Body,
+		Label:      ann.Label,
+		Group:      ann.GroupName,
+		Filter:     ann.FilterName,
+		Recipients: ann.Recipients,
+		CreatedAt:  ann.CreatedAt,
+	}
+	if ann.CompletedAt.Valid {
+		jsonData.CompletedAt = &ann.CompletedAt.Time
+	}
+	return json.Marshal(jsonData)
+}
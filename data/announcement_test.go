@@ -0,0 +1,122 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestAnnouncement_CountRecipients(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	// bob opted out
+	if n := (&Announcement{}).CountRecipients(); n != 2 {
+		t.Errorf("Two recipients expected but was %d", n)
+	}
+	if n := (&Announcement{GroupName: "lmu-neuro"}).CountRecipients(); n != 1 {
+		t.Errorf("One recipient expected but was %d", n)
+	}
+	if n := (&Announcement{Label: "doesnotexist"}).CountRecipients(); n != 0 {
+		t.Errorf("No recipients expected but was %d", n)
+	}
+	if n := (&Announcement{FilterName: "unverified-30-days"}).CountRecipients(); n != 1 {
+		t.Errorf("One recipient expected but was %d", n)
+	}
+}
+
+func TestAnnouncement_Create(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	invalid := &Announcement{Subject: "Two\nlines", GroupName: "doesnotexist"}
+	err := invalid.Create()
+	valErr, ok := err.(*util.ValidationError)
+	if !ok || len(valErr.FieldErrors) != 3 {
+		t.Errorf("Validation error for subject, body and group expected but was %v", err)
+	}
+
+	ann := &Announcement{Subject: "Maintenance", Body: "GIN is down on sunday",
+		CreatedBy: sql.NullString{String: uuidBob, Valid: true}}
+	err = ann.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ann.Recipients != 2 || ann.CompletedAt.Valid {
+		t.Errorf("Unexpected announcement: %+v", ann)
+	}
+	if len(ListAnnouncements()) != 1 {
+		t.Error("One announcement expected")
+	}
+
+	invalid = &Announcement{Subject: "Reminder", Body: "Please verify your e-mail address",
+		Label: "verified researcher", FilterName: "unverified-30-days"}
+	if valErr := invalid.Validate(); valErr == nil || valErr.FieldErrors["filter"] == "" {
+		t.Error("Filter combined with label expected to be invalid")
+	}
+
+	reminder := &Announcement{Subject: "Reminder", Body: "Please verify your e-mail address",
+		FilterName: "unverified-30-days", CreatedBy: sql.NullString{String: uuidBob, Valid: true}}
+	err = reminder.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reminder.Recipients != 1 || reminder.FilterName != "unverified-30-days" {
+		t.Errorf("Unexpected announcement: %+v", reminder)
+	}
+}
+
+func TestDispatchAnnouncements(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	ann := &Announcement{Subject: "Maintenance", Body: "GIN is down on sunday"}
+	err := ann.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// alice first
+	err = DispatchAnnouncements(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emails, _ := GetQueuedEmails()
+	if len(emails) != 1 || !emails[0].Recipient.Contains("aclic@foo.com") {
+		t.Fatalf("One e-mail to alice expected but got %v", emails)
+	}
+	if !strings.Contains(string(emails[0].Content), "GIN is down on sunday") {
+		t.Error("E-mail expected to contain the announcement")
+	}
+	if ListAnnouncements()[0].CompletedAt.Valid {
+		t.Error("Announcement should not be completed")
+	}
+
+	// john opted out in the meantime
+	john, _ := GetAccountByLogin("john")
+	err = john.UpdateAnnouncementOptOut(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = DispatchAnnouncements(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	emails, _ = GetQueuedEmails()
+	if len(emails) != 1 {
+		t.Errorf("No further e-mails expected but got %d", len(emails)-1)
+	}
+	if !ListAnnouncements()[0].CompletedAt.Valid {
+		t.Error("Announcement should be completed")
+	}
+}
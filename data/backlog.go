@@ -0,0 +1,64 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Backlog describes work which is not yet done by the background jobs: the number of expired
+// rows per table which were not yet removed by the cleaner, the number of queued e-mails and
+// the age of the oldest queued e-mail (zero if the queue is empty).
+type Backlog struct {
+	ExpiredRows     map[string]int64
+	EmailQueueDepth int64
+	EmailQueueAge   time.Duration
+}
+
+// GetBacklog counts the backlog of the cleaner and the e-mail queue. Expired sessions are
+// only counted if sessions are stored in the database.
+func GetBacklog() *Backlog {
+	const qGrant = `SELECT count(*) FROM GrantRequests WHERE createdAt <= $1`
+	const qEmails = `SELECT count(*), min(createdAt) FROM EmailQueue`
+
+	backlog := &Backlog{ExpiredRows: make(map[string]int64)}
+
+	var count int64
+	err := database.Get(&count, qGrant, expiryTime().Add(-1*conf.GetServerConfig().GrantReqLifeTime))
+	if err != nil {
+		panic(err)
+	}
+	backlog.ExpiredRows["GrantRequests"] = count
+
+	tables := append([]string{}, expiringTables...)
+	if conf.GetSessionStore().Backend == conf.SessionStorePostgres {
+		tables = append(tables, "Sessions")
+	}
+	for _, table := range tables {
+		err = database.Get(&count, `SELECT count(*) FROM `+table+` WHERE expires <= $1`, expiryTime())
+		if err != nil {
+			panic(err)
+		}
+		backlog.ExpiredRows[table] = count
+	}
+
+	var oldest *time.Time
+	err = database.QueryRow(qEmails).Scan(&backlog.EmailQueueDepth, &oldest)
+	if err != nil {
+		panic(err)
+	}
+	if oldest != nil {
+		backlog.EmailQueueAge = util.Now().Sub(*oldest)
+	}
+
+	return backlog
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestGetBacklog(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	backlog := GetBacklog()
+	if backlog.ExpiredRows["AccessTokens"] < 1 || backlog.ExpiredRows["Sessions"] < 1 {
+		t.Errorf("Expired access tokens and sessions expected: %v", backlog.ExpiredRows)
+	}
+	if backlog.EmailQueueDepth != 2 || backlog.EmailQueueAge < 0 {
+		t.Errorf("Two queued e-mails expected: %+v", backlog)
+	}
+
+	RemoveExpired(testServiceToken(t, JobCleaner))
+	EmailDispatch()
+	backlog = GetBacklog()
+	for table, count := range backlog.ExpiredRows {
+		if count != 0 {
+			t.Errorf("No expired rows expected in table %s but were %d", table, count)
+		}
+	}
+	if backlog.EmailQueueDepth != 0 || backlog.EmailQueueAge != 0 {
+		t.Errorf("Empty e-mail queue expected: %+v", backlog)
+	}
+}
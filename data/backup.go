@@ -0,0 +1,298 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// BackupVersion is the version of the backup format written by CreateBackup.
+const BackupVersion = 1
+
+// Modes for the handling of secrets in backups
+const (
+	SecretsInclude = "include"
+	SecretsExclude = "exclude"
+	SecretsEncrypt = "encrypt"
+)
+
+// Prefix of encrypted secrets in a backup
+const encryptedSecretPrefix = "enc:"
+
+// backupTable describes a table contained in a backup.
+type backupTable struct {
+	name    string
+	secrets []string // columns containing secrets
+	skip    []string // columns which are generated by the database
+	order   string   // order of rows if rows reference other rows of the same table
+}
+
+// backupTables contains all tables of a backup in an order that satisfies all foreign key constraints.
+var backupTables = []backupTable{
+	{name: "accounts", secrets: []string{"pwhash"}},
+	{name: "accounthistory", skip: []string{"id"}},
+	{name: "accountnotes"},
+	{name: "sshkeys"},
+	{name: "clients", secrets: []string{"secret"}},
+	{name: "clientscopeprovided"},
+	{name: "clientscoperequests"},
+	{name: "clientapprovals"},
+	{name: "consentreceipts"},
+	{name: "groups", order: "createdAt"},
+	{name: "groupmembers"},
+	{name: "accountscopes"},
+	{name: "featureflags"},
+}
+
+var columnNameRegex = regexp.MustCompile(`^[a-z_]+$`)
+
+// Backup contains accounts, ssh keys, clients, approvals and groups in a database independent format.
+// Each table is represented by a list of rows, each row maps column names to values.
+type Backup struct {
+	Version   int                                 `json:"version"`
+	CreatedAt time.Time                           `json:"created_at"`
+	Secrets   string                              `json:"secrets"`
+	Salt      string                              `json:"salt,omitempty"`
+	Tables    map[string][]map[string]interface{} `json:"tables"`
+}
+
+// CreateBackup reads all backed up tables from the database. Depending on the secrets
+// mode password hashes and client secrets are included, excluded or encrypted with a key
+// derived from the passphrase. Without a mode secrets are excluded.
+func CreateBackup(secrets, passphrase string) (*Backup, error) {
+	if secrets == "" {
+		secrets = SecretsExclude
+	}
+	backup := &Backup{
+		Version:   BackupVersion,
+		CreatedAt: time.Now(),
+		Secrets:   secrets,
+		Tables:    make(map[string][]map[string]interface{}),
+	}
+
+	var gcm cipher.AEAD
+	switch secrets {
+	case SecretsInclude, SecretsExclude:
+	case SecretsEncrypt:
+		salt := make([]byte, 16)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return nil, err
+		}
+		backup.Salt = base64.StdEncoding.EncodeToString(salt)
+		gcm, err = backupCipher(passphrase, salt)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unknown secrets mode '%s'", secrets)
+	}
+
+	for _, table := range backupTables {
+		q := "SELECT * FROM " + table.name
+		if table.order != "" {
+			q += " ORDER BY " + table.order
+		}
+		rows, err := database.Queryx(q)
+		if err != nil {
+			return nil, err
+		}
+
+		list := make([]map[string]interface{}, 0)
+		for rows.Next() {
+			row := make(map[string]interface{})
+			err = rows.MapScan(row)
+			if err != nil {
+				rows.Close()
+				return nil, err
+			}
+			for col, val := range row {
+				if b, ok := val.([]byte); ok {
+					row[col] = string(b)
+				}
+			}
+			for _, col := range table.skip {
+				delete(row, col)
+			}
+			for _, col := range table.secrets {
+				s, ok := row[col].(string)
+				if !ok {
+					continue
+				}
+				switch secrets {
+				case SecretsExclude:
+					row[col] = ""
+				case SecretsEncrypt:
+					row[col], err = encryptSecret(gcm, s)
+				}
+				if err != nil {
+					rows.Close()
+					return nil, err
+				}
+			}
+			list = append(list, row)
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+		backup.Tables[table.name] = list
+	}
+
+	return backup, nil
+}
+
+// ReadBackup decodes a backup from JSON.
+func ReadBackup(r io.Reader) (*Backup, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	backup := &Backup{}
+	err := dec.Decode(backup)
+	return backup, err
+}
+
+// RestoreBackup inserts all rows of a backup in a single transaction. The database must not
+// contain any accounts and existing clients are replaced by those from the backup. Encrypted secrets are decrypted using the passphrase. Accounts from
+// backups without secrets need a password reset before they can be used.
+func RestoreBackup(backup *Backup, passphrase string) error {
+	if backup.Version != BackupVersion {
+		return fmt.Errorf("Unsupported backup version %d", backup.Version)
+	}
+	if HasAccounts() {
+		return errors.New("Backups can only be restored into a database without accounts")
+	}
+
+	var gcm cipher.AEAD
+	if backup.Secrets == SecretsEncrypt {
+		salt, err := base64.StdEncoding.DecodeString(backup.Salt)
+		if err != nil {
+			return err
+		}
+		gcm, err = backupCipher(passphrase, salt)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx := database.MustBegin()
+
+	// clients are usually already present since they are initialized from the clients config file
+	_, err := tx.Exec("DELETE FROM clients")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	for _, table := range backupTables {
+		for _, row := range backup.Tables[table.name] {
+			if gcm != nil {
+				for _, col := range table.secrets {
+					if s, ok := row[col].(string); ok && strings.HasPrefix(s, encryptedSecretPrefix) {
+						plain, err := decryptSecret(gcm, s)
+						if err != nil {
+							tx.Rollback()
+							return err
+						}
+						row[col] = plain
+					}
+				}
+			}
+
+			err := insertBackupRow(tx, table.name, row)
+			if err != nil {
+				tx.Rollback()
+				return fmt.Errorf("Error restoring table '%s': %s", table.name, err.Error())
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// insertBackupRow inserts a single row into a table.
+func insertBackupRow(tx *sqlx.Tx, table string, row map[string]interface{}) error {
+	columns := make([]string, 0, len(row))
+	for col := range row {
+		if !columnNameRegex.MatchString(col) {
+			return fmt.Errorf("Invalid column name '%s'", col)
+		}
+		columns = append(columns, col)
+	}
+	sort.Strings(columns)
+
+	params := make([]string, len(columns))
+	values := make([]interface{}, len(columns))
+	for i, col := range columns {
+		params[i] = fmt.Sprintf("$%d", i+1)
+		values[i] = row[col]
+		if n, ok := values[i].(json.Number); ok {
+			values[i] = n.String()
+		}
+	}
+
+	q := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(params, ", "))
+	_, err := tx.Exec(q, values...)
+	return err
+}
+
+// backupCipher creates an AES-GCM cipher with a key derived from a passphrase.
+func backupCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	if passphrase == "" {
+		return nil, errors.New("Passphrase required for encrypted secrets")
+	}
+	key := pbkdf2.Key([]byte(passphrase), salt, 100000, 32, sha256.New)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptSecret encrypts a secret and returns it in base64 encoding with prefix.
+func encryptSecret(gcm cipher.AEAD, plain string) (string, error) {
+	nonce := make([]byte, gcm.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+	sealed := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return encryptedSecretPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret decrypts a secret created by encryptSecret.
+func decryptSecret(gcm cipher.AEAD, encrypted string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, encryptedSecretPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return "", errors.New("Encrypted secret is too short")
+	}
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("Unable to decrypt secret, wrong passphrase?")
+	}
+	return string(plain), nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	gcm, err := backupCipher("passphrase", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted, err := encryptSecret(gcm, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(encrypted, encryptedSecretPrefix) || strings.Contains(encrypted, "secret") {
+		t.Errorf("Unexpected encrypted secret '%s'", encrypted)
+	}
+
+	plain, err := decryptSecret(gcm, encrypted)
+	if err != nil || plain != "secret" {
+		t.Error("Unable to decrypt secret")
+	}
+
+	wrong, _ := backupCipher("wrong", salt)
+	_, err = decryptSecret(wrong, encrypted)
+	if err == nil {
+		t.Error("Decryption with wrong passphrase should fail")
+	}
+
+	_, err = backupCipher("", salt)
+	if err == nil {
+		t.Error("Empty passphrase should be rejected")
+	}
+}
+
+// backupRoundTrip encodes and decodes a backup like the admin tool does.
+func backupRoundTrip(t *testing.T, backup *Backup) *Backup {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := ReadBackup(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func TestCreateRestoreBackup(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	_, err := CreateBackup("unknown", "")
+	if err == nil {
+		t.Error("Unknown secrets mode should fail")
+	}
+
+	backup, err := CreateBackup(SecretsEncrypt, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	database.Get(&count, "SELECT count(*) FROM Accounts")
+	if len(backup.Tables["accounts"]) != count {
+		t.Errorf("%d accounts expected in backup but was %d", count, len(backup.Tables["accounts"]))
+	}
+	if len(backup.Tables["clients"]) != 2 {
+		t.Errorf("Two clients expected in backup but was %d", len(backup.Tables["clients"]))
+	}
+	for _, row := range backup.Tables["accounts"] {
+		if !strings.HasPrefix(row["pwhash"].(string), encryptedSecretPrefix) {
+			t.Error("Password hash expected to be encrypted")
+		}
+	}
+
+	err = RestoreBackup(backupRoundTrip(t, backup), "passphrase")
+	if err == nil {
+		t.Error("Restore into a database with accounts should fail")
+	}
+
+	removeAllAccounts()
+	err = RestoreBackup(backupRoundTrip(t, backup), "wrong")
+	if err == nil {
+		t.Error("Restore with wrong passphrase should fail")
+	}
+	err = RestoreBackup(backupRoundTrip(t, backup), "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc, ok := GetAccountByLogin("alice")
+	if !ok {
+		t.Fatal("Account 'alice' expected to be restored")
+	}
+	if !acc.VerifyPassword("testtest") {
+		t.Error("Password of 'alice' expected to be restored")
+	}
+	if len(ListAccountHistory(acc.UUID)) != 2 {
+		t.Error("History of 'alice' expected to be restored")
+	}
+	if len(acc.SSHKeys()) == 0 {
+		t.Error("Keys of 'alice' expected to be restored")
+	}
+	if client, ok := GetClientByName("gin"); !ok || client.Secret != "secret" {
+		t.Error("Client 'gin' expected to be restored with secret")
+	}
+
+	// backup without secrets
+	backup, err = CreateBackup("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup.Secrets != SecretsExclude {
+		t.Errorf("Secrets expected to be excluded by default but mode was '%s'", backup.Secrets)
+	}
+	for _, row := range backup.Tables["accounts"] {
+		if row["pwhash"] != "" {
+			t.Error("Password hash expected to be excluded")
+		}
+	}
+}
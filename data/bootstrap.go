@@ -0,0 +1,75 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"errors"
+)
+
+// Placeholders for the profile of the initial account, which can be changed later
+// via the account API.
+const (
+	bootstrapFirstName   = "GIN"
+	bootstrapLastName    = "Administrator"
+	bootstrapAffiliation = "-"
+)
+
+// HasAccounts checks whether the database contains at least one account.
+func HasAccounts() bool {
+	const q = `SELECT EXISTS (SELECT 1 FROM Accounts)`
+
+	var exists bool
+	err := database.Get(&exists, q)
+	if err != nil {
+		panic(err)
+	}
+
+	return exists
+}
+
+// BootstrapAccount creates an initial account with the given login, e-mail address and password.
+// The account is active and its e-mail address is marked as verified. Returns an error if the
+// database already contains accounts or if the account data is invalid.
+func BootstrapAccount(login, email, password string) (*Account, error) {
+	if HasAccounts() {
+		return nil, conflictError("The database already contains accounts")
+	}
+	if password == "" {
+		return nil, errors.New("Please add a password")
+	}
+
+	acc := &Account{
+		Login:      login,
+		Email:      email,
+		FirstName:  bootstrapFirstName,
+		LastName:   bootstrapLastName,
+		Institute:  bootstrapAffiliation,
+		Department: bootstrapAffiliation,
+		City:       bootstrapAffiliation,
+		Country:    bootstrapAffiliation,
+	}
+
+	valErr := acc.Validate()
+	if len(valErr.FieldErrors) > 0 {
+		return nil, valErr
+	}
+
+	err := acc.SetPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	err = acc.Create()
+	if err != nil {
+		return nil, err
+	}
+
+	err = acc.RemoveActivationCode()
+	return acc, err
+}
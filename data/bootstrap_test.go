@@ -0,0 +1,81 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+// removeAllAccounts deletes all accounts and depending entries from the test database.
+func removeAllAccounts() {
+	const q = `DELETE FROM EmailQueue;
+	           DELETE FROM RefreshTokens;
+	           DELETE FROM AccessTokens;
+	           DELETE FROM Sessions;
+	           DELETE FROM GrantRequests;
+	           DELETE FROM ClientApprovals;
+	           DELETE FROM SSHKeys;
+	           DELETE FROM AccountHistory;
+	           DELETE FROM Groups;
+	           DELETE FROM Accounts;`
+	database.MustExec(q)
+}
+
+func TestHasAccounts(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	if !HasAccounts() {
+		t.Error("Database expected to contain accounts")
+	}
+
+	removeAllAccounts()
+	if HasAccounts() {
+		t.Error("Database expected to contain no accounts")
+	}
+}
+
+func TestBootstrapAccount(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	_, err := BootstrapAccount("admin", "admin@example.com", "testtest")
+	if err == nil {
+		t.Error("Bootstrap should fail if accounts exist")
+	}
+
+	removeAllAccounts()
+
+	_, err = BootstrapAccount("admin", "admin@example.com", "")
+	if err == nil {
+		t.Error("Bootstrap without password should fail")
+	}
+	_, err = BootstrapAccount("admin", "invalid", "testtest")
+	if err == nil {
+		t.Error("Bootstrap with invalid e-mail address should fail")
+	}
+
+	_, err = BootstrapAccount("admin", "admin@example.com", "testtest")
+	if err != nil {
+		t.Error(err)
+	}
+
+	acc, ok := GetAccountByLogin("admin")
+	if !ok {
+		t.Error("Initial account should be active")
+	}
+	if !acc.IsEmailVerified {
+		t.Error("E-mail address of the initial account should be verified")
+	}
+	if !acc.VerifyPassword("testtest") {
+		t.Error("Password of the initial account does not match")
+	}
+}
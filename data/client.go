@@ -13,8 +13,12 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
 	"time"
 
+	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/util"
 	"github.com/jmoiron/sqlx"
 	"github.com/pborman/uuid"
@@ -23,15 +27,30 @@ import (
 
 // Client object stored in the database
 type Client struct {
-	UUID             string
-	Name             string
-	Secret           string
-	ScopeProvidedMap map[string]string
-	ScopeWhitelist   util.StringSet
-	ScopeBlacklist   util.StringSet
-	RedirectURIs     util.StringSet
-	CreatedAt        time.Time
-	UpdatedAt        time.Time
+	UUID                   string
+	Name                   string
+	Secret                 string
+	ScopeProvidedMap       map[string]string
+	ScopeVersions          map[string]int
+	ScopeWhitelist         util.StringSet
+	ScopeBlacklist         util.StringSet
+	ScopeAllowed           util.StringSet
+	RedirectURIs           util.StringSet
+	TokenBinding           string
+	FirstParty             bool
+	SkipConsent            bool
+	Development            bool
+	PostLogoutRedirectURIs util.StringSet
+	FrontChannelLogoutURI  string
+	AuthMethod             string
+	JWKS                   string
+	PublisherName          string
+	Homepage               string
+	LogoURL                string
+	Verified               bool
+	ResourceServer         bool
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
 }
 
 // ListClients returns all registered OAuth clients ordered by name
@@ -68,18 +87,22 @@ func GetClient(uuid string) (*Client, bool) {
 	return getClient(q, uuid)
 }
 
-// GetClientByName returns an OAuth client with a given client name.
+// GetClientByName returns an OAuth client with a given client name. Former names of renamed
+// clients are accepted during their grace period.
 // Returns false if no client with a matching name can be found.
 func GetClientByName(name string) (*Client, bool) {
 	const q = `SELECT * FROM Clients WHERE name=$1`
-	return getClient(q, name)
+	if client, ok := getClient(q, name); ok {
+		return client, true
+	}
+	return getClientByFormerName(name)
 }
 
-func getClient(q, parameter string) (*Client, bool) {
-	const qScope = `SELECT name, description FROM ClientScopeProvided WHERE clientUUID = $1`
+func getClient(q string, args ...interface{}) (*Client, bool) {
+	const qScope = `SELECT name, description, version FROM ClientScopeProvided WHERE clientUUID = $1`
 
-	client := &Client{ScopeProvidedMap: make(map[string]string)}
-	err := database.Get(client, q, parameter)
+	client := &Client{ScopeProvidedMap: make(map[string]string), ScopeVersions: make(map[string]int)}
+	err := database.Get(client, q, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, false
@@ -90,6 +113,7 @@ func getClient(q, parameter string) (*Client, bool) {
 	scope := []struct {
 		Name        string
 		Description string
+		Version     int
 	}{}
 	err = database.Select(&scope, qScope, client.UUID)
 	if err != nil {
@@ -97,6 +121,7 @@ func getClient(q, parameter string) (*Client, bool) {
 	}
 	for _, s := range scope {
 		client.ScopeProvidedMap[s.Name] = s.Description
+		client.ScopeVersions[s.Name] = s.Version
 	}
 
 	return client, true
@@ -163,6 +188,15 @@ func (client *Client) ScopeProvided() util.StringSet {
 	return util.NewStringSet(scope...)
 }
 
+// ScopeVersion returns the version of a scope provided by this client. Scopes without
+// an explicit version have the version 1.
+func (client *Client) ScopeVersion(name string) int {
+	if v, ok := client.ScopeVersions[name]; ok && v > 0 {
+		return v
+	}
+	return 1
+}
+
 // ApprovalForAccount gets a client approval for this client which was
 // approved for a specific account.
 func (client *Client) ApprovalForAccount(accountUUID string) (*ClientApproval, bool) {
@@ -177,7 +211,8 @@ func (client *Client) ApprovalForAccount(accountUUID string) (*ClientApproval, b
 }
 
 // Approve creates a new client approval or extends an existing approval, such that the
-// given scope is is approved for the given account.
+// given scope is is approved for the given account. A stale approval is replaced by the given
+// scope. A consent receipt is recorded for each approval which grants additional scope.
 func (client *Client) Approve(accountUUID string, scope util.StringSet) (err error) {
 	if !CheckScope(scope) {
 		return errors.New("Invalid scope")
@@ -187,18 +222,28 @@ func (client *Client) Approve(accountUUID string, scope util.StringSet) (err err
 		return errors.New("Blacklisted scope")
 	}
 
+	if err := CheckScopeGrant(accountUUID, client.UUID, scope); err != nil {
+		return err
+	}
+
 	scope = scope.Difference(client.ScopeWhitelist)
 	if scope.Len() == 0 {
 		return nil
 	}
 
 	approval, ok := client.ApprovalForAccount(accountUUID)
-	if ok {
+	if ok && !approval.Stale {
 		// approval exists
-		if !approval.Scope.IsSuperset(scope) {
-			approval.Scope = approval.Scope.Union(scope)
-			err = approval.Update()
+		if approval.Scope.IsSuperset(scope) {
+			return nil
 		}
+		approval.Scope = approval.Scope.Union(scope)
+		err = approval.Update()
+	} else if ok {
+		// renew stale approval
+		approval.Scope = scope
+		approval.Stale = false
+		err = approval.Update()
 	} else {
 		// create new approval
 		approval = &ClientApproval{
@@ -208,18 +253,23 @@ func (client *Client) Approve(accountUUID string, scope util.StringSet) (err err
 		}
 		err = approval.Create()
 	}
+	if err != nil {
+		return err
+	}
+
+	_, err = client.recordConsent(accountUUID, scope)
 	return err
 }
 
-// CreateGrantRequest check whether response type, redirect URI and scope are valid and creates a new
-// grant request for this client. Grant types are defined by RFC6749 "OAuth 2.0 Authorization Framework"
+// CreateGrantRequest check whether response type, redirect URI, scope and the optional return URL are valid
+// and creates a new grant request for this client. Grant types are defined by RFC6749 "OAuth 2.0 Authorization Framework"
 // Supported grant types are: "code" (authorization code), "token" (implicit request),
 // "owner" (resource owner password credentials), "client" (client credentials)
-func (client *Client) CreateGrantRequest(responseType, redirectURI, state string, scope util.StringSet) (*GrantRequest, error) {
+func (client *Client) CreateGrantRequest(responseType, redirectURI, state string, scope util.StringSet, returnTo string) (*GrantRequest, error) {
 	if !(responseType == "code" || responseType == "token" || responseType == "owner" || responseType == "client") {
 		return nil, errors.New("Response type expected to be one of the following: 'code', 'token', 'owner', 'client'")
 	}
-	if !client.RedirectURIs.Contains(redirectURI) {
+	if !client.acceptsRedirectURI(redirectURI) {
 		return nil, fmt.Errorf("Redirect URI invalid: '%s'", redirectURI)
 	}
 	if !CheckScope(scope) {
@@ -228,21 +278,183 @@ func (client *Client) CreateGrantRequest(responseType, redirectURI, state string
 	if scope.Intersect(client.ScopeBlacklist).Len() > 0 {
 		return nil, errors.New("Blacklisted scope")
 	}
+	if !client.AllowsScope(scope) {
+		return nil, errors.New("Scope not allowed for this client")
+	}
 	if state == "" {
 		return nil, errors.New("Missing client state")
 	}
+	if err := client.ValidateReturnTo(redirectURI, returnTo); err != nil {
+		return nil, err
+	}
 
 	request := &GrantRequest{
 		GrantType:      responseType,
 		RedirectURI:    redirectURI,
 		State:          state,
 		ScopeRequested: scope,
+		ReturnTo:       returnTo,
 		ClientUUID:     client.UUID}
 	err := request.Create()
 
 	return request, err
 }
 
+// ValidateReturnTo checks the URL a user is sent back to after a grant request with the given
+// redirect URI. Only absolute http(s) URLs with the origin of gin-auth, of the redirect URI or of
+// one of the current redirect URIs of the client are accepted. Empty URLs are always valid.
+func (client *Client) ValidateReturnTo(redirectURI, returnTo string) error {
+	if returnTo == "" {
+		return nil
+	}
+	if len(returnTo) > maxReturnToLength {
+		return errors.New("Return URL too long")
+	}
+
+	origin, ok := urlOrigin(returnTo)
+	if ok {
+		accepted := util.NewStringSet(conf.GetServerConfig().BaseURL, redirectURI).Union(client.RedirectURIs)
+		for uri := range accepted {
+			if o, isURL := urlOrigin(uri); isURL && o == origin {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("Return URL invalid: '%s'", returnTo)
+}
+
+// BindNetwork returns the network an access token issued to a requester with the given IP address
+// is bound to. Clients without token binding return an invalid NullString. Clients with the binding
+// 'ip' bind tokens to the single address of the requester, clients with a network in CIDR notation
+// bind tokens to this network and refuse requesters from outside of the network with an error.
+func (client *Client) BindNetwork(ip string) (sql.NullString, error) {
+	if client.TokenBinding == "" {
+		return sql.NullString{}, nil
+	}
+
+	addr := util.NormalizeIP(ip)
+	if addr == nil {
+		return sql.NullString{}, errors.New("Unable to determine the address of the requester")
+	}
+
+	if client.TokenBinding == "ip" {
+		bits := 128
+		if addr.To4() != nil {
+			bits = 32
+		}
+		network := &net.IPNet{IP: addr, Mask: net.CIDRMask(bits, bits)}
+		return sql.NullString{String: network.String(), Valid: true}, nil
+	}
+
+	_, network, err := net.ParseCIDR(client.TokenBinding)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	if !network.Contains(addr) {
+		return sql.NullString{}, errors.New("Requester is not within the network of the client")
+	}
+
+	return sql.NullString{String: network.String(), Valid: true}, nil
+}
+
+// loopbackHost returns the host of a URL without port if it refers to the local machine.
+func loopbackHost(u *url.URL) (string, bool) {
+	host := u.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if host == "localhost" {
+		return host, true
+	}
+	ip := net.ParseIP(host)
+	return host, ip != nil && ip.IsLoopback()
+}
+
+// matchesDevelopmentRedirectURI checks whether a redirect URI matches one of the given redirect URIs
+// registered for a development client. Redirect URIs on localhost or a loopback address match with
+// any port and with both schemes http and https, path and query have to be equal.
+func matchesDevelopmentRedirectURI(registered util.StringSet, uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.User != nil || u.Fragment != "" {
+		return false
+	}
+	host, ok := loopbackHost(u)
+	if !ok {
+		return false
+	}
+
+	for r := range registered {
+		reg, err := url.Parse(r)
+		if err != nil || (reg.Scheme != "http" && reg.Scheme != "https") {
+			continue
+		}
+		regHost, ok := loopbackHost(reg)
+		if ok && regHost == host && reg.Path == u.Path && reg.RawQuery == u.RawQuery {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeAllowed returns the allowed scope as non nil set.
+func (client *Client) scopeAllowed() util.StringSet {
+	if client.ScopeAllowed == nil {
+		return util.NewStringSet()
+	}
+	return client.ScopeAllowed
+}
+
+// postLogoutRedirectURIs returns the post logout redirect URIs as non nil set.
+func (client *Client) postLogoutRedirectURIs() util.StringSet {
+	if client.PostLogoutRedirectURIs == nil {
+		return util.NewStringSet()
+	}
+	return client.PostLogoutRedirectURIs
+}
+
+// ListFrontChannelLogoutURIs returns the front-channel logout URIs of all clients which were
+// approved by the account or hold access tokens for the account.
+func ListFrontChannelLogoutURIs(accountUUID string) []string {
+	const q = `SELECT DISTINCT frontChannelLogoutURI FROM Clients
+	           WHERE frontChannelLogoutURI <> '' AND uuid IN (
+	             SELECT clientUUID FROM ClientApprovals WHERE accountUUID = $1
+	             UNION SELECT clientUUID FROM AccessTokens WHERE accountUUID = $1)
+	           ORDER BY frontChannelLogoutURI`
+
+	uris := make([]string, 0)
+	err := database.Select(&uris, q, accountUUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return uris
+}
+
+// checkTokenBinding validates the token binding of a client.
+func (client *Client) checkTokenBinding() error {
+	if client.TokenBinding == "" || client.TokenBinding == "ip" {
+		return nil
+	}
+	_, _, err := net.ParseCIDR(client.TokenBinding)
+	if err != nil {
+		return fmt.Errorf("Invalid token binding for client '%s': '%s'", client.Name, client.TokenBinding)
+	}
+	return nil
+}
+
+// checkPublisher checks the homepage and the logo of the client. The homepage must be an absolute
+// http(s) URL, the logo is embedded in the consent page and must therefore be served via https.
+func (client *Client) checkPublisher() error {
+	if _, ok := urlOrigin(client.Homepage); client.Homepage != "" && !ok {
+		return fmt.Errorf("Invalid homepage for client '%s': '%s'", client.Name, client.Homepage)
+	}
+	if origin, ok := urlOrigin(client.LogoURL); client.LogoURL != "" && (!ok || !strings.HasPrefix(origin, "https://")) {
+		return fmt.Errorf("Invalid logo URL for client '%s': '%s'", client.Name, client.LogoURL)
+	}
+	return nil
+}
+
 // delete removes a client from a database via a transaction.
 func (client *Client) delete(tx *sqlx.Tx) error {
 	const q = `DELETE FROM Clients c WHERE c.uuid=$1`
@@ -254,21 +466,28 @@ func (client *Client) delete(tx *sqlx.Tx) error {
 
 // create stores a new client in the database.
 func (client *Client) create(tx *sqlx.Tx) error {
-	const q = `INSERT INTO Clients (uuid, name, secret, scopeWhitelist, scopeBlacklist, redirectURIs, createdAt, updatedAt)
-	           VALUES ($1, $2, $3, $4, $5, $6, now(), now())
+	const q = `INSERT INTO Clients (uuid, name, secret, scopeWhitelist, scopeBlacklist, redirectURIs, tokenBinding,
+	                                firstParty, postLogoutRedirectURIs, frontChannelLogoutURI, authMethod, jwks,
+	                                scopeAllowed, development, skipConsent, publisherName, homepage, logoURL, verified,
+	                                resourceServer, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19,
+	                   $20, now(), now())
 	           RETURNING *`
-	const qScope = `INSERT INTO ClientScopeProvided (clientUUID, name, description)
-	                VALUES ($1, $2, $3)`
+	const qScope = `INSERT INTO ClientScopeProvided (clientUUID, name, description, version)
+	                VALUES ($1, $2, $3, $4)`
 
 	if client.UUID == "" {
 		client.UUID = uuid.NewRandom().String()
 	}
 
 	err := tx.Get(client, q, client.UUID, client.Name, client.Secret, client.ScopeWhitelist,
-		client.ScopeBlacklist, client.RedirectURIs)
+		client.ScopeBlacklist, client.RedirectURIs, client.TokenBinding, client.FirstParty,
+		client.postLogoutRedirectURIs(), client.FrontChannelLogoutURI, client.AuthMethod, client.JWKS,
+		client.scopeAllowed(), client.Development, client.SkipConsent, client.PublisherName, client.Homepage,
+		client.LogoURL, client.Verified, client.ResourceServer)
 	if err == nil {
 		for k, v := range client.ScopeProvidedMap {
-			_, err = tx.Exec(qScope, client.UUID, k, v)
+			_, err = tx.Exec(qScope, client.UUID, k, v, client.ScopeVersion(k))
 			if err != nil {
 				break
 			}
@@ -289,12 +508,12 @@ func (client *Client) deleteScope(tx *sqlx.Tx) error {
 
 // createScope adds all client scopes from a Client to the database.
 func (client *Client) createScope(tx *sqlx.Tx) error {
-	const qScope = `INSERT INTO ClientScopeProvided (clientUUID, name, description)
-	                VALUES ($1, $2, $3)`
+	const qScope = `INSERT INTO ClientScopeProvided (clientUUID, name, description, version)
+	                VALUES ($1, $2, $3, $4)`
 
 	var err error
 	for k, v := range client.ScopeProvidedMap {
-		_, err = tx.Exec(qScope, client.UUID, k, v)
+		_, err = tx.Exec(qScope, client.UUID, k, v, client.ScopeVersion(k))
 		if err != nil {
 			break
 		}
@@ -306,7 +525,10 @@ func (client *Client) createScope(tx *sqlx.Tx) error {
 // updates all client database fields and adds new scopes with data from this Client.
 func (client *Client) update(tx *sqlx.Tx) error {
 	const q = `UPDATE Clients
-	           SET name=$2, secret=$3, scopeWhitelist=$4, scopeBlacklist=$5, redirectURIs=$6, updatedAt=now()
+	           SET name=$2, secret=$3, scopeWhitelist=$4, scopeBlacklist=$5, redirectURIs=$6, tokenBinding=$7,
+	               firstParty=$8, postLogoutRedirectURIs=$9, frontChannelLogoutURI=$10, authMethod=$11, jwks=$12,
+	               scopeAllowed=$13, development=$14, skipConsent=$15, publisherName=$16, homepage=$17,
+	               logoURL=$18, verified=$19, resourceServer=$20, updatedAt=now()
 	           WHERE uuid=$1`
 
 	err := client.deleteScope(tx)
@@ -315,7 +537,10 @@ func (client *Client) update(tx *sqlx.Tx) error {
 	}
 
 	_, err = tx.Exec(q, client.UUID, client.Name, client.Secret, client.ScopeWhitelist,
-		client.ScopeBlacklist, client.RedirectURIs)
+		client.ScopeBlacklist, client.RedirectURIs, client.TokenBinding, client.FirstParty,
+		client.postLogoutRedirectURIs(), client.FrontChannelLogoutURI, client.AuthMethod, client.JWKS,
+		client.scopeAllowed(), client.Development, client.SkipConsent, client.PublisherName, client.Homepage,
+		client.LogoURL, client.Verified, client.ResourceServer)
 	if err != nil {
 		return err
 	}
@@ -327,6 +552,33 @@ func (client *Client) update(tx *sqlx.Tx) error {
 	return err
 }
 
+// markApprovalsStale compares this client with its previous version and marks approvals stale,
+// such that users have to consent again: all approvals of the client if its whitelist changed and
+// approvals of any client which contain a scope with an increased version.
+func (client *Client) markApprovalsStale(tx *sqlx.Tx, previous *Client) error {
+	const qClient = `UPDATE ClientApprovals SET stale=TRUE WHERE clientUUID=$1`
+	const qScope = `UPDATE ClientApprovals SET stale=TRUE WHERE $1 = ANY(scope)`
+
+	if !client.ScopeWhitelist.IsSuperset(previous.ScopeWhitelist) || !previous.ScopeWhitelist.IsSuperset(client.ScopeWhitelist) {
+		conf.GetLogEnv().Err.Warnf("Whitelist of client '%s' changed, approvals require consent again", client.Name)
+		_, err := tx.Exec(qClient, client.UUID)
+		return err
+	}
+
+	for name := range client.ScopeProvidedMap {
+		if _, ok := previous.ScopeProvidedMap[name]; !ok || client.ScopeVersion(name) <= previous.ScopeVersion(name) {
+			continue
+		}
+		conf.GetLogEnv().Err.Warnf("Scope '%s' of client '%s' changed to version %d, approvals require consent again",
+			name, client.Name, client.ScopeVersion(name))
+		_, err := tx.Exec(qScope, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // InitClients loads client information from a yaml configuration file
 // and updates the corresponding entries in the database.
 func InitClients(path string) {
@@ -337,13 +589,28 @@ func InitClients(path string) {
 	}
 
 	confClients := make([]struct {
-		UUID           string            `yaml:"UUID"`
-		Name           string            `yaml:"Name"`
-		Secret         string            `yaml:"Secret"`
-		ScopeProvided  map[string]string `yaml:"ScopeProvided"`
-		ScopeWhitelist []string          `yaml:"ScopeWhitelist"`
-		ScopeBlacklist []string          `yaml:"ScopeBlacklist"`
-		RedirectURIs   []string          `yaml:"RedirectURIs"`
+		UUID                   string            `yaml:"UUID"`
+		Name                   string            `yaml:"Name"`
+		Secret                 string            `yaml:"Secret"`
+		ScopeProvided          map[string]string `yaml:"ScopeProvided"`
+		ScopeVersions          map[string]int    `yaml:"ScopeVersions"`
+		ScopeWhitelist         []string          `yaml:"ScopeWhitelist"`
+		ScopeBlacklist         []string          `yaml:"ScopeBlacklist"`
+		ScopeAllowed           []string          `yaml:"ScopeAllowed"`
+		RedirectURIs           []string          `yaml:"RedirectURIs"`
+		TokenBinding           string            `yaml:"TokenBinding"`
+		FirstParty             bool              `yaml:"FirstParty"`
+		SkipConsent            bool              `yaml:"SkipConsent"`
+		Development            bool              `yaml:"Development"`
+		PostLogoutRedirectURIs []string          `yaml:"PostLogoutRedirectURIs"`
+		FrontChannelLogoutURI  string            `yaml:"FrontChannelLogoutURI"`
+		AuthMethod             string            `yaml:"AuthMethod"`
+		JWKS                   string            `yaml:"JWKS"`
+		Publisher              string            `yaml:"Publisher"`
+		Homepage               string            `yaml:"Homepage"`
+		LogoURL                string            `yaml:"LogoURL"`
+		Verified               bool              `yaml:"Verified"`
+		ResourceServer         bool              `yaml:"ResourceServer"`
 	}, 0)
 
 	err = yaml.Unmarshal(content, &confClients)
@@ -357,9 +624,39 @@ func InitClients(path string) {
 		clients[i].Name = cl.Name
 		clients[i].Secret = cl.Secret
 		clients[i].ScopeProvidedMap = cl.ScopeProvided
+		clients[i].ScopeVersions = cl.ScopeVersions
 		clients[i].ScopeWhitelist = util.NewStringSet(cl.ScopeWhitelist...)
 		clients[i].ScopeBlacklist = util.NewStringSet(cl.ScopeBlacklist...)
+		clients[i].ScopeAllowed = util.NewStringSet(cl.ScopeAllowed...)
 		clients[i].RedirectURIs = util.NewStringSet(cl.RedirectURIs...)
+		clients[i].TokenBinding = cl.TokenBinding
+		clients[i].FirstParty = cl.FirstParty
+		clients[i].SkipConsent = cl.SkipConsent
+		clients[i].Development = cl.Development
+		clients[i].PostLogoutRedirectURIs = util.NewStringSet(cl.PostLogoutRedirectURIs...)
+		clients[i].FrontChannelLogoutURI = cl.FrontChannelLogoutURI
+		clients[i].AuthMethod = cl.AuthMethod
+		clients[i].JWKS = cl.JWKS
+		clients[i].PublisherName = cl.Publisher
+		clients[i].Homepage = cl.Homepage
+		clients[i].LogoURL = cl.LogoURL
+		clients[i].Verified = cl.Verified
+		clients[i].ResourceServer = cl.ResourceServer
+		if clients[i].AuthMethod == "" {
+			clients[i].AuthMethod = ClientAuthSecret
+		}
+		err = clients[i].checkTokenBinding()
+		if err != nil {
+			panic(err)
+		}
+		err = clients[i].checkAuthMethod()
+		if err != nil {
+			panic(err)
+		}
+		err = clients[i].checkPublisher()
+		if err != nil {
+			panic(err)
+		}
 	}
 
 	updateClients(clients)
@@ -394,7 +691,14 @@ func updateClients(confClients []Client) {
 
 	for _, cl := range confClients {
 		if dbClientIDs.Contains(cl.UUID) {
+			previous, _ := GetClient(cl.UUID)
 			err = cl.update(tx)
+			if err == nil && previous != nil {
+				err = cl.updateHistory(tx, previous)
+			}
+			if err == nil && previous != nil {
+				err = cl.markApprovalsStale(tx, previous)
+			}
 		} else {
 			err = cl.create(tx)
 		}
@@ -17,12 +17,14 @@ import (
 
 // ClientApproval contains information about scopes a user has already
 // approved for a certain client. This is needed to implement Trust On
-// First Use (TOFU).
+// First Use (TOFU). Stale approvals refer to a former version of the client or
+// its scopes and require the consent of the user again.
 type ClientApproval struct {
 	UUID        string
 	Scope       util.StringSet
 	ClientUUID  string
 	AccountUUID string
+	Stale       bool
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
@@ -41,6 +43,19 @@ func ListClientApprovals() []ClientApproval {
 	return approvals
 }
 
+// ListAccountClientApprovals returns all client approvals of an account ordered by creation time.
+func ListAccountClientApprovals(accountUUID string) []ClientApproval {
+	const q = `SELECT * FROM ClientApprovals WHERE accountUUID=$1 ORDER BY createdAt`
+
+	approvals := make([]ClientApproval, 0)
+	err := database.Select(&approvals, q, accountUUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return approvals
+}
+
 // GetClientApproval retrieves an approval with a given UUID.
 // Returns false if no matching approval exists.
 func GetClientApproval(uuid string) (*ClientApproval, bool) {
@@ -73,11 +88,11 @@ func (app *ClientApproval) Create() error {
 // New values for CreatedAt will be ignored. UpdatedAt will be set
 // automatically to the current time.
 func (app *ClientApproval) Update() error {
-	const q = `UPDATE ClientApprovals SET (scope, clientUUID, accountUUID, updatedAt) = ($1, $2, $3, now())
-	           WHERE uuid=$4
+	const q = `UPDATE ClientApprovals SET (scope, clientUUID, accountUUID, stale, updatedAt) = ($1, $2, $3, $4, now())
+	           WHERE uuid=$5
 	           RETURNING *`
 
-	return database.Get(app, q, app.Scope, app.ClientUUID, app.AccountUUID, app.UUID)
+	return database.Get(app, q, app.Scope, app.ClientUUID, app.AccountUUID, app.Stale, app.UUID)
 }
 
 // Delete removes an approval from the database.
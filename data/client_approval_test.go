@@ -28,6 +28,18 @@ func TestListClientApprovals(t *testing.T) {
 	}
 }
 
+func TestListAccountClientApprovals(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	if len(ListAccountClientApprovals(uuidAlice)) != 2 {
+		t.Error("Two approvals of alice expected")
+	}
+	if len(ListAccountClientApprovals(uuidBob)) != 0 {
+		t.Error("No approvals of bob expected")
+	}
+}
+
 func TestGetClientApproval(t *testing.T) {
 	defer util.FailOnPanic(t)
 	InitTestDb(t)
@@ -0,0 +1,114 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Methods used by clients to authenticate at the token endpoint (see RFC 7523 and OpenID Connect Core):
+// the client secret itself, a JWT signed with a private key whose public key is registered for the client
+// or a JWT signed with the client secret.
+const (
+	ClientAuthSecret        = "client_secret"
+	ClientAuthPrivateKeyJWT = "private_key_jwt"
+	ClientAuthSecretJWT     = "client_secret_jwt"
+)
+
+// Maximum time until a client assertion expires
+const maxClientAssertionLifeTime = 10 * time.Minute
+
+// AcceptsSecret returns true if the client authenticates with its secret and the given secret matches.
+// Clients which authenticate with assertions never accept their secret.
+func (client *Client) AcceptsSecret(secret string) bool {
+	if client.AuthMethod != ClientAuthSecret || client.Secret == "" {
+		return false
+	}
+	return util.EqualTokens(secret, client.Secret)
+}
+
+// VerifyAssertion authenticates the client using a signed JWT (client assertion). The issuer and subject of
+// the JWT must be the client id, the audience must contain the given audience (the URL of the token endpoint)
+// and the JWT must expire within a few minutes. Each JWT is only accepted once.
+func (client *Client) VerifyAssertion(assertion, audience string) error {
+	const q = `INSERT INTO ClientAssertions (clientUUID, jti, expires) VALUES ($1, $2, $3)
+	           ON CONFLICT (clientUUID, jti) DO NOTHING`
+
+	jwt, err := util.ParseJWT(assertion)
+	if err != nil {
+		return err
+	}
+
+	switch client.AuthMethod {
+	case ClientAuthPrivateKeyJWT:
+		keys, err := util.ParseJWKSet(client.JWKS)
+		if err != nil {
+			return err
+		}
+		err = jwt.VerifyKeys(keys)
+		if err != nil {
+			return err
+		}
+	case ClientAuthSecretJWT:
+		err = jwt.VerifyHMAC([]byte(client.Secret))
+		if err != nil {
+			return err
+		}
+	default:
+		return errors.New("The client does not authenticate with assertions")
+	}
+
+	if jwt.Claims.Issuer != client.Name || jwt.Claims.Subject != client.Name {
+		return errors.New("Issuer and subject of the assertion must be the client id")
+	}
+	if audience == "" || !jwt.Claims.Audience.Contains(audience) {
+		return errors.New("Invalid audience of the assertion")
+	}
+	err = jwt.ValidateTime(maxClientAssertionLifeTime)
+	if err != nil {
+		return err
+	}
+	if jwt.Claims.ID == "" || len(jwt.Claims.ID) > 512 {
+		return errors.New("Invalid assertion id")
+	}
+
+	res, err := database.Exec(q, client.UUID, jwt.Claims.ID, time.Unix(jwt.Claims.ExpiresAt, 0))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("The assertion was already used")
+	}
+	return nil
+}
+
+// checkAuthMethod validates the authentication method of a client and the keys or secret it requires.
+func (client *Client) checkAuthMethod() error {
+	switch client.AuthMethod {
+	case ClientAuthSecret, ClientAuthSecretJWT:
+		if client.Secret == "" {
+			return fmt.Errorf("Client '%s' requires a secret", client.Name)
+		}
+	case ClientAuthPrivateKeyJWT:
+		keys, err := util.ParseJWKSet(client.JWKS)
+		if err != nil {
+			return fmt.Errorf("Invalid JWKS for client '%s': %s", client.Name, err)
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("Client '%s' requires at least one public key", client.Name)
+		}
+	default:
+		return fmt.Errorf("Invalid auth method for client '%s': '%s'", client.Name, client.AuthMethod)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+const testTokenEndpoint = "http://localhost:8081/oauth/token"
+
+// signTestAssertion creates a client assertion signed with an *rsa.PrivateKey (RS256) or a secret (HS256).
+func signTestAssertion(t *testing.T, claims map[string]interface{}, key interface{}) string {
+	alg := "HS256"
+	if _, ok := key.(*rsa.PrivateKey); ok {
+		alg = "RS256"
+	}
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var sig []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(input))
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+	case string:
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func testAssertionClaims(client, jti string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss": client, "sub": client, "aud": testTokenEndpoint, "jti": jti,
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	}
+}
+
+func TestClient_VerifyAssertion(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	// secret based assertion
+	database.MustExec(`UPDATE Clients SET authMethod = $1 WHERE name = 'wb'`, ClientAuthSecretJWT)
+	client, _ := GetClientByName("wb")
+	if client.AcceptsSecret("secret") {
+		t.Error("Client with assertions should not accept its secret")
+	}
+
+	err := client.VerifyAssertion(signTestAssertion(t, testAssertionClaims("wb", "1"), "secret"), testTokenEndpoint)
+	if err != nil {
+		t.Error(err)
+	}
+	err = client.VerifyAssertion(signTestAssertion(t, testAssertionClaims("wb", "1"), "secret"), testTokenEndpoint)
+	if err == nil {
+		t.Error("Replayed assertion should be rejected")
+	}
+	err = client.VerifyAssertion(signTestAssertion(t, testAssertionClaims("wb", "2"), "wrong"), testTokenEndpoint)
+	if err == nil {
+		t.Error("Assertion with wrong secret should be rejected")
+	}
+	err = client.VerifyAssertion(signTestAssertion(t, testAssertionClaims("gin", "3"), "secret"), testTokenEndpoint)
+	if err == nil {
+		t.Error("Assertion with wrong issuer should be rejected")
+	}
+	err = client.VerifyAssertion(signTestAssertion(t, testAssertionClaims("wb", "4"), "secret"), "http://example.com")
+	if err == nil {
+		t.Error("Assertion with wrong audience should be rejected")
+	}
+	claims := testAssertionClaims("wb", "5")
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	err = client.VerifyAssertion(signTestAssertion(t, claims, "secret"), testTokenEndpoint)
+	if err == nil {
+		t.Error("Assertion with late expiry should be rejected")
+	}
+
+	// private key based assertion
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	jwks := fmt.Sprintf(`{"keys": [{"kty": "RSA", "n": "%s", "e": "%s"}]}`, b64(key.N), b64(big.NewInt(int64(key.E))))
+	database.MustExec(`UPDATE Clients SET authMethod = $1, jwks = $2 WHERE name = 'gin'`, ClientAuthPrivateKeyJWT, jwks)
+	client, _ = GetClientByName("gin")
+	if err = client.checkAuthMethod(); err != nil {
+		t.Error(err)
+	}
+
+	err = client.VerifyAssertion(signTestAssertion(t, testAssertionClaims("gin", "1"), key), testTokenEndpoint)
+	if err != nil {
+		t.Error(err)
+	}
+	err = client.VerifyAssertion(signTestAssertion(t, testAssertionClaims("gin", "2"), "secret"), testTokenEndpoint)
+	if err == nil {
+		t.Error("Secret based assertion should be rejected for private_key_jwt")
+	}
+
+	// plain secret
+	client.AuthMethod = ClientAuthSecret
+	if !client.AcceptsSecret("secret") || client.AcceptsSecret("wrong") {
+		t.Error("Client should only accept its secret")
+	}
+	if client.VerifyAssertion(signTestAssertion(t, testAssertionClaims("gin", "3"), key), testTokenEndpoint) == nil {
+		t.Error("Client without assertions should reject assertions")
+	}
+}
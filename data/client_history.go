@@ -0,0 +1,96 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/jmoiron/sqlx"
+)
+
+// Kinds of client history entries
+const (
+	clientHistoryName        = "name"
+	clientHistoryRedirectURI = "redirect-uri"
+)
+
+// getClientByFormerName returns the client which used the given name before it was renamed.
+// Returns false if there is no such client or if the grace period of the name has passed.
+func getClientByFormerName(name string) (*Client, bool) {
+	const q = `SELECT c.* FROM Clients c JOIN ClientHistory h ON h.clientUUID = c.uuid
+	           WHERE h.kind = 'name' AND h.value = $1 AND h.expires > $2`
+
+	client, ok := getClient(q, name, expiryTime())
+	if ok {
+		conf.GetLogEnv().Err.Warnf("Client '%s' was requested by its former name '%s'", client.Name, name)
+	}
+	return client, ok
+}
+
+// acceptsRedirectURI checks whether the redirect URI is one of the current redirect URIs of the client
+// or a former redirect URI, which is still within its grace period. The use of former URIs is logged.
+// Development clients additionally accept their localhost redirect URIs with any port and scheme.
+func (client *Client) acceptsRedirectURI(uri string) bool {
+	const q = `SELECT EXISTS (SELECT 1 FROM ClientHistory
+	                         WHERE clientUUID = $1 AND kind = 'redirect-uri' AND value = $2 AND expires > $3)`
+
+	if client.RedirectURIs.Contains(uri) {
+		return true
+	}
+	if client.Development && matchesDevelopmentRedirectURI(client.RedirectURIs, uri) {
+		return true
+	}
+
+	var former bool
+	err := database.Get(&former, q, client.UUID, uri, expiryTime())
+	if err != nil {
+		panic(err)
+	}
+	if former {
+		conf.GetLogEnv().Err.Warnf("Client '%s' used its former redirect URI '%s'", client.Name, uri)
+	}
+	return former
+}
+
+// updateHistory keeps the name and the redirect URIs of the previous version of a client, which are
+// no longer used by the client, for their configured grace periods. Nothing is kept if the grace period
+// is zero. Entries which are used again are removed.
+func (client *Client) updateHistory(tx *sqlx.Tx, previous *Client) error {
+	const qAdd = `INSERT INTO ClientHistory (clientUUID, kind, value, expires, createdAt)
+	              VALUES ($1, $2, $3, $4, $5)
+	              ON CONFLICT (clientUUID, kind, value) DO UPDATE SET expires = EXCLUDED.expires`
+	const qName = `DELETE FROM ClientHistory WHERE kind = 'name' AND value = $1`
+	const qURIs = `DELETE FROM ClientHistory WHERE clientUUID = $1 AND kind = 'redirect-uri' AND value = ANY($2)`
+
+	now := util.Now()
+	settings := conf.GetClientHistory()
+
+	if previous.Name != client.Name && settings.GracePeriod > 0 {
+		_, err := tx.Exec(qAdd, client.UUID, clientHistoryName, previous.Name, now.Add(settings.GracePeriod), now)
+		if err != nil {
+			return err
+		}
+	}
+	if settings.RedirectURIGracePeriod > 0 {
+		expires := now.Add(settings.RedirectURIGracePeriod)
+		for uri := range previous.RedirectURIs.Difference(client.RedirectURIs) {
+			_, err := tx.Exec(qAdd, client.UUID, clientHistoryRedirectURI, uri, expires, now)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := tx.Exec(qName, client.Name)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(qURIs, client.UUID, client.RedirectURIs)
+	return err
+}
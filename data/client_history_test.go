@@ -0,0 +1,87 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestGetClientByFormerName(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	client, ok := GetClientByName("workbench")
+	if !ok {
+		t.Fatal("Client expected to be found by its former name")
+	}
+	if client.UUID != uuidClientWB {
+		t.Errorf("Client UUID was expected to be '%s'", uuidClientWB)
+	}
+
+	if _, ok = GetClientByName("gin-old"); ok {
+		t.Error("Former name after grace period should not be accepted")
+	}
+}
+
+func TestClient_acceptsRedirectURI(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	client, _ := GetClient(uuidClientWB)
+	if !client.acceptsRedirectURI("https://localhost:8081/login") {
+		t.Error("Current redirect URI expected to be accepted")
+	}
+	if !client.acceptsRedirectURI("https://localhost:8081/oauth/login") {
+		t.Error("Former redirect URI expected to be accepted")
+	}
+	if client.acceptsRedirectURI("https://localhost:8081/other") {
+		t.Error("Unknown redirect URI should not be accepted")
+	}
+
+	_, err := client.CreateGrantRequest("code", "https://localhost:8081/oauth/login", "state", util.NewStringSet("repo-read"), "")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestClient_updateHistory(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	clients := ListClients()
+	for i := range clients {
+		if clients[i].UUID == uuidClientGin {
+			clients[i].Name = "gin-renamed"
+			clients[i].RedirectURIs = util.NewStringSet("https://localhost:8081/login")
+		}
+		if clients[i].UUID == uuidClientWB {
+			clients[i].RedirectURIs = util.NewStringSet("https://localhost:8081/login", "https://localhost:8081/oauth/login")
+		}
+	}
+	updateClients(clients)
+
+	client, ok := GetClientByName("gin")
+	if !ok || client.Name != "gin-renamed" {
+		t.Error("Client expected to be found by its former name")
+	}
+	if client.acceptsRedirectURI("http://localhost:8080/notice") {
+		t.Error("Removed redirect URI should not be accepted without a configured grace period")
+	}
+
+	var count int
+	err := database.Get(&count, `SELECT count(*) FROM ClientHistory WHERE clientUUID = $1`, uuidClientWB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Redirect URI which is used again expected to be removed from history, %d entries left", count)
+	}
+}
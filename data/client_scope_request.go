@@ -0,0 +1,172 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/pborman/uuid"
+)
+
+// ClientScopeRequest is the request of a client for a scope it may not yet request, which
+// has to be granted by an administrator. The states are those of account scope requests,
+// except that client scope requests need no confirmation and start as pending.
+type ClientScopeRequest struct {
+	UUID       string
+	ClientUUID string
+	Scope      string
+	Reason     string
+	State      string
+	DecidedBy  sql.NullString
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// ListPendingClientScopeRequests returns all client scope requests waiting for a decision
+// by an administrator, oldest first.
+func ListPendingClientScopeRequests() []ClientScopeRequest {
+	const q = `SELECT * FROM ClientScopeRequests WHERE state=$1 ORDER BY createdAt, uuid`
+
+	requests := make([]ClientScopeRequest, 0)
+	err := database.Select(&requests, q, ScopeRequestPending)
+	if err != nil {
+		panic(err)
+	}
+
+	return requests
+}
+
+// GetClientScopeRequest returns the client scope request with the given UUID.
+// Returns false if no such request exists.
+func GetClientScopeRequest(uuid string) (*ClientScopeRequest, bool) {
+	const q = `SELECT * FROM ClientScopeRequests WHERE uuid=$1`
+
+	request := &ClientScopeRequest{}
+	err := database.Get(request, q, uuid)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return request, err == nil
+}
+
+// ListScopeRequests returns all scope requests of the client, newest first.
+func (client *Client) ListScopeRequests() []ClientScopeRequest {
+	const q = `SELECT * FROM ClientScopeRequests WHERE clientUUID=$1 ORDER BY createdAt DESC, uuid`
+
+	requests := make([]ClientScopeRequest, 0)
+	err := database.Select(&requests, q, client.UUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return requests
+}
+
+// GrantedScope returns all scopes which were granted to the client by an administrator.
+func (client *Client) GrantedScope() util.StringSet {
+	const q = `SELECT DISTINCT scope FROM ClientScopeRequests WHERE clientUUID=$1 AND state=$2`
+
+	scopes := make([]string, 0)
+	err := database.Select(&scopes, q, client.UUID, ScopeRequestGranted)
+	if err != nil {
+		panic(err)
+	}
+
+	return util.NewStringSet(scopes...)
+}
+
+// AllowedScope returns all scopes the client may request: the scopes of its whitelist,
+// its allowed scopes and the scopes granted by an administrator.
+func (client *Client) AllowedScope() util.StringSet {
+	scopes := client.ScopeWhitelist.Strings()
+	scopes = append(scopes, client.scopeAllowed().Strings()...)
+	scopes = append(scopes, client.GrantedScope().Strings()...)
+	return util.NewStringSet(scopes...)
+}
+
+// AllowsScope checks whether the client may request the scope. Without strict
+// client scopes all scopes are allowed, which are not blacklisted.
+func (client *Client) AllowsScope(scope util.StringSet) bool {
+	if !conf.GetClientScopes().Strict {
+		return true
+	}
+	return client.AllowedScope().IsSuperset(scope)
+}
+
+// RequestScope creates a pending request of the client for a scope, which it may not yet request.
+func (client *Client) RequestScope(scope, reason string) (*ClientScopeRequest, error) {
+	const qOpen = `SELECT count(*) FROM ClientScopeRequests WHERE clientUUID=$1 AND scope=$2 AND state=$3`
+	const q = `INSERT INTO ClientScopeRequests (uuid, clientUUID, scope, reason, state, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, $5, now(), now())
+	           RETURNING *`
+
+	reason = strings.TrimSpace(reason)
+	fieldErrors := make(map[string]string)
+	if !CheckScope(util.NewStringSet(scope)) || client.ScopeBlacklist.Contains(scope) {
+		fieldErrors["scope"] = fmt.Sprintf("The scope '%s' can not be requested", scope)
+	} else if client.AllowedScope().Contains(scope) {
+		fieldErrors["scope"] = fmt.Sprintf("The scope '%s' is already allowed", scope)
+	} else {
+		var open int
+		err := database.Get(&open, qOpen, client.UUID, scope, ScopeRequestPending)
+		if err != nil {
+			return nil, err
+		}
+		if open > 0 {
+			fieldErrors["scope"] = fmt.Sprintf("The scope '%s' was already requested", scope)
+		}
+	}
+	if reason == "" {
+		fieldErrors["reason"] = "Please explain why the client needs the scope"
+	} else if len(reason) > maxScopeRequestReason {
+		fieldErrors["reason"] = fmt.Sprintf("Please use at most %d characters", maxScopeRequestReason)
+	}
+	if len(fieldErrors) > 0 {
+		return nil, &util.ValidationError{Message: "Invalid scope request", FieldErrors: fieldErrors}
+	}
+
+	request := &ClientScopeRequest{}
+	err := database.Get(request, q, uuid.NewRandom().String(), client.UUID, scope, reason, ScopeRequestPending)
+	return request, err
+}
+
+// Grant grants the scope of a pending request to the client, thus the client may request it
+// from now on. The given UUID identifies the account of the administrator who granted the scope.
+func (req *ClientScopeRequest) Grant(grantedBy string) error {
+	return req.decide(ScopeRequestGranted, grantedBy)
+}
+
+// Reject rejects a pending request. The given UUID identifies the account of the
+// administrator who rejected the request.
+func (req *ClientScopeRequest) Reject(rejectedBy string) error {
+	return req.decide(ScopeRequestRejected, rejectedBy)
+}
+
+// decide sets the final state of a pending request.
+func (req *ClientScopeRequest) decide(state, decidedBy string) error {
+	const q = `UPDATE ClientScopeRequests SET (state, decidedBy, updatedAt) = ($1, $2, now())
+	           WHERE uuid=$3 AND state=$4`
+
+	req.DecidedBy = sql.NullString{String: decidedBy, Valid: decidedBy != ""}
+	res, err := database.Exec(q, state, req.DecidedBy, req.UUID, ScopeRequestPending)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return conflictError("Scope request is not pending")
+	}
+	req.State = state
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestClient_AllowsScope(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	client, _ := GetClient(uuidClientWB)
+	if !client.AllowsScope(util.NewStringSet("account-read", "repo-write")) {
+		t.Error("Whitelisted and allowed scope expected to be allowed")
+	}
+	if client.AllowsScope(util.NewStringSet("account-create")) {
+		t.Error("Scope expected not to be allowed")
+	}
+
+	_, err := client.CreateGrantRequest("code", "https://localhost:8081/login", "state", util.NewStringSet("account-create"), "")
+	if err == nil {
+		t.Error("Grant request for a scope which is not allowed should fail")
+	}
+
+	conf.GetClientScopes().Strict = false
+	defer func() { conf.GetClientScopes().Strict = true }()
+	if !client.AllowsScope(util.NewStringSet("account-create")) {
+		t.Error("All scopes expected to be allowed without strict client scopes")
+	}
+}
+
+func TestClient_RequestScope(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	client, _ := GetClient(uuidClientWB)
+
+	invalid := []struct {
+		scope  string
+		reason string
+	}{
+		{"does-not-exist", "reason"},
+		{"account-admin", "blacklisted"},
+		{"repo-read", "already allowed"},
+		{"account-create", " "},
+	}
+	for _, c := range invalid {
+		if _, err := client.RequestScope(c.scope, c.reason); err == nil {
+			t.Errorf("Request for scope '%s' with reason '%s' should fail", c.scope, c.reason)
+		}
+	}
+
+	request, err := client.RequestScope("account-create", "Registration of accounts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.State != ScopeRequestPending || request.ClientUUID != uuidClientWB {
+		t.Errorf("Unexpected scope request: %+v", request)
+	}
+	if _, err = client.RequestScope("account-create", "again"); err == nil {
+		t.Error("Scope which was already requested should not be requested again")
+	}
+	if len(client.ListScopeRequests()) != 2 {
+		t.Errorf("Client expected to have 2 scope requests but has %d", len(client.ListScopeRequests()))
+	}
+	if len(ListPendingClientScopeRequests()) != 2 {
+		t.Errorf("Expected 2 pending requests but was %d", len(ListPendingClientScopeRequests()))
+	}
+
+	err = request.Grant(uuidAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !client.AllowsScope(util.NewStringSet("account-create")) {
+		t.Error("Granted scope expected to be allowed")
+	}
+	if err = request.Reject(uuidAlice); KindOf(err) != ErrConflict {
+		t.Error("Decided request should not be decided again")
+	}
+
+	pending, ok := GetClientScopeRequest("c5f1e8a0-3b5d-4c1e-9f2a-7d6b8e4a1c01")
+	if !ok {
+		t.Fatal("Scope request expected to exist")
+	}
+	err = pending.Reject(uuidAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.AllowsScope(util.NewStringSet("ssh-cert")) {
+		t.Error("Rejected scope expected not to be allowed")
+	}
+}
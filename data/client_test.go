@@ -53,6 +53,12 @@ func TestGetClient(t *testing.T) {
 	if client.Name != "gin" {
 		t.Error("Client name was expected to be 'gin'")
 	}
+	if client.PublisherName != "G-Node" || !client.Verified {
+		t.Errorf("Client expected to be published by a verified publisher: %+v", client)
+	}
+	if !client.ResourceServer {
+		t.Error("Client expected to be a resource server")
+	}
 
 	_, ok = GetClient("doesNotExist")
 	if ok {
@@ -229,6 +235,104 @@ func TestClient_ApprovalForAccount_Approve(t *testing.T) {
 	}
 }
 
+func TestClient_markApprovalsStale(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	const uuidClientWB = "177c56a4-57b4-4baf-a1a7-04f3d8e5b276"
+
+	stale := func(clientUUID string) bool {
+		client, _ := GetClient(clientUUID)
+		approval, ok := client.ApprovalForAccount(uuidAlice)
+		if !ok {
+			t.Fatal("Approval does not exist")
+		}
+		return approval.Stale
+	}
+	mark := func(client, previous *Client) {
+		tx := database.MustBegin()
+		err := client.markApprovalsStale(tx, previous)
+		if err != nil {
+			tx.Rollback()
+			t.Fatal(err)
+		}
+		tx.Commit()
+	}
+
+	// unchanged client
+	previous, _ := GetClient(uuidClientGin)
+	client, _ := GetClient(uuidClientGin)
+	if client.ScopeVersion("repo-write") != 1 {
+		t.Errorf("Scope version 1 expected but was %d", client.ScopeVersion("repo-write"))
+	}
+	mark(client, previous)
+	if stale(uuidClientGin) || stale(uuidClientWB) {
+		t.Error("Approvals should not be stale")
+	}
+
+	// new scope version affects approvals of all clients
+	client.ScopeVersions["repo-write"] = 2
+	mark(client, previous)
+	if !stale(uuidClientGin) || !stale(uuidClientWB) {
+		t.Error("Approvals with scope 'repo-write' should be stale")
+	}
+
+	// renew the consent
+	err := client.Approve(uuidAlice, util.NewStringSet("repo-write"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	approval, _ := client.ApprovalForAccount(uuidAlice)
+	if approval.Stale || approval.Scope.Len() != 1 || !approval.Scope.Contains("repo-write") {
+		t.Errorf("Renewed approval expected but was: %+v", approval)
+	}
+
+	// changed whitelist
+	previous, _ = GetClient(uuidClientGin)
+	client.ScopeWhitelist = util.NewStringSet("account-create", "repo-read")
+	mark(client, previous)
+	if !stale(uuidClientGin) {
+		t.Error("Approval should be stale")
+	}
+}
+
+func TestMatchesDevelopmentRedirectURI(t *testing.T) {
+	registered := util.NewStringSet("http://localhost/callback", "http://127.0.0.1:8080/login?app=cli", "https://example.com/login")
+
+	accepted := []string{
+		"http://localhost/callback",
+		"http://localhost:4200/callback",
+		"https://localhost:8443/callback",
+		"http://127.0.0.1:9999/login?app=cli",
+	}
+	for _, uri := range accepted {
+		if !matchesDevelopmentRedirectURI(registered, uri) {
+			t.Errorf("Redirect URI '%s' expected to be accepted", uri)
+		}
+	}
+
+	rejected := []string{
+		"http://localhost:4200/other",
+		"http://127.0.0.1:4200/callback",
+		"http://127.0.0.1:4200/login",
+		"http://localhost.example.com/callback",
+		"http://user@localhost:4200/callback",
+		"https://example.com:8443/login",
+		"http://example.com/login",
+		"ftp://localhost/callback",
+	}
+	for _, uri := range rejected {
+		if matchesDevelopmentRedirectURI(registered, uri) {
+			t.Errorf("Redirect URI '%s' expected to be rejected", uri)
+		}
+	}
+
+	client := &Client{Development: true, RedirectURIs: registered}
+	if !client.acceptsRedirectURI("http://localhost:3000/callback") {
+		t.Error("Development client expected to accept any port on localhost")
+	}
+}
+
 func TestClient_CreateGrantRequest(t *testing.T) {
 	InitTestDb(t)
 
@@ -243,37 +347,37 @@ func TestClient_CreateGrantRequest(t *testing.T) {
 	validScope := util.NewStringSet("repo-read")
 
 	// Test invalid response type
-	_, err := client.CreateGrantRequest("foo", validRedirectURI, validState, validScope)
+	_, err := client.CreateGrantRequest("foo", validRedirectURI, validState, validScope, "")
 	if err == nil || !strings.Contains(err.Error(), "Response type expected") {
 		t.Error("Error expected")
 	}
 
 	// Test invalid redirect
-	_, err = client.CreateGrantRequest(validResponseType, "https://doesnotexist.com/callback", validState, validScope)
+	_, err = client.CreateGrantRequest(validResponseType, "https://doesnotexist.com/callback", validState, validScope, "")
 	if err == nil || !strings.Contains(err.Error(), "Redirect URI invalid") {
 		t.Error("Error expected")
 	}
 
 	// Test invalid scope
-	_, err = client.CreateGrantRequest(validResponseType, validRedirectURI, validState, util.NewStringSet("foo-read"))
+	_, err = client.CreateGrantRequest(validResponseType, validRedirectURI, validState, util.NewStringSet("foo-read"), "")
 	if err == nil || !strings.Contains(err.Error(), "Invalid scope") {
 		t.Error("Error expected")
 	}
 
 	// Test blacklisted scope
-	_, err = client.CreateGrantRequest(validResponseType, validRedirectURI, validState, util.NewStringSet("account-admin"))
+	_, err = client.CreateGrantRequest(validResponseType, validRedirectURI, validState, util.NewStringSet("account-admin"), "")
 	if err == nil || !strings.Contains(err.Error(), "Blacklisted scope") {
 		t.Error("Error expected")
 	}
 
 	// Test missing client state token
-	_, err = client.CreateGrantRequest(validResponseType, validRedirectURI, "", validScope)
+	_, err = client.CreateGrantRequest(validResponseType, validRedirectURI, "", validScope, "")
 	if err == nil || !strings.Contains(err.Error(), "Missing client state") {
 		t.Error("Error expected")
 	}
 
 	// all OK
-	request, err := client.CreateGrantRequest(validResponseType, validRedirectURI, validState, validScope)
+	request, err := client.CreateGrantRequest(validResponseType, validRedirectURI, validState, validScope, "")
 	if err != nil {
 		t.Error(err)
 	}
@@ -286,6 +390,142 @@ func TestClient_CreateGrantRequest(t *testing.T) {
 	if request.State != validState {
 		t.Error("State does not match")
 	}
+
+	// return URL is stored with the request
+	_, err = client.CreateGrantRequest(validResponseType, validRedirectURI, validState, validScope, "https://example.com/alice")
+	if err == nil {
+		t.Error("Error expected for a foreign return URL")
+	}
+	request, err = client.CreateGrantRequest(validResponseType, validRedirectURI, validState, validScope, "https://localhost:8081/alice/repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, _ := GetGrantRequest(request.Token); stored.ReturnTo != "https://localhost:8081/alice/repo" {
+		t.Errorf("Return URL was not stored: '%s'", stored.ReturnTo)
+	}
+}
+
+func TestClient_ValidateReturnTo(t *testing.T) {
+	InitTestDb(t)
+
+	client, ok := GetClient(uuidClientGin)
+	if !ok {
+		t.Error("Client does not exist")
+	}
+	redirectURI := "https://localhost:8081/login"
+
+	valid := []string{
+		"",
+		"https://localhost:8081/alice/repo?tab=files#readme",
+		"HTTPS://LOCALHOST:8081/alice",
+		conf.GetServerConfig().BaseURL + "/oauth/personal",
+	}
+	for _, returnTo := range valid {
+		if err := client.ValidateReturnTo(redirectURI, returnTo); err != nil {
+			t.Errorf("Return URL '%s' expected to be valid: %s", returnTo, err)
+		}
+	}
+
+	invalid := []string{
+		"/alice/repo",
+		"//example.com/alice",
+		"https://example.com/alice",
+		"https://localhost:8082/alice",
+		"https://user@localhost:8081/alice",
+		"javascript:alert(1)",
+		"https://localhost:8081/" + strings.Repeat("a", maxReturnToLength),
+	}
+	for _, returnTo := range invalid {
+		if err := client.ValidateReturnTo(redirectURI, returnTo); err == nil {
+			t.Errorf("Return URL '%s' expected to be invalid", returnTo)
+		}
+	}
+}
+
+func TestGrantRequest_SetReturnTo(t *testing.T) {
+	InitTestDb(t)
+
+	request, ok := GetGrantRequest("U7JIKKYI")
+	if !ok {
+		t.Fatal("Grant request does not exist")
+	}
+
+	err := request.SetReturnTo("https://example.com/alice")
+	if err == nil {
+		t.Error("Error expected for a foreign return URL")
+	}
+
+	err = request.SetReturnTo("https://localhost:8081/alice/repo")
+	if err != nil {
+		t.Error(err)
+	}
+	request, _ = GetGrantRequest("U7JIKKYI")
+	if request.ReturnTo != "https://localhost:8081/alice/repo" {
+		t.Errorf("Return URL was not stored: '%s'", request.ReturnTo)
+	}
+
+	// updates of the request keep the return URL
+	request.Code.Valid = false
+	err = request.Update()
+	if err != nil {
+		t.Error(err)
+	}
+	if request.ReturnTo != "https://localhost:8081/alice/repo" {
+		t.Error("Return URL expected to survive updates")
+	}
+}
+
+func TestClient_BindNetwork(t *testing.T) {
+	client := &Client{}
+	bound, err := client.BindNetwork("192.0.2.1")
+	if err != nil || bound.Valid {
+		t.Error("Client without token binding should not bind tokens")
+	}
+
+	client.TokenBinding = "ip"
+	bound, err = client.BindNetwork("192.0.2.1")
+	if err != nil {
+		t.Error(err)
+	}
+	if bound.String != "192.0.2.1/32" {
+		t.Errorf("Bound network expected to be '192.0.2.1/32' but was '%s'", bound.String)
+	}
+	bound, _ = client.BindNetwork("2001:db8::1")
+	if bound.String != "2001:db8::1/128" {
+		t.Errorf("Bound network expected to be '2001:db8::1/128' but was '%s'", bound.String)
+	}
+	_, err = client.BindNetwork("")
+	if err == nil {
+		t.Error("Binding to an unknown address should fail")
+	}
+
+	client.TokenBinding = "10.0.0.0/8"
+	bound, err = client.BindNetwork("10.1.2.3")
+	if err != nil {
+		t.Error(err)
+	}
+	if bound.String != "10.0.0.0/8" {
+		t.Errorf("Bound network expected to be '10.0.0.0/8' but was '%s'", bound.String)
+	}
+	_, err = client.BindNetwork("192.0.2.1")
+	if err == nil {
+		t.Error("Binding from outside of the client network should fail")
+	}
+}
+
+func TestListFrontChannelLogoutURIs(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	uris := ListFrontChannelLogoutURIs(uuidAlice)
+	if len(uris) != 2 {
+		t.Errorf("Expected 2 front-channel logout URIs but got %d", len(uris))
+	}
+
+	uris = ListFrontChannelLogoutURIs("doesnotexist")
+	if len(uris) != 0 {
+		t.Error("No front-channel logout URIs expected")
+	}
 }
 
 func TestClientScopeProvided(t *testing.T) {
@@ -346,6 +586,9 @@ func TestClient_create(t *testing.T) {
 	client.Secret = "TestSecret"
 	client.ScopeProvidedMap = map[string]string{testScope: testScope}
 	client.RedirectURIs = util.NewStringSet(testUri)
+	client.PublisherName = "Test Lab"
+	client.Homepage = "https://lab.example.org"
+	client.Verified = true
 
 	tx := database.MustBegin()
 
@@ -383,6 +626,33 @@ func TestClient_create(t *testing.T) {
 		t.Errorf("DB redirectURI '%v' entry does not contain expected entry '%s'",
 			check.RedirectURIs, testUri)
 	}
+	if check.PublisherName != client.PublisherName || check.Homepage != client.Homepage || !check.Verified {
+		t.Errorf("Publisher of DB client does not match: %+v", check)
+	}
+}
+
+func TestClient_checkPublisher(t *testing.T) {
+	valid := []Client{
+		{Name: "empty"},
+		{Name: "http", Homepage: "http://lab.example.org", LogoURL: "https://lab.example.org/logo.png"},
+	}
+	for _, client := range valid {
+		if err := client.checkPublisher(); err != nil {
+			t.Errorf("Publisher of client '%s' expected to be valid: %v", client.Name, err)
+		}
+	}
+
+	invalid := []Client{
+		{Name: "relative", Homepage: "/about"},
+		{Name: "script", Homepage: "javascript:alert(1)"},
+		{Name: "insecure-logo", LogoURL: "http://lab.example.org/logo.png"},
+		{Name: "data-logo", LogoURL: "data:image/png;base64,AAAA"},
+	}
+	for _, client := range invalid {
+		if err := client.checkPublisher(); err == nil {
+			t.Errorf("Publisher of client '%s' expected to be invalid", client.Name)
+		}
+	}
 }
 
 // Tests various correct fails when trying to insert a client into the database.
@@ -0,0 +1,177 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"sync"
+	"time"
+)
+
+// ClientUsage contains the usage of a client on a single day: completed authorizations,
+// issued access tokens, token validations and the number of accounts which used the client.
+type ClientUsage struct {
+	Day              time.Time
+	Authorizations   int64
+	TokensIssued     int64
+	TokenValidations int64
+	UniqueUsers      int64
+}
+
+// ClientUsageTotal contains the summed up usage of a client. UniqueUsers is the number
+// of distinct accounts which used the client within the whole period.
+type ClientUsageTotal struct {
+	ClientName       string
+	Authorizations   int64
+	TokensIssued     int64
+	TokenValidations int64
+	UniqueUsers      int64
+}
+
+type clientUsageKey struct {
+	day        string
+	clientUUID string
+}
+
+type clientUsageCount struct {
+	tokensIssued     int64
+	tokenValidations int64
+}
+
+// Client usage is counted in memory and written to the database together with the usage of accounts.
+var clientUsage = struct {
+	sync.Mutex
+	counts map[clientUsageKey]*clientUsageCount
+}{counts: make(map[clientUsageKey]*clientUsageCount)}
+
+// RecordTokenIssued counts an access token issued to a client.
+func RecordTokenIssued(token *AccessToken) {
+	recordClientUsage(token.ClientUUID, clientUsageCount{tokensIssued: 1})
+}
+
+func recordClientUsage(clientUUID string, add clientUsageCount) {
+	key := clientUsageKey{time.Now().Format("2006-01-02"), clientUUID}
+
+	clientUsage.Lock()
+	defer clientUsage.Unlock()
+
+	count, ok := clientUsage.counts[key]
+	if !ok {
+		count = &clientUsageCount{}
+		clientUsage.counts[key] = count
+	}
+	count.tokensIssued += add.tokensIssued
+	count.tokenValidations += add.tokenValidations
+}
+
+// flushClientUsage adds all client usage counted since the last flush to the daily
+// counters in the database. If writing fails, the counts are kept for the next flush.
+func flushClientUsage() error {
+	clientUsage.Lock()
+	counts := clientUsage.counts
+	clientUsage.counts = make(map[clientUsageKey]*clientUsageCount)
+	clientUsage.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	const q = `INSERT INTO ClientUsage (day, clientUUID, tokensIssued, tokenValidations)
+	           SELECT $1::date, $2::varchar, $3::bigint, $4::bigint
+	           WHERE EXISTS (SELECT 1 FROM Clients WHERE uuid = $2)
+	           ON CONFLICT (day, clientUUID) DO UPDATE
+	           SET tokensIssued = ClientUsage.tokensIssued + EXCLUDED.tokensIssued,
+	               tokenValidations = ClientUsage.tokenValidations + EXCLUDED.tokenValidations`
+
+	tx, err := database.Beginx()
+	if err != nil {
+		restoreClientUsage(counts)
+		return err
+	}
+	for key, count := range counts {
+		_, err := tx.Exec(q, key.day, key.clientUUID, count.tokensIssued, count.tokenValidations)
+		if err != nil {
+			tx.Rollback()
+			restoreClientUsage(counts)
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		restoreClientUsage(counts)
+	}
+	return err
+}
+
+// restoreClientUsage adds counts which could not be written back to the in memory counters.
+func restoreClientUsage(counts map[clientUsageKey]*clientUsageCount) {
+	clientUsage.Lock()
+	defer clientUsage.Unlock()
+
+	for key, add := range counts {
+		count, ok := clientUsage.counts[key]
+		if !ok {
+			clientUsage.counts[key] = add
+			continue
+		}
+		count.tokensIssued += add.tokensIssued
+		count.tokenValidations += add.tokenValidations
+	}
+}
+
+// ListClientUsage returns the daily usage of a client since the given day, latest first.
+// Days without any usage are omitted.
+func ListClientUsage(clientUUID string, since time.Time) []ClientUsage {
+	const q = `SELECT day, SUM(authorizations) AS authorizations, SUM(tokensIssued) AS tokensIssued,
+	                  SUM(tokenValidations) AS tokenValidations, SUM(uniqueUsers) AS uniqueUsers
+	           FROM (SELECT day, completed AS authorizations, 0::bigint AS tokensIssued,
+	                        0::bigint AS tokenValidations, 0::bigint AS uniqueUsers
+	                 FROM GrantRequestStats WHERE clientUUID = $1 AND day >= $2
+	                 UNION ALL
+	                 SELECT day, 0, tokensIssued, tokenValidations, 0
+	                 FROM ClientUsage WHERE clientUUID = $1 AND day >= $2
+	                 UNION ALL
+	                 SELECT day, 0, 0, 0, count(DISTINCT accountUUID)
+	                 FROM UsageCounters WHERE clientUUID = $1 AND day >= $2
+	                 GROUP BY day) u
+	           GROUP BY day
+	           ORDER BY day DESC`
+
+	days := make([]ClientUsage, 0)
+	err := database.Select(&days, q, clientUUID, since.Format("2006-01-02"))
+	if err != nil {
+		panic(err)
+	}
+
+	return days
+}
+
+// ListClientUsageTotals returns the summed up usage of all clients since the given day,
+// ordered by client name. Clients without any usage are included with zero counts.
+func ListClientUsageTotals(since time.Time) []ClientUsageTotal {
+	const q = `SELECT c.name AS clientName,
+	                  (SELECT COALESCE(SUM(s.completed), 0) FROM GrantRequestStats s
+	                   WHERE s.clientUUID = c.uuid AND s.day >= $1) AS authorizations,
+	                  (SELECT COALESCE(SUM(u.tokensIssued), 0) FROM ClientUsage u
+	                   WHERE u.clientUUID = c.uuid AND u.day >= $1) AS tokensIssued,
+	                  (SELECT COALESCE(SUM(u.tokenValidations), 0) FROM ClientUsage u
+	                   WHERE u.clientUUID = c.uuid AND u.day >= $1) AS tokenValidations,
+	                  (SELECT count(DISTINCT u.accountUUID) FROM UsageCounters u
+	                   WHERE u.clientUUID = c.uuid AND u.day >= $1) AS uniqueUsers
+	           FROM Clients c
+	           ORDER BY c.name`
+
+	totals := make([]ClientUsageTotal, 0)
+	err := database.Select(&totals, q, since.Format("2006-01-02"))
+	if err != nil {
+		panic(err)
+	}
+
+	return totals
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestListClientUsage(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	days := ListClientUsage(usageGinUUID, time.Now().AddDate(0, 0, -29))
+	if len(days) != 2 {
+		t.Fatalf("Two days expected but was %d", len(days))
+	}
+	today := days[0]
+	if today.Authorizations != 15 || today.TokensIssued != 25 || today.TokenValidations != 40 || today.UniqueUsers != 2 {
+		t.Errorf("Unexpected usage of today: %v", today)
+	}
+	if days[1].UniqueUsers != 1 || !days[1].Day.Before(today.Day) {
+		t.Errorf("Unexpected usage of yesterday: %v", days[1])
+	}
+
+	days = ListClientUsage(usageGinUUID, time.Now().AddDate(0, 0, -89))
+	if len(days) != 3 {
+		t.Errorf("Three days expected but was %d", len(days))
+	}
+}
+
+func TestListClientUsageTotals(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	totals := ListClientUsageTotals(time.Now().AddDate(0, 0, -29))
+	if len(totals) != 2 {
+		t.Fatalf("Two clients expected but was %d", len(totals))
+	}
+	gin := totals[0]
+	if gin.ClientName != "gin" || gin.Authorizations != 24 || gin.TokensIssued != 37 || gin.TokenValidations != 60 || gin.UniqueUsers != 2 {
+		t.Errorf("Unexpected totals of gin: %v", gin)
+	}
+	if totals[1].ClientName != "wb" || totals[1].TokensIssued != 0 || totals[1].UniqueUsers != 1 {
+		t.Errorf("Unexpected totals of wb: %v", totals[1])
+	}
+}
+
+func TestFlushClientUsage(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	before := ListClientUsage(usageGinUUID, time.Now())[0]
+	RecordTokenIssued(&AccessToken{ClientUUID: usageGinUUID})
+	RecordTokenValidation(&AccessToken{ClientUUID: usageGinUUID})
+	RecordTokenIssued(&AccessToken{ClientUUID: "doesnotexist"})
+
+	err := FlushUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after := ListClientUsage(usageGinUUID, time.Now())[0]
+	if after.TokensIssued < before.TokensIssued+1 || after.TokenValidations < before.TokenValidations+1 {
+		t.Errorf("Unexpected usage after flush: %v", after)
+	}
+
+	err = FlushUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again := ListClientUsage(usageGinUUID, time.Now())[0]; again.TokensIssued != after.TokensIssued {
+		t.Error("Usage should only be written once")
+	}
+}
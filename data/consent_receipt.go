@@ -0,0 +1,92 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/pborman/uuid"
+)
+
+// ConsentReceipt records a single approval given by a user: the client, the approved
+// scope, the version of the privacy policy at that time and when the approval was given.
+// Receipts are kept when the approval or the client is removed.
+type ConsentReceipt struct {
+	UUID          string
+	AccountUUID   string
+	ClientUUID    string
+	ClientName    string
+	Scope         util.StringSet
+	PolicyVersion string
+	CreatedAt     time.Time
+}
+
+// ListConsentReceipts returns all consent receipts of an account, newest first.
+func ListConsentReceipts(accountUUID string) []ConsentReceipt {
+	const q = `SELECT * FROM ConsentReceipts WHERE accountUUID=$1 ORDER BY createdAt DESC`
+
+	receipts := make([]ConsentReceipt, 0)
+	err := database.Select(&receipts, q, accountUUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return receipts
+}
+
+// GetConsentReceipt retrieves a consent receipt with a given UUID.
+// Returns false if no matching receipt exists.
+func GetConsentReceipt(uuid string) (*ConsentReceipt, bool) {
+	const q = `SELECT * FROM ConsentReceipts WHERE uuid=$1`
+
+	receipt := &ConsentReceipt{}
+	err := database.Get(receipt, q, uuid)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return receipt, err == nil
+}
+
+// MarshalJSON implements Marshaler for ConsentReceipt
+func (receipt *ConsentReceipt) MarshalJSON() ([]byte, error) {
+	jsonData := &struct {
+		UUID          string    `json:"uuid"`
+		ClientUUID    string    `json:"client_uuid"`
+		ClientName    string    `json:"client_name"`
+		Scope         []string  `json:"scope"`
+		PolicyVersion string    `json:"policy_version"`
+		CreatedAt     time.Time `json:"created_at"`
+	}{
+		UUID:          receipt.UUID,
+		ClientUUID:    receipt.ClientUUID,
+		ClientName:    receipt.ClientName,
+		Scope:         receipt.Scope.Strings(),
+		PolicyVersion: receipt.PolicyVersion,
+		CreatedAt:     receipt.CreatedAt,
+	}
+	return json.Marshal(jsonData)
+}
+
+// recordConsent stores a receipt for scopes approved by an account for this client
+// together with the current version of the privacy policy.
+func (client *Client) recordConsent(accountUUID string, scope util.StringSet) (*ConsentReceipt, error) {
+	const q = `INSERT INTO ConsentReceipts (uuid, accountUUID, clientUUID, clientName, scope, policyVersion, createdAt)
+	           VALUES ($1, $2, $3, $4, $5, $6, now())
+	           RETURNING *`
+
+	receipt := &ConsentReceipt{}
+	err := database.Get(receipt, q, uuid.NewRandom().String(), accountUUID, client.UUID, client.Name, scope,
+		conf.GetConsent().PolicyVersion)
+	return receipt, err
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+const receiptUUIDAlice = "6a0b6a2e-3b2d-4d6b-9d53-0c1c2f6e8f01"
+
+func TestListConsentReceipts(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	receipts := ListConsentReceipts(uuidAlice)
+	if len(receipts) != 2 {
+		t.Fatalf("Two receipts expected but found %d", len(receipts))
+	}
+	if receipts[0].ClientName != "wb" {
+		t.Error("Newest receipt expected first")
+	}
+	if len(ListConsentReceipts(uuidBob)) != 0 {
+		t.Error("No receipts of bob expected")
+	}
+}
+
+func TestGetConsentReceipt(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	receipt, ok := GetConsentReceipt(receiptUUIDAlice)
+	if !ok {
+		t.Fatal("Receipt does not exist")
+	}
+	if receipt.AccountUUID != uuidAlice || !receipt.Scope.Contains("repo-read") || receipt.PolicyVersion != "2016-01" {
+		t.Error("Unexpected receipt content")
+	}
+
+	b, err := json.Marshal(receipt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"scope":["repo-read","repo-write"]`) {
+		t.Errorf("Unexpected JSON '%s'", string(b))
+	}
+
+	_, ok = GetConsentReceipt("doesNotExist")
+	if ok {
+		t.Error("Receipt should not exist")
+	}
+}
+
+func TestClient_ApproveRecordsConsent(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	client, _ := GetClient(uuidClientGin)
+
+	err := client.Approve(uuidBob, util.NewStringSet("repo-read"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// already approved
+	err = client.Approve(uuidBob, util.NewStringSet("repo-read"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Approve(uuidBob, util.NewStringSet("repo-read", "repo-write"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	receipts := ListConsentReceipts(uuidBob)
+	if len(receipts) != 2 {
+		t.Fatalf("Two receipts expected but found %d", len(receipts))
+	}
+	for _, receipt := range receipts {
+		if receipt.ClientName != "gin" || receipt.PolicyVersion != "2016-01" {
+			t.Error("Unexpected receipt content")
+		}
+	}
+}
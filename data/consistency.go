@@ -0,0 +1,145 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+// consistencyCheck describes an invariant of the data. The query returns a description of each
+// violation, the repair statements remove the violations. Checks without repair statements
+// have to be resolved manually.
+type consistencyCheck struct {
+	name        string
+	description string
+	query       string
+	repair      []string
+}
+
+// consistencyChecks contains all checks in the order they are applied.
+var consistencyChecks = []consistencyCheck{
+	{
+		name:        "access_tokens",
+		description: "Access tokens reference existing accounts and clients",
+		query: `SELECT 'account ' || COALESCE(accountUUID, '-') || ', client ' || clientUUID || ': ' || count(*) || ' tokens'
+		        FROM AccessTokens t
+		        WHERE (accountUUID IS NOT NULL AND NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = t.accountUUID))
+		              OR NOT EXISTS (SELECT 1 FROM Clients c WHERE c.uuid = t.clientUUID)
+		        GROUP BY accountUUID, clientUUID ORDER BY 1`,
+		repair: []string{
+			`DELETE FROM AccessTokens t
+			 WHERE (accountUUID IS NOT NULL AND NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = t.accountUUID))
+			       OR NOT EXISTS (SELECT 1 FROM Clients c WHERE c.uuid = t.clientUUID)`,
+		},
+	},
+	{
+		name:        "refresh_tokens",
+		description: "Refresh tokens reference existing accounts and clients",
+		query: `SELECT 'account ' || accountUUID || ', client ' || clientUUID || ': ' || count(*) || ' tokens'
+		        FROM RefreshTokens t
+		        WHERE NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = t.accountUUID)
+		              OR NOT EXISTS (SELECT 1 FROM Clients c WHERE c.uuid = t.clientUUID)
+		        GROUP BY accountUUID, clientUUID ORDER BY 1`,
+		repair: []string{
+			`DELETE FROM RefreshTokens t
+			 WHERE NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = t.accountUUID)
+			       OR NOT EXISTS (SELECT 1 FROM Clients c WHERE c.uuid = t.clientUUID)`,
+		},
+	},
+	{
+		name:        "sessions",
+		description: "Sessions reference existing accounts",
+		query: `SELECT 'account ' || accountUUID || ': ' || count(*) || ' sessions'
+		        FROM Sessions s WHERE NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = s.accountUUID)
+		        GROUP BY accountUUID ORDER BY 1`,
+		repair: []string{
+			`DELETE FROM Sessions s WHERE NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = s.accountUUID)`,
+		},
+	},
+	{
+		name:        "client_approvals",
+		description: "Client approvals reference existing accounts and clients",
+		query: `SELECT 'account ' || accountUUID || ', client ' || clientUUID
+		        FROM ClientApprovals ca
+		        WHERE NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = ca.accountUUID)
+		              OR NOT EXISTS (SELECT 1 FROM Clients c WHERE c.uuid = ca.clientUUID)
+		        ORDER BY 1`,
+		repair: []string{
+			`DELETE FROM ClientApprovals ca
+			 WHERE NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = ca.accountUUID)
+			       OR NOT EXISTS (SELECT 1 FROM Clients c WHERE c.uuid = ca.clientUUID)`,
+		},
+	},
+	{
+		name:        "ssh_keys",
+		description: "SSH keys reference existing accounts",
+		query: `SELECT 'account ' || accountUUID || ': ' || fingerprint
+		        FROM SSHKeys k WHERE NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = k.accountUUID)
+		        ORDER BY 1`,
+		repair: []string{
+			`DELETE FROM SSHKeys k WHERE NOT EXISTS (SELECT 1 FROM Accounts a WHERE a.uuid = k.accountUUID)`,
+		},
+	},
+	{
+		name:        "duplicate_emails",
+		description: "E-mail addresses are unique regardless of case",
+		query: `SELECT lower(email) || ': ' || string_agg(login, ', ' ORDER BY createdAt)
+		        FROM Accounts GROUP BY lower(email) HAVING count(*) > 1 ORDER BY 1`,
+	},
+	{
+		name:        "duplicate_logins",
+		description: "Logins are unique regardless of case",
+		query: `SELECT lower(login) || ': ' || string_agg(login, ', ' ORDER BY createdAt)
+		        FROM Accounts GROUP BY lower(login) HAVING count(*) > 1 ORDER BY 1`,
+	},
+}
+
+// ConsistencyReport lists the violations found by a consistency check. Repaired is true if
+// the violations were removed, checks which can not be repaired automatically are not Repairable.
+type ConsistencyReport struct {
+	Check       string
+	Description string
+	Violations  []string
+	Repairable  bool
+	Repaired    bool
+}
+
+// CheckConsistency runs all consistency checks in a single transaction. With repair the
+// violations of all repairable checks are removed, otherwise nothing is changed.
+func CheckConsistency(repair bool) ([]ConsistencyReport, error) {
+	reports := make([]ConsistencyReport, 0, len(consistencyChecks))
+
+	tx := database.MustBegin()
+	for _, check := range consistencyChecks {
+		report := ConsistencyReport{
+			Check:       check.name,
+			Description: check.description,
+			Violations:  make([]string, 0),
+			Repairable:  len(check.repair) > 0,
+		}
+
+		err := tx.Select(&report.Violations, check.query)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		if repair && report.Repairable && len(report.Violations) > 0 {
+			for _, q := range check.repair {
+				_, err = tx.Exec(q)
+				if err != nil {
+					tx.Rollback()
+					return nil, err
+				}
+			}
+			report.Repaired = true
+		}
+		reports = append(reports, report)
+	}
+
+	if !repair {
+		return reports, tx.Rollback()
+	}
+	return reports, tx.Commit()
+}
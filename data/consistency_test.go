@@ -0,0 +1,58 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	violations := func(reports []ConsistencyReport) map[string][]string {
+		m := make(map[string][]string)
+		for _, r := range reports {
+			if len(r.Violations) > 0 {
+				m[r.Check] = r.Violations
+			}
+		}
+		return m
+	}
+
+	reports, err := CheckConsistency(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != len(consistencyChecks) {
+		t.Errorf("Report for each check expected but was %d", len(reports))
+	}
+	if v := violations(reports); len(v) != 0 {
+		t.Errorf("No violations expected for the fixtures: %v", v)
+	}
+
+	// e-mail addresses which only differ in case can not be repaired automatically
+	database.MustExec(`UPDATE Accounts SET email = upper((SELECT email FROM Accounts WHERE login = 'alice'))
+	                   WHERE login = 'bob'`)
+	reports, err = CheckConsistency(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := violations(reports)
+	if len(v) != 1 || len(v["duplicate_emails"]) != 1 {
+		t.Fatalf("Duplicate e-mail address expected: %v", v)
+	}
+	for _, r := range reports {
+		if r.Check == "duplicate_emails" && (r.Repairable || r.Repaired) {
+			t.Errorf("Duplicate e-mail addresses expected to require a manual repair: %+v", r)
+		}
+	}
+}
@@ -9,18 +9,26 @@
 package data
 
 import (
-	"io/ioutil"
-	"strings"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq" // pg driver needs to be imported in order to load it
 )
 
 var database *sqlx.DB
 
+// expiryTime returns the time after which sessions, tokens, requests and codes must expire in order
+// to be valid. Entries are accepted for the configured leeway after they expired, to tolerate clock
+// skew between replicas.
+func expiryTime() time.Time {
+	return util.Now().Add(-conf.GetClock().Leeway)
+}
+
 // InitDb initializes a global database connection.
 // An existing connection will be closed.
 func InitDb(config *conf.DbConfig) {
@@ -35,52 +43,124 @@ func InitDb(config *conf.DbConfig) {
 	}
 }
 
-// InitTestDb initializes a database for testing purpose.
+// InitTestDb initializes a database for testing purpose. Each test binary works on its own
+// schema, which is created from the migrations when the first test starts, such that the
+// tests of different packages don't interfere when they run in parallel. Tests within a
+// package share the schema and the fixtures, which are reset by each call, thus they must
+// not run in parallel.
 func InitTestDb(t *testing.T) {
-	config := conf.GetDbConfig()
-	if !strings.Contains(config.Open, "user=test") {
+	if !IsTestDb() {
 		t.Fatal("Prohibit running tests outside a test environment.")
 	}
-	InitDb(config)
+	testSchema.Do(func() {
+		testSchema.err = initTestSchema(conf.GetDbConfig(), testSchemaName())
+	})
+	if testSchema.err != nil {
+		t.Fatal(testSchema.err)
+	}
 
-	fixtures, err := ioutil.ReadFile(conf.GetResourceFile("fixtures", "testdb.sql"))
+	err := resetFixtures()
 	if err != nil {
 		t.Fatal(err)
 	}
-	database.MustExec(string(fixtures))
 }
 
+// Tables with an expires column from which RemoveExpired deletes expired rows
+var expiringTables = []string{"AccessTokens", "MagicLinks", "ClientAssertions", "ClientHistory", "TokenRevocations",
+	"AccountRecoveries", "ServiceTokens", "GroupInvitations"}
+
 // RemoveExpired removes rows of expired entries from
-// AccessTokens, Sessions and GrantRequests database tables.
-func RemoveExpired() {
-	const delGrant = `DELETE from GrantRequests WHERE createdAt <= $1`
-	database.MustExec(delGrant, time.Now().Add(-1*conf.GetServerConfig().GrantReqLifeTime))
-
-	const q = `DELETE from AccessTokens WHERE expires <= now();
-		   DELETE from Sessions WHERE expires <= now();`
-	database.MustExec(q)
+// AccessTokens, RefreshTokens, Sessions, GrantRequests and ClientHistory database tables.
+// Removed grant requests are counted as abandoned authorization flows.
+// The job acts with the service token svc.
+func RemoveExpired(svc *ServiceToken) {
+	removeAbandonedGrantRequests(expiryTime().Add(-1 * conf.GetServerConfig().GrantReqLifeTime))
+
+	for _, table := range expiringTables {
+		database.MustExec(`DELETE from `+table+` WHERE expires <= $1`, expiryTime())
+	}
+	removeExpiredRefreshTokens()
+
+	err := sessionStore().DeleteExpired()
+	if err != nil {
+		panic(err)
+	}
 }
 
 // RemoveStaleAccounts removes all accounts that where registered,
-// but never accessed within a defined period of time
-func RemoveStaleAccounts() {
+// but never accessed within a defined period of time. The job acts
+// with the service token svc, which is written to the audit log.
+func RemoveStaleAccounts(svc *ServiceToken) {
 	const q = `DELETE FROM Accounts WHERE
 	 	   NOT isdisabled AND
 	 	   resetpwcode IS NULL AND
 	 	   activationcode IS NOT NULL AND
-	 	   updatedat < $1`
-	database.MustExec(q, time.Now().Add(-1*conf.GetServerConfig().UnusedAccountLifeTime))
+	 	   updatedat < $1
+	 	   RETURNING *`
+
+	accounts := make([]Account, 0)
+	err := database.Select(&accounts, q, util.Now().Add(-1*conf.GetServerConfig().UnusedAccountLifeTime))
+	if err != nil {
+		panic(err)
+	}
+	for i := range accounts {
+		svc.audit(logrus.Fields{
+			"event":   "stale-account",
+			"account": accounts[i].Login,
+		}).Info("Removed account which was never activated")
+		accountDeleted(&accounts[i])
+	}
 }
 
 // RunCleaner starts an infinite loop which
 // periodically executes database cleanup functions.
 func RunCleaner() {
 	go func() {
+		creds := NewServiceCredentials(JobCleaner)
 		t := time.NewTicker(conf.GetServerConfig().CleanerInterval)
 		defer t.Stop()
 		for range t.C {
-			RemoveExpired()
-			RemoveStaleAccounts()
+			if conf.GetReadOnly().Enabled {
+				continue
+			}
+			runCleanup(creds, RemoveExpired)
+			runCleanup(creds, RemoveStaleAccounts)
+			runCleanup(creds, NotifyPasswordExpiry)
+			runCleanup(creds, EnforceDormancy)
+			runCleanup(creds, EnforceRetention)
+		}
+	}()
+}
+
+// runCleanup executes a cleanup function with the service token of the job, if the token carries
+// the cleanup scope. A failure is logged and reported as alert instead of stopping the cleaner.
+func runCleanup(creds *ServiceCredentials, cleanup func(svc *ServiceToken)) {
+	defer func() {
+		if err := recover(); err != nil {
+			conf.GetLogEnv().Err.Errorf("Error running database cleanup: %v\n", err)
+			util.RecordEvent(util.AlertCleanerFailure, fmt.Sprint(err))
+		}
+	}()
+	svc, ok := authorizeJob(creds, ScopeServiceCleanup)
+	if !ok {
+		util.RecordEvent(util.AlertCleanerFailure, "background job "+creds.Job()+" was not authorized")
+		return
+	}
+	cleanup(svc)
+}
+
+// RunGrantRequestGC starts an infinite loop which periodically
+// removes abandoned grant requests.
+func RunGrantRequestGC() {
+	go func() {
+		creds := NewServiceCredentials(JobGrantRequestGC)
+		t := time.NewTicker(conf.GetGrantRequestGC().Interval)
+		defer t.Stop()
+		for range t.C {
+			if conf.GetReadOnly().Enabled {
+				continue
+			}
+			runCleanup(creds, RemoveAbandonedGrantRequests)
 		}
 	}()
 }
@@ -97,6 +177,7 @@ func EmailDispatch() {
 		if err != nil {
 			conf.GetLogEnv().Err.
 				Errorf("Error trying to send e-mail (Id %d): %s\n", email.Id, err.Error())
+			util.RecordEvent(util.AlertSmtpFailure, err.Error())
 		} else {
 			err = email.Delete()
 			if err != nil {
@@ -107,13 +188,55 @@ func EmailDispatch() {
 }
 
 // RunEmailDispatch starts an infinite loop which periodically
-// runs e-mail queue functions.
+// converts due notifications and announcements into e-mails and runs e-mail queue functions.
 func RunEmailDispatch() {
 	go func() {
+		creds := NewServiceCredentials(JobEmailDispatch)
 		t := time.NewTicker(conf.GetServerConfig().MailQueueInterval)
 		defer t.Stop()
 		for range t.C {
+			if conf.GetReadOnly().Enabled {
+				continue
+			}
+			if _, ok := authorizeJob(creds, ScopeServiceEmail); !ok {
+				continue
+			}
+			err := DispatchNotifications()
+			if err != nil {
+				conf.GetLogEnv().Err.Errorf("Error dispatching notifications: %s\n", err.Error())
+			}
+			err = DispatchAnnouncements(conf.GetAnnouncements().BatchSize)
+			if err != nil {
+				conf.GetLogEnv().Err.Errorf("Error dispatching announcements: %s\n", err.Error())
+			}
 			EmailDispatch()
 		}
 	}()
 }
+
+// Interval in which counted API usage is written to the database
+const usageFlushInterval = time.Minute
+
+// RunUsageFlush starts an infinite loop which periodically
+// writes counted API usage to the database.
+func RunUsageFlush() {
+	go func() {
+		creds := NewServiceCredentials(JobUsageFlush)
+		t := time.NewTicker(usageFlushInterval)
+		defer t.Stop()
+		for range t.C {
+			// usage is counted in memory and written once read-only mode is disabled
+			// and the job is authorized
+			if conf.GetReadOnly().Enabled {
+				continue
+			}
+			if _, ok := authorizeJob(creds, ScopeServiceUsage); !ok {
+				continue
+			}
+			err := FlushUsage()
+			if err != nil {
+				conf.GetLogEnv().Err.Errorf("Error writing usage statistics: %s\n", err.Error())
+			}
+		}
+	}()
+}
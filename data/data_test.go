@@ -15,6 +15,10 @@ import (
 	"github.com/G-Node/gin-auth/util"
 )
 
+func init() {
+	conf.UseTestConfig()
+}
+
 func TestEmailDispatch(t *testing.T) {
 	InitTestDb(t)
 
@@ -0,0 +1,179 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+)
+
+// RecordLogin stores the time of a successful login. A login ends the warning period
+// of an account which was notified about its upcoming deactivation.
+func (acc *Account) RecordLogin() error {
+	const q = `UPDATE Accounts SET (lastLoginAt, dormancyNotifiedAt) = (now(), NULL)
+	           WHERE uuid=$1
+	           RETURNING *`
+
+	return database.Get(acc, q, acc.UUID)
+}
+
+// IsDormancyExempt returns true if the account carries a label, which exempts
+// it from the dormancy policy (e.g. bot or machine accounts).
+func (acc *Account) IsDormancyExempt() bool {
+	var labels []string
+	if notes, ok := GetAccountNotes(acc.UUID); ok {
+		labels = notes.Labels.Strings()
+	}
+	return conf.GetDormancy().IsExempt(labels)
+}
+
+// resetDormancy restarts the dormancy period of a re-enabled account as if it had just logged in.
+func (acc *Account) resetDormancy(tx *sqlx.Tx) error {
+	const q = `UPDATE Accounts SET (lastLoginAt, dormancyNotifiedAt, dormantSince, isArchived) = (now(), NULL, NULL, false)
+	           WHERE uuid=$1
+	           RETURNING *`
+
+	return tx.Get(acc, q, acc.UUID)
+}
+
+// EnforceDormancy applies the account dormancy policy: active accounts without login since the
+// cutoff of the policy are notified about their upcoming deactivation, notified accounts which did
+// not log in within the warning period are disabled and accounts which are disabled for longer than
+// the archiving period are archived. Accounts exempt from the policy are skipped. The job acts
+// with the service token svc, which is written to the audit log.
+func EnforceDormancy(svc *ServiceToken) {
+	policy := conf.GetDormancy()
+	if policy.Months == 0 {
+		return
+	}
+
+	notifyDormantAccounts(policy)
+	disableDormantAccounts(svc, policy)
+	archiveDormantAccounts(svc, policy)
+}
+
+// notifyDormantAccounts warns all active accounts without login since the cutoff of the policy.
+// Each account is only notified once until its next login.
+func notifyDormantAccounts(policy *conf.Dormancy) {
+	const q = `SELECT * FROM ActiveAccounts WHERE dormancyNotifiedAt IS NULL AND lastLoginAt < $1`
+	const qNotified = `UPDATE Accounts SET dormancyNotifiedAt=now() WHERE uuid=$1`
+
+	accounts := make([]Account, 0)
+	err := database.Select(&accounts, q, policy.Cutoff(util.Now()))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, acc := range accounts {
+		if acc.IsDormancyExempt() {
+			continue
+		}
+
+		format := acc.TimeFormat()
+		body := fmt.Sprintf("You did not sign in to your GIN account '%s' since %s.\n\n"+
+			"Unused accounts are deactivated. Your account will be deactivated on %s unless you sign in before this date.\n%s",
+			acc.Login, format.Date(acc.LastLoginAt), format.Date(util.Now().Add(policy.Warn)),
+			conf.GetExternals().GinUiURL)
+		err = acc.Notify("Your GIN account will be deactivated", body)
+		if err != nil {
+			panic(err)
+		}
+		database.MustExec(qNotified, acc.UUID)
+	}
+}
+
+// disableDormantAccounts disables all accounts which were notified before the warning period
+// and did not log in since then. Sessions and tokens of these accounts are removed.
+func disableDormantAccounts(svc *ServiceToken, policy *conf.Dormancy) {
+	const q = `SELECT * FROM ActiveAccounts WHERE dormancyNotifiedAt < $1`
+	const qDisable = `UPDATE Accounts SET (isDisabled, dormantSince, updatedAt) = (true, now(), now()) WHERE uuid=$1`
+
+	accounts := make([]Account, 0)
+	err := database.Select(&accounts, q, util.Now().Add(-policy.Warn))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, acc := range accounts {
+		if acc.IsDormancyExempt() {
+			continue
+		}
+
+		tx := database.MustBegin()
+		for _, stmt := range []string{
+			qDisable,
+			`DELETE FROM AccessTokens WHERE accountUUID=$1`,
+			`DELETE FROM RefreshTokens WHERE accountUUID=$1`,
+		} {
+			_, err = tx.Exec(stmt, acc.UUID)
+			if err != nil {
+				tx.Rollback()
+				panic(err)
+			}
+		}
+		err = tx.Commit()
+		if err != nil {
+			panic(err)
+		}
+		err = sessionStore().DeleteAccount(acc.UUID)
+		if err != nil {
+			panic(err)
+		}
+
+		svc.audit(logrus.Fields{
+			"event":     "dormancy",
+			"account":   acc.Login,
+			"lastLogin": acc.LastLoginAt.Format(time.RFC3339),
+		}).Info("Disabled dormant account")
+	}
+}
+
+// archiveDormantAccounts archives all accounts which were disabled because of dormancy before the
+// archiving period: the password, ssh keys, client approvals and open grant requests are removed,
+// the profile is kept until it is purged by the data retention policy.
+func archiveDormantAccounts(svc *ServiceToken, policy *conf.Dormancy) {
+	const q = `SELECT * FROM Accounts WHERE isDisabled AND NOT isArchived AND dormantSince < $1`
+	const qArchive = `UPDATE Accounts SET (pwHash, isArchived) = ('', true) WHERE uuid=$1`
+
+	accounts := make([]Account, 0)
+	err := database.Select(&accounts, q, util.Now().Add(-policy.ArchiveAfter))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, acc := range accounts {
+		tx := database.MustBegin()
+		for _, stmt := range []string{
+			qArchive,
+			`DELETE FROM SSHKeys WHERE accountUUID=$1`,
+			`DELETE FROM ClientApprovals WHERE accountUUID=$1`,
+			`DELETE FROM GrantRequests WHERE accountUUID=$1`,
+		} {
+			_, err = tx.Exec(stmt, acc.UUID)
+			if err != nil {
+				tx.Rollback()
+				panic(err)
+			}
+		}
+		err = tx.Commit()
+		if err != nil {
+			panic(err)
+		}
+
+		svc.audit(logrus.Fields{
+			"event":   "dormancy",
+			"account": acc.Login,
+		}).Info("Archived dormant account")
+	}
+}
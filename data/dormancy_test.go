@@ -0,0 +1,94 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestEnforceDormancy(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	policy := conf.GetDormancy()
+	defer func() { policy.Months = 0 }()
+	policy.Months = 6
+
+	database.MustExec(`UPDATE Accounts SET lastLoginAt = now() - INTERVAL '1 year' WHERE login IN ('alice', 'bob')`)
+	notes := &AccountNotes{AccountUUID: "51f5ac36-d332-4889-8023-6e033fcd8e17", Labels: util.NewStringSet("bot")}
+	err := notes.SaveBy("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// notify
+	EnforceDormancy(testServiceToken(t, JobCleaner))
+	EnforceDormancy(testServiceToken(t, JobCleaner))
+
+	alice, _ := GetAccountByLogin("alice")
+	if !alice.DormancyNotifiedAt.Valid || alice.IsDisabled {
+		t.Error("Alice should be notified but not disabled")
+	}
+	if len(ListNotifications(alice.UUID)) != 2 {
+		t.Error("Alice should be notified once")
+	}
+	bob, _ := GetAccountByLogin("bob")
+	if bob.DormancyNotifiedAt.Valid {
+		t.Error("Bob should be exempt")
+	}
+
+	// login ends the warning period
+	err = alice.RecordLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.DormancyNotifiedAt.Valid {
+		t.Error("Login should reset the notification")
+	}
+
+	// disable
+	database.MustExec(`UPDATE Accounts SET dormancyNotifiedAt = now() - INTERVAL '40 days' WHERE uuid = $1`, uuidAlice)
+	EnforceDormancy(testServiceToken(t, JobCleaner))
+
+	if _, ok := GetAccountByLogin("alice"); ok {
+		t.Error("Alice should be disabled")
+	}
+	alice, _ = GetAccountDisabled(uuidAlice)
+	if !alice.DormantSince.Valid || alice.IsArchived {
+		t.Error("Alice should be dormant but not archived")
+	}
+	if _, ok := GetSession("DNM5RS3C"); ok {
+		t.Error("Session of alice should be removed")
+	}
+
+	// archive
+	database.MustExec(`UPDATE Accounts SET dormantSince = now() - INTERVAL '200 days' WHERE uuid = $1`, uuidAlice)
+	EnforceDormancy(testServiceToken(t, JobCleaner))
+
+	alice, _ = GetAccountDisabled(uuidAlice)
+	if !alice.IsArchived || alice.PWHash != "" {
+		t.Error("Alice should be archived")
+	}
+	if len(alice.SSHKeys()) != 0 {
+		t.Error("SSH keys of alice should be removed")
+	}
+
+	// re-enable
+	alice.IsDisabled = false
+	err = alice.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.IsArchived || alice.DormantSince.Valid {
+		t.Error("Dormancy of re-enabled account should be reset")
+	}
+}
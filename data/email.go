@@ -11,8 +11,6 @@ package data
 import (
 	"database/sql"
 	"fmt"
-	"net/smtp"
-	"strconv"
 	"time"
 
 	"github.com/G-Node/gin-auth/conf"
@@ -40,8 +38,14 @@ func GetQueuedEmails() ([]Email, error) {
 	return emails, err
 }
 
-// Create adds a new entry to table EmailQueue
+// Create adds a new entry to table EmailQueue.
+// Suppressed addresses (see EmailBounce) are removed from the recipients,
+// if no recipient remains the e-mail is not queued.
 func (e *Email) Create(to util.StringSet, content []byte) error {
+	to = to.Difference(suppressedRecipients(to))
+	if to.Len() == 0 {
+		return nil
+	}
 
 	const q = `INSERT INTO EmailQueue(mode, sender, recipient, content, createdat)
 	           VALUES ($1, $2, $3, $4, now())
@@ -63,7 +67,7 @@ func (e *Email) Delete() error {
 }
 
 // Send checks the smtp Mode setting and if appropriate
-// sets up authentication for e-mail dispatch via smtp and sends the e-mail.
+// sends the e-mail via the smtp connection pool.
 func (e *Email) Send() error {
 	switch e.Mode.String {
 	case "skip":
@@ -71,17 +75,11 @@ func (e *Email) Send() error {
 	case "print":
 		fmt.Printf("%s\n", string(e.Content))
 	default:
-		config := conf.GetSmtpCredentials()
-
-		var auth smtp.Auth
-		if config.Username == "" && config.Password == "" {
-			auth = &conf.NoAuth{}
-		} else {
-			auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+		pool, err := util.GetSmtpPool()
+		if err != nil {
+			return err
 		}
-
-		addr := config.Host + ":" + strconv.Itoa(config.Port)
-		err := smtp.SendMail(addr, auth, e.Sender, e.Recipient.Strings(), e.Content)
+		err = pool.Send(e.Sender, e.Recipient.Strings(), e.Content)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,173 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Supported bounce types: a hard bounce or a complaint suppresses an address immediately,
+// soft bounces only after the configured number of bounces.
+var bounceTypes = util.NewStringSet("hard", "soft", "complaint")
+
+// EmailBounce contains information about an e-mail address, for which the mail provider
+// reported bounces or complaints. No e-mails are sent to suppressed addresses.
+type EmailBounce struct {
+	Email        string
+	BounceType   string
+	Reason       string
+	Count        int
+	IsSuppressed bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// ListEmailBounces returns all bouncing addresses, the latest bounces first.
+func ListEmailBounces() []EmailBounce {
+	const q = `SELECT * FROM EmailBounces ORDER BY updatedAt DESC, email`
+
+	bounces := make([]EmailBounce, 0)
+	err := database.Select(&bounces, q)
+	if err != nil {
+		panic(err)
+	}
+
+	return bounces
+}
+
+// EachEmailBounce works like ListEmailBounces but passes the bounces one by one to fn
+// while they are read from the database. Iteration stops at the first error returned by fn.
+func EachEmailBounce(fn func(*EmailBounce) error) error {
+	const q = `SELECT * FROM EmailBounces ORDER BY updatedAt DESC, email`
+
+	rows, err := database.Queryx(q)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		bounce := &EmailBounce{}
+		err = rows.StructScan(bounce)
+		if err != nil {
+			return err
+		}
+		err = fn(bounce)
+		if err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetEmailBounce returns the bounce information of an e-mail address.
+// Returns false if no bounces were reported for the address.
+func GetEmailBounce(email string) (*EmailBounce, bool) {
+	const q = `SELECT * FROM EmailBounces WHERE email=$1`
+
+	bounce := &EmailBounce{}
+	err := database.Get(bounce, q, strings.ToLower(email))
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return bounce, err == nil
+}
+
+// RecordEmailBounce records a bounce or complaint reported for an e-mail address.
+// Once the address is suppressed, all accounts using this address are flagged as bouncing.
+func RecordEmailBounce(email, bounceType, reason string) (*EmailBounce, error) {
+	if !strings.Contains(email, "@") || len(email) > 512 {
+		return nil, &util.ValidationError{
+			Message:     "Invalid bounce",
+			FieldErrors: map[string]string{"email": "Please use a valid e-mail address"}}
+	}
+	if !bounceTypes.Contains(bounceType) {
+		return nil, &util.ValidationError{
+			Message:     "Invalid bounce",
+			FieldErrors: map[string]string{"type": "Type must be one of 'hard', 'soft' or 'complaint'"}}
+	}
+
+	const q = `INSERT INTO EmailBounces (email, bounceType, reason, count, isSuppressed, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, 1, $2 <> 'soft' OR $4 <= 1, now(), now())
+	           ON CONFLICT (email) DO UPDATE
+	           SET (bounceType, reason, count, isSuppressed, updatedAt) =
+	               (EXCLUDED.bounceType, EXCLUDED.reason, EmailBounces.count + 1,
+	                EmailBounces.isSuppressed OR EXCLUDED.bounceType <> 'soft' OR EmailBounces.count + 1 >= $4, now())
+	           RETURNING *`
+	const qFlag = `UPDATE Accounts SET isEmailBouncing=TRUE WHERE lower(email)=$1`
+
+	bounce := &EmailBounce{}
+	tx := database.MustBegin()
+	err := tx.Get(bounce, q, strings.ToLower(email), bounceType, reason, conf.GetEmailBounces().SoftLimit)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if bounce.IsSuppressed {
+		_, err = tx.Exec(qFlag, bounce.Email)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+
+	return bounce, tx.Commit()
+}
+
+// Delete removes the bounce information of the address, e-mails are sent to the address again.
+func (bounce *EmailBounce) Delete() error {
+	const q = `DELETE FROM EmailBounces WHERE email=$1`
+	const qFlag = `UPDATE Accounts SET isEmailBouncing=FALSE WHERE lower(email)=$1`
+
+	tx := database.MustBegin()
+	_, err := tx.Exec(q, bounce.Email)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(qFlag, bounce.Email)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// suppressedRecipients returns all addresses of a set of recipients, which are suppressed.
+func suppressedRecipients(to util.StringSet) util.StringSet {
+	const q = `SELECT email FROM EmailBounces WHERE isSuppressed AND email = ANY($1)`
+
+	byLower := make(map[string]string, to.Len())
+	for _, addr := range to.Strings() {
+		byLower[strings.ToLower(addr)] = addr
+	}
+	lower := util.NewStringSet()
+	for addr := range byLower {
+		lower = lower.Add(addr)
+	}
+
+	suppressed := make([]string, 0)
+	err := database.Select(&suppressed, q, lower)
+	if err != nil {
+		panic(err)
+	}
+
+	result := make([]string, 0, len(suppressed))
+	for _, addr := range suppressed {
+		result = append(result, byLower[addr])
+	}
+	return util.NewStringSet(result...)
+}
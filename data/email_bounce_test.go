@@ -0,0 +1,108 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestListEmailBounces(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	bounces := ListEmailBounces()
+	if len(bounces) != 2 {
+		t.Errorf("Expected 2 bounces but got %d", len(bounces))
+	}
+}
+
+func TestRecordEmailBounce(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	_, err := RecordEmailBounce("bob@foo.com", "unknown", "")
+	if err == nil {
+		t.Error("Invalid bounce type should fail")
+	}
+
+	// third soft bounce suppresses the address
+	bounce, err := RecordEmailBounce("Bob@Foo.com", "soft", "452 4.2.2 Mailbox full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bounce.Count != 2 || bounce.IsSuppressed {
+		t.Errorf("Second soft bounce should not suppress the address: %v", bounce)
+	}
+	bounce, _ = RecordEmailBounce("bob@foo.com", "soft", "452 4.2.2 Mailbox full")
+	if bounce.Count != 3 || !bounce.IsSuppressed {
+		t.Errorf("Third soft bounce should suppress the address: %v", bounce)
+	}
+
+	bob, _ := GetAccountByLogin("bob")
+	if !bob.IsEmailBouncing {
+		t.Error("Account of bob should be flagged as bouncing")
+	}
+
+	// hard bounce suppresses immediately
+	bounce, _ = RecordEmailBounce("new@example.com", "hard", "")
+	if !bounce.IsSuppressed {
+		t.Error("Hard bounce should suppress the address")
+	}
+}
+
+func TestEmailBounce_Delete(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	bounce, ok := GetEmailBounce("jj@example.com")
+	if !ok {
+		t.Fatal("Bounce does not exist")
+	}
+	err := bounce.Delete()
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, ok = GetEmailBounce("jj@example.com")
+	if ok {
+		t.Error("Bounce should not exist")
+	}
+	john, _ := GetAccountByLogin("john")
+	if john.IsEmailBouncing {
+		t.Error("Account of john should not be flagged as bouncing")
+	}
+}
+
+func TestEmailSuppression(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	before, _ := GetQueuedEmails()
+
+	e := &Email{}
+	err := e.Create(util.NewStringSet("jj@example.com"), []byte("content"))
+	if err != nil {
+		t.Error(err)
+	}
+	e = &Email{}
+	err = e.Create(util.NewStringSet("JJ@example.com", "bob@foo.com"), []byte("content"))
+	if err != nil {
+		t.Error(err)
+	}
+
+	after, _ := GetQueuedEmails()
+	if len(after) != len(before)+1 {
+		t.Fatal("Only one e-mail expected to be queued")
+	}
+	if !e.Recipient.Contains("bob@foo.com") || e.Recipient.Contains("JJ@example.com") {
+		t.Errorf("Suppressed address should be removed from recipients: %v", e.Recipient.Strings())
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors returned by the data package. Errors of these kinds are wrapped in an
+// *Error which carries a message with context, use KindOf to obtain the kind of an error.
+//
+// Typed errors are returned by operations which create, change or remove data and by lookups
+// which can fail for more than one reason (e.g. AuthorizeService or UseMagicLink). Plain
+// getters (Get*, List*) keep the convention of the package: they return false or an empty
+// list if nothing matches and panic on database errors.
+var (
+	ErrNotFound  = errors.New("Not found")
+	ErrConflict  = errors.New("Conflict")
+	ErrExpired   = errors.New("Expired")
+	ErrForbidden = errors.New("Forbidden")
+)
+
+// Error is an error of a certain kind with a message describing the context.
+type Error struct {
+	Kind    error
+	Message string
+}
+
+// Error implements the error interface.
+func (err *Error) Error() string {
+	return err.Message
+}
+
+// KindOf returns the kind of an error or nil if the error has no known kind.
+func KindOf(err error) error {
+	switch err := err.(type) {
+	case *Error:
+		return err.Kind
+	case nil:
+		return nil
+	}
+	if err == ErrNotFound || err == ErrConflict || err == ErrExpired || err == ErrForbidden {
+		return err
+	}
+	return nil
+}
+
+func notFoundError(format string, args ...interface{}) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func conflictError(format string, args ...interface{}) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func expiredError(format string, args ...interface{}) error {
+	return &Error{Kind: ErrExpired, Message: fmt.Sprintf(format, args...)}
+}
+
+func forbiddenError(format string, args ...interface{}) error {
+	return &Error{Kind: ErrForbidden, Message: fmt.Sprintf(format, args...)}
+}
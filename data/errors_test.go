@@ -0,0 +1,39 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestKindOf(t *testing.T) {
+	err := notFoundError("Account '%s' does not exist", "foo")
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Kind ErrNotFound expected but was %v", KindOf(err))
+	}
+	if err.Error() != "Account 'foo' does not exist" {
+		t.Errorf("Unexpected message: %s", err.Error())
+	}
+	if KindOf(ErrLastGroupOwner) != ErrConflict {
+		t.Error("ErrLastGroupOwner expected to be a conflict")
+	}
+	if KindOf(expiredError("Link expired")) != ErrExpired {
+		t.Error("Kind ErrExpired expected")
+	}
+	if KindOf(forbiddenError("Scope missing")) != ErrForbidden {
+		t.Error("Kind ErrForbidden expected")
+	}
+	if KindOf(ErrNotFound) != ErrNotFound {
+		t.Error("Plain kind expected to be its own kind")
+	}
+	if KindOf(errors.New("other")) != nil || KindOf(nil) != nil {
+		t.Error("Errors without kind expected to have no kind")
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Names of feature flags consist of lower case letters, digits and hyphens, e.g. "oidc-login"
+var featureFlagNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// FeatureFlag decides whether a new feature is on for an account, such that risky changes can be
+// launched gradually. An enabled flag is on for all accounts, otherwise it is on for the accounts
+// with the logins in Accounts and for Percentage percent of all other accounts. Flags set by an
+// administrator are stored in the database and override the flags of the server configuration.
+type FeatureFlag struct {
+	Name       string
+	Enabled    bool
+	Percentage int
+	Accounts   util.StringSet
+	UpdatedBy  sql.NullString
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// configuredFeatureFlag returns the flag with the given name from the server configuration.
+func configuredFeatureFlag(name string) (*FeatureFlag, bool) {
+	f, ok := conf.GetFeatureFlags()[name]
+	if !ok {
+		return nil, false
+	}
+	return &FeatureFlag{Name: name, Enabled: f.Enabled, Percentage: f.Percentage, Accounts: util.NewStringSet(f.Accounts...)}, true
+}
+
+// GetFeatureFlag returns the feature flag with the given name, either as set by an administrator
+// or as configured. Returns false if no such flag exists.
+func GetFeatureFlag(name string) (*FeatureFlag, bool) {
+	const q = `SELECT * FROM FeatureFlags WHERE name=$1`
+
+	flag := &FeatureFlag{}
+	err := database.Get(flag, q, name)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+	if err == nil {
+		return flag, true
+	}
+
+	return configuredFeatureFlag(name)
+}
+
+// ListFeatureFlags returns all configured feature flags and those set by an administrator, ordered by name.
+func ListFeatureFlags() []FeatureFlag {
+	const q = `SELECT * FROM FeatureFlags ORDER BY name`
+
+	flags := make([]FeatureFlag, 0)
+	err := database.Select(&flags, q)
+	if err != nil {
+		panic(err)
+	}
+
+	stored := util.NewStringSet()
+	for _, f := range flags {
+		stored = stored.Add(f.Name)
+	}
+	for name := range conf.GetFeatureFlags() {
+		if !stored.Contains(name) {
+			f, _ := configuredFeatureFlag(name)
+			flags = append(flags, *f)
+		}
+	}
+	sort.Sort(featureFlagsByName(flags))
+
+	return flags
+}
+
+type featureFlagsByName []FeatureFlag
+
+func (s featureFlagsByName) Len() int           { return len(s) }
+func (s featureFlagsByName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s featureFlagsByName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// IsFeatureEnabled checks whether the feature flag with the given name is on for an account.
+// Without account (nil) only enabled flags are on. Unknown flags are off.
+func IsFeatureEnabled(name string, acc *Account) bool {
+	flag, ok := GetFeatureFlag(name)
+	return ok && flag.EnabledFor(acc)
+}
+
+// EnabledFor checks whether the flag is on for an account. Whether an account belongs to the
+// percentage of accounts for which the flag is on is decided by a hash of flag name and account
+// UUID, thus accounts keep the feature when the percentage is raised.
+func (flag *FeatureFlag) EnabledFor(acc *Account) bool {
+	if flag.Enabled {
+		return true
+	}
+	if acc == nil {
+		return false
+	}
+	if flag.Accounts.Contains(acc.Login) {
+		return true
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(flag.Name + "/" + acc.UUID))
+	return int(h.Sum32()%100) < flag.Percentage
+}
+
+// IsOverride returns true if the flag was set by an administrator.
+func (flag *FeatureFlag) IsOverride() bool {
+	return !flag.CreatedAt.IsZero()
+}
+
+// Validate the feature flag.
+func (flag *FeatureFlag) Validate() error {
+	fieldErrors := make(map[string]string)
+	if !featureFlagNameRegex.MatchString(flag.Name) {
+		fieldErrors["name"] = "Please use lower case letters, digits and hyphens only"
+	}
+	if flag.Percentage < 0 || flag.Percentage > 100 {
+		fieldErrors["percentage"] = "Please use a percentage between 0 and 100"
+	}
+	for _, login := range flag.Accounts.Strings() {
+		if _, ok := GetAccountByLogin(login); !ok {
+			fieldErrors["accounts"] = fmt.Sprintf("The account '%s' does not exist", login)
+			break
+		}
+	}
+	if len(fieldErrors) > 0 {
+		return &util.ValidationError{Message: "Invalid feature flag", FieldErrors: fieldErrors}
+	}
+	return nil
+}
+
+// SaveBy stores the feature flag, which overrides a configured flag with the same name.
+// The given UUID identifies the account of the administrator who changed the flag.
+func (flag *FeatureFlag) SaveBy(changedBy string) error {
+	if flag.Accounts == nil {
+		flag.Accounts = util.NewStringSet()
+	}
+	if err := flag.Validate(); err != nil {
+		return err
+	}
+
+	const q = `INSERT INTO FeatureFlags (name, enabled, percentage, accounts, updatedBy, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, $5, now(), now())
+	           ON CONFLICT (name) DO UPDATE
+	           SET (enabled, percentage, accounts, updatedBy, updatedAt) =
+	               (EXCLUDED.enabled, EXCLUDED.percentage, EXCLUDED.accounts, EXCLUDED.updatedBy, now())
+	           RETURNING *`
+
+	flag.UpdatedBy = sql.NullString{String: changedBy, Valid: changedBy != ""}
+	return database.Get(flag, q, flag.Name, flag.Enabled, flag.Percentage, flag.Accounts, flag.UpdatedBy)
+}
+
+// DeleteFeatureFlag removes the flag set by an administrator, such that the configured flag
+// with the same name applies again.
+func DeleteFeatureFlag(name string) error {
+	const q = `DELETE FROM FeatureFlags WHERE name=$1`
+
+	res, err := database.Exec(q, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return notFoundError("Feature flag '%s' was not set by an administrator", name)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestFeatureFlagEnabledFor(t *testing.T) {
+	alice := &Account{UUID: "bf431618-f696-4dca-a95d-882618ce4ef9", Login: "alice"}
+
+	flag := &FeatureFlag{Name: "foo", Accounts: util.NewStringSet("alice")}
+	if !flag.EnabledFor(alice) {
+		t.Error("Flag expected to be on for alice")
+	}
+	if flag.EnabledFor(&Account{UUID: "51f5ac36-d332-4889-8023-6e033fcd8e17", Login: "bob"}) {
+		t.Error("Flag expected to be off for bob")
+	}
+	if flag.EnabledFor(nil) {
+		t.Error("Flag expected to be off without account")
+	}
+
+	flag.Enabled = true
+	if !flag.EnabledFor(nil) {
+		t.Error("Enabled flag expected to be on without account")
+	}
+
+	// percentage rollout
+	flag = &FeatureFlag{Name: "foo", Accounts: util.NewStringSet()}
+	accounts := make([]*Account, 1000)
+	for i := range accounts {
+		accounts[i] = &Account{UUID: fmt.Sprintf("00000000-0000-0000-0000-%012d", i), Login: fmt.Sprintf("acc%d", i)}
+	}
+	count := func() int {
+		n := 0
+		for _, acc := range accounts {
+			if flag.EnabledFor(acc) {
+				n++
+			}
+		}
+		return n
+	}
+	if n := count(); n != 0 {
+		t.Errorf("Flag expected to be off for all accounts but was on for %d", n)
+	}
+	flag.Percentage = 20
+	enabled := make(map[string]bool)
+	for _, acc := range accounts {
+		enabled[acc.UUID] = flag.EnabledFor(acc)
+	}
+	if n := count(); n < 150 || n > 250 {
+		t.Errorf("Flag expected to be on for about 200 accounts but was on for %d", n)
+	}
+	flag.Percentage = 50
+	for _, acc := range accounts {
+		if enabled[acc.UUID] && !flag.EnabledFor(acc) {
+			t.Errorf("Flag expected to stay on for %s", acc.Login)
+		}
+	}
+	flag.Percentage = 100
+	if n := count(); n != len(accounts) {
+		t.Errorf("Flag expected to be on for all accounts but was on for %d", n)
+	}
+}
+
+func TestGetFeatureFlag(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	flag, ok := GetFeatureFlag("stored-feature")
+	if !ok {
+		t.Fatal("Flag 'stored-feature' expected to exist")
+	}
+	if !flag.IsOverride() || !flag.Accounts.Contains("bob") || flag.UpdatedBy.String != "51f5ac36-d332-4889-8023-6e033fcd8e17" {
+		t.Errorf("Unexpected flag: %+v", flag)
+	}
+
+	flag, ok = GetFeatureFlag("example-feature")
+	if !ok {
+		t.Fatal("Configured flag 'example-feature' expected to exist")
+	}
+	if flag.IsOverride() || flag.Percentage != 10 || !flag.Accounts.Contains("alice") {
+		t.Errorf("Unexpected flag: %+v", flag)
+	}
+
+	_, ok = GetFeatureFlag("doesnotexist")
+	if ok {
+		t.Error("Flag 'doesnotexist' should not exist")
+	}
+}
+
+func TestListFeatureFlags(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	flags := ListFeatureFlags()
+	if len(flags) != 3 || flags[0].Name != "example-feature" || flags[1].Name != "magic-link-login" ||
+		flags[2].Name != "stored-feature" {
+		t.Errorf("Unexpected flags: %+v", flags)
+	}
+}
+
+func TestIsFeatureEnabled(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	alice, _ := GetAccountByLogin("alice")
+	bob, _ := GetAccountByLogin("bob")
+
+	if !IsFeatureEnabled("example-feature", alice) || IsFeatureEnabled("example-feature", nil) {
+		t.Error("Flag 'example-feature' expected to be on for alice only")
+	}
+	if !IsFeatureEnabled("stored-feature", bob) || IsFeatureEnabled("stored-feature", alice) {
+		t.Error("Flag 'stored-feature' expected to be on for bob only")
+	}
+	if IsFeatureEnabled("doesnotexist", alice) {
+		t.Error("Unknown flags expected to be off")
+	}
+}
+
+func TestFeatureFlagSaveBy(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	const bobUUID = "51f5ac36-d332-4889-8023-6e033fcd8e17"
+
+	flag := &FeatureFlag{Name: "Not Valid!", Percentage: 101, Accounts: util.NewStringSet("doesnotexist")}
+	err := flag.SaveBy(bobUUID)
+	if verr, ok := err.(*util.ValidationError); !ok || len(verr.FieldErrors) != 3 {
+		t.Errorf("Validation error with three field errors expected but was: %v", err)
+	}
+
+	// override a configured flag
+	flag = &FeatureFlag{Name: "example-feature", Enabled: true}
+	err = flag.SaveBy(bobUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flag, _ = GetFeatureFlag("example-feature")
+	if !flag.IsOverride() || !flag.Enabled || flag.Accounts.Len() != 0 {
+		t.Errorf("Unexpected flag: %+v", flag)
+	}
+
+	// update
+	flag.Enabled = false
+	flag.Percentage = 30
+	err = flag.SaveBy(bobUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flag, _ = GetFeatureFlag("example-feature")
+	if flag.Enabled || flag.Percentage != 30 {
+		t.Errorf("Unexpected flag: %+v", flag)
+	}
+}
+
+func TestDeleteFeatureFlag(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	err := DeleteFeatureFlag("stored-feature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := GetFeatureFlag("stored-feature"); ok {
+		t.Error("Flag 'stored-feature' should not exist")
+	}
+
+	err = DeleteFeatureFlag("example-feature")
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Not found error expected but was: %v", err)
+	}
+}
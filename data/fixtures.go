@@ -0,0 +1,131 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// FixturePassword is the password of all accounts in the test fixtures.
+const FixturePassword = "testtest"
+
+// Key of the advisory lock which serializes concurrent fixture resets of a schema
+const fixtureLockKey = 0x67696e61
+
+// ErrNoTestDb is returned when fixtures should be loaded into a database which
+// is not a test database.
+var ErrNoTestDb = errors.New("Fixtures can only be loaded into a test database")
+
+// FixtureCredentials contains the credentials seeded by the test fixtures, so that
+// integration tests of dependent services don't need to hard code them.
+type FixtureCredentials struct {
+	Password     string               `json:"password"`
+	Accounts     []FixtureAccount     `json:"accounts"`
+	Clients      []FixtureClient      `json:"clients"`
+	AccessTokens []FixtureAccessToken `json:"access_tokens"`
+}
+
+// FixtureAccount is an account seeded by the test fixtures.
+type FixtureAccount struct {
+	Login string `json:"login"`
+	Email string `json:"email"`
+}
+
+// FixtureClient is a client seeded by the test fixtures.
+type FixtureClient struct {
+	Name   string `json:"client_id"`
+	Secret string `json:"client_secret"`
+}
+
+// FixtureAccessToken is a valid access token seeded by the test fixtures.
+type FixtureAccessToken struct {
+	Token  string         `json:"token"`
+	Login  string         `json:"login"`
+	Client string         `json:"client_id"`
+	Scope  util.StringSet `json:"-"`
+	Scopes []string       `json:"scope" db:"-"`
+}
+
+// IsTestDb returns true if the configured database is a test database,
+// which is the case if it is accessed by the user 'test'.
+func IsTestDb() bool {
+	return strings.Contains(conf.GetDbConfig().Open, "user=test")
+}
+
+// ResetFixtures resets the database to the test fixtures and returns the seeded credentials.
+// The fixtures are loaded in a single transaction and concurrent resets are serialized, so
+// other connections either see the old or the new state of the database.
+func ResetFixtures() (*FixtureCredentials, error) {
+	err := resetFixtures()
+	if err != nil {
+		return nil, err
+	}
+
+	const qAccounts = `SELECT login, email FROM ActiveAccounts ORDER BY login`
+	const qClients = `SELECT name, secret FROM Clients ORDER BY name`
+	const qTokens = `SELECT t.token, a.login, c.name AS client, t.scope
+	                 FROM AccessTokens t JOIN Accounts a ON a.uuid = t.accountUUID JOIN Clients c ON c.uuid = t.clientUUID
+	                 WHERE t.expires > now()
+	                 ORDER BY a.login, t.token`
+
+	creds := &FixtureCredentials{
+		Password:     FixturePassword,
+		Accounts:     make([]FixtureAccount, 0),
+		Clients:      make([]FixtureClient, 0),
+		AccessTokens: make([]FixtureAccessToken, 0),
+	}
+	err = database.Select(&creds.Accounts, qAccounts)
+	if err != nil {
+		return nil, err
+	}
+	err = database.Select(&creds.Clients, qClients)
+	if err != nil {
+		return nil, err
+	}
+	err = database.Select(&creds.AccessTokens, qTokens)
+	if err != nil {
+		return nil, err
+	}
+	for i := range creds.AccessTokens {
+		creds.AccessTokens[i].Scopes = creds.AccessTokens[i].Scope.Strings()
+	}
+
+	return creds, nil
+}
+
+// resetFixtures loads the test fixtures in a single transaction.
+func resetFixtures() error {
+	if !IsTestDb() {
+		return ErrNoTestDb
+	}
+
+	fixtures, err := ioutil.ReadFile(conf.GetResourceFile("fixtures", "testdb.sql"))
+	if err != nil {
+		return err
+	}
+
+	tx := database.MustBegin()
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1, hashtext(current_schema()))`, fixtureLockKey)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(string(fixtures))
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestResetFixtures(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	acc, _ := GetAccountByLogin("alice")
+	err := acc.UpdatePassword("changedpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := ResetFixtures()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(creds.Clients) != 2 {
+		t.Errorf("Two clients expected but was %d", len(creds.Clients))
+	}
+	if len(creds.AccessTokens) != 3 {
+		t.Errorf("Three access tokens expected but was %d", len(creds.AccessTokens))
+	}
+
+	acc, _ = GetAccountByLogin("alice")
+	if !acc.VerifyPassword(creds.Password) {
+		t.Error("Password of alice expected to be reset")
+	}
+}
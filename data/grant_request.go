@@ -11,12 +11,17 @@ package data
 import (
 	"database/sql"
 	"errors"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/util"
 )
 
+// Maximum length of return URLs of grant requests
+const maxReturnToLength = 1024
+
 // GrantRequest contains data about an ongoing authorization grant request.
 type GrantRequest struct {
 	Token          string
@@ -25,6 +30,7 @@ type GrantRequest struct {
 	Code           sql.NullString
 	ScopeRequested util.StringSet
 	RedirectURI    string
+	ReturnTo       string
 	ClientUUID     string
 	AccountUUID    sql.NullString
 	CreatedAt      time.Time
@@ -37,7 +43,7 @@ func ListGrantRequests() []GrantRequest {
 
 	grantRequests := make([]GrantRequest, 0)
 	err := database.Select(&grantRequests, q,
-		time.Now().Add(-1*conf.GetServerConfig().GrantReqLifeTime))
+		expiryTime().Add(-1*conf.GetServerConfig().GrantReqLifeTime))
 	if err != nil {
 		panic(err)
 	}
@@ -52,7 +58,7 @@ func GetGrantRequest(token string) (*GrantRequest, bool) {
 
 	grantRequest := &GrantRequest{}
 	err := database.Get(grantRequest, q, token,
-		time.Now().Add(-1*conf.GetServerConfig().GrantReqLifeTime))
+		expiryTime().Add(-1*conf.GetServerConfig().GrantReqLifeTime))
 	if err != nil && err != sql.ErrNoRows {
 		panic(err)
 	}
@@ -67,7 +73,7 @@ func GetGrantRequestByCode(code string) (*GrantRequest, bool) {
 
 	grantRequest := &GrantRequest{}
 	err := database.Get(grantRequest, q, code,
-		time.Now().Add(-1*conf.GetServerConfig().GrantReqLifeTime))
+		expiryTime().Add(-1*conf.GetServerConfig().GrantReqLifeTime))
 	if err != nil && err != sql.ErrNoRows {
 		panic(err)
 	}
@@ -76,74 +82,117 @@ func GetGrantRequestByCode(code string) (*GrantRequest, bool) {
 }
 
 // ExchangeCodeForTokens creates an access token and a refresh token.
+// If boundNetwork is valid the access token can only be used from within this network.
+// If groups is not empty the access token only acts for the account as member of these groups.
 // Finally the grant request will be deleted from the database, even if the token creation fails!
-func (req *GrantRequest) ExchangeCodeForTokens() (string, string, error) {
+func (req *GrantRequest) ExchangeCodeForTokens(boundNetwork sql.NullString, groups util.StringSet) (string, string, error) {
 	defer req.Delete()
 
-	const qCreateRefresh = `INSERT INTO RefreshTokens (token, scope, clientUUID, accountUUID, createdAt, updatedAt)
-	                        VALUES ($1, $2, $3, $4, now(), now())
-	                        RETURNING *`
-	const qCreateAccess = `INSERT INTO AccessTokens (token, scope, expires, clientUUID, accountUUID, createdAt, updatedAt)
+	const qCreateRefresh = `INSERT INTO RefreshTokens (token, scope, clientUUID, accountUUID, groupRestriction,
+	                                                   createdAt, updatedAt)
 	                        VALUES ($1, $2, $3, $4, $5, now(), now())
 	                        RETURNING *`
+	const qCreateAccess = `INSERT INTO AccessTokens (token, scope, expires, clientUUID, accountUUID, boundNetwork,
+	                                                 groupRestriction, createdAt, updatedAt)
+	                        VALUES ($1, $2, $3, $4, $5, $6, $7, now(), now())
+	                        RETURNING *`
 
 	if !req.AccountUUID.Valid || !req.IsApproved() {
 		return "", "", errors.New("Invalid grant request")
 	}
 
 	refresh := &RefreshToken{
-		Token:       util.RandomToken(),
-		Scope:       req.ScopeRequested,
-		ClientUUID:  req.ClientUUID,
-		AccountUUID: req.AccountUUID.String}
+		Token:            util.RandomToken(),
+		Scope:            req.ScopeRequested,
+		ClientUUID:       req.ClientUUID,
+		AccountUUID:      req.AccountUUID.String,
+		GroupRestriction: groups}
 	access := &AccessToken{
-		Token:       util.RandomToken(),
-		Scope:       req.ScopeRequested,
-		Expires:     time.Now().Add(conf.GetServerConfig().GrantReqLifeTime),
-		ClientUUID:  req.ClientUUID,
-		AccountUUID: req.AccountUUID}
+		Token:            util.RandomToken(),
+		Scope:            req.ScopeRequested,
+		Expires:          util.Now().Add(conf.GetServerConfig().GrantReqLifeTime),
+		ClientUUID:       req.ClientUUID,
+		AccountUUID:      req.AccountUUID,
+		BoundNetwork:     boundNetwork,
+		GroupRestriction: groups}
 
 	tx := database.MustBegin()
-	err := tx.Get(refresh, qCreateRefresh, refresh.Token, refresh.Scope, refresh.ClientUUID, refresh.AccountUUID)
+	err := tx.Get(refresh, qCreateRefresh, refresh.Token, refresh.Scope, refresh.ClientUUID, refresh.AccountUUID,
+		refresh.GroupRestriction)
 	if err != nil {
 		tx.Rollback()
 		return "", "", err
 	}
-	err = tx.Get(access, qCreateAccess, access.Token, access.Scope, access.Expires, access.ClientUUID, access.AccountUUID)
+	err = tx.Get(access, qCreateAccess, access.Token, access.Scope, access.Expires, access.ClientUUID, access.AccountUUID,
+		access.BoundNetwork, access.GroupRestriction)
 	if err != nil {
 		tx.Rollback()
 		return "", "", err
 	}
 	err = tx.Commit()
+	if err == nil {
+		countGrantRequest(req.ClientUUID, 0, 1)
+		RecordTokenIssued(access)
+	}
 
 	return access.Token, refresh.Token, err
 }
 
 // Create stores a new grant request.
 func (req *GrantRequest) Create() error {
-	const q = `INSERT INTO GrantRequests (token, grantType, state, code, scopeRequested, redirectUri,
+	const q = `INSERT INTO GrantRequests (token, grantType, state, code, scopeRequested, redirectUri, returnTo,
 	                                      clientUUID, accountUUID, createdAt, updatedAt)
-	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now(), now())
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, now(), now())
 	           RETURNING *`
 
 	if req.Token == "" {
 		req.Token = util.RandomToken()
 	}
 
-	return database.Get(req, q, req.Token, req.GrantType, req.State, req.Code, req.ScopeRequested,
-		req.RedirectURI, req.ClientUUID, req.AccountUUID)
+	err := database.Get(req, q, req.Token, req.GrantType, req.State, req.Code, req.ScopeRequested,
+		req.RedirectURI, req.ReturnTo, req.ClientUUID, req.AccountUUID)
+	if err == nil && isAuthorizationFlow(req.GrantType) {
+		countGrantRequest(req.ClientUUID, 1, 0)
+	}
+	return err
 }
 
 // Update an existing grant request.
 func (req *GrantRequest) Update() error {
 	const q = `UPDATE GrantRequests gr
-	           SET (grantType, state, code, scopeRequested, redirectUri, clientUUID, accountUUID, updatedAt) =
-	               ($1, $2, $3, $4, $5, $6, $7, now())
-	           WHERE token=$8
+	           SET (grantType, state, code, scopeRequested, redirectUri, returnTo, clientUUID, accountUUID, updatedAt) =
+	               ($1, $2, $3, $4, $5, $6, $7, $8, now())
+	           WHERE token=$9
 	           RETURNING *`
 
 	return database.Get(req, q, req.GrantType, req.State, req.Code, req.ScopeRequested, req.RedirectURI,
-		req.ClientUUID, req.AccountUUID, req.Token)
+		req.ReturnTo, req.ClientUUID, req.AccountUUID, req.Token)
+}
+
+// SetReturnTo stores the URL the user is sent back to when the grant request is finished, such that
+// deep links into a client survive the login, the consent page and the renewal of an expired password.
+// See Client.ValidateReturnTo for the accepted URLs. An empty URL removes the return URL.
+func (req *GrantRequest) SetReturnTo(returnTo string) error {
+	if err := req.Client().ValidateReturnTo(req.RedirectURI, returnTo); err != nil {
+		return err
+	}
+
+	req.ReturnTo = returnTo
+	return req.Update()
+}
+
+// urlOrigin returns the lower case scheme and host of an absolute http or https URL.
+// Returns false for relative URLs, other schemes and URLs with user information.
+func urlOrigin(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || u.User != nil || u.Opaque != "" {
+		return "", false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return "", false
+	}
+	return scheme + "://" + strings.ToLower(u.Host), true
 }
 
 // Delete removes an existing request from the database.
@@ -154,6 +203,16 @@ func (req *GrantRequest) Delete() error {
 	return err
 }
 
+// Complete removes a successfully finished grant request from the database
+// and counts the authorization flow as completed.
+func (req *GrantRequest) Complete() error {
+	err := req.Delete()
+	if err == nil {
+		countGrantRequest(req.ClientUUID, 0, 1)
+	}
+	return err
+}
+
 // Client returns the client associated with the grant request.
 func (req *GrantRequest) Client() *Client {
 	client, ok := GetClient(req.ClientUUID)
@@ -164,9 +223,9 @@ func (req *GrantRequest) Client() *Client {
 }
 
 // IsApproved just looks up whether the requested scope is covered by the scope
-// of an existing approval
+// of an existing approval. Stale approvals are ignored.
 func (req *GrantRequest) IsApproved() bool {
-	const q = `SELECT scope FROM ClientApprovals WHERE clientUUID = $1 AND accountUUID = $2`
+	const q = `SELECT scope FROM ClientApprovals WHERE clientUUID = $1 AND accountUUID = $2 AND NOT stale`
 
 	if !req.AccountUUID.Valid {
 		return false
@@ -176,6 +235,9 @@ func (req *GrantRequest) IsApproved() bool {
 	if req.ScopeRequested.Intersect(client.ScopeBlacklist).Len() > 0 {
 		return false
 	}
+	if CheckScopeGrant(req.AccountUUID.String, req.ClientUUID, req.ScopeRequested) != nil {
+		return false
+	}
 	if client.ScopeWhitelist.IsSuperset(req.ScopeRequested) {
 		return true
 	}
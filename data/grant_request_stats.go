@@ -0,0 +1,105 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// GrantRequestStats contains the number of authorization flows of a client which were started,
+// completed or abandoned (removed without being completed) and the number of currently pending flows.
+type GrantRequestStats struct {
+	ClientName string
+	Started    int64
+	Completed  int64
+	Abandoned  int64
+	Pending    int64
+}
+
+// isAuthorizationFlow returns true for grant requests created by an authorization request of
+// a client, which is expected to be completed by a token or code exchange.
+func isAuthorizationFlow(grantType string) bool {
+	return grantType == "code" || grantType == "token"
+}
+
+// countGrantRequest adds the given numbers to the daily statistics of a client.
+// Failures are only logged since statistics must not interrupt an authorization flow.
+func countGrantRequest(clientUUID string, started, completed int64) {
+	const q = `INSERT INTO GrantRequestStats (day, clientUUID, started, completed)
+	           VALUES (current_date, $1, $2, $3)
+	           ON CONFLICT (day, clientUUID) DO UPDATE
+	           SET started = GrantRequestStats.started + EXCLUDED.started,
+	               completed = GrantRequestStats.completed + EXCLUDED.completed`
+
+	_, err := database.Exec(q, clientUUID, started, completed)
+	if err != nil {
+		conf.GetLogEnv().Err.Errorf("Error counting grant request: %s\n", err.Error())
+	}
+}
+
+// RemoveAbandonedGrantRequests removes all grant requests which exceeded their life time
+// and counts abandoned authorization flows per client. If more grant requests are pending
+// than configured, the shorter life time for high load is used. The job acts with the
+// service token svc.
+func RemoveAbandonedGrantRequests(svc *ServiceToken) {
+	const qPending = `SELECT count(*) FROM GrantRequests`
+
+	gc := conf.GetGrantRequestGC()
+	lifeTime := conf.GetServerConfig().GrantReqLifeTime
+
+	var pending int
+	err := database.Get(&pending, qPending)
+	if err != nil {
+		panic(err)
+	}
+	if pending > gc.MaxPending && gc.LoadLifeTime < lifeTime {
+		lifeTime = gc.LoadLifeTime
+	}
+
+	removeAbandonedGrantRequests(util.Now().Add(-1 * lifeTime))
+}
+
+// removeAbandonedGrantRequests removes all grant requests created before the cutoff time and
+// counts the removed authorization flows as abandoned per client.
+func removeAbandonedGrantRequests(cutoff time.Time) {
+	const q = `WITH removed AS (DELETE FROM GrantRequests WHERE createdAt < $1 RETURNING clientUUID, grantType)
+	           INSERT INTO GrantRequestStats (day, clientUUID, abandoned)
+	           SELECT current_date, clientUUID, count(*) FROM removed
+	           WHERE grantType IN ('code', 'token')
+	           GROUP BY clientUUID
+	           ON CONFLICT (day, clientUUID) DO UPDATE
+	           SET abandoned = GrantRequestStats.abandoned + EXCLUDED.abandoned`
+
+	database.MustExec(q, cutoff)
+}
+
+// ListGrantRequestStats returns the authorization flow statistics of all clients since
+// the given day, ordered by client name.
+func ListGrantRequestStats(since time.Time) []GrantRequestStats {
+	const q = `SELECT c.name AS clientName,
+	                  COALESCE(SUM(s.started), 0) AS started,
+	                  COALESCE(SUM(s.completed), 0) AS completed,
+	                  COALESCE(SUM(s.abandoned), 0) AS abandoned,
+	                  (SELECT count(*) FROM GrantRequests g
+	                   WHERE g.clientUUID = c.uuid AND g.grantType IN ('code', 'token')) AS pending
+	           FROM Clients c LEFT JOIN GrantRequestStats s ON s.clientUUID = c.uuid AND s.day >= $1
+	           GROUP BY c.uuid, c.name
+	           ORDER BY c.name`
+
+	stats := make([]GrantRequestStats, 0)
+	err := database.Select(&stats, q, since.Format("2006-01-02"))
+	if err != nil {
+		panic(err)
+	}
+
+	return stats
+}
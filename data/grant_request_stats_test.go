@@ -0,0 +1,97 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func grantRequestStatsOf(clientName string, since time.Time) *GrantRequestStats {
+	for _, s := range ListGrantRequestStats(since) {
+		if s.ClientName == clientName {
+			return &s
+		}
+	}
+	return nil
+}
+
+func TestListGrantRequestStats(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	stats := ListGrantRequestStats(time.Now().AddDate(0, 0, -29))
+	if len(stats) != 2 {
+		t.Fatalf("Expected stats for 2 clients but got %d", len(stats))
+	}
+	gin := stats[0]
+	if gin.ClientName != "gin" || gin.Started != 30 || gin.Completed != 24 || gin.Abandoned != 3 {
+		t.Errorf("Unexpected stats for client gin: %+v", gin)
+	}
+	if gin.Pending != 3 {
+		t.Errorf("Expected 3 pending grant requests but got %d", gin.Pending)
+	}
+	wb := stats[1]
+	if wb.ClientName != "wb" || wb.Started != 8 || wb.Completed != 0 || wb.Abandoned != 6 || wb.Pending != 1 {
+		t.Errorf("Unexpected stats for client wb: %+v", wb)
+	}
+}
+
+func TestRemoveAbandonedGrantRequests(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	RemoveAbandonedGrantRequests(testServiceToken(t, JobGrantRequestGC))
+
+	if len(ListGrantRequests()) != 4 {
+		t.Error("Current grant requests should not be removed")
+	}
+	gin := grantRequestStatsOf("gin", time.Now())
+	if gin == nil || gin.Abandoned != 3 || gin.Pending != 2 {
+		t.Errorf("Expired grant request should be counted as abandoned: %+v", gin)
+	}
+}
+
+func TestRemoveExpired_GrantRequests(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	RemoveExpired(testServiceToken(t, JobCleaner))
+
+	gin := grantRequestStatsOf("gin", time.Now())
+	if gin == nil || gin.Abandoned != 3 {
+		t.Errorf("Grant requests removed by the cleaner should be counted as abandoned: %+v", gin)
+	}
+}
+
+func TestGrantRequest_Complete(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	req, ok := GetGrantRequest(grantReqWBAlice)
+	if !ok {
+		t.Fatal("Grant request does not exist")
+	}
+
+	err := req.Complete()
+	if err != nil {
+		t.Error(err)
+	}
+	_, ok = GetGrantRequest(grantReqWBAlice)
+	if ok {
+		t.Error("Grant request should not exist")
+	}
+
+	wb := grantRequestStatsOf("wb", time.Now())
+	if wb == nil || wb.Completed != 1 || wb.Pending != 0 {
+		t.Errorf("Grant request should be counted as completed: %+v", wb)
+	}
+}
@@ -89,7 +89,7 @@ func TestGrantRequest_ExchangeCodeForTokens(t *testing.T) {
 		t.Error("Grant request does not exist")
 	}
 
-	accessToken, refreshToken, err := req.ExchangeCodeForTokens()
+	accessToken, refreshToken, err := req.ExchangeCodeForTokens(sql.NullString{}, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -226,6 +226,18 @@ func TestGrantRequest_IsApproved(t *testing.T) {
 	if !request.IsApproved() {
 		t.Error("Grant request should be approved")
 	}
+
+	// request with stale approval
+	request, _ = GetGrantRequest(grantReqTokenAlice)
+	approval, _ := request.Client().ApprovalForAccount(uuidAlice)
+	approval.Stale = true
+	err := approval.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.IsApproved() {
+		t.Error("Grant request should not be approved")
+	}
 }
 
 func TestGrantRequest_Delete(t *testing.T) {
@@ -0,0 +1,593 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/pborman/uuid"
+)
+
+// Roles of group members: owners manage members and teams of a group and all its teams.
+const (
+	GroupRoleOwner  = "owner"
+	GroupRoleMember = "member"
+)
+
+// ErrLastGroupOwner is returned when the last owner of a group would be removed or demoted.
+var ErrLastGroupOwner error = &Error{Kind: ErrConflict, Message: "A group needs at least one owner"}
+
+// Group is a named set of accounts. Groups may have a parent group, in which case
+// they are sub-teams of the parent and can be managed by the owners of the parent.
+type Group struct {
+	UUID        string
+	Name        string
+	Description string
+	ParentUUID  sql.NullString
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// GroupMember is the membership of an account in a group.
+type GroupMember struct {
+	GroupUUID   string
+	GroupName   string
+	AccountUUID string
+	Login       string
+	Email       string
+	Role        string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// GetGroup returns the group with the given name.
+// Returns false if no such group exists.
+func GetGroup(name string) (*Group, bool) {
+	const q = `SELECT * FROM Groups WHERE name=$1`
+
+	group := &Group{}
+	err := database.Get(group, q, name)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return group, err == nil
+}
+
+// ListAccountGroups returns all groups the account is a member of, ordered by name.
+func ListAccountGroups(accountUUID string) []Group {
+	const q = `SELECT g.* FROM Groups g JOIN GroupMembers m ON m.groupUUID = g.uuid
+	           WHERE m.accountUUID = $1
+	           ORDER BY g.name`
+
+	groups := make([]Group, 0)
+	err := database.Select(&groups, q, accountUUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return groups
+}
+
+// ListTokenGroups returns the group memberships the access token acts for, ordered by group name.
+// These are all memberships of the account unless the token is restricted to certain groups.
+func ListTokenGroups(token *AccessToken) []GroupMember {
+	const q = `SELECT m.*, g.name AS groupName, a.login, a.email
+	           FROM GroupMembers m JOIN Groups g ON g.uuid = m.groupUUID JOIN Accounts a ON a.uuid = m.accountUUID
+	           WHERE m.accountUUID = $1 AND (cardinality($2::varchar[]) = 0 OR g.name = ANY($2::varchar[]))
+	           ORDER BY g.name`
+
+	members := make([]GroupMember, 0)
+	if !token.AccountUUID.Valid {
+		return members
+	}
+	err := database.Select(&members, q, token.AccountUUID.String, token.GroupRestriction)
+	if err != nil {
+		panic(err)
+	}
+
+	return members
+}
+
+// ValidateGroupRestriction checks whether the account with the given UUID is a member of
+// all groups a token should be restricted to.
+func ValidateGroupRestriction(accountUUID string, groups util.StringSet) *util.ValidationError {
+	if groups.Len() == 0 {
+		return nil
+	}
+
+	const q = `SELECT g.name FROM Groups g JOIN GroupMembers m ON m.groupUUID = g.uuid
+	           WHERE m.accountUUID = $1 AND g.name = ANY($2::varchar[])`
+
+	names := make([]string, 0)
+	err := database.Select(&names, q, accountUUID, groups)
+	if err != nil {
+		panic(err)
+	}
+
+	missing := groups.Difference(util.NewStringSet(names...))
+	if missing.Len() > 0 {
+		return &util.ValidationError{
+			Message:     "Invalid group restriction",
+			FieldErrors: map[string]string{"groups": fmt.Sprintf("Not a member of '%s'", strings.Join(missing.Strings(), "', '"))}}
+	}
+	return nil
+}
+
+// Validate checks the name and description of the group.
+func (g *Group) Validate() *util.ValidationError {
+	valErr := &util.ValidationError{FieldErrors: make(map[string]string)}
+	if !IsValidLogin(g.Name) {
+		valErr.FieldErrors["name"] = "Please use only letters, digits, hyphens and underscores"
+	}
+	if len(g.Description) > 2048 {
+		valErr.FieldErrors["description"] = "Please use at most 2048 characters"
+	}
+	if len(valErr.FieldErrors) > 0 {
+		valErr.Message = "Invalid group"
+		return valErr
+	}
+	return nil
+}
+
+// Create stores the group as new group in the database and adds the account with the
+// given UUID as its owner. If the UUID of the group is empty a new UUID will be generated.
+func (g *Group) Create(ownerUUID string) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	const qExists = `SELECT EXISTS (SELECT 1 FROM Groups WHERE name=$1)`
+	const qGroup = `INSERT INTO Groups (uuid, name, description, parentUUID, createdAt, updatedAt)
+	                VALUES ($1, $2, $3, $4, now(), now())
+	                RETURNING *`
+	const qOwner = `INSERT INTO GroupMembers (groupUUID, accountUUID, role, createdAt, updatedAt)
+	                VALUES ($1, $2, $3, now(), now())`
+
+	if g.UUID == "" {
+		g.UUID = uuid.NewRandom().String()
+	}
+
+	tx := database.MustBegin()
+
+	var exists bool
+	err := tx.Get(&exists, qExists, g.Name)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if exists {
+		tx.Rollback()
+		return &util.ValidationError{
+			Message:     "Invalid group",
+			FieldErrors: map[string]string{"name": "A group with this name already exists"}}
+	}
+
+	err = tx.Get(g, qGroup, g.UUID, g.Name, g.Description, g.ParentUUID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(qOwner, g.UUID, ownerUUID, GroupRoleOwner)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Parent returns the group the group is a sub-team of.
+// Returns false if the group has no parent.
+func (g *Group) Parent() (*Group, bool) {
+	if !g.ParentUUID.Valid {
+		return nil, false
+	}
+
+	const q = `SELECT * FROM Groups WHERE uuid=$1`
+
+	parent := &Group{}
+	err := database.Get(parent, q, g.ParentUUID.String)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return parent, err == nil
+}
+
+// Teams returns the direct sub-teams of the group ordered by name.
+func (g *Group) Teams() []Group {
+	const q = `SELECT * FROM Groups WHERE parentUUID=$1 ORDER BY name`
+
+	teams := make([]Group, 0)
+	err := database.Select(&teams, q, g.UUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return teams
+}
+
+// Members returns all members of the group ordered by role and login.
+func (g *Group) Members() []GroupMember {
+	const q = `SELECT m.*, g.name AS groupName, a.login, a.email
+	           FROM GroupMembers m JOIN Groups g ON g.uuid = m.groupUUID JOIN Accounts a ON a.uuid = m.accountUUID
+	           WHERE m.groupUUID = $1
+	           ORDER BY m.role DESC, a.login`
+
+	members := make([]GroupMember, 0)
+	err := database.Select(&members, q, g.UUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return members
+}
+
+// Member returns the membership of the account with the given UUID.
+// Returns false if the account is not a member of the group.
+func (g *Group) Member(accountUUID string) (*GroupMember, bool) {
+	const q = `SELECT m.*, g.name AS groupName, a.login, a.email
+	           FROM GroupMembers m JOIN Groups g ON g.uuid = m.groupUUID JOIN Accounts a ON a.uuid = m.accountUUID
+	           WHERE m.groupUUID = $1 AND m.accountUUID = $2`
+
+	member := &GroupMember{}
+	err := database.Get(member, q, g.UUID, accountUUID)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return member, err == nil
+}
+
+// IsOwner returns true if the account with the given UUID owns the group
+// or one of the groups it is a sub-team of.
+func (g *Group) IsOwner(accountUUID string) bool {
+	const q = `WITH RECURSIVE ancestors(uuid, parentUUID) AS (
+	             SELECT uuid, parentUUID FROM Groups WHERE uuid = $1
+	             UNION SELECT p.uuid, p.parentUUID FROM Groups p JOIN ancestors a ON p.uuid = a.parentUUID
+	           )
+	           SELECT EXISTS (SELECT 1 FROM GroupMembers m JOIN ancestors a ON a.uuid = m.groupUUID
+	                          WHERE m.accountUUID = $2 AND m.role = $3)`
+
+	var isOwner bool
+	err := database.Get(&isOwner, q, g.UUID, accountUUID, GroupRoleOwner)
+	if err != nil {
+		panic(err)
+	}
+
+	return isOwner
+}
+
+// AllowsToken returns true if the access token may act for the group, which is the case if the
+// token is not restricted to certain groups or if it is restricted to the group or one of its parents.
+func (g *Group) AllowsToken(token *AccessToken) bool {
+	if token.GroupRestriction.Len() == 0 {
+		return true
+	}
+
+	const q = `WITH RECURSIVE ancestors(uuid, name, parentUUID) AS (
+	             SELECT uuid, name, parentUUID FROM Groups WHERE uuid = $1
+	             UNION SELECT p.uuid, p.name, p.parentUUID FROM Groups p JOIN ancestors a ON p.uuid = a.parentUUID
+	           )
+	           SELECT EXISTS (SELECT 1 FROM ancestors WHERE name = ANY($2::varchar[]))`
+
+	var allowed bool
+	err := database.Get(&allowed, q, g.UUID, token.GroupRestriction)
+	if err != nil {
+		panic(err)
+	}
+
+	return allowed
+}
+
+// SetMember changes the role of an existing member. Accounts become members only by accepting
+// an invitation (see Invite). The last owner of a group can not be demoted.
+func (g *Group) SetMember(accountUUID, role string) error {
+	if err := validateGroupRole(role); err != nil {
+		return err
+	}
+
+	const qOwners = `SELECT COUNT(*) FROM GroupMembers WHERE groupUUID=$1 AND role=$2 AND accountUUID<>$3`
+	const qUpdate = `UPDATE GroupMembers SET (role, updatedAt) = ($3, now())
+	                 WHERE groupUUID=$1 AND accountUUID=$2`
+
+	tx := database.MustBegin()
+
+	if role != GroupRoleOwner {
+		var owners int
+		err := tx.Get(&owners, qOwners, g.UUID, GroupRoleOwner, accountUUID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if owners == 0 {
+			tx.Rollback()
+			return ErrLastGroupOwner
+		}
+	}
+
+	res, err := tx.Exec(qUpdate, g.UUID, accountUUID, role)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if n == 0 {
+		tx.Rollback()
+		return notFoundError("The account is not a member of the group")
+	}
+
+	return tx.Commit()
+}
+
+// RemoveMember removes the account with the given UUID from the group.
+// The last owner of a group can not be removed.
+func (g *Group) RemoveMember(accountUUID string) error {
+	const qOwners = `SELECT COUNT(*) FROM GroupMembers WHERE groupUUID=$1 AND role=$2 AND accountUUID<>$3`
+	const qDelete = `DELETE FROM GroupMembers WHERE groupUUID=$1 AND accountUUID=$2`
+
+	tx := database.MustBegin()
+
+	var owners int
+	err := tx.Get(&owners, qOwners, g.UUID, GroupRoleOwner, accountUUID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if owners == 0 {
+		tx.Rollback()
+		return ErrLastGroupOwner
+	}
+
+	_, err = tx.Exec(qDelete, g.UUID, accountUUID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GroupInvitation invites an account to become a member of a group with the given role.
+type GroupInvitation struct {
+	GroupUUID   string
+	GroupName   string
+	AccountUUID string
+	Login       string
+	Role        string
+	InvitedBy   sql.NullString
+	Expires     time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Invite invites the account with the given UUID to the group. The account becomes a member with
+// the given role once it accepts the invitation. Inviting an account again renews the invitation.
+// Returns an error of kind ErrConflict if the account is already a member.
+func (g *Group) Invite(accountUUID, role, invitedBy string) (*GroupInvitation, error) {
+	if err := validateGroupRole(role); err != nil {
+		return nil, err
+	}
+
+	const qMember = `SELECT EXISTS (SELECT 1 FROM GroupMembers WHERE groupUUID=$1 AND accountUUID=$2)`
+	const qUpsert = `INSERT INTO GroupInvitations (groupUUID, accountUUID, role, invitedBy, expires, createdAt, updatedAt)
+	                 VALUES ($1, $2, $3, $4, $5, now(), now())
+	                 ON CONFLICT (groupUUID, accountUUID) DO UPDATE
+	                 SET (role, invitedBy, expires, updatedAt) = (EXCLUDED.role, EXCLUDED.invitedBy, EXCLUDED.expires, now())`
+
+	tx := database.MustBegin()
+
+	var isMember bool
+	err := tx.Get(&isMember, qMember, g.UUID, accountUUID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if isMember {
+		tx.Rollback()
+		return nil, conflictError("The account is already a member of the group")
+	}
+
+	by := sql.NullString{String: invitedBy, Valid: invitedBy != ""}
+	expires := util.Now().Add(conf.GetGroups().InvitationLifeTime)
+	_, err = tx.Exec(qUpsert, g.UUID, accountUUID, role, by, expires)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, _ := g.Invitation(accountUUID)
+	return invitation, nil
+}
+
+// Invitations returns all pending invitations of the group ordered by login.
+func (g *Group) Invitations() []GroupInvitation {
+	const q = `SELECT i.*, g.name AS groupName, a.login
+	           FROM GroupInvitations i JOIN Groups g ON g.uuid = i.groupUUID JOIN Accounts a ON a.uuid = i.accountUUID
+	           WHERE i.groupUUID = $1 AND i.expires > $2
+	           ORDER BY a.login`
+
+	invitations := make([]GroupInvitation, 0)
+	err := database.Select(&invitations, q, g.UUID, expiryTime())
+	if err != nil {
+		panic(err)
+	}
+
+	return invitations
+}
+
+// Invitation returns the pending invitation of the account with the given UUID.
+// Returns false if the account was not invited or the invitation expired.
+func (g *Group) Invitation(accountUUID string) (*GroupInvitation, bool) {
+	const q = `SELECT i.*, g.name AS groupName, a.login
+	           FROM GroupInvitations i JOIN Groups g ON g.uuid = i.groupUUID JOIN Accounts a ON a.uuid = i.accountUUID
+	           WHERE i.groupUUID = $1 AND i.accountUUID = $2 AND i.expires > $3`
+
+	invitation := &GroupInvitation{}
+	err := database.Get(invitation, q, g.UUID, accountUUID, expiryTime())
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return invitation, err == nil
+}
+
+// AcceptInvitation makes the invited account a member of the group with the role of the invitation.
+// Returns an error of kind ErrNotFound if there is no pending invitation.
+func (g *Group) AcceptInvitation(accountUUID string) error {
+	const qInvitation = `DELETE FROM GroupInvitations WHERE groupUUID=$1 AND accountUUID=$2 AND expires > $3
+	                     RETURNING role`
+	const qMember = `INSERT INTO GroupMembers (groupUUID, accountUUID, role, createdAt, updatedAt)
+	                 VALUES ($1, $2, $3, now(), now())
+	                 ON CONFLICT (groupUUID, accountUUID) DO NOTHING`
+
+	tx := database.MustBegin()
+
+	var role string
+	err := tx.Get(&role, qInvitation, g.UUID, accountUUID, expiryTime())
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return notFoundError("No pending invitation to the group %s", g.Name)
+	}
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(qMember, g.UUID, accountUUID, role)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RemoveInvitation removes the invitation of the account with the given UUID, either because
+// the account declined it or an owner revoked it.
+// Returns an error of kind ErrNotFound if there is no pending invitation.
+func (g *Group) RemoveInvitation(accountUUID string) error {
+	const q = `DELETE FROM GroupInvitations WHERE groupUUID=$1 AND accountUUID=$2 AND expires > $3`
+
+	res, err := database.Exec(q, g.UUID, accountUUID, expiryTime())
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return notFoundError("No pending invitation to the group %s", g.Name)
+	}
+	return nil
+}
+
+// validateGroupRole checks that role is one of the roles of group members.
+func validateGroupRole(role string) error {
+	if role != GroupRoleOwner && role != GroupRoleMember {
+		return &util.ValidationError{
+			Message:     "Invalid member role",
+			FieldErrors: map[string]string{"role": "Role must be one of 'owner' or 'member'"}}
+	}
+	return nil
+}
+
+// GroupMarshaler converts a group and its members into JSON.
+type GroupMarshaler struct {
+	Group   *Group
+	Parent  *Group
+	Members []GroupMember
+	Teams   []Group
+}
+
+// MarshalJSON implements Marshaler for GroupMarshaler.
+func (gm *GroupMarshaler) MarshalJSON() ([]byte, error) {
+	jsonData := &struct {
+		URL         string        `json:"url"`
+		Name        string        `json:"name"`
+		Description string        `json:"description"`
+		Parent      *string       `json:"parent,omitempty"`
+		MembersURL  string        `json:"members_url"`
+		Members     []GroupMember `json:"members"`
+		Teams       []string      `json:"teams"`
+		CreatedAt   time.Time     `json:"created_at"`
+		UpdatedAt   time.Time     `json:"updated_at"`
+	}{
+		URL:         conf.MakeUrl("/api/v1/groups/%s", gm.Group.Name),
+		Name:        gm.Group.Name,
+		Description: gm.Group.Description,
+		MembersURL:  conf.MakeUrl("/api/v1/groups/%s/members", gm.Group.Name),
+		Members:     gm.Members,
+		Teams:       make([]string, 0, len(gm.Teams)),
+		CreatedAt:   gm.Group.CreatedAt,
+		UpdatedAt:   gm.Group.UpdatedAt,
+	}
+	if gm.Parent != nil {
+		jsonData.Parent = &gm.Parent.Name
+	}
+	for _, team := range gm.Teams {
+		jsonData.Teams = append(jsonData.Teams, team.Name)
+	}
+	return json.Marshal(jsonData)
+}
+
+// MarshalJSON implements Marshaler for GroupMember.
+func (m GroupMember) MarshalJSON() ([]byte, error) {
+	jsonData := &struct {
+		Group      string    `json:"group"`
+		Login      string    `json:"login"`
+		AccountURL string    `json:"account_url"`
+		Role       string    `json:"role"`
+		CreatedAt  time.Time `json:"created_at"`
+	}{
+		Group:      m.GroupName,
+		Login:      m.Login,
+		AccountURL: conf.MakeUrl("/api/v1/accounts/%s", m.Login),
+		Role:       m.Role,
+		CreatedAt:  m.CreatedAt,
+	}
+	return json.Marshal(jsonData)
+}
+
+// MarshalJSON implements Marshaler for GroupInvitation.
+func (i GroupInvitation) MarshalJSON() ([]byte, error) {
+	jsonData := &struct {
+		Group      string    `json:"group"`
+		Login      string    `json:"login"`
+		AccountURL string    `json:"account_url"`
+		Role       string    `json:"role"`
+		Expires    time.Time `json:"expires"`
+		CreatedAt  time.Time `json:"created_at"`
+	}{
+		Group:      i.GroupName,
+		Login:      i.Login,
+		AccountURL: conf.MakeUrl("/api/v1/accounts/%s", i.Login),
+		Role:       i.Role,
+		Expires:    i.Expires,
+		CreatedAt:  i.CreatedAt,
+	}
+	return json.Marshal(jsonData)
+}
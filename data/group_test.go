@@ -0,0 +1,229 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+const uuidJohn = "03dcd573-1cce-4eb1-8b33-73860575da65"
+
+func TestGetGroup(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	group, ok := GetGroup("lmu-neuro")
+	if !ok {
+		t.Fatal("Group 'lmu-neuro' expected to exist")
+	}
+	if len(group.Members()) != 2 {
+		t.Errorf("Two members expected but was %d", len(group.Members()))
+	}
+	if teams := group.Teams(); len(teams) != 1 || teams[0].Name != "lmu-neuro-ephys" {
+		t.Errorf("Team 'lmu-neuro-ephys' expected but was %v", teams)
+	}
+
+	_, ok = GetGroup("doesnotexist")
+	if ok {
+		t.Error("Group should not exist")
+	}
+
+	if len(ListAccountGroups(uuidBob)) != 1 {
+		t.Error("Bob expected to be member of one group")
+	}
+}
+
+func TestGroupIsOwner(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	team, _ := GetGroup("lmu-neuro-ephys")
+	if !team.IsOwner(uuidJohn) {
+		t.Error("John expected to own the team")
+	}
+	if !team.IsOwner(uuidAlice) {
+		t.Error("Alice expected to own the team via the parent group")
+	}
+	if team.IsOwner(uuidBob) {
+		t.Error("Bob should not own the team")
+	}
+
+	group, _ := GetGroup("lmu-neuro")
+	if group.IsOwner(uuidJohn) {
+		t.Error("John should not own the parent group")
+	}
+}
+
+func TestGroupCreate(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	group := &Group{Name: "invalid name"}
+	if err := group.Create(uuidBob); err == nil {
+		t.Error("Group with invalid name should not be created")
+	}
+	group = &Group{Name: "lmu-neuro"}
+	if err := group.Create(uuidBob); err == nil {
+		t.Error("Group with existing name should not be created")
+	}
+
+	parent, _ := GetGroup("lmu-neuro")
+	group = &Group{Name: "lmu-neuro-imaging", ParentUUID: sql.NullString{String: parent.UUID, Valid: true}}
+	err := group.Create(uuidBob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, ok := group.Member(uuidBob)
+	if !ok || member.Role != GroupRoleOwner {
+		t.Error("Bob expected to own the new team")
+	}
+	if len(parent.Teams()) != 2 {
+		t.Error("Parent group expected to have two teams")
+	}
+}
+
+func TestGroupMembers(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	group, _ := GetGroup("lmu-neuro")
+
+	if err := group.SetMember(uuidJohn, "admin"); err == nil {
+		t.Error("Invalid role should be rejected")
+	}
+	if err := group.SetMember(uuidAlice, GroupRoleMember); err != ErrLastGroupOwner {
+		t.Errorf("ErrLastGroupOwner expected but was %v", err)
+	}
+	if err := group.RemoveMember(uuidAlice); err != ErrLastGroupOwner {
+		t.Errorf("ErrLastGroupOwner expected but was %v", err)
+	}
+
+	if err := group.SetMember(uuidJohn, GroupRoleMember); KindOf(err) != ErrNotFound {
+		t.Errorf("Error of kind ErrNotFound expected for an account which is not a member but was %v", err)
+	}
+	_, err := group.Invite(uuidJohn, GroupRoleMember, uuidAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = group.AcceptInvitation(uuidJohn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = group.SetMember(uuidBob, GroupRoleOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member, ok := group.Member(uuidBob); !ok || member.Role != GroupRoleOwner {
+		t.Error("Bob expected to be owner")
+	}
+
+	err = group.RemoveMember(uuidAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := group.Member(uuidAlice); ok {
+		t.Error("Alice should not be a member any more")
+	}
+	if len(group.Members()) != 2 {
+		t.Errorf("Two members expected but was %d", len(group.Members()))
+	}
+}
+
+func TestGroupInvitations(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	group, _ := GetGroup("lmu-neuro")
+	if _, err := group.Invite(uuidBob, GroupRoleMember, uuidAlice); KindOf(err) != ErrConflict {
+		t.Errorf("Error of kind ErrConflict expected for a member but was %v", err)
+	}
+	if _, err := group.Invite(uuidJohn, "admin", uuidAlice); err == nil {
+		t.Error("Invalid role should be rejected")
+	}
+
+	invitation, err := group.Invite(uuidJohn, GroupRoleOwner, uuidAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invitation.Login != "john" || invitation.Role != GroupRoleOwner || invitation.InvitedBy.String != uuidAlice {
+		t.Errorf("Unexpected invitation: %+v", invitation)
+	}
+	if _, ok := group.Member(uuidJohn); ok {
+		t.Error("John should not be a member before accepting the invitation")
+	}
+	if len(group.Invitations()) != 1 {
+		t.Errorf("One invitation expected but was %d", len(group.Invitations()))
+	}
+
+	err = group.RemoveInvitation(uuidJohn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = group.AcceptInvitation(uuidJohn); KindOf(err) != ErrNotFound {
+		t.Errorf("Error of kind ErrNotFound expected for a declined invitation but was %v", err)
+	}
+
+	// invitation of the fixtures
+	team, _ := GetGroup("lmu-neuro-ephys")
+	err = team.AcceptInvitation(uuidAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member, ok := team.Member(uuidAlice); !ok || member.Role != GroupRoleMember {
+		t.Error("Alice expected to be a member after accepting the invitation")
+	}
+	if _, ok := team.Invitation(uuidAlice); ok {
+		t.Error("Accepted invitation should not exist")
+	}
+
+	// expired invitations can not be accepted
+	_, err = group.Invite(uuidJohn, GroupRoleMember, uuidAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.SetClock(util.SetClock(util.FixedClock(time.Now().Add(conf.GetGroups().InvitationLifeTime + time.Hour))))
+	if err = group.AcceptInvitation(uuidJohn); KindOf(err) != ErrNotFound {
+		t.Errorf("Error of kind ErrNotFound expected for an expired invitation but was %v", err)
+	}
+}
+
+func TestGroupRestriction(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	if err := ValidateGroupRestriction(uuidAlice, util.NewStringSet("lmu-neuro")); err != nil {
+		t.Error(err)
+	}
+	if err := ValidateGroupRestriction(uuidAlice, util.NewStringSet("lmu-neuro", "lmu-neuro-ephys")); err == nil {
+		t.Error("Restriction to a group without membership should fail")
+	}
+
+	token := &AccessToken{AccountUUID: sql.NullString{String: uuidAlice, Valid: true}}
+	if len(ListTokenGroups(token)) != 1 {
+		t.Error("Unrestricted token expected to act for all groups")
+	}
+
+	team, _ := GetGroup("lmu-neuro-ephys")
+	token.GroupRestriction = util.NewStringSet("lmu-neuro")
+	if !team.AllowsToken(token) {
+		t.Error("Token restricted to the parent group expected to act for the team")
+	}
+	token.GroupRestriction = util.NewStringSet("other")
+	if team.AllowsToken(token) {
+		t.Error("Token restricted to another group should not act for the team")
+	}
+	if len(ListTokenGroups(token)) != 0 {
+		t.Error("No groups expected for token restricted to another group")
+	}
+}
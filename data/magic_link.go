@@ -0,0 +1,102 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Time after which an unused magic link expires
+const magicLinkLifeTime = 15 * time.Minute
+
+// MagicLink is a single-use token sent by e-mail, which logs the account in
+// and continues the associated grant request.
+type MagicLink struct {
+	Token        string
+	AccountUUID  string
+	GrantRequest string
+	Expires      time.Time
+	CreatedAt    time.Time
+}
+
+// CreateMagicLink creates a magic link for an account and a grant request.
+// Previous magic links of the account are invalidated.
+func CreateMagicLink(accountUUID, grantRequest string) (*MagicLink, error) {
+	const qDelete = `DELETE FROM MagicLinks WHERE accountUUID=$1`
+	const qInsert = `INSERT INTO MagicLinks (token, accountUUID, grantRequest, expires, createdAt)
+	                 VALUES ($1, $2, $3, $4, now())
+	                 RETURNING *`
+
+	tx := database.MustBegin()
+	_, err := tx.Exec(qDelete, accountUUID)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	link := &MagicLink{}
+	err = tx.Get(link, qInsert, util.RandomToken(), accountUUID, grantRequest, util.Now().Add(magicLinkLifeTime))
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	return link, tx.Commit()
+}
+
+// GetMagicLink returns the valid magic link with the given token without using it.
+// Returns false if no such link exists or if it is expired.
+func GetMagicLink(token string) (*MagicLink, bool) {
+	const q = `SELECT * FROM MagicLinks WHERE token=$1 AND expires > $2`
+
+	link := &MagicLink{}
+	err := database.Get(link, q, token, expiryTime())
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return link, err == nil
+}
+
+// UseMagicLink returns and removes the magic link with the given token.
+// Returns an error of kind ErrNotFound or ErrExpired if the link can not be used.
+func UseMagicLink(token string) (*MagicLink, error) {
+	const q = `DELETE FROM MagicLinks WHERE token=$1 RETURNING *`
+
+	link := &MagicLink{}
+	err := database.Get(link, q, token)
+	if err == sql.ErrNoRows {
+		return nil, notFoundError("Magic link does not exist")
+	}
+	if err != nil {
+		panic(err)
+	}
+	if !link.Expires.After(expiryTime()) {
+		return nil, expiredError("Magic link is expired")
+	}
+
+	return link, nil
+}
+
+// UpdateMagicLinkEnabled enables or disables the login via magic link for the account.
+// Disabling removes all magic links of the account.
+func (acc *Account) UpdateMagicLinkEnabled(enabled bool) error {
+	const q = `UPDATE Accounts SET isMagicLinkEnabled=$1 WHERE uuid=$2 RETURNING *`
+	const qDelete = `DELETE FROM MagicLinks WHERE accountUUID=$1`
+
+	err := database.Get(acc, q, enabled, acc.UUID)
+	if err != nil || enabled {
+		return err
+	}
+	_, err = database.Exec(qDelete, acc.UUID)
+	return err
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestUseMagicLink(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	if _, ok := GetMagicLink("EXP1R3DL"); ok {
+		t.Error("Expired magic link should not be returned")
+	}
+	if link, ok := GetMagicLink("M4G1CL1K"); !ok || link.AccountUUID != uuidAlice {
+		t.Error("Magic link expected to be returned without being used")
+	}
+
+	_, err := UseMagicLink("EXP1R3DL")
+	if KindOf(err) != ErrExpired {
+		t.Errorf("Expired magic link should not be usable: %v", err)
+	}
+
+	link, err := UseMagicLink("M4G1CL1K")
+	if err != nil {
+		t.Fatal("Magic link expected to be usable")
+	}
+	if link.AccountUUID != uuidAlice || link.GrantRequest != "U7JIKKYI" {
+		t.Error("Magic link has wrong account or grant request")
+	}
+
+	_, err = UseMagicLink("M4G1CL1K")
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Magic link should only be usable once: %v", err)
+	}
+}
+
+func TestCreateMagicLink(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	link, err := CreateMagicLink(uuidAlice, "QH92T99D")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// previous links are invalidated
+	if _, err := UseMagicLink("M4G1CL1K"); err == nil {
+		t.Error("Previous magic link should be removed")
+	}
+	if _, err := UseMagicLink(link.Token); err != nil {
+		t.Error("New magic link expected to be usable")
+	}
+}
+
+func TestAccount_UpdateMagicLinkEnabled(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	alice, _ := GetAccount(uuidAlice)
+	if !alice.IsMagicLinkEnabled {
+		t.Error("Magic link expected to be enabled for alice")
+	}
+
+	err := alice.UpdateMagicLinkEnabled(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.IsMagicLinkEnabled {
+		t.Error("Magic link expected to be disabled")
+	}
+	if _, err := UseMagicLink("M4G1CL1K"); err == nil {
+		t.Error("Magic links should be removed when disabled")
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+// Interval in which the maintenance state set on other instances is loaded
+const maintenanceSyncInterval = 10 * time.Second
+
+// maintenanceState is the maintenance mode stored by an administrator.
+type maintenanceState struct {
+	ID         bool
+	Enabled    bool
+	Message    string
+	RetryAfter int // in seconds
+	UpdatedBy  sql.NullString
+	UpdatedAt  time.Time
+}
+
+// SaveMaintenance changes the maintenance state and stores it, such that it survives restarts
+// and applies to all instances. Empty values for message and retry interval are replaced by the
+// configured defaults.
+func SaveMaintenance(m conf.Maintenance, changedBy string) error {
+	const q = `INSERT INTO MaintenanceState (id, enabled, message, retryAfter, updatedBy, updatedAt)
+	           VALUES (TRUE, $1, $2, $3, $4, now())
+	           ON CONFLICT (id) DO UPDATE
+	           SET (enabled, message, retryAfter, updatedBy, updatedAt) =
+	               (EXCLUDED.enabled, EXCLUDED.message, EXCLUDED.retryAfter, EXCLUDED.updatedBy, now())`
+
+	conf.SetMaintenance(m)
+	m = conf.GetMaintenance()
+
+	by := sql.NullString{String: changedBy, Valid: changedBy != ""}
+	_, err := database.Exec(q, m.Enabled, m.Message, int(m.RetryAfter/time.Second), by)
+	return err
+}
+
+// LoadMaintenance applies the stored maintenance state. The configured state is kept if no
+// state was stored yet.
+func LoadMaintenance() error {
+	const q = `SELECT * FROM MaintenanceState`
+
+	state := &maintenanceState{}
+	err := database.Get(state, q)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	conf.SetMaintenance(conf.Maintenance{
+		Enabled:    state.Enabled,
+		Message:    state.Message,
+		RetryAfter: time.Duration(state.RetryAfter) * time.Second,
+	})
+	return nil
+}
+
+// RunMaintenanceSync starts an infinite loop which periodically loads the maintenance state,
+// such that changes made on other instances apply.
+func RunMaintenanceSync() {
+	go func() {
+		t := time.NewTicker(maintenanceSyncInterval)
+		defer t.Stop()
+		for range t.C {
+			err := LoadMaintenance()
+			if err != nil {
+				conf.GetLogEnv().Err.Errorf("Error loading maintenance state: %s\n", err.Error())
+			}
+		}
+	}()
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestSaveMaintenance(t *testing.T) {
+	defer util.FailOnPanic(t)
+	defer conf.SetMaintenance(conf.Maintenance{Enabled: false})
+	InitTestDb(t)
+
+	// nothing stored yet, the current state is kept
+	conf.SetMaintenance(conf.Maintenance{Enabled: false})
+	err := LoadMaintenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.GetMaintenance().Enabled {
+		t.Error("Maintenance mode expected to be disabled")
+	}
+
+	err = SaveMaintenance(conf.Maintenance{Enabled: true, Message: "Migration", RetryAfter: 2 * time.Minute}, uuidAlice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := conf.GetMaintenance(); !m.Enabled || m.Message != "Migration" {
+		t.Errorf("Maintenance mode expected to be enabled: %+v", m)
+	}
+
+	// a restart or another instance loads the stored state
+	conf.SetMaintenance(conf.Maintenance{Enabled: false})
+	err = LoadMaintenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := conf.GetMaintenance(); !m.Enabled || m.Message != "Migration" || m.RetryAfter != 2*time.Minute {
+		t.Errorf("Stored maintenance mode expected: %+v", m)
+	}
+
+	err = SaveMaintenance(conf.Maintenance{Enabled: false}, uuidBob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.SetMaintenance(conf.Maintenance{Enabled: true})
+	err = LoadMaintenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.GetMaintenance().Enabled {
+		t.Error("Stored maintenance mode expected to be disabled")
+	}
+}
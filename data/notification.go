@@ -0,0 +1,156 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Notification modes of an account: notifications are either sent as one e-mail
+// per notification or collected and sent as a daily digest.
+const (
+	NotifyImmediate = "immediate"
+	NotifyDaily     = "daily"
+)
+
+// Period after which collected notifications are sent as digest
+const digestPeriod = 24 * time.Hour
+
+// Notification is a message to the owner of an account, which is waiting to be sent.
+type Notification struct {
+	Id          int
+	AccountUUID string
+	Subject     string
+	Body        string
+	CreatedAt   time.Time
+}
+
+// ListNotifications returns all notifications of an account waiting to be sent, oldest first.
+func ListNotifications(accountUUID string) []Notification {
+	const q = `SELECT * FROM Notifications WHERE accountUUID=$1 ORDER BY createdAt, id`
+
+	notifications := make([]Notification, 0)
+	err := database.Select(&notifications, q, accountUUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return notifications
+}
+
+// Notify queues a notification to the account owner. Depending on the notification mode
+// of the account, the notification is sent with the next e-mail dispatch or as part of a
+// daily digest.
+func (acc *Account) Notify(subject, body string) error {
+	const q = `INSERT INTO Notifications (accountUUID, subject, body, createdAt)
+	           VALUES ($1, $2, $3, now())`
+
+	_, err := database.Exec(q, acc.UUID, subject, body)
+	return err
+}
+
+// UpdateNotificationMode sets the notification mode of the account.
+func (acc *Account) UpdateNotificationMode(mode string) error {
+	if mode != NotifyImmediate && mode != NotifyDaily {
+		return &util.ValidationError{
+			Message:     "Invalid notification mode",
+			FieldErrors: map[string]string{"mode": "Mode must be one of 'immediate' or 'daily'"}}
+	}
+
+	const q = `UPDATE Accounts SET notificationMode=$1 WHERE uuid=$2 RETURNING *`
+	return database.Get(acc, q, mode, acc.UUID)
+}
+
+// DispatchNotifications converts pending notifications into e-mails: notifications of accounts
+// in immediate mode are sent one by one, notifications of accounts in daily mode are grouped into
+// a digest once the oldest notification is older than a day.
+func DispatchNotifications() error {
+	const qDue = `SELECT a.* FROM ActiveAccounts a
+	              WHERE EXISTS (SELECT 1 FROM Notifications n WHERE n.accountUUID = a.uuid
+	                            AND (a.notificationMode <> $1 OR n.createdAt <= $2))`
+	const qDelete = `DELETE FROM Notifications WHERE accountUUID=$1 AND id <= $2`
+
+	accounts := make([]Account, 0)
+	err := database.Select(&accounts, qDue, NotifyDaily, util.Now().Add(-digestPeriod))
+	if err != nil {
+		return err
+	}
+
+	for i := range accounts {
+		acc := &accounts[i]
+		notifications := ListNotifications(acc.UUID)
+		if len(notifications) == 0 {
+			continue
+		}
+
+		if acc.NotificationMode == NotifyDaily {
+			err = sendDigest(acc, notifications)
+		} else {
+			for _, n := range notifications {
+				err = sendNotification(acc, &n)
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = database.Exec(qDelete, acc.UUID, notifications[len(notifications)-1].Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendNotification queues a single notification as e-mail.
+func sendNotification(acc *Account, n *Notification) error {
+	tmplFields := &struct {
+		From    string
+		To      string
+		Subject string
+		Body    string
+	}{
+		conf.GetSmtpCredentials().From,
+		acc.Email,
+		n.Subject,
+		n.Body,
+	}
+	content := util.MakeEmailTemplate("emailplain.txt", tmplFields)
+	email := &Email{}
+	return email.Create(util.NewStringSet(acc.Email), content.Bytes())
+}
+
+// sendDigest queues all given notifications as a single digest e-mail.
+func sendDigest(acc *Account, notifications []Notification) error {
+	tmplFields := &struct {
+		From          string
+		To            string
+		Subject       string
+		Login         string
+		Notifications []Notification
+		Time          *util.TimeFormat
+	}{
+		conf.GetSmtpCredentials().From,
+		acc.Email,
+		"Your GIN notifications",
+		acc.Login,
+		notifications,
+		acc.TimeFormat(),
+	}
+	content := util.MakeEmailTemplate("emaildigest.txt", tmplFields)
+	email := &Email{}
+	return email.Create(util.NewStringSet(acc.Email), content.Bytes())
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestAccount_Notify(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	john, _ := GetAccountByLogin("john")
+	err := john.Notify("Subject", "Body")
+	if err != nil {
+		t.Error(err)
+	}
+
+	notifications := ListNotifications(john.UUID)
+	if len(notifications) != 1 || notifications[0].Subject != "Subject" {
+		t.Error("Notification expected")
+	}
+}
+
+func TestAccount_UpdateNotificationMode(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	alice, _ := GetAccountByLogin("alice")
+	if alice.NotificationMode != NotifyImmediate {
+		t.Error("Notification mode of alice expected to be 'immediate'")
+	}
+
+	err := alice.UpdateNotificationMode("weekly")
+	if err == nil {
+		t.Error("Invalid mode should fail")
+	}
+	err = alice.UpdateNotificationMode(NotifyDaily)
+	if err != nil {
+		t.Error(err)
+	}
+	alice, _ = GetAccountByLogin("alice")
+	if alice.NotificationMode != NotifyDaily {
+		t.Error("Notification mode of alice expected to be 'daily'")
+	}
+}
+
+func TestDispatchNotifications(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	before, _ := GetQueuedEmails()
+
+	err := DispatchNotifications()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one e-mail for alice and one digest for bob
+	after, _ := GetQueuedEmails()
+	if len(after) != len(before)+2 {
+		t.Errorf("Expected 2 e-mails but got %d", len(after)-len(before))
+	}
+	if len(ListNotifications(uuidAlice)) != 0 {
+		t.Error("Notifications of alice should be sent")
+	}
+	bob, _ := GetAccountByLogin("bob")
+	if len(ListNotifications(bob.UUID)) != 0 {
+		t.Error("Notifications of bob should be sent as digest")
+	}
+
+	// the digest of bob is not due
+	bob.Notify("Subject", "Body")
+	err = DispatchNotifications()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ListNotifications(bob.UUID)) != 1 {
+		t.Error("Notification of bob should wait for the next digest")
+	}
+}
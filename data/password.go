@@ -0,0 +1,188 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/G-Node/gin-auth/util"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// PasswordVerifier checks plain text passwords against password hashes of a certain format.
+// Verifiers for hash formats of other systems can be registered with RegisterPasswordVerifier
+// in order to import accounts from those systems. Hashes verified by a verifier other than
+// the default bcrypt verifier are replaced by a bcrypt hash on the next successful login.
+type PasswordVerifier interface {
+	// Name returns a short name of the hash format.
+	Name() string
+	// Handles returns true if the hash has the format supported by the verifier.
+	Handles(hash string) bool
+	// Verify returns true if the hash matches the plain text password.
+	Verify(hash, plain string) bool
+}
+
+var passwordVerifiers = []PasswordVerifier{
+	&bcryptVerifier{},
+	&djangoVerifier{},
+	&gogsVerifier{},
+	&ldapSHAVerifier{},
+}
+var passwordVerifiersLock = sync.Mutex{}
+
+// RegisterPasswordVerifier adds a verifier for an additional hash format. Verifiers registered
+// later take precedence over those registered before.
+func RegisterPasswordVerifier(v PasswordVerifier) {
+	passwordVerifiersLock.Lock()
+	defer passwordVerifiersLock.Unlock()
+
+	passwordVerifiers = append([]PasswordVerifier{v}, passwordVerifiers...)
+}
+
+// findPasswordVerifier returns the verifier which handles the given hash.
+func findPasswordVerifier(hash string) (PasswordVerifier, bool) {
+	passwordVerifiersLock.Lock()
+	defer passwordVerifiersLock.Unlock()
+
+	for _, v := range passwordVerifiers {
+		if v.Handles(hash) {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// hashPassword creates a bcrypt hash from a plain text password.
+func hashPassword(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	return string(hash), err
+}
+
+// dummyHash is verified instead of a missing or unsupported hash, such that verifying the password
+// of an unknown account takes about as long as for an existing account.
+var dummyHash = struct {
+	sync.Once
+	hash []byte
+}{}
+
+// verifyDummyPassword takes the time of verifying a bcrypt hash with the default cost.
+func verifyDummyPassword(plain string) {
+	dummyHash.Do(func() {
+		hash, err := hashPassword(util.RandomToken()[:40])
+		if err != nil {
+			panic(err)
+		}
+		dummyHash.hash = []byte(hash)
+	})
+	bcrypt.CompareHashAndPassword(dummyHash.hash, []byte(plain))
+}
+
+// bcryptVerifier verifies bcrypt hashes, which is the format used by gin-auth.
+type bcryptVerifier struct{}
+
+func (v *bcryptVerifier) Name() string {
+	return "bcrypt"
+}
+
+func (v *bcryptVerifier) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func (v *bcryptVerifier) Verify(hash, plain string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) == nil
+}
+
+// djangoVerifier verifies hashes of the Django PBKDF2 hasher:
+// "pbkdf2_sha256$<iterations>$<salt>$<base64 hash>"
+type djangoVerifier struct{}
+
+func (v *djangoVerifier) Name() string {
+	return "django"
+}
+
+func (v *djangoVerifier) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "pbkdf2_sha256$")
+}
+
+func (v *djangoVerifier) Verify(hash, plain string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 {
+		return false
+	}
+	iter, err := strconv.Atoi(parts[1])
+	if err != nil || iter <= 0 {
+		return false
+	}
+	expected, err := base64.StdEncoding.DecodeString(parts[3])
+	if err != nil || len(expected) < sha256.Size {
+		return false
+	}
+
+	actual := pbkdf2.Key([]byte(plain), []byte(parts[2]), iter, len(expected), sha256.New)
+	return subtle.ConstantTimeCompare(expected, actual) == 1
+}
+
+// gogsVerifier verifies Gogs and Gitea password hashes. Since those store hash and salt
+// in separate columns, the imported hash must be of the form "gogs$<salt>$<hex hash>".
+type gogsVerifier struct{}
+
+func (v *gogsVerifier) Name() string {
+	return "gogs"
+}
+
+func (v *gogsVerifier) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "gogs$")
+}
+
+func (v *gogsVerifier) Verify(hash, plain string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 3 {
+		return false
+	}
+	expected, err := hex.DecodeString(parts[2])
+	if err != nil || len(expected) < sha256.Size {
+		return false
+	}
+
+	actual := pbkdf2.Key([]byte(plain), []byte(parts[1]), 10000, len(expected), sha256.New)
+	return subtle.ConstantTimeCompare(expected, actual) == 1
+}
+
+// ldapSHAVerifier verifies salted and unsalted SHA-1 hashes as used by LDAP servers:
+// "{SSHA}<base64 hash and salt>" and "{SHA}<base64 hash>"
+type ldapSHAVerifier struct{}
+
+func (v *ldapSHAVerifier) Name() string {
+	return "ldap-sha"
+}
+
+func (v *ldapSHAVerifier) Handles(hash string) bool {
+	return strings.HasPrefix(hash, "{SSHA}") || strings.HasPrefix(hash, "{SHA}")
+}
+
+func (v *ldapSHAVerifier) Verify(hash, plain string) bool {
+	salted := strings.HasPrefix(hash, "{SSHA}")
+	encoded := strings.TrimPrefix(strings.TrimPrefix(hash, "{SSHA}"), "{SHA}")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(decoded) < sha1.Size || (!salted && len(decoded) != sha1.Size) {
+		return false
+	}
+
+	expected, salt := decoded[:sha1.Size], decoded[sha1.Size:]
+	actual := sha1.Sum(append([]byte(plain), salt...))
+	return subtle.ConstantTimeCompare(expected, actual[:]) == 1
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+var breachChecker util.BreachChecker
+var breachCheckerLock = sync.Mutex{}
+
+// getBreachChecker returns the breached password checker configured in the breached
+// password settings: an offline dataset if a directory is configured, otherwise the range API.
+func getBreachChecker() util.BreachChecker {
+	breachCheckerLock.Lock()
+	defer breachCheckerLock.Unlock()
+
+	if breachChecker == nil {
+		config := conf.GetBreachedPasswords()
+		if config.Dir != "" {
+			breachChecker = util.NewPwnedDirChecker(config.Dir, config.CacheTime)
+		} else {
+			breachChecker = util.NewPwnedAPIChecker(config.APIURL, config.Timeout, config.CacheTime)
+		}
+	}
+	return breachChecker
+}
+
+// ValidateNewPassword checks a password which is about to be set against the password strength
+// policy and, if enabled, against the dataset of breached passwords. Breached passwords are
+// rejected with an error or, in mode warn, accepted with a warning for the user (see
+// SetPasswordBreached). If the dataset
+// is not available the password is accepted and the failure is logged.
+func ValidateNewPassword(plain string, userInputs ...string) (warning string, err error) {
+	_, err = CheckPasswordStrength(plain, userInputs...)
+	if err != nil {
+		return "", err
+	}
+
+	mode := conf.GetBreachedPasswords().Mode
+	if mode == conf.BreachCheckOff {
+		return "", nil
+	}
+
+	count, err := getBreachChecker().Count(plain)
+	if err != nil {
+		conf.GetLogEnv().Err.Errorf("Error checking for breached password: %s\n", err.Error())
+		return "", nil
+	}
+	if count == 0 {
+		return "", nil
+	}
+
+	msg := fmt.Sprintf("This password appeared %d times in data breaches, please choose a different password", count)
+	if mode == conf.BreachCheckReject {
+		return "", errors.New(msg)
+	}
+	return msg, nil
+}
+
+// SetPasswordBreached records whether the current password of the account appeared in data
+// breaches. Accounts with a breached password are warned once at their next login.
+func (acc *Account) SetPasswordBreached(breached bool) error {
+	const q = `UPDATE Accounts SET isPasswordBreached=$1 WHERE uuid=$2`
+
+	_, err := database.Exec(q, breached, acc.UUID)
+	if err == nil {
+		acc.IsPasswordBreached = breached
+	}
+	return err
+}
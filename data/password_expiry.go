@@ -0,0 +1,85 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// PasswordExpires returns the time when the password of the account expires according to
+// the password expiry policy. Returns false if the password of the account does not expire.
+func (acc *Account) PasswordExpires() (time.Time, bool) {
+	var labels []string
+	if notes, ok := GetAccountNotes(acc.UUID); ok {
+		labels = notes.Labels.Strings()
+	}
+
+	maxAge := conf.GetPasswordExpiry().MaxAgeFor(labels)
+	if maxAge == 0 {
+		return time.Time{}, false
+	}
+	return acc.PasswordChangedAt.Add(maxAge), true
+}
+
+// IsPasswordExpired returns true if the password of the account has expired.
+func (acc *Account) IsPasswordExpired() bool {
+	expires, ok := acc.PasswordExpires()
+	return ok && !expires.After(expiryTime())
+}
+
+// IsPasswordExpiring returns true and the time of expiry if the password of the account
+// expires within the warning period of the password expiry policy.
+func (acc *Account) IsPasswordExpiring() (time.Time, bool) {
+	expires, ok := acc.PasswordExpires()
+	if !ok {
+		return expires, false
+	}
+	return expires, expires.Before(util.Now().Add(conf.GetPasswordExpiry().Warn))
+}
+
+// NotifyPasswordExpiry notifies all active accounts whose password expires within the
+// warning period of the password expiry policy. Each account is only notified once per password.
+// The job acts with the service token svc.
+func NotifyPasswordExpiry(svc *ServiceToken) {
+	const q = `SELECT * FROM ActiveAccounts WHERE NOT isPasswordExpiryNotified AND passwordChangedAt < $1`
+	const qNotified = `UPDATE Accounts SET isPasswordExpiryNotified=TRUE WHERE uuid=$1`
+
+	policy := conf.GetPasswordExpiry()
+	minAge := policy.MinMaxAge()
+	if minAge == 0 {
+		return
+	}
+
+	accounts := make([]Account, 0)
+	err := database.Select(&accounts, q, util.Now().Add(policy.Warn-minAge))
+	if err != nil {
+		panic(err)
+	}
+
+	for _, acc := range accounts {
+		expires, ok := acc.IsPasswordExpiring()
+		if !ok {
+			continue
+		}
+
+		body := fmt.Sprintf("The password of your GIN account '%s' expires on %s.\n\n"+
+			"Please sign in and change your password in your account settings before this date.\n%s\n\n"+
+			"If your password has expired you will be asked to choose a new password at your next login.",
+			acc.Login, acc.TimeFormat().Date(expires), conf.GetExternals().GinUiURL)
+		err = acc.Notify("Your GIN password expires soon", body)
+		if err != nil {
+			panic(err)
+		}
+		database.MustExec(qNotified, acc.UUID)
+	}
+}
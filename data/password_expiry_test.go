@@ -0,0 +1,78 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestAccount_PasswordExpires(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	alice, _ := GetAccountByLogin("alice")
+	if _, ok := alice.PasswordExpires(); ok {
+		t.Error("Password of alice should not expire")
+	}
+	if alice.IsPasswordExpired() {
+		t.Error("Password of alice should not be expired")
+	}
+
+	john, _ := GetAccountByLogin("john")
+	if !john.IsPasswordExpired() {
+		t.Error("Password of john should be expired")
+	}
+
+	john.PasswordChangedAt = time.Now().AddDate(0, 0, -170)
+	if john.IsPasswordExpired() {
+		t.Error("Password of john should not be expired")
+	}
+	if _, ok := john.IsPasswordExpiring(); !ok {
+		t.Error("Password of john should expire soon")
+	}
+
+	john.PasswordChangedAt = time.Now()
+	if _, ok := john.IsPasswordExpiring(); ok {
+		t.Error("Password of john should not expire soon")
+	}
+
+	// expiry follows the clock of the server
+	expires, _ := john.PasswordExpires()
+	defer util.SetClock(util.SetClock(util.FixedClock(expires.Add(time.Hour))))
+	if !john.IsPasswordExpired() {
+		t.Error("Password of john should be expired after the expiry time")
+	}
+}
+
+func TestNotifyPasswordExpiry(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	NotifyPasswordExpiry(testServiceToken(t, JobCleaner))
+	NotifyPasswordExpiry(testServiceToken(t, JobCleaner))
+
+	john, _ := GetAccountByLogin("john")
+	if !john.IsPasswordExpiryNotified {
+		t.Error("John should be notified")
+	}
+	if len(ListNotifications(john.UUID)) != 1 {
+		t.Error("Exactly one notification expected")
+	}
+
+	err := john.UpdatePassword("testtest")
+	if err != nil {
+		t.Error(err)
+	}
+	if john.IsPasswordExpiryNotified || john.IsPasswordExpired() {
+		t.Error("Password expiry should start anew after a password change")
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// CheckPasswordStrength estimates the strength of a password. Personal data of the account,
+// e.g. login and name, given as userInputs are considered easy to guess.
+// Returns an error describing why the password violates the configured password strength
+// policy, or nil if the password is acceptable.
+func CheckPasswordStrength(plain string, userInputs ...string) (util.PasswordStrength, error) {
+	policy := conf.GetPasswordStrength()
+	strength := util.EstimatePasswordStrength(plain, userInputs...)
+
+	if utf8.RuneCountInString(plain) < policy.MinLength {
+		return strength, fmt.Errorf("Password must be at least %d characters long", policy.MinLength)
+	}
+	if strength.Score < policy.MinScore {
+		msg := "Password is too easy to guess"
+		if strength.Warning != "" {
+			msg += ": " + strength.Warning
+		}
+		return strength, errors.New(msg)
+	}
+	return strength, nil
+}
+
+// PasswordInputs returns the personal data of an account which should not be used in passwords.
+func (acc *Account) PasswordInputs() []string {
+	return []string{acc.Login, acc.Email, acc.FirstName, acc.LastName}
+}
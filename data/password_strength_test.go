@@ -0,0 +1,109 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func TestCheckPasswordStrength(t *testing.T) {
+	policy := conf.GetPasswordStrength()
+	defer func(score int) { policy.MinScore = score }(policy.MinScore)
+
+	_, err := CheckPasswordStrength("short")
+	if err == nil {
+		t.Error("Password shorter than the min length should fail")
+	}
+	_, err = CheckPasswordStrength("testtest")
+	if err != nil {
+		t.Error(err)
+	}
+
+	policy.MinScore = 3
+	acc := &Account{Login: "alice", Email: "aclic3@foo.com", FirstName: "alice", LastName: "bobson"}
+	strength, err := CheckPasswordStrength("alicebobson2016", acc.PasswordInputs()...)
+	if err == nil || strength.Score >= 3 {
+		t.Error("Password containing personal data should fail")
+	}
+	_, err = CheckPasswordStrength("correct horse battery staple", acc.PasswordInputs()...)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+// breachCheckerMock reports a fixed count for a single password.
+type breachCheckerMock struct {
+	password string
+	count    int
+}
+
+func (m *breachCheckerMock) Count(password string) (int, error) {
+	if password == m.password {
+		return m.count, nil
+	}
+	return 0, nil
+}
+
+func TestValidateNewPassword(t *testing.T) {
+	config := conf.GetBreachedPasswords()
+	defer func(mode string) {
+		config.Mode = mode
+		breachChecker = nil
+	}(config.Mode)
+	breachChecker = &breachCheckerMock{password: "breached-password", count: 42}
+
+	warning, err := ValidateNewPassword("breached-password")
+	if err != nil || warning != "" {
+		t.Error("Breached password expected to be accepted if the check is off")
+	}
+
+	config.Mode = conf.BreachCheckWarn
+	warning, err = ValidateNewPassword("breached-password")
+	if err != nil || warning == "" {
+		t.Error("Breached password expected to be accepted with a warning")
+	}
+
+	config.Mode = conf.BreachCheckReject
+	_, err = ValidateNewPassword("breached-password")
+	if err == nil {
+		t.Error("Breached password expected to be rejected")
+	}
+	warning, err = ValidateNewPassword("unknown-password")
+	if err != nil || warning != "" {
+		t.Error("Unknown password expected to be accepted")
+	}
+	_, err = ValidateNewPassword("short")
+	if err == nil {
+		t.Error("Too short password expected to be rejected")
+	}
+}
+
+func TestAccount_SetPasswordBreached(t *testing.T) {
+	InitTestDb(t)
+
+	account, _ := GetAccountByLogin("alice")
+	err := account.SetPasswordBreached(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	account, _ = GetAccountByLogin("alice")
+	if !account.IsPasswordBreached {
+		t.Error("Breached password expected to be recorded")
+	}
+
+	err = account.UpdatePassword("a new password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.IsPasswordBreached {
+		t.Error("Breached password expected to be reset by a password change")
+	}
+}
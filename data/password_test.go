@@ -0,0 +1,142 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+var legacyHashes = map[string]string{
+	"django":   "pbkdf2_sha256$1000$salt$Bt6CDbYstzzSYyF5jXOORZldF8eTnevPfh0WEMFfSpg=",
+	"gogs":     "gogs$abcdefghij$e2bc9559b0b5dd7314f1d1cb5b5c0bd4f7a17617747cca2e0c10938300492202db407a77360f9244cd5883473bed062f41ae",
+	"ldap-sha": "{SSHA}9wW2MMYTyjIArw6U5IgQlTHDTncxMjM0",
+}
+
+func TestPasswordVerifiers(t *testing.T) {
+	hashes := map[string]string{"ldap-sha-unsalted": "{SHA}Uau5Y2B43vv4iNhFenx2+FyPEUw="}
+	for name, hash := range legacyHashes {
+		hashes[name] = hash
+	}
+
+	for name, hash := range hashes {
+		v, ok := findPasswordVerifier(hash)
+		if !ok || !strings.HasPrefix(name, v.Name()) {
+			t.Errorf("No matching verifier found for '%s'", name)
+			continue
+		}
+		if !v.Verify(hash, "testtest") {
+			t.Errorf("Unable to verify '%s' hash", name)
+		}
+		if v.Verify(hash, "testtesf") {
+			t.Errorf("Wrong password matches '%s' hash", name)
+		}
+	}
+
+	if _, ok := findPasswordVerifier("unknown$hash"); ok {
+		t.Error("Unknown hash format should not be handled")
+	}
+	if (&Account{PWHash: "unknown$hash"}).VerifyPassword("unknown$hash") {
+		t.Error("Unknown hash format should never match")
+	}
+}
+
+func TestPasswordVerifiersShortHash(t *testing.T) {
+	hashes := []string{
+		"pbkdf2_sha256$1000$salt$",
+		"pbkdf2_sha256$1000$salt$Bt6CDbYstzzSYyF5",
+		"gogs$abcdefghij$",
+		"gogs$abcdefghij$e2bc9559b0b5dd73",
+	}
+	for _, hash := range hashes {
+		v, ok := findPasswordVerifier(hash)
+		if !ok {
+			t.Errorf("No matching verifier found for '%s'", hash)
+			continue
+		}
+		if v.Verify(hash, "testtest") || v.Verify(hash, "") {
+			t.Errorf("Hash '%s' shorter than the digest should never match", hash)
+		}
+	}
+}
+
+type plainVerifier struct{}
+
+func (v *plainVerifier) Name() string                   { return "plain" }
+func (v *plainVerifier) Handles(hash string) bool       { return strings.HasPrefix(hash, "plain$") }
+func (v *plainVerifier) Verify(hash, plain string) bool { return hash == "plain$"+plain }
+
+func TestRegisterPasswordVerifier(t *testing.T) {
+	verifiers := passwordVerifiers
+	defer func() { passwordVerifiers = verifiers }()
+
+	RegisterPasswordVerifier(&plainVerifier{})
+	acc := &Account{PWHash: "plain$testtest"}
+	if !acc.VerifyPassword("testtest") {
+		t.Error("Unable to verify password with registered verifier")
+	}
+}
+
+func TestAccount_VerifyPasswordUpgrade(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	for name, hash := range legacyHashes {
+		acc, _ := GetAccount(uuidAlice)
+		_, err := database.Exec("UPDATE Accounts SET pwhash=$1 WHERE uuid=$2", hash, uuidAlice)
+		if err != nil {
+			t.Fatal(err)
+		}
+		acc.PWHash = hash
+
+		if acc.VerifyPassword("wrongpassword") {
+			t.Errorf("Wrong password matches '%s' hash", name)
+		}
+		if !acc.VerifyPassword("testtest") {
+			t.Errorf("Unable to verify '%s' hash", name)
+		}
+
+		check, _ := GetAccount(uuidAlice)
+		if !strings.HasPrefix(check.PWHash, "$2a$") {
+			t.Errorf("Hash of type '%s' was not upgraded to bcrypt", name)
+		}
+		if !check.VerifyPassword("testtest") {
+			t.Errorf("Unable to verify upgraded '%s' hash", name)
+		}
+	}
+}
+
+func TestAccount_VerifyPasswordTiming(t *testing.T) {
+	hash, err := hashPassword("testtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	existing := &Account{PWHash: hash}
+	unknown := &Account{}
+	unknown.VerifyPassword("wrongpassword") // creates the dummy hash
+
+	measure := func(acc *Account) time.Duration {
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			if acc.VerifyPassword("wrongpassword") {
+				t.Error("Wrong password should not match")
+			}
+		}
+		return time.Since(start)
+	}
+
+	durExisting := measure(existing)
+	durUnknown := measure(unknown)
+	if durUnknown < durExisting/2 || durUnknown > durExisting*2 {
+		t.Errorf("Verification for unknown accounts took %s, for existing accounts %s", durUnknown, durExisting)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Actions which are authorized by the policy engine
+const (
+	PolicyActionAdminAPI   = "admin-api"
+	PolicyActionGrantScope = "grant-scope"
+)
+
+// ErrPolicyDenied is returned if the policy engine denies a request.
+var ErrPolicyDenied = errors.New("Denied by policy")
+
+// PolicyInput describes a request which is authorized by the policy engine. For the admin API
+// Method, Path (the route template without API version) and Vars (the route variables) describe
+// the request, for scope grants Client and Scope describe the grant. Account is the login of the
+// account which makes the request or to which the scope is granted.
+type PolicyInput struct {
+	Action  string            `json:"action"`
+	Account string            `json:"account,omitempty"`
+	Labels  []string          `json:"labels,omitempty"`
+	Client  string            `json:"client,omitempty"`
+	Scope   []string          `json:"scope,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Path    string            `json:"path,omitempty"`
+	Vars    map[string]string `json:"vars,omitempty"`
+}
+
+// NewPolicyInput creates the input for an action of the account with the given UUID,
+// including the login and the labels of the account.
+func NewPolicyInput(action, accountUUID string) *PolicyInput {
+	input := &PolicyInput{Action: action}
+	if acc, ok := GetAccount(accountUUID); ok {
+		input.Account = acc.Login
+	}
+	if notes, ok := GetAccountNotes(accountUUID); ok {
+		input.Labels = notes.Labels.Strings()
+	}
+	return input
+}
+
+// PolicyEngine decides whether a request is allowed. Deployments may implement this interface,
+// e.g. with embedded Rego policies, and register it with RegisterPolicyEngine in an init function
+// of a file added at build time. Alternatively an Open Policy Agent can be configured in the
+// policy section of the server configuration.
+type PolicyEngine interface {
+	Decide(input *PolicyInput) (bool, error)
+}
+
+var policyEngine = struct {
+	sync.Mutex
+	engine PolicyEngine
+}{}
+
+// RegisterPolicyEngine sets the engine which authorizes all subsequent requests. Passing nil
+// removes the engine, requests are then authorized by gin-auth alone.
+func RegisterPolicyEngine(engine PolicyEngine) {
+	policyEngine.Lock()
+	defer policyEngine.Unlock()
+
+	policyEngine.engine = engine
+}
+
+func registeredPolicyEngine() PolicyEngine {
+	policyEngine.Lock()
+	defer policyEngine.Unlock()
+
+	return policyEngine.engine
+}
+
+// PolicyEngineEnabled returns true if a policy engine is registered, either configured with the
+// URL in the policy section of the server configuration or by a deployment.
+func PolicyEngineEnabled() bool {
+	return registeredPolicyEngine() != nil
+}
+
+// CheckPolicy asks the registered policy engine whether a request is allowed. Returns nil if the
+// request is allowed or no engine is registered. If the engine fails the request is denied,
+// unless FailOpen is configured.
+func CheckPolicy(input *PolicyInput) error {
+	engine := registeredPolicyEngine()
+	if engine == nil {
+		return nil
+	}
+
+	allow, err := engine.Decide(input)
+	if err != nil {
+		conf.GetLogEnv().Err.Errorf("Policy engine failed to decide on '%s' of %s: %s", input.Action, input.Account, err.Error())
+		if conf.GetPolicy().FailOpen {
+			return nil
+		}
+		return &Error{Kind: ErrPolicyDenied, Message: "Policy engine not available"}
+	}
+	if !allow {
+		return &Error{Kind: ErrPolicyDenied, Message: fmt.Sprintf("Action '%s' is not allowed by policy", input.Action)}
+	}
+	return nil
+}
+
+// CheckScopeGrant returns an error if the scope must not be granted to the account with the given
+// UUID for the client, either because it contains elevated scopes which were not granted to the
+// account or because the policy engine denies the grant.
+func CheckScopeGrant(accountUUID, clientUUID string, scope util.StringSet) error {
+	if err := CheckElevatedScope(accountUUID, scope); err != nil {
+		return err
+	}
+
+	if !PolicyEngineEnabled() {
+		return nil
+	}
+
+	input := NewPolicyInput(PolicyActionGrantScope, accountUUID)
+	input.Scope = scope.Strings()
+	if client, ok := GetClient(clientUUID); ok {
+		input.Client = client.Name
+	}
+	return CheckPolicy(input)
+}
+
+// OPAPolicyEngine is a policy engine which queries the data API of an Open Policy Agent.
+// The input is posted as {"input": ...} and the result must either be a boolean or an
+// object with the boolean field allow. An undefined result denies the request.
+type OPAPolicyEngine struct {
+	URL    string
+	client *http.Client
+}
+
+// NewOPAPolicyEngine creates a policy engine for the Open Policy Agent of the server configuration.
+func NewOPAPolicyEngine(config *conf.Policy) *OPAPolicyEngine {
+	return &OPAPolicyEngine{URL: config.URL, client: &http.Client{Timeout: config.Timeout}}
+}
+
+// Decide implements PolicyEngine.
+func (e *OPAPolicyEngine) Decide(input *PolicyInput) (bool, error) {
+	body, err := json.Marshal(&struct {
+		Input *PolicyInput `json:"input"`
+	}{input})
+	if err != nil {
+		return false, err
+	}
+
+	res, err := e.client.Post(e.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("Policy engine responded with status %d", res.StatusCode)
+	}
+
+	result := &struct {
+		Result json.RawMessage `json:"result"`
+	}{}
+	err = json.NewDecoder(res.Body).Decode(result)
+	if err != nil {
+		return false, err
+	}
+	if len(result.Result) == 0 {
+		return false, nil
+	}
+
+	var allow bool
+	if json.Unmarshal(result.Result, &allow) == nil {
+		return allow, nil
+	}
+	decision := &struct {
+		Allow bool `json:"allow"`
+	}{}
+	err = json.Unmarshal(result.Result, decision)
+	if err != nil {
+		return false, fmt.Errorf("Unexpected policy result: %s", string(result.Result))
+	}
+	return decision.Allow, nil
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+type testPolicyEngine struct {
+	allow  func(input *PolicyInput) bool
+	inputs []*PolicyInput
+}
+
+func (e *testPolicyEngine) Decide(input *PolicyInput) (bool, error) {
+	e.inputs = append(e.inputs, input)
+	if e.allow == nil {
+		return false, errors.New("Engine not available")
+	}
+	return e.allow(input), nil
+}
+
+func TestOPAPolicyEngine_Decide(t *testing.T) {
+	var result string
+	var received *PolicyInput
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := &struct {
+			Input *PolicyInput `json:"input"`
+		}{}
+		json.NewDecoder(r.Body).Decode(body)
+		received = body.Input
+		w.Write([]byte(result))
+	}))
+	defer server.Close()
+
+	engine := NewOPAPolicyEngine(&conf.Policy{URL: server.URL, Timeout: time.Second})
+	input := &PolicyInput{Action: PolicyActionGrantScope, Account: "alice", Scope: []string{"repo-read"}}
+
+	cases := []struct {
+		result string
+		allow  bool
+		err    bool
+	}{
+		{`{"result": true}`, true, false},
+		{`{"result": false}`, false, false},
+		{`{"result": {"allow": true}}`, true, false},
+		{`{"result": {"deny": true}}`, false, false},
+		{`{}`, false, false},
+		{`{"result": "yes"}`, false, true},
+	}
+	for _, c := range cases {
+		result = c.result
+		allow, err := engine.Decide(input)
+		if allow != c.allow || (err != nil) != c.err {
+			t.Errorf("Result '%s' expected to be %t but was %t (%v)", c.result, c.allow, allow, err)
+		}
+	}
+	if received == nil || received.Account != "alice" || received.Action != PolicyActionGrantScope {
+		t.Errorf("Unexpected input received: %+v", received)
+	}
+
+	server.Close()
+	if _, err := engine.Decide(input); err == nil {
+		t.Error("Error expected if the engine is not available")
+	}
+}
+
+func TestCheckPolicy(t *testing.T) {
+	input := &PolicyInput{Action: PolicyActionAdminAPI, Account: "bob"}
+	if err := CheckPolicy(input); err != nil {
+		t.Error("Request expected to be allowed without policy engine")
+	}
+	if PolicyEngineEnabled() {
+		t.Error("No policy engine expected")
+	}
+
+	engine := &testPolicyEngine{allow: func(input *PolicyInput) bool { return input.Account == "alice" }}
+	RegisterPolicyEngine(engine)
+	defer RegisterPolicyEngine(nil)
+	if !PolicyEngineEnabled() {
+		t.Error("Registered policy engine expected")
+	}
+
+	if err := CheckPolicy(input); KindOf(err) != ErrPolicyDenied {
+		t.Error("Request expected to be denied by policy")
+	}
+	input.Account = "alice"
+	if err := CheckPolicy(input); err != nil {
+		t.Error("Request expected to be allowed by policy")
+	}
+
+	// failing engine
+	engine.allow = nil
+	if err := CheckPolicy(input); KindOf(err) != ErrPolicyDenied {
+		t.Error("Request expected to be denied if the engine fails")
+	}
+	conf.GetPolicy().FailOpen = true
+	defer func() { conf.GetPolicy().FailOpen = false }()
+	if err := CheckPolicy(input); err != nil {
+		t.Error("Request expected to be allowed if the engine fails open")
+	}
+}
+
+func TestCheckScopeGrant(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	scope := util.NewStringSet("repo-read")
+	if err := CheckScopeGrant(uuidAlice, uuidClientGin, scope); err != nil {
+		t.Error(err)
+	}
+
+	engine := &testPolicyEngine{allow: func(input *PolicyInput) bool { return !util.NewStringSet(input.Scope...).Contains("repo-write") }}
+	RegisterPolicyEngine(engine)
+	defer RegisterPolicyEngine(nil)
+
+	if err := CheckScopeGrant(uuidAlice, uuidClientGin, scope); err != nil {
+		t.Error(err)
+	}
+	if len(engine.inputs) != 1 || engine.inputs[0].Account != "alice" || engine.inputs[0].Client != "gin" {
+		t.Errorf("Unexpected policy input: %+v", engine.inputs)
+	}
+
+	if err := CheckScopeGrant(uuidAlice, uuidClientGin, scope.Add("repo-write")); KindOf(err) != ErrPolicyDenied {
+		t.Error("Scope grant expected to be denied by policy")
+	}
+
+	client, _ := GetClient(uuidClientGin)
+	if err := client.Approve(uuidAlice, util.NewStringSet("repo-write")); KindOf(err) != ErrPolicyDenied {
+		t.Error("Approval expected to be denied by policy")
+	}
+}
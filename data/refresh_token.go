@@ -10,19 +10,26 @@ package data
 
 import (
 	"database/sql"
-	"github.com/G-Node/gin-auth/util"
+	"strings"
 	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/lib/pq"
 )
 
 // RefreshToken represents an OAuth refresh token issued
-// in a `code` grant request.
+// in a `code` grant request. Access tokens issued with a refresh token
+// inherit its group restriction.
 type RefreshToken struct {
-	Token       string
-	Scope       util.StringSet
-	ClientUUID  string
-	AccountUUID string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	Token            string
+	Scope            util.StringSet
+	ClientUUID       string
+	AccountUUID      string
+	GroupRestriction util.StringSet
+	LastUsedAt       pq.NullTime
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
 }
 
 // ListRefreshTokens returns all refresh tokens sorted by creation time.
@@ -55,15 +62,84 @@ func GetRefreshToken(token string) (*RefreshToken, bool) {
 // Create stores a new refresh token in the database.
 // If the token is empty a random token will be generated.
 func (tok *RefreshToken) Create() error {
-	const q = `INSERT INTO RefreshTokens (token, scope, clientUUID, accountUUID, createdAt, updatedAt)
-	           VALUES ($1, $2, $3, $4, now(), now())
+	const q = `INSERT INTO RefreshTokens (token, scope, clientUUID, accountUUID, groupRestriction, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, $5, now(), now())
 	           RETURNING *`
 
 	if tok.Token == "" {
 		tok.Token = util.RandomToken()
 	}
 
-	return database.Get(tok, q, tok.Token, tok.Scope, tok.ClientUUID, tok.AccountUUID)
+	return database.Get(tok, q, tok.Token, tok.Scope, tok.ClientUUID, tok.AccountUUID, tok.GroupRestriction)
+}
+
+// Expires returns the time when the refresh token expires, which is the earlier of the end of its
+// life time and the end of its idle time after the last use. Returns false if it never expires.
+func (tok *RefreshToken) Expires() (time.Time, bool) {
+	policy := conf.GetRefreshTokens()
+
+	var expires time.Time
+	if policy.LifeTime > 0 {
+		expires = tok.CreatedAt.Add(policy.LifeTime)
+	}
+	if policy.IdleTime > 0 {
+		lastUse := tok.CreatedAt
+		if tok.LastUsedAt.Valid {
+			lastUse = tok.LastUsedAt.Time
+		}
+		idle := lastUse.Add(policy.IdleTime)
+		if expires.IsZero() || idle.Before(expires) {
+			expires = idle
+		}
+	}
+
+	return expires, !expires.IsZero()
+}
+
+// IsExpired returns true if the refresh token exceeded its life time or was not used within its idle time.
+func (tok *RefreshToken) IsExpired() bool {
+	expires, ok := tok.Expires()
+	return ok && !expires.After(expiryTime())
+}
+
+// removeExpiredRefreshTokens deletes refresh tokens which exceeded their life time or idle time.
+func removeExpiredRefreshTokens() {
+	const qLifeTime = `DELETE FROM RefreshTokens WHERE createdAt <= $1`
+	const qIdleTime = `DELETE FROM RefreshTokens WHERE COALESCE(lastUsedAt, createdAt) <= $1`
+
+	policy := conf.GetRefreshTokens()
+	if policy.LifeTime > 0 {
+		database.MustExec(qLifeTime, expiryTime().Add(-policy.LifeTime))
+	}
+	if policy.IdleTime > 0 {
+		database.MustExec(qIdleTime, expiryTime().Add(-policy.IdleTime))
+	}
+}
+
+// UpdateLastUse stores the current time as time of the last use of the refresh token.
+func (tok *RefreshToken) UpdateLastUse() error {
+	const q = `UPDATE RefreshTokens SET lastUsedAt = now() WHERE token=$1 RETURNING *`
+
+	return database.Get(tok, q, tok.Token)
+}
+
+// NarrowGroupRestriction returns the group restriction of an access token issued with the refresh
+// token. If groups is empty the restriction of the refresh token is inherited, otherwise groups may only
+// narrow it. Returns an error if groups contains a group the refresh token is not restricted to.
+func (tok *RefreshToken) NarrowGroupRestriction(groups util.StringSet) (util.StringSet, *util.ValidationError) {
+	if tok.GroupRestriction.Len() == 0 {
+		return groups, nil
+	}
+	if groups.Len() == 0 {
+		return tok.GroupRestriction, nil
+	}
+	if !tok.GroupRestriction.IsSuperset(groups) {
+		return nil, &util.ValidationError{
+			Message:     "Group restriction can only be narrowed",
+			FieldErrors: map[string]string{"groups": "The refresh token is restricted to: " + strings.Join(tok.GroupRestriction.Strings(), " ")},
+		}
+	}
+	return groups, nil
 }
 
 // Delete removes an refresh token from the database.
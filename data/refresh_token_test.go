@@ -9,8 +9,12 @@
 package data
 
 import (
-	"github.com/G-Node/gin-auth/util"
 	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/lib/pq"
 )
 
 const (
@@ -50,10 +54,11 @@ func TestCreateRefreshToken(t *testing.T) {
 
 	token := util.RandomToken()
 	fresh := RefreshToken{
-		Token:       token,
-		Scope:       util.NewStringSet("foo-read", "foo-write"),
-		ClientUUID:  uuidClientGin,
-		AccountUUID: uuidAlice}
+		Token:            token,
+		Scope:            util.NewStringSet("foo-read", "foo-write"),
+		ClientUUID:       uuidClientGin,
+		AccountUUID:      uuidAlice,
+		GroupRestriction: util.NewStringSet("lmu-neuro")}
 
 	err := fresh.Create()
 	if err != nil {
@@ -70,6 +75,30 @@ func TestCreateRefreshToken(t *testing.T) {
 	if !check.Scope.Contains("foo-write") {
 		t.Error("Scope should contain 'foo-write'")
 	}
+	if check.GroupRestriction.Len() != 1 || !check.GroupRestriction.Contains("lmu-neuro") {
+		t.Errorf("Group restriction is supposed to be 'lmu-neuro': %v", check.GroupRestriction.Strings())
+	}
+}
+
+func TestRefreshTokenNarrowGroupRestriction(t *testing.T) {
+	unrestricted := &RefreshToken{}
+	groups, err := unrestricted.NarrowGroupRestriction(util.NewStringSet("lmu-neuro"))
+	if err != nil || !groups.Contains("lmu-neuro") {
+		t.Errorf("Unrestricted token expected to accept any restriction: %v", err)
+	}
+
+	restricted := &RefreshToken{GroupRestriction: util.NewStringSet("lmu-neuro", "lmu-neuro-ephys")}
+	groups, err = restricted.NarrowGroupRestriction(util.NewStringSet())
+	if err != nil || groups.Len() != 2 {
+		t.Errorf("Restriction of the refresh token expected to be inherited: %v", groups.Strings())
+	}
+	groups, err = restricted.NarrowGroupRestriction(util.NewStringSet("lmu-neuro-ephys"))
+	if err != nil || groups.Len() != 1 || !groups.Contains("lmu-neuro-ephys") {
+		t.Errorf("Narrowed restriction expected: %v", groups.Strings())
+	}
+	if _, err = restricted.NarrowGroupRestriction(util.NewStringSet("lmu-neuro", "other")); err == nil {
+		t.Error("Widened restriction expected to be rejected")
+	}
 }
 
 func TestRefreshTokenDelete(t *testing.T) {
@@ -90,3 +119,66 @@ func TestRefreshTokenDelete(t *testing.T) {
 		t.Error("Refresh token should not exist")
 	}
 }
+
+func TestRefreshTokenExpires(t *testing.T) {
+	policy := conf.GetRefreshTokens()
+	defer func(lifeTime, idleTime time.Duration) {
+		policy.LifeTime, policy.IdleTime = lifeTime, idleTime
+	}(policy.LifeTime, policy.IdleTime)
+
+	now := util.Now()
+	policy.LifeTime, policy.IdleTime = 10*24*time.Hour, 2*24*time.Hour
+
+	tok := &RefreshToken{CreatedAt: now.Add(-24 * time.Hour)}
+	expires, ok := tok.Expires()
+	if !ok || !expires.Equal(tok.CreatedAt.Add(policy.IdleTime)) {
+		t.Errorf("Unused token expected to expire after the idle time but expires %v", expires)
+	}
+	if tok.IsExpired() {
+		t.Error("Token expected to be valid")
+	}
+
+	tok = &RefreshToken{CreatedAt: now.Add(-3 * 24 * time.Hour)}
+	if !tok.IsExpired() {
+		t.Error("Unused token expected to expire after the idle time")
+	}
+
+	tok.LastUsedAt = pq.NullTime{Time: now.Add(-time.Hour), Valid: true}
+	if tok.IsExpired() {
+		t.Error("Recent use expected to extend the token")
+	}
+
+	tok = &RefreshToken{CreatedAt: now.Add(-9*24*time.Hour - 12*time.Hour),
+		LastUsedAt: pq.NullTime{Time: now.Add(-time.Hour), Valid: true}}
+	expires, _ = tok.Expires()
+	if !expires.Equal(tok.CreatedAt.Add(policy.LifeTime)) {
+		t.Errorf("Active token expected to expire after the life time but expires %v", expires)
+	}
+	tok.CreatedAt = now.Add(-11 * 24 * time.Hour)
+	if !tok.IsExpired() {
+		t.Error("Active token expected to expire after the life time")
+	}
+
+	policy.LifeTime, policy.IdleTime = 0, 0
+	if _, ok := tok.Expires(); ok || tok.IsExpired() {
+		t.Error("Token expected to never expire")
+	}
+}
+
+func TestRemoveExpiredRefreshTokens(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	policy := conf.GetRefreshTokens()
+	defer func(idleTime time.Duration) { policy.IdleTime = idleTime }(policy.IdleTime)
+	policy.IdleTime = time.Hour
+
+	RemoveExpired(testServiceToken(t, JobCleaner))
+
+	if _, ok := GetRefreshToken("4FKJVX3K"); ok {
+		t.Error("Idle refresh token expected to be removed")
+	}
+	if _, ok := GetRefreshToken(refreshTokenAlice); !ok {
+		t.Error("Recent refresh token expected to be kept")
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+)
+
+// retentionPolicy describes which rows are purged by a policy. The condition selects
+// rows older than the cutoff time ($1), dependent rows are purged first by the
+// statements in dependents.
+type retentionPolicy struct {
+	name       string
+	table      string
+	condition  string
+	dependents []string
+}
+
+// Accounts selected by the disabled_accounts policy
+const qDisabledAccounts = `SELECT uuid FROM Accounts WHERE isDisabled AND updatedAt < $1`
+
+// retentionPolicies contains all policies in the order they are applied.
+var retentionPolicies = []retentionPolicy{
+	{name: "refresh_tokens", table: "RefreshTokens", condition: `updatedAt < $1`},
+	{name: "account_history", table: "AccountHistory", condition: `createdAt < $1`},
+	{name: "usage_counters", table: "UsageCounters", condition: `day < $1::date`},
+	{name: "client_usage", table: "ClientUsage", condition: `day < $1::date`},
+	{name: "grant_request_stats", table: "GrantRequestStats", condition: `day < $1::date`},
+	{name: "email_bounces", table: "EmailBounces", condition: `updatedAt < $1`},
+	{name: "row_audit", table: "RowAudit", condition: `createdAt < $1`},
+	{
+		name:      "disabled_accounts",
+		table:     "Accounts",
+		condition: `isDisabled AND updatedAt < $1`,
+		dependents: []string{
+			`DELETE FROM AccessTokens WHERE accountUUID IN (` + qDisabledAccounts + `)`,
+			`DELETE FROM RefreshTokens WHERE accountUUID IN (` + qDisabledAccounts + `)`,
+			`DELETE FROM Sessions WHERE accountUUID IN (` + qDisabledAccounts + `)`,
+			`DELETE FROM GrantRequests WHERE accountUUID IN (` + qDisabledAccounts + `)`,
+			`DELETE FROM ClientApprovals WHERE accountUUID IN (` + qDisabledAccounts + `)`,
+			`DELETE FROM SSHKeys WHERE accountUUID IN (` + qDisabledAccounts + `)`,
+		},
+	},
+}
+
+// Maximum number of account logins listed in a retention report
+const retentionSampleSize = 10
+
+// RetentionReport contains the number of rows of a retention policy which are older
+// than the cutoff time and were (or would be) purged. For purged accounts the logins
+// of some of them are listed.
+type RetentionReport struct {
+	Policy    string        `json:"policy"`
+	Keep      time.Duration `json:"-"`
+	Cutoff    time.Time     `json:"cutoff"`
+	Rows      int64         `json:"rows"`
+	SampleIDs []string      `json:"sample_ids,omitempty"`
+}
+
+// ApplyRetention purges all data older than configured by the retention policies in a
+// single transaction. If dryRun is true the transaction is rolled back, such that the report
+// shows what would be purged. Policies which keep data forever are not contained in the report.
+func ApplyRetention(dryRun bool) ([]RetentionReport, error) {
+	config := conf.GetRetention()
+	reports := make([]RetentionReport, 0, len(retentionPolicies))
+
+	deleted := make([]Account, 0)
+	tx := database.MustBegin()
+	for _, policy := range retentionPolicies {
+		keep := config.KeepFor(policy.name)
+		if keep <= 0 {
+			continue
+		}
+		report := RetentionReport{Policy: policy.name, Keep: keep, Cutoff: util.Now().Add(-keep)}
+
+		for _, q := range policy.dependents {
+			_, err := tx.Exec(q, report.Cutoff)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		if policy.table == "Accounts" {
+			err := tx.Select(&deleted, `DELETE FROM Accounts WHERE `+policy.condition+` RETURNING *`, report.Cutoff)
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			report.Rows = int64(len(deleted))
+			for i := 0; i < len(deleted) && i < retentionSampleSize; i++ {
+				report.SampleIDs = append(report.SampleIDs, deleted[i].Login)
+			}
+		} else {
+			res, err := tx.Exec(`DELETE FROM `+policy.table+` WHERE `+policy.condition, report.Cutoff)
+			if err == nil {
+				report.Rows, err = res.RowsAffected()
+			}
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+		}
+		reports = append(reports, report)
+	}
+
+	if dryRun {
+		return reports, tx.Rollback()
+	}
+	err := tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	// sessions in the database were removed with the account, other session stores are cleared here
+	for i := range deleted {
+		err = sessionStore().DeleteAccount(deleted[i].UUID)
+		if err != nil {
+			return reports, err
+		}
+		accountDeleted(&deleted[i])
+	}
+	return reports, nil
+}
+
+// EnforceRetention purges data according to the retention policies and writes
+// the number of purged rows and the service token svc the job acts with to the audit log.
+func EnforceRetention(svc *ServiceToken) {
+	reports, err := ApplyRetention(false)
+	if err != nil {
+		panic(err)
+	}
+	for _, report := range reports {
+		if report.Rows > 0 {
+			svc.audit(logrus.Fields{
+				"event":  "retention",
+				"policy": report.Policy,
+				"rows":   report.Rows,
+			}).Info("Purged data by retention policy")
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestApplyRetention(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	config := conf.GetRetention()
+	policies := config.Policies
+	defer func() { config.Policies = policies }()
+	config.Policies = map[string]time.Duration{
+		"account_history":   30 * 24 * time.Hour,
+		"refresh_tokens":    12 * time.Hour,
+		"disabled_accounts": 24 * time.Hour,
+	}
+	database.MustExec(`UPDATE Accounts SET updatedAt = now() - INTERVAL '10 days' WHERE login = 'inact_log4'`)
+
+	rows := func(reports []RetentionReport) map[string]int64 {
+		m := make(map[string]int64)
+		for _, r := range reports {
+			m[r.Policy] = r.Rows
+		}
+		return m
+	}
+
+	// dry run
+	reports, err := ApplyRetention(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 3 {
+		t.Errorf("Three policies expected in report but was %d", len(reports))
+	}
+	counts := rows(reports)
+	if counts["account_history"] != 2 || counts["refresh_tokens"] != 1 || counts["disabled_accounts"] != 1 {
+		t.Errorf("Unexpected report: %v", counts)
+	}
+	if len(ListAccountHistory(uuidAlice)) != 2 {
+		t.Error("Dry run should not purge data")
+	}
+
+	// sessions of purged accounts are removed from other session stores
+	defer func(store SessionStore) { sessionStoreInstance = store }(sessionStoreInstance)
+	sessionStoreInstance = NewMemorySessionStore()
+	err = sessionStoreInstance.Create(&Session{Token: "PURGED", AccountUUID: "test0004-1234-6789-1234-678901234567",
+		Expires: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// purge
+	reports, err = ApplyRetention(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows(reports)["account_history"] != 2 {
+		t.Error("Two history entries expected to be purged")
+	}
+	if len(ListAccountHistory(uuidAlice)) != 0 {
+		t.Error("History of alice expected to be purged")
+	}
+	if _, ok := GetRefreshToken("4FKJVX3K"); ok {
+		t.Error("Old refresh token expected to be purged")
+	}
+	if _, ok := GetRefreshToken("YYPTDSVZ"); !ok {
+		t.Error("New refresh token expected to be kept")
+	}
+	if _, ok := GetAccountDisabled("test0004-1234-6789-1234-678901234567"); ok {
+		t.Error("Disabled account expected to be purged after the grace period")
+	}
+	if _, ok := GetAccountDisabled("test0005-1234-6789-1234-678901234567"); !ok {
+		t.Error("Recently disabled account expected to be kept")
+	}
+	if _, err := sessionStoreInstance.Get("PURGED"); KindOf(err) != ErrNotFound {
+		t.Error("Session of the purged account expected to be removed")
+	}
+}
@@ -0,0 +1,342 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+	"github.com/lib/pq"
+	"github.com/pborman/uuid"
+)
+
+// Maximum length of the reason of a re-verification campaign
+const maxReverificationReasonLength = 512
+
+// ReverificationCampaign requires the selected accounts to confirm their e-mail address and/or to
+// change their password at the next login, e.g. after a suspected credential stuffing incident.
+// Accounts are selected by their logins, by a label or group or by a saved AccountFilter when the
+// campaign is created. Logins with an existing session and refresh grants are interrupted until the
+// steps are completed. With RevokeSessions the sessions and tokens of the accounts are ended as well,
+// such that issued access tokens stop working immediately.
+type ReverificationCampaign struct {
+	UUID            string
+	Reason          string
+	RequireEmail    bool
+	RequirePassword bool
+	Label           string
+	GroupName       string
+	FilterName      string
+	CreatedBy       sql.NullString
+	CreatedAt       time.Time
+	Logins          []string `db:"-"`
+	RevokeSessions  bool     `db:"-"`
+}
+
+// ReverificationProgress counts the accounts of a campaign and the completed steps.
+type ReverificationProgress struct {
+	Accounts        int
+	EmailVerified   int
+	PasswordChanged int
+	Completed       int
+}
+
+// ReverificationStatus describes the progress of a single account of a campaign.
+type ReverificationStatus struct {
+	Login             string
+	EmailVerifiedAt   pq.NullTime
+	PasswordChangedAt pq.NullTime
+	CompletedAt       pq.NullTime
+}
+
+// PendingReverification contains the steps of re-verification campaigns which the
+// owner of an account still has to complete.
+type PendingReverification struct {
+	Email    bool
+	Password bool
+}
+
+// IsPending returns true if any step remains to be completed.
+func (p *PendingReverification) IsPending() bool {
+	return p.Email || p.Password
+}
+
+// selection queries of campaigns, $1 to $7 are the parameters of the account filter
+const (
+	qReverificationFiltered = `SELECT a.uuid FROM ActiveAccounts a WHERE ` + qAccountFilterCondition
+	qReverificationLogins   = `SELECT a.uuid FROM ActiveAccounts a WHERE lower(a.login) = ANY($1::varchar[])`
+)
+
+// ListReverificationCampaigns returns all campaigns, newest first.
+func ListReverificationCampaigns() []ReverificationCampaign {
+	const q = `SELECT * FROM ReverificationCampaigns ORDER BY createdAt DESC`
+
+	campaigns := make([]ReverificationCampaign, 0)
+	err := database.Select(&campaigns, q)
+	if err != nil {
+		panic(err)
+	}
+
+	return campaigns
+}
+
+// GetReverificationCampaign returns the campaign with the given UUID.
+// Returns false if no such campaign exists.
+func GetReverificationCampaign(uuid string) (*ReverificationCampaign, bool) {
+	const q = `SELECT * FROM ReverificationCampaigns WHERE uuid=$1`
+
+	campaign := &ReverificationCampaign{}
+	err := database.Get(campaign, q, uuid)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return campaign, err == nil
+}
+
+// Validate checks the reason, the required steps and the selection of accounts.
+func (campaign *ReverificationCampaign) Validate() error {
+	fieldErrors := make(map[string]string)
+	campaign.Reason = strings.TrimSpace(campaign.Reason)
+	if campaign.Reason == "" {
+		fieldErrors["reason"] = "Please describe the reason of the campaign"
+	} else if len(campaign.Reason) > maxReverificationReasonLength {
+		fieldErrors["reason"] = "Please use at most 512 characters"
+	}
+	if !campaign.RequireEmail && !campaign.RequirePassword {
+		fieldErrors["require"] = "Please require an e-mail confirmation, a password change or both"
+	}
+
+	selectors := 0
+	for _, s := range []string{campaign.Label, campaign.GroupName, campaign.FilterName} {
+		if s != "" {
+			selectors++
+		}
+	}
+	switch {
+	case len(campaign.Logins) > 0 && selectors > 0:
+		fieldErrors["selection"] = "Please select accounts either by logins or by label, group or filter"
+	case len(campaign.Logins) == 0 && selectors == 0:
+		fieldErrors["selection"] = "Please select accounts by logins, label, group or filter"
+	case campaign.FilterName != "" && selectors > 1:
+		fieldErrors["filter"] = "Please use either a filter or label and group"
+	}
+	if campaign.Label != "" && !accountLabelRegex.MatchString(campaign.Label) {
+		fieldErrors["label"] = "Invalid label"
+	}
+	if campaign.GroupName != "" {
+		if _, ok := GetGroup(campaign.GroupName); !ok {
+			fieldErrors["group"] = "The group does not exist"
+		}
+	}
+	if campaign.FilterName != "" {
+		if _, ok := GetAccountFilter(campaign.FilterName); !ok {
+			fieldErrors["filter"] = "The filter does not exist"
+		}
+	}
+	if unknown := unknownLogins(campaign.Logins); len(unknown) > 0 {
+		fieldErrors["logins"] = "Unknown or inactive accounts: " + strings.Join(unknown, ", ")
+	}
+
+	if len(fieldErrors) > 0 {
+		return &util.ValidationError{Message: "Invalid re-verification campaign", FieldErrors: fieldErrors}
+	}
+	return nil
+}
+
+// unknownLogins returns the logins for which no active account exists.
+func unknownLogins(logins []string) []string {
+	const q = `SELECT lower(login) FROM ActiveAccounts WHERE lower(login) = ANY($1::varchar[])`
+
+	if len(logins) == 0 {
+		return nil
+	}
+	lower := lowerLogins(logins)
+
+	found := make([]string, 0, lower.Len())
+	err := database.Select(&found, q, lower)
+	if err != nil {
+		panic(err)
+	}
+
+	return lower.Difference(util.NewStringSet(found...)).Strings()
+}
+
+// lowerLogins returns the set of the given logins in lower case.
+func lowerLogins(logins []string) util.StringSet {
+	lower := util.NewStringSet()
+	for _, login := range logins {
+		lower = lower.Add(strings.ToLower(strings.TrimSpace(login)))
+	}
+	return lower
+}
+
+// selection returns the query and its parameters which select the accounts of the campaign.
+func (campaign *ReverificationCampaign) selection() (string, []interface{}) {
+	if len(campaign.Logins) > 0 {
+		return qReverificationLogins, []interface{}{lowerLogins(campaign.Logins)}
+	}
+
+	filter := &AccountFilter{Label: campaign.Label, GroupName: campaign.GroupName}
+	if campaign.FilterName != "" {
+		filter, _ = GetAccountFilter(campaign.FilterName)
+	}
+	return qReverificationFiltered, filter.params()
+}
+
+// Create stores the campaign and flags the selected accounts. If an e-mail confirmation is
+// required the addresses of the accounts are marked as unverified.
+func (campaign *ReverificationCampaign) Create() error {
+	if err := campaign.Validate(); err != nil {
+		return err
+	}
+
+	const q = `INSERT INTO ReverificationCampaigns (uuid, reason, requireEmail, requirePassword, label, groupName,
+	                                                filterName, createdBy, createdAt)
+	           VALUES ($1, $2, $3, $4, $5, $6, $7, $8, now())
+	           RETURNING *`
+	const qUnverify = `UPDATE Accounts SET isEmailVerified = FALSE
+	                   WHERE uuid IN (SELECT accountUUID FROM Reverifications WHERE campaignUUID = $1)`
+	const qAccounts = `SELECT accountUUID FROM Reverifications WHERE campaignUUID = $1`
+
+	selection, params := campaign.selection()
+	n := len(params)
+	qFlag := `INSERT INTO Reverifications (campaignUUID, accountUUID, emailRequired, passwordRequired)
+	          SELECT $` + strconv.Itoa(n+1) + `, s.uuid, $` + strconv.Itoa(n+2) + `, $` + strconv.Itoa(n+3) + ` FROM (` + selection + `) s`
+
+	if campaign.UUID == "" {
+		campaign.UUID = uuid.NewRandom().String()
+	}
+
+	tx := database.MustBegin()
+	err := tx.Get(campaign, q, campaign.UUID, campaign.Reason, campaign.RequireEmail, campaign.RequirePassword,
+		campaign.Label, campaign.GroupName, campaign.FilterName, campaign.CreatedBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(qFlag, append(params, campaign.UUID, campaign.RequireEmail, campaign.RequirePassword)...)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if campaign.RequireEmail {
+		_, err = tx.Exec(qUnverify, campaign.UUID)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if campaign.RevokeSessions {
+		for _, stmt := range []string{
+			`DELETE FROM AccessTokens WHERE accountUUID IN (` + qAccounts + `)`,
+			`DELETE FROM RefreshTokens WHERE accountUUID IN (` + qAccounts + `)`,
+		} {
+			_, err = tx.Exec(stmt, campaign.UUID)
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	accounts := make([]string, 0)
+	err = tx.Select(&accounts, qAccounts, campaign.UUID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil || !campaign.RevokeSessions {
+		return err
+	}
+
+	for _, accountUUID := range accounts {
+		err = sessionStore().DeleteAccount(accountUUID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Progress returns the number of accounts of the campaign and of the completed steps.
+func (campaign *ReverificationCampaign) Progress() *ReverificationProgress {
+	const q = `SELECT count(*) AS accounts, count(emailVerifiedAt) AS emailVerified,
+	                  count(passwordChangedAt) AS passwordChanged, count(completedAt) AS completed
+	           FROM Reverifications WHERE campaignUUID = $1`
+
+	progress := &ReverificationProgress{}
+	err := database.Get(progress, q, campaign.UUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return progress
+}
+
+// Accounts returns the progress of all accounts of the campaign, pending accounts first.
+func (campaign *ReverificationCampaign) Accounts() []ReverificationStatus {
+	const q = `SELECT a.login, r.emailVerifiedAt, r.passwordChangedAt, r.completedAt
+	           FROM Reverifications r JOIN Accounts a ON a.uuid = r.accountUUID
+	           WHERE r.campaignUUID = $1
+	           ORDER BY r.completedAt IS NOT NULL, a.login`
+
+	accounts := make([]ReverificationStatus, 0)
+	err := database.Select(&accounts, q, campaign.UUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return accounts
+}
+
+// PendingReverification returns the steps the account still has to complete for
+// all campaigns it was selected by.
+func (acc *Account) PendingReverification() *PendingReverification {
+	const q = `SELECT COALESCE(bool_or(emailRequired AND emailVerifiedAt IS NULL), FALSE) AS email,
+	                  COALESCE(bool_or(passwordRequired AND passwordChangedAt IS NULL), FALSE) AS password
+	           FROM Reverifications WHERE accountUUID = $1 AND completedAt IS NULL`
+
+	pending := &PendingReverification{}
+	err := database.Get(pending, q, acc.UUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return pending
+}
+
+// Steps of a re-verification, the column which records the time of completion
+const (
+	reverificationEmail    = "emailVerifiedAt"
+	reverificationPassword = "passwordChangedAt"
+)
+
+// completeReverificationStep records a completed step for all pending campaigns of the
+// account and marks campaigns without remaining steps as completed.
+func (acc *Account) completeReverificationStep(step string) error {
+	required := map[string]string{reverificationEmail: "emailRequired", reverificationPassword: "passwordRequired"}
+	qStep := `UPDATE Reverifications SET ` + step + ` = now()
+	          WHERE accountUUID = $1 AND completedAt IS NULL AND ` + required[step] + ` AND ` + step + ` IS NULL`
+	const qComplete = `UPDATE Reverifications SET completedAt = now()
+	                   WHERE accountUUID = $1 AND completedAt IS NULL
+	                         AND (NOT emailRequired OR emailVerifiedAt IS NOT NULL)
+	                         AND (NOT passwordRequired OR passwordChangedAt IS NOT NULL)`
+
+	_, err := database.Exec(qStep, acc.UUID)
+	if err != nil {
+		return err
+	}
+	_, err = database.Exec(qComplete, acc.UUID)
+	return err
+}
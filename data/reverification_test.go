@@ -0,0 +1,103 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestReverificationCampaign_Validate(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	invalid := &ReverificationCampaign{Logins: []string{"alice", "doesnotexist"}, FilterName: "doesnotexist"}
+	err := invalid.Validate()
+	valErr, ok := err.(*util.ValidationError)
+	if !ok {
+		t.Fatalf("Validation error expected but was %v", err)
+	}
+	for _, field := range []string{"reason", "require", "selection", "filter", "logins"} {
+		if valErr.FieldErrors[field] == "" {
+			t.Errorf("Field error for '%s' expected: %v", field, valErr.FieldErrors)
+		}
+	}
+
+	valid := &ReverificationCampaign{Reason: "Leaked credentials", RequirePassword: true, Logins: []string{"Alice"}}
+	if err := valid.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReverificationCampaign_Create(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	campaign := &ReverificationCampaign{
+		Reason:          "Credential stuffing",
+		RequireEmail:    true,
+		RequirePassword: true,
+		GroupName:       "lmu-neuro",
+		RevokeSessions:  true,
+	}
+	err := campaign.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	progress := campaign.Progress()
+	if progress.Accounts != 2 || progress.Completed != 0 {
+		t.Errorf("Two pending accounts expected: %+v", progress)
+	}
+	alice, _ := GetAccountByLogin("alice")
+	if alice.IsEmailVerified {
+		t.Error("E-mail address of alice expected to be unverified")
+	}
+	if sessions := ListAccountSessions(alice.UUID); len(sessions) != 0 {
+		t.Error("Sessions of alice expected to be revoked")
+	}
+	if _, ok := GetRefreshToken(refreshTokenAlice); ok {
+		t.Error("Refresh tokens of alice expected to be revoked")
+	}
+	if campaigns := ListReverificationCampaigns(); len(campaigns) != 1 || campaigns[0].UUID != campaign.UUID {
+		t.Errorf("Created campaign expected to be listed: %v", campaigns)
+	}
+
+	pending := alice.PendingReverification()
+	if !pending.Email || !pending.Password {
+		t.Errorf("E-mail and password steps expected to be pending: %+v", pending)
+	}
+
+	err = alice.VerifyEmail()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pending = alice.PendingReverification()
+	if pending.Email || !pending.Password {
+		t.Errorf("Only the password step expected to be pending: %+v", pending)
+	}
+
+	err = alice.UpdatePassword("a new password for alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.PendingReverification().IsPending() {
+		t.Error("No steps expected to be pending")
+	}
+
+	progress = campaign.Progress()
+	if progress.EmailVerified != 1 || progress.PasswordChanged != 1 || progress.Completed != 1 {
+		t.Errorf("One completed account expected: %+v", progress)
+	}
+	accounts := campaign.Accounts()
+	if len(accounts) != 2 || accounts[0].Login != "bob" || accounts[1].Login != "alice" || !accounts[1].CompletedAt.Valid {
+		t.Errorf("Pending account bob expected before completed account alice: %+v", accounts)
+	}
+}
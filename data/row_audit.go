@@ -0,0 +1,52 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+// rowAuditTables contains the tables whose changes are recorded by the row audit triggers.
+var rowAuditTables = []string{"Accounts", "Clients", "ClientApprovals", "SSHKeys", "GroupMembers"}
+
+// SetRowAudit creates or drops the triggers which record every insert, update and delete of the
+// audited tables in the table RowAudit. Unlike the account history the triggers also record
+// changes which are made directly in the database.
+func SetRowAudit(enabled bool) error {
+	tx := database.MustBegin()
+	for _, table := range rowAuditTables {
+		_, err := tx.Exec(`DROP TRIGGER IF EXISTS RowAudit ON ` + table)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if !enabled {
+			continue
+		}
+		_, err = tx.Exec(`CREATE TRIGGER RowAudit AFTER INSERT OR UPDATE OR DELETE ON ` + table +
+			` FOR EACH ROW EXECUTE PROCEDURE recordRowAudit()`)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// IsRowAuditEnabled returns true if the row audit triggers exist on all audited tables.
+func IsRowAuditEnabled() bool {
+	const q = `SELECT count(*) FROM pg_trigger t
+	             JOIN pg_class c ON c.oid = t.tgrelid
+	             JOIN pg_namespace n ON n.oid = c.relnamespace
+	           WHERE t.tgname = 'rowaudit' AND n.nspname = current_schema()`
+
+	var count int
+	err := database.Get(&count, q)
+	if err != nil {
+		panic(err)
+	}
+
+	return count == len(rowAuditTables)
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestSetRowAudit(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	if IsRowAuditEnabled() {
+		t.Fatal("Row audit expected to be off")
+	}
+	err := SetRowAudit(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer SetRowAudit(false)
+	if !IsRowAuditEnabled() {
+		t.Error("Row audit expected to be on")
+	}
+
+	database.MustExec(`UPDATE Accounts SET firstName = 'Alicia' WHERE login = 'alice'`)
+	audit := &struct {
+		Operation string
+		NewRow    string
+	}{}
+	err = database.Get(audit, `SELECT operation, newRow FROM RowAudit WHERE tableName = 'accounts'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if audit.Operation != "UPDATE" {
+		t.Errorf("Recorded update expected but was '%s'", audit.Operation)
+	}
+	if !strings.Contains(audit.NewRow, "Alicia") || strings.Contains(audit.NewRow, "pwhash") {
+		t.Errorf("New row without password hash expected: %s", audit.NewRow)
+	}
+
+	err = SetRowAudit(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if IsRowAuditEnabled() {
+		t.Error("Row audit expected to be off")
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"io/ioutil"
+	"regexp"
+	"strconv"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/lib/pq"
+)
+
+// Migration files are named <version>_<name>.sql
+var migrationFileRegex = regexp.MustCompile(`^([0-9]+)_.+\.sql$`)
+
+// SchemaInfo describes the state of the database schema: the version of the last
+// applied migration, the version of the latest available migration and the
+// number of rows of each table.
+type SchemaInfo struct {
+	Version       int64
+	LatestVersion int64
+	Tables        []TableInfo
+}
+
+// TableInfo contains the name and the number of rows of a database table.
+type TableInfo struct {
+	Name string
+	Rows int64
+}
+
+// IsUpToDate returns true if all available migrations were applied.
+func (info *SchemaInfo) IsUpToDate() bool {
+	return info.Version >= info.LatestVersion
+}
+
+// GetSchemaInfo returns the current migration version and row counts of all tables.
+func GetSchemaInfo() *SchemaInfo {
+	const qHasVersion = `SELECT to_regclass('goose_db_version') IS NOT NULL`
+	const qVersion = `SELECT COALESCE((SELECT version_id FROM goose_db_version WHERE is_applied
+	                                   ORDER BY id DESC LIMIT 1), 0)`
+	const qTables = `SELECT table_name FROM information_schema.tables
+	                 WHERE table_schema = current_schema() AND table_type = 'BASE TABLE'
+	                 AND table_name <> 'goose_db_version'
+	                 ORDER BY table_name`
+
+	info := &SchemaInfo{LatestVersion: latestMigration()}
+
+	var hasVersion bool
+	err := database.Get(&hasVersion, qHasVersion)
+	if err != nil {
+		panic(err)
+	}
+	if hasVersion {
+		err = database.Get(&info.Version, qVersion)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	names := make([]string, 0)
+	err = database.Select(&names, qTables)
+	if err != nil {
+		panic(err)
+	}
+
+	info.Tables = make([]TableInfo, 0, len(names))
+	for _, name := range names {
+		table := TableInfo{Name: name}
+		err = database.Get(&table.Rows, "SELECT count(*) FROM "+pq.QuoteIdentifier(name))
+		if err != nil {
+			panic(err)
+		}
+		info.Tables = append(info.Tables, table)
+	}
+
+	return info
+}
+
+// latestMigration returns the highest version of all migration files.
+// Returns zero if the migrations directory can't be read.
+func latestMigration() int64 {
+	files, err := ioutil.ReadDir(conf.GetResourceFile("conf", "migrations"))
+	if err != nil {
+		return 0
+	}
+
+	var latest int64
+	for _, f := range files {
+		match := migrationFileRegex.FindStringSubmatch(f.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err == nil && version > latest {
+			latest = version
+		}
+	}
+	return latest
+}
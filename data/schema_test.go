@@ -0,0 +1,64 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestGetSchemaInfo(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	info := GetSchemaInfo()
+	if info.LatestVersion < 11 {
+		t.Errorf("Latest migration version expected to be at least 11 but was %d", info.LatestVersion)
+	}
+	if !info.IsUpToDate() {
+		t.Errorf("Test schema expected with all migrations applied: %d of %d", info.Version, info.LatestVersion)
+	}
+
+	var accounts *TableInfo
+	for i, table := range info.Tables {
+		if table.Name == "accounts" {
+			accounts = &info.Tables[i]
+		}
+	}
+	if accounts == nil || accounts.Rows != 10 {
+		t.Errorf("Table 'accounts' expected with 10 rows: %v", accounts)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	all, err := migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) == 0 || all[len(all)-1].version != latestMigration() {
+		t.Fatal("Migrations expected up to the latest version")
+	}
+	for i, m := range all {
+		if i > 0 && all[i-1].version >= m.version {
+			t.Errorf("Migration %d expected after %d", m.version, all[i-1].version)
+		}
+		if !strings.HasPrefix(m.up, "-- +goose Up") || strings.Contains(m.up, "-- +goose Down") {
+			t.Errorf("Migration %d expected with the 'up' section only", m.version)
+		}
+	}
+}
+
+func TestTestSchemaName(t *testing.T) {
+	name := testSchemaName()
+	if !strings.HasPrefix(name, "test_") || testSchemaCharRegex.MatchString(name) {
+		t.Errorf("Invalid test schema name '%s'", name)
+	}
+}
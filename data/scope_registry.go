@@ -0,0 +1,81 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"encoding/json"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+// ScopeInfo describes a scope provided by a client, whether it has to be granted by an
+// administrator and which clients may request it.
+type ScopeInfo struct {
+	Name          string
+	Description   string
+	Provider      string
+	Elevated      bool
+	RequestableBy []string
+	Whitelisted   []string
+}
+
+// ListScopes returns all scopes provided by the registered clients ordered by name.
+// A client may request every scope except the scopes in its blacklist, scopes in its
+// whitelist are granted without asking the user.
+func ListScopes() []ScopeInfo {
+	const q = `SELECT s.name, s.description, c.name AS provider
+	           FROM ClientScopeProvided s JOIN Clients c ON c.uuid = s.clientUUID
+	           ORDER BY s.name`
+
+	scopes := make([]ScopeInfo, 0)
+	err := database.Select(&scopes, q)
+	if err != nil {
+		panic(err)
+	}
+
+	clients := ListClients()
+	registration := conf.GetRegistration()
+	for i := range scopes {
+		s := &scopes[i]
+		s.Elevated = registration.IsElevated(s.Name)
+		s.RequestableBy = make([]string, 0, len(clients))
+		s.Whitelisted = make([]string, 0)
+		for _, client := range clients {
+			if client.ScopeBlacklist.Contains(s.Name) {
+				continue
+			}
+			s.RequestableBy = append(s.RequestableBy, client.Name)
+			if client.ScopeWhitelist.Contains(s.Name) {
+				s.Whitelisted = append(s.Whitelisted, client.Name)
+			}
+		}
+	}
+
+	return scopes
+}
+
+// MarshalJSON implements Marshaler for ScopeInfo
+func (scope *ScopeInfo) MarshalJSON() ([]byte, error) {
+	jsonData := &struct {
+		Name          string   `json:"name"`
+		Description   string   `json:"description"`
+		Provider      string   `json:"provider"`
+		Elevated      bool     `json:"elevated"`
+		RequestableBy []string `json:"requestable_by"`
+		Whitelisted   []string `json:"whitelisted"`
+	}{
+		Name:          scope.Name,
+		Description:   scope.Description,
+		Provider:      scope.Provider,
+		Elevated:      scope.Elevated,
+		RequestableBy: scope.RequestableBy,
+		Whitelisted:   scope.Whitelisted,
+	}
+	return json.Marshal(jsonData)
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestListScopes(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	scopes := ListScopes()
+	if len(scopes) != 6 {
+		t.Fatalf("Six scopes expected but found %d", len(scopes))
+	}
+	if scopes[0].Name != "account-admin" || scopes[0].Provider != "gin" {
+		t.Errorf("Unexpected first scope: %+v", scopes[0])
+	}
+	if len(scopes[0].RequestableBy) != 0 {
+		t.Error("Blacklisted scope should not be requestable by any client")
+	}
+
+	for _, s := range scopes {
+		if s.Name == "repo-read" {
+			if !reflect.DeepEqual(s.RequestableBy, []string{"gin", "wb"}) || !reflect.DeepEqual(s.Whitelisted, []string{"wb"}) {
+				t.Errorf("Unexpected clients of repo-read: %+v", s)
+			}
+		}
+	}
+}
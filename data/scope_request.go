@@ -0,0 +1,245 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/jmoiron/sqlx"
+	"github.com/pborman/uuid"
+)
+
+// States of a scope request: a request is unconfirmed until the account owner confirmed it via
+// e-mail, pending until an administrator decided about it and finally granted or rejected.
+const (
+	ScopeRequestUnconfirmed = "unconfirmed"
+	ScopeRequestPending     = "pending"
+	ScopeRequestGranted     = "granted"
+	ScopeRequestRejected    = "rejected"
+)
+
+// Time after which an unconfirmed scope request can no longer be confirmed
+const scopeRequestConfirmLifeTime = 24 * time.Hour
+
+// Maximum length of the reason given for a scope request
+const maxScopeRequestReason = 1024
+
+// ScopeRequest is the request of an account for an elevated scope (e.g. 'curator'),
+// which has to be granted by an administrator.
+type ScopeRequest struct {
+	UUID             string
+	AccountUUID      string
+	Scope            string
+	Reason           string
+	State            string
+	ConfirmationCode sql.NullString
+	DecidedBy        sql.NullString
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// ListPendingScopeRequests returns all confirmed scope requests of active accounts, which are
+// waiting for a decision by an administrator, oldest first.
+func ListPendingScopeRequests() []ScopeRequest {
+	const q = `SELECT r.* FROM ScopeRequests r JOIN ActiveAccounts a ON a.uuid = r.accountUUID
+	           WHERE r.state=$1
+	           ORDER BY r.createdAt, r.uuid`
+
+	requests := make([]ScopeRequest, 0)
+	err := database.Select(&requests, q, ScopeRequestPending)
+	if err != nil {
+		panic(err)
+	}
+
+	return requests
+}
+
+// ListAccountScopeRequests returns all scope requests of an account, newest first.
+func ListAccountScopeRequests(accountUUID string) []ScopeRequest {
+	const q = `SELECT * FROM ScopeRequests WHERE accountUUID=$1 ORDER BY createdAt DESC, uuid`
+
+	requests := make([]ScopeRequest, 0)
+	err := database.Select(&requests, q, accountUUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return requests
+}
+
+// GetScopeRequest returns the scope request with the given UUID.
+// Returns false if no such request exists.
+func GetScopeRequest(uuid string) (*ScopeRequest, bool) {
+	const q = `SELECT * FROM ScopeRequests WHERE uuid=$1`
+
+	request := &ScopeRequest{}
+	err := database.Get(request, q, uuid)
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return request, err == nil
+}
+
+// GetScopeRequestByCode returns the unconfirmed scope request with the given confirmation code.
+// Returns false if no such request exists or if the code is outdated.
+func GetScopeRequestByCode(code string) (*ScopeRequest, bool) {
+	const q = `SELECT * FROM ScopeRequests WHERE confirmationCode=$1 AND state=$2 AND createdAt > $3`
+
+	request := &ScopeRequest{}
+	err := database.Get(request, q, code, ScopeRequestUnconfirmed, expiryTime().Add(-scopeRequestConfirmLifeTime))
+	if err != nil && err != sql.ErrNoRows {
+		panic(err)
+	}
+
+	return request, err == nil
+}
+
+// RequestScope creates an unconfirmed request of the account for an elevated scope. The request
+// contains a confirmation code, which has to be sent to the account owner.
+func (acc *Account) RequestScope(scope, reason string) (*ScopeRequest, error) {
+	const qOpen = `SELECT count(*) FROM ScopeRequests WHERE accountUUID=$1 AND scope=$2 AND state=$3`
+	const q = `INSERT INTO ScopeRequests (uuid, accountUUID, scope, reason, state, confirmationCode, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, $5, $6, now(), now())
+	           RETURNING *`
+
+	reason = strings.TrimSpace(reason)
+	fieldErrors := make(map[string]string)
+	if !conf.GetRegistration().IsElevated(scope) {
+		fieldErrors["scope"] = fmt.Sprintf("The scope '%s' can not be requested", scope)
+	} else if acc.ElevatedScope().Contains(scope) {
+		fieldErrors["scope"] = fmt.Sprintf("The scope '%s' was already granted", scope)
+	} else {
+		var open int
+		err := database.Get(&open, qOpen, acc.UUID, scope, ScopeRequestPending)
+		if err != nil {
+			return nil, err
+		}
+		if open > 0 {
+			fieldErrors["scope"] = fmt.Sprintf("The scope '%s' was already requested", scope)
+		}
+	}
+	if reason == "" {
+		fieldErrors["reason"] = "Please explain why you need the scope"
+	} else if len(reason) > maxScopeRequestReason {
+		fieldErrors["reason"] = fmt.Sprintf("Please use at most %d characters", maxScopeRequestReason)
+	}
+	if len(fieldErrors) > 0 {
+		return nil, &util.ValidationError{Message: "Invalid scope request", FieldErrors: fieldErrors}
+	}
+
+	request := &ScopeRequest{}
+	err := database.Get(request, q, uuid.NewRandom().String(), acc.UUID, scope, reason,
+		ScopeRequestUnconfirmed, util.RandomToken())
+	return request, err
+}
+
+// Confirm marks an unconfirmed request as pending, thus the request is shown to administrators.
+func (req *ScopeRequest) Confirm() error {
+	const q = `UPDATE ScopeRequests SET (state, confirmationCode, updatedAt) = ($1, NULL, now())
+	           WHERE uuid=$2 AND state=$3
+	           RETURNING *`
+
+	err := database.Get(req, q, ScopeRequestPending, req.UUID, ScopeRequestUnconfirmed)
+	if err == sql.ErrNoRows {
+		return conflictError("Scope request is not waiting for confirmation")
+	}
+	return err
+}
+
+// Grant grants the scope of a pending request to the account. The given UUID
+// identifies the account of the administrator who granted the scope.
+func (req *ScopeRequest) Grant(grantedBy string) error {
+	const qGrant = `INSERT INTO AccountScopes (accountUUID, scope, grantedBy, createdAt)
+	                VALUES ($1, $2, $3, now())
+	                ON CONFLICT (accountUUID, scope) DO NOTHING`
+
+	tx := database.MustBegin()
+	err := req.decide(tx, ScopeRequestGranted, grantedBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	_, err = tx.Exec(qGrant, req.AccountUUID, req.Scope, req.DecidedBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Reject rejects a pending request. The given UUID identifies the account of the
+// administrator who rejected the request.
+func (req *ScopeRequest) Reject(rejectedBy string) error {
+	tx := database.MustBegin()
+	err := req.decide(tx, ScopeRequestRejected, rejectedBy)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// decide sets the final state of a pending request.
+func (req *ScopeRequest) decide(tx *sqlx.Tx, state, decidedBy string) error {
+	const q = `UPDATE ScopeRequests SET (state, decidedBy, updatedAt) = ($1, $2, now())
+	           WHERE uuid=$3 AND state=$4`
+
+	req.DecidedBy = sql.NullString{String: decidedBy, Valid: decidedBy != ""}
+	res, err := tx.Exec(q, state, req.DecidedBy, req.UUID, ScopeRequestPending)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return conflictError("Scope request is not pending")
+	}
+	req.State = state
+	return nil
+}
+
+// ElevatedScope returns all elevated scopes granted to the account.
+func (acc *Account) ElevatedScope() util.StringSet {
+	const q = `SELECT scope FROM AccountScopes WHERE accountUUID=$1`
+
+	scopes := make([]string, 0)
+	err := database.Select(&scopes, q, acc.UUID)
+	if err != nil {
+		panic(err)
+	}
+
+	return util.NewStringSet(scopes...)
+}
+
+// CheckElevatedScope returns an error if the scope contains elevated scopes, which
+// were not granted to the account with the given UUID.
+func CheckElevatedScope(accountUUID string, scope util.StringSet) error {
+	registration := conf.GetRegistration()
+	elevated := util.NewStringSet()
+	for _, s := range scope.Strings() {
+		if registration.IsElevated(s) {
+			elevated = elevated.Add(s)
+		}
+	}
+	if elevated.Len() == 0 {
+		return nil
+	}
+
+	acc := &Account{UUID: accountUUID}
+	if missing := elevated.Difference(acc.ElevatedScope()); missing.Len() > 0 {
+		return fmt.Errorf("Scope '%s' requires approval by an administrator", strings.Join(missing.Strings(), " "))
+	}
+	return nil
+}
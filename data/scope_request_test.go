@@ -0,0 +1,143 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestAccount_RequestScope(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	alice, _ := GetAccountByLogin("alice")
+
+	_, err := alice.RequestScope("account-admin", "I want to be admin")
+	if err == nil {
+		t.Error("Requesting a scope which is not elevated should fail")
+	}
+	_, err = alice.RequestScope("curator", " ")
+	if err == nil {
+		t.Error("Requesting a scope without reason should fail")
+	}
+
+	req, err := alice.RequestScope("curator", "I maintain public datasets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.State != ScopeRequestUnconfirmed || !req.ConfirmationCode.Valid {
+		t.Error("New request expected to be unconfirmed with confirmation code")
+	}
+	if len(ListPendingScopeRequests()) != 0 {
+		t.Error("Unconfirmed requests should not be pending")
+	}
+	if len(ListAccountScopeRequests(alice.UUID)) != 1 {
+		t.Error("One request of alice expected")
+	}
+}
+
+func TestScopeRequest_Confirm(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	alice, _ := GetAccountByLogin("alice")
+	created, _ := alice.RequestScope("curator", "I maintain public datasets")
+
+	if _, ok := GetScopeRequestByCode("doesnotexist"); ok {
+		t.Error("Request with invalid code should not exist")
+	}
+	req, ok := GetScopeRequestByCode(created.ConfirmationCode.String)
+	if !ok {
+		t.Fatal("Unable to get request by confirmation code")
+	}
+
+	err := req.Confirm()
+	if err != nil {
+		t.Error(err)
+	}
+	if req.State != ScopeRequestPending || req.ConfirmationCode.Valid {
+		t.Error("Confirmed request expected to be pending without confirmation code")
+	}
+	if err = req.Confirm(); KindOf(err) != ErrConflict {
+		t.Error("Confirming a request twice should fail with a conflict")
+	}
+	if pending := ListPendingScopeRequests(); len(pending) != 1 || pending[0].UUID != req.UUID {
+		t.Error("Confirmed request expected to be pending")
+	}
+
+	_, err = alice.RequestScope("curator", "Once more")
+	if err == nil {
+		t.Error("Requesting a scope with a pending request should fail")
+	}
+}
+
+func TestScopeRequest_Grant(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	alice, _ := GetAccountByLogin("alice")
+	bob, _ := GetAccountByLogin("bob")
+	req, _ := alice.RequestScope("curator", "I maintain public datasets")
+
+	if err := req.Grant(bob.UUID); err == nil {
+		t.Error("Granting an unconfirmed request should fail")
+	}
+	if alice.ElevatedScope().Len() != 0 {
+		t.Error("Scope should not be granted")
+	}
+	if CheckElevatedScope(alice.UUID, util.NewStringSet("account-read", "curator")) == nil {
+		t.Error("Scope 'curator' should require approval")
+	}
+
+	req.Confirm()
+	err := req.Grant(bob.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req.State != ScopeRequestGranted || req.DecidedBy.String != bob.UUID {
+		t.Error("Request expected to be granted by bob")
+	}
+	if !alice.ElevatedScope().Contains("curator") {
+		t.Error("Scope 'curator' expected to be granted to alice")
+	}
+	if err = CheckElevatedScope(alice.UUID, util.NewStringSet("account-read", "curator")); err != nil {
+		t.Error(err)
+	}
+	if err = req.Reject(bob.UUID); err == nil {
+		t.Error("Rejecting a granted request should fail")
+	}
+
+	_, err = alice.RequestScope("curator", "Once more")
+	if err == nil {
+		t.Error("Requesting a granted scope should fail")
+	}
+}
+
+func TestScopeRequest_Reject(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	alice, _ := GetAccountByLogin("alice")
+	req, _ := alice.RequestScope("curator", "I maintain public datasets")
+	req.Confirm()
+
+	err := req.Reject("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, _ := GetScopeRequest(req.UUID)
+	if check.State != ScopeRequestRejected {
+		t.Error("Request expected to be rejected")
+	}
+	if alice.ElevatedScope().Len() != 0 {
+		t.Error("Scope should not be granted")
+	}
+}
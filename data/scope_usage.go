@@ -0,0 +1,121 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ScopeUsage contains the number of token validations of a registered scope within a period
+// and the last day the scope was used at all. Unused is true if no token with the scope was
+// validated within the period.
+type ScopeUsage struct {
+	Name             string
+	Provider         string
+	TokenValidations int64
+	LastUsed         pq.NullTime
+	Unused           bool
+}
+
+type scopeUsageKey struct {
+	day   string
+	scope string
+}
+
+// Scope usage is counted in memory and written to the database together with the usage of accounts.
+var scopeUsage = struct {
+	sync.Mutex
+	counts map[scopeUsageKey]int64
+}{counts: make(map[scopeUsageKey]int64)}
+
+// recordScopeUsage counts a validation for each scope of the token.
+func recordScopeUsage(token *AccessToken) {
+	day := time.Now().Format("2006-01-02")
+
+	scopeUsage.Lock()
+	defer scopeUsage.Unlock()
+
+	for _, scope := range token.Scope.Strings() {
+		scopeUsage.counts[scopeUsageKey{day, scope}]++
+	}
+}
+
+// flushScopeUsage adds all scope usage counted since the last flush to the daily counters
+// in the database. If writing fails, the counts are kept for the next flush.
+func flushScopeUsage() error {
+	scopeUsage.Lock()
+	counts := scopeUsage.counts
+	scopeUsage.counts = make(map[scopeUsageKey]int64)
+	scopeUsage.Unlock()
+
+	if len(counts) == 0 {
+		return nil
+	}
+
+	const q = `INSERT INTO ScopeUsage (day, scope, tokenValidations) VALUES ($1, $2, $3)
+	           ON CONFLICT (day, scope) DO UPDATE
+	           SET tokenValidations = ScopeUsage.tokenValidations + EXCLUDED.tokenValidations`
+
+	tx, err := database.Beginx()
+	if err != nil {
+		restoreScopeUsage(counts)
+		return err
+	}
+	for key, count := range counts {
+		_, err := tx.Exec(q, key.day, key.scope, count)
+		if err != nil {
+			tx.Rollback()
+			restoreScopeUsage(counts)
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		restoreScopeUsage(counts)
+	}
+	return err
+}
+
+// restoreScopeUsage adds counts which could not be written back to the in memory counters.
+func restoreScopeUsage(counts map[scopeUsageKey]int64) {
+	scopeUsage.Lock()
+	defer scopeUsage.Unlock()
+
+	for key, count := range counts {
+		scopeUsage.counts[key] += count
+	}
+}
+
+// ListScopeUsage returns the usage of all scopes provided by the registered clients since
+// the given day, ordered by name. Scopes are only counted since the usage is recorded, thus
+// scopes used before are reported as unused until tokens with the scope are validated again.
+func ListScopeUsage(since time.Time) []ScopeUsage {
+	const q = `SELECT s.name, c.name AS provider,
+	                  (SELECT COALESCE(SUM(u.tokenValidations), 0) FROM ScopeUsage u
+	                   WHERE u.scope = s.name AND u.day >= $1) AS tokenValidations,
+	                  (SELECT MAX(u.day) FROM ScopeUsage u
+	                   WHERE u.scope = s.name AND u.tokenValidations > 0) AS lastUsed
+	           FROM ClientScopeProvided s JOIN Clients c ON c.uuid = s.clientUUID
+	           ORDER BY s.name`
+
+	scopes := make([]ScopeUsage, 0)
+	err := database.Select(&scopes, q, since.Format("2006-01-02"))
+	if err != nil {
+		panic(err)
+	}
+
+	for i := range scopes {
+		scopes[i].Unused = scopes[i].TokenValidations == 0
+	}
+	return scopes
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestListScopeUsage(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	scopes := ListScopeUsage(time.Now().AddDate(0, -6, 0))
+	if len(scopes) != 6 {
+		t.Fatalf("Six scopes expected but was %d", len(scopes))
+	}
+	usage := make(map[string]ScopeUsage)
+	for _, s := range scopes {
+		usage[s.Name] = s
+	}
+	if s := usage["repo-read"]; s.TokenValidations != 60 || s.Unused || s.Provider != "gin" {
+		t.Errorf("Unexpected usage of repo-read: %v", s)
+	}
+	if s := usage["account-create"]; !s.Unused || !s.LastUsed.Valid {
+		t.Errorf("Scope account-create expected to be unused: %v", s)
+	}
+	if s := usage["account-admin"]; !s.Unused || s.LastUsed.Valid {
+		t.Errorf("Scope account-admin expected to be never used: %v", s)
+	}
+
+	scopes = ListScopeUsage(time.Now().AddDate(0, -1, 0))
+	for _, s := range scopes {
+		if s.Name == "repo-write" && !s.Unused {
+			t.Error("Scope repo-write expected to be unused within the last month")
+		}
+	}
+}
+
+func TestFlushScopeUsage(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	RecordTokenValidation(&AccessToken{ClientUUID: usageGinUUID, Scope: util.NewStringSet("account-admin", "repo-read")})
+	err := FlushUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range ListScopeUsage(time.Now()) {
+		if (s.Name == "account-admin" && s.TokenValidations != 1) || (s.Name == "repo-read" && s.TokenValidations != 41) {
+			t.Errorf("Unexpected usage after flush: %v", s)
+		}
+	}
+}
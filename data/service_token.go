@@ -0,0 +1,307 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+	"github.com/pborman/uuid"
+)
+
+// Scopes of service tokens. Service scopes are never granted to clients, they only authorize
+// the background jobs of the server.
+const (
+	ScopeServiceCleanup = "service-cleanup" // remove expired and stale data, enforce dormancy and retention
+	ScopeServiceEmail   = "service-email"   // dispatch notifications, announcements and queued e-mails
+	ScopeServiceUsage   = "service-usage"   // write counted API usage
+)
+
+// Background jobs which act with service tokens
+const (
+	JobCleaner        = "cleaner"
+	JobGrantRequestGC = "grant-request-gc"
+	JobEmailDispatch  = "email-dispatch"
+	JobUsageFlush     = "usage-flush"
+)
+
+// serviceJobScopes contains the scopes of the tokens minted for each background job
+var serviceJobScopes = map[string][]string{
+	JobCleaner:        {ScopeServiceCleanup},
+	JobGrantRequestGC: {ScopeServiceCleanup},
+	JobEmailDispatch:  {ScopeServiceEmail},
+	JobUsageFlush:     {ScopeServiceUsage},
+}
+
+// ServiceToken is a token with which a background job acts. Only the hash of the token is stored,
+// the UUID identifies the token in audit events.
+type ServiceToken struct {
+	UUID      string
+	TokenHash string
+	Job       string
+	Scope     util.StringSet
+	Expires   time.Time
+	CreatedAt time.Time
+}
+
+// serviceTokenHash returns the hex encoded SHA-256 hash of a service token.
+func serviceTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// ListServiceTokens returns all valid service tokens sorted by creation time.
+func ListServiceTokens() []ServiceToken {
+	const q = `SELECT * FROM ServiceTokens WHERE expires > $1 ORDER BY createdAt`
+
+	tokens := make([]ServiceToken, 0)
+	err := database.Select(&tokens, q, expiryTime())
+	if err != nil {
+		panic(err)
+	}
+
+	return tokens
+}
+
+// AuthorizeService checks that a service token is valid and carries the given scope and returns it.
+// Returns an error of kind ErrNotFound for unknown, revoked or expired tokens and tokens of disabled
+// jobs and an error of kind ErrForbidden if the scope is missing.
+func AuthorizeService(token, scope string) (*ServiceToken, error) {
+	const q = `SELECT t.* FROM ServiceTokens t
+	           WHERE t.tokenHash=$1 AND t.expires > $2
+	           AND NOT EXISTS (SELECT 1 FROM ServiceJobs j WHERE j.job = t.job AND j.isDisabled)`
+
+	tok := &ServiceToken{}
+	err := database.Get(tok, q, serviceTokenHash(token), expiryTime())
+	if err == sql.ErrNoRows {
+		return nil, notFoundError("Service token does not exist or expired")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !tok.Scope.Contains(scope) {
+		return nil, forbiddenError("Service token of job '%s' lacks scope '%s'", tok.Job, scope)
+	}
+
+	return tok, nil
+}
+
+// RevokeServiceTokens removes all service tokens of a job, or of all jobs if job is empty, and
+// disables the jobs. Disabled jobs do not mint new tokens and skip their runs until they are
+// enabled with EnableServiceJobs.
+func RevokeServiceTokens(job string) (int64, error) {
+	const qDisable = `INSERT INTO ServiceJobs (job, isDisabled, updatedAt) VALUES ($1, TRUE, now())
+	                  ON CONFLICT (job) DO UPDATE SET isDisabled = TRUE, updatedAt = now()`
+	const qRevoke = `DELETE FROM ServiceTokens WHERE job=$1 OR $1=''`
+
+	jobs, err := serviceJobs(job)
+	if err != nil {
+		return 0, err
+	}
+
+	tx := database.MustBegin()
+	for _, j := range jobs {
+		_, err = tx.Exec(qDisable, j)
+		if err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	res, err := tx.Exec(qRevoke, job)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// EnableServiceJobs enables a job, or all jobs if job is empty, after its tokens were revoked.
+// The job mints a new token on its next run.
+func EnableServiceJobs(job string) error {
+	const q = `UPDATE ServiceJobs SET isDisabled = FALSE, updatedAt = now() WHERE job=$1 OR $1=''`
+
+	if _, err := serviceJobs(job); err != nil {
+		return err
+	}
+	_, err := database.Exec(q, job)
+	return err
+}
+
+// ListDisabledServiceJobs returns the names of all disabled jobs.
+func ListDisabledServiceJobs() []string {
+	const q = `SELECT job FROM ServiceJobs WHERE isDisabled ORDER BY job`
+
+	jobs := make([]string, 0)
+	err := database.Select(&jobs, q)
+	if err != nil {
+		panic(err)
+	}
+
+	return jobs
+}
+
+// serviceJobs returns the given job after checking that it exists, or all jobs if job is empty.
+func serviceJobs(job string) ([]string, error) {
+	if job != "" {
+		if _, ok := serviceJobScopes[job]; !ok {
+			return nil, fmt.Errorf("Unknown background job '%s'", job)
+		}
+		return []string{job}, nil
+	}
+	jobs := make([]string, 0, len(serviceJobScopes))
+	for j := range serviceJobScopes {
+		jobs = append(jobs, j)
+	}
+	return jobs, nil
+}
+
+// mintServiceToken stores a new token with the scopes of the given job and returns the token
+// together with its database entry. Returns an error of kind ErrForbidden if the job is disabled.
+func mintServiceToken(job string) (string, *ServiceToken, error) {
+	const q = `INSERT INTO ServiceTokens (uuid, tokenHash, job, scope, expires, createdAt)
+	           SELECT $1, $2, $3, $4, $5, $6
+	           WHERE NOT EXISTS (SELECT 1 FROM ServiceJobs WHERE job = $3 AND isDisabled)
+	           RETURNING *`
+
+	scope, ok := serviceJobScopes[job]
+	if !ok {
+		return "", nil, fmt.Errorf("Unknown background job '%s'", job)
+	}
+
+	token := util.RandomToken()
+	now := util.Now()
+	tok := &ServiceToken{}
+	err := database.Get(tok, q, uuid.NewRandom().String(), serviceTokenHash(token), job, util.NewStringSet(scope...),
+		now.Add(conf.GetServiceTokens().LifeTime), now)
+	if err == sql.ErrNoRows {
+		return "", nil, forbiddenError("Background job '%s' is disabled", job)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, tok, nil
+}
+
+// audit returns an entry of the audit log which names the job and the service token it acts with.
+func (tok *ServiceToken) audit(fields logrus.Fields) *logrus.Entry {
+	return conf.GetLogEnv().Audit.WithFields(fields).WithFields(logrus.Fields{"job": tok.Job, "service_token": tok.UUID})
+}
+
+// ServiceCredentials hold the service token of a background job. The token is minted on first use
+// and renewed once half of its life time has passed or when it expired. Revoked tokens are not
+// renewed until the job is enabled again.
+type ServiceCredentials struct {
+	job   string
+	lock  sync.Mutex
+	token string
+	entry *ServiceToken
+}
+
+// NewServiceCredentials creates the credentials of a background job without minting a token yet.
+func NewServiceCredentials(job string) *ServiceCredentials {
+	return &ServiceCredentials{job: job}
+}
+
+// Job returns the name of the background job the credentials belong to.
+func (c *ServiceCredentials) Job() string {
+	return c.job
+}
+
+// Authorize returns the current service token of the job after checking that it carries the
+// given scope. The token is renewed if necessary.
+func (c *ServiceCredentials) Authorize(scope string) (*ServiceToken, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.renewalDue() {
+		err := c.renew()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tok, err := AuthorizeService(c.token, scope)
+	if KindOf(err) == ErrNotFound {
+		// the token expired while the job was not running or was revoked with gin-auth-admin,
+		// in the latter case the job is disabled and renewing fails
+		err = c.renew()
+		if err != nil {
+			return nil, err
+		}
+		tok, err = AuthorizeService(c.token, scope)
+	}
+	return tok, err
+}
+
+// renewalDue checks whether no token was minted yet or half of the life time of the token has passed.
+func (c *ServiceCredentials) renewalDue() bool {
+	if c.entry == nil {
+		return true
+	}
+	halfLife := c.entry.Expires.Sub(c.entry.CreatedAt) / 2
+	return !util.Now().Before(c.entry.CreatedAt.Add(halfLife))
+}
+
+// renew mints a new token for the job and removes the previous one.
+func (c *ServiceCredentials) renew() error {
+	token, entry, err := mintServiceToken(c.job)
+	if err != nil {
+		return err
+	}
+
+	fields := logrus.Fields{
+		"event":   "service-token-issued",
+		"job":     c.job,
+		"token":   entry.UUID,
+		"scope":   entry.Scope.Strings(),
+		"expires": entry.Expires.Format(time.RFC3339),
+	}
+	if c.entry != nil {
+		fields["event"] = "service-token-renewed"
+		fields["previous"] = c.entry.UUID
+		_, err = database.Exec(`DELETE FROM ServiceTokens WHERE uuid=$1`, c.entry.UUID)
+		if err != nil {
+			conf.GetLogEnv().Err.Errorf("Error removing service token of job %s: %s\n", c.job, err.Error())
+		}
+	}
+	conf.GetLogEnv().Audit.WithFields(fields).Info("Issued service token")
+
+	c.token = token
+	c.entry = entry
+	return nil
+}
+
+// authorizeJob checks that a background job holds a service token with the scope required for its
+// work and returns the token, with which the job acts. A failure is logged and the job has to skip
+// its run.
+func authorizeJob(creds *ServiceCredentials, scope string) (*ServiceToken, bool) {
+	tok, err := creds.Authorize(scope)
+	if err != nil {
+		conf.GetLogEnv().Err.Errorf("Error authorizing background job %s: %s\n", creds.Job(), err.Error())
+		conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+			"event": "service-job-denied",
+			"job":   creds.Job(),
+			"scope": scope,
+		}).Warn("Background job was not authorized")
+		return nil, false
+	}
+	return tok, true
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// testServiceToken mints a token for a background job run by a test.
+func testServiceToken(t *testing.T, job string) *ServiceToken {
+	_, tok, err := mintServiceToken(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+func TestAuthorizeService(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	token, tok, err := mintServiceToken(JobEmailDispatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.TokenHash == token || !tok.Scope.Contains(ScopeServiceEmail) {
+		t.Errorf("Hashed token with scope %s expected: %+v", ScopeServiceEmail, tok)
+	}
+
+	check, err := AuthorizeService(token, ScopeServiceEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if check.UUID != tok.UUID || check.Job != JobEmailDispatch {
+		t.Errorf("Token of job %s expected: %+v", JobEmailDispatch, check)
+	}
+
+	_, err = AuthorizeService(token, ScopeServiceCleanup)
+	if KindOf(err) != ErrForbidden {
+		t.Errorf("Error of kind ErrForbidden expected but was %v", err)
+	}
+	_, err = AuthorizeService("doesnotexist", ScopeServiceEmail)
+	if KindOf(err) != ErrNotFound {
+		t.Errorf("Error of kind ErrNotFound expected but was %v", err)
+	}
+	if _, _, err = mintServiceToken("doesnotexist"); err == nil {
+		t.Error("Error expected for an unknown job")
+	}
+}
+
+func TestServiceCredentials_Authorize(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	now := time.Now()
+	defer util.SetClock(util.SetClock(util.FixedClock(now)))
+
+	creds := NewServiceCredentials(JobCleaner)
+	first, err := creds.Authorize(ScopeServiceCleanup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = creds.Authorize(ScopeServiceUsage); KindOf(err) != ErrForbidden {
+		t.Errorf("Error of kind ErrForbidden expected but was %v", err)
+	}
+	if tok, _ := creds.Authorize(ScopeServiceCleanup); tok.UUID != first.UUID {
+		t.Error("Token expected to be reused before half of its life time has passed")
+	}
+
+	// renewed after half of the life time
+	util.SetClock(util.FixedClock(now.Add(conf.GetServiceTokens().LifeTime/2 + time.Second)))
+	second, err := creds.Authorize(ScopeServiceCleanup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.UUID == first.UUID {
+		t.Error("Renewed token expected")
+	}
+	if tokens := ListServiceTokens(); len(tokens) != 1 || tokens[0].UUID != second.UUID {
+		t.Errorf("Only the renewed token expected: %+v", tokens)
+	}
+
+	// revoked tokens are not renewed until the job is enabled
+	count, err := RevokeServiceTokens(JobCleaner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("One revoked token expected but was %d", count)
+	}
+	if _, err = creds.Authorize(ScopeServiceCleanup); KindOf(err) != ErrForbidden {
+		t.Errorf("Error of kind ErrForbidden expected but was %v", err)
+	}
+	if len(ListServiceTokens()) != 0 {
+		t.Error("No token expected to be minted for a disabled job")
+	}
+	if jobs := ListDisabledServiceJobs(); len(jobs) != 1 || jobs[0] != JobCleaner {
+		t.Errorf("Job '%s' expected to be disabled: %v", JobCleaner, jobs)
+	}
+
+	err = EnableServiceJobs(JobCleaner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := creds.Authorize(ScopeServiceCleanup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third.UUID == second.UUID {
+		t.Error("Token expected to be renewed after the job was enabled")
+	}
+}
+
+func TestRevokeServiceTokens(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	token, _, err := mintServiceToken(JobEmailDispatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = RevokeServiceTokens("doesnotexist"); err == nil {
+		t.Error("Error expected for an unknown job")
+	}
+	if _, err = RevokeServiceTokens(""); err != nil {
+		t.Fatal(err)
+	}
+	if len(ListDisabledServiceJobs()) != len(serviceJobScopes) {
+		t.Errorf("All jobs expected to be disabled: %v", ListDisabledServiceJobs())
+	}
+	if _, err = AuthorizeService(token, ScopeServiceEmail); KindOf(err) != ErrNotFound {
+		t.Errorf("Error of kind ErrNotFound expected but was %v", err)
+	}
+	if _, _, err = mintServiceToken(JobUsageFlush); KindOf(err) != ErrForbidden {
+		t.Errorf("Error of kind ErrForbidden expected but was %v", err)
+	}
+
+	err = EnableServiceJobs("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ListDisabledServiceJobs()) != 0 {
+		t.Error("No disabled jobs expected")
+	}
+}
@@ -9,7 +9,9 @@
 package data
 
 import (
-	"database/sql"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
 	"time"
 
 	"github.com/G-Node/gin-auth/conf"
@@ -22,16 +24,31 @@ type Session struct {
 	Token       string
 	Expires     time.Time
 	AccountUUID string
+	UserAgent   string
+	DeviceName  string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
 
+// Maximum length of stored user agents and device names
+const (
+	maxUserAgentLength  = 512
+	maxDeviceNameLength = 64
+)
+
 // ListSessions returns all sessions sorted by creation time.
 func ListSessions() []Session {
-	const q = `SELECT * FROM Sessions WHERE expires > now() ORDER BY createdAt`
+	sessions, err := sessionStore().List()
+	if err != nil {
+		panic(err)
+	}
 
-	sessions := make([]Session, 0)
-	err := database.Select(&sessions, q)
+	return sessions
+}
+
+// ListAccountSessions returns all sessions of an account sorted by creation time (latest first).
+func ListAccountSessions(accountUUID string) []Session {
+	sessions, err := sessionStore().ListAccount(accountUUID)
 	if err != nil {
 		panic(err)
 	}
@@ -39,49 +56,96 @@ func ListSessions() []Session {
 	return sessions
 }
 
+// LimitAccountSessions deletes the oldest sessions of an account until at most max sessions
+// are left and returns the deleted sessions. A max of zero or less keeps all sessions.
+func LimitAccountSessions(accountUUID string, max int) ([]Session, error) {
+	if max <= 0 {
+		return nil, nil
+	}
+
+	sessions, err := sessionStore().ListAccount(accountUUID)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) <= max {
+		return nil, nil
+	}
+
+	evicted := sessions[max:]
+	for i := range evicted {
+		err = sessionStore().Delete(&evicted[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return evicted, nil
+}
+
 // GetSession returns a session with a given token.
 // Returns false if no such session exists.
 func GetSession(token string) (*Session, bool) {
-	const q = `SELECT * FROM Sessions WHERE token=$1 AND expires > now()`
-
-	session := &Session{}
-	err := database.Get(session, q, token)
-	if err != nil && err != sql.ErrNoRows {
+	session, err := sessionStore().Get(token)
+	if KindOf(err) == ErrNotFound {
+		return &Session{}, false
+	}
+	if err != nil {
 		panic(err)
 	}
 
-	return session, err == nil
+	return session, true
 }
 
 // Create stores a new session.
 // If the token is empty a random token will be generated.
 func (sess *Session) Create() error {
-	const q = `INSERT INTO Sessions (token, expires, accountUUID, createdAt, updatedAt)
-	           VALUES ($1, $2, $3, now(), now())
-	           RETURNING *`
-
-	sess.Expires = time.Now().Add(conf.GetServerConfig().SessionLifeTime)
+	sess.Expires = util.Now().Add(conf.GetServerConfig().SessionLifeTime)
 	if sess.Token == "" {
 		sess.Token = util.RandomToken()
 	}
+	sess.UserAgent = util.SanitizeString(sess.UserAgent, maxUserAgentLength)
+
+	return sessionStore().Create(sess)
+}
+
+// ID returns an identifier of the session which can be shown to the user
+// without revealing the session token.
+func (sess *Session) ID() string {
+	sum := sha256.Sum256([]byte("session:" + sess.Token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Device returns the name of the device given by the user or, if there is none,
+// a label derived from the user agent, e.g. "Firefox on Linux".
+func (sess *Session) Device() string {
+	if sess.DeviceName != "" {
+		return sess.DeviceName
+	}
+	return util.ParseUserAgent(sess.UserAgent).String()
+}
+
+// Rename sets the device name of the session. An empty name restores the
+// label derived from the user agent.
+func (sess *Session) Rename(name string) error {
+	name = strings.TrimSpace(name)
+	if len(name) > maxDeviceNameLength {
+		return &util.ValidationError{
+			Message:     "Invalid device name",
+			FieldErrors: map[string]string{"name": "Please use a name with at most 64 characters"}}
+	}
 
-	return database.Get(sess, q, sess.Token, sess.Expires, sess.AccountUUID)
+	sess.DeviceName = name
+	return sessionStore().Update(sess)
 }
 
 // UpdateExpirationTime updates the expiration time and stores
-// the new time in the database.
+// the new time in the session store.
 func (sess *Session) UpdateExpirationTime() error {
-	const q = `UPDATE Sessions SET (expires, updatedAt) = ($1, now())
-	           WHERE token=$2
-	           RETURNING *`
-
-	return database.Get(sess, q, time.Now().Add(conf.GetServerConfig().SessionLifeTime), sess.Token)
+	sess.Expires = util.Now().Add(conf.GetServerConfig().SessionLifeTime)
+	return sessionStore().Update(sess)
 }
 
-// Delete removes a session from the database.
+// Delete removes a session from the session store.
 func (sess *Session) Delete() error {
-	const q = `DELETE FROM Sessions WHERE token=$1`
-
-	_, err := database.Exec(q, sess.Token)
-	return err
+	return sessionStore().Delete(sess)
 }
@@ -0,0 +1,445 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// SessionStore persists login sessions. Get returns an error of kind ErrNotFound if there
+// is no session with the token or if the session is expired. Lists only contain sessions
+// which are not expired.
+type SessionStore interface {
+	List() ([]Session, error)
+	ListAccount(accountUUID string) ([]Session, error)
+	Get(token string) (*Session, error)
+	Create(sess *Session) error
+	Update(sess *Session) error
+	Delete(sess *Session) error
+	DeleteAccount(accountUUID string) error
+	DeleteExpired() error
+}
+
+var sessionStoreInstance SessionStore
+var sessionStoreLock = sync.Mutex{}
+
+// sessionStore returns the session store configured in the server configuration.
+func sessionStore() SessionStore {
+	sessionStoreLock.Lock()
+	defer sessionStoreLock.Unlock()
+
+	if sessionStoreInstance == nil {
+		config := conf.GetSessionStore()
+		switch config.Backend {
+		case conf.SessionStoreRedis:
+			client := util.NewRedisClient(config.RedisAddress, config.RedisPassword, config.RedisDB)
+			sessionStoreInstance = NewRedisSessionStore(client, config.RedisPrefix)
+		case conf.SessionStoreMemory:
+			sessionStoreInstance = NewMemorySessionStore()
+		default:
+			sessionStoreInstance = &pgSessionStore{}
+		}
+	}
+
+	return sessionStoreInstance
+}
+
+// SetSessionStore replaces the configured session store.
+func SetSessionStore(store SessionStore) {
+	sessionStoreLock.Lock()
+	defer sessionStoreLock.Unlock()
+
+	sessionStoreInstance = store
+}
+
+// sortSessions sorts sessions by creation time, the latest first if desc is true.
+func sortSessions(sessions []Session, desc bool) {
+	sort.Sort(sessionsByCreation{sessions, desc})
+}
+
+type sessionsByCreation struct {
+	sessions []Session
+	desc     bool
+}
+
+func (s sessionsByCreation) Len() int { return len(s.sessions) }
+func (s sessionsByCreation) Swap(i, j int) {
+	s.sessions[i], s.sessions[j] = s.sessions[j], s.sessions[i]
+}
+func (s sessionsByCreation) Less(i, j int) bool {
+	if s.desc {
+		return s.sessions[i].CreatedAt.After(s.sessions[j].CreatedAt)
+	}
+	return s.sessions[i].CreatedAt.Before(s.sessions[j].CreatedAt)
+}
+
+// pgSessionStore keeps sessions in the Sessions table of the database.
+type pgSessionStore struct{}
+
+func (s *pgSessionStore) List() ([]Session, error) {
+	const q = `SELECT * FROM Sessions WHERE expires > $1 ORDER BY createdAt`
+
+	sessions := make([]Session, 0)
+	err := database.Select(&sessions, q, expiryTime())
+	return sessions, err
+}
+
+func (s *pgSessionStore) ListAccount(accountUUID string) ([]Session, error) {
+	const q = `SELECT * FROM Sessions WHERE accountUUID = $1 AND expires > $2 ORDER BY createdAt DESC`
+
+	sessions := make([]Session, 0)
+	err := database.Select(&sessions, q, accountUUID, expiryTime())
+	return sessions, err
+}
+
+func (s *pgSessionStore) Get(token string) (*Session, error) {
+	const q = `SELECT * FROM Sessions WHERE token=$1 AND expires > $2`
+
+	session := &Session{}
+	err := database.Get(session, q, token, expiryTime())
+	if err == sql.ErrNoRows {
+		return nil, notFoundError("Session does not exist")
+	}
+	return session, err
+}
+
+func (s *pgSessionStore) Create(sess *Session) error {
+	const q = `INSERT INTO Sessions (token, expires, accountUUID, userAgent, deviceName, createdAt, updatedAt)
+	           VALUES ($1, $2, $3, $4, $5, now(), now())
+	           RETURNING *`
+
+	return database.Get(sess, q, sess.Token, sess.Expires, sess.AccountUUID, sess.UserAgent, sess.DeviceName)
+}
+
+func (s *pgSessionStore) Update(sess *Session) error {
+	const q = `UPDATE Sessions SET (expires, deviceName, updatedAt) = ($1, $2, now())
+	           WHERE token=$3
+	           RETURNING *`
+
+	return database.Get(sess, q, sess.Expires, sess.DeviceName, sess.Token)
+}
+
+func (s *pgSessionStore) Delete(sess *Session) error {
+	const q = `DELETE FROM Sessions WHERE token=$1`
+
+	_, err := database.Exec(q, sess.Token)
+	return err
+}
+
+func (s *pgSessionStore) DeleteAccount(accountUUID string) error {
+	const q = `DELETE FROM Sessions WHERE accountUUID=$1`
+
+	_, err := database.Exec(q, accountUUID)
+	return err
+}
+
+func (s *pgSessionStore) DeleteExpired() error {
+	const q = `DELETE FROM Sessions WHERE expires <= $1`
+
+	_, err := database.Exec(q, expiryTime())
+	return err
+}
+
+// memorySessionStore keeps sessions in memory, thus all sessions are lost on restart.
+type memorySessionStore struct {
+	sync.Mutex
+	sessions map[string]Session
+}
+
+// NewMemorySessionStore creates an empty session store which keeps sessions in memory.
+func NewMemorySessionStore() SessionStore {
+	return &memorySessionStore{sessions: make(map[string]Session)}
+}
+
+func (s *memorySessionStore) List() ([]Session, error) {
+	return s.filter(func(sess *Session) bool { return true }, false), nil
+}
+
+func (s *memorySessionStore) ListAccount(accountUUID string) ([]Session, error) {
+	return s.filter(func(sess *Session) bool { return sess.AccountUUID == accountUUID }, true), nil
+}
+
+func (s *memorySessionStore) filter(match func(sess *Session) bool, desc bool) []Session {
+	s.Lock()
+	defer s.Unlock()
+
+	now := expiryTime()
+	sessions := make([]Session, 0)
+	for _, sess := range s.sessions {
+		if sess.Expires.After(now) && match(&sess) {
+			sessions = append(sessions, sess)
+		}
+	}
+	sortSessions(sessions, desc)
+	return sessions
+}
+
+func (s *memorySessionStore) Get(token string) (*Session, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	sess, ok := s.sessions[token]
+	if !ok || !sess.Expires.After(expiryTime()) {
+		return nil, notFoundError("Session does not exist")
+	}
+	return &sess, nil
+}
+
+func (s *memorySessionStore) Create(sess *Session) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if _, ok := s.sessions[sess.Token]; ok {
+		return conflictError("Session already exists")
+	}
+	sess.CreatedAt = util.Now()
+	sess.UpdatedAt = sess.CreatedAt
+	s.sessions[sess.Token] = *sess
+	return nil
+}
+
+func (s *memorySessionStore) Update(sess *Session) error {
+	s.Lock()
+	defer s.Unlock()
+
+	stored, ok := s.sessions[sess.Token]
+	if !ok {
+		return notFoundError("Session does not exist")
+	}
+	stored.Expires = sess.Expires
+	stored.DeviceName = sess.DeviceName
+	stored.UpdatedAt = util.Now()
+	s.sessions[sess.Token] = stored
+	*sess = stored
+	return nil
+}
+
+func (s *memorySessionStore) Delete(sess *Session) error {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.sessions, sess.Token)
+	return nil
+}
+
+func (s *memorySessionStore) DeleteAccount(accountUUID string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for token, sess := range s.sessions {
+		if sess.AccountUUID == accountUUID {
+			delete(s.sessions, token)
+		}
+	}
+	return nil
+}
+
+func (s *memorySessionStore) DeleteExpired() error {
+	s.Lock()
+	defer s.Unlock()
+
+	now := expiryTime()
+	for token, sess := range s.sessions {
+		if !sess.Expires.After(now) {
+			delete(s.sessions, token)
+		}
+	}
+	return nil
+}
+
+// redisSessionStore keeps sessions in redis, such that several instances of gin-auth
+// can share them. Each session is stored as JSON under its own key which expires together
+// with the session. Sets of tokens are kept for listing all sessions and those of an account,
+// tokens of expired sessions are removed from these sets when they are listed.
+type redisSessionStore struct {
+	client *util.RedisClient
+	prefix string
+}
+
+// NewRedisSessionStore creates a session store which keeps sessions in redis. All keys
+// start with prefix.
+func NewRedisSessionStore(client *util.RedisClient, prefix string) SessionStore {
+	return &redisSessionStore{client: client, prefix: prefix}
+}
+
+func (s *redisSessionStore) key(token string) string {
+	return s.prefix + "session:" + token
+}
+
+func (s *redisSessionStore) allKey() string {
+	return s.prefix + "sessions"
+}
+
+func (s *redisSessionStore) accountKey(accountUUID string) string {
+	return s.prefix + "account-sessions:" + accountUUID
+}
+
+func (s *redisSessionStore) List() ([]Session, error) {
+	return s.list(s.allKey(), false)
+}
+
+func (s *redisSessionStore) ListAccount(accountUUID string) ([]Session, error) {
+	return s.list(s.accountKey(accountUUID), true)
+}
+
+// list returns all sessions with a token in the set and removes tokens of expired sessions.
+func (s *redisSessionStore) list(set string, desc bool) ([]Session, error) {
+	tokens, err := s.client.Strings("SMEMBERS", set)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(tokens))
+	for _, token := range tokens {
+		sess, err := s.Get(token)
+		if KindOf(err) == ErrNotFound {
+			_, err = s.client.Do("SREM", set, token)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *sess)
+	}
+	sortSessions(sessions, desc)
+	return sessions, nil
+}
+
+func (s *redisSessionStore) Get(token string) (*Session, error) {
+	reply, err := s.client.Do("GET", s.key(token))
+	if err != nil {
+		return nil, err
+	}
+	value, ok := reply.(string)
+	if !ok {
+		return nil, notFoundError("Session does not exist")
+	}
+
+	sess := &Session{}
+	err = json.Unmarshal([]byte(value), sess)
+	if err != nil {
+		return nil, err
+	}
+	if !sess.Expires.After(expiryTime()) {
+		return nil, notFoundError("Session does not exist")
+	}
+	return sess, nil
+}
+
+func (s *redisSessionStore) Create(sess *Session) error {
+	sess.CreatedAt = util.Now()
+	sess.UpdatedAt = sess.CreatedAt
+
+	err := s.set(sess, "NX")
+	if err != nil {
+		return err
+	}
+	if _, err = s.client.Do("SADD", s.allKey(), sess.Token); err != nil {
+		return err
+	}
+	if _, err = s.client.Do("SADD", s.accountKey(sess.AccountUUID), sess.Token); err != nil {
+		return err
+	}
+	return s.expireAccount(sess)
+}
+
+func (s *redisSessionStore) Update(sess *Session) error {
+	stored, err := s.Get(sess.Token)
+	if err != nil {
+		return err
+	}
+	stored.Expires = sess.Expires
+	stored.DeviceName = sess.DeviceName
+	stored.UpdatedAt = util.Now()
+
+	err = s.set(stored, "XX")
+	if err != nil {
+		return err
+	}
+	*sess = *stored
+	return s.expireAccount(sess)
+}
+
+// expireAccount lets the set of sessions of an account expire together with the session,
+// which was created or updated last and therefore expires last.
+func (s *redisSessionStore) expireAccount(sess *Session) error {
+	_, err := s.client.Do("PEXPIREAT", s.accountKey(sess.AccountUUID), strconv.FormatInt(sess.Expires.UnixNano()/int64(time.Millisecond), 10))
+	return err
+}
+
+// set stores a session with the expiration time of the session. The condition NX only stores
+// new sessions, XX only existing ones.
+func (s *redisSessionStore) set(sess *Session, condition string) error {
+	ttl := sess.Expires.Sub(expiryTime()) / time.Millisecond
+	if ttl < 1 {
+		ttl = 1
+	}
+	value, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+
+	reply, err := s.client.Do("SET", s.key(sess.Token), string(value), "PX", strconv.FormatInt(int64(ttl), 10), condition)
+	if err != nil {
+		return err
+	}
+	if reply == nil {
+		if condition == "NX" {
+			return conflictError("Session already exists")
+		}
+		return notFoundError("Session does not exist")
+	}
+	return nil
+}
+
+func (s *redisSessionStore) Delete(sess *Session) error {
+	if _, err := s.client.Do("DEL", s.key(sess.Token)); err != nil {
+		return err
+	}
+	if _, err := s.client.Do("SREM", s.allKey(), sess.Token); err != nil {
+		return err
+	}
+	_, err := s.client.Do("SREM", s.accountKey(sess.AccountUUID), sess.Token)
+	return err
+}
+
+func (s *redisSessionStore) DeleteAccount(accountUUID string) error {
+	tokens, err := s.client.Strings("SMEMBERS", s.accountKey(accountUUID))
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if _, err = s.client.Do("DEL", s.key(token)); err != nil {
+			return err
+		}
+		if _, err = s.client.Do("SREM", s.allKey(), token); err != nil {
+			return err
+		}
+	}
+	_, err = s.client.Do("DEL", s.accountKey(accountUUID))
+	return err
+}
+
+// DeleteExpired removes tokens of expired sessions from the set of all sessions, the sessions
+// themselves and the sets of accounts are expired by redis.
+func (s *redisSessionStore) DeleteExpired() error {
+	_, err := s.List()
+	return err
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestMemorySessionStore(t *testing.T) {
+	store := NewMemorySessionStore()
+
+	first := &Session{Token: "FIRST", AccountUUID: uuidAlice, Expires: time.Now().Add(time.Hour)}
+	expired := &Session{Token: "EXPIRED", AccountUUID: uuidAlice, Expires: time.Now().Add(-time.Hour)}
+	other := &Session{Token: "OTHER", AccountUUID: "other", Expires: time.Now().Add(time.Hour)}
+	for _, sess := range []*Session{first, expired, other} {
+		if err := store.Create(sess); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Create(first); KindOf(err) != ErrConflict {
+		t.Error("Creating a session twice should fail with a conflict")
+	}
+
+	if _, err := store.Get("EXPIRED"); KindOf(err) != ErrNotFound {
+		t.Error("Expired session should not be found")
+	}
+	sessions, _ := store.ListAccount(uuidAlice)
+	if len(sessions) != 1 || sessions[0].Token != "FIRST" {
+		t.Errorf("Only the first session of alice expected but was %v", sessions)
+	}
+
+	first.DeviceName = "Laptop"
+	if err := store.Update(first); err != nil {
+		t.Fatal(err)
+	}
+	if sess, err := store.Get("FIRST"); err != nil || sess.DeviceName != "Laptop" {
+		t.Error("Updated device name expected")
+	}
+
+	store.DeleteAccount(uuidAlice)
+	if _, err := store.Get("FIRST"); KindOf(err) != ErrNotFound {
+		t.Error("Sessions of alice should be removed")
+	}
+	store.Delete(other)
+	if sessions, _ := store.List(); len(sessions) != 0 {
+		t.Errorf("No sessions expected but was %v", sessions)
+	}
+}
+
+func TestMemorySessionStoreExpiry(t *testing.T) {
+	now := time.Now()
+	defer util.SetClock(util.SetClock(util.FixedClock(now)))
+
+	store := NewMemorySessionStore()
+	sess := &Session{Token: "SESSION", AccountUUID: uuidAlice, Expires: now.Add(time.Hour)}
+	if err := store.Create(sess); err != nil {
+		t.Fatal(err)
+	}
+
+	util.SetClock(util.FixedClock(now.Add(time.Hour + 2*time.Second)))
+	if _, err := store.Get("SESSION"); err != nil {
+		t.Error("Session expected to be valid within the leeway")
+	}
+
+	util.SetClock(util.FixedClock(now.Add(time.Hour + time.Minute)))
+	if _, err := store.Get("SESSION"); KindOf(err) != ErrNotFound {
+		t.Error("Session expected to be expired")
+	}
+	store.DeleteExpired()
+	util.SetClock(util.FixedClock(now))
+	if _, err := store.Get("SESSION"); KindOf(err) != ErrNotFound {
+		t.Error("Expired session expected to be removed")
+	}
+}
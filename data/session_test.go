@@ -9,6 +9,7 @@
 package data
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -74,6 +75,60 @@ func TestCreateSession(t *testing.T) {
 	if check.AccountUUID != uuidAlice {
 		t.Errorf("AccountUUID is supposed to be '%s'", uuidAlice)
 	}
+
+	// user agents are truncated without splitting characters and sanitized
+	long := Session{AccountUUID: uuidAlice, UserAgent: strings.Repeat("a", maxUserAgentLength-1) + "ä\xff"}
+	err = long.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	check, _ = GetSession(long.Token)
+	if check.UserAgent != strings.Repeat("a", maxUserAgentLength-1) {
+		t.Errorf("Truncated user agent expected but was %q", check.UserAgent)
+	}
+}
+
+func TestLimitAccountSessions(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	for i := 0; i < 2; i++ {
+		fresh := &Session{AccountUUID: uuidAlice}
+		err := fresh.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evicted, err := LimitAccountSessions(uuidAlice, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 0 || len(ListAccountSessions(uuidAlice)) != 3 {
+		t.Error("No session expected to be evicted without a limit")
+	}
+
+	evicted, err = LimitAccountSessions(uuidAlice, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 1 || evicted[0].Token != sessionTokenAlice {
+		t.Fatalf("Only the oldest session expected to be evicted: %+v", evicted)
+	}
+	if _, ok := GetSession(sessionTokenAlice); ok {
+		t.Error("Evicted session expected to be deleted")
+	}
+	if len(ListAccountSessions(uuidAlice)) != 2 {
+		t.Error("Two sessions of alice expected to remain")
+	}
+
+	evicted, err = LimitAccountSessions(uuidAlice, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evicted) != 0 {
+		t.Error("No session expected to be evicted within the limit")
+	}
 }
 
 func TestSessionUpdateExpirationTime(t *testing.T) {
@@ -120,3 +175,42 @@ func TestSessionDelete(t *testing.T) {
 		t.Error("Session should not exist")
 	}
 }
+
+func TestSessionDevice(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	sessions := ListAccountSessions(uuidAlice)
+	if len(sessions) != 1 || sessions[0].Token != sessionTokenAlice {
+		t.Fatal("Exactly one session of alice expected")
+	}
+	sess := &sessions[0]
+	if sess.Device() != "Firefox on Linux" {
+		t.Errorf("Device expected to be 'Firefox on Linux' but was '%s'", sess.Device())
+	}
+	if sess.ID() == "" || sess.ID() == sess.Token {
+		t.Error("Session id expected to differ from the token")
+	}
+
+	err := sess.Rename("  Office laptop ")
+	if err != nil {
+		t.Error(err)
+	}
+	check, _ := GetSession(sessionTokenAlice)
+	if check.Device() != "Office laptop" {
+		t.Errorf("Device expected to be 'Office laptop' but was '%s'", check.Device())
+	}
+
+	err = sess.Rename(strings.Repeat("x", 65))
+	if err == nil {
+		t.Error("Too long device name should fail")
+	}
+
+	err = sess.Rename("")
+	if err != nil {
+		t.Error(err)
+	}
+	if sess.Device() != "Firefox on Linux" {
+		t.Error("Empty name expected to restore the recognized device")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"golang.org/x/crypto/ssh"
+)
+
+// ErrSSHCANotConfigured is returned if ssh certificates are requested but no CA key is configured.
+var ErrSSHCANotConfigured = errors.New("Issuing ssh certificates is not enabled")
+
+// Tolerated clock difference between gin-auth and ssh servers
+const sshCertClockSkew = time.Minute
+
+// The CA signer is loaded when used the first time and reloaded if the key file changes.
+var sshCA = struct {
+	sync.Mutex
+	keyFile string
+	signer  ssh.Signer
+}{}
+
+// GetSSHCASigner returns the signer of the configured ssh certificate authority.
+func GetSSHCASigner() (ssh.Signer, error) {
+	keyFile := conf.GetSSHCertificateAuthority().KeyFile
+	if keyFile == "" {
+		return nil, ErrSSHCANotConfigured
+	}
+
+	sshCA.Lock()
+	defer sshCA.Unlock()
+
+	if sshCA.signer == nil || sshCA.keyFile != keyFile {
+		pem, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			return nil, err
+		}
+		sshCA.keyFile = keyFile
+		sshCA.signer = signer
+	}
+
+	return sshCA.signer, nil
+}
+
+// IssueSSHCertificate signs the given public key (authorized_keys format) with the
+// ssh certificate authority. The certificate is valid for the login of the account
+// as principal and expires after the configured validity.
+func (acc *Account) IssueSSHCertificate(authorizedKey string) (*ssh.Certificate, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(authorizedKey))
+	if err != nil {
+		return nil, &util.ValidationError{
+			Message:     "Invalid ssh key",
+			FieldErrors: map[string]string{"key": "Please provide a public key in authorized_keys format"}}
+	}
+	if _, ok := key.(*ssh.Certificate); ok {
+		return nil, &util.ValidationError{
+			Message:     "Invalid ssh key",
+			FieldErrors: map[string]string{"key": "Certificates can not be signed"}}
+	}
+
+	signer, err := GetSSHCASigner()
+	if err != nil {
+		return nil, err
+	}
+
+	return newSSHCertificate(signer, acc.Login, key, conf.GetSSHCertificateAuthority(), util.Now())
+}
+
+// newSSHCertificate creates a user certificate for the key with the login as principal.
+func newSSHCertificate(signer ssh.Signer, login string, key ssh.PublicKey, ca *conf.SSHCertificateAuthority, now time.Time) (*ssh.Certificate, error) {
+	serial := make([]byte, 8)
+	_, err := rand.Read(serial)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &ssh.Certificate{
+		Key:             key,
+		Serial:          binary.BigEndian.Uint64(serial),
+		CertType:        ssh.UserCert,
+		ValidPrincipals: []string{login},
+		ValidAfter:      uint64(now.Add(-sshCertClockSkew).Unix()),
+		ValidBefore:     uint64(now.Add(ca.Validity).Unix()),
+		Permissions:     ssh.Permissions{Extensions: make(map[string]string)},
+	}
+	cert.KeyId = fmt.Sprintf("gin-auth:%s:%d", login, cert.Serial)
+	for _, ext := range ca.Extensions {
+		cert.Permissions.Extensions[ext] = ""
+	}
+
+	err = cert.SignCert(rand.Reader, signer)
+	if err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
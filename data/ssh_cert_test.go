@@ -0,0 +1,81 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestNewSSHCertificate(t *testing.T) {
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	signer, err := ssh.NewSignerFromKey(caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	userPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key, _ := ssh.NewPublicKey(userPub)
+
+	ca := &conf.SSHCertificateAuthority{Validity: time.Hour, Extensions: []string{"permit-pty"}}
+	now := time.Now()
+	cert, err := newSSHCertificate(signer, "alice", key, ca, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "alice" {
+		t.Errorf("Unexpected principals: %v", cert.ValidPrincipals)
+	}
+	if _, ok := cert.Permissions.Extensions["permit-pty"]; !ok {
+		t.Error("Extension 'permit-pty' expected")
+	}
+
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(signer.PublicKey().Marshal())
+		},
+		Clock: func() time.Time { return now },
+	}
+	err = checker.CheckCert("alice", cert)
+	if err != nil {
+		t.Errorf("Certificate expected to be valid: %s", err)
+	}
+	err = checker.CheckCert("bob", cert)
+	if err == nil {
+		t.Error("Certificate should not be valid for other principals")
+	}
+
+	checker.Clock = func() time.Time { return now.Add(2 * time.Hour) }
+	err = checker.CheckCert("alice", cert)
+	if err == nil {
+		t.Error("Certificate should be expired")
+	}
+}
+
+func TestAccount_IssueSSHCertificate(t *testing.T) {
+	conf.GetSSHCertificateAuthority().KeyFile = ""
+
+	acc := &Account{Login: "alice"}
+	_, err := acc.IssueSSHCertificate("invalid key")
+	if err == nil {
+		t.Error("Invalid key should fail")
+	}
+
+	userPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	key, _ := ssh.NewPublicKey(userPub)
+	_, err = acc.IssueSSHCertificate(string(ssh.MarshalAuthorizedKey(key)))
+	if err != ErrSSHCANotConfigured {
+		t.Errorf("Expected error '%v' but was '%v'", ErrSSHCANotConfigured, err)
+	}
+}
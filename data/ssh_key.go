@@ -53,7 +53,7 @@ func GetSSHKey(fingerprint string) (*SSHKey, bool) {
 	const q = `SELECT * FROM SSHKeys k WHERE k.fingerprint=$1 AND (NOT temporary OR createdat > $2)`
 
 	key := &SSHKey{}
-	err := database.Get(key, q, fingerprint, time.Now().Add(-1*conf.GetServerConfig().TmpSshKeyLifeTime))
+	err := database.Get(key, q, fingerprint, expiryTime().Add(-1*conf.GetServerConfig().TmpSshKeyLifeTime))
 	if err != nil && err != sql.ErrNoRows {
 		panic(err)
 	}
@@ -88,12 +88,12 @@ type SSHKeyMarshaler struct {
 // MarshalJSON implements Marshaler for SSHKeyMarshaler
 func (keyMarshaler *SSHKeyMarshaler) MarshalJSON() ([]byte, error) {
 	jsonData := gin.SSHKey{
-		URL:         conf.MakeUrl("/api/keys?fingerprint=%s", keyMarshaler.SSHKey.Fingerprint),
+		URL:         conf.MakeUrl("/api/v1/keys?fingerprint=%s", keyMarshaler.SSHKey.Fingerprint),
 		Fingerprint: keyMarshaler.SSHKey.Fingerprint,
 		Key:         keyMarshaler.SSHKey.Key,
 		Description: keyMarshaler.SSHKey.Description,
 		Login:       keyMarshaler.Account.Login,
-		AccountURL:  conf.MakeUrl("/api/accounts/%s", keyMarshaler.Account.Login),
+		AccountURL:  conf.MakeUrl("/api/v1/accounts/%s", keyMarshaler.Account.Login),
 		CreatedAt:   keyMarshaler.SSHKey.CreatedAt,
 		UpdatedAt:   keyMarshaler.SSHKey.UpdatedAt,
 	}
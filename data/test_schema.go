@@ -0,0 +1,137 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/lib/pq"
+)
+
+// Characters which are replaced in the names of test schemas
+var testSchemaCharRegex = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// The schema of the running test binary, it is created once by InitTestDb
+var testSchema = struct {
+	sync.Once
+	err error
+}{}
+
+// testSchemaName returns the name of the schema the running test binary works on. It is
+// derived from the name of the binary, e.g. "test_web" for the tests of the web package.
+func testSchemaName() string {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(os.Args[0]), ".test"))
+	return "test_" + testSchemaCharRegex.ReplaceAllString(name, "_")
+}
+
+// migration is the 'up' section of a migration file.
+type migration struct {
+	version int64
+	up      string
+}
+
+// migrations reads the 'up' sections of all migration files ordered by version.
+func migrations() ([]migration, error) {
+	dir := conf.GetResourceFile("conf", "migrations")
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]migration, 0, len(files))
+	for _, f := range files {
+		match := migrationFileRegex.FindStringSubmatch(f.Name())
+		if match == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		up := string(content)
+		if i := strings.Index(up, "-- +goose Up"); i >= 0 {
+			up = up[i:]
+		}
+		if i := strings.Index(up, "-- +goose Down"); i >= 0 {
+			up = up[:i]
+		}
+		result = append(result, migration{version: version, up: up})
+	}
+	sort.Sort(migrationsByVersion(result))
+
+	return result, nil
+}
+
+type migrationsByVersion []migration
+
+func (m migrationsByVersion) Len() int           { return len(m) }
+func (m migrationsByVersion) Swap(i, j int)      { m[i], m[j] = m[j], m[i] }
+func (m migrationsByVersion) Less(i, j int) bool { return m[i].version < m[j].version }
+
+// initTestSchema replaces the schema with an empty one, applies all migrations to it and
+// connects to the database with the schema in front of the search path. Each test package
+// thereby works on its own tables and the packages can be tested in parallel.
+func initTestSchema(config *conf.DbConfig, schema string) error {
+	const qVersions = `CREATE TABLE goose_db_version (
+	                     id SERIAL PRIMARY KEY ,
+	                     version_id BIGINT NOT NULL ,
+	                     is_applied BOOLEAN NOT NULL ,
+	                     tstamp TIMESTAMP NULL DEFAULT now()
+	                   )`
+	const qVersion = `INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, TRUE)`
+
+	all, err := migrations()
+	if err != nil {
+		return err
+	}
+
+	InitDb(config)
+	quoted := pq.QuoteIdentifier(schema)
+
+	tx := database.MustBegin()
+	for _, q := range []string{`DROP SCHEMA IF EXISTS ` + quoted + ` CASCADE`, `CREATE SCHEMA ` + quoted,
+		`SET LOCAL search_path TO ` + quoted + `, public`, qVersions} {
+		_, err = tx.Exec(q)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	for _, m := range all {
+		_, err = tx.Exec(m.up)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		_, err = tx.Exec(qVersion, m.version)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	InitDb(&conf.DbConfig{Driver: config.Driver, Open: config.Open + " search_path=" + schema + ",public"})
+	return nil
+}
@@ -0,0 +1,187 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// Maximum number of tokens deleted in a single transaction by RevokeTokens
+const tokenRevocationBatchSize = 500
+
+// Maximum number of token IDs listed by a dry run of RevokeTokens
+const tokenRevocationSampleSize = 10
+
+// TokenRevocation contains the number of tokens removed by RevokeTokens. For a dry run it
+// contains the number of tokens which would be removed and the IDs of some of them.
+type TokenRevocation struct {
+	AccessTokens  int64    `json:"access_tokens"`
+	RefreshTokens int64    `json:"refresh_tokens"`
+	DryRun        bool     `json:"dry_run,omitempty"`
+	SampleIDs     []string `json:"sample_ids,omitempty"`
+}
+
+// RevokeTokens removes all access and refresh tokens issued to the client with the given
+// uuid and containing the given scope. Empty values match all clients or scopes respectively,
+// but at least one of them must be given. Tokens are removed in batches, each in its own
+// transaction, thus the revocation does not lock large parts of the token tables at once.
+// A dry run removes all matching tokens in a single transaction, which is rolled back; the
+// result contains the IDs (see AccountToken) of some of the tokens.
+func RevokeTokens(clientUUID, scope string, dryRun bool) (*TokenRevocation, error) {
+	if clientUUID == "" && scope == "" {
+		return nil, errors.New("Client or scope required for token revocation")
+	}
+	if dryRun {
+		return revokeTokensDryRun(clientUUID, scope)
+	}
+
+	revocation := &TokenRevocation{}
+	var err error
+	revocation.AccessTokens, err = revokeTokenBatches("AccessTokens", clientUUID, scope)
+	if err != nil {
+		return revocation, err
+	}
+	revocation.RefreshTokens, err = revokeTokenBatches("RefreshTokens", clientUUID, scope)
+	return revocation, err
+}
+
+// revokeTokensDryRun removes the matching tokens in a transaction and rolls it back.
+func revokeTokensDryRun(clientUUID, scope string) (*TokenRevocation, error) {
+	revocation := &TokenRevocation{DryRun: true, SampleIDs: make([]string, 0)}
+	tables := []struct {
+		name  string
+		kind  string
+		count *int64
+	}{
+		{"AccessTokens", AccountTokenAccess, &revocation.AccessTokens},
+		{"RefreshTokens", AccountTokenRefresh, &revocation.RefreshTokens},
+	}
+
+	tx := database.MustBegin()
+	defer tx.Rollback()
+	for _, table := range tables {
+		tokens, err := revokeTokenBatch(tx, table.name, clientUUID, scope, nil)
+		if err != nil {
+			return nil, err
+		}
+		*table.count = int64(len(tokens))
+		for _, token := range tokens {
+			if len(revocation.SampleIDs) == tokenRevocationSampleSize {
+				break
+			}
+			tok := &AccountToken{Token: token, Kind: table.kind}
+			revocation.SampleIDs = append(revocation.SampleIDs, tok.ID())
+		}
+	}
+	return revocation, nil
+}
+
+// revokeTokenBatches removes matching tokens from a token table until no more tokens match.
+func revokeTokenBatches(table, clientUUID, scope string) (int64, error) {
+	var total int64
+	for {
+		tx := database.MustBegin()
+		tokens, err := revokeTokenBatch(tx, table, clientUUID, scope, tokenRevocationBatchSize)
+		if err != nil {
+			tx.Rollback()
+			return total, err
+		}
+		err = tx.Commit()
+		if err != nil {
+			return total, err
+		}
+
+		total += int64(len(tokens))
+		if len(tokens) < tokenRevocationBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// revokeTokenBatch removes at most limit matching tokens from a token table, or all matching
+// tokens if limit is nil, and returns the removed tokens.
+func revokeTokenBatch(tx *sqlx.Tx, table, clientUUID, scope string, limit interface{}) ([]string, error) {
+	q := fmt.Sprintf(`DELETE FROM %[1]s WHERE token IN (
+	                    SELECT token FROM %[1]s
+	                    WHERE ($1 = '' OR clientUUID = $1) AND ($2 = '' OR $2 = ANY(scope))
+	                    LIMIT $3)
+	                  RETURNING token`, table)
+
+	tokens := make([]string, 0)
+	err := tx.Select(&tokens, q, clientUUID, scope, limit)
+	return tokens, err
+}
+
+// RevokedToken is an access token which was deleted before it expired. Revoked tokens are
+// recorded by a database trigger, which only stores the hex encoded SHA-256 hash of the token,
+// and kept until the token would have expired, such that resource servers can drop cached
+// validations of the token. TxID is the id of the transaction which revoked the token.
+type RevokedToken struct {
+	ID         int64
+	TokenHash  string
+	ClientUUID string
+	TxID       int64
+	Expires    time.Time
+	CreatedAt  time.Time
+}
+
+// ListRevokedTokens returns the tokens revoked by transactions with an id of at least cursor,
+// which have finished, ordered by transaction. The returned cursor is passed with the next call.
+// Since revocations of transactions which are still running are held back until they finish,
+// revocations committed out of order are never skipped. Revocations of a single transaction are
+// returned together, thus more than limit tokens may be returned. If more is true further
+// revocations can be fetched immediately.
+func ListRevokedTokens(cursor int64, limit int) (revoked []RevokedToken, next int64, more bool) {
+	const q = `SELECT * FROM TokenRevocations WHERE txid >= $1 AND txid < $2 AND expires > now()
+	           ORDER BY txid, id LIMIT $3`
+	const qTx = `SELECT * FROM TokenRevocations WHERE txid = $1 AND expires > now() ORDER BY id`
+
+	finished := LatestTokenRevocation()
+	revoked = make([]RevokedToken, 0)
+	err := database.Select(&revoked, q, cursor, finished, limit+1)
+	if err != nil {
+		panic(err)
+	}
+	if len(revoked) <= limit {
+		return revoked, finished, false
+	}
+
+	// the page ends within the revocations of a transaction, which are left for the next call
+	next = revoked[limit].TxID
+	for len(revoked) > 0 && revoked[len(revoked)-1].TxID == next {
+		revoked = revoked[:len(revoked)-1]
+	}
+	if len(revoked) == 0 {
+		err = database.Select(&revoked, qTx, next)
+		if err != nil {
+			panic(err)
+		}
+		next++
+	}
+
+	return revoked, next, true
+}
+
+// LatestTokenRevocation returns the cursor for resource servers starting to follow the revocations,
+// which is the id of the oldest transaction still running. All older transactions have finished.
+func LatestTokenRevocation() int64 {
+	const q = `SELECT txid_snapshot_xmin(txid_current_snapshot())`
+
+	var cursor int64
+	err := database.Get(&cursor, q)
+	if err != nil {
+		panic(err)
+	}
+
+	return cursor
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"testing"
+
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestRevokeTokens(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	_, err := RevokeTokens("", "", false)
+	if err == nil {
+		t.Error("Revocation without client and scope should fail")
+	}
+
+	revocation, err := RevokeTokens("", "account-admin", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revocation.AccessTokens != 1 || revocation.RefreshTokens != 0 {
+		t.Errorf("One access token expected to be revoked but was %d", revocation.AccessTokens)
+	}
+	if _, ok := GetAccessToken("KDEW57D4"); ok {
+		t.Error("Access token with scope 'account-admin' should be revoked")
+	}
+	if _, ok := GetAccessToken("3N7MP7M7"); !ok {
+		t.Error("Access token without scope 'account-admin' should not be revoked")
+	}
+
+	revocation, err = RevokeTokens("8b14d6bb-cae7-4163-bbd1-f3be46e43e31", "repo-read", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revocation.AccessTokens != 2 || revocation.RefreshTokens != 2 {
+		t.Errorf("Two access and refresh tokens expected to be revoked but was %d and %d",
+			revocation.AccessTokens, revocation.RefreshTokens)
+	}
+	if _, ok := GetAccessToken("B7NDW8TX"); !ok {
+		t.Error("Access token without scope 'repo-read' should not be revoked")
+	}
+
+	revocation, err = RevokeTokens("8b14d6bb-cae7-4163-bbd1-f3be46e43e31", "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ListAccessTokens()) != 0 || len(ListRefreshTokens()) != 0 {
+		t.Error("All tokens of the client should be revoked")
+	}
+}
+
+func TestRevokeTokensDryRun(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	_, err := RevokeTokens("", "", true)
+	if err == nil {
+		t.Error("Dry run without client and scope should fail")
+	}
+
+	numAccess, numRefresh := len(ListAccessTokens()), len(ListRefreshTokens())
+	cursor := LatestTokenRevocation()
+	revocation, err := RevokeTokens("8b14d6bb-cae7-4163-bbd1-f3be46e43e31", "repo-read", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !revocation.DryRun || revocation.AccessTokens != 2 || revocation.RefreshTokens != 2 {
+		t.Errorf("Two access and refresh tokens expected to match but was %d and %d",
+			revocation.AccessTokens, revocation.RefreshTokens)
+	}
+	if len(revocation.SampleIDs) != 4 {
+		t.Errorf("Four sample IDs expected but was %d", len(revocation.SampleIDs))
+	}
+	if len(ListAccessTokens()) != numAccess || len(ListRefreshTokens()) != numRefresh {
+		t.Error("No tokens should be revoked by the dry run")
+	}
+	if revoked, _, _ := ListRevokedTokens(cursor, 10); len(revoked) != 0 {
+		t.Error("No revocations should be recorded by the dry run")
+	}
+}
+
+func TestListRevokedTokens(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	cursor := LatestTokenRevocation()
+	if revoked, _, _ := ListRevokedTokens(cursor, 10); len(revoked) != 0 {
+		t.Fatal("No revoked tokens expected")
+	}
+
+	token, _ := GetAccessToken("3N7MP7M7")
+	err := token.Delete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, next, more := ListRevokedTokens(cursor, 10)
+	if len(revoked) != 1 || more {
+		t.Fatalf("Only the token '3N7MP7M7' expected to be recorded but was %v", revoked)
+	}
+	if revoked[0].TokenHash != "912fdeb08647f5743ff0ebb4aa4954908abee5fdf387717d72eb486b09603d79" {
+		t.Errorf("Unexpected token hash '%s'", revoked[0].TokenHash)
+	}
+	if next <= revoked[0].TxID {
+		t.Errorf("Cursor expected to be after transaction %d but was %d", revoked[0].TxID, next)
+	}
+
+	// the valid tokens 'KDEW57D4' and 'B7NDW8TX' are revoked in one transaction
+	_, err = RevokeTokens(uuidClientGin, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if revoked, _, _ = ListRevokedTokens(next, 10); len(revoked) != 2 {
+		t.Errorf("Two tokens expected after the cursor but was %v", revoked)
+	}
+
+	// pages end between transactions and revocations of one transaction are not split
+	revoked, next, more = ListRevokedTokens(cursor, 1)
+	if len(revoked) != 1 || revoked[0].TokenHash != "912fdeb08647f5743ff0ebb4aa4954908abee5fdf387717d72eb486b09603d79" || !more {
+		t.Errorf("Only the first revocation expected but was %v", revoked)
+	}
+	revoked, next, more = ListRevokedTokens(next, 1)
+	if len(revoked) != 2 || revoked[0].TxID != revoked[1].TxID || !more {
+		t.Errorf("Both revocations of the transaction expected but was %v", revoked)
+	}
+	if revoked, _, more = ListRevokedTokens(next, 1); len(revoked) != 0 || more {
+		t.Errorf("No further revocations expected but was %v", revoked)
+	}
+}
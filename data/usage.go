@@ -0,0 +1,219 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"sync"
+	"time"
+)
+
+// UsageCounter contains the number of API requests and token validations of
+// an account via one client on a single day.
+type UsageCounter struct {
+	Day              time.Time
+	AccountUUID      string
+	ClientUUID       string
+	ClientName       string
+	APIRequests      int64
+	TokenValidations int64
+}
+
+// UsageTotal contains the summed up usage counters of an account.
+type UsageTotal struct {
+	Login            string
+	APIRequests      int64
+	TokenValidations int64
+}
+
+type usageKey struct {
+	day         string
+	accountUUID string
+	clientUUID  string
+}
+
+type usageCount struct {
+	apiRequests      int64
+	tokenValidations int64
+}
+
+// Usage is counted in memory and periodically written to the database by FlushUsage.
+// Together with the counts the time of the last use of each access token is kept.
+var usage = struct {
+	sync.Mutex
+	counts   map[usageKey]*usageCount
+	lastUsed map[string]time.Time
+}{counts: make(map[usageKey]*usageCount), lastUsed: make(map[string]time.Time)}
+
+// RecordAPIRequest counts an API request authorized by the given access token.
+// Requests with tokens not associated with an account are not counted.
+func RecordAPIRequest(token *AccessToken) {
+	recordUsage(token, usageCount{apiRequests: 1})
+}
+
+// RecordTokenValidation counts a validation of the given access token for the client and
+// each scope of the token. For the account the validation is only counted if the token is
+// associated with an account.
+func RecordTokenValidation(token *AccessToken) {
+	recordClientUsage(token.ClientUUID, clientUsageCount{tokenValidations: 1})
+	recordScopeUsage(token)
+	recordUsage(token, usageCount{tokenValidations: 1})
+}
+
+func recordUsage(token *AccessToken, add usageCount) {
+	if !token.AccountUUID.Valid {
+		return
+	}
+	now := time.Now()
+	key := usageKey{now.Format("2006-01-02"), token.AccountUUID.String, token.ClientUUID}
+
+	usage.Lock()
+	defer usage.Unlock()
+
+	usage.lastUsed[token.Token] = now
+
+	count, ok := usage.counts[key]
+	if !ok {
+		count = &usageCount{}
+		usage.counts[key] = count
+	}
+	count.apiRequests += add.apiRequests
+	count.tokenValidations += add.tokenValidations
+}
+
+// FlushUsage adds all usage of accounts, clients and scopes counted since the last flush to the
+// daily counters in the database. If writing fails, the counts are kept for the next flush.
+func FlushUsage() error {
+	err := flushClientUsage()
+	if err != nil {
+		return err
+	}
+	err = flushScopeUsage()
+	if err != nil {
+		return err
+	}
+
+	usage.Lock()
+	counts := usage.counts
+	usage.counts = make(map[usageKey]*usageCount)
+	lastUsed := usage.lastUsed
+	usage.lastUsed = make(map[string]time.Time)
+	usage.Unlock()
+
+	err = flushTokenLastUse(lastUsed)
+	if err != nil {
+		restoreUsage(counts)
+		return err
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+
+	const q = `INSERT INTO UsageCounters (day, accountUUID, clientUUID, apiRequests, tokenValidations)
+	           SELECT $1::date, $2::varchar, $3::varchar, $4::bigint, $5::bigint
+	           WHERE EXISTS (SELECT 1 FROM Accounts WHERE uuid = $2) AND EXISTS (SELECT 1 FROM Clients WHERE uuid = $3)
+	           ON CONFLICT (day, accountUUID, clientUUID) DO UPDATE
+	           SET apiRequests = UsageCounters.apiRequests + EXCLUDED.apiRequests,
+	               tokenValidations = UsageCounters.tokenValidations + EXCLUDED.tokenValidations`
+
+	tx, err := database.Beginx()
+	if err != nil {
+		restoreUsage(counts)
+		return err
+	}
+	for key, count := range counts {
+		_, err := tx.Exec(q, key.day, key.accountUUID, key.clientUUID, count.apiRequests, count.tokenValidations)
+		if err != nil {
+			tx.Rollback()
+			restoreUsage(counts)
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		restoreUsage(counts)
+	}
+	return err
+}
+
+// flushTokenLastUse stores the time of the last use of access tokens. Tokens which were
+// removed in the meantime are ignored. Other than counts, times are not kept if writing fails.
+func flushTokenLastUse(lastUsed map[string]time.Time) error {
+	if len(lastUsed) == 0 {
+		return nil
+	}
+
+	const q = `UPDATE AccessTokens SET lastUsedAt = $2
+	           WHERE token = $1 AND (lastUsedAt IS NULL OR lastUsedAt < $2)`
+
+	tx, err := database.Beginx()
+	if err != nil {
+		return err
+	}
+	for token, at := range lastUsed {
+		_, err := tx.Exec(q, token, at)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// restoreUsage adds counts which could not be written back to the in memory counters.
+func restoreUsage(counts map[usageKey]*usageCount) {
+	usage.Lock()
+	defer usage.Unlock()
+
+	for key, add := range counts {
+		count, ok := usage.counts[key]
+		if !ok {
+			usage.counts[key] = add
+			continue
+		}
+		count.apiRequests += add.apiRequests
+		count.tokenValidations += add.tokenValidations
+	}
+}
+
+// ListAccountUsage returns the daily usage counters of an account since the given day,
+// ordered by day (latest first) and client name.
+func ListAccountUsage(accountUUID string, since time.Time) []UsageCounter {
+	const q = `SELECT u.*, c.name AS clientName
+	           FROM UsageCounters u JOIN Clients c ON c.uuid = u.clientUUID
+	           WHERE u.accountUUID = $1 AND u.day >= $2
+	           ORDER BY u.day DESC, c.name`
+
+	counters := make([]UsageCounter, 0)
+	err := database.Select(&counters, q, accountUUID, since.Format("2006-01-02"))
+	if err != nil {
+		panic(err)
+	}
+
+	return counters
+}
+
+// ListUsageTotals returns the summed up usage of all accounts since the given day,
+// ordered by the number of API requests (highest first). At most limit entries are returned.
+func ListUsageTotals(since time.Time, limit int) []UsageTotal {
+	const q = `SELECT a.login, SUM(u.apiRequests) AS apiRequests, SUM(u.tokenValidations) AS tokenValidations
+	           FROM UsageCounters u JOIN Accounts a ON a.uuid = u.accountUUID
+	           WHERE u.day >= $1
+	           GROUP BY a.login
+	           ORDER BY apiRequests DESC, a.login
+	           LIMIT $2`
+
+	totals := make([]UsageTotal, 0)
+	err := database.Select(&totals, q, since.Format("2006-01-02"), limit)
+	if err != nil {
+		panic(err)
+	}
+
+	return totals
+}
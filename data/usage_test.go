@@ -0,0 +1,164 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package data
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+	"github.com/jmoiron/sqlx"
+)
+
+const (
+	usageAliceUUID = "bf431618-f696-4dca-a95d-882618ce4ef9"
+	usageGinUUID   = "8b14d6bb-cae7-4163-bbd1-f3be46e43e31"
+)
+
+func TestListAccountUsage(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	counters := ListAccountUsage(usageAliceUUID, time.Now().AddDate(0, 0, -29))
+	if len(counters) != 3 {
+		t.Fatalf("Three usage counters expected but was %d", len(counters))
+	}
+	if counters[0].ClientName != "gin" || counters[0].APIRequests != 120 || counters[0].TokenValidations != 30 {
+		t.Errorf("Unexpected first counter: %v", counters[0])
+	}
+	if !counters[2].Day.Before(counters[0].Day) {
+		t.Error("Counters expected to be ordered by day")
+	}
+
+	counters = ListAccountUsage(usageAliceUUID, time.Now().AddDate(0, 0, -89))
+	if len(counters) != 4 {
+		t.Errorf("Four usage counters expected but was %d", len(counters))
+	}
+}
+
+func TestListUsageTotals(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	totals := ListUsageTotals(time.Now().AddDate(0, 0, -29), 10)
+	if len(totals) != 2 {
+		t.Fatalf("Two accounts expected but was %d", len(totals))
+	}
+	if totals[0].Login != "alice" || totals[0].APIRequests != 205 || totals[0].TokenValidations != 50 {
+		t.Errorf("Unexpected totals of alice: %v", totals[0])
+	}
+
+	totals = ListUsageTotals(time.Now().AddDate(0, 0, -29), 1)
+	if len(totals) != 1 {
+		t.Errorf("One account expected but was %d", len(totals))
+	}
+}
+
+func TestFlushUsage(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	account := sql.NullString{String: usageAliceUUID, Valid: true}
+	RecordAPIRequest(&AccessToken{ClientUUID: usageGinUUID, AccountUUID: account})
+	RecordAPIRequest(&AccessToken{ClientUUID: usageGinUUID, AccountUUID: account})
+	RecordTokenValidation(&AccessToken{ClientUUID: usageGinUUID, AccountUUID: account})
+	RecordAPIRequest(&AccessToken{ClientUUID: usageGinUUID})
+
+	unknown := sql.NullString{String: "doesnotexist", Valid: true}
+	RecordAPIRequest(&AccessToken{ClientUUID: usageGinUUID, AccountUUID: unknown})
+
+	err := FlushUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counters := ListAccountUsage(usageAliceUUID, time.Now())
+	if len(counters) != 2 {
+		t.Fatalf("Two usage counters expected but was %d", len(counters))
+	}
+	if counters[0].APIRequests != 122 || counters[0].TokenValidations != 31 {
+		t.Errorf("Unexpected counter after flush: %v", counters[0])
+	}
+
+	err = FlushUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counters = ListAccountUsage(usageAliceUUID, time.Now())
+	if counters[0].APIRequests != 122 {
+		t.Error("Usage should only be written once")
+	}
+}
+
+func TestFlushUsageDatabaseError(t *testing.T) {
+	defer util.FailOnPanic(t)
+
+	previous := database
+	defer func() { database = previous }()
+	unreachable, err := sqlx.Open("postgres", "host=/doesnotexist sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unreachable.Close()
+	database = unreachable
+
+	account := sql.NullString{String: usageAliceUUID, Valid: true}
+	RecordAPIRequest(&AccessToken{ClientUUID: usageGinUUID, AccountUUID: account})
+	RecordTokenValidation(&AccessToken{ClientUUID: usageGinUUID})
+
+	today := time.Now().Format("2006-01-02")
+
+	// the counts are kept for the next flush
+	err = FlushUsage()
+	if err == nil {
+		t.Fatal("Error expected for an unreachable database")
+	}
+	clientUsage.Lock()
+	if count := clientUsage.counts[clientUsageKey{today, usageGinUUID}]; count == nil || count.tokenValidations != 1 {
+		t.Error("Client usage expected to be kept")
+	}
+	clientUsage.counts = make(map[clientUsageKey]*clientUsageCount)
+	clientUsage.Unlock()
+
+	err = FlushUsage()
+	if err == nil {
+		t.Fatal("Error expected for an unreachable database")
+	}
+	usage.Lock()
+	defer usage.Unlock()
+	if count := usage.counts[usageKey{today, usageAliceUUID, usageGinUUID}]; count == nil || count.apiRequests != 1 {
+		t.Error("Account usage expected to be kept")
+	}
+	usage.counts = make(map[usageKey]*usageCount)
+	usage.lastUsed = make(map[string]time.Time)
+}
+
+func TestFlushTokenLastUse(t *testing.T) {
+	defer util.FailOnPanic(t)
+	InitTestDb(t)
+
+	token, ok := GetAccessToken("3N7MP7M7")
+	if !ok {
+		t.Fatal("Access token does not exist")
+	}
+	if token.LastUsedAt.Valid {
+		t.Error("Access token should not have been used")
+	}
+	RecordAPIRequest(token)
+
+	err := FlushUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ = GetAccessToken("3N7MP7M7")
+	if !token.LastUsedAt.Valid || time.Since(token.LastUsedAt.Time) > time.Minute {
+		t.Errorf("Unexpected time of last use: %v", token.LastUsedAt)
+	}
+}
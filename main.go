@@ -11,6 +11,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"os"
 
 	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/data"
@@ -34,7 +35,7 @@ func versionString() string {
 const doc = `G-Node Infrastructure Authentication Provider
 
 Usage:
-  gin-auth [--res <dir>] [--conf <dir>]
+  gin-auth [--res <dir>] [--conf <dir>] [--reload-templates]
   gin-auth -h | --help
   gin-auth --version
 
@@ -44,8 +45,46 @@ Options:
                   will use GOPATH to find the directory.
   --conf <dir>    Path to the configuration files directory. By default
                   gin-auth will use the resources/conf directory.
+  --reload-templates
+                  Parse templates on each use instead of once at startup.
+                  Only intended for development.
   -h --help       Show this screen.
-  --version       Print gin-auth version`
+  --version       Print gin-auth version
+
+Environment:
+  GIN_AUTH_ADMIN_LOGIN, GIN_AUTH_ADMIN_EMAIL, GIN_AUTH_ADMIN_PASSWORD
+                  If set, an initial account with these credentials is
+                  created on startup when the database contains no accounts.
+                  Use gin-auth-admin bootstrap to enter them interactively.`
+
+// Environment variables providing the credentials of the initial account.
+const (
+	envAdminLogin    = "GIN_AUTH_ADMIN_LOGIN"
+	envAdminEmail    = "GIN_AUTH_ADMIN_EMAIL"
+	envAdminPassword = "GIN_AUTH_ADMIN_PASSWORD"
+)
+
+// bootstrap creates the initial account from environment variables. Nothing happens if the
+// login is not set or the database already contains accounts.
+func bootstrap() error {
+	login := os.Getenv(envAdminLogin)
+	if login == "" || data.HasAccounts() {
+		return nil
+	}
+
+	acc, err := data.BootstrapAccount(login, os.Getenv(envAdminEmail), os.Getenv(envAdminPassword))
+	if err != nil {
+		if valErr, ok := err.(*util.ValidationError); ok {
+			for field, msg := range valErr.FieldErrors {
+				err = fmt.Errorf("%s (%s: %s)", err.Error(), field, msg)
+			}
+		}
+		return err
+	}
+
+	fmt.Printf("Created initial account '%s'\n", acc.Login)
+	return nil
+}
 
 func main() {
 	args, _ := docopt.Parse(doc, nil, true, versionString(), false)
@@ -61,7 +100,36 @@ func main() {
 	logEnv := conf.GetLogEnv()
 	defer logEnv.Close()
 
+	// Export audit events to a SIEM and deliver buffered events before the log files are closed.
+	if export := conf.GetAuditExport(); export.Target != "" {
+		sender, err := util.NewAuditSender(export, fmt.Sprintf("%d.%d", major, minor))
+		if err != nil {
+			panic(err.Error())
+		}
+		exporter := util.NewAuditExporter(sender, export)
+		logEnv.Audit.Hooks.Add(exporter)
+		defer exporter.Close()
+	}
+
 	srvConf := conf.GetServerConfig()
+
+	// random secrets are only acceptable for test instances, since codes sent by e-mail and sessions would
+	// be invalid after a restart, and publicly known secrets would allow forging them
+	if conf.GetAccountCodes().IsSecretGenerated {
+		if !srvConf.TestMode {
+			fmt.Fprintln(os.Stderr, "No secret for account codes configured, set Secret in the codes section of server.yml")
+			os.Exit(1)
+		}
+		logEnv.Err.Warnf("No secret for account codes configured, codes sent by e-mail are invalid after a restart")
+	}
+	if conf.GetCookieKeys().IsKeyGenerated {
+		if !srvConf.TestMode {
+			fmt.Fprintln(os.Stderr, "No cookie keys configured, set Keys in the cookies section of server.yml")
+			os.Exit(1)
+		}
+		logEnv.Err.Warnf("No cookie keys configured, all sessions end when the server is restarted")
+	}
+
 	err := conf.SmtpCheck()
 	if err != nil {
 		panic(err.Error())
@@ -69,32 +137,91 @@ func main() {
 
 	dbConf := conf.GetDbConfig()
 	data.InitDb(dbConf)
-	data.InitClients(conf.GetClientsConfigFile())
+
+	// the database may be a read-only replica, clients are updated once read-only mode is disabled
+	if conf.GetReadOnly().Enabled {
+		logEnv.Err.Warnf("Started in read-only mode, clients and initial account are not updated")
+	} else {
+		err = bootstrap()
+		if err != nil {
+			panic(err.Error())
+		}
+
+		data.InitClients(conf.GetClientsConfigFile())
+	}
+
+	// maintenance mode set by an administrator survives restarts
+	err = data.LoadMaintenance()
+	if err != nil {
+		panic(err.Error())
+	}
 
 	// Initialize externals
 	conf.GetExternals()
 
+	// Parse all templates once in order to detect errors before serving requests
+	err = conf.LoadTemplates()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+	if reload, ok := args["--reload-templates"]; ok && reload.(bool) {
+		conf.SetTemplatesReload(true)
+	}
+
+	var reporter util.ErrorReporter
+	if reporting := conf.GetErrorReporting(); reporting.SentryDSN != "" {
+		reporter, err = util.NewSentryReporter(reporting.SentryDSN, reporting.Environment)
+		if err != nil {
+			panic(err.Error())
+		}
+	}
+
 	router := mux.NewRouter()
 	router.NotFoundHandler = &web.NotFoundHandler{}
 
 	web.RegisterRoutes(router)
 
-	handler := util.RecoveryHandler(router, logEnv.Err, true)
-	handler = handlers.LoggingHandler(logEnv.Access.Out, handler)
-	handler = handlers.CORS(
-		handlers.AllowedHeaders([]string{"Accept", "Content-Type", "Authorization"}),
-		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedMethods([]string{"GET", "PUT", "POST", "DELETE"}),
-	)(handler)
+	// middleware for all requests, the first one sees a request first
+	chain := []web.Middleware{
+		handlers.CORS(
+			handlers.AllowedHeaders([]string{"Accept", "Content-Type", "Authorization"}),
+			handlers.AllowedOrigins([]string{"*"}),
+			handlers.AllowedMethods([]string{"GET", "PUT", "POST", "DELETE"}),
+		),
+		web.ProxyHandler,
+		func(h http.Handler) http.Handler { return handlers.LoggingHandler(logEnv.Access.Out, h) },
+		func(h http.Handler) http.Handler { return util.RecoveryHandler(h, logEnv.Err, true, reporter) },
+	}
+	if srvConf.PathPrefix != "" {
+		chain = append(chain, func(h http.Handler) http.Handler { return http.StripPrefix(srvConf.PathPrefix, h) })
+	}
+	chain = append(chain, web.MaintenanceHandler, web.ReadOnlyHandler)
+	handler := web.Chain(chain...)(router)
+
+	if hooks := conf.GetAccountHooks(); hooks.Webhook != "" {
+		data.RegisterAccountHook(data.NewWebhookAccountHook(hooks))
+	}
+
+	if policy := conf.GetPolicy(); policy.URL != "" {
+		data.RegisterPolicyEngine(data.NewOPAPolicyEngine(policy))
+	}
 
 	data.RunCleaner()
+	data.RunGrantRequestGC()
 	data.RunEmailDispatch()
+	data.RunUsageFlush()
+	data.RunMaintenanceSync()
+
+	listener, err := util.Listen(srvConf.Socket, fmt.Sprintf("%s:%d", srvConf.Host, srvConf.Port))
+	if err != nil {
+		panic(err)
+	}
 
 	server := http.Server{
-		Addr:    fmt.Sprintf("%s:%d", srvConf.Host, srvConf.Port),
 		Handler: handler,
 	}
-	err = server.ListenAndServe()
+	err = server.Serve(listener)
 	if err != nil {
 		panic(err)
 	}
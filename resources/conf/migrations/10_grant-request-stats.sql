@@ -0,0 +1,28 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE GrantRequestStats (
+  day               DATE NOT NULL ,
+  clientUUID        VARCHAR(36) NOT NULL REFERENCES Clients(uuid) ON DELETE CASCADE ,
+  started           BIGINT NOT NULL DEFAULT 0 ,
+  completed         BIGINT NOT NULL DEFAULT 0 ,
+  abandoned         BIGINT NOT NULL DEFAULT 0 ,
+  PRIMARY KEY (day, clientUUID)
+);
+
+CREATE INDEX ON GrantRequests (createdAt);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP INDEX IF EXISTS grantrequests_createdat_idx;
+DROP TABLE IF EXISTS GrantRequestStats CASCADE;
@@ -0,0 +1,32 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE Accounts ADD COLUMN passwordChangedAt TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE Accounts ADD COLUMN isPasswordExpiryNotified BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE Accounts SET passwordChangedAt = updatedAt;
+
+CREATE OR REPLACE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP VIEW IF EXISTS ActiveAccounts;
+
+ALTER TABLE Accounts DROP COLUMN IF EXISTS passwordChangedAt;
+ALTER TABLE Accounts DROP COLUMN IF EXISTS isPasswordExpiryNotified;
+
+CREATE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
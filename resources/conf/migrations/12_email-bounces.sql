@@ -0,0 +1,40 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE EmailBounces (
+  email             VARCHAR(512) PRIMARY KEY ,
+  bounceType        VARCHAR(16) NOT NULL ,
+  reason            TEXT NOT NULL DEFAULT '' ,
+  count             INT NOT NULL DEFAULT 1 ,
+  isSuppressed      BOOLEAN NOT NULL DEFAULT FALSE ,
+  createdAt         TIMESTAMP NOT NULL ,
+  updatedAt         TIMESTAMP NOT NULL
+);
+
+ALTER TABLE Accounts ADD COLUMN isEmailBouncing BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE OR REPLACE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP VIEW IF EXISTS ActiveAccounts;
+
+ALTER TABLE Accounts DROP COLUMN IF EXISTS isEmailBouncing;
+
+CREATE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
+
+DROP TABLE IF EXISTS EmailBounces CASCADE;
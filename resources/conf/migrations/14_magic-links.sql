@@ -0,0 +1,38 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE MagicLinks (
+  token             VARCHAR(512) PRIMARY KEY ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  grantRequest      VARCHAR(512) NOT NULL REFERENCES GrantRequests(token) ON DELETE CASCADE ,
+  expires           TIMESTAMP NOT NULL ,
+  createdAt         TIMESTAMP NOT NULL
+);
+
+ALTER TABLE Accounts ADD COLUMN isMagicLinkEnabled BOOLEAN NOT NULL DEFAULT false;
+
+CREATE OR REPLACE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP VIEW IF EXISTS ActiveAccounts;
+
+ALTER TABLE Accounts DROP COLUMN IF EXISTS isMagicLinkEnabled;
+
+CREATE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
+
+DROP TABLE IF EXISTS MagicLinks CASCADE;
@@ -0,0 +1,39 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE Groups (
+  uuid              VARCHAR(36) PRIMARY KEY CHECK (char_length(uuid) = 36) ,
+  name              VARCHAR(512) NOT NULL UNIQUE ,
+  description       TEXT NOT NULL DEFAULT '' ,
+  parentUUID        VARCHAR(36) REFERENCES Groups(uuid) ON DELETE CASCADE ,
+  createdAt         TIMESTAMP NOT NULL ,
+  updatedAt         TIMESTAMP NOT NULL
+);
+
+CREATE INDEX ON Groups (parentUUID);
+
+CREATE TABLE GroupMembers (
+  groupUUID         VARCHAR(36) NOT NULL REFERENCES Groups(uuid) ON DELETE CASCADE ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  role              VARCHAR(16) NOT NULL ,
+  createdAt         TIMESTAMP NOT NULL ,
+  updatedAt         TIMESTAMP NOT NULL ,
+  PRIMARY KEY (groupUUID, accountUUID)
+);
+
+CREATE INDEX ON GroupMembers (accountUUID);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS GroupMembers CASCADE;
+DROP TABLE IF EXISTS Groups CASCADE;
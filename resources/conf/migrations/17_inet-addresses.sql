@@ -0,0 +1,19 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- store bound networks as cidr, which normalizes IPv6 representations
+ALTER TABLE AccessTokens ALTER COLUMN boundNetwork TYPE CIDR USING boundNetwork::cidr;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE AccessTokens ALTER COLUMN boundNetwork TYPE VARCHAR(64) USING boundNetwork::text;
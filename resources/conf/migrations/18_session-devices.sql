@@ -0,0 +1,21 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- user agent of the login and a device name chosen by the user (empty: derived from the user agent)
+ALTER TABLE Sessions ADD COLUMN userAgent VARCHAR(512) NOT NULL DEFAULT '';
+ALTER TABLE Sessions ADD COLUMN deviceName VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE Sessions DROP COLUMN IF EXISTS deviceName;
+ALTER TABLE Sessions DROP COLUMN IF EXISTS userAgent;
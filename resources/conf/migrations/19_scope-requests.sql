@@ -0,0 +1,40 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE ScopeRequests (
+  uuid              VARCHAR(36) PRIMARY KEY CHECK (char_length(uuid) = 36) ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  scope             VARCHAR(64) NOT NULL ,
+  reason            TEXT NOT NULL DEFAULT '' ,
+  state             VARCHAR(16) NOT NULL ,
+  confirmationCode  VARCHAR(512) UNIQUE ,
+  decidedBy         VARCHAR(36) NULL REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  createdAt         TIMESTAMP NOT NULL ,
+  updatedAt         TIMESTAMP NOT NULL
+);
+
+CREATE INDEX ON ScopeRequests (accountUUID);
+CREATE INDEX ON ScopeRequests (state);
+
+CREATE TABLE AccountScopes (
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  scope             VARCHAR(64) NOT NULL ,
+  grantedBy         VARCHAR(36) NULL REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  createdAt         TIMESTAMP NOT NULL ,
+  PRIMARY KEY (accountUUID, scope)
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS AccountScopes CASCADE;
+DROP TABLE IF EXISTS ScopeRequests CASCADE;
@@ -0,0 +1,27 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE ClientUsage (
+  day               DATE NOT NULL ,
+  clientUUID        VARCHAR(36) NOT NULL REFERENCES Clients(uuid) ON DELETE CASCADE ,
+  tokensIssued      BIGINT NOT NULL DEFAULT 0 ,
+  tokenValidations  BIGINT NOT NULL DEFAULT 0 ,
+  PRIMARY KEY (day, clientUUID)
+);
+
+CREATE INDEX ON UsageCounters (clientUUID, day);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP INDEX IF EXISTS usagecounters_clientuuid_day_idx;
+DROP TABLE IF EXISTS ClientUsage CASCADE;
@@ -0,0 +1,35 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE Accounts ADD COLUMN lastLoginAt TIMESTAMP NOT NULL DEFAULT now();
+ALTER TABLE Accounts ADD COLUMN dormancyNotifiedAt TIMESTAMP NULL;
+ALTER TABLE Accounts ADD COLUMN dormantSince TIMESTAMP NULL;
+ALTER TABLE Accounts ADD COLUMN isArchived BOOLEAN NOT NULL DEFAULT false;
+
+CREATE OR REPLACE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP VIEW IF EXISTS ActiveAccounts;
+
+ALTER TABLE Accounts DROP COLUMN IF EXISTS lastLoginAt;
+ALTER TABLE Accounts DROP COLUMN IF EXISTS dormancyNotifiedAt;
+ALTER TABLE Accounts DROP COLUMN IF EXISTS dormantSince;
+ALTER TABLE Accounts DROP COLUMN IF EXISTS isArchived;
+
+CREATE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
@@ -0,0 +1,32 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- How clients authenticate at the token endpoint and public keys (JSON Web Key Set) for private_key_jwt
+ALTER TABLE Clients ADD COLUMN authMethod VARCHAR(32) NOT NULL DEFAULT 'client_secret';
+ALTER TABLE Clients ADD COLUMN jwks TEXT NOT NULL DEFAULT '';
+
+-- Identifiers of used client assertions, which must not be replayed before they expire
+CREATE TABLE ClientAssertions (
+  clientUUID        VARCHAR(36) NOT NULL REFERENCES Clients(uuid) ON DELETE CASCADE ,
+  jti               VARCHAR(512) NOT NULL ,
+  expires           TIMESTAMP NOT NULL ,
+  PRIMARY KEY (clientUUID, jti)
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS ClientAssertions CASCADE;
+
+ALTER TABLE Clients DROP COLUMN IF EXISTS jwks;
+ALTER TABLE Clients DROP COLUMN IF EXISTS authMethod;
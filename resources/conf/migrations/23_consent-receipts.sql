@@ -0,0 +1,31 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- Receipts of all approvals given by users. The client name is kept, since receipts
+-- outlive the approval and the client itself.
+CREATE TABLE ConsentReceipts (
+  uuid              VARCHAR(36) PRIMARY KEY CHECK (char_length(uuid) = 36) ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  clientUUID        VARCHAR(36) NOT NULL ,
+  clientName        VARCHAR(512) NOT NULL ,
+  scope             VARCHAR[] NOT NULL ,
+  policyVersion     VARCHAR(64) NOT NULL ,
+  createdAt         TIMESTAMP NOT NULL
+);
+
+CREATE INDEX ON ConsentReceipts (accountUUID, createdAt);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS ConsentReceipts CASCADE;
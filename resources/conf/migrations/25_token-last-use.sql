@@ -0,0 +1,21 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- time of the last API request or token validation, respectively of the last exchange for a refresh token
+ALTER TABLE AccessTokens ADD COLUMN lastUsedAt TIMESTAMP;
+ALTER TABLE RefreshTokens ADD COLUMN lastUsedAt TIMESTAMP;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE RefreshTokens DROP COLUMN IF EXISTS lastUsedAt;
+ALTER TABLE AccessTokens DROP COLUMN IF EXISTS lastUsedAt;
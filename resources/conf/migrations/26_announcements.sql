@@ -0,0 +1,51 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- accounts which do not want to receive announcements sent by administrators
+ALTER TABLE Accounts ADD COLUMN isAnnouncementOptOut BOOLEAN NOT NULL DEFAULT false;
+
+CREATE OR REPLACE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
+
+CREATE TABLE Announcements (
+  uuid              VARCHAR(36) PRIMARY KEY CHECK (char_length(uuid) = 36) ,
+  subject           VARCHAR(512) NOT NULL ,
+  body              TEXT NOT NULL ,
+  label             VARCHAR(64) NOT NULL DEFAULT '' ,
+  groupName         VARCHAR(64) NOT NULL DEFAULT '' ,
+  recipients        INT NOT NULL DEFAULT 0 ,
+  createdBy         VARCHAR(36) REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  completedAt       TIMESTAMP ,
+  createdAt         TIMESTAMP NOT NULL
+);
+
+-- recipients of announcements which are not yet queued for sending
+CREATE TABLE AnnouncementRecipients (
+  announcementUUID  VARCHAR(36) NOT NULL REFERENCES Announcements(uuid) ON DELETE CASCADE ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  PRIMARY KEY (announcementUUID, accountUUID)
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS AnnouncementRecipients CASCADE;
+DROP TABLE IF EXISTS Announcements CASCADE;
+
+DROP VIEW IF EXISTS ActiveAccounts;
+
+ALTER TABLE Accounts DROP COLUMN IF EXISTS isAnnouncementOptOut;
+
+CREATE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND NOT isApprovalPending AND activationCode IS NULL AND resetPWCode IS NULL;
@@ -0,0 +1,26 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- former names and redirect URIs of clients, which are accepted with a warning until they expire
+CREATE TABLE ClientHistory (
+  clientUUID        VARCHAR(36) NOT NULL REFERENCES Clients(uuid) ON DELETE CASCADE ,
+  kind              VARCHAR(16) NOT NULL CHECK (kind IN ('name', 'redirect-uri')) ,
+  value             VARCHAR(512) NOT NULL ,
+  expires           TIMESTAMP NOT NULL ,
+  createdAt         TIMESTAMP NOT NULL ,
+  PRIMARY KEY (clientUUID, kind, value)
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS ClientHistory;
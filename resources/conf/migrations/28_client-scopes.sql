@@ -0,0 +1,35 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- scopes a client may request in addition to its whitelist
+ALTER TABLE Clients ADD COLUMN scopeAllowed VARCHAR[] NOT NULL DEFAULT '{}';
+
+-- requests of clients for additional scopes, which become requestable when granted by an administrator
+CREATE TABLE ClientScopeRequests (
+  uuid              VARCHAR(36) PRIMARY KEY CHECK (char_length(uuid) = 36) ,
+  clientUUID        VARCHAR(36) NOT NULL REFERENCES Clients(uuid) ON DELETE CASCADE ,
+  scope             VARCHAR(64) NOT NULL ,
+  reason            TEXT NOT NULL DEFAULT '' ,
+  state             VARCHAR(16) NOT NULL ,
+  decidedBy         VARCHAR(36) NULL REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  createdAt         TIMESTAMP NOT NULL ,
+  updatedAt         TIMESTAMP NOT NULL
+);
+
+CREATE INDEX ON ClientScopeRequests (clientUUID);
+CREATE INDEX ON ClientScopeRequests (state);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS ClientScopeRequests CASCADE;
+ALTER TABLE Clients DROP COLUMN IF EXISTS scopeAllowed;
@@ -0,0 +1,27 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- feature flags set by administrators, which override the flags of the server configuration
+CREATE TABLE FeatureFlags (
+  name              VARCHAR(64) PRIMARY KEY ,
+  enabled           BOOLEAN NOT NULL DEFAULT FALSE ,
+  percentage        INTEGER NOT NULL DEFAULT 0 CHECK (percentage BETWEEN 0 AND 100) ,
+  accounts          VARCHAR[] NOT NULL DEFAULT '{}' ,
+  updatedBy         VARCHAR(36) NULL REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  createdAt         TIMESTAMP NOT NULL ,
+  updatedAt         TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS FeatureFlags CASCADE;
@@ -0,0 +1,28 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE AccountHistory (
+  id                SERIAL PRIMARY KEY ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  field             VARCHAR(64) NOT NULL ,
+  oldValue          VARCHAR(512) ,
+  newValue          VARCHAR(512) ,
+  changedBy         VARCHAR(36) REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  createdAt         TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX ON AccountHistory (accountUUID);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS AccountHistory CASCADE;
@@ -0,0 +1,21 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- the version of a scope is increased when its meaning changes, approvals of changed scopes become stale
+ALTER TABLE ClientScopeProvided ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE ClientApprovals ADD COLUMN stale BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE ClientApprovals DROP COLUMN IF EXISTS stale;
+ALTER TABLE ClientScopeProvided DROP COLUMN IF EXISTS version;
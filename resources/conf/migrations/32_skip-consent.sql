@@ -0,0 +1,19 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- trusted clients are approved without showing the consent page
+ALTER TABLE Clients ADD COLUMN skipConsent BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE Clients DROP COLUMN IF EXISTS skipConsent;
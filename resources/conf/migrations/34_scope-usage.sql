@@ -0,0 +1,26 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- daily validations of tokens per scope, used to find scopes which are no longer used
+CREATE TABLE ScopeUsage (
+  day               DATE NOT NULL ,
+  scope             VARCHAR(512) NOT NULL ,
+  tokenValidations  BIGINT NOT NULL DEFAULT 0 ,
+  PRIMARY KEY (day, scope)
+);
+
+CREATE INDEX ON ScopeUsage (scope, day);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS ScopeUsage CASCADE;
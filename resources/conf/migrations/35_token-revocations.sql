@@ -0,0 +1,46 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- access tokens which were deleted before they expired, published to resource servers via the
+-- revocation feed; the id is the cursor of the feed and rows are removed when the token expires
+CREATE TABLE TokenRevocations (
+  id                BIGSERIAL PRIMARY KEY ,
+  token             VARCHAR(512) NOT NULL ,
+  clientUUID        VARCHAR(36) NOT NULL ,
+  expires           TIMESTAMP WITH TIME ZONE NOT NULL ,
+  createdAt         TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX ON TokenRevocations (expires);
+
+-- the trigger records every way an access token can be revoked: logout, revocation by the owner
+-- or an administrator, disabled accounts and deleted clients
+-- +goose StatementBegin
+CREATE FUNCTION recordTokenRevocation() RETURNS trigger AS $$
+BEGIN
+  IF OLD.expires > now() THEN
+    INSERT INTO TokenRevocations (token, clientUUID, expires) VALUES (OLD.token, OLD.clientUUID, OLD.expires);
+  END IF;
+  RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER AccessTokenRevocation AFTER DELETE ON AccessTokens
+  FOR EACH ROW EXECUTE PROCEDURE recordTokenRevocation();
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TRIGGER IF EXISTS AccessTokenRevocation ON AccessTokens;
+DROP FUNCTION IF EXISTS recordTokenRevocation();
+DROP TABLE IF EXISTS TokenRevocations CASCADE;
@@ -0,0 +1,30 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- codes sent with the notification about a changed e-mail address or password, which allow
+-- the owner to revert the change and lock the account; only the HMAC of a code is stored
+CREATE TABLE AccountRecoveries (
+  code              VARCHAR(64) PRIMARY KEY ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  field             VARCHAR(32) NOT NULL ,      -- 'email' or 'password'
+  email             VARCHAR(512) NOT NULL ,     -- address the code was sent to
+  expires           TIMESTAMP WITH TIME ZONE NOT NULL ,
+  createdAt         TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX ON AccountRecoveries (accountUUID);
+CREATE INDEX ON AccountRecoveries (expires);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS AccountRecoveries CASCADE;
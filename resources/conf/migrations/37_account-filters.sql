@@ -0,0 +1,36 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- named account filters saved by administrators
+CREATE TABLE AccountFilters (
+  name              VARCHAR(64) PRIMARY KEY ,
+  description       VARCHAR(512) NOT NULL DEFAULT '' ,
+  search            VARCHAR(256) NOT NULL DEFAULT '' ,
+  label             VARCHAR(64) NOT NULL DEFAULT '' ,
+  groupName         VARCHAR(64) NOT NULL DEFAULT '' ,
+  emailVerified     BOOLEAN NULL ,                    -- NULL matches verified and unverified accounts
+  createdDays       INT NOT NULL DEFAULT 0 ,
+  inactiveDays      INT NOT NULL DEFAULT 0 ,
+  updatedBy         VARCHAR(36) NULL REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  createdAt         TIMESTAMP WITH TIME ZONE NOT NULL ,
+  updatedAt         TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+-- announcements can be sent to the accounts matching a saved filter
+ALTER TABLE Announcements ADD COLUMN filterName VARCHAR(64) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE Announcements DROP COLUMN IF EXISTS filterName;
+
+DROP TABLE IF EXISTS AccountFilters CASCADE;
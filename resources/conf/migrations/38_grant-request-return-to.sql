@@ -0,0 +1,19 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- URL within a client (or gin-auth) the user is sent back to when the grant request is finished
+ALTER TABLE GrantRequests ADD COLUMN returnTo VARCHAR(1024) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE GrantRequests DROP COLUMN IF EXISTS returnTo;
@@ -0,0 +1,25 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- publisher information and verification status shown on the consent page
+ALTER TABLE Clients ADD COLUMN publisherName VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE Clients ADD COLUMN homepage VARCHAR(1024) NOT NULL DEFAULT '';
+ALTER TABLE Clients ADD COLUMN logoURL VARCHAR(1024) NOT NULL DEFAULT '';
+ALTER TABLE Clients ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE Clients DROP COLUMN IF EXISTS verified;
+ALTER TABLE Clients DROP COLUMN IF EXISTS logoURL;
+ALTER TABLE Clients DROP COLUMN IF EXISTS homepage;
+ALTER TABLE Clients DROP COLUMN IF EXISTS publisherName;
@@ -0,0 +1,33 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+ALTER TABLE Accounts ADD COLUMN isEmailVerified BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE Accounts ADD COLUMN emailVerificationCode VARCHAR(512) UNIQUE;
+
+-- activated accounts have proven access to their e-mail address
+UPDATE Accounts SET isEmailVerified = TRUE WHERE activationCode IS NULL;
+
+CREATE OR REPLACE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND activationCode IS NULL AND resetPWCode IS NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP VIEW IF EXISTS ActiveAccounts;
+
+ALTER TABLE Accounts DROP COLUMN IF EXISTS emailVerificationCode;
+ALTER TABLE Accounts DROP COLUMN IF EXISTS isEmailVerified;
+
+CREATE VIEW ActiveAccounts AS
+  SELECT * from Accounts
+  WHERE NOT isDisabled AND activationCode IS NULL AND resetPWCode IS NULL;
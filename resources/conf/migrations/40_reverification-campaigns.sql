@@ -0,0 +1,44 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- campaigns which require selected accounts to confirm their e-mail address and/or change
+-- their password at the next login, e.g. after a suspected credential stuffing incident
+CREATE TABLE ReverificationCampaigns (
+  uuid              VARCHAR(36) PRIMARY KEY CHECK (char_length(uuid) = 36) ,
+  reason            VARCHAR(512) NOT NULL ,
+  requireEmail      BOOLEAN NOT NULL ,
+  requirePassword   BOOLEAN NOT NULL ,
+  label             VARCHAR(64) NOT NULL DEFAULT '' ,
+  groupName         VARCHAR(64) NOT NULL DEFAULT '' ,
+  filterName        VARCHAR(64) NOT NULL DEFAULT '' ,
+  createdBy         VARCHAR(36) REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  createdAt         TIMESTAMP NOT NULL
+);
+
+-- accounts selected by a campaign and the steps they completed
+CREATE TABLE Reverifications (
+  campaignUUID      VARCHAR(36) NOT NULL REFERENCES ReverificationCampaigns(uuid) ON DELETE CASCADE ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  emailRequired     BOOLEAN NOT NULL ,
+  passwordRequired  BOOLEAN NOT NULL ,
+  emailVerifiedAt   TIMESTAMP ,
+  passwordChangedAt TIMESTAMP ,
+  completedAt       TIMESTAMP ,
+  PRIMARY KEY (campaignUUID, accountUUID)
+);
+CREATE INDEX ReverificationsPendingIdx ON Reverifications (accountUUID) WHERE completedAt IS NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS Reverifications CASCADE;
+DROP TABLE IF EXISTS ReverificationCampaigns CASCADE;
@@ -0,0 +1,55 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- rows written by the row audit triggers, which record every change of audited tables including
+-- changes made directly in the database; the triggers are switched on and off with gin-auth-admin
+CREATE TABLE RowAudit (
+  id                BIGSERIAL PRIMARY KEY ,
+  tableName         VARCHAR(64) NOT NULL ,
+  operation         VARCHAR(8) NOT NULL ,
+  oldRow            JSONB NULL ,
+  newRow            JSONB NULL ,
+  dbUser            VARCHAR(64) NOT NULL DEFAULT current_user ,
+  createdAt         TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX ON RowAudit (createdAt);
+
+-- password hashes and client secrets are never copied into the audit table
+-- +goose StatementBegin
+CREATE FUNCTION recordRowAudit() RETURNS trigger AS $$
+DECLARE
+  oldRow JSONB;
+  newRow JSONB;
+BEGIN
+  IF TG_OP <> 'INSERT' THEN
+    oldRow := to_jsonb(OLD) - 'pwhash' - 'secret';
+  END IF;
+  IF TG_OP <> 'DELETE' THEN
+    newRow := to_jsonb(NEW) - 'pwhash' - 'secret';
+  END IF;
+  INSERT INTO RowAudit (tableName, operation, oldRow, newRow) VALUES (TG_TABLE_NAME, TG_OP, oldRow, newRow);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TRIGGER IF EXISTS RowAudit ON Accounts;
+DROP TRIGGER IF EXISTS RowAudit ON Clients;
+DROP TRIGGER IF EXISTS RowAudit ON ClientApprovals;
+DROP TRIGGER IF EXISTS RowAudit ON SSHKeys;
+DROP TRIGGER IF EXISTS RowAudit ON GroupMembers;
+DROP FUNCTION IF EXISTS recordRowAudit();
+DROP TABLE IF EXISTS RowAudit CASCADE;
@@ -0,0 +1,29 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- tokens with which background jobs act, only the SHA-256 hash of the token is stored; the
+-- uuid identifies a token in audit events and rows are removed when the token expires
+CREATE TABLE ServiceTokens (
+  uuid              VARCHAR(36) PRIMARY KEY CHECK (char_length(uuid) = 36) ,
+  tokenHash         VARCHAR(64) NOT NULL UNIQUE ,
+  job               VARCHAR(64) NOT NULL ,
+  scope             VARCHAR(64)[] NOT NULL ,
+  expires           TIMESTAMP WITH TIME ZONE NOT NULL ,
+  createdAt         TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX ON ServiceTokens (expires);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS ServiceTokens CASCADE;
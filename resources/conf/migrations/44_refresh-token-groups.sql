@@ -0,0 +1,19 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- names of the groups access tokens issued with the refresh token act for, empty if not restricted to groups
+ALTER TABLE RefreshTokens ADD COLUMN groupRestriction VARCHAR[] NOT NULL DEFAULT '{}';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE RefreshTokens DROP COLUMN IF EXISTS groupRestriction;
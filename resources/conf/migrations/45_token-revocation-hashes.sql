@@ -0,0 +1,54 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- revoked tokens are only kept as hex encoded SHA-256 hash (requires PostgreSQL 11 or newer)
+ALTER TABLE TokenRevocations ADD COLUMN tokenHash VARCHAR(64);
+UPDATE TokenRevocations SET tokenHash = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+ALTER TABLE TokenRevocations ALTER COLUMN tokenHash SET NOT NULL;
+ALTER TABLE TokenRevocations DROP COLUMN token;
+
+-- the id of the transaction which revoked the token; the feed only publishes revocations of finished
+-- transactions, thus revocations committed out of order are not skipped
+ALTER TABLE TokenRevocations ADD COLUMN txid BIGINT NOT NULL DEFAULT txid_current();
+CREATE INDEX ON TokenRevocations (txid);
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION recordTokenRevocation() RETURNS trigger AS $$
+BEGIN
+  IF OLD.expires > now() THEN
+    INSERT INTO TokenRevocations (tokenHash, clientUUID, expires)
+      VALUES (encode(sha256(convert_to(OLD.token, 'UTF8')), 'hex'), OLD.clientUUID, OLD.expires);
+  END IF;
+  RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+-- the tokens can not be restored, revocations recorded so far are dropped
+DELETE FROM TokenRevocations;
+ALTER TABLE TokenRevocations DROP COLUMN IF EXISTS txid;
+ALTER TABLE TokenRevocations DROP COLUMN IF EXISTS tokenHash;
+ALTER TABLE TokenRevocations ADD COLUMN token VARCHAR(512) NOT NULL;
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION recordTokenRevocation() RETURNS trigger AS $$
+BEGIN
+  IF OLD.expires > now() THEN
+    INSERT INTO TokenRevocations (token, clientUUID, expires) VALUES (OLD.token, OLD.clientUUID, OLD.expires);
+  END IF;
+  RETURN OLD;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- background jobs whose service tokens were revoked; disabled jobs neither mint new tokens
+-- nor are their remaining tokens accepted until they are enabled again
+CREATE TABLE ServiceJobs (
+  job               VARCHAR(64) PRIMARY KEY ,
+  isDisabled        BOOLEAN NOT NULL DEFAULT FALSE ,
+  updatedAt         TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS ServiceJobs CASCADE;
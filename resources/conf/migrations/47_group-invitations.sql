@@ -0,0 +1,30 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- accounts become members of a group only after they accepted the invitation of an owner
+CREATE TABLE GroupInvitations (
+  groupUUID         VARCHAR(36) NOT NULL REFERENCES Groups(uuid) ON DELETE CASCADE ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  role              VARCHAR(16) NOT NULL ,
+  invitedBy         VARCHAR(36) REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  expires           TIMESTAMP NOT NULL ,
+  createdAt         TIMESTAMP NOT NULL ,
+  updatedAt         TIMESTAMP NOT NULL ,
+  PRIMARY KEY (groupUUID, accountUUID)
+);
+
+CREATE INDEX ON GroupInvitations (accountUUID);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS GroupInvitations CASCADE;
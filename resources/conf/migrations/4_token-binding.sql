@@ -0,0 +1,21 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- empty: no binding, 'ip': bind to the requesting address, otherwise a network in CIDR notation
+ALTER TABLE Clients ADD COLUMN tokenBinding VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE AccessTokens ADD COLUMN boundNetwork VARCHAR(64) NULL;
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE AccessTokens DROP COLUMN IF EXISTS boundNetwork;
+ALTER TABLE Clients DROP COLUMN IF EXISTS tokenBinding;
@@ -0,0 +1,26 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- maintenance mode set by an administrator, a single row which survives restarts and is shared by all instances
+CREATE TABLE MaintenanceState (
+  id                BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id) ,
+  enabled           BOOLEAN NOT NULL DEFAULT FALSE ,
+  message           TEXT NOT NULL ,
+  retryAfter        INTEGER NOT NULL ,
+  updatedBy         VARCHAR(36) NULL REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  updatedAt         TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS MaintenanceState CASCADE;
@@ -0,0 +1,27 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+CREATE TABLE UsageCounters (
+  day               DATE NOT NULL ,
+  accountUUID       VARCHAR(36) NOT NULL REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  clientUUID        VARCHAR(36) NOT NULL REFERENCES Clients(uuid) ON DELETE CASCADE ,
+  apiRequests       BIGINT NOT NULL DEFAULT 0 ,
+  tokenValidations  BIGINT NOT NULL DEFAULT 0 ,
+  PRIMARY KEY (day, accountUUID, clientUUID)
+);
+
+CREATE INDEX ON UsageCounters (accountUUID, day);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS UsageCounters CASCADE;
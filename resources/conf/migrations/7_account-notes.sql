@@ -0,0 +1,28 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- notes and labels on accounts which are only visible to administrators
+CREATE TABLE AccountNotes (
+  accountUUID       VARCHAR(36) PRIMARY KEY REFERENCES Accounts(uuid) ON DELETE CASCADE ,
+  notes             TEXT NOT NULL DEFAULT '' ,
+  labels            VARCHAR[] NOT NULL DEFAULT '{}' ,
+  updatedBy         VARCHAR(36) NULL REFERENCES Accounts(uuid) ON DELETE SET NULL ,
+  createdAt         TIMESTAMP WITH TIME ZONE NOT NULL ,
+  updatedAt         TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX ON AccountNotes USING GIN (labels);
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+DROP TABLE IF EXISTS AccountNotes CASCADE;
@@ -0,0 +1,21 @@
+-- Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+--
+-- All rights reserved.
+--
+-- Redistribution and use in source and binary forms, with or without
+-- modification, are permitted under the terms of the BSD License. See
+-- LICENSE file in the root of the Project.
+
+
+-- +goose Up
+-- SQL in section 'Up' is executed when this migration is applied
+
+-- URLs clients may redirect to after logout and URL loaded by the browser to notify the client about a logout
+ALTER TABLE Clients ADD COLUMN postLogoutRedirectURIs VARCHAR[] NOT NULL DEFAULT '{}';
+ALTER TABLE Clients ADD COLUMN frontChannelLogoutURI VARCHAR(512) NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQL section 'Down' is executed when this migration is rolled back
+
+ALTER TABLE Clients DROP COLUMN IF EXISTS frontChannelLogoutURI;
+ALTER TABLE Clients DROP COLUMN IF EXISTS postLogoutRedirectURIs;
@@ -1,13 +1,44 @@
 -- Test fixtures to be used in tests
+DELETE FROM ServiceJobs;
+DELETE FROM ServiceTokens;
+DELETE FROM RowAudit;
+DELETE FROM Reverifications;
+DELETE FROM ReverificationCampaigns;
+DELETE FROM FeatureFlags;
+DELETE FROM MaintenanceState;
+DELETE FROM AccountFilters;
+DELETE FROM AnnouncementRecipients;
+DELETE FROM Announcements;
+DELETE FROM AccountScopes;
+DELETE FROM ScopeRequests;
+DELETE FROM UsageCounters;
+DELETE FROM ClientUsage;
+DELETE FROM ScopeUsage;
+DELETE FROM Notifications;
+DELETE FROM MagicLinks;
+DELETE FROM AccountRecoveries;
+DELETE FROM GrantRequestStats;
 DELETE FROM EmailQueue;
+DELETE FROM EmailBounces;
 DELETE FROM RefreshTokens;
 DELETE FROM AccessTokens;
+-- after AccessTokens, whose deletion records revocations
+DELETE FROM TokenRevocations;
 DELETE FROM Sessions;
 DELETE FROM GrantRequests;
 DELETE FROM ClientApprovals;
+DELETE FROM ConsentReceipts;
+DELETE FROM GroupInvitations;
+DELETE FROM GroupMembers;
+DELETE FROM Groups;
+DELETE FROM ClientHistory;
+DELETE FROM ClientScopeRequests;
+DELETE FROM ClientAssertions;
 DELETE FROM ClientScopeProvided;
 DELETE FROM Clients;
 DELETE FROM SSHKeys;
+DELETE FROM AccountHistory;
+DELETE FROM AccountNotes;
 DELETE FROM Accounts;
 
 INSERT INTO Accounts (uuid, login, pwHash, email, isEmailPublic, title, firstName, lastName, institute, department, city, country, isAffiliationPublic, activationCode, createdAt, updatedAt) VALUES
@@ -16,15 +47,24 @@ INSERT INTO Accounts (uuid, login, pwHash, email, isEmailPublic, title, firstNam
   ('03dcd573-1cce-4eb1-8b33-73860575da65', 'john', '', 'jj@example.com', FALSE, 'Mr.', 'John', 'Josephson', 'LMU', 'Biology II', 'Munich', 'Germany', TRUE, NULL, '2015-01-01 01:00:00', '2015-02-02 01:00:00');
 -- Set pw to 'testtest'
 UPDATE Accounts SET pwHash = '$2a$10$kYB77ZPuIxon00ZPpk6APeAqi5J7aOPpqaPwS6riF40/RrfQ.EMlW';
+-- Alice and Bob have verified e-mail addresses
+UPDATE Accounts SET isEmailVerified = TRUE WHERE login IN ('alice', 'bob');
+-- Codes are stored as HMAC (see codes in server.yml) of '<code>.4102444800', e.g. 'ac_a.4102444800'
+UPDATE Accounts SET emailVerificationCode = '9807f19c6bf6cc5043b39b7b008aff325b421801adea2c4a2e1902058128a89c' WHERE login = 'john';
+-- The password of john expired by the 'institutional' policy (see account notes)
+UPDATE Accounts SET passwordChangedAt = now() - INTERVAL '200 days' WHERE login = 'john';
 
 -- add account active and disabled testaccounts
 INSERT INTO Accounts (uuid, login, pwhash, email, firstname, lastname, institute, department, city, country, activationcode, resetpwcode, isdisabled, createdat, updatedat) VALUES
-  ('test0001-1234-6789-1234-678901234567', 'inact_log1', '', 'email1@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', 'ac_a', NULL, FALSE, now(), now()),
-  ('test0002-1234-6789-1234-678901234567', 'inact_log2', '', 'email2@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', NULL, 'rc_a', FALSE, now(), now()),
-  ('test0003-1234-6789-1234-678901234567', 'inact_log3', '', 'email3@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', 'ac_c', 'rc_b', FALSE, now(), now()),
+  ('test0001-1234-6789-1234-678901234567', 'inact_log1', '', 'email1@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', '39e095417a4c73ee02925df52bb08bcc1fe193e404a9380957e989409f8d83f8', NULL, FALSE, now(), now()),
+  ('test0002-1234-6789-1234-678901234567', 'inact_log2', '', 'email2@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', NULL, 'c50159783a3f423c41945d3b6241dffa457abb334e00b07ec75b9c5f2297480a', FALSE, now(), now()),
+  ('test0003-1234-6789-1234-678901234567', 'inact_log3', '', 'email3@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', '42fff72684fd4e82b840b5a9bc3e4aca99c2085ee7d1a01ab0d9794a19caae16', '7ec14d8ac3a60c91250c3925b7f9ecd27cb192393ec50e4fc7b001291157b2e3', FALSE, now(), now()),
   ('test0004-1234-6789-1234-678901234567', 'inact_log4', '', 'email4@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', NULL, NULL, TRUE, now(), now()),
-  ('test0005-1234-6789-1234-678901234567', 'inact_log5', '', 'email5@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', 'ac_b', NULL, TRUE, now(), now()),
-  ('test0006-1234-6789-1234-678901234567', 'inact_log6', '', 'email6@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', 'ac_d', 'rc_c', TRUE, now(), now());
+  ('test0005-1234-6789-1234-678901234567', 'inact_log5', '', 'email5@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', '90f08f65144fa84849969463b68b9b4822ac4a33c99e626de0e97040606bc100', NULL, TRUE, now(), now()),
+  ('test0006-1234-6789-1234-678901234567', 'inact_log6', '', 'email6@example.com', 'fname', 'lname', 'inst', 'dep', 'cty', 'ctry', 'fae97c01452e8b243b3b772b376db63f9ec98fe49d5393aad66e380372ff47e0', 'd38c6dd1a1e0a1c0100e90e494e7e066e3458213a1ec6dd5ecbcec8fba2d74a9', TRUE, now(), now()),
+  ('test0007-1234-6789-1234-678901234567', 'pending', '', 'pending@example.com', 'Paul', 'Pending', 'inst', 'dep', 'cty', 'ctry', NULL, NULL, FALSE, now(), now());
+-- activated account waiting for approval by an administrator
+UPDATE Accounts SET isApprovalPending = TRUE WHERE login = 'pending';
 
 INSERT INTO SSHKeys (fingerprint, accountUUID, description, temporary, key, createdAt, updatedAt) VALUES
   ('A3tkBXFQWkjU6rzhkofY55G7tPR/Lmna4B+WEGVFXOQ', 'bf431618-f696-4dca-a95d-882618ce4ef9', 'Key from alice', false, 'ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQDLtRNg1UHUf0k0ZlkfoYod9NoDPpOgx2AStEaEk/0bIKBqWJUNAZUfc6CHooKXTP3YakgqI7/BxV2pVgJIFBI4K9yGeLu76mwTpIZUTjEw/VoOaNP/vfV0LmXvQXstXMOZkmWt1rFaLsBpL9REP7XxteZYc2tjyVqy32GsVZHh6pPNes2q1Cf+awhkV/kXjup5AXwROLzqRvYBRs8oMPFDRZEGGax/Pp+r2GTB44M8YC0p7JAL3tLDDWsLVyygFA0OGhUffHmOGGf69uhh5JHhOjp49GEGftABdjnJznrVAI/71ySt0xWHJIOgMScsUGLYJtOZE/9KVrOQgZ1UAQML bar@foo', now(), now()),
@@ -37,6 +77,29 @@ INSERT INTO SSHKeys (fingerprint, accountUUID, description, temporary, key, crea
 INSERT INTO Clients (uuid, name, secret, scopeWhitelist, scopeBlacklist, redirectURIs, createdAt, updatedAt) VALUES
   ('8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'gin', 'secret', '{"account-create"}','{"account-admin"}','{"https://localhost:8081/login","http://localhost:8080/notice"}', now(), now()),
   ('177c56a4-57b4-4baf-a1a7-04f3d8e5b276', 'wb', 'secret', '{"account-read","repo-read"}','{"account-admin"}','{"https://localhost:8081/login"}', now(), now());
+-- scopes the clients may request in addition to their whitelist
+UPDATE Clients SET scopeAllowed = '{"account-read","account-read-email","account-write","repo-read","repo-write","ssh-cert","curator"}' WHERE name = 'gin';
+UPDATE Clients SET scopeAllowed = '{"account-write","repo-write"}' WHERE name = 'wb';
+-- wb requested the scope ssh-cert, gin was refused the scope account-admin
+INSERT INTO ClientScopeRequests (uuid, clientUUID, scope, reason, state, createdAt, updatedAt) VALUES
+  ('c5f1e8a0-3b5d-4c1e-9f2a-7d6b8e4a1c01', '177c56a4-57b4-4baf-a1a7-04f3d8e5b276', 'ssh-cert', 'Access to repositories via ssh', 'pending', now(), now()),
+  ('c5f1e8a0-3b5d-4c1e-9f2a-7d6b8e4a1c02', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'account-admin', 'Administration', 'rejected', now() - interval '2 days', now() - interval '1 day');
+-- wb is a trusted first-party client which may use the JSON login API and is approved without consent page
+UPDATE Clients SET firstParty = TRUE, skipConsent = TRUE WHERE name = 'wb';
+-- gin may query access decisions for repositories
+UPDATE Clients SET resourceServer = TRUE WHERE name = 'gin';
+-- logout URLs
+UPDATE Clients SET postLogoutRedirectURIs = '{"http://localhost:8080/logged_out"}',
+                   frontChannelLogoutURI = 'http://localhost:8080/frontchannel_logout' WHERE name = 'gin';
+UPDATE Clients SET frontChannelLogoutURI = 'https://localhost:8081/frontchannel_logout' WHERE name = 'wb';
+-- gin is published by a verified publisher, wb is not verified
+UPDATE Clients SET publisherName = 'G-Node', homepage = 'https://www.g-node.org',
+                   logoURL = 'https://www.g-node.org/images/logo.png', verified = TRUE WHERE name = 'gin';
+-- wb was renamed and moved its login page, the former name of gin is no longer accepted
+INSERT INTO ClientHistory (clientUUID, kind, value, expires, createdAt) VALUES
+  ('177c56a4-57b4-4baf-a1a7-04f3d8e5b276', 'name', 'workbench', now() + interval '10 days', now()),
+  ('177c56a4-57b4-4baf-a1a7-04f3d8e5b276', 'redirect-uri', 'https://localhost:8081/oauth/login', now() + interval '10 days', now()),
+  ('8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'name', 'gin-old', now() - interval '1 day', now() - interval '31 days');
 
 INSERT INTO ClientScopeProvided (clientuuid, name, description) VALUES
   ('8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'account-create', 'Create an account'),
@@ -50,6 +113,10 @@ INSERT INTO ClientApprovals (uuid, scope, clientUUID, accountUUID, createdAt, up
   ('31da7869-4593-4682-b9f2-5f47987aa5fc', '{"repo-read","repo-write"}', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'bf431618-f696-4dca-a95d-882618ce4ef9', now(), now()),
   ('ffde3769-cb45-43c1-8afd-4fb154ddf0b0', '{"repo-write","account-write"}', '177c56a4-57b4-4baf-a1a7-04f3d8e5b276', 'bf431618-f696-4dca-a95d-882618ce4ef9', now(), now());
 
+INSERT INTO ConsentReceipts (uuid, accountUUID, clientUUID, clientName, scope, policyVersion, createdAt) VALUES
+  ('6a0b6a2e-3b2d-4d6b-9d53-0c1c2f6e8f01', 'bf431618-f696-4dca-a95d-882618ce4ef9', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'gin', '{"repo-read","repo-write"}', '2016-01', '2016-02-01 10:00:00'),
+  ('c4f2e1d7-8a9b-4c3d-b2e1-5f6a7b8c9d02', 'bf431618-f696-4dca-a95d-882618ce4ef9', '177c56a4-57b4-4baf-a1a7-04f3d8e5b276', 'wb', '{"repo-write","account-write"}', '2016-01', '2016-02-02 10:00:00');
+
 INSERT INTO GrantRequests (token, grantType, state, code, scopeRequested, redirectUri, clientUUID, accountUUID, createdAt, updatedAt) VALUES
   ('U7JIKKYI', 'code', 'OCQYDRYW', 'HGZQP6WE','{"repo-read","repo-write"}', 'https://localhost:8081/login', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'bf431618-f696-4dca-a95d-882618ce4ef9', now(), now()),
   ('QH92T99D', 'code', 'HD58GHV9', NULL ,'{"account-read","repo-read"}', 'https://localhost:8081/login', '177c56a4-57b4-4baf-a1a7-04f3d8e5b276', 'bf431618-f696-4dca-a95d-882618ce4ef9', now(), now()),
@@ -57,20 +124,98 @@ INSERT INTO GrantRequests (token, grantType, state, code, scopeRequested, redire
   ('AGTBAI3D', 'code', 'GBNAM23L', 'KWANG2G4','{"account-read"}', 'https://localhost:8081/login', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', '51f5ac36-d332-4889-8023-6e033fcd8e17', 'yesterday', 'yesterday'),
   ('QPJ64HK0', 'client', 'AHZ6DK8F', '0LA7T4EO','{"account-create"}', 'http://localhost:8080/notice', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', NULL, now(), now());
 
-INSERT INTO Sessions (token, expires, accountUUID, createdAt, updatedAt) VALUES
-  ('DNM5RS3C', 'tomorrow', 'bf431618-f696-4dca-a95d-882618ce4ef9', now(), now()),
-  ('4KDNO8T0', 'tomorrow', '51f5ac36-d332-4889-8023-6e033fcd8e17', now(), now()),
-  ('2MFZZUKI', 'yesterday', '51f5ac36-d332-4889-8023-6e033fcd8e17', 'yesterday', 'yesterday');
+INSERT INTO Sessions (token, expires, accountUUID, userAgent, createdAt, updatedAt) VALUES
+  ('DNM5RS3C', 'tomorrow', 'bf431618-f696-4dca-a95d-882618ce4ef9', 'Mozilla/5.0 (X11; Linux x86_64; rv:109.0) Gecko/20100101 Firefox/118.0', now(), now()),
+  ('4KDNO8T0', 'tomorrow', '51f5ac36-d332-4889-8023-6e033fcd8e17', '', now(), now()),
+  ('2MFZZUKI', 'yesterday', '51f5ac36-d332-4889-8023-6e033fcd8e17', '', 'yesterday', 'yesterday');
 
 INSERT INTO AccessTokens (token, expires, scope, clientUUID, accountUUID, createdAt, updatedAt) VALUES
   ('3N7MP7M7', 'tomorrow', '{"account-read","account-write","repo-read","repo-write"}', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'bf431618-f696-4dca-a95d-882618ce4ef9', now(), now()),
   ('LJ3W7ZFK', 'yesterday', '{"account-read","account-write","repo-read","repo-write"}', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', '51f5ac36-d332-4889-8023-6e033fcd8e17', 'yesterday', 'yesterday'),
   ('KDEW57D4', 'tomorrow', '{"account-admin","repo-admin"}', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', '51f5ac36-d332-4889-8023-6e033fcd8e17', now(), now());
+-- access token bound to a network
+INSERT INTO AccessTokens (token, expires, scope, clientUUID, accountUUID, boundNetwork, lastUsedAt, createdAt, updatedAt) VALUES
+  ('B7NDW8TX', 'tomorrow', '{"account-read"}', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'bf431618-f696-4dca-a95d-882618ce4ef9', '10.0.0.0/8', 'yesterday', now(), now());
 
 INSERT INTO RefreshTokens (token, scope, clientUUID, accountUUID, createdAt, updatedAt) VALUES
   ('YYPTDSVZ', '{"repo-read","repo-write"}', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 'bf431618-f696-4dca-a95d-882618ce4ef9', now(), now()),
   ('4FKJVX3K', '{"repo-read","repo-write"}', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', '51f5ac36-d332-4889-8023-6e033fcd8e17', 'yesterday', 'yesterday');
 
+INSERT INTO AccountHistory (accountUUID, field, oldValue, newValue, changedBy, createdAt) VALUES
+  ('bf431618-f696-4dca-a95d-882618ce4ef9', 'first_name', 'Alicia', 'Alice', 'bf431618-f696-4dca-a95d-882618ce4ef9', '2015-02-02 01:00:00'),
+  ('bf431618-f696-4dca-a95d-882618ce4ef9', 'title', NULL, 'Dr.', '51f5ac36-d332-4889-8023-6e033fcd8e17', '2015-02-03 01:00:00');
+
 INSERT INTO EmailQueue (mode, sender, recipient, content, createdat) VALUES
   ('print', 'no-reply@g-node.org', '{"a@example.com"}', 'content2', now()),
   ('skip', 'no-reply@g-node.org', '{"b@example.com"}', 'content3', now());
+
+INSERT INTO UsageCounters (day, accountUUID, clientUUID, apiRequests, tokenValidations) VALUES
+  (current_date, 'bf431618-f696-4dca-a95d-882618ce4ef9', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 120, 30),
+  (current_date, 'bf431618-f696-4dca-a95d-882618ce4ef9', '177c56a4-57b4-4baf-a1a7-04f3d8e5b276', 5, 0),
+  (current_date - 1, 'bf431618-f696-4dca-a95d-882618ce4ef9', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 80, 20),
+  (current_date - 60, 'bf431618-f696-4dca-a95d-882618ce4ef9', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 1000, 0),
+  (current_date, '51f5ac36-d332-4889-8023-6e033fcd8e17', '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 10, 2);
+
+INSERT INTO GrantRequestStats (day, clientUUID, started, completed, abandoned) VALUES
+  (current_date, '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 20, 15, 2),
+  (current_date - 1, '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 10, 9, 1),
+  (current_date - 60, '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 100, 100, 0),
+  (current_date, '177c56a4-57b4-4baf-a1a7-04f3d8e5b276', 8, 0, 6);
+
+INSERT INTO ClientUsage (day, clientUUID, tokensIssued, tokenValidations) VALUES
+  (current_date, '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 25, 40),
+  (current_date - 1, '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 12, 20),
+  (current_date - 60, '8b14d6bb-cae7-4163-bbd1-f3be46e43e31', 100, 0);
+
+-- account-create was last used a year ago, account-admin never
+INSERT INTO ScopeUsage (day, scope, tokenValidations) VALUES
+  (current_date, 'repo-read', 40),
+  (current_date - 1, 'repo-read', 20),
+  (current_date - 60, 'repo-write', 10),
+  (current_date - 60, 'account-read', 5),
+  (current_date - 60, 'account-write', 3),
+  (current_date - 365, 'account-create', 2);
+
+-- Alice logs in via magic links, one link is valid and one is expired
+UPDATE Accounts SET isMagicLinkEnabled = TRUE WHERE login = 'alice';
+INSERT INTO MagicLinks (token, accountUUID, grantRequest, expires, createdAt) VALUES
+  ('M4G1CL1K', 'bf431618-f696-4dca-a95d-882618ce4ef9', 'U7JIKKYI', now() + INTERVAL '10 minutes', now()),
+  ('EXP1R3DL', 'bf431618-f696-4dca-a95d-882618ce4ef9', 'QH92T99D', now() - INTERVAL '5 minutes', now() - INTERVAL '20 minutes');
+
+INSERT INTO EmailBounces (email, bounceType, reason, count, isSuppressed, createdAt, updatedAt) VALUES
+  ('jj@example.com', 'hard', '550 5.1.1 User unknown', 1, TRUE, now(), now()),
+  ('bob@foo.com', 'soft', '452 4.2.2 Mailbox full', 1, FALSE, now(), now());
+UPDATE Accounts SET isEmailBouncing = TRUE WHERE login = 'john';
+
+-- Bob collects notifications in a daily digest, one of them is due
+UPDATE Accounts SET notificationMode = 'daily' WHERE login = 'bob';
+-- Bob does not want to receive announcements
+UPDATE Accounts SET isAnnouncementOptOut = TRUE WHERE login = 'bob';
+INSERT INTO Notifications (accountUUID, subject, body, createdAt) VALUES
+  ('51f5ac36-d332-4889-8023-6e033fcd8e17', 'First notification', 'Something happened', now() - INTERVAL '25 hours'),
+  ('51f5ac36-d332-4889-8023-6e033fcd8e17', 'Second notification', 'Something else happened', now() - INTERVAL '1 hour'),
+  ('bf431618-f696-4dca-a95d-882618ce4ef9', 'Alice notification', 'Something happened', now());
+
+INSERT INTO AccountNotes (accountUUID, notes, labels, updatedBy, createdAt, updatedAt) VALUES
+  ('bf431618-f696-4dca-a95d-882618ce4ef9', 'Member of the LMU neuroscience group', '{"verified researcher"}', '51f5ac36-d332-4889-8023-6e033fcd8e17', now(), now()),
+  ('03dcd573-1cce-4eb1-8b33-73860575da65', '', '{"spam-suspect","institutional"}', '51f5ac36-d332-4889-8023-6e033fcd8e17', now(), now());
+
+-- A saved filter for accounts which are unverified for more than 30 days (john)
+INSERT INTO AccountFilters (name, description, emailVerified, createdDays, updatedBy, createdAt, updatedAt) VALUES
+  ('unverified-30-days', 'Unverified for more than 30 days', FALSE, 30, '51f5ac36-d332-4889-8023-6e033fcd8e17', now(), now());
+
+-- Alice owns the group lmu-neuro with bob as member, john owns its sub-team lmu-neuro-ephys
+INSERT INTO Groups (uuid, name, description, parentUUID, createdAt, updatedAt) VALUES
+  ('6f2a9c1e-4b7d-4e3a-9f1c-2d8e5b7a3c10', 'lmu-neuro', 'LMU neuroscience group', NULL, now() - INTERVAL '1 day', now()),
+  ('0c4e8b2a-7d1f-4a6e-b3c9-5e2f8a1d7b46', 'lmu-neuro-ephys', 'Electrophysiology team', '6f2a9c1e-4b7d-4e3a-9f1c-2d8e5b7a3c10', now(), now());
+INSERT INTO GroupMembers (groupUUID, accountUUID, role, createdAt, updatedAt) VALUES
+  ('6f2a9c1e-4b7d-4e3a-9f1c-2d8e5b7a3c10', 'bf431618-f696-4dca-a95d-882618ce4ef9', 'owner', now(), now()),
+  ('6f2a9c1e-4b7d-4e3a-9f1c-2d8e5b7a3c10', '51f5ac36-d332-4889-8023-6e033fcd8e17', 'member', now(), now()),
+  ('0c4e8b2a-7d1f-4a6e-b3c9-5e2f8a1d7b46', '03dcd573-1cce-4eb1-8b33-73860575da65', 'owner', now(), now());
+-- John invited Alice to join lmu-neuro-ephys
+INSERT INTO GroupInvitations (groupUUID, accountUUID, role, invitedBy, expires, createdAt, updatedAt) VALUES
+  ('0c4e8b2a-7d1f-4a6e-b3c9-5e2f8a1d7b46', 'bf431618-f696-4dca-a95d-882618ce4ef9', 'member', '03dcd573-1cce-4eb1-8b33-73860575da65', 'tomorrow', now(), now());
+
+-- Feature flag set by an administrator (bob), overriding the flags of the server configuration
+INSERT INTO FeatureFlags (name, enabled, percentage, accounts, updatedBy, createdAt, updatedAt) VALUES
+  ('stored-feature', FALSE, 0, '{bob}', '51f5ac36-d332-4889-8023-6e033fcd8e17', '2016-01-01 01:00:00', '2016-01-01 01:00:00');
@@ -0,0 +1,28 @@
+// Redirects the browser after all front-channel logout frames were loaded,
+// but waits at most three seconds for slow clients.
+(function() {
+    var target = document.getElementById('logout-redirect').getAttribute('data-redirect');
+    var frames = document.getElementsByClassName('frontchannel-logout');
+    var pending = frames.length;
+    var done = false;
+
+    function redirect() {
+        if (!done) {
+            done = true;
+            window.location.href = target;
+        }
+    }
+
+    for (var i = 0; i < frames.length; i++) {
+        frames[i].addEventListener('load', function() {
+            pending--;
+            if (pending <= 0) {
+                redirect();
+            }
+        });
+    }
+    if (pending === 0) {
+        redirect();
+    }
+    setTimeout(redirect, 3000);
+})();
@@ -0,0 +1,54 @@
+// Shows the estimated strength of the password entered into inputs with a data-strength-url attribute.
+// Login, e-mail and name entered in the same form are sent along, since passwords containing them
+// are easy to guess.
+var strengthLabels = ['Very weak', 'Weak', 'Fair', 'Strong', 'Very strong'];
+
+function checkStrength() {
+    var input = $(this);
+    var form = input.closest('form');
+    var group = input.closest('.form-group');
+    group.find('.strength-block').remove();
+    if (input.val() === '') {
+        return;
+    }
+    var params = {password: input.val()};
+    ['login', 'email', 'first_name', 'last_name'].forEach(function(name) {
+        var field = form.find('[name="' + name + '"]');
+        if (field.length > 0) {
+            params[name] = field.val();
+        }
+    });
+    $.ajax({
+        url: input.attr('data-strength-url'),
+        type: 'POST',
+        contentType: 'application/json',
+        data: JSON.stringify(params),
+        dataType: 'json'
+    }).done(function(result) {
+        if (input.val() !== params.password) {
+            return;
+        }
+        var text = strengthLabels[result.score];
+        if (result.message) {
+            text += ': ' + result.message;
+        } else if (result.warning) {
+            text += ': ' + result.warning;
+        }
+        group.toggleClass('has-error', !result.acceptable);
+        group.toggleClass('has-success', result.acceptable && result.score >= 3);
+        var block = $('<span class="help-block strength-block" aria-live="polite"></span>').text(text);
+        if (result.suggestions.length > 0) {
+            block.append($('<br>'), document.createTextNode(result.suggestions.join('. ') + '.'));
+        }
+        input.after(block);
+    });
+}
+
+$(document).ready(function() {
+    var timeout;
+    $('[data-strength-url]').on('input', function() {
+        var input = this;
+        clearTimeout(timeout);
+        timeout = setTimeout(function() { checkStrength.call(input); }, 300);
+    });
+});
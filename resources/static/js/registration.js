@@ -0,0 +1,37 @@
+// Reloads the captcha image of the registration or login form. Without JavaScript the
+// reload link loads the page again, which shows a new captcha.
+function reloadCaptcha(event) {
+    event.preventDefault();
+    var id = document.getElementById('reg-captcha-id').value;
+    var image = document.getElementById('reg-image');
+    var base = image.getAttribute('data-captcha-url');
+    var xmlHttp = new XMLHttpRequest();
+    xmlHttp.open("GET", base + id + ".png?reload=" + new Date().getTime());
+    xmlHttp.send();
+    image.src = base + id + ".png?" + new Date().getTime();
+}
+
+// Checks whether the value of a login or e-mail input is available and marks the input if not.
+function checkAvailability() {
+    var input = $(this);
+    var group = input.closest('.form-group');
+    group.find('.check-block').remove();
+    if (input.val() === '') {
+        return;
+    }
+    var params = {};
+    params[input.attr('name')] = input.val();
+    $.getJSON(input.attr('data-check-url'), params, function(result) {
+        if (result[input.attr('name')] === false) {
+            group.addClass('has-error');
+            input.after($('<span class="help-block check-block"></span>').text(input.attr('data-check-message')));
+        } else {
+            group.removeClass('has-error');
+        }
+    });
+}
+
+$(document).ready(function() {
+    $('[data-check-url]').on('change', checkAvailability);
+    $('[data-captcha-reload]').on('click', reloadCaptcha);
+});
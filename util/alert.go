@@ -0,0 +1,152 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+// Kinds of events which may cause an alert
+const (
+	AlertFailedLogin    = "failed-login"
+	AlertTokenIssued    = "token-issued"
+	AlertCleanerFailure = "cleaner-failure"
+	AlertSmtpFailure    = "smtp-failure"
+)
+
+// Alert is a notification for operators about an anomaly.
+type Alert struct {
+	Kind    string    `json:"kind"`
+	Count   int       `json:"count"`
+	Message string    `json:"message"`
+	Time    time.Time `json:"time"`
+}
+
+type alerter struct {
+	lock    sync.Mutex
+	events  map[string][]time.Time
+	alerted map[string]time.Time
+	sent    []time.Time
+	notify  func(alert *Alert)
+}
+
+var alerts = &alerter{
+	events:  make(map[string][]time.Time),
+	alerted: make(map[string]time.Time),
+	notify:  notifyOperators,
+}
+
+// RecordEvent records an event of a certain kind. If the number of recorded events of this kind
+// within the alerting window reaches the configured threshold, operators are notified in the
+// background. Detail describes the latest event and is included in the alert message.
+func RecordEvent(kind, detail string) {
+	config := conf.GetAlerting()
+	threshold := config.Thresholds[kind]
+	if threshold <= 0 {
+		return
+	}
+
+	alert := alerts.record(kind, detail, threshold, config, time.Now())
+	if alert != nil {
+		go alerts.notify(alert)
+	}
+}
+
+// record adds an event and returns an alert if one should be sent.
+func (a *alerter) record(kind, detail string, threshold int, config *conf.Alerting, now time.Time) *Alert {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// keep only the latest events within the window, but not more than needed to reach the threshold
+	events := append(a.events[kind], now)
+	for len(events) > 0 && (now.Sub(events[0]) > config.Window || len(events) > threshold) {
+		events = events[1:]
+	}
+	a.events[kind] = events
+	if len(events) < threshold {
+		return nil
+	}
+
+	if last, ok := a.alerted[kind]; ok && now.Sub(last) < config.Dedup {
+		return nil
+	}
+	for len(a.sent) > 0 && now.Sub(a.sent[0]) > time.Hour {
+		a.sent = a.sent[1:]
+	}
+	if len(a.sent) >= config.MaxPerHour {
+		return nil
+	}
+	a.alerted[kind] = now
+	a.sent = append(a.sent, now)
+
+	return &Alert{
+		Kind:    kind,
+		Count:   len(events),
+		Message: fmt.Sprintf("%d '%s' events within %s, latest: %s", len(events), kind, config.Window, detail),
+		Time:    now,
+	}
+}
+
+// notifyOperators sends an alert to the configured e-mail address and webhook.
+func notifyOperators(alert *Alert) {
+	config := conf.GetAlerting()
+	logErr := conf.GetLogEnv().Err
+
+	if config.Email != "" {
+		fields := &struct {
+			From    string
+			To      string
+			Subject string
+			Body    string
+		}{
+			conf.GetSmtpCredentials().From,
+			config.Email,
+			"GIN alert: " + alert.Kind,
+			alert.Message,
+		}
+		content := MakeEmailTemplate("emailplain.txt", fields)
+		err := NewEmailDispatcher().Send([]string{config.Email}, content.Bytes())
+		if err != nil {
+			logErr.Errorf("Error sending alert e-mail: %s", err.Error())
+		}
+	}
+
+	if config.Webhook != "" {
+		err := sendWebhook(config.Webhook, alert)
+		if err != nil {
+			logErr.Errorf("Error sending alert to webhook: %s", err.Error())
+		}
+	}
+}
+
+// sendWebhook posts an alert as JSON to a webhook URL.
+func sendWebhook(url string, alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	res, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("Webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func TestAlerterRecord(t *testing.T) {
+	config := &conf.Alerting{Window: 10 * time.Minute, Dedup: time.Hour, MaxPerHour: 2}
+	a := &alerter{events: make(map[string][]time.Time), alerted: make(map[string]time.Time)}
+	now := time.Now()
+
+	// threshold not reached within window
+	a.record(AlertFailedLogin, "alice", 3, config, now.Add(-20*time.Minute))
+	a.record(AlertFailedLogin, "alice", 3, config, now.Add(-1*time.Minute))
+	if alert := a.record(AlertFailedLogin, "alice", 3, config, now); alert != nil {
+		t.Error("Events outside the window should not be counted")
+	}
+
+	// threshold reached
+	alert := a.record(AlertFailedLogin, "bob", 3, config, now)
+	if alert == nil || alert.Count != 3 || alert.Kind != AlertFailedLogin {
+		t.Fatal("Alert expected")
+	}
+
+	// dedup
+	if alert := a.record(AlertFailedLogin, "bob", 3, config, now.Add(time.Minute)); alert != nil {
+		t.Error("Alert should not be repeated within dedup interval")
+	}
+	if len(a.events[AlertFailedLogin]) != 3 {
+		t.Error("Not more events than the threshold should be kept")
+	}
+
+	// rate limit
+	if alert := a.record(AlertSmtpFailure, "error", 1, config, now); alert == nil {
+		t.Error("Alert of another kind expected")
+	}
+	if alert := a.record(AlertCleanerFailure, "error", 1, config, now); alert != nil {
+		t.Error("Alert should be rate limited")
+	}
+	if alert := a.record(AlertCleanerFailure, "error", 1, config, now.Add(2*time.Hour)); alert == nil {
+		t.Error("Alert expected after rate limit interval")
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	var received Alert
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	err := sendWebhook(server.URL, &Alert{Kind: AlertTokenIssued, Count: 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.Kind != AlertTokenIssued || received.Count != 500 {
+		t.Error("Alert was not properly sent")
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	err = sendWebhook(failing.URL, &Alert{})
+	if err == nil {
+		t.Error("Webhook error expected")
+	}
+}
@@ -0,0 +1,380 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/Sirupsen/logrus"
+)
+
+// Number of attempts to deliver a batch of audit events before it is dropped
+const auditExportAttempts = 3
+
+// AuditEvent is an entry of the audit log as exported to a SIEM.
+type AuditEvent struct {
+	Time    time.Time              `json:"time"`
+	Level   string                 `json:"level"`
+	Message string                 `json:"message"`
+	Fields  map[string]interface{} `json:"fields"`
+}
+
+// AuditSender delivers a batch of audit events to an external collector.
+type AuditSender interface {
+	Send(events []AuditEvent) error
+}
+
+// AuditExporter is a logrus hook which exports the entries of the audit log in the background.
+// Events are buffered and sent in batches. If the buffer is full, events are dropped and counted
+// or, in blocking mode, logging waits until there is room in the buffer.
+type AuditExporter struct {
+	sender        AuditSender
+	events        chan AuditEvent
+	batchSize     int
+	flushInterval time.Duration
+	block         bool
+	dropped       uint64
+	reported      uint64 // dropped events which were already reported, only used by run
+	closeOnce     sync.Once
+	done          chan struct{}
+}
+
+// NewAuditExporter creates an exporter which delivers events with the given sender according
+// to the audit export settings and starts the delivery in the background.
+func NewAuditExporter(sender AuditSender, config *conf.AuditExport) *AuditExporter {
+	e := &AuditExporter{
+		sender:        sender,
+		events:        make(chan AuditEvent, config.BufferSize),
+		batchSize:     config.BatchSize,
+		flushInterval: config.FlushInterval,
+		block:         config.Block,
+		done:          make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// NewAuditSender creates the sender for the target of the audit export settings. Version is
+// the version of gin-auth reported in CEF events.
+func NewAuditSender(config *conf.AuditExport, version string) (AuditSender, error) {
+	switch config.Target {
+	case conf.AuditExportSyslog:
+		u, err := url.Parse(config.Address)
+		if err != nil || (u.Scheme != "tcp" && u.Scheme != "udp") || u.Host == "" {
+			return nil, fmt.Errorf("Invalid syslog address '%s'", config.Address)
+		}
+		hostname, _ := os.Hostname()
+		return &SyslogSender{Network: u.Scheme, Address: u.Host, Format: config.Format, Version: version,
+			Hostname: hostname, Timeout: config.Timeout}, nil
+	case conf.AuditExportHTTP:
+		if config.URL == "" {
+			return nil, fmt.Errorf("No URL for the audit export configured")
+		}
+		return &HTTPAuditSender{URL: config.URL, Token: config.Token, Format: config.Format, Version: version,
+			client: &http.Client{Timeout: config.Timeout}}, nil
+	}
+	return nil, fmt.Errorf("Unknown audit export target '%s'", config.Target)
+}
+
+// Levels implements logrus.Hook, all entries of the audit log are exported.
+func (e *AuditExporter) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire implements logrus.Hook.
+func (e *AuditExporter) Fire(entry *logrus.Entry) error {
+	event := AuditEvent{
+		Time:    entry.Time,
+		Level:   entry.Level.String(),
+		Message: entry.Message,
+		Fields:  make(map[string]interface{}, len(entry.Data)),
+	}
+	for k, v := range entry.Data {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		event.Fields[k] = v
+	}
+
+	if e.block {
+		e.events <- event
+		return nil
+	}
+	select {
+	case e.events <- event:
+	default:
+		atomic.AddUint64(&e.dropped, 1)
+	}
+	return nil
+}
+
+// Dropped returns the number of events which were dropped because the buffer was full
+// or because they could not be delivered.
+func (e *AuditExporter) Dropped() uint64 {
+	return atomic.LoadUint64(&e.dropped)
+}
+
+// Close delivers all buffered events and stops the exporter. Events logged after Close are lost.
+func (e *AuditExporter) Close() {
+	e.closeOnce.Do(func() {
+		close(e.events)
+		<-e.done
+	})
+}
+
+// run collects events into batches and delivers them, until the exporter is closed.
+func (e *AuditExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]AuditEvent, 0, e.batchSize)
+	for {
+		select {
+		case event, ok := <-e.events:
+			if !ok {
+				e.deliver(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) < e.batchSize {
+				continue
+			}
+		case <-ticker.C:
+		}
+		e.deliver(batch)
+		batch = batch[:0]
+		e.reportDropped()
+	}
+}
+
+// reportDropped logs a warning if events were dropped since the last report.
+func (e *AuditExporter) reportDropped() {
+	dropped := e.Dropped()
+	if dropped > e.reported {
+		conf.GetLogEnv().Err.Warnf("%d audit events were not exported (%d in total)", dropped-e.reported, dropped)
+		e.reported = dropped
+	}
+}
+
+// deliver sends a batch of events with retries. While the exporter retries, events pile up
+// in the buffer, thus a failing collector slows down logging in blocking mode.
+func (e *AuditExporter) deliver(batch []AuditEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var err error
+	for attempt := 0; attempt < auditExportAttempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(1<<uint(attempt-1)) * time.Second)
+		}
+		if err = e.sender.Send(batch); err == nil {
+			return
+		}
+	}
+
+	atomic.AddUint64(&e.dropped, uint64(len(batch)))
+	conf.GetLogEnv().Err.Errorf("Unable to export %d audit events: %s", len(batch), err.Error())
+}
+
+// SyslogSender sends audit events to a syslog server as RFC 5424 messages of the facility
+// 'security/authorization' with either a JSON or a CEF formatted message.
+type SyslogSender struct {
+	Network  string // tcp or udp
+	Address  string
+	Format   string
+	Version  string
+	Hostname string
+	Timeout  time.Duration
+	conn     net.Conn
+}
+
+// Send implements AuditSender. The connection is kept open and reestablished on errors.
+func (s *SyslogSender) Send(events []AuditEvent) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.Network, s.Address, s.Timeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+
+	for _, event := range events {
+		msg, err := formatAuditEvent(&event, s.Format, s.Version)
+		if err != nil {
+			return err
+		}
+		line := fmt.Sprintf("<%d>1 %s %s gin-auth - - - %s\n", 10*8+syslogSeverity(event.Level),
+			event.Time.UTC().Format(time.RFC3339Nano), nilValue(s.Hostname), msg)
+
+		s.conn.SetWriteDeadline(time.Now().Add(s.Timeout))
+		_, err = s.conn.Write([]byte(line))
+		if err != nil {
+			s.conn.Close()
+			s.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+// syslogSeverity maps logrus levels to syslog severities.
+func syslogSeverity(level string) int {
+	switch level {
+	case "panic", "fatal":
+		return 2
+	case "error":
+		return 3
+	case "warning":
+		return 4
+	case "info":
+		return 6
+	}
+	return 7
+}
+
+// nilValue returns the RFC 5424 nil value for empty header fields.
+func nilValue(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// HTTPAuditSender posts batches of audit events to an HTTP collector. JSON events are posted as
+// JSON array, CEF events as plain text with one event per line.
+type HTTPAuditSender struct {
+	URL     string
+	Token   string
+	Format  string
+	Version string
+	client  *http.Client
+}
+
+// Send implements AuditSender.
+func (s *HTTPAuditSender) Send(events []AuditEvent) error {
+	body := &bytes.Buffer{}
+	contentType := "application/json"
+	if s.Format == conf.AuditFormatCEF {
+		contentType = "text/plain"
+		for i := range events {
+			line, err := formatAuditEvent(&events[i], s.Format, s.Version)
+			if err != nil {
+				return err
+			}
+			body.WriteString(line)
+			body.WriteString("\n")
+		}
+	} else {
+		err := json.NewEncoder(body).Encode(events)
+		if err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequest("POST", s.URL, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", contentType)
+	if s.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+	res, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("Audit collector responded with status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// formatAuditEvent formats a single event as JSON or CEF.
+func formatAuditEvent(event *AuditEvent, format, version string) (string, error) {
+	if format == conf.AuditFormatCEF {
+		return FormatCEF(event, version), nil
+	}
+	b, err := json.Marshal(event)
+	return string(b), err
+}
+
+// Fields of audit events which map to CEF extension keys. The login is the source user,
+// unless an administrator acted on the account.
+var cefKeys = map[string]string{
+	"ip":     "src",
+	"login":  "suser",
+	"admin":  "suser",
+	"client": "app",
+}
+
+var cefKeyRegex = regexp.MustCompile(`[^a-zA-Z0-9]`)
+
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+var cefValueEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+
+// FormatCEF formats an audit event in the ArcSight Common Event Format. The signature ID is
+// the event field of the audit entry, fields without a standard CEF key keep their name.
+func FormatCEF(event *AuditEvent, version string) string {
+	signature, _ := event.Fields["event"].(string)
+	if signature == "" {
+		signature = "audit"
+	}
+
+	ext := []string{fmt.Sprintf("rt=%d", event.Time.UnixNano()/int64(time.Millisecond))}
+	names := make([]string, 0, len(event.Fields))
+	for name := range event.Fields {
+		if name != "event" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		key, ok := cefKeys[name]
+		if _, isAdmin := event.Fields["admin"]; name == "login" && isAdmin {
+			key = "duser"
+		} else if !ok {
+			key = cefKeyRegex.ReplaceAllString(name, "")
+		}
+		ext = append(ext, key+"="+cefValueEscaper.Replace(fmt.Sprint(event.Fields[name])))
+	}
+
+	return fmt.Sprintf("CEF:0|G-Node|gin-auth|%s|%s|%s|%d|%s",
+		cefHeaderEscaper.Replace(version), cefHeaderEscaper.Replace(signature),
+		cefHeaderEscaper.Replace(event.Message), cefSeverity(event.Level), strings.Join(ext, " "))
+}
+
+// cefSeverity maps logrus levels to CEF severities (0-10).
+func cefSeverity(level string) int {
+	switch level {
+	case "panic", "fatal":
+		return 10
+	case "error":
+		return 7
+	case "warning":
+		return 5
+	}
+	return 3
+}
@@ -0,0 +1,256 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/Sirupsen/logrus"
+)
+
+// testAuditSender records all delivered batches. If wait is set, Send blocks until it is closed.
+type testAuditSender struct {
+	lock    sync.Mutex
+	batches [][]AuditEvent
+	wait    chan struct{}
+}
+
+func (s *testAuditSender) Send(events []AuditEvent) error {
+	if s.wait != nil {
+		<-s.wait
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.batches = append(s.batches, append([]AuditEvent{}, events...))
+	return nil
+}
+
+func (s *testAuditSender) events() []AuditEvent {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	events := make([]AuditEvent, 0)
+	for _, b := range s.batches {
+		events = append(events, b...)
+	}
+	return events
+}
+
+func testAuditLogger(exporter *AuditExporter) *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(exporter)
+	return logger
+}
+
+func TestAuditExporter(t *testing.T) {
+	sender := &testAuditSender{}
+	config := &conf.AuditExport{BufferSize: 100, BatchSize: 3, FlushInterval: time.Hour}
+	exporter := NewAuditExporter(sender, config)
+	logger := testAuditLogger(exporter)
+
+	for i := 0; i < 7; i++ {
+		logger.WithFields(logrus.Fields{"event": "login", "login": "alice"}).Info("Login")
+	}
+	exporter.Close()
+
+	events := sender.events()
+	if len(events) != 7 {
+		t.Fatalf("Seven events expected but were %d", len(events))
+	}
+	if len(sender.batches) != 3 || len(sender.batches[0]) != 3 || len(sender.batches[2]) != 1 {
+		t.Errorf("Events expected in batches of three")
+	}
+	if events[0].Message != "Login" || events[0].Level != "info" || events[0].Fields["login"] != "alice" {
+		t.Errorf("Unexpected event: %+v", events[0])
+	}
+}
+
+func TestAuditExporterFlush(t *testing.T) {
+	sender := &testAuditSender{}
+	config := &conf.AuditExport{BufferSize: 100, BatchSize: 100, FlushInterval: 10 * time.Millisecond}
+	exporter := NewAuditExporter(sender, config)
+	defer exporter.Close()
+	logger := testAuditLogger(exporter)
+
+	logger.WithField("event", "logout").Info("Logout")
+	time.Sleep(100 * time.Millisecond)
+	if len(sender.events()) != 1 {
+		t.Error("Event expected to be delivered after the flush interval")
+	}
+}
+
+func TestAuditExporterBackpressure(t *testing.T) {
+	// collector does not respond, events are dropped
+	sender := &testAuditSender{wait: make(chan struct{})}
+	config := &conf.AuditExport{BufferSize: 2, BatchSize: 1, FlushInterval: time.Hour}
+	exporter := NewAuditExporter(sender, config)
+	logger := testAuditLogger(exporter)
+
+	for i := 0; i < 10; i++ {
+		logger.Info("Event")
+	}
+	if exporter.Dropped() < 7 {
+		t.Errorf("At least seven dropped events expected but were %d", exporter.Dropped())
+	}
+	close(sender.wait)
+	exporter.Close()
+	if n := uint64(len(sender.events())) + exporter.Dropped(); n != 10 {
+		t.Errorf("All events expected to be either delivered or dropped but were %d", n)
+	}
+
+	// blocking mode
+	sender = &testAuditSender{wait: make(chan struct{})}
+	config.Block = true
+	exporter = NewAuditExporter(sender, config)
+	logger = testAuditLogger(exporter)
+
+	logged := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			logger.Info("Event")
+		}
+		close(logged)
+	}()
+	select {
+	case <-logged:
+		t.Error("Logging expected to wait for the exporter")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(sender.wait)
+	<-logged
+	exporter.Close()
+	if len(sender.events()) != 10 || exporter.Dropped() != 0 {
+		t.Errorf("All events expected to be delivered but were %d", len(sender.events()))
+	}
+}
+
+func TestFormatCEF(t *testing.T) {
+	event := &AuditEvent{
+		Time:    time.Unix(1451610000, 0),
+		Level:   "warning",
+		Message: "Account a|b deleted",
+		Fields:  map[string]interface{}{"event": "account-deleted", "login": "alice", "admin": "bob", "ip": "10.0.0.1", "reason": "a=b\nc"},
+	}
+	cef := FormatCEF(event, "0.1")
+	expected := `CEF:0|G-Node|gin-auth|0.1|account-deleted|Account a\|b deleted|5|rt=1451610000000 suser=bob src=10.0.0.1 duser=alice reason=a\=b\nc`
+	if cef != expected {
+		t.Errorf("Unexpected CEF:\n%s\n%s", cef, expected)
+	}
+
+	event.Fields = map[string]interface{}{"login": "alice"}
+	if cef = FormatCEF(event, "0.1"); !strings.Contains(cef, "|audit|") || !strings.HasSuffix(cef, "suser=alice") {
+		t.Errorf("Unexpected CEF: %s", cef)
+	}
+}
+
+func TestHTTPAuditSender(t *testing.T) {
+	var body []byte
+	var auth, contentType string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ = ioutil.ReadAll(r.Body)
+		auth = r.Header.Get("Authorization")
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer server.Close()
+
+	config := &conf.AuditExport{Target: conf.AuditExportHTTP, URL: server.URL + "/", Token: "secret", Format: conf.AuditFormatJSON, Timeout: time.Second}
+	sender, err := NewAuditSender(config, "0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := []AuditEvent{{Time: time.Now(), Level: "info", Message: "Login", Fields: map[string]interface{}{"login": "alice"}}}
+	err = sender.Send(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := make([]AuditEvent, 0)
+	json.Unmarshal(body, &decoded)
+	if len(decoded) != 1 || decoded[0].Fields["login"] != "alice" || auth != "Bearer secret" || contentType != "application/json" {
+		t.Errorf("Unexpected request: %s", string(body))
+	}
+
+	config.Format = conf.AuditFormatCEF
+	sender, _ = NewAuditSender(config, "0.1")
+	err = sender.Send(events)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(body), "CEF:0|G-Node|gin-auth|0.1|audit|Login|3|") || contentType != "text/plain" {
+		t.Errorf("Unexpected request: %s", string(body))
+	}
+
+	config.URL = server.URL + "/doesnotexist"
+	sender, _ = NewAuditSender(config, "0.1")
+	if err = sender.Send(events); err == nil {
+		t.Error("Error expected for a failing collector")
+	}
+}
+
+func TestSyslogSender(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	config := &conf.AuditExport{Target: conf.AuditExportSyslog, Address: "tcp://" + listener.Addr().String(), Format: conf.AuditFormatCEF, Timeout: time.Second}
+	sender, err := NewAuditSender(config, "0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sender.Send([]AuditEvent{
+		{Time: time.Now(), Level: "info", Message: "Login", Fields: map[string]interface{}{"event": "login"}},
+		{Time: time.Now(), Level: "error", Message: "Failed", Fields: map[string]interface{}{}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, prefix := range []string{"<86>1 ", "<83>1 "} {
+		select {
+		case line := <-lines:
+			if !strings.HasPrefix(line, prefix) || !strings.Contains(line, " gin-auth - - - CEF:0|G-Node|gin-auth|0.1|") {
+				t.Errorf("Unexpected syslog message: %s", line)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Syslog message expected")
+		}
+	}
+
+	config.Address = "localhost:514"
+	if _, err = NewAuditSender(config, "0.1"); err == nil {
+		t.Error("Error expected for an address without network")
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+// ErrBlobNotFound is returned if a blob with the requested key does not exist.
+var ErrBlobNotFound = errors.New("Blob does not exist")
+
+// BlobStore stores files like backups under keys consisting of path segments,
+// e.g. "backups/2016-11-03.json". Downloads are handed out as signed URLs which expire.
+type BlobStore interface {
+	Put(key string, content io.Reader, contentType string) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	SignedURL(key string, lifeTime time.Duration) (string, error)
+}
+
+// Keys consist of segments of letters, digits, dots, hyphens and underscores separated by slashes
+var blobKeyRegex = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9._-]*(/[a-zA-Z0-9_-][a-zA-Z0-9._-]*)*$`)
+
+// IsValidBlobKey checks whether a key can be used with all blob stores. Segments must not start
+// with a dot, such that keys can not point outside of the storage.
+func IsValidBlobKey(key string) bool {
+	return len(key) <= 1024 && blobKeyRegex.MatchString(key)
+}
+
+// NewBlobStore creates the blob store for the driver of the given settings. Signed URLs of the
+// local driver point to the route /blobs of the server at baseURL.
+func NewBlobStore(config *conf.BlobStorage, baseURL string) (BlobStore, error) {
+	switch config.Driver {
+	case conf.BlobStorageLocal:
+		if config.Path == "" || config.Secret == "" {
+			return nil, errors.New("The local blob storage requires Path and Secret")
+		}
+		err := os.MkdirAll(config.Path, 0700)
+		if err != nil {
+			return nil, err
+		}
+		return &LocalBlobStore{path: config.Path, secret: config.Secret, baseURL: strings.TrimSuffix(baseURL, "/")}, nil
+	case conf.BlobStorageS3:
+		if config.Endpoint == "" || config.Bucket == "" {
+			return nil, errors.New("The s3 blob storage requires Endpoint and Bucket")
+		}
+		return &S3BlobStore{
+			endpoint:  config.Endpoint,
+			region:    config.Region,
+			bucket:    config.Bucket,
+			accessKey: config.AccessKey,
+			secretKey: config.SecretKey,
+			pathStyle: config.PathStyle,
+			client:    newS3Client(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unknown blob storage driver '%s'", config.Driver)
+	}
+}
+
+var blobStore = struct {
+	sync.Mutex
+	store BlobStore
+}{}
+
+// GetBlobStore returns the blob store configured in server.yml, which is created on first use.
+// An error is returned if the configuration is incomplete, e.g. if no secret is set for the local
+// blob storage.
+func GetBlobStore() (BlobStore, error) {
+	blobStore.Lock()
+	defer blobStore.Unlock()
+
+	if blobStore.store == nil {
+		store, err := NewBlobStore(conf.GetBlobStorage(), conf.GetServerConfig().BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		blobStore.store = store
+	}
+	return blobStore.store, nil
+}
+
+// SetBlobStore replaces the configured blob store, e.g. by a store in a temporary directory
+// in tests, and returns the previous store. A nil store restores the configured store.
+func SetBlobStore(store BlobStore) BlobStore {
+	blobStore.Lock()
+	defer blobStore.Unlock()
+
+	previous := blobStore.store
+	blobStore.store = store
+	return previous
+}
+
+// LocalBlobStore stores blobs as files in a directory. Signed URLs point to the route which
+// serves blobs and contain an expiry time and an HMAC of key and expiry time.
+type LocalBlobStore struct {
+	path    string
+	secret  string
+	baseURL string
+}
+
+// file returns the path of the file containing the blob with the given key.
+func (s *LocalBlobStore) file(key string) (string, error) {
+	if !IsValidBlobKey(key) {
+		return "", fmt.Errorf("Invalid blob key '%s'", key)
+	}
+	return filepath.Join(s.path, filepath.FromSlash(key)), nil
+}
+
+// Put stores a blob, an existing blob with the same key is replaced. The content type is
+// derived from the extension of the key when the blob is served.
+func (s *LocalBlobStore) Put(key string, content io.Reader, contentType string) error {
+	file, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(file), 0700)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, such that readers never see partial blobs
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".upload-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+// Get opens the blob with the given key.
+func (s *LocalBlobStore) Get(key string) (io.ReadCloser, error) {
+	file, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, ErrBlobNotFound
+	}
+	return f, err
+}
+
+// Delete removes the blob with the given key.
+func (s *LocalBlobStore) Delete(key string) error {
+	file, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(file)
+	if os.IsNotExist(err) {
+		return ErrBlobNotFound
+	}
+	return err
+}
+
+// SignedURL returns a download URL for the blob which is valid for the given life time.
+func (s *LocalBlobStore) SignedURL(key string, lifeTime time.Duration) (string, error) {
+	if !IsValidBlobKey(key) {
+		return "", fmt.Errorf("Invalid blob key '%s'", key)
+	}
+	expires := strconv.FormatInt(Now().Add(lifeTime).Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", expires)
+	query.Set("signature", s.signature(key, expires))
+	return s.baseURL + "/blobs/" + key + "?" + query.Encode(), nil
+}
+
+// VerifyURL checks the signature and the expiry time of a signed URL.
+func (s *LocalBlobStore) VerifyURL(key, expires, signature string) bool {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || Now().Unix() > unix {
+		return false
+	}
+	return IsValidBlobKey(key) && EqualTokens(signature, s.signature(key, expires))
+}
+
+// signature returns the hex encoded HMAC-SHA256 of key and expiry time.
+func (s *LocalBlobStore) signature(key, expires string) string {
+	mac := hmac.New(sha256.New, []byte(s.secret))
+	mac.Write([]byte(key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func init() {
+	conf.UseTestConfig()
+}
+
+func TestIsValidBlobKey(t *testing.T) {
+	for _, key := range []string{"avatars/alice.png", "exports/2016-11-03/bob_data.zip", "a"} {
+		if !IsValidBlobKey(key) {
+			t.Errorf("Key '%s' expected to be valid", key)
+		}
+	}
+	for _, key := range []string{"", "/etc/passwd", "avatars/../secret", ".hidden", "avatars//alice", "a b", "avatars/"} {
+		if IsValidBlobKey(key) {
+			t.Errorf("Key '%s' expected to be invalid", key)
+		}
+	}
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := &conf.BlobStorage{Driver: conf.BlobStorageLocal, Path: dir, Secret: "secret"}
+	bs, err := NewBlobStore(config, "https://auth.example.org/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := bs.(*LocalBlobStore)
+	if _, err = NewBlobStore(&conf.BlobStorage{Driver: conf.BlobStorageLocal, Path: dir}, ""); err == nil {
+		t.Error("Error expected for a local blob storage without secret")
+	}
+
+	err = store.Put("avatars/alice.png", strings.NewReader("png data"), "image/png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Put("../alice.png", strings.NewReader("png data"), "image/png")
+	if err == nil {
+		t.Error("Error expected for a key outside of the storage")
+	}
+
+	r, err := store.Get("avatars/alice.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(r)
+	r.Close()
+	if string(content) != "png data" {
+		t.Errorf("Stored content expected but was '%s'", content)
+	}
+
+	signed, err := store.SignedURL("avatars/alice.png", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(signed, "https://auth.example.org/blobs/avatars/alice.png?") {
+		t.Errorf("URL of the blob route expected but was '%s'", signed)
+	}
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	if !store.VerifyURL("avatars/alice.png", expires, signature) {
+		t.Error("Signed URL expected to be valid")
+	}
+	if store.VerifyURL("avatars/bob.png", expires, signature) {
+		t.Error("Signature expected to be invalid for another key")
+	}
+	defer SetClock(SetClock(FixedClock(time.Now().Add(2 * time.Minute))))
+	if store.VerifyURL("avatars/alice.png", expires, signature) {
+		t.Error("Signed URL expected to be expired")
+	}
+
+	err = store.Delete("avatars/alice.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = store.Get("avatars/alice.png"); err != ErrBlobNotFound {
+		t.Errorf("ErrBlobNotFound expected but was %v", err)
+	}
+	if err = store.Delete("avatars/alice.png"); err != ErrBlobNotFound {
+		t.Errorf("ErrBlobNotFound expected but was %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BreachChecker defines an interface for looking up passwords in a dataset of breached passwords.
+type BreachChecker interface {
+	// Count returns how often the password appeared in breaches, zero if it is not known.
+	Count(password string) (int, error)
+}
+
+// rangeSource returns the suffixes and counts of all SHA-1 hashes (upper case hex) starting with
+// a five character prefix, as provided by the HaveIBeenPwned range API.
+type rangeSource interface {
+	hashRange(prefix string) (map[string]int, error)
+}
+
+// pwnedChecker implements BreachChecker with k-anonymity: only the first five characters
+// of the SHA-1 hash of a password leave the checker.
+type pwnedChecker struct {
+	source rangeSource
+	ttl    time.Duration
+	lock   sync.Mutex
+	cache  map[string]cachedRange
+}
+
+type cachedRange struct {
+	hashes  map[string]int
+	expires time.Time
+}
+
+// Maximum number of hash ranges kept in the cache
+const maxCachedRanges = 1000
+
+// NewPwnedAPIChecker returns a BreachChecker querying a HaveIBeenPwned compatible range API,
+// e.g. "https://api.pwnedpasswords.com/range/". Ranges are cached for ttl.
+func NewPwnedAPIChecker(apiURL string, timeout, ttl time.Duration) BreachChecker {
+	return &pwnedChecker{
+		source: &apiRangeSource{url: strings.TrimSuffix(apiURL, "/") + "/", client: &http.Client{Timeout: timeout}},
+		ttl:    ttl,
+		cache:  make(map[string]cachedRange),
+	}
+}
+
+// NewPwnedDirChecker returns a BreachChecker reading an offline copy of the HaveIBeenPwned dataset
+// from a directory containing one file per range, named by the five character prefix (e.g. "21BD1"
+// or "21BD1.txt") in the format of the range API. Ranges are cached for ttl.
+func NewPwnedDirChecker(dir string, ttl time.Duration) BreachChecker {
+	return &pwnedChecker{
+		source: &dirRangeSource{dir: dir},
+		ttl:    ttl,
+		cache:  make(map[string]cachedRange),
+	}
+}
+
+// Count implements BreachChecker.
+func (c *pwnedChecker) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	c.lock.Lock()
+	cached, ok := c.cache[prefix]
+	c.lock.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.hashes[suffix], nil
+	}
+
+	hashes, err := c.source.hashRange(prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	c.lock.Lock()
+	if len(c.cache) >= maxCachedRanges {
+		for p, r := range c.cache {
+			if time.Now().After(r.expires) || len(c.cache) >= maxCachedRanges {
+				delete(c.cache, p)
+			}
+		}
+	}
+	c.cache[prefix] = cachedRange{hashes: hashes, expires: time.Now().Add(c.ttl)}
+	c.lock.Unlock()
+
+	return hashes[suffix], nil
+}
+
+type apiRangeSource struct {
+	url    string
+	client *http.Client
+}
+
+func (s *apiRangeSource) hashRange(prefix string) (map[string]int, error) {
+	req, err := http.NewRequest("GET", s.url+prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "gin-auth")
+	req.Header.Set("Add-Padding", "true")
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Breached password API responded with status %d", res.StatusCode)
+	}
+	return parseHashRange(res.Body)
+}
+
+type dirRangeSource struct {
+	dir string
+}
+
+func (s *dirRangeSource) hashRange(prefix string) (map[string]int, error) {
+	f, err := os.Open(filepath.Join(s.dir, prefix))
+	if os.IsNotExist(err) {
+		f, err = os.Open(filepath.Join(s.dir, prefix+".txt"))
+	}
+	if os.IsNotExist(err) {
+		return make(map[string]int), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseHashRange(f)
+}
+
+// parseHashRange reads lines of the form "<hash suffix>:<count>". Padding entries with
+// a count of zero are skipped.
+func parseHashRange(r io.Reader) (map[string]int, error) {
+	hashes := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		parts := strings.SplitN(strings.TrimSpace(scanner.Text()), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		count, err := strconv.Atoi(parts[1])
+		if err != nil || count == 0 {
+			continue
+		}
+		hashes[strings.ToUpper(parts[0])] = count
+	}
+	return hashes, scanner.Err()
+}
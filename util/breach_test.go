@@ -0,0 +1,81 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+const pwnedRange = "1E4C9B93F3F0682250B6CF8331B7EE68FD8:3730471\r\n011053FD0102E94D6AE2F8B83D76FAF94F6:1\r\n0000000000000000000000000000000000A:0\r\n"
+
+func TestPwnedAPIChecker(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/range/5BAA6" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		fmt.Fprint(w, pwnedRange)
+	}))
+	defer server.Close()
+
+	checker := NewPwnedAPIChecker(server.URL+"/range", time.Second, time.Hour)
+	count, err := checker.Count("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3730471 {
+		t.Errorf("Password expected to be breached 3730471 times but was %d", count)
+	}
+
+	checker.Count("password")
+	if requests != 1 {
+		t.Errorf("Range expected to be cached, but %d requests were sent", requests)
+	}
+
+	count, err = checker.Count("correct horse battery staple gin")
+	if err != nil || count != 0 {
+		t.Errorf("Unknown password expected to have count 0 but was %d (%v)", count, err)
+	}
+
+	server.Close()
+	_, err = NewPwnedAPIChecker(server.URL, time.Second, time.Hour).Count("password")
+	if err == nil {
+		t.Error("Unavailable API should fail")
+	}
+}
+
+func TestPwnedDirChecker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pwned")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte(pwnedRange), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checker := NewPwnedDirChecker(dir, time.Hour)
+	if count, err := checker.Count("password"); err != nil || count != 3730471 {
+		t.Errorf("Password expected to be breached 3730471 times but was %d (%v)", count, err)
+	}
+	if count, err := checker.Count("not in the dataset"); err != nil || count != 0 {
+		t.Errorf("Missing range expected to have count 0 but was %d (%v)", count, err)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock returns the current time.
+type Clock func() time.Time
+
+var clock = struct {
+	sync.Mutex
+	now Clock
+}{now: time.Now}
+
+// Now returns the current time of the clock used for expiry checks.
+func Now() time.Time {
+	clock.Lock()
+	now := clock.now
+	clock.Unlock()
+
+	return now()
+}
+
+// SetClock replaces the clock used for expiry checks, e.g. in order to test expiry without
+// waiting, and returns the previous clock. A nil clock restores the system clock.
+func SetClock(c Clock) Clock {
+	clock.Lock()
+	defer clock.Unlock()
+
+	if c == nil {
+		c = time.Now
+	}
+	previous := clock.now
+	clock.now = c
+	return previous
+}
+
+// FixedClock returns a clock which always returns the given time.
+func FixedClock(t time.Time) Clock {
+	return func() time.Time { return t }
+}
@@ -0,0 +1,33 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetClock(t *testing.T) {
+	fixed := time.Date(2016, 1, 1, 12, 0, 0, 0, time.UTC)
+	previous := SetClock(FixedClock(fixed))
+	if !Now().Equal(fixed) {
+		t.Errorf("Time expected to be %s but was %s", fixed, Now())
+	}
+
+	SetClock(previous)
+	if Now().Sub(time.Now()) > time.Second || time.Now().Sub(Now()) > time.Second {
+		t.Error("Previous clock expected to be restored")
+	}
+
+	SetClock(FixedClock(fixed))
+	SetClock(nil)
+	if Now().Equal(fixed) {
+		t.Error("System clock expected to be restored")
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Headers which are signed if present in an e-mail
+var dkimSignedHeaders = []string{
+	"from", "to", "cc", "reply-to", "subject", "date", "message-id",
+	"mime-version", "content-type", "content-transfer-encoding",
+}
+
+var dkimWhitespace = regexp.MustCompile(`[ \t]+`)
+
+// DKIMSigner signs e-mails with rsa-sha256 and relaxed/relaxed canonicalization (RFC 6376).
+type DKIMSigner struct {
+	Domain   string
+	Selector string
+	key      *rsa.PrivateKey
+	now      func() time.Time
+}
+
+// NewDKIMSigner creates a signer for the domain and selector with the given PEM encoded
+// RSA private key (PKCS#1 or PKCS#8).
+func NewDKIMSigner(domain, selector string, keyPEM []byte) (*DKIMSigner, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM encoded DKIM key found")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		parsed, err8 := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err8 != nil {
+			return nil, err
+		}
+		var ok bool
+		if key, ok = parsed.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("DKIM key is not an RSA key")
+		}
+	}
+
+	return &DKIMSigner{Domain: domain, Selector: selector, key: key, now: time.Now}, nil
+}
+
+// Sign returns the message with CRLF line endings and a prepended DKIM-Signature header.
+func (s *DKIMSigner) Sign(message []byte) ([]byte, error) {
+	message = dkimNormalizeLines(message)
+	header, body := dkimSplit(message)
+
+	fields := dkimHeaderFields(header)
+	names := make([]string, 0, len(fields))
+	signed := make([]string, 0, len(fields))
+	for _, name := range dkimSignedHeaders {
+		for i := len(fields) - 1; i >= 0; i-- {
+			if dkimFieldName(fields[i]) == name {
+				names = append(names, name)
+				signed = append(signed, fields[i])
+			}
+		}
+	}
+	if len(names) == 0 || names[0] != "from" {
+		return nil, fmt.Errorf("DKIM signing requires a From header")
+	}
+
+	bodyHash := sha256.Sum256(dkimRelaxedBody(body))
+	value := fmt.Sprintf("v=1; a=rsa-sha256; c=relaxed/relaxed; d=%s; s=%s; t=%d; h=%s; bh=%s; b=",
+		s.Domain, s.Selector, s.now().Unix(), strings.Join(names, ":"),
+		base64.StdEncoding.EncodeToString(bodyHash[:]))
+
+	hash := sha256.New()
+	for _, field := range signed {
+		hash.Write([]byte(dkimRelaxedHeader(field)))
+	}
+	hash.Write([]byte(strings.TrimSuffix(dkimRelaxedHeader("DKIM-Signature: "+value), "\r\n")))
+
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	var signedMessage bytes.Buffer
+	signedMessage.WriteString("DKIM-Signature: " + value + base64.StdEncoding.EncodeToString(sig) + "\r\n")
+	signedMessage.Write(message)
+	return signedMessage.Bytes(), nil
+}
+
+// dkimNormalizeLines converts all line endings to CRLF.
+func dkimNormalizeLines(message []byte) []byte {
+	message = bytes.Replace(message, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.Replace(message, []byte("\n"), []byte("\r\n"), -1)
+}
+
+// dkimSplit splits a message into header and body at the first empty line.
+func dkimSplit(message []byte) (string, []byte) {
+	i := bytes.Index(message, []byte("\r\n\r\n"))
+	if i < 0 {
+		return string(message), nil
+	}
+	return string(message[:i+2]), message[i+4:]
+}
+
+// dkimHeaderFields splits the header into fields including folded continuation lines.
+func dkimHeaderFields(header string) []string {
+	fields := make([]string, 0)
+	for _, line := range strings.SplitAfter(header, "\r\n") {
+		if line == "" {
+			continue
+		}
+		if (line[0] == ' ' || line[0] == '\t') && len(fields) > 0 {
+			fields[len(fields)-1] += line
+		} else {
+			fields = append(fields, line)
+		}
+	}
+	return fields
+}
+
+func dkimFieldName(field string) string {
+	i := strings.Index(field, ":")
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(field[:i]))
+}
+
+// dkimRelaxedHeader applies the relaxed header canonicalization to a header field.
+func dkimRelaxedHeader(field string) string {
+	i := strings.Index(field, ":")
+	value := strings.Replace(field[i+1:], "\r\n", "", -1)
+	value = strings.TrimSpace(dkimWhitespace.ReplaceAllString(value, " "))
+	return dkimFieldName(field) + ":" + value + "\r\n"
+}
+
+// dkimRelaxedBody applies the relaxed body canonicalization.
+func dkimRelaxedBody(body []byte) []byte {
+	lines := strings.Split(string(body), "\r\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(dkimWhitespace.ReplaceAllString(line, " "), " ")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+)
+
+const dkimTestMessage = "From: GIN <no-reply@example.com>\n" +
+	"To: alice@example.com\n" +
+	"Subject: A  folded\n" +
+	"\tsubject\n" +
+	"\n" +
+	"Hello   Alice  \n" +
+	"\n" +
+	"\n"
+
+func TestDKIMSigner_Sign(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	_, err = NewDKIMSigner("example.com", "mail", []byte("no key"))
+	if err == nil {
+		t.Error("Invalid key should fail")
+	}
+
+	signer, err := NewDKIMSigner("example.com", "mail", keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = signer.Sign([]byte("Subject: no sender\n\nBody\n"))
+	if err == nil {
+		t.Error("Message without From header should fail")
+	}
+
+	signed, err := signer.Sign([]byte(dkimTestMessage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, body := dkimSplit(signed)
+	fields := dkimHeaderFields(header)
+	if len(fields) != 4 || !strings.HasPrefix(fields[0], "DKIM-Signature: ") {
+		t.Fatalf("DKIM-Signature header expected: %s", header)
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range strings.Split(strings.TrimSpace(fields[0][len("DKIM-Signature: "):]), "; ") {
+		kv := strings.SplitN(tag, "=", 2)
+		tags[kv[0]] = kv[1]
+	}
+	if tags["d"] != "example.com" || tags["s"] != "mail" || tags["h"] != "from:to:subject" {
+		t.Errorf("Unexpected signature tags: %v", tags)
+	}
+
+	bodyHash := sha256.Sum256([]byte("Hello Alice\r\n"))
+	if tags["bh"] != base64.StdEncoding.EncodeToString(bodyHash[:]) {
+		t.Error("Body hash does not match")
+	}
+	if string(dkimRelaxedBody(body)) != "Hello Alice\r\n" {
+		t.Errorf("Unexpected canonical body: %q", dkimRelaxedBody(body))
+	}
+	if dkimRelaxedHeader(fields[3]) != "subject:A folded subject\r\n" {
+		t.Errorf("Unexpected canonical header: %q", dkimRelaxedHeader(fields[3]))
+	}
+
+	hash := sha256.New()
+	for _, field := range fields[1:] {
+		hash.Write([]byte(dkimRelaxedHeader(field)))
+	}
+	unsigned := strings.TrimSuffix(fields[0], tags["b"]+"\r\n")
+	hash.Write([]byte(strings.TrimSuffix(dkimRelaxedHeader(unsigned), "\r\n")))
+
+	sig, _ := base64.StdEncoding.DecodeString(tags["b"])
+	err = rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash.Sum(nil), sig)
+	if err != nil {
+		t.Errorf("Signature verification failed: %s", err)
+	}
+}
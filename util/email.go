@@ -11,11 +11,10 @@ package util
 import (
 	"bytes"
 	"fmt"
-	"net"
 	"net/smtp"
 	"strconv"
-	"text/template"
-	"time"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/G-Node/gin-auth/conf"
 )
@@ -34,25 +33,22 @@ type emailDispatcher struct {
 func (e *emailDispatcher) Send(recipient []string, content []byte) error {
 	addr := e.conf.Host + ":" + strconv.Itoa(e.conf.Port)
 	auth := smtp.PlainAuth("", e.conf.Username, e.conf.Password, e.conf.Host)
-	if e.conf.Mode != "print" && e.conf.Mode != "skip" {
-		netCon, err := net.DialTimeout("tcp", addr, time.Second*10)
-		if err != nil {
-			return err
-		}
-		if err = netCon.Close(); err != nil {
-			return err
-		}
-	}
 	return e.send(addr, auth, e.conf.From, recipient, content)
 }
 
 // NewEmailDispatcher returns an instance of emailDispatcher.
 // Dependent on the value of config.smtp.Mode the send method will
 // print the e-mail content to the commandline (value "print"), do nothing (value "skip")
-// or by default send an e-mail via smtp.SendMail.
+// or by default send an e-mail via the SmtpPool.
 func NewEmailDispatcher() EmailDispatcher {
 	config := conf.GetSmtpCredentials()
-	send := smtp.SendMail
+	send := func(addr string, auth smtp.Auth, from string, recipient []string, cont []byte) error {
+		pool, err := GetSmtpPool()
+		if err != nil {
+			return err
+		}
+		return pool.Send(from, recipient, cont)
+	}
 	if config.Mode == "print" {
 		send = func(addr string, auth smtp.Auth, from string, recipient []string, cont []byte) error {
 			fmt.Printf("E-Mail content:\n---\n%s---\n", string(cont))
@@ -66,23 +62,38 @@ func NewEmailDispatcher() EmailDispatcher {
 	return &emailDispatcher{config, send}
 }
 
-// MakeEmailTemplate parses a given template into the main email layout template,
-// applies the parsed template to the specified content object and returns
-// the result as a bytes.Buffer.
+// MakeEmailTemplate applies the given template within the main email layout template
+// to the specified content object and returns the result as a bytes.Buffer.
 func MakeEmailTemplate(fileName string, content interface{}) *bytes.Buffer {
 	var doc bytes.Buffer
 
-	mainFile := conf.GetResourceFile("templates", "emaillayout.txt")
-	contentFile := conf.GetResourceFile("templates", fileName)
-	tmpl, err := template.ParseFiles(mainFile, contentFile)
-	if err != nil {
-		panic("Error parsing e-mail template: " + err.Error())
-	}
-
-	err = tmpl.Execute(&doc, content)
+	tmpl := conf.MakeEmailTemplate(fileName)
+	err := tmpl.Execute(&doc, content)
 	if err != nil {
 		panic("Error executing e-mail template: " + err.Error())
 	}
 
 	return &doc
 }
+
+// MaskEmail obfuscates an e-mail address for public display, such that the address can be recognized
+// by people who know it but not harvested, e.g. "alice.goodchild@g-node.org" becomes "a***@g***.org".
+func MaskEmail(email string) string {
+	mask := func(s string) string {
+		if s == "" {
+			return ""
+		}
+		r, _ := utf8.DecodeRuneInString(s)
+		return string(r) + "***"
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return mask(email)
+	}
+	local, domain := email[:at], email[at+1:]
+	if dot := strings.LastIndex(domain, "."); dot > 0 {
+		return mask(local) + "@" + mask(domain[:dot]) + domain[dot:]
+	}
+	return mask(local) + "@" + mask(domain)
+}
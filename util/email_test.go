@@ -96,12 +96,13 @@ func TestMakeEmailTemplate_Reset(t *testing.T) {
 	recipient := []string{"recipient@example.com"}
 
 	fields := &struct {
-		From    string
-		To      string
-		Subject string
-		Code    string
-		BaseUrl string
-	}{from, strings.Join(recipient, ", "), subject, code, url}
+		From     string
+		To       string
+		Subject  string
+		Code     string
+		BaseUrl  string
+		Continue string
+	}{from, strings.Join(recipient, ", "), subject, code, url, "expired=true&request_id=U7JIKKYI"}
 
 	content := MakeEmailTemplate(template, fields).String()
 	if strings.Contains(content, "<no value>") {
@@ -120,7 +121,7 @@ func TestMakeEmailTemplate_Reset(t *testing.T) {
 	if !strings.Contains(content, url+"/oauth") {
 		t.Errorf("Url is malformed or missing:\n\n%s", content)
 	}
-	if !strings.Contains(content, "reset_page?reset_code="+code) {
+	if !strings.Contains(content, "reset_page?reset_code="+code+"&expired=true&request_id=U7JIKKYI") {
 		t.Errorf("Reset code is malformed or missing:\n\n%s", content)
 	}
 }
@@ -189,3 +190,18 @@ func TestNewEmailDispatcher(t *testing.T) {
 		t.Error(err.Error())
 	}
 }
+
+func TestMaskEmail(t *testing.T) {
+	tests := map[string]string{
+		"alice.goodchild@g-node.org":   "a***@g***.org",
+		"bob@biologie.uni-muenchen.de": "b***@b***.de",
+		"jürgen@localhost":             "j***@l***",
+		"noaddress":                    "n***",
+		"":                             "",
+	}
+	for email, expected := range tests {
+		if masked := MaskEmail(email); masked != expected {
+			t.Errorf("Masked address of '%s' expected to be '%s' but was '%s'", email, expected, masked)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"net"
+	"strings"
+)
+
+// NormalizeIP parses an IP address in any of its textual representations: IPv6 addresses
+// may be enclosed in brackets and contain a zone ID (e.g. "[fe80::1%eth0]"), which is removed.
+// IPv4-mapped IPv6 addresses are converted into IPv4 addresses. Returns nil if the address
+// is invalid.
+func NormalizeIP(addr string) net.IP {
+	addr = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(addr), "["), "]")
+	if i := strings.IndexByte(addr, '%'); i >= 0 {
+		addr = addr[:i]
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// IPBucket returns the key under which requests from an IP address are counted by rate
+// limiters. IPv4 addresses are counted individually, IPv6 addresses are counted per network
+// with the given prefix length (usually 64), since a single host can use a whole /64 network.
+func IPBucket(ip net.IP, ipv6Prefix int) string {
+	if ip == nil {
+		return ""
+	}
+	if ip.To4() != nil || ipv6Prefix <= 0 || ipv6Prefix >= 128 {
+		return ip.String()
+	}
+	network := &net.IPNet{IP: ip.Mask(net.CIDRMask(ipv6Prefix, 128)), Mask: net.CIDRMask(ipv6Prefix, 128)}
+	return network.String()
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"net"
+	"testing"
+)
+
+func TestNormalizeIP(t *testing.T) {
+	for in, out := range map[string]string{
+		"192.0.2.1":                   "192.0.2.1",
+		"::ffff:192.0.2.1":            "192.0.2.1",
+		"2001:DB8:0:0:0:0:0:1":        "2001:db8::1",
+		"[2001:db8::1]":               "2001:db8::1",
+		"fe80::1%eth0":                "fe80::1",
+		"[fe80::0001%25en0]":          "fe80::1",
+		" 2001:0db8:0000::0000:0001 ": "2001:db8::1",
+	} {
+		ip := NormalizeIP(in)
+		if ip == nil || ip.String() != out {
+			t.Errorf("Address '%s' expected to be normalized to '%s' but was '%v'", in, out, ip)
+		}
+	}
+	if NormalizeIP("localhost") != nil || NormalizeIP("") != nil {
+		t.Error("Invalid addresses expected to return nil")
+	}
+	if len(NormalizeIP("::ffff:192.0.2.1")) != net.IPv4len {
+		t.Error("IPv4-mapped address expected to be converted into IPv4")
+	}
+}
+
+func TestIPBucket(t *testing.T) {
+	if b := IPBucket(NormalizeIP("192.0.2.1"), 64); b != "192.0.2.1" {
+		t.Errorf("IPv4 bucket expected to be the address but was '%s'", b)
+	}
+	b1 := IPBucket(NormalizeIP("2001:db8:1:2:aaaa::1"), 64)
+	b2 := IPBucket(NormalizeIP("2001:db8:1:2:bbbb::2"), 64)
+	if b1 != "2001:db8:1:2::/64" || b1 != b2 {
+		t.Errorf("IPv6 addresses expected to share the bucket of their /64 network: '%s', '%s'", b1, b2)
+	}
+	if b := IPBucket(NormalizeIP("2001:db8:1:3::1"), 64); b == b1 {
+		t.Error("Different /64 networks expected to have different buckets")
+	}
+	if b := IPBucket(NormalizeIP("2001:db8::1"), 128); b != "2001:db8::1" {
+		t.Errorf("Prefix 128 expected to count single addresses but was '%s'", b)
+	}
+	if IPBucket(nil, 64) != "" {
+		t.Error("Empty bucket expected for invalid address")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// Number of elements after which a JSON array stream is flushed
+const jsonStreamFlushEvery = 100
+
+// JSONArrayStream writes a JSON array element by element, such that large lists do not
+// have to be kept in memory. If the underlying writer is a http.Flusher the written data
+// is flushed every few elements.
+type JSONArrayStream struct {
+	w     io.Writer
+	enc   *json.Encoder
+	count int
+}
+
+// NewJSONArrayStream creates a stream which writes a JSON array to w.
+func NewJSONArrayStream(w io.Writer) *JSONArrayStream {
+	return &JSONArrayStream{w: w, enc: json.NewEncoder(w)}
+}
+
+// Write encodes one element of the array.
+func (s *JSONArrayStream) Write(v interface{}) error {
+	sep := ","
+	if s.count == 0 {
+		sep = "["
+	}
+	_, err := io.WriteString(s.w, sep)
+	if err != nil {
+		return err
+	}
+	err = s.enc.Encode(v)
+	if err != nil {
+		return err
+	}
+
+	s.count++
+	if s.count%jsonStreamFlushEvery == 0 {
+		s.flush()
+	}
+	return nil
+}
+
+// Close terminates the array. Close must be called once after the last element was written.
+func (s *JSONArrayStream) Close() error {
+	end := "]\n"
+	if s.count == 0 {
+		end = "[]\n"
+	}
+	_, err := io.WriteString(s.w, end)
+	s.flush()
+	return err
+}
+
+// Count returns the number of elements written so far.
+func (s *JSONArrayStream) Count() int {
+	return s.count
+}
+
+func (s *JSONArrayStream) flush() {
+	if f, ok := s.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestJSONArrayStream(t *testing.T) {
+	// empty array
+	buf := bytes.NewBuffer(nil)
+	s := NewJSONArrayStream(buf)
+	s.Close()
+	if buf.String() != "[]\n" {
+		t.Errorf("Empty array expected but was '%s'", buf.String())
+	}
+
+	// many elements with flushing
+	response := httptest.NewRecorder()
+	s = NewJSONArrayStream(response)
+	for i := 0; i < 250; i++ {
+		err := s.Write(map[string]int{"n": i})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !response.Flushed {
+		t.Error("Response expected to be flushed")
+	}
+	s.Close()
+	if s.Count() != 250 {
+		t.Errorf("250 elements expected but was %d", s.Count())
+	}
+
+	var result []struct{ N int }
+	err := json.Unmarshal(response.Body.Bytes(), &result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 250 || result[249].N != 249 {
+		t.Error("Unexpected array content")
+	}
+}
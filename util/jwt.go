@@ -0,0 +1,311 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // register hash functions used by JWS algorithms
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+// JWTClaims contains the registered claims of a JSON Web Token (RFC 7519).
+// Times are seconds since the epoch, zero means the claim is missing.
+type JWTClaims struct {
+	Issuer    string      `json:"iss"`
+	Subject   string      `json:"sub"`
+	Audience  JWTAudience `json:"aud"`
+	ExpiresAt int64       `json:"exp"`
+	NotBefore int64       `json:"nbf"`
+	IssuedAt  int64       `json:"iat"`
+	ID        string      `json:"jti"`
+}
+
+// JWTAudience is the audience of a JWT, which is either a single string or an array of strings.
+type JWTAudience []string
+
+// UnmarshalJSON reads a single string or an array of strings.
+func (aud *JWTAudience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*aud = JWTAudience{single}
+		return nil
+	}
+	var multiple []string
+	err := json.Unmarshal(b, &multiple)
+	*aud = JWTAudience(multiple)
+	return err
+}
+
+// Contains returns true if one of the audiences is equal to the given value.
+func (aud JWTAudience) Contains(value string) bool {
+	for _, a := range aud {
+		if a == value {
+			return true
+		}
+	}
+	return false
+}
+
+// JWT is a parsed JSON Web Token with JWS compact serialization, whose signature
+// has not necessarily been verified.
+type JWT struct {
+	Algorithm    string
+	KeyID        string
+	Claims       JWTClaims
+	signingInput string
+	signature    []byte
+}
+
+// ParseJWT parses a JWT in compact serialization without verifying its signature.
+func ParseJWT(token string) (*JWT, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Malformed JWT")
+	}
+
+	header := &struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	if err := decodeJWTPart(parts[0], header); err != nil {
+		return nil, err
+	}
+
+	jwt := &JWT{Algorithm: header.Alg, KeyID: header.Kid, signingInput: parts[0] + "." + parts[1]}
+	if err := decodeJWTPart(parts[1], &jwt.Claims); err != nil {
+		return nil, err
+	}
+
+	var err error
+	jwt.signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("Malformed JWT signature")
+	}
+
+	return jwt, nil
+}
+
+func decodeJWTPart(part string, dest interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("Malformed JWT")
+	}
+	err = json.Unmarshal(b, dest)
+	if err != nil {
+		return errors.New("Malformed JWT")
+	}
+	return nil
+}
+
+// jwtHash returns the hash function used by a JWS algorithm, e.g. SHA-256 for RS256.
+func jwtHash(alg string) (crypto.Hash, bool) {
+	if len(alg) != 5 {
+		return 0, false
+	}
+	switch alg[2:] {
+	case "256":
+		return crypto.SHA256, true
+	case "384":
+		return crypto.SHA384, true
+	case "512":
+		return crypto.SHA512, true
+	}
+	return 0, false
+}
+
+// VerifyHMAC verifies the signature of a JWT signed with HS256, HS384 or HS512 using the shared secret.
+func (jwt *JWT) VerifyHMAC(secret []byte) error {
+	hash, ok := jwtHash(jwt.Algorithm)
+	if !ok || !strings.HasPrefix(jwt.Algorithm, "HS") || len(secret) == 0 {
+		return fmt.Errorf("Unsupported JWT algorithm '%s'", jwt.Algorithm)
+	}
+
+	mac := hmac.New(hash.New, secret)
+	mac.Write([]byte(jwt.signingInput))
+	if !hmac.Equal(mac.Sum(nil), jwt.signature) {
+		return errors.New("Invalid JWT signature")
+	}
+	return nil
+}
+
+// VerifyKeys verifies the signature of a JWT signed with RS256, RS384, RS512, ES256, ES384 or ES512
+// using one of the given public keys. If the JWT names a key id, only the key with this id is used.
+// Keys whose type or curve does not match the algorithm are never used.
+func (jwt *JWT) VerifyKeys(keys []JWK) error {
+	hash, ok := jwtHash(jwt.Algorithm)
+	if !ok || !(strings.HasPrefix(jwt.Algorithm, "RS") || strings.HasPrefix(jwt.Algorithm, "ES")) {
+		return fmt.Errorf("Unsupported JWT algorithm '%s'", jwt.Algorithm)
+	}
+	h := hash.New()
+	h.Write([]byte(jwt.signingInput))
+	digest := h.Sum(nil)
+
+	for _, key := range keys {
+		if jwt.KeyID != "" && key.Kid != jwt.KeyID {
+			continue
+		}
+		if !key.matchesAlgorithm(jwt.Algorithm) {
+			continue
+		}
+		pub, err := key.PublicKey()
+		if err != nil {
+			continue
+		}
+
+		switch pub := pub.(type) {
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(pub, hash, digest, jwt.signature) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			size := (pub.Curve.Params().BitSize + 7) / 8
+			if len(jwt.signature) != 2*size {
+				continue
+			}
+			r := new(big.Int).SetBytes(jwt.signature[:size])
+			s := new(big.Int).SetBytes(jwt.signature[size:])
+			if ecdsa.Verify(pub, digest, r, s) {
+				return nil
+			}
+		}
+	}
+	return errors.New("Invalid JWT signature")
+}
+
+// ValidateTime checks the expiry and not-before claims of a JWT against the server clock, with the
+// configured leeway for clock skew. Tokens without expiry or with an expiry further in the future
+// than maxLifeTime are rejected.
+func (jwt *JWT) ValidateTime(maxLifeTime time.Duration) error {
+	now := Now()
+	leeway := conf.GetClock().Leeway
+	if jwt.Claims.ExpiresAt == 0 {
+		return errors.New("JWT has no expiry")
+	}
+	expires := time.Unix(jwt.Claims.ExpiresAt, 0)
+	if !expires.After(now.Add(-leeway)) {
+		return errors.New("JWT has expired")
+	}
+	if expires.After(now.Add(maxLifeTime + leeway)) {
+		return errors.New("JWT expires too late")
+	}
+	if jwt.Claims.NotBefore != 0 && time.Unix(jwt.Claims.NotBefore, 0).After(now.Add(leeway)) {
+		return errors.New("JWT is not yet valid")
+	}
+	return nil
+}
+
+// JWK is a public JSON Web Key (RFC 7517) of type RSA or EC.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// jwkCurves contains the curve each ECDSA algorithm must be used with
+var jwkCurves = map[string]string{"ES256": "P-256", "ES384": "P-384", "ES512": "P-521"}
+
+// matchesAlgorithm checks that the key type, curve and the algorithm named by the key (if any)
+// match the given JWS algorithm.
+func (key *JWK) matchesAlgorithm(alg string) bool {
+	if key.Alg != "" && key.Alg != alg {
+		return false
+	}
+	switch key.Kty {
+	case "RSA":
+		return strings.HasPrefix(alg, "RS")
+	case "EC":
+		return jwkCurves[alg] != "" && jwkCurves[alg] == key.Crv
+	}
+	return false
+}
+
+// ParseJWKSet parses a JSON Web Key Set, e.g. {"keys": [{"kty": "RSA", ...}]}, and checks that all keys are valid.
+func ParseJWKSet(set string) ([]JWK, error) {
+	keys := &struct {
+		Keys []JWK `json:"keys"`
+	}{}
+	err := json.Unmarshal([]byte(set), keys)
+	if err != nil {
+		return nil, errors.New("Malformed JSON Web Key Set")
+	}
+	for _, key := range keys.Keys {
+		if _, err := key.PublicKey(); err != nil {
+			return nil, err
+		}
+	}
+	return keys.Keys, nil
+}
+
+// PublicKey converts the JWK into an *rsa.PublicKey or *ecdsa.PublicKey.
+func (key *JWK) PublicKey() (crypto.PublicKey, error) {
+	decode := func(s string) (*big.Int, error) {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil, fmt.Errorf("Invalid JSON Web Key '%s'", key.Kid)
+		}
+		return new(big.Int).SetBytes(b), nil
+	}
+
+	switch key.Kty {
+	case "RSA":
+		n, err := decode(key.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(key.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("Invalid JSON Web Key '%s'", key.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("Unsupported curve '%s' of JSON Web Key '%s'", key.Crv, key.Kid)
+		}
+		x, err := decode(key.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(key.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("Invalid JSON Web Key '%s'", key.Kid)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("Unsupported type '%s' of JSON Web Key '%s'", key.Kty, key.Kid)
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// signTestJWT creates a JWT signed with an *rsa.PrivateKey, *ecdsa.PrivateKey (P-256) or a shared secret.
+// RSA and ECDSA signatures use the hash function of the algorithm.
+func signTestJWT(t *testing.T, alg, kid string, claims map[string]interface{}, key interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash, ok := jwtHash(alg)
+	if !ok {
+		hash = crypto.SHA256
+	}
+	h := hash.New()
+	h.Write([]byte(input))
+	digest := h.Sum(nil)
+
+	var sig []byte
+	var err error
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		sig, err = rsa.SignPKCS1v15(rand.Reader, key, hash, digest)
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, key, digest)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWT_VerifyKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64 := func(i *big.Int) string { return base64.RawURLEncoding.EncodeToString(i.Bytes()) }
+	set := fmt.Sprintf(`{"keys": [{"kty": "RSA", "kid": "rsa", "n": "%s", "e": "%s"},
+	                              {"kty": "EC", "kid": "ec", "crv": "P-256", "x": "%s", "y": "%s"}]}`,
+		b64(rsaKey.N), b64(big.NewInt(int64(rsaKey.E))), b64(ecKey.X), b64(ecKey.Y))
+	keys, err := ParseJWKSet(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("Two keys expected but was %d", len(keys))
+	}
+
+	claims := map[string]interface{}{"iss": "gin", "sub": "gin", "aud": "https://example.com/oauth/token", "jti": "1"}
+	for _, key := range []struct {
+		alg, kid string
+		key      interface{}
+	}{{"RS256", "rsa", rsaKey}, {"ES256", "ec", ecKey}, {"ES256", "", ecKey}} {
+		jwt, err := ParseJWT(signTestJWT(t, key.alg, key.kid, claims, key.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = jwt.VerifyKeys(keys); err != nil {
+			t.Errorf("Signature with %s key '%s' expected to be valid: %s", key.alg, key.kid, err)
+		}
+		if !jwt.Claims.Audience.Contains("https://example.com/oauth/token") || jwt.Claims.ID != "1" {
+			t.Error("Unexpected claims")
+		}
+	}
+
+	// wrong key
+	jwt, _ := ParseJWT(signTestJWT(t, "RS256", "ec", claims, rsaKey))
+	if jwt.VerifyKeys(keys) == nil {
+		t.Error("Signature with wrong key id expected to be invalid")
+	}
+	other, _ := rsa.GenerateKey(rand.Reader, 1024)
+	jwt, _ = ParseJWT(signTestJWT(t, "RS256", "rsa", claims, other))
+	if jwt.VerifyKeys(keys) == nil {
+		t.Error("Signature with unknown key expected to be invalid")
+	}
+
+	// key type and curve must match the algorithm
+	jwt, _ = ParseJWT(signTestJWT(t, "ES384", "ec", claims, ecKey))
+	if jwt.VerifyKeys(keys) == nil {
+		t.Error("Signature with ES384 and a P-256 key expected to be invalid")
+	}
+	jwt, _ = ParseJWT(signTestJWT(t, "RS256", "", claims, rsaKey))
+	if jwt.VerifyKeys(keys[1:]) == nil {
+		t.Error("RSA signature expected to be rejected for EC keys")
+	}
+
+	// shared secrets are not accepted as keys
+	jwt, _ = ParseJWT(signTestJWT(t, "HS256", "", claims, []byte("secret")))
+	if jwt.VerifyKeys(keys) == nil {
+		t.Error("HMAC signature expected to be rejected")
+	}
+
+	if _, err = ParseJWKSet(`{"keys": [{"kty": "EC", "crv": "P-256", "x": "AQ", "y": "AQ"}]}`); err == nil {
+		t.Error("Key not on the curve expected to be rejected")
+	}
+}
+
+func TestJWT_VerifyHMAC(t *testing.T) {
+	claims := map[string]interface{}{"iss": "gin", "aud": []string{"a", "b"}}
+	jwt, err := ParseJWT(signTestJWT(t, "HS256", "", claims, []byte("secret")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = jwt.VerifyHMAC([]byte("secret")); err != nil {
+		t.Error(err)
+	}
+	if jwt.VerifyHMAC([]byte("wrong")) == nil {
+		t.Error("Signature with wrong secret expected to be invalid")
+	}
+	if !jwt.Claims.Audience.Contains("b") {
+		t.Error("Audience expected to contain 'b'")
+	}
+
+	jwt.Algorithm = "none"
+	if jwt.VerifyHMAC([]byte("secret")) == nil {
+		t.Error("Algorithm 'none' expected to be rejected")
+	}
+	if _, err = ParseJWT("a.b"); err == nil {
+		t.Error("Malformed JWT expected to be rejected")
+	}
+}
+
+func TestJWT_ValidateTime(t *testing.T) {
+	now := time.Date(2016, 11, 3, 12, 0, 0, 0, time.UTC)
+	defer SetClock(SetClock(FixedClock(now)))
+
+	jwt := &JWT{}
+	if jwt.ValidateTime(time.Hour) == nil {
+		t.Error("JWT without expiry expected to be invalid")
+	}
+	jwt.Claims.ExpiresAt = now.Add(-time.Minute).Unix()
+	if jwt.ValidateTime(time.Hour) == nil {
+		t.Error("Expired JWT expected to be invalid")
+	}
+	jwt.Claims.ExpiresAt = now.Add(-time.Second).Unix()
+	if err := jwt.ValidateTime(time.Hour); err != nil {
+		t.Errorf("JWT expired within the leeway expected to be valid: %s", err)
+	}
+	jwt.Claims.ExpiresAt = now.Add(2 * time.Hour).Unix()
+	if jwt.ValidateTime(time.Hour) == nil {
+		t.Error("JWT with late expiry expected to be invalid")
+	}
+	jwt.Claims.ExpiresAt = now.Add(5 * time.Minute).Unix()
+	if err := jwt.ValidateTime(time.Hour); err != nil {
+		t.Error(err)
+	}
+	jwt.Claims.NotBefore = now.Add(time.Minute).Unix()
+	if jwt.ValidateTime(time.Hour) == nil {
+		t.Error("JWT not yet valid expected to be invalid")
+	}
+}
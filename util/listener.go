@@ -0,0 +1,60 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+)
+
+// SocketSystemd selects a socket passed by systemd socket activation.
+const SocketSystemd = "systemd"
+
+// First file descriptor passed by systemd (SD_LISTEN_FDS_START).
+const systemdListenFdsStart = 3
+
+// Listen creates the listener for the server. If socket is empty a TCP listener on addr is created.
+// If socket is "systemd" the first socket passed via systemd socket activation is used,
+// otherwise socket is the path of a unix domain socket; a stale socket file at this path is removed.
+func Listen(socket, addr string) (net.Listener, error) {
+	switch socket {
+	case "":
+		return net.Listen("tcp", addr)
+	case SocketSystemd:
+		return systemdListener()
+	default:
+		if info, err := os.Stat(socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(socket)
+		}
+		return net.Listen("unix", socket)
+	}
+}
+
+// systemdListener returns a listener for the first socket passed by systemd.
+// See sd_listen_fds(3) for the protocol.
+func systemdListener() (net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, errors.New("No sockets passed by systemd")
+	}
+	fds, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || fds < 1 {
+		return nil, errors.New("No sockets passed by systemd")
+	}
+
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+
+	file := os.NewFile(uintptr(systemdListenFdsStart), "systemd-socket")
+	defer file.Close()
+
+	return net.FileListener(file)
+}
@@ -0,0 +1,61 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gin-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "gin-auth.sock")
+
+	l, err := Listen(socket, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Addr().Network() != "unix" {
+		t.Errorf("Network expected to be 'unix' but was '%s'", l.Addr().Network())
+	}
+	l.Close()
+
+	// stale socket file
+	l, err = Listen(socket, "")
+	if err != nil {
+		t.Errorf("Listening on a stale socket should not fail: %s", err.Error())
+	} else {
+		l.Close()
+	}
+}
+
+func TestListenTCP(t *testing.T) {
+	l, err := Listen("", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if l.Addr().Network() != "tcp" {
+		t.Errorf("Network expected to be 'tcp' but was '%s'", l.Addr().Network())
+	}
+}
+
+func TestListenSystemd(t *testing.T) {
+	os.Unsetenv("LISTEN_PID")
+	_, err := Listen(SocketSystemd, "")
+	if err == nil {
+		t.Error("Listening without systemd sockets should fail")
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/Sirupsen/logrus"
+)
+
+// Triggers of a failed login burst
+const (
+	LoginBurstAccount = "account"
+	LoginBurstIP      = "ip"
+)
+
+// Maximum number of failed logins kept in memory, older failures are dropped first
+const maxTrackedLoginFailures = 10000
+
+// LoginBurst is posted to the failed login webhook when an account or an address reaches its
+// threshold of failed logins. Addresses and Logins count the failed logins of the burst within
+// the window per address and per login.
+type LoginBurst struct {
+	Event     string         `json:"event"`
+	Trigger   string         `json:"trigger"`
+	Login     string         `json:"login,omitempty"`
+	IP        string         `json:"ip,omitempty"`
+	Count     int            `json:"count"`
+	Window    int            `json:"window"`
+	Addresses map[string]int `json:"addresses"`
+	Logins    map[string]int `json:"logins"`
+	Time      time.Time      `json:"time"`
+}
+
+type loginFailure struct {
+	login string
+	ip    string
+	time  time.Time
+}
+
+type burstDetector struct {
+	lock     sync.Mutex
+	failures []loginFailure
+	reported map[string]time.Time
+	notify   func(burst *LoginBurst)
+}
+
+var loginBursts = &burstDetector{
+	reported: make(map[string]time.Time),
+	notify:   sendLoginBurst,
+}
+
+// RecordFailedLogin records a failed login for an account and the address it came from. Both may
+// be empty if unknown. If the account or the address reaches its configured threshold within the
+// window, the burst is posted to the failed login webhook in the background.
+func RecordFailedLogin(login, ip string) {
+	config := conf.GetLoginBursts()
+	if config.Webhook == "" {
+		return
+	}
+
+	for _, burst := range loginBursts.record(strings.ToLower(login), ip, config, time.Now()) {
+		conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+			"event":   "failed-login-burst",
+			"trigger": burst.Trigger,
+			"login":   burst.Login,
+			"ip":      burst.IP,
+			"count":   burst.Count,
+		}).Warn("Failed login threshold reached")
+		go loginBursts.notify(burst)
+	}
+}
+
+// record adds a failed login and returns the bursts which should be reported.
+func (d *burstDetector) record(login, ip string, config *conf.LoginBursts, now time.Time) []*LoginBurst {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	// forget failures and reports outside the window
+	start := 0
+	for start < len(d.failures) &&
+		(now.Sub(d.failures[start].time) > config.Window || len(d.failures)-start >= maxTrackedLoginFailures) {
+		start++
+	}
+	d.failures = append(d.failures[start:], loginFailure{login, ip, now})
+	for key, reported := range d.reported {
+		if now.Sub(reported) > config.Window {
+			delete(d.reported, key)
+		}
+	}
+
+	bursts := make([]*LoginBurst, 0)
+	if login != "" && config.AccountThreshold > 0 {
+		burst := d.burst(LoginBurstAccount, login, config, config.AccountThreshold, now,
+			func(f *loginFailure) bool { return f.login == login })
+		if burst != nil {
+			burst.Login = login
+			bursts = append(bursts, burst)
+		}
+	}
+	if ip != "" && config.IPThreshold > 0 {
+		burst := d.burst(LoginBurstIP, ip, config, config.IPThreshold, now,
+			func(f *loginFailure) bool { return f.ip == ip })
+		if burst != nil {
+			burst.IP = ip
+			bursts = append(bursts, burst)
+		}
+	}
+
+	return bursts
+}
+
+// burst returns a burst if the failures selected by match reached the threshold and the
+// same account or address was not reported within the window.
+func (d *burstDetector) burst(trigger, key string, config *conf.LoginBursts, threshold int, now time.Time,
+	match func(f *loginFailure) bool) *LoginBurst {
+
+	reportKey := trigger + ":" + key
+	if _, ok := d.reported[reportKey]; ok {
+		return nil
+	}
+
+	burst := &LoginBurst{
+		Event:     "failed-login-burst",
+		Trigger:   trigger,
+		Window:    int(config.Window / time.Second),
+		Addresses: make(map[string]int),
+		Logins:    make(map[string]int),
+		Time:      now,
+	}
+	for i := range d.failures {
+		f := &d.failures[i]
+		if !match(f) {
+			continue
+		}
+		burst.Count++
+		if f.ip != "" {
+			burst.Addresses[f.ip]++
+		}
+		if f.login != "" {
+			burst.Logins[f.login]++
+		}
+	}
+	if burst.Count < threshold {
+		return nil
+	}
+
+	d.reported[reportKey] = now
+	return burst
+}
+
+// sendLoginBurst posts a burst to the configured webhook and logs errors.
+func sendLoginBurst(burst *LoginBurst) {
+	config := conf.GetLoginBursts()
+	err := postLoginBurst(config.Webhook, config.Secret, burst)
+	if err != nil {
+		conf.GetLogEnv().Err.Errorf("Error sending failed login burst to webhook: %s", err.Error())
+	}
+}
+
+// postLoginBurst posts a burst as JSON to a webhook URL, signed with the secret if given
+// (see PostWebhook).
+func postLoginBurst(url, secret string, burst *LoginBurst) error {
+	body, err := json.Marshal(burst)
+	if err != nil {
+		return err
+	}
+	return PostWebhook(&http.Client{Timeout: 10 * time.Second}, url, secret, body)
+}
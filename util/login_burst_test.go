@@ -0,0 +1,88 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func TestBurstDetectorRecord(t *testing.T) {
+	config := &conf.LoginBursts{Window: 10 * time.Minute, AccountThreshold: 3, IPThreshold: 4}
+	d := &burstDetector{reported: make(map[string]time.Time)}
+	now := time.Now()
+
+	// failures outside the window are not counted
+	d.record("alice", "10.0.0.1", config, now.Add(-20*time.Minute))
+	d.record("alice", "10.0.0.1", config, now.Add(-2*time.Minute))
+	if bursts := d.record("alice", "10.0.0.2", config, now.Add(-time.Minute)); len(bursts) != 0 {
+		t.Error("Failures outside the window should not be counted")
+	}
+
+	// account threshold reached
+	bursts := d.record("alice", "10.0.0.3", config, now)
+	if len(bursts) != 1 || bursts[0].Trigger != LoginBurstAccount || bursts[0].Login != "alice" || bursts[0].Count != 3 {
+		t.Fatalf("Account burst expected: %+v", bursts)
+	}
+	if len(bursts[0].Addresses) != 3 || bursts[0].Addresses["10.0.0.1"] != 1 || bursts[0].Logins["alice"] != 3 {
+		t.Errorf("Offending addresses expected: %+v", bursts[0])
+	}
+	if bursts[0].Window != 600 {
+		t.Errorf("Window expected to be 600 seconds but was %d", bursts[0].Window)
+	}
+
+	// not reported again within the window
+	if bursts := d.record("alice", "10.0.0.4", config, now.Add(time.Minute)); len(bursts) != 0 {
+		t.Error("Burst of the same account should not be reported again within the window")
+	}
+
+	// address threshold reached with several accounts
+	for _, login := range []string{"bob", "carol", "dave"} {
+		bursts = d.record(login, "10.0.0.9", config, now.Add(2*time.Minute))
+	}
+	bursts = d.record("", "10.0.0.9", config, now.Add(2*time.Minute))
+	if len(bursts) != 1 || bursts[0].Trigger != LoginBurstIP || bursts[0].IP != "10.0.0.9" || bursts[0].Count != 4 {
+		t.Fatalf("Address burst expected: %+v", bursts)
+	}
+	if len(bursts[0].Logins) != 3 || bursts[0].Addresses["10.0.0.9"] != 4 {
+		t.Errorf("Tried logins expected: %+v", bursts[0])
+	}
+
+	// reported again after the window
+	later := now.Add(15 * time.Minute)
+	for i := 0; i < 2; i++ {
+		d.record("alice", "10.0.0.5", config, later)
+	}
+	if bursts := d.record("alice", "10.0.0.5", config, later); len(bursts) != 1 {
+		t.Error("Burst expected to be reported again after the window")
+	}
+}
+
+func TestPostLoginBurst(t *testing.T) {
+	var received LoginBurst
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	burst := &LoginBurst{Event: "failed-login-burst", Trigger: LoginBurstIP, IP: "10.0.0.9", Count: 20,
+		Addresses: map[string]int{"10.0.0.9": 20}}
+	err := postLoginBurst(server.URL, "burst-secret", burst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received.IP != "10.0.0.9" || received.Count != 20 || received.Addresses["10.0.0.9"] != 20 {
+		t.Errorf("Burst was not properly sent: %+v", received)
+	}
+}
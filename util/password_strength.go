@@ -0,0 +1,269 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// PasswordStrength is the estimated strength of a password. Guesses is the base 10 logarithm
+// of the estimated number of guesses needed to find the password, Score ranges from 0 (too
+// guessable) to 4 (very unguessable) like the score of zxcvbn.
+type PasswordStrength struct {
+	Score       int
+	Guesses     float64
+	Warning     string
+	Suggestions []string
+}
+
+// Upper bounds of log10(guesses) for the scores 0 to 3
+var passwordScoreThresholds = []float64{3, 6, 8, 10}
+
+// Passwords shorter than this have a score of at most 1
+const minStrongPasswordLength = 8
+
+// Frequently used passwords and words, which are guessed first by attackers
+var commonPasswords = []string{
+	"password", "passwort", "123456", "qwerty", "letmein", "welcome", "admin", "login", "monkey",
+	"dragon", "master", "shadow", "sunshine", "princess", "football", "baseball", "iloveyou",
+	"trustno1", "superman", "batman", "starwars", "secret", "hello", "freedom", "whatever",
+	"charlie", "michael", "jordan", "hunter", "ranger", "summer", "winter", "spring", "autumn",
+	"test", "guest", "root", "user", "changeme", "default", "abc123", "access", "computer",
+	"internet", "science", "research", "neuro", "brain", "gnode", "data", "lab",
+}
+
+// Keyboard rows and alphabets in which adjacent characters form sequences
+var passwordSequences = []string{
+	"abcdefghijklmnopqrstuvwxyz", "0123456789", "qwertyuiop", "asdfghjkl", "zxcvbnm", "qwertzuiop", "yxcvbnm",
+}
+
+// Common substitutions of letters by digits and symbols
+var leetReplacer = strings.NewReplacer("0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s", "!", "i")
+
+var yearRegex = regexp.MustCompile(`(19|20)\d\d`)
+
+// EstimatePasswordStrength estimates how hard a password is to guess by a brute force attack which
+// tries common passwords, words from the user inputs (e.g. login, name or e-mail address), repeated
+// characters, sequences and years first.
+func EstimatePasswordStrength(password string, userInputs ...string) PasswordStrength {
+	strength := PasswordStrength{Suggestions: make([]string, 0)}
+	if password == "" {
+		strength.Warning = "Please enter a password"
+		return strength
+	}
+
+	runes := []rune(password)
+	lower := strings.ToLower(password)
+	plain := leetReplacer.Replace(lower)
+
+	// characters which are guessed as part of a pattern are not counted individually
+	covered := make([]bool, len(runes))
+	guesses := 0.0
+	cover := func(start, end int, log10Guesses float64) {
+		for i := start; i < end; i++ {
+			covered[i] = true
+		}
+		guesses += log10Guesses
+	}
+
+	// whole password is a repetition of a shorter chunk, e.g. "testtest"
+	if chunk := repeatedChunk(runes); chunk > 0 {
+		inner := EstimatePasswordStrength(string(runes[:chunk]), userInputs...)
+		strength.Guesses = inner.Guesses + math.Log10(float64(len(runes)/chunk))
+		strength.Warning = "Repeated words like 'abcabc' are only slightly harder to guess than 'abc'"
+		strength.Suggestions = append(strength.Suggestions, "Avoid repeated words and characters")
+		strength.Score = passwordScore(strength.Guesses)
+		return strength
+	}
+
+	words := make([]string, 0, len(userInputs)*2)
+	for _, input := range userInputs {
+		for _, w := range strings.FieldsFunc(strings.ToLower(input), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		}) {
+			if len(w) >= 3 {
+				words = append(words, w)
+			}
+		}
+	}
+	for _, w := range words {
+		if i := strings.Index(plain, w); i >= 0 && len(plain) == len(lower) {
+			cover(runeIndex(lower, i), runeIndex(lower, i+len(w)), 1)
+			strength.Warning = "Passwords containing your name, login or e-mail address are easy to guess"
+		}
+	}
+
+	for rank, w := range commonPasswords {
+		if i := strings.Index(plain, w); i >= 0 && len(plain) == len(lower) {
+			start, end := runeIndex(lower, i), runeIndex(lower, i+len(w))
+			if !covered[start] {
+				cover(start, end, math.Log10(float64(rank+2)))
+				if end-start == len(runes) {
+					strength.Warning = "This is a very common password"
+				} else if strength.Warning == "" {
+					strength.Warning = "Common words are easy to guess"
+				}
+			}
+		}
+	}
+
+	for _, loc := range yearRegex.FindAllStringIndex(lower, -1) {
+		start, end := runeIndex(lower, loc[0]), runeIndex(lower, loc[1])
+		if !covered[start] {
+			cover(start, end, math.Log10(200))
+			if strength.Warning == "" {
+				strength.Warning = "Years are easy to guess"
+			}
+			strength.Suggestions = append(strength.Suggestions, "Avoid years that are associated with you")
+		}
+	}
+
+	for i := 0; i < len(runes); {
+		n := patternLength(runes, i)
+		if n >= 3 && !covered[i] {
+			cover(i, i+n, math.Log10(float64(4*n)))
+			if strength.Warning == "" {
+				strength.Warning = "Repeated characters and sequences like 'aaa' or 'abc' are easy to guess"
+			}
+			strength.Suggestions = append(strength.Suggestions, "Avoid repeated characters and sequences")
+			i += n
+			continue
+		}
+		i++
+	}
+
+	uncovered := 0
+	for _, c := range covered {
+		if !c {
+			uncovered++
+		}
+	}
+	guesses += float64(uncovered) * math.Log10(float64(charsetSize(runes)))
+
+	strength.Guesses = guesses
+	strength.Score = passwordScore(guesses)
+	if len(runes) < minStrongPasswordLength && strength.Score > 1 {
+		strength.Score = 1
+	}
+	if strength.Score < 3 && strength.Warning == "" {
+		strength.Warning = "Short passwords are easy to guess"
+	}
+	if strength.Score < 3 {
+		strength.Suggestions = append(strength.Suggestions, "Add another word or two, uncommon words are better")
+		if len(runes) < 12 {
+			strength.Suggestions = append(strength.Suggestions, "Use a longer password")
+		}
+	}
+	strength.Suggestions = NewStringSet(strength.Suggestions...).Strings()
+	return strength
+}
+
+// passwordScore converts log10(guesses) into a score from 0 to 4.
+func passwordScore(guesses float64) int {
+	for score, threshold := range passwordScoreThresholds {
+		if guesses < threshold {
+			return score
+		}
+	}
+	return len(passwordScoreThresholds)
+}
+
+// charsetSize returns the size of the character set an attacker has to try for the password.
+func charsetSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < 128:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	size := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			size += class.size
+		}
+	}
+	return size
+}
+
+// repeatedChunk returns the length of the shortest chunk, which repeated forms the
+// whole password, or 0 if the password is not a repetition.
+func repeatedChunk(runes []rune) int {
+	for n := 1; n <= len(runes)/2; n++ {
+		if len(runes)%n != 0 {
+			continue
+		}
+		repeated := true
+		for i := n; i < len(runes) && repeated; i++ {
+			repeated = runes[i] == runes[i-n]
+		}
+		if repeated {
+			return n
+		}
+	}
+	return 0
+}
+
+// patternLength returns the length of the repetition of a single character or of the
+// sequence (e.g. "abc", "321" or "qwer") starting at position i.
+func patternLength(runes []rune, i int) int {
+	n := 1
+	for i+n < len(runes) && runes[i+n] == runes[i] {
+		n++
+	}
+	if n > 1 {
+		return n
+	}
+
+	lower := []rune(strings.ToLower(string(runes[i:])))
+	best := 1
+	for _, seq := range passwordSequences {
+		for _, s := range []string{seq, reverse(seq)} {
+			start := strings.IndexRune(s, lower[0])
+			if start < 0 {
+				continue
+			}
+			n := 1
+			for n < len(lower) && start+n < len(s) && rune(s[start+n]) == lower[n] {
+				n++
+			}
+			if n > best {
+				best = n
+			}
+		}
+	}
+	return best
+}
+
+// runeIndex converts a byte index of a string into a rune index.
+func runeIndex(s string, byteIndex int) int {
+	return len([]rune(s[:byteIndex]))
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import "testing"
+
+func TestEstimatePasswordStrength(t *testing.T) {
+	for password, score := range map[string]int{
+		"":                             0,
+		"password":                     0,
+		"P@ssw0rd":                     0,
+		"testtest":                     0,
+		"aaaaaaaaaaaa":                 0,
+		"abcdef123456":                 0,
+		"qwerty1990":                   0,
+		"kq8fz":                        1,
+		"correct horse battery staple": 4,
+		"Xk2#pQ9!vL4$":                 4,
+	} {
+		s := EstimatePasswordStrength(password)
+		if s.Score != score {
+			t.Errorf("Password '%s' expected to have score %d but was %d (%.1f)", password, score, s.Score, s.Guesses)
+		}
+		if score < 3 && s.Warning == "" {
+			t.Errorf("Warning expected for password '%s'", password)
+		}
+	}
+
+	weak := EstimatePasswordStrength("alicesmith", "alice", "Alice", "Smith", "alice@example.com")
+	strong := EstimatePasswordStrength("alicesmith")
+	if weak.Score >= strong.Score || weak.Warning == "" {
+		t.Errorf("Password containing user inputs expected to be weaker: %d >= %d", weak.Score, strong.Score)
+	}
+}
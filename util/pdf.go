@@ -0,0 +1,98 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Layout of text documents: A4 pages in points with Helvetica
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 56
+	pdfTitleSize  = 14
+	pdfFontSize   = 10
+	pdfLeading    = 14
+)
+
+// TextPDF renders a title and lines of plain text as a PDF document. Lines which do not fit on
+// one page continue on the next page. Characters outside of Latin-1 are replaced by '?'.
+func TextPDF(title string, lines []string) []byte {
+	perPage := (pdfPageHeight - 2*pdfMargin) / pdfLeading
+	pages := make([][]string, 0)
+	rest := append([]string{""}, lines...) // first line is left blank below the title
+	for len(rest) > perPage {
+		pages = append(pages, rest[:perPage])
+		rest = rest[perPage:]
+	}
+	pages = append(pages, rest)
+
+	// objects: 1 catalog, 2 page tree, 3 font, 4 info, then a page and its content stream per page
+	objects := make([]string, 4, 4+2*len(pages))
+	kids := bytes.NewBuffer(nil)
+	for i, page := range pages {
+		content := bytes.NewBuffer(nil)
+		fmt.Fprintf(content, "BT\n%d TL\n%d %d Td\n", pdfLeading, pdfMargin, pdfPageHeight-pdfMargin)
+		if i == 0 {
+			fmt.Fprintf(content, "/F1 %d Tf\n(%s) Tj\n", pdfTitleSize, pdfEscape(title))
+		}
+		fmt.Fprintf(content, "/F1 %d Tf\n", pdfFontSize)
+		for _, line := range page {
+			fmt.Fprintf(content, "T* (%s) Tj\n", pdfEscape(line))
+		}
+		content.WriteString("ET")
+
+		pageID := len(objects) + 1
+		fmt.Fprintf(kids, "%d 0 R ", pageID)
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>",
+				pdfPageWidth, pdfPageHeight, pageID+1),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()))
+	}
+	objects[0] = "<< /Type /Catalog /Pages 2 0 R >>"
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", bytes.TrimSpace(kids.Bytes()), len(pages))
+	objects[2] = "<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>"
+	objects[3] = fmt.Sprintf("<< /Title (%s) /Producer (gin-auth) >>", pdfEscape(title))
+
+	doc := bytes.NewBufferString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = doc.Len()
+		fmt.Fprintf(doc, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := doc.Len()
+	fmt.Fprintf(doc, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(doc, "trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return doc.Bytes()
+}
+
+// pdfEscape converts a string into the content of a PDF string literal in WinAnsi encoding.
+func pdfEscape(s string) string {
+	buf := bytes.NewBuffer(nil)
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			buf.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(buf, "\\%03o", r)
+		default:
+			buf.WriteByte('?')
+		}
+	}
+	return buf.String()
+}
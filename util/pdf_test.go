@@ -0,0 +1,62 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestTextPDF(t *testing.T) {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("Line %d", i)
+	}
+	lines[0] = "Scope (repo-read) for Jürgen ✓"
+
+	doc := TextPDF("Consent receipts", lines)
+	if !bytes.HasPrefix(doc, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(doc, []byte("%%EOF\n")) {
+		t.Error("Document header or trailer missing")
+	}
+	if !bytes.Contains(doc, []byte(`(Scope \(repo-read\) for J\374rgen ?) Tj`)) {
+		t.Error("Text expected to be escaped")
+	}
+	if !bytes.Contains(doc, []byte("/Count 2")) {
+		t.Error("Document expected to have two pages")
+	}
+
+	// all objects must be at the offsets given in the cross-reference table
+	match := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(doc, -1)
+	if len(match) != 8 {
+		t.Fatalf("8 objects expected but found %d", len(match))
+	}
+	for i, m := range match {
+		offset, _ := strconv.Atoi(string(m[1]))
+		if !bytes.HasPrefix(doc[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))) {
+			t.Errorf("Object %d not found at offset %d", i+1, offset)
+		}
+	}
+	start := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(doc)
+	offset, _ := strconv.Atoi(string(start[1]))
+	if !bytes.HasPrefix(doc[offset:], []byte("xref\n")) {
+		t.Error("Wrong offset of the cross-reference table")
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	if s := pdfEscape(`a\b`); s != `a\\b` {
+		t.Errorf("Unexpected escaped string '%s'", s)
+	}
+	if s := pdfEscape("tab\there"); s != "tab?here" {
+		t.Errorf("Unexpected escaped string '%s'", s)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter limits the number of attempts per key (e.g. an IP address or a login)
+// within a sliding time window.
+type RateLimiter struct {
+	limit     int
+	window    time.Duration
+	lock      sync.Mutex
+	attempts  map[string][]time.Time
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a rate limiter which allows limit attempts per key within the window.
+func NewRateLimiter(limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		limit:    limit,
+		window:   window,
+		attempts: make(map[string][]time.Time),
+		now:      time.Now,
+	}
+}
+
+// Allow records an attempt for the key and returns false if the attempt exceeds the limit.
+// Rejected attempts are not recorded.
+func (l *RateLimiter) Allow(key string) bool {
+	return l.AllowScaled(key, 1)
+}
+
+// AllowScaled works like Allow but with the limit multiplied by factor, e.g. for
+// requests from internal networks.
+func (l *RateLimiter) AllowScaled(key string, factor int) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	attempts := l.current(key)
+	if len(attempts) >= l.limit*factor {
+		return false
+	}
+	l.attempts[key] = append(attempts, l.now())
+	return true
+}
+
+// Blocked returns true if the limit for the key is reached, without recording an attempt.
+func (l *RateLimiter) Blocked(key string) bool {
+	return l.BlockedScaled(key, 1)
+}
+
+// BlockedScaled works like Blocked but with the limit multiplied by factor.
+func (l *RateLimiter) BlockedScaled(key string, factor int) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return len(l.current(key)) >= l.limit*factor
+}
+
+// Record records an attempt for the key, e.g. a failed login.
+func (l *RateLimiter) Record(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.attempts[key] = append(l.current(key), l.now())
+}
+
+// Reset removes all recorded attempts for the key.
+func (l *RateLimiter) Reset(key string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	delete(l.attempts, key)
+}
+
+// RetryAfter returns the time until the next attempt for the key is allowed.
+func (l *RateLimiter) RetryAfter(key string) time.Duration {
+	return l.RetryAfterScaled(key, 1)
+}
+
+// RetryAfterScaled works like RetryAfter but with the limit multiplied by factor.
+func (l *RateLimiter) RetryAfterScaled(key string, factor int) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	limit := l.limit * factor
+	attempts := l.current(key)
+	if len(attempts) < limit {
+		return 0
+	}
+	return attempts[len(attempts)-limit].Add(l.window).Sub(l.now())
+}
+
+// current returns the attempts for the key within the window and removes expired
+// attempts of all keys once per window. The caller must hold the lock.
+func (l *RateLimiter) current(key string) []time.Time {
+	now := l.now()
+	if now.Sub(l.lastSweep) > l.window {
+		for k, attempts := range l.attempts {
+			if len(attempts) == 0 || now.Sub(attempts[len(attempts)-1]) > l.window {
+				delete(l.attempts, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	attempts := l.attempts[key]
+	for len(attempts) > 0 && now.Sub(attempts[0]) > l.window {
+		attempts = attempts[1:]
+	}
+	if len(attempts) == 0 {
+		delete(l.attempts, key)
+		return nil
+	}
+	l.attempts[key] = attempts
+	return attempts
+}
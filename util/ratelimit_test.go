@@ -0,0 +1,75 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimiter(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	if !l.Allow("alice") || !l.Allow("alice") {
+		t.Error("Two attempts expected to be allowed")
+	}
+	if l.Allow("alice") {
+		t.Error("Third attempt should not be allowed")
+	}
+	if !l.Blocked("alice") || l.Blocked("bob") {
+		t.Error("Only 'alice' expected to be blocked")
+	}
+	if retry := l.RetryAfter("alice"); retry != time.Minute {
+		t.Errorf("Retry after one minute expected but was %s", retry)
+	}
+
+	now = now.Add(61 * time.Second)
+	if l.Blocked("alice") || !l.Allow("alice") {
+		t.Error("Attempts outside the window should not be counted")
+	}
+
+	l.Record("bob")
+	l.Record("bob")
+	if !l.Blocked("bob") {
+		t.Error("Recorded attempts expected to block 'bob'")
+	}
+	l.Reset("bob")
+	if l.Blocked("bob") {
+		t.Error("Reset should remove recorded attempts")
+	}
+
+	now = now.Add(time.Hour)
+	l.Blocked("john")
+	if len(l.attempts) != 0 {
+		t.Error("Expired attempts expected to be removed")
+	}
+}
+
+func TestRateLimiterScaled(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimiter(2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 6; i++ {
+		if !l.AllowScaled("alice", 3) {
+			t.Fatalf("Attempt %d expected to be allowed with scaled limit", i+1)
+		}
+	}
+	if l.AllowScaled("alice", 3) || !l.BlockedScaled("alice", 3) {
+		t.Error("Seventh attempt should not be allowed")
+	}
+	if !l.Blocked("alice") {
+		t.Error("Unscaled limit expected to be exceeded")
+	}
+	if retry := l.RetryAfterScaled("alice", 3); retry != time.Minute {
+		t.Errorf("Retry after one minute expected but was %s", retry)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+//go:build go1.18
+// +build go1.18
+
+package util
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// The query parameters of the authorization endpoint
+type fuzzAuthorizeParams struct {
+	ResponseType string
+	ClientId     string
+	RedirectURI  string
+	State        string
+	Scope        string
+	ReturnTo     string
+}
+
+// The form of the token endpoint together with fields of all other supported types
+type fuzzTokenForm struct {
+	GrantType    string
+	ClientId     string
+	ClientSecret string
+	Code         string
+	Scope        []string
+	ExpiresIn    int
+	Limit        uint
+	Ratio        float64
+	Confirm      bool
+}
+
+func FuzzReadQueryIntoStruct(f *testing.F) {
+	f.Add("response_type=code&client_id=gin&redirect_uri=https%3A%2F%2Flocalhost%3A8081%2Flogin&scope=repo-read+repo-write&state=abc")
+	f.Add("response_type=code,token&scope=a,b,,c&state=")
+	f.Add("%zz=1&;=;&client_id&&==")
+	f.Add(strings.Repeat("scope=x&", 64))
+
+	f.Fuzz(func(t *testing.T, query string) {
+		request, err := http.NewRequest("GET", "/oauth/authorize", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.URL.RawQuery = query
+
+		param := &fuzzAuthorizeParams{}
+		err = ReadQueryIntoStruct(request, param, false)
+		if err == nil && param.ResponseType != request.URL.Query().Get("response_type") {
+			t.Errorf("Response type '%s' does not match the query '%s'", param.ResponseType, query)
+		}
+		if err != nil {
+			if _, ok := err.(*ValidationError); !ok {
+				t.Errorf("Validation error expected but was %T", err)
+			}
+		}
+	})
+}
+
+func FuzzReadFormIntoStruct(f *testing.F) {
+	f.Add("grant_type=authorization_code&code=HGZQP6WE&client_id=gin&client_secret=secret")
+	f.Add("expires_in=9223372036854775808&limit=-1&ratio=NaN&confirm=maybe")
+	f.Add("expires_in=1&expires_in=2&scope=a&scope=b&confirm=true&ratio=1e400")
+	f.Add("grant_type=%ff%fe&code=%00")
+
+	f.Fuzz(func(t *testing.T, body string) {
+		request, err := http.NewRequest("POST", "/oauth/token", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		form := &fuzzTokenForm{}
+		err = ReadFormIntoStruct(request, form, true)
+		if err != nil {
+			if _, ok := err.(*ValidationError); !ok {
+				t.Errorf("Validation error expected but was %T", err)
+			}
+		}
+	})
+}
@@ -9,6 +9,9 @@
 package util
 
 import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -17,35 +20,52 @@ import (
 	"github.com/Sirupsen/logrus"
 )
 
+// RequestIDHeader is the response header containing the id of a request.
+const RequestIDHeader = "X-Request-Id"
+
 type recoveryHandler struct {
 	handler    http.Handler
 	logger     interface{}
 	printStack bool
+	reporter   ErrorReporter
 }
 
 // RecoveryHandler recovers from a panic, writes an HTTP InternalServerError,
 // logs the panic message to the defined logging mechanism and continues
-// to the next handler.
-func RecoveryHandler(h http.Handler, l interface{}, ps bool) http.Handler {
+// to the next handler. Each response gets a request id, which is part of the
+// error message and of the report sent to the optional error reporter.
+func RecoveryHandler(h http.Handler, l interface{}, ps bool, rep ErrorReporter) http.Handler {
 	return &recoveryHandler{
 		handler:    h,
 		logger:     l,
 		printStack: ps,
+		reporter:   rep,
 	}
 }
 
 func (h recoveryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	id := newRequestID()
+	w.Header().Set(RequestIDHeader, id)
+
 	defer func() {
 		if err := recover(); err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			h.log(err)
+			stack := debug.Stack()
+			http.Error(w, fmt.Sprintf("%s (request id: %s)", http.StatusText(http.StatusInternalServerError), id),
+				http.StatusInternalServerError)
+			h.log(fmt.Sprintf("%v (request id: %s)", err, id), stack)
+			if h.reporter != nil {
+				rerr := h.reporter.Report(err, stack, req, id)
+				if rerr != nil {
+					h.log("Error reporting failed: "+rerr.Error(), nil)
+				}
+			}
 		}
 	}()
 
 	h.handler.ServeHTTP(w, req)
 }
 
-func (h recoveryHandler) log(msg interface{}) {
+func (h recoveryHandler) log(msg interface{}, stack []byte) {
 	if h.logger != nil {
 		switch h.logger.(type) {
 		default:
@@ -56,7 +76,17 @@ func (h recoveryHandler) log(msg interface{}) {
 		}
 	}
 
-	if h.printStack {
-		debug.PrintStack()
+	if h.printStack && stack != nil {
+		os.Stderr.Write(stack)
+	}
+}
+
+// newRequestID returns a random id for a request.
+func newRequestID() string {
+	rnd := make([]byte, 8)
+	_, err := rand.Read(rnd)
+	if err != nil {
+		panic(err)
 	}
+	return hex.EncodeToString(rnd)
 }
@@ -0,0 +1,63 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testReporter struct {
+	err       interface{}
+	requestID string
+}
+
+func (rep *testReporter) Report(err interface{}, stack []byte, r *http.Request, requestID string) error {
+	rep.err = err
+	rep.requestID = requestID
+	return nil
+}
+
+func TestRecoveryHandler(t *testing.T) {
+	reporter := &testReporter{}
+	handler := RecoveryHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/panic" {
+			panic("something went wrong")
+		}
+	}), nil, false, reporter)
+
+	request, _ := http.NewRequest("GET", "/ok", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if response.Header().Get(RequestIDHeader) == "" {
+		t.Error("Request id header expected")
+	}
+	if reporter.err != nil {
+		t.Error("No error should be reported")
+	}
+
+	request, _ = http.NewRequest("GET", "/panic", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusInternalServerError, response.Code)
+	}
+	id := response.Header().Get(RequestIDHeader)
+	if id == "" || !strings.Contains(response.Body.String(), id) {
+		t.Error("Response body should contain the request id")
+	}
+	if reporter.err != "something went wrong" || reporter.requestID != id {
+		t.Error("Panic was not properly reported")
+	}
+}
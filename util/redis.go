@@ -0,0 +1,187 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Timeout for connecting to redis and for each command
+const redisTimeout = 5 * time.Second
+
+// RedisError is an error reply sent by a redis server.
+type RedisError string
+
+// Error implements the error interface.
+func (err RedisError) Error() string {
+	return string(err)
+}
+
+// RedisClient is a minimal client for the redis protocol. It uses a single connection
+// which is opened on first use and reopened after network errors. A client is safe for
+// concurrent use, but commands are executed one after another.
+type RedisClient struct {
+	address  string
+	password string
+	db       int
+
+	lock   sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// NewRedisClient creates a client for the redis server at address. If password is not
+// empty the client authenticates, if db is not 0 the respective database is selected.
+func NewRedisClient(address, password string, db int) *RedisClient {
+	return &RedisClient{address: address, password: password, db: db}
+}
+
+// Do sends a command to the server and returns the reply. Depending on the type of the
+// reply the result is a string, an int64, nil or a []interface{} of these types. Error
+// replies are returned as RedisError.
+func (c *RedisClient) Do(args ...string) (interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.conn == nil {
+		err := c.connect()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := c.do(args...)
+	if _, ok := err.(RedisError); err != nil && !ok {
+		c.Close()
+	}
+	return reply, err
+}
+
+// Strings sends a command to the server and returns an array reply as strings.
+// Nil elements are returned as empty strings.
+func (c *RedisClient) Strings(args ...string) ([]string, error) {
+	reply, err := c.Do(args...)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := reply.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Unexpected redis reply %v", reply)
+	}
+	strs := make([]string, len(list))
+	for i, elem := range list {
+		strs[i], _ = elem.(string)
+	}
+	return strs, nil
+}
+
+// Close closes the connection to the server. The next command opens a new connection.
+func (c *RedisClient) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	c.reader = nil
+	return err
+}
+
+func (c *RedisClient) connect() error {
+	conn, err := net.DialTimeout("tcp", c.address, redisTimeout)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	c.reader = bufio.NewReader(conn)
+
+	if c.password != "" {
+		if _, err = c.do("AUTH", c.password); err != nil {
+			c.Close()
+			return err
+		}
+	}
+	if c.db != 0 {
+		if _, err = c.do("SELECT", strconv.Itoa(c.db)); err != nil {
+			c.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *RedisClient) do(args ...string) (interface{}, error) {
+	c.conn.SetDeadline(time.Now().Add(redisTimeout))
+
+	buf := make([]byte, 0, 64)
+	buf = append(buf, fmt.Sprintf("*%d\r\n", len(args))...)
+	for _, arg := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n", len(arg))...)
+		buf = append(buf, arg...)
+		buf = append(buf, "\r\n"...)
+	}
+	_, err := c.conn.Write(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	return readRedisReply(c.reader)
+}
+
+// readRedisReply reads one reply of the redis protocol.
+func readRedisReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("Invalid redis reply")
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, RedisError(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		data := make([]byte, n+2)
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return nil, err
+		}
+		return string(data[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		list := make([]interface{}, n)
+		for i := range list {
+			list[i], err = readRedisReply(r)
+			if _, ok := err.(RedisError); err != nil && !ok {
+				return nil, err
+			}
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("Invalid redis reply type '%c'", kind)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestReadRedisReply(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("+OK\r\n:42\r\n$5\r\nhello\r\n$-1\r\n*2\r\n$1\r\na\r\n$-1\r\n-ERR wrong\r\n"))
+
+	if reply, err := readRedisReply(r); err != nil || reply != "OK" {
+		t.Errorf("Simple string expected but was %v (%v)", reply, err)
+	}
+	if reply, err := readRedisReply(r); err != nil || reply != int64(42) {
+		t.Errorf("Integer expected but was %v (%v)", reply, err)
+	}
+	if reply, err := readRedisReply(r); err != nil || reply != "hello" {
+		t.Errorf("Bulk string expected but was %v (%v)", reply, err)
+	}
+	if reply, err := readRedisReply(r); err != nil || reply != nil {
+		t.Errorf("Nil expected but was %v (%v)", reply, err)
+	}
+	reply, err := readRedisReply(r)
+	if list, ok := reply.([]interface{}); err != nil || !ok || len(list) != 2 || list[0] != "a" || list[1] != nil {
+		t.Errorf("Array expected but was %v (%v)", reply, err)
+	}
+	if _, err := readRedisReply(r); err != RedisError("ERR wrong") {
+		t.Errorf("Error reply expected but was %v", err)
+	}
+}
+
+func TestRedisClient(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+
+	commands := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			reply, err := readRedisReply(r)
+			if err != nil {
+				return
+			}
+			args := make([]string, 0)
+			for _, arg := range reply.([]interface{}) {
+				args = append(args, arg.(string))
+			}
+			commands <- strings.Join(args, " ")
+			switch args[0] {
+			case "AUTH", "SELECT":
+				conn.Write([]byte("+OK\r\n"))
+			case "SMEMBERS":
+				conn.Write([]byte("*2\r\n$1\r\na\r\n$1\r\nb\r\n"))
+			default:
+				conn.Write([]byte("-ERR unknown command\r\n"))
+			}
+		}
+	}()
+
+	client := NewRedisClient(listener.Addr().String(), "secret", 2)
+	defer client.Close()
+
+	members, err := client.Strings("SMEMBERS", "set")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members[0] != "a" || members[1] != "b" {
+		t.Errorf("Unexpected members: %v", members)
+	}
+	for _, expected := range []string{"AUTH secret", "SELECT 2", "SMEMBERS set"} {
+		if cmd := <-commands; cmd != expected {
+			t.Errorf("Command '%s' expected but was '%s'", expected, cmd)
+		}
+	}
+
+	_, err = client.Do("FOO")
+	if _, ok := err.(RedisError); !ok {
+		t.Errorf("Redis error expected but was %v", err)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrorReporter defines an interface for reporting recovered panics to an external service.
+type ErrorReporter interface {
+	Report(err interface{}, stack []byte, r *http.Request, requestID string) error
+}
+
+type sentryReporter struct {
+	storeURL    string
+	auth        string
+	environment string
+	client      *http.Client
+}
+
+// NewSentryReporter returns an ErrorReporter that sends errors as events to the store
+// endpoint of a Sentry compatible service. The DSN has the form
+// "https://<key>[:<secret>]@<host>[/<path>]/<project>".
+func NewSentryReporter(dsn, environment string) (ErrorReporter, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if u.User == nil || u.User.Username() == "" || u.Host == "" {
+		return nil, errors.New("Sentry DSN must contain a key and a host")
+	}
+
+	i := strings.LastIndex(u.Path, "/")
+	project := u.Path[i+1:]
+	if project == "" {
+		return nil, errors.New("Sentry DSN must contain a project id")
+	}
+
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=gin-auth/1.0, sentry_key=%s", u.User.Username())
+	if secret, ok := u.User.Password(); ok {
+		auth += ", sentry_secret=" + secret
+	}
+
+	return &sentryReporter{
+		storeURL:    fmt.Sprintf("%s://%s%s/api/%s/store/", u.Scheme, u.Host, u.Path[:i], project),
+		auth:        auth,
+		environment: environment,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Report sends an error event to the Sentry store endpoint.
+func (s *sentryReporter) Report(err interface{}, stack []byte, r *http.Request, requestID string) error {
+	id := make([]byte, 16)
+	_, e := rand.Read(id)
+	if e != nil {
+		return e
+	}
+
+	event := map[string]interface{}{
+		"event_id":    hex.EncodeToString(id),
+		"timestamp":   time.Now().UTC().Format("2006-01-02T15:04:05"),
+		"level":       "error",
+		"platform":    "go",
+		"logger":      "gin-auth",
+		"environment": s.environment,
+		"message":     fmt.Sprint(err),
+		"tags":        map[string]string{"request_id": requestID},
+		"extra":       map[string]string{"stacktrace": string(stack)},
+		"request": map[string]string{
+			"url":    r.URL.Path,
+			"method": r.Method,
+		},
+	}
+	body, e := json.Marshal(event)
+	if e != nil {
+		return e
+	}
+
+	req, e := http.NewRequest("POST", s.storeURL, bytes.NewReader(body))
+	if e != nil {
+		return e
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", s.auth)
+
+	res, e := s.client.Do(req)
+	if e != nil {
+		return e
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error report rejected with status %d", res.StatusCode)
+	}
+	return nil
+}
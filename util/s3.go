@@ -0,0 +1,223 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timeout of requests to an S3 compatible service
+const s3Timeout = 60 * time.Second
+
+// Payload hash of presigned URLs, the content is not known when the URL is signed
+const s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3BlobStore stores blobs in a bucket of an S3 compatible service. Requests and URLs are
+// signed with AWS signature version 4.
+type S3BlobStore struct {
+	endpoint  string
+	region    string
+	bucket    string
+	accessKey string
+	secretKey string
+	pathStyle bool
+	client    *http.Client
+}
+
+func newS3Client() *http.Client {
+	return &http.Client{Timeout: s3Timeout}
+}
+
+// Put uploads a blob, an existing blob with the same key is replaced.
+func (s *S3BlobStore) Put(key string, content io.Reader, contentType string) error {
+	body, err := ioutil.ReadAll(content)
+	if err != nil {
+		return err
+	}
+	res, err := s.do("PUT", key, body, contentType)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// Get downloads the blob with the given key.
+func (s *S3BlobStore) Get(key string) (io.ReadCloser, error) {
+	res, err := s.do("GET", key, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// Delete removes the blob with the given key. Since S3 does not report whether an object
+// existed, deleting a missing blob is not an error.
+func (s *S3BlobStore) Delete(key string) error {
+	res, err := s.do("DELETE", key, nil, "")
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+// SignedURL returns a presigned download URL for the blob which is valid for the given life time,
+// at most seven days.
+func (s *S3BlobStore) SignedURL(key string, lifeTime time.Duration) (string, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return "", err
+	}
+	if lifeTime > 7*24*time.Hour {
+		lifeTime = 7 * 24 * time.Hour
+	}
+
+	now := Now().UTC()
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", "AWS4-HMAC-SHA256")
+	query.Set("X-Amz-Credential", s.accessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(lifeTime/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+	query.Set("X-Amz-Signature", s.signature("GET", u, query, map[string]string{"host": u.Host}, s3UnsignedPayload, now))
+
+	return u.String() + "?" + s3Query(query), nil
+}
+
+// do sends a signed request for an object and returns the response if it was successful.
+func (s *S3BlobStore) do(method, key string, body []byte, contentType string) (*http.Response, error) {
+	u, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := Now().UTC()
+	sum := sha256.Sum256(body)
+	payload := hex.EncodeToString(sum[:])
+	headers := map[string]string{
+		"host":                 u.Host,
+		"x-amz-content-sha256": payload,
+		"x-amz-date":           now.Format("20060102T150405Z"),
+	}
+	signature := s.signature(method, u, url.Values{}, headers, payload, now)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Amz-Content-Sha256", headers["x-amz-content-sha256"])
+	req.Header.Set("X-Amz-Date", headers["x-amz-date"])
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, s.scope(now), s3SignedHeaders(headers), signature))
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound && method == "GET" {
+		res.Body.Close()
+		return nil, ErrBlobNotFound
+	}
+	if res.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 512))
+		res.Body.Close()
+		return nil, fmt.Errorf("S3 responded with status %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return res, nil
+}
+
+// objectURL returns the URL of the object with the given key. Valid keys only contain characters
+// which need no escaping in URLs.
+func (s *S3BlobStore) objectURL(key string) (*url.URL, error) {
+	if !IsValidBlobKey(key) {
+		return nil, fmt.Errorf("Invalid blob key '%s'", key)
+	}
+	u, err := url.Parse(s.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if s.pathStyle {
+		u.Path = "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+	return u, nil
+}
+
+// scope returns the credential scope of a request at the given time.
+func (s *S3BlobStore) scope(t time.Time) string {
+	return t.Format("20060102") + "/" + s.region + "/s3/aws4_request"
+}
+
+// signature calculates the AWS signature version 4 of a request.
+func (s *S3BlobStore) signature(method string, u *url.URL, query url.Values, headers map[string]string,
+	payload string, t time.Time) string {
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + strings.TrimSpace(headers[name]) + "\n"
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method, u.EscapedPath(), s3Query(query), canonicalHeaders, s3SignedHeaders(headers), payload,
+	}, "\n")
+	sum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256", t.Format("20060102T150405Z"), s.scope(t), hex.EncodeToString(sum[:]),
+	}, "\n")
+
+	key := s3HMAC([]byte("AWS4"+s.secretKey), t.Format("20060102"))
+	key = s3HMAC(key, s.region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+	return hex.EncodeToString(s3HMAC(key, stringToSign))
+}
+
+// s3SignedHeaders returns the sorted names of the signed headers separated by semicolons.
+func s3SignedHeaders(headers map[string]string) string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ";")
+}
+
+// s3Query encodes query parameters sorted by name, spaces are encoded as %20.
+func s3Query(query url.Values) string {
+	return strings.Replace(query.Encode(), "+", "%20", -1)
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
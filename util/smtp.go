@@ -0,0 +1,191 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"io/ioutil"
+	"net"
+	"net/smtp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+// SmtpPool sends e-mails via smtp and keeps connections open for reuse.
+// If DKIM is configured, all e-mails are signed before they are sent.
+type SmtpPool struct {
+	config *conf.SmtpCredentials
+	signer *DKIMSigner
+	lock   sync.Mutex
+	idle   []*smtpConn
+}
+
+type smtpConn struct {
+	client   *smtp.Client
+	lastUsed time.Time
+}
+
+var smtpPool *SmtpPool
+var smtpPoolLock = sync.Mutex{}
+
+// GetSmtpPool returns the smtp pool for the configured smtp server.
+// The pool is created when called the first time.
+func GetSmtpPool() (*SmtpPool, error) {
+	smtpPoolLock.Lock()
+	defer smtpPoolLock.Unlock()
+
+	if smtpPool == nil {
+		pool, err := NewSmtpPool(conf.GetSmtpCredentials())
+		if err != nil {
+			return nil, err
+		}
+		smtpPool = pool
+	}
+
+	return smtpPool, nil
+}
+
+// NewSmtpPool creates a new smtp pool and loads the DKIM key if configured.
+func NewSmtpPool(config *conf.SmtpCredentials) (*SmtpPool, error) {
+	pool := &SmtpPool{config: config}
+	if config.DKIMKeyFile != "" {
+		key, err := ioutil.ReadFile(config.DKIMKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		pool.signer, err = NewDKIMSigner(config.DKIMDomain, config.DKIMSelector, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pool, nil
+}
+
+// Send sends an e-mail over an idle connection or a new connection if none is available.
+func (p *SmtpPool) Send(from string, recipient []string, content []byte) error {
+	var err error
+	if p.signer != nil {
+		content, err = p.signer.Sign(content)
+		if err != nil {
+			return err
+		}
+	}
+
+	conn, err := p.get()
+	if err != nil {
+		return err
+	}
+
+	err = conn.send(from, recipient, content)
+	if err != nil {
+		conn.client.Close()
+		return err
+	}
+
+	p.put(conn)
+	return nil
+}
+
+// Close closes all idle connections.
+func (p *SmtpPool) Close() {
+	p.lock.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.lock.Unlock()
+
+	for _, conn := range idle {
+		conn.client.Quit()
+	}
+}
+
+// get returns an idle connection which is still usable or opens a new connection.
+func (p *SmtpPool) get() (*smtpConn, error) {
+	for {
+		p.lock.Lock()
+		if len(p.idle) == 0 {
+			p.lock.Unlock()
+			break
+		}
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		p.lock.Unlock()
+
+		if time.Since(conn.lastUsed) < p.config.IdleTimeout && conn.client.Reset() == nil {
+			return conn, nil
+		}
+		conn.client.Close()
+	}
+
+	return p.dial()
+}
+
+// put returns a connection to the pool or closes it if the pool is full.
+func (p *SmtpPool) put(conn *smtpConn) {
+	conn.lastUsed = time.Now()
+
+	p.lock.Lock()
+	if len(p.idle) < p.config.PoolSize {
+		p.idle = append(p.idle, conn)
+		conn = nil
+	}
+	p.lock.Unlock()
+
+	if conn != nil {
+		conn.client.Quit()
+	}
+}
+
+// dial opens a new connection, upgrades it via STARTTLS and authenticates if credentials are set.
+func (p *SmtpPool) dial() (*smtpConn, error) {
+	addr := p.config.Host + ":" + strconv.Itoa(p.config.Port)
+	netCon, err := net.DialTimeout("tcp", addr, time.Second*10)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := smtp.NewClient(netCon, p.config.Host)
+	if err != nil {
+		netCon.Close()
+		return nil, err
+	}
+
+	err = conf.SmtpStartTLS(client, p.config)
+	if err == nil && (p.config.Username != "" || p.config.Password != "") {
+		err = client.Auth(smtp.PlainAuth("", p.config.Username, p.config.Password, p.config.Host))
+	}
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	return &smtpConn{client: client}, nil
+}
+
+func (conn *smtpConn) send(from string, recipient []string, content []byte) error {
+	err := conn.client.Mail(from)
+	if err != nil {
+		return err
+	}
+	for _, to := range recipient {
+		if err = conn.client.Rcpt(to); err != nil {
+			return err
+		}
+	}
+	w, err := conn.client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(content); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
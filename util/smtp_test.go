@@ -0,0 +1,137 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+// fakeSmtpServer accepts smtp connections and counts connections and received messages.
+type fakeSmtpServer struct {
+	listener    net.Listener
+	lock        sync.Mutex
+	connections int
+	messages    []string
+}
+
+func newFakeSmtpServer(t *testing.T) *fakeSmtpServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeSmtpServer{listener: l}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.lock.Lock()
+			s.connections++
+			s.lock.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeSmtpServer) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	w("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"):
+			w("250 localhost")
+		case cmd == "DATA":
+			w("354 go ahead")
+			var msg []string
+			for {
+				line, err = r.ReadString('\n')
+				if err != nil || line == ".\r\n" {
+					break
+				}
+				msg = append(msg, line)
+			}
+			s.lock.Lock()
+			s.messages = append(s.messages, strings.Join(msg, ""))
+			s.lock.Unlock()
+			w("250 ok")
+		case cmd == "QUIT":
+			w("221 bye")
+			return
+		default:
+			w("250 ok")
+		}
+	}
+}
+
+func (s *fakeSmtpServer) counts() (int, int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.connections, len(s.messages)
+}
+
+func TestSmtpPool_Send(t *testing.T) {
+	server := newFakeSmtpServer(t)
+	defer server.listener.Close()
+
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	config := &conf.SmtpCredentials{StartTLS: "auto", PoolSize: 1, IdleTimeout: time.Minute, Host: host}
+	config.Port, _ = strconv.Atoi(port)
+
+	pool, err := NewSmtpPool(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	for i := 0; i < 3; i++ {
+		err = pool.Send("no-reply@example.com", []string{"alice@example.com"}, []byte("Subject: Test\n\nBody\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	connections, messages := server.counts()
+	if connections != 1 || messages != 3 {
+		t.Errorf("Expected 3 messages over 1 connection but got %d messages over %d connections", messages, connections)
+	}
+
+	// expired idle connection
+	config.IdleTimeout = 0
+	err = pool.Send("no-reply@example.com", []string{"alice@example.com"}, []byte("Subject: Test\n\nBody\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if connections, _ = server.counts(); connections != 2 {
+		t.Errorf("Expected a new connection but got %d connections", connections)
+	}
+
+	// server does not offer STARTTLS
+	config.StartTLS = "require"
+	err = pool.Send("no-reply@example.com", []string{"alice@example.com"}, []byte("Subject: Test\n\nBody\n"))
+	if err == nil {
+		t.Error("Send without STARTTLS should fail if STARTTLS is required")
+	}
+}
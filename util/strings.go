@@ -16,6 +16,8 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var arrayRegex = regexp.MustCompile(`((((([^",\\{}\s]|NULL)+|"([^"\\]|\\"|\\\\)*")))(,)?)`)
@@ -31,6 +33,24 @@ func ToSnakeCase(str string) string {
 	return strings.ToLower(snake)
 }
 
+// SanitizeString replaces invalid UTF-8 sequences in s by the replacement character, removes
+// control characters and truncates the result to at most max bytes without splitting a character.
+func SanitizeString(s string, max int) string {
+	clean := make([]byte, 0, len(s))
+	buf := make([]byte, utf8.UTFMax)
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			continue
+		}
+		n := utf8.EncodeRune(buf, r)
+		if len(clean)+n > max {
+			break
+		}
+		clean = append(clean, buf[:n]...)
+	}
+	return string(clean)
+}
+
 // NewStringSet creates a new StringSet from a slice of strings.
 func NewStringSet(strs ...string) StringSet {
 	set := StringSet{}
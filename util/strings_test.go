@@ -37,6 +37,21 @@ func TestToSnakeCase(t *testing.T) {
 	}
 }
 
+func TestSanitizeString(t *testing.T) {
+	if s := SanitizeString("Firefox\x00\n/52", 64); s != "Firefox/52" {
+		t.Errorf("Control characters expected to be removed but was %q", s)
+	}
+	if s := SanitizeString("a\xffb", 64); s != "a\uFFFDb" {
+		t.Errorf("Invalid byte expected to be replaced but was %q", s)
+	}
+	if s := SanitizeString("aäb", 2); s != "a" {
+		t.Errorf("Character expected not to be split but was %q", s)
+	}
+	if s := SanitizeString("aäb", 3); s != "aä" {
+		t.Errorf("Truncated string 'aä' expected but was %q", s)
+	}
+}
+
 func TestStringSet(t *testing.T) {
 	set := NewStringSet("a", "b")
 	if !set.Contains("a") {
@@ -0,0 +1,74 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"fmt"
+	"time"
+)
+
+// Default timezone and locale of accounts without preferences.
+const (
+	DefaultTimezone = "UTC"
+	DefaultLocale   = "en"
+)
+
+// Layouts of date and time of day for all supported locales.
+var timeLayouts = map[string][2]string{
+	"en":    {"2006-01-02", "15:04"},
+	"en-US": {"01/02/2006", "3:04 PM"},
+	"en-GB": {"02/01/2006", "15:04"},
+	"de":    {"02.01.2006", "15:04"},
+	"fr":    {"02/01/2006", "15:04"},
+}
+
+// IsLocaleSupported checks whether timestamps can be formatted for the given locale.
+func IsLocaleSupported(locale string) bool {
+	_, ok := timeLayouts[locale]
+	return ok
+}
+
+// LoadTimezone returns the location of an IANA timezone name such as "Europe/Berlin".
+// In contrast to time.LoadLocation the timezone of the server ("Local") is not accepted.
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("Unknown timezone '%s'", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// TimeFormat formats timestamps in the timezone and locale preferred by a user.
+type TimeFormat struct {
+	Location *time.Location
+	Locale   string
+}
+
+// NewTimeFormat returns a TimeFormat for the given timezone and locale. Unknown timezones
+// and locales fall back to the defaults.
+func NewTimeFormat(timezone, locale string) *TimeFormat {
+	loc, err := LoadTimezone(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	if !IsLocaleSupported(locale) {
+		locale = DefaultLocale
+	}
+	return &TimeFormat{Location: loc, Locale: locale}
+}
+
+// Format returns date and time of t, e.g. for use in templates: {{ $.Time.Format .CreatedAt }}
+func (f *TimeFormat) Format(t time.Time) string {
+	layout := timeLayouts[f.Locale]
+	return t.In(f.Location).Format(layout[0] + " " + layout[1])
+}
+
+// Date returns the date of t without the time of day.
+func (f *TimeFormat) Date(t time.Time) string {
+	return t.In(f.Location).Format(timeLayouts[f.Locale][0])
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeFormat(t *testing.T) {
+	ts := time.Date(2016, 3, 14, 15, 9, 0, 0, time.UTC)
+
+	f := NewTimeFormat("", "")
+	if s := f.Format(ts); s != "2016-03-14 15:09" {
+		t.Errorf("Unexpected default format: %s", s)
+	}
+
+	f = NewTimeFormat("Europe/Berlin", "de")
+	if s := f.Format(ts); s != "14.03.2016 16:09" {
+		t.Errorf("Unexpected format: %s", s)
+	}
+	if s := f.Date(ts); s != "14.03.2016" {
+		t.Errorf("Unexpected date: %s", s)
+	}
+
+	f = NewTimeFormat("America/New_York", "en-US")
+	if s := f.Format(ts); s != "03/14/2016 11:09 AM" {
+		t.Errorf("Unexpected format: %s", s)
+	}
+
+	f = NewTimeFormat("Local", "xx")
+	if f.Location != time.UTC || f.Locale != DefaultLocale {
+		t.Errorf("Defaults expected for unknown timezone and locale: %+v", f)
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	if _, err := LoadTimezone("Europe/Berlin"); err != nil {
+		t.Error(err)
+	}
+	for _, name := range []string{"", "Local", "Mars/Olympus"} {
+		if _, err := LoadTimezone(name); err == nil {
+			t.Errorf("Error expected for timezone '%s'", name)
+		}
+	}
+}
@@ -10,6 +10,8 @@ package util
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base32"
 	"strings"
 )
@@ -26,3 +28,11 @@ func RandomToken() string {
 
 	return strings.Trim(base32.StdEncoding.EncodeToString(rnd), "=")
 }
+
+// EqualTokens compares two secret strings in constant time. The strings are hashed first,
+// such that the time does not even depend on their lengths.
+func EqualTokens(a, b string) bool {
+	sumA := sha256.Sum256([]byte(a))
+	sumB := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(sumA[:], sumB[:]) == 1
+}
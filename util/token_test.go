@@ -18,3 +18,14 @@ func TestRandomToken(t *testing.T) {
 		t.Error("Token length is expected to be 103")
 	}
 }
+
+func TestEqualTokens(t *testing.T) {
+	if !EqualTokens("3N7MP7M7", "3N7MP7M7") {
+		t.Error("Equal tokens expected to match")
+	}
+	for _, other := range []string{"3N7MP7M8", "3N7MP7M", "3N7MP7M77", ""} {
+		if EqualTokens("3N7MP7M7", other) {
+			t.Errorf("Token '%s' should not match", other)
+		}
+	}
+}
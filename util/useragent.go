@@ -0,0 +1,91 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import "strings"
+
+// UserAgent contains the browser (or command line client) and operating system
+// recognized in a User-Agent header.
+type UserAgent struct {
+	Browser string
+	OS      string
+}
+
+// uaPattern maps a substring of a User-Agent header to a name. Patterns are
+// checked in order, since e.g. Chrome also claims to be Safari.
+type uaPattern struct {
+	match string
+	name  string
+}
+
+var uaBrowsers = []uaPattern{
+	{"gin-cli", "gin-cli"},
+	{"git/", "git"},
+	{"curl/", "curl"},
+	{"Wget/", "Wget"},
+	{"python-requests/", "Python"},
+	{"Go-http-client/", "Go"},
+	{"Edg/", "Edge"},
+	{"Edge/", "Edge"},
+	{"OPR/", "Opera"},
+	{"Opera", "Opera"},
+	{"SamsungBrowser/", "Samsung Internet"},
+	{"Firefox/", "Firefox"},
+	{"Chromium/", "Chromium"},
+	{"Chrome/", "Chrome"},
+	{"CriOS/", "Chrome"},
+	{"Safari/", "Safari"},
+	{"MSIE ", "Internet Explorer"},
+	{"Trident/", "Internet Explorer"},
+}
+
+var uaSystems = []uaPattern{
+	{"Windows", "Windows"},
+	{"Android", "Android"},
+	{"iPhone", "iOS"},
+	{"iPad", "iOS"},
+	{"Mac OS X", "macOS"},
+	{"Macintosh", "macOS"},
+	{"CrOS", "ChromeOS"},
+	{"FreeBSD", "FreeBSD"},
+	{"Linux", "Linux"},
+}
+
+// ParseUserAgent recognizes the browser and operating system in a User-Agent header.
+// Unknown parts are left empty.
+func ParseUserAgent(header string) UserAgent {
+	ua := UserAgent{}
+	for _, p := range uaBrowsers {
+		if strings.Contains(header, p.match) {
+			ua.Browser = p.name
+			break
+		}
+	}
+	for _, p := range uaSystems {
+		if strings.Contains(header, p.match) {
+			ua.OS = p.name
+			break
+		}
+	}
+	return ua
+}
+
+// String returns a human readable label of the device, e.g. "Firefox on Linux".
+func (ua UserAgent) String() string {
+	switch {
+	case ua.Browser != "" && ua.OS != "":
+		return ua.Browser + " on " + ua.OS
+	case ua.Browser != "":
+		return ua.Browser
+	case ua.OS != "":
+		return "Unknown browser on " + ua.OS
+	default:
+		return "Unknown device"
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+// WebhookSignature returns the value of the header X-Gin-Signature for a webhook body:
+// the hex encoded HMAC-SHA256 of the body, prefixed with "sha256=".
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostWebhook posts a JSON body to a webhook URL using the given client. If a secret is given
+// the body is signed in the header X-Gin-Signature (see WebhookSignature). Responses with a
+// status other than 2xx are returned as error.
+func PostWebhook(client *http.Client, url, secret string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set("X-Gin-Signature", WebhookSignature(secret, body))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("Webhook responded with status %d", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPostWebhook(t *testing.T) {
+	const secret = "webhook-secret"
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	var signature, contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get("X-Gin-Signature")
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	err := PostWebhook(client, server.URL, secret, []byte(`{"event": "test"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != `{"event": "test"}` || contentType != "application/json" {
+		t.Errorf("Body was not properly sent: %s", body)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	if signature != "sha256="+hex.EncodeToString(mac.Sum(nil)) {
+		t.Errorf("Invalid signature '%s'", signature)
+	}
+
+	// no secret
+	err = PostWebhook(client, server.URL, "", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature != "" {
+		t.Errorf("No signature expected but was '%s'", signature)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	err = PostWebhook(client, failing.URL, "", []byte(`{}`))
+	if err == nil {
+		t.Error("Error expected for failing webhook")
+	}
+}
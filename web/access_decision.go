@@ -0,0 +1,89 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/Sirupsen/logrus"
+)
+
+// AuthorizeAccess is a handler which decides whether an account, identified by an access token
+// or the fingerprint of an ssh key, may perform an action on the repositories of an owner.
+// Services like gin-repo authenticate with their client credentials via basic authentication,
+// only clients configured as resource servers may query decisions. The decision and the effective
+// roles of the account are returned as JSON.
+func AuthorizeAccess(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	client, exists := data.GetClientByName(clientID)
+	if !ok || !exists || !client.AcceptsSecret(clientSecret) {
+		PrintErrorJSON(w, r, "Wrong client id or client secret", http.StatusUnauthorized)
+		return
+	}
+	if !client.ResourceServer {
+		PrintErrorJSON(w, r, "Only resource servers may query access decisions", http.StatusForbidden)
+		return
+	}
+
+	body := &struct {
+		Token       string `json:"token"`
+		Fingerprint string `json:"fingerprint"`
+		IP          string `json:"ip"`
+		Owner       string `json:"owner"`
+		Action      string `json:"action"`
+		Public      bool   `json:"public"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	decision, err := data.AuthorizeAccess(&data.AccessRequest{
+		Token:       body.Token,
+		Fingerprint: body.Fingerprint,
+		IP:          body.IP,
+		Owner:       body.Owner,
+		Action:      body.Action,
+		Public:      body.Public,
+	})
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "authorize-access",
+		"client": client.Name,
+		"login":  decision.Login,
+		"owner":  body.Owner,
+		"action": body.Action,
+		"allow":  decision.Allow,
+	}).Info(decision.Reason)
+
+	marshal := &struct {
+		Allow  bool     `json:"allow"`
+		Login  string   `json:"login,omitempty"`
+		Roles  []string `json:"roles"`
+		Reason string   `json:"reason,omitempty"`
+	}{
+		Allow:  decision.Allow,
+		Login:  decision.Login,
+		Roles:  decision.Roles,
+		Reason: decision.Reason,
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
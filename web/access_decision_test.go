@@ -0,0 +1,82 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthorizeAccess(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	mkClientRequest := func(client, body, secret string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/api/authorize-access", strings.NewReader(body))
+		request.SetBasicAuth(client, secret)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+	mkRequest := func(body, secret string) *httptest.ResponseRecorder {
+		return mkClientRequest("gin", body, secret)
+	}
+
+	// wrong client secret
+	response := mkRequest(`{"token": "3N7MP7M7", "owner": "alice", "action": "write"}`, "wrongsecret")
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// client which is not a resource server
+	response = mkClientRequest("wb", `{"token": "3N7MP7M7", "owner": "alice", "action": "write"}`, "secret")
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// invalid action
+	response = mkRequest(`{"token": "3N7MP7M7", "owner": "alice", "action": "delete"}`, "secret")
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	decision := &struct {
+		Allow bool     `json:"allow"`
+		Login string   `json:"login"`
+		Roles []string `json:"roles"`
+	}{}
+
+	// owner with token
+	response = mkRequest(`{"token": "3N7MP7M7", "owner": "alice", "action": "write"}`, "secret")
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	err := json.NewDecoder(response.Body).Decode(decision)
+	if err != nil {
+		t.Error(err)
+	}
+	if !decision.Allow || decision.Login != "alice" || len(decision.Roles) != 1 || decision.Roles[0] != "owner" {
+		t.Errorf("Unexpected decision: %+v", decision)
+	}
+
+	// other account with ssh key
+	response = mkRequest(`{"fingerprint": "SHA256:x9nS/Siw6cUy0qemb10V0dSK8YQYS2BKvV5KFowitUw", "owner": "alice", "action": "read"}`, "secret")
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	err = json.NewDecoder(response.Body).Decode(decision)
+	if err != nil {
+		t.Error(err)
+	}
+	if decision.Allow || decision.Login != "bob" {
+		t.Errorf("Unexpected decision: %+v", decision)
+	}
+}
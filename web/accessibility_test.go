@@ -0,0 +1,219 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+var (
+	scriptPattern    = regexp.MustCompile(`(?is)<script\b.*?</script>`)
+	tagPattern       = regexp.MustCompile(`(?s)<([a-zA-Z][a-zA-Z0-9]*)((?:\s+[^\s=/>]+(?:\s*=\s*(?:"[^"]*"|'[^']*'|[^\s>]+))?)*)\s*/?>`)
+	attrPattern      = regexp.MustCompile(`([^\s=/>]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+)))?`)
+	namedPattern     = regexp.MustCompile(`(?is)<(a|button|label)\b([^>]*)>(.*?)</(?:a|button|label)>`)
+	innerTagPattern  = regexp.MustCompile(`(?s)<[^>]*>`)
+	innerAltPattern  = regexp.MustCompile(`(?is)<img\b[^>]*\balt="[^"]+"`)
+	labelledElements = map[string]bool{"input": true, "select": true, "textarea": true}
+)
+
+// htmlAttrs parses the attributes of a start tag. Attributes without value map to an empty string.
+func htmlAttrs(s string) map[string]string {
+	attrs := make(map[string]string)
+	for _, m := range attrPattern.FindAllStringSubmatch(s, -1) {
+		attrs[strings.ToLower(m[1])] = m[2] + m[3] + m[4]
+	}
+	return attrs
+}
+
+// checkAccessibility reports all accessibility violations of an html page as test errors.
+func checkAccessibility(t *testing.T, page string) {
+	for _, violation := range accessibilityViolations(page) {
+		t.Errorf("Accessibility: %s", violation)
+	}
+}
+
+// accessibilityViolations checks an html page against basic accessibility rules: the language
+// must be set, images need a text alternative, form controls a label, links and buttons an
+// accessible name, forms must work without JavaScript and ids must be unique.
+func accessibilityViolations(page string) []string {
+	var violations []string
+	report := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+	page = scriptPattern.ReplaceAllString(page, "")
+
+	if !regexp.MustCompile(`<html\b[^>]*\blang="[^"]+"`).MatchString(page) {
+		report("html element without lang attribute")
+	}
+
+	ids := make(map[string]bool)
+	labels := make(map[string]bool)
+	references := make([]string, 0)
+	controls := make([]map[string]string, 0)
+	for _, m := range tagPattern.FindAllStringSubmatch(page, -1) {
+		tag := strings.ToLower(m[1])
+		attrs := htmlAttrs(m[2])
+
+		if id, ok := attrs["id"]; ok {
+			if ids[id] {
+				report("duplicate id '%s'", id)
+			}
+			ids[id] = true
+		}
+		for _, attr := range []string{"aria-describedby", "aria-labelledby"} {
+			if ref, ok := attrs[attr]; ok {
+				references = append(references, strings.Fields(ref)...)
+			}
+		}
+		for name := range attrs {
+			if strings.HasPrefix(name, "on") {
+				report("<%s> with inline event handler '%s'", tag, name)
+			}
+		}
+		if tabindex, ok := attrs["tabindex"]; ok {
+			if n, err := strconv.Atoi(tabindex); err != nil || n > 0 {
+				report("<%s> with tabindex '%s'", tag, tabindex)
+			}
+		}
+
+		switch {
+		case tag == "img":
+			if _, ok := attrs["alt"]; !ok {
+				report("image '%s' without alt attribute", attrs["src"])
+			}
+		case tag == "a":
+			href, ok := attrs["href"]
+			if !ok || href == "#" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+				report("link '%s' does not work without JavaScript", href)
+			}
+		case tag == "form":
+			if attrs["action"] == "" || attrs["method"] == "" {
+				report("form without action or method")
+			}
+		case tag == "label":
+			if f, ok := attrs["for"]; ok {
+				labels[f] = true
+			}
+		case labelledElements[tag] && attrs["type"] != "hidden" && attrs["type"] != "submit":
+			controls = append(controls, attrs)
+		}
+	}
+
+	for _, attrs := range controls {
+		_, hasLabel := attrs["aria-label"]
+		_, hasLabelledBy := attrs["aria-labelledby"]
+		if !labels[attrs["id"]] && !hasLabel && !hasLabelledBy {
+			report("form control '%s' without label", attrs["name"])
+		}
+	}
+	for _, ref := range references {
+		if !ids[ref] {
+			report("reference to missing id '%s'", ref)
+		}
+	}
+	for _, m := range namedPattern.FindAllStringSubmatch(page, -1) {
+		text := strings.TrimSpace(innerTagPattern.ReplaceAllString(m[3], ""))
+		_, hasLabel := htmlAttrs(m[2])["aria-label"]
+		if text == "" && !hasLabel && !innerAltPattern.MatchString(m[3]) {
+			report("<%s> without accessible name", strings.ToLower(m[1]))
+		}
+	}
+	return violations
+}
+
+func TestCheckAccessibility(t *testing.T) {
+	violations := func(page string) int {
+		return len(accessibilityViolations(page))
+	}
+
+	valid := `<html lang="en"><body><form action="/x" method="post"><label for="a">A</label>
+		<input id="a" name="a" aria-describedby="h"><span id="h">Help</span>
+		<input type="hidden" name="b"><button type="submit">Send</button>
+		<a href="/y"><img src="/i.png" alt="Logo"></a></form><script>if (a < b) {}</script></body></html>`
+	if violations(valid) != 0 {
+		t.Error("Valid page expected to pass")
+	}
+
+	invalid := []string{
+		`<html><body></body></html>`,
+		`<html lang="en"><img src="/i.png"></html>`,
+		`<html lang="en"><form action="/x" method="post"><input id="a" name="a"></form></html>`,
+		`<html lang="en"><a href="#">reload</a></html>`,
+		`<html lang="en"><a href="/x" onclick="reload()">reload</a></html>`,
+		`<html lang="en"><button type="submit"><span class="glyphicon"></span></button></html>`,
+		`<html lang="en"><div id="a"></div><div id="a"></div></html>`,
+		`<html lang="en"><input aria-label="A" aria-describedby="missing"></html>`,
+		`<html lang="en"><form><input aria-label="A"></form></html>`,
+		`<html lang="en"><a href="/x" tabindex="2">x</a></html>`,
+	}
+	for _, page := range invalid {
+		if violations(page) == 0 {
+			t.Errorf("Page expected to fail: %s", page)
+		}
+	}
+}
+
+func TestTemplatesAccessibility(t *testing.T) {
+	render := func(name string, pageData interface{}) string {
+		buff := &bytes.Buffer{}
+		err := conf.MakeTemplate(name).ExecuteTemplate(buff, "layout", pageData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return buff.String()
+	}
+
+	login := &loginPageData{loginData: &loginData{Login: "alice", RequestID: "U7JIKKYI"}}
+	checkAccessibility(t, render("login.html", login))
+	login.CaptchaId = "captcha"
+	login.Message = "Wrong login or password"
+	checkAccessibility(t, render("login.html", login))
+
+	registration := &validateAccount{Account: &data.Account{}, ValidationError: &util.ValidationError{}, RequestId: "QPJ64HK0", CaptchaId: "captcha"}
+	checkAccessibility(t, render("registration.html", registration))
+	registration.ValidationError = &util.ValidationError{
+		Message: "Invalid registration",
+		FieldErrors: map[string]string{
+			"title": "Too long", "first_name": "Missing", "middle_name": "Too long", "last_name": "Missing",
+			"login": "Taken", "email": "Taken", "institute": "Missing", "department": "Missing",
+			"city": "Missing", "country": "Missing", "password": "Too short", "captcha": "Wrong",
+		},
+	}
+	checkAccessibility(t, render("registration.html", registration))
+
+	approve := &struct {
+		Client        string
+		AddScope      map[string]string
+		ExistingScope map[string]string
+		Renewal       bool
+		RequestID     string
+	}{"gin", map[string]string{"repo-write": "Write repositories"}, map[string]string{"repo-read": "Read repositories"}, true, "B4LIMIMB"}
+	checkAccessibility(t, render("approve.html", approve))
+
+	checkAccessibility(t, render("magiclink.html", &magicLinkData{RequestID: "U7JIKKYI"}))
+	checkAccessibility(t, render("magiclink.html", &magicLinkData{RequestID: "U7JIKKYI", ErrMessage: "Invalid e-mail address"}))
+	checkAccessibility(t, render("resetinit.html", &credentialData{}))
+	checkAccessibility(t, render("resetinit.html", &credentialData{ErrMessage: "Invalid login or e-mail address"}))
+
+	reset := &struct {
+		ResetCode string
+		Expired   bool
+		*util.ValidationError
+	}{"code", true, &util.ValidationError{FieldErrors: map[string]string{"password": "Too short"}}}
+	checkAccessibility(t, render("reset.html", reset))
+}
@@ -11,6 +11,7 @@ package web
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/G-Node/gin-auth/conf"
@@ -19,60 +20,144 @@ import (
 	"github.com/gorilla/mux"
 )
 
-// ListAccounts is a handler which returns a list of existing accounts as JSON
+// ListAccounts is a handler which returns a list of existing accounts as JSON.
+// The accounts are streamed while they are read from the database.
 func ListAccounts(w http.ResponseWriter, r *http.Request) {
 	isAdmin := false
 	if oauth, ok := OAuthToken(r); ok {
-		isAdmin = oauth.Match.Contains("account-admin")
+		isAdmin = data.HasAdminScope(oauth.Match, data.ScopeAdminRead)
 	}
 
-	var accounts []data.Account
 	search := r.URL.Query().Get("q")
-	if search != "" {
-		accounts = data.SearchAccounts(search)
-	} else {
-		accounts = data.ListAccounts()
-	}
-
-	marshal := make([]data.AccountMarshaler, 0, len(accounts))
-	for i := 0; i < len(accounts); i++ {
-		acc := &accounts[i]
-		marshal = append(marshal, data.AccountMarshaler{
-			WithMail:        isAdmin || acc.IsEmailPublic,
-			WithAffiliation: isAdmin || acc.IsAffiliationPublic,
-			Account:         acc,
-		})
+	label := r.URL.Query().Get("label")
+	if label != "" && !isAdmin {
+		PrintErrorJSON(w, r, "Filtering by label requires scope 'account-admin' or 'admin-read'", http.StatusUnauthorized)
+		return
 	}
 
 	w.Header().Add("Cache-Control", "no-cache")
 	w.Header().Add("Content-Type", "application/json")
-	enc := json.NewEncoder(w)
-	enc.Encode(marshal)
+	stream := util.NewJSONArrayStream(w)
+	err := data.EachAccountListing(search, label, func(listing *data.AccountListing) error {
+		marshal := accountMarshaler(r, &listing.Account)
+		if isAdmin {
+			// groups and labels are loaded together with the accounts
+			marshal.Groups = listing.Groups
+			marshal.Labels = listing.Labels
+		}
+		return stream.Write(marshal)
+	})
+	if err != nil {
+		panic(err)
+	}
+	stream.Close()
 }
 
-// GetAccount is a handler which returns a requested account as JSON
-func GetAccount(w http.ResponseWriter, r *http.Request) {
-	login := mux.Vars(r)["login"]
+// accountMarshaler returns a marshaler which serializes the fields of the account
+// visible to the access token of the request (see data.NewAccountMarshaler) together
+// with links to the subresources of the account the token may access.
+func accountMarshaler(r *http.Request, account *data.Account) *data.AccountMarshaler {
+	if oauth, ok := OAuthToken(r); ok {
+		marshal := data.NewAccountMarshaler(account, oauth.Token)
+		isOwner := oauth.Token.AccountUUID.Valid && oauth.Token.AccountUUID.String == account.UUID
+		marshal.Links = accountLinks(account, isOwner, oauth.Token.Scope)
+		return marshal
+	}
+	marshal := data.NewAccountMarshaler(account, nil)
+	marshal.Links = accountLinks(account, false, util.NewStringSet())
+	return marshal
+}
+
+// subresource is a resource of an account which is linked from the account. It is linked for the
+// owner of the account if owner is true and for tokens with the admin scope if it is not empty.
+type subresource struct {
+	tmpl       string
+	owner      bool
+	adminScope string
+}
+
+// Subresources of accounts by link relation
+var accountSubresources = map[string]subresource{
+	"keys":             {"/api/accounts/{account}/keys", true, data.ScopeAdminRead},
+	"login_settings":   {"/api/accounts/{account}/login_settings", true, data.ScopeAdminRead},
+	"privacy_settings": {"/api/accounts/{account}/privacy_settings", true, data.ScopeAdminRead},
+	"locale_settings":  {"/api/accounts/{account}/locale_settings", true, data.ScopeAdminRead},
+	"notifications":    {"/api/accounts/{account}/notifications", true, data.ScopeAdminRead},
+	"usage":            {"/api/accounts/{account}/usage", true, data.ScopeAdminRead},
+	"password":         {"/api/accounts/{account}/password", true, ""},
+	"email":            {"/api/accounts/{account}/email", true, ""},
+	"notes":            {"/api/accounts/{account}/notes", false, data.ScopeAdminRead},
+	"tokens":           {"/api/accounts/{account}/tokens", false, data.ScopeTokenAdmin},
+	"history":          {"/api/accounts/{account}/history", false, data.ScopeAuditRead},
+}
 
-	account, ok := data.GetAccountByLogin(login)
+// accountLinks returns the URLs of the account and of the subresources which are accessible for its owner
+// or for the given scope respectively, such that clients don't need to know the URL patterns of the API.
+// Accounts which are not yet activated or approved have no links, their resources are not served.
+func accountLinks(account *data.Account, isOwner bool, scope util.StringSet) map[string]string {
+	links := make(map[string]string)
+	if account.ActivationCode.Valid || account.IsApprovalPending {
+		return links
+	}
+
+	if href := apiLink("/api/accounts/{account}", "account", account.Login); href != "" {
+		links["self"] = href
+	}
+	for rel, sub := range accountSubresources {
+		if sub.owner && isOwner || sub.adminScope != "" && data.HasAdminScope(scope, sub.adminScope) {
+			if href := apiLink(sub.tmpl, "account", account.Login); href != "" {
+				links[rel] = href
+			}
+		}
+	}
+	return links
+}
+
+// pathAccount returns the active account given by the path parameter 'account' of a request,
+// which is either the UUID or the login of the account.
+func pathAccount(r *http.Request) (*data.Account, bool) {
+	return data.GetAccountByID(mux.Vars(r)["account"])
+}
+
+// ownAccount returns the account given by the UUID or login in the request URL if the token of the
+// request belongs to the account and contains the given scope or if it contains 'account-admin', or
+// 'admin-read' for 'account-read' and 'admin-account-write' for 'account-write' respectively.
+// Otherwise an error is written to the response.
+func ownAccount(w http.ResponseWriter, r *http.Request, scope string) (*data.Account, bool) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	account, ok := pathAccount(r)
 	if !ok {
 		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
-		return
+		return nil, false
 	}
 
-	isAdmin := false
-	isOwner := false
-	if oauth, ok := OAuthToken(r); ok {
-		isAdmin = oauth.Match.Contains("account-admin")
-		isOwner = oauth.Token.AccountUUID.String == account.UUID && oauth.Match.Contains("account-write")
+	adminScope := data.ScopeAdminAccountWrite
+	if scope == "account-read" {
+		adminScope = data.ScopeAdminRead
+	}
+	isOwner := oauth.Token.AccountUUID.String == account.UUID && oauth.Match.Contains(scope)
+	if !isOwner && !data.HasAdminScope(oauth.Match, adminScope) {
+		PrintErrorJSON(w, r, "Access to requested account forbidden", http.StatusUnauthorized)
+		return nil, false
 	}
 
-	marshal := &data.AccountMarshaler{
-		WithMail:        account.IsEmailPublic || isOwner || isAdmin,
-		WithAffiliation: account.IsAffiliationPublic || isOwner || isAdmin,
-		Account:         account,
+	return account, true
+}
+
+// GetAccount is a handler which returns a requested account as JSON
+func GetAccount(w http.ResponseWriter, r *http.Request) {
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
 	}
 
+	marshal := accountMarshaler(r, account)
+
 	w.Header().Add("Cache-Control", "no-cache")
 	w.Header().Add("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
@@ -82,13 +167,12 @@ func GetAccount(w http.ResponseWriter, r *http.Request) {
 // UpdateAccount is a handler which updated all updatable fields of an account (Title, FirstName,
 // MiddleName and LastName) and returns the updated account as JSON
 func UpdateAccount(w http.ResponseWriter, r *http.Request) {
-	login := mux.Vars(r)["login"]
 	oauth, ok := OAuthToken(r)
 	if !ok {
 		panic("Request was authorized but no OAuth token is available!") // this should never happen
 	}
 
-	account, ok := data.GetAccountByLogin(login)
+	account, ok := pathAccount(r)
 	if !ok {
 		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
 		return
@@ -99,16 +183,15 @@ func UpdateAccount(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	marshal := &data.AccountMarshaler{WithMail: true, WithAffiliation: true, Account: account}
+	marshal := accountMarshaler(r, account)
 
-	dec := json.NewDecoder(r.Body)
-	err := dec.Decode(marshal)
+	err := decodeJSON(r, marshal)
 	if err != nil {
 		PrintErrorJSON(w, r, "Error while processing account", http.StatusBadRequest)
 		return
 	}
 
-	err = account.Update()
+	err = account.UpdateBy(oauth.Token.AccountUUID.String)
 	if err != nil {
 		PrintErrorJSON(w, r, "Error while processing account", http.StatusBadRequest)
 		return
@@ -121,15 +204,15 @@ func UpdateAccount(w http.ResponseWriter, r *http.Request) {
 }
 
 // UpdateAccountPassword is a handler which parses the old and new password from the request body and
-// updates the accounts password. Returns StatusOK and an empty body on success.
+// updates the accounts password. The owner is notified by e-mail with a link to revert the change.
+// Returns StatusOK and an empty body on success.
 func UpdateAccountPassword(w http.ResponseWriter, r *http.Request) {
-	login := mux.Vars(r)["login"]
 	oauth, ok := OAuthToken(r)
 	if !ok {
 		panic("Request was authorized but no OAuth token is available!") // this should never happen
 	}
 
-	account, ok := data.GetAccountByLogin(login)
+	account, ok := pathAccount(r)
 	if !ok {
 		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
 		return
@@ -145,8 +228,7 @@ func UpdateAccountPassword(w http.ResponseWriter, r *http.Request) {
 		PasswordNew       string `json:"password_new"`
 		PasswordNewRepeat string `json:"password_new_repeat"`
 	}{}
-	dec := json.NewDecoder(r.Body)
-	dec.Decode(pwData)
+	decodeJSON(r, pwData)
 
 	if !account.VerifyPassword(pwData.PasswordOld) {
 		err := &util.ValidationError{
@@ -156,10 +238,11 @@ func UpdateAccountPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(pwData.PasswordNew) < 6 {
+	warning, err := data.ValidateNewPassword(pwData.PasswordNew, account.PasswordInputs()...)
+	if err != nil {
 		err := &util.ValidationError{
 			Message:     "Unable to set password",
-			FieldErrors: map[string]string{"password_new": "Password must be at least 6 characters long"}}
+			FieldErrors: map[string]string{"password_new": err.Error()}}
 		PrintErrorJSON(w, r, err, http.StatusBadRequest)
 		return
 	}
@@ -172,24 +255,36 @@ func UpdateAccountPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := account.UpdatePassword(pwData.PasswordNew)
+	err = account.UpdatePassword(pwData.PasswordNew)
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	err = sendRecoveryNotification(account, data.RecoveryPassword, account.Email)
 	if err != nil {
 		PrintErrorJSON(w, r, err, http.StatusInternalServerError)
 		return
 	}
+	if warning != "" {
+		err = account.SetPasswordBreached(true)
+		if err != nil {
+			PrintErrorJSON(w, r, err, http.StatusInternalServerError)
+			return
+		}
+	}
 }
 
 // UpdateAccountEmail parses an e-mail address and the account password
 // from a JSON request body and updates the e-mail address of the authorized account.
+// The previous address is notified with a link to revert the change.
 func UpdateAccountEmail(w http.ResponseWriter, r *http.Request) {
 
-	login := mux.Vars(r)["login"]
 	oauth, ok := OAuthToken(r)
 	if !ok {
 		panic("Missing OAuth token")
 	}
 
-	acc, ok := data.GetAccountByLogin(login)
+	acc, ok := pathAccount(r)
 	if !ok {
 		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
 		return
@@ -205,8 +300,7 @@ func UpdateAccountEmail(w http.ResponseWriter, r *http.Request) {
 		Email    string `json:"email"`
 	}{}
 
-	dec := json.NewDecoder(r.Body)
-	dec.Decode(cred)
+	decodeJSON(r, cred)
 
 	if !acc.VerifyPassword(cred.Password) {
 		valErr := &util.ValidationError{
@@ -216,43 +310,215 @@ func UpdateAccountEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	oldEmail := acc.Email
 	err := acc.UpdateEmail(cred.Email)
 	if err != nil {
 		PrintErrorJSON(w, r, err, http.StatusBadRequest)
 		return
 	}
 
+	err = sendEmailVerification(acc)
+	if err != nil {
+		msg := "An error occurred trying to create change e-mail address confirmation."
+		PrintErrorJSON(w, r, msg, http.StatusInternalServerError)
+		return
+	}
+
+	err = sendRecoveryNotification(acc, data.RecoveryEmail, oldEmail)
+	if err != nil {
+		msg := "An error occurred trying to notify the previous e-mail address."
+		PrintErrorJSON(w, r, msg, http.StatusInternalServerError)
+		return
+	}
+}
+
+// ResendEmailVerification is a handler which renews the e-mail verification code of
+// the authorized account and sends a new verification e-mail.
+func ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	acc, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	if oauth.Token.AccountUUID.String != acc.UUID || !oauth.Match.Contains("account-write") {
+		PrintErrorJSON(w, r, "Unauthorized account access", http.StatusUnauthorized)
+		return
+	}
+
+	err := sendEmailVerification(acc)
+	if code := errorStatus(err, 0); code != 0 {
+		PrintErrorJSON(w, r, err, code)
+		return
+	}
+	if err != nil {
+		msg := "An error occurred trying to create e-mail address verification."
+		PrintErrorJSON(w, r, msg, http.StatusInternalServerError)
+		return
+	}
+}
+
+// sendEmailVerification renews the e-mail verification code of an account and queues an
+// e-mail containing a link to verify the current e-mail address.
+func sendEmailVerification(acc *data.Account) error {
+	code, err := acc.RenewEmailVerificationCode()
+	if err != nil {
+		return err
+	}
+
 	tmplFields := &struct {
 		From    string
 		To      string
 		Subject string
-		Body    string
+		BaseUrl string
+		Code    string
 	}{}
 	tmplFields.From = conf.GetSmtpCredentials().From
-	tmplFields.To = cred.Email
-	tmplFields.Subject = "GIN account confirmation"
-	tmplFields.Body = "The e-mail address of your GIN account has been successfully changed."
+	tmplFields.To = acc.Email
+	tmplFields.Subject = "GIN account e-mail verification"
+	tmplFields.BaseUrl = conf.GetServerConfig().BaseURL
+	tmplFields.Code = code
 
-	content := util.MakeEmailTemplate("emailplain.txt", tmplFields)
+	content := util.MakeEmailTemplate("emailverify.txt", tmplFields)
 	email := &data.Email{}
-	err = email.Create(util.NewStringSet(cred.Email), content.Bytes())
+	return email.Create(util.NewStringSet(acc.Email), content.Bytes())
+}
+
+// ListAccountHistory is a handler which streams all recorded changes of an account as JSON.
+func ListAccountHistory(w http.ResponseWriter, r *http.Request) {
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	stream := util.NewJSONArrayStream(w)
+	err := data.EachAccountChange(account.UUID, func(change *data.AccountChange) error {
+		return stream.Write(change)
+	})
 	if err != nil {
-		msg := "An error occurred trying to create change e-mail address confirmation."
-		PrintErrorJSON(w, r, msg, http.StatusInternalServerError)
+		panic(err)
+	}
+	stream.Close()
+}
+
+// RevertAccountChange is a handler which sets the field of a recorded account change back
+// to its previous value and returns the updated account as JSON.
+func RevertAccountChange(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		PrintErrorJSON(w, r, "The requested change does not exist", http.StatusNotFound)
+		return
+	}
+
+	change, ok := data.GetAccountChange(id)
+	if !ok || change.AccountUUID != account.UUID {
+		PrintErrorJSON(w, r, "The requested change does not exist", http.StatusNotFound)
+		return
+	}
+
+	err = account.RevertChange(change, oauth.Token.AccountUUID.String)
+	if err != nil {
+		PrintErrorJSON(w, r, err, errorStatus(err, http.StatusBadRequest))
 		return
 	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(accountMarshaler(r, account))
+}
+
+// GetAccountNotes is a handler which returns the notes and labels on an account as JSON.
+func GetAccountNotes(w http.ResponseWriter, r *http.Request) {
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	notes, ok := data.GetAccountNotes(account.UUID)
+	if !ok {
+		notes = &data.AccountNotes{AccountUUID: account.UUID, Labels: util.NewStringSet()}
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(accountNotesMarshaler(notes, account))
+}
+
+// UpdateAccountNotes is a handler which replaces the notes and labels on an account
+// and returns the updated notes as JSON.
+func UpdateAccountNotes(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	marshal := &data.AccountNotesMarshaler{Notes: &data.AccountNotes{AccountUUID: account.UUID}}
+	err := decodeJSON(r, marshal)
+	if err != nil {
+		PrintErrorJSON(w, r, "Error while processing notes", http.StatusBadRequest)
+		return
+	}
+
+	err = marshal.Notes.SaveBy(oauth.Token.AccountUUID.String)
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(accountNotesMarshaler(marshal.Notes, account))
+}
+
+// accountNotesMarshaler prepares notes on an account for JSON output.
+func accountNotesMarshaler(notes *data.AccountNotes, account *data.Account) *data.AccountNotesMarshaler {
+	marshal := &data.AccountNotesMarshaler{Notes: notes, Account: account}
+	if notes.UpdatedBy.Valid {
+		if admin, ok := data.GetAccount(notes.UpdatedBy.String); ok {
+			marshal.UpdatedBy = admin
+		}
+	}
+	return marshal
 }
 
 // ListAccountKeys is a handler which returns all ssh keys belonging to a given
 // account as JSON.
 func ListAccountKeys(w http.ResponseWriter, r *http.Request) {
-	login := mux.Vars(r)["login"]
 	oauth, ok := OAuthToken(r)
 	if !ok {
 		panic("Request was authorized but no OAuth token is available!") // this should never happen
 	}
 
-	account, ok := data.GetAccountByLogin(login)
+	account, ok := pathAccount(r)
 	if !ok {
 		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
 		return
@@ -306,13 +572,12 @@ func GetKey(w http.ResponseWriter, r *http.Request) {
 
 // CreateKey stores a new key for a given account.
 func CreateKey(w http.ResponseWriter, r *http.Request) {
-	login := mux.Vars(r)["login"]
 	oauth, ok := OAuthToken(r)
 	if !ok {
 		panic("Request was authorized but no OAuth token is available!") // this should never happen
 	}
 
-	account, ok := data.GetAccountByLogin(login)
+	account, ok := pathAccount(r)
 	if !ok {
 		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
 		return
@@ -324,8 +589,7 @@ func CreateKey(w http.ResponseWriter, r *http.Request) {
 	}
 
 	key := &data.SSHKey{AccountUUID: account.UUID}
-	dec := json.NewDecoder(r.Body)
-	err := dec.Decode(key)
+	err := decodeJSON(r, key)
 	if err != nil {
 		PrintErrorJSON(w, r, err, http.StatusBadRequest)
 		return
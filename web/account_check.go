@@ -0,0 +1,63 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// The account check allows 30 requests per minute from one address.
+var accountCheckLimiter = util.NewRateLimiter(30, time.Minute)
+
+// accountCheck is the JSON representation of the availability of a login and an e-mail address.
+type accountCheck struct {
+	Login *bool `json:"login,omitempty"`
+	Email *bool `json:"email,omitempty"`
+}
+
+// CheckAccount is a handler which reports whether the login and/or e-mail address given by
+// the query parameters 'login' and 'email' are available for a new account. Invalid logins
+// and e-mail addresses are reported as not available. Requests are rate limited per address,
+// except requests from internal networks.
+func CheckAccount(w http.ResponseWriter, r *http.Request) {
+	key := rateLimitKey(r)
+	if !isInternalRequest(r) && !accountCheckLimiter.Allow(key) {
+		printTooManyRequests(w, r, accountCheckLimiter.RetryAfter(key))
+		return
+	}
+
+	query := r.URL.Query()
+	login, email := query.Get("login"), query.Get("email")
+	if login == "" && email == "" {
+		PrintErrorJSON(w, r, "Query parameter 'login' or 'email' is required", http.StatusBadRequest)
+		return
+	}
+
+	loginExists, emailExists := data.AccountExists(login, email)
+	marshal := &accountCheck{}
+	if login != "" {
+		available := data.IsValidLogin(login) && !loginExists
+		marshal.Login = &available
+	}
+	if email != "" {
+		available := len(email) > 2 && strings.Contains(email, "@") && !emailExists
+		marshal.Email = &available
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
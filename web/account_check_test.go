@@ -0,0 +1,84 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestCheckAccount(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	accountCheckLimiter = util.NewRateLimiter(30, time.Minute)
+
+	check := func(query url.Values) (*httptest.ResponseRecorder, *accountCheck) {
+		request, _ := http.NewRequest("GET", "/api/accounts/check?"+query.Encode(), nil)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		result := &accountCheck{}
+		json.NewDecoder(response.Body).Decode(result)
+		return response, result
+	}
+
+	// missing parameters
+	response, _ := check(url.Values{})
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// existing login and e-mail
+	response, result := check(url.Values{"login": {"alice"}, "email": {"bob@foo.com"}})
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if result.Login == nil || *result.Login || result.Email == nil || *result.Email {
+		t.Error("Login and e-mail expected to be not available")
+	}
+
+	// available login only
+	_, result = check(url.Values{"login": {"new_login"}})
+	if result.Login == nil || !*result.Login || result.Email != nil {
+		t.Error("Only login expected to be available")
+	}
+
+	// invalid login and e-mail
+	_, result = check(url.Values{"login": {"in valid"}, "email": {"invalid"}})
+	if *result.Login || *result.Email {
+		t.Error("Invalid login and e-mail expected to be not available")
+	}
+
+	// rate limit
+	accountCheckLimiter = util.NewRateLimiter(1, time.Minute)
+	check(url.Values{"login": {"new_login"}})
+	response, _ = check(url.Values{"login": {"new_login"}})
+	if response.Code != http.StatusTooManyRequests {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusTooManyRequests, response.Code)
+	}
+
+	// internal networks are not rate limited
+	_, internal, _ := net.ParseCIDR("10.0.0.0/8")
+	conf.GetServerConfig().InternalNetworks = []*net.IPNet{internal}
+	defer func() { conf.GetServerConfig().InternalNetworks = nil }()
+	request, _ := http.NewRequest("GET", "/api/accounts/check?login=new_login", nil)
+	request.RemoteAddr = "10.0.0.5:4321"
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	accountCheckLimiter = util.NewRateLimiter(30, time.Minute)
+}
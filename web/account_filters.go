@@ -0,0 +1,197 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// accountFilterJSON is the JSON representation of a saved account filter. EmailVerified is
+// null if the filter matches verified and unverified accounts.
+type accountFilterJSON struct {
+	Name          string    `json:"name"`
+	Description   string    `json:"description"`
+	Search        string    `json:"search"`
+	Label         string    `json:"label"`
+	Group         string    `json:"group"`
+	EmailVerified *bool     `json:"email_verified"`
+	CreatedDays   int       `json:"created_days"`
+	InactiveDays  int       `json:"inactive_days"`
+	Accounts      int       `json:"accounts"`
+	UpdatedBy     *string   `json:"updated_by,omitempty"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ListAccountFilters is a handler which returns all saved account filters together with the
+// number of matching accounts as JSON.
+func ListAccountFilters(w http.ResponseWriter, r *http.Request) {
+	filters := data.ListAccountFilters()
+	marshal := make([]*accountFilterJSON, 0, len(filters))
+	for i := range filters {
+		marshal = append(marshal, accountFilterMarshaler(&filters[i]))
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// UpdateAccountFilter is a handler which saves an account filter under the name given by the
+// URL, an existing filter with this name is replaced.
+func UpdateAccountFilter(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	body := &struct {
+		Description   string `json:"description"`
+		Search        string `json:"search"`
+		Label         string `json:"label"`
+		Group         string `json:"group"`
+		EmailVerified *bool  `json:"email_verified"`
+		CreatedDays   int    `json:"created_days"`
+		InactiveDays  int    `json:"inactive_days"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Invalid account filter data", http.StatusBadRequest)
+		return
+	}
+
+	filter := &data.AccountFilter{
+		Name:         mux.Vars(r)["name"],
+		Description:  body.Description,
+		Search:       body.Search,
+		Label:        body.Label,
+		GroupName:    body.Group,
+		CreatedDays:  body.CreatedDays,
+		InactiveDays: body.InactiveDays,
+	}
+	if body.EmailVerified != nil {
+		filter.EmailVerified = sql.NullBool{Bool: *body.EmailVerified, Valid: true}
+	}
+	err = filter.SaveBy(oauth.Token.AccountUUID.String)
+	if err != nil {
+		if _, ok := err.(*util.ValidationError); ok {
+			PrintErrorJSON(w, r, err, http.StatusBadRequest)
+			return
+		}
+		panic(err)
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "account-filter-updated",
+		"filter": filter.Name,
+		"admin":  accountFilterAdmin(filter),
+		"ip":     remoteIP(r),
+	}).Info("Account filter saved")
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(accountFilterMarshaler(filter))
+}
+
+// DeleteAccountFilter is a handler which removes a saved account filter.
+func DeleteAccountFilter(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	name := mux.Vars(r)["name"]
+	err := data.DeleteAccountFilter(name)
+	if data.KindOf(err) == data.ErrNotFound {
+		PrintErrorJSON(w, r, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	admin := ""
+	if acc, ok := data.GetAccount(oauth.Token.AccountUUID.String); ok {
+		admin = acc.Login
+	}
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "account-filter-deleted",
+		"filter": name,
+		"admin":  admin,
+		"ip":     remoteIP(r),
+	}).Info("Account filter deleted")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListFilteredAccounts is a handler which runs a saved account filter and streams the matching
+// accounts with their groups and labels as JSON, like ListAccounts does for administrators.
+func ListFilteredAccounts(w http.ResponseWriter, r *http.Request) {
+	filter, ok := data.GetAccountFilter(mux.Vars(r)["name"])
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account filter does not exist", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	stream := util.NewJSONArrayStream(w)
+	err := filter.EachListing(func(listing *data.AccountListing) error {
+		marshal := accountMarshaler(r, &listing.Account)
+		marshal.Groups = listing.Groups
+		marshal.Labels = listing.Labels
+		return stream.Write(marshal)
+	})
+	if err != nil {
+		panic(err)
+	}
+	stream.Close()
+}
+
+// accountFilterAdmin returns the login of the administrator who saved a filter.
+func accountFilterAdmin(filter *data.AccountFilter) string {
+	if filter.UpdatedBy.Valid {
+		if acc, ok := data.GetAccount(filter.UpdatedBy.String); ok {
+			return acc.Login
+		}
+	}
+	return ""
+}
+
+// accountFilterMarshaler prepares an account filter for JSON output.
+func accountFilterMarshaler(filter *data.AccountFilter) *accountFilterJSON {
+	marshal := &accountFilterJSON{
+		Name:         filter.Name,
+		Description:  filter.Description,
+		Search:       filter.Search,
+		Label:        filter.Label,
+		Group:        filter.GroupName,
+		CreatedDays:  filter.CreatedDays,
+		InactiveDays: filter.InactiveDays,
+		Accounts:     filter.Count(),
+		UpdatedAt:    filter.UpdatedAt,
+	}
+	if filter.EmailVerified.Valid {
+		verified := filter.EmailVerified.Bool
+		marshal.EmailVerified = &verified
+	}
+	if admin := accountFilterAdmin(filter); admin != "" {
+		marshal.UpdatedBy = &admin
+	}
+	return marshal
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestListAccountFilters(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("GET", "/api/account_filters", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	request, _ = http.NewRequest("GET", "/api/account_filters", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	filters := make([]map[string]interface{}, 0)
+	err := json.NewDecoder(response.Body).Decode(&filters)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(filters) != 1 || filters[0]["name"] != "unverified-30-days" || filters[0]["accounts"] != 1.0 {
+		t.Errorf("Saved filter with one account expected but was %v", filters)
+	}
+}
+
+func TestUpdateAccountFilter(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	put := func(name, body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("PUT", "/api/account_filters/"+name, strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// invalid filter
+	response := put("verified", `{"created_days": -1}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	response = put("verified", `{"description": "Verified accounts", "email_verified": true}`)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	filter, ok := data.GetAccountFilter("verified")
+	if !ok || !filter.EmailVerified.Valid || !filter.EmailVerified.Bool || filter.Count() != 2 {
+		t.Errorf("Filter for two verified accounts expected: %+v", filter)
+	}
+}
+
+func TestDeleteAccountFilter(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("DELETE", "/api/account_filters/unverified-30-days", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNoContent {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNoContent, response.Code)
+	}
+
+	request, _ = http.NewRequest("DELETE", "/api/account_filters/unverified-30-days", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+}
+
+func TestListFilteredAccounts(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("GET", "/api/account_filters/doesnotexist/accounts", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	request, _ = http.NewRequest("GET", "/api/account_filters/unverified-30-days/accounts", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	accounts := make([]map[string]interface{}, 0)
+	err := json.NewDecoder(response.Body).Decode(&accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts) != 1 || accounts[0]["login"] != "john" {
+		t.Errorf("Only john expected but was %v", accounts)
+	}
+}
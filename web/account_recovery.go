@@ -0,0 +1,132 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+)
+
+// sendRecoveryNotification informs the owner of an account about a changed e-mail address or password.
+// The e-mail is sent to the given address and contains a link, which reverts the change and locks
+// the account in case the owner did not make it.
+func sendRecoveryNotification(acc *data.Account, field, to string) error {
+	code, err := acc.CreateRecovery(field, to)
+	if err != nil {
+		return err
+	}
+
+	tmplFields := &struct {
+		From     string
+		To       string
+		Subject  string
+		BaseUrl  string
+		Code     string
+		Field    string
+		Login    string
+		NewEmail string
+		Days     int
+	}{}
+	tmplFields.From = conf.GetSmtpCredentials().From
+	tmplFields.To = to
+	tmplFields.Subject = "Your GIN account has been changed"
+	tmplFields.BaseUrl = conf.GetServerConfig().BaseURL
+	tmplFields.Code = code
+	tmplFields.Field = field
+	tmplFields.Login = acc.Login
+	tmplFields.NewEmail = acc.Email
+	tmplFields.Days = int(conf.GetAccountCodes().RecoveryLifeTime / (24 * time.Hour))
+
+	content := util.MakeEmailTemplate("emailrecovery.txt", tmplFields)
+	email := &data.Email{}
+	return email.Create(util.NewStringSet(to), content.Bytes())
+}
+
+// RecoverPage shows the confirmation form for the recovery link of a notification about a changed
+// e-mail address or password. The change is only reverted when the form is submitted, thus links
+// opened by e-mail scanners don't lock accounts.
+func RecoverPage(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("recovery_code")
+	if code == "" {
+		PrintErrorHTML(w, r, "Recovery code was absent", http.StatusBadRequest)
+		return
+	}
+
+	tmpl := conf.MakeTemplate("recover.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", &struct{ RecoveryCode string }{code})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Recover reverts the change of an account given by the posted recovery code and locks the account:
+// a changed e-mail address is set back, sessions and tokens are removed and an e-mail with a password
+// reset link is sent to the address which received the recovery link.
+func Recover(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		PrintErrorHTML(w, r, "Recovery request was malformed", http.StatusBadRequest)
+		return
+	}
+
+	account, recovery, resetCode, err := data.RecoverAccount(r.PostForm.Get("recovery_code"))
+	switch data.KindOf(err) {
+	case nil:
+	case data.ErrNotFound, data.ErrExpired:
+		PrintErrorHTML(w, r, "Your request is invalid or outdated.", http.StatusNotFound)
+		return
+	case data.ErrConflict:
+		msg := "Your previous e-mail address is now used by another account, please contact the support."
+		PrintErrorHTML(w, r, msg, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	err = sendPasswordReset(recovery.Email, resetCode, nil)
+	if err != nil {
+		msg := "An error occurred trying to send password reset e-mail. Please use the password reset form."
+		PrintErrorHTML(w, r, msg, http.StatusInternalServerError)
+		return
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event": "account-recovery",
+		"login": account.Login,
+		"field": recovery.Field,
+		"ip":    remoteIP(r),
+	}).Warn("Account change reverted and account locked")
+
+	head := "Your account has been locked"
+	message := "The change has been reverted, all sessions and access tokens of your account have been revoked.<br/><br/>"
+	message += "An e-mail with a link to set a new password has been sent to " +
+		template.HTMLEscapeString(recovery.Email) + ". Your account stays locked until a new password is set."
+
+	info := struct {
+		Header  string
+		Message template.HTML
+	}{head, template.HTML(message)}
+
+	tmpl := conf.MakeTemplate("success.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err = tmpl.ExecuteTemplate(w, "layout", info)
+	if err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestRecover(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	account, _ := data.GetAccountByLogin("bob")
+	err := account.UpdateEmail("mallory@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := account.CreateRecovery(data.RecoveryEmail, "bob@foo.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(code string) *httptest.ResponseRecorder {
+		body := url.Values{}
+		body.Set("recovery_code", code)
+		request, _ := http.NewRequest("POST", "/oauth/recover", strings.NewReader(body.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// confirmation page
+	request, _ := http.NewRequest("GET", "/oauth/recover_page", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+	request, _ = http.NewRequest("GET", "/oauth/recover_page?recovery_code="+url.QueryEscape(code), nil)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if _, ok := data.GetAccountByLogin("bob"); !ok {
+		t.Error("Account should not be locked by the confirmation page")
+	}
+
+	// wrong code
+	response = post("wrongcode.4102444800")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	emails, _ := data.GetQueuedEmails()
+	num := len(emails)
+	response = post(code)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if _, ok := data.GetAccountByLogin("bob"); ok {
+		t.Error("Account expected to be locked")
+	}
+	emails, _ = data.GetQueuedEmails()
+	if len(emails) != num+1 || !emails[len(emails)-1].Recipient.Contains("bob@foo.com") {
+		t.Error("Password reset e-mail expected to be sent to the previous address")
+	}
+
+	// codes can only be used once
+	response = post(code)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+}
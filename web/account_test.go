@@ -12,6 +12,7 @@ import (
 	"bytes"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -57,6 +58,23 @@ func TestGetAccount(t *testing.T) {
 		t.Error("Email not expected to be present")
 	}
 
+	// ok (by UUID)
+	request, _ = http.NewRequest("GET", "/api/accounts/bf431618-f696-4dca-a95d-882618ce4ef9", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	acc = &data.AccountMarshaler{}
+	err = json.NewDecoder(response.Body).Decode(acc)
+	if err != nil {
+		t.Error(err)
+	}
+	if acc.Account.Login != "alice" {
+		t.Errorf("Account login expected to be 'alice' but was %s", acc.Account.Login)
+	}
+
 	// all ok (own account)
 	request, _ = http.NewRequest("GET", "/api/accounts/alice", strings.NewReader(""))
 	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
@@ -100,6 +118,31 @@ func TestGetAccount(t *testing.T) {
 	}
 }
 
+func TestGetAccountAdminFields(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// own account without admin scope
+	request, _ := http.NewRequest("GET", "/api/accounts/alice", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if strings.Contains(response.Body.String(), `"admin"`) {
+		t.Error("Admin fields not expected to be present")
+	}
+
+	// token with admin scope
+	request, _ = http.NewRequest("GET", "/api/accounts/alice", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `"admin":{"disabled":false`) {
+		t.Errorf("Admin fields expected to be present: %s", response.Body.String())
+	}
+}
+
 func TestListAccounts(t *testing.T) {
 	handler := InitTestHttpHandler(t)
 
@@ -135,8 +178,9 @@ func TestListAccounts(t *testing.T) {
 		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
 	}
 
+	body := response.Body.String()
 	accounts = []data.AccountMarshaler{}
-	err = json.NewDecoder(response.Body).Decode(&accounts)
+	err = json.NewDecoder(strings.NewReader(body)).Decode(&accounts)
 	if err != nil {
 		t.Error(err)
 	}
@@ -147,6 +191,9 @@ func TestListAccounts(t *testing.T) {
 	if acc.Account.Login != "alice" {
 		t.Error("Account login expected to be 'alice'")
 	}
+	if !strings.Contains(body, `"groups":["lmu-neuro"],"labels":["verified researcher"]`) {
+		t.Error("Groups and labels expected in listing for administrators")
+	}
 }
 
 func TestUpdateAccount(t *testing.T) {
@@ -406,6 +453,288 @@ func TestUpdateAccountEmail(t *testing.T) {
 	}
 }
 
+func TestResendEmailVerification(t *testing.T) {
+	const uriAlice = "/api/accounts/alice/email/verification"
+	const uriBob = "/api/accounts/bob/email/verification"
+
+	handler := InitTestHttpHandler(t)
+
+	// missing authorization header
+	request, _ := http.NewRequest("POST", uriAlice, strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// other account
+	request, _ = http.NewRequest("POST", uriBob, strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// already verified
+	request, _ = http.NewRequest("POST", uriAlice, strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusConflict {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusConflict, response.Code)
+	}
+
+	// unverified e-mail address
+	acc, _ := data.GetAccountByLogin("alice")
+	err := acc.UpdateEmail("testemail@example.com")
+	if err != nil {
+		t.Error(err)
+	}
+	emails, _ := data.GetQueuedEmails()
+	num := len(emails)
+
+	request, _ = http.NewRequest("POST", uriAlice, strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	emails, _ = data.GetQueuedEmails()
+	if len(emails) != num+1 {
+		t.Errorf("Expected e-mail queue to contain '%d' entries but had '%d'", num+1, len(emails))
+	}
+
+	// creating keys does not require a verified e-mail address
+	body := bytes.NewReader([]byte(`{"key": "ssh-rsa AAAA", "description": "new key"}`))
+	request, _ = http.NewRequest("POST", "/api/accounts/alice/keys", body)
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code == http.StatusForbidden {
+		t.Error("Creating keys expected to be possible with an unverified e-mail address")
+	}
+}
+
+func TestListAccountHistory(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no admin scope
+	request, _ := http.NewRequest("GET", "/api/accounts/alice/history", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// account does not exist
+	request, _ = http.NewRequest("GET", "/api/accounts/doesnotexist/history", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/accounts/alice/history", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	history := []struct {
+		Id       int    `json:"id"`
+		Field    string `json:"field"`
+		NewValue string `json:"new_value"`
+	}{}
+	err := json.NewDecoder(response.Body).Decode(&history)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(history) != 2 {
+		t.Error("Two changes expected in response")
+	}
+	if history[0].Field != "first_name" || history[0].NewValue != "Alice" {
+		t.Error("First change was expected to set 'first_name' to 'Alice'")
+	}
+}
+
+func TestRevertAccountChange(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	id := data.ListAccountHistory("bf431618-f696-4dca-a95d-882618ce4ef9")[0].Id
+	uri := fmt.Sprintf("/api/accounts/alice/history/%d/revert", id)
+
+	// no admin scope
+	request, _ := http.NewRequest("POST", uri, strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// change of another account
+	request, _ = http.NewRequest("POST", fmt.Sprintf("/api/accounts/bob/history/%d/revert", id), strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// invalid change id
+	request, _ = http.NewRequest("POST", "/api/accounts/alice/history/foo/revert", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("POST", uri, strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	acc := &data.AccountMarshaler{}
+	err := json.NewDecoder(response.Body).Decode(acc)
+	if err != nil {
+		t.Error(err)
+	}
+	if acc.Account.FirstName != "Alicia" {
+		t.Error("Account FirstName expected to be 'Alicia'")
+	}
+}
+
+func TestListAccountsByLabel(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no admin scope
+	request, _ := http.NewRequest("GET", "/api/accounts?label=spam-suspect", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/accounts?label=spam-suspect", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	accounts := []data.AccountMarshaler{}
+	err := json.NewDecoder(response.Body).Decode(&accounts)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(accounts) != 1 || accounts[0].Account.Login != "john" {
+		t.Error("Only account 'john' expected in response")
+	}
+}
+
+func TestGetAccountNotes(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no admin scope
+	request, _ := http.NewRequest("GET", "/api/accounts/alice/notes", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// account does not exist
+	request, _ = http.NewRequest("GET", "/api/accounts/doesnotexist/notes", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/accounts/alice/notes", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	notes := &struct {
+		Notes     string   `json:"notes"`
+		Labels    []string `json:"labels"`
+		UpdatedBy string   `json:"updated_by"`
+	}{}
+	err := json.NewDecoder(response.Body).Decode(notes)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(notes.Labels) != 1 || notes.Labels[0] != "verified researcher" || notes.UpdatedBy != "bob" {
+		t.Errorf("Unexpected notes: %v", notes)
+	}
+
+	// account without notes
+	request, _ = http.NewRequest("GET", "/api/accounts/bob/notes", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+}
+
+func TestUpdateAccountNotes(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// invalid label
+	body := strings.NewReader(`{"notes": "Sends a lot of requests", "labels": ["Spam!"]}`)
+	request, _ := http.NewRequest("PUT", "/api/accounts/bob/notes", body)
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	body = strings.NewReader(`{"notes": "Sends a lot of requests", "labels": ["spam-suspect"]}`)
+	request, _ = http.NewRequest("PUT", "/api/accounts/bob/notes", body)
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	if len(data.ListAccountsByLabel("spam-suspect")) != 2 {
+		t.Error("Two accounts with label 'spam-suspect' expected")
+	}
+}
+
 func TestListAccountKeys(t *testing.T) {
 	handler := InitTestHttpHandler(t)
 
@@ -738,3 +1067,77 @@ func TestDeleteKey(t *testing.T) {
 		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
 	}
 }
+
+func TestAccountLinks(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	base := conf.GetServerConfig().BaseURL + "/api/v1/accounts/alice"
+
+	getLinks := func(path, token string) map[string]string {
+		request, _ := http.NewRequest("GET", path, strings.NewReader(""))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code != http.StatusOK {
+			t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+		}
+		body := &struct {
+			Links map[string]struct {
+				Href string `json:"href"`
+			} `json:"_links"`
+		}{}
+		err := json.NewDecoder(response.Body).Decode(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		links := make(map[string]string)
+		for rel, link := range body.Links {
+			links[rel] = link.Href
+		}
+		return links
+	}
+
+	// anonymous requests, links point to the current API version
+	links := getLinks("/api/accounts/alice", "")
+	if len(links) != 1 || links["self"] != base {
+		t.Errorf("Only the link to the account expected: %v", links)
+	}
+
+	// owner of the account
+	links = getLinks("/api/v1/accounts/alice", accessTokenAlice)
+	if links["password"] != base+"/password" || links["keys"] != base+"/keys" {
+		t.Errorf("Links to password and keys expected: %v", links)
+	}
+	if _, ok := links["notes"]; ok {
+		t.Error("No link to the notes expected for the owner")
+	}
+
+	// admin
+	links = getLinks("/api/v1/accounts/alice", accessTokenAliceAdmin)
+	if links["notes"] != base+"/notes" || links["tokens"] != base+"/tokens" || links["history"] != base+"/history" {
+		t.Errorf("Links to the administrative resources expected: %v", links)
+	}
+	if _, ok := links["password"]; ok {
+		t.Error("No link to the password of other accounts expected")
+	}
+
+	// linked subresources exist
+	for rel, href := range links {
+		request, _ := http.NewRequest("GET", strings.TrimPrefix(href, conf.GetServerConfig().BaseURL), strings.NewReader(""))
+		request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code == http.StatusNotFound {
+			t.Errorf("Link '%s' to '%s' does not exist", rel, href)
+		}
+	}
+
+	// unknown routes and missing variables result in no link
+	if href := apiLink("/api/doesnotexist"); href != "" {
+		t.Errorf("No link expected for an unknown route: %s", href)
+	}
+	if href := apiLink("/api/accounts/{account}"); href != "" {
+		t.Errorf("No link expected without path variables: %s", href)
+	}
+}
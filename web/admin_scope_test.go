@@ -0,0 +1,70 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestAdminScopes(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	tokens := make(map[string]string)
+	for _, scope := range []string{"admin-read", "admin-account-write", "token-admin", "client-admin", "audit-read"} {
+		token := &data.AccessToken{
+			AccountUUID: sql.NullString{String: "51f5ac36-d332-4889-8023-6e033fcd8e17", Valid: true},
+			ClientUUID:  "8b14d6bb-cae7-4163-bbd1-f3be46e43e31",
+			Scope:       util.NewStringSet(scope),
+		}
+		err := token.Create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[scope] = token.Token
+	}
+
+	cases := []struct {
+		scope  string
+		method string
+		path   string
+		code   int
+	}{
+		{"admin-read", "GET", "/api/v1/usage", http.StatusOK},
+		{"admin-read", "GET", "/api/v1/pending_accounts", http.StatusOK},
+		{"admin-read", "GET", "/api/v1/accounts/alice/notifications", http.StatusOK},
+		{"admin-read", "PUT", "/api/v1/accounts/alice/notifications", http.StatusUnauthorized},
+		{"admin-read", "GET", "/api/v1/accounts/alice/history", http.StatusUnauthorized},
+		{"admin-read", "GET", "/api/v1/accounts/alice/tokens", http.StatusUnauthorized},
+		{"admin-read", "PUT", "/api/v1/readonly", http.StatusUnauthorized},
+		{"admin-account-write", "GET", "/api/v1/usage", http.StatusUnauthorized},
+		{"admin-account-write", "DELETE", "/api/v1/email_bounces/nobody@example.com", http.StatusNotFound},
+		{"token-admin", "GET", "/api/v1/accounts/alice/tokens", http.StatusOK},
+		{"token-admin", "GET", "/api/v1/pending_accounts", http.StatusUnauthorized},
+		{"client-admin", "GET", "/api/v1/grant_requests/stats", http.StatusOK},
+		{"client-admin", "GET", "/api/v1/accounts/alice/history", http.StatusUnauthorized},
+		{"audit-read", "GET", "/api/v1/accounts/alice/history", http.StatusOK},
+		{"audit-read", "GET", "/api/v1/usage", http.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		request, _ := http.NewRequest(c.method, c.path, nil)
+		request.Header.Set("Authorization", "Bearer "+tokens[c.scope])
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		if response.Code != c.code {
+			t.Errorf("Response code '%d' expected for %s %s with scope '%s' but was '%d'",
+				c.code, c.method, c.path, c.scope, response.Code)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+)
+
+// ListAnnouncements is a handler which returns all announcements as JSON.
+func ListAnnouncements(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(data.ListAnnouncements())
+}
+
+// CreateAnnouncement is a handler which sends an announcement to all active accounts, to the
+// accounts with the given label or in the given group or to the accounts matching a saved filter.
+// With "preview" set the announcement is not sent, the response contains the number of recipients
+// and the e-mail as it is sent to the administrator.
+func CreateAnnouncement(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+	admin, ok := data.GetAccount(oauth.Token.AccountUUID.String)
+	if !ok {
+		PrintErrorJSON(w, r, "Unable to find account associated with the request", http.StatusInternalServerError)
+		return
+	}
+
+	body := &struct {
+		Subject string `json:"subject"`
+		Body    string `json:"body"`
+		Label   string `json:"label"`
+		Group   string `json:"group"`
+		Filter  string `json:"filter"`
+		Preview bool   `json:"preview"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	announcement := &data.Announcement{
+		Subject:    body.Subject,
+		Body:       body.Body,
+		Label:      body.Label,
+		GroupName:  body.Group,
+		FilterName: body.Filter,
+		CreatedBy:  sql.NullString{String: admin.UUID, Valid: true},
+	}
+	if body.Preview {
+		if err := announcement.Validate(); err != nil {
+			PrintErrorJSON(w, r, err, http.StatusBadRequest)
+			return
+		}
+		w.Header().Add("Cache-Control", "no-cache")
+		w.Header().Add("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.Encode(&struct {
+			Recipients int    `json:"recipients"`
+			Email      string `json:"email"`
+		}{announcement.CountRecipients(), string(announcement.Preview(admin))})
+		return
+	}
+
+	err = createAnnouncement(r, announcement, admin)
+	if err != nil {
+		if _, ok := err.(*util.ValidationError); ok {
+			PrintErrorJSON(w, r, err, http.StatusBadRequest)
+			return
+		}
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(announcement)
+}
+
+// AnnouncementsPage shows a form for announcements and all sent announcements to an
+// administrator logged in via session cookie.
+func AnnouncementsPage(w http.ResponseWriter, r *http.Request) {
+	session, admin, ok := administratorSession(w, r)
+	if !ok {
+		return
+	}
+
+	printAnnouncementsPage(w, &announcementsData{
+		Announcements: data.ListAnnouncements(),
+		CSRFToken:     sessionCSRFToken(session),
+		Time:          admin.TimeFormat(),
+	})
+}
+
+// AnnouncementsAction previews or sends an announcement submitted from the announcements page.
+func AnnouncementsAction(w http.ResponseWriter, r *http.Request) {
+	session, admin, ok := administratorSession(w, r)
+	if !ok {
+		return
+	}
+
+	param := &struct {
+		Action    string
+		Subject   string
+		Body      string
+		Label     string
+		Group     string
+		Filter    string
+		CSRFToken string
+	}{}
+	err := util.ReadFormIntoStruct(r, param, true)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+	expected := sessionCSRFToken(session)
+	if !util.EqualTokens(param.CSRFToken, expected) {
+		PrintErrorHTML(w, r, "Invalid form token", http.StatusForbidden)
+		return
+	}
+
+	announcement := &data.Announcement{
+		Subject:    param.Subject,
+		Body:       param.Body,
+		Label:      param.Label,
+		GroupName:  param.Group,
+		FilterName: param.Filter,
+		CreatedBy:  sql.NullString{String: admin.UUID, Valid: true},
+	}
+	pageData := &announcementsData{Draft: announcement, CSRFToken: expected, Time: admin.TimeFormat()}
+
+	switch param.Action {
+	case "preview":
+		if valErr := announcement.Validate(); valErr != nil {
+			pageData.Error = valErr
+		} else {
+			pageData.Preview = string(announcement.Preview(admin))
+			pageData.Recipients = announcement.CountRecipients()
+		}
+	case "send":
+		err = createAnnouncement(r, announcement, admin)
+		if valErr, ok := err.(*util.ValidationError); ok {
+			pageData.Error = valErr
+		} else if err != nil {
+			panic(err)
+		} else {
+			w.Header().Add("Cache-Control", "no-store")
+			http.Redirect(w, r, conf.MakePath("/oauth/announcements"), http.StatusFound)
+			return
+		}
+	default:
+		PrintErrorHTML(w, r, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	pageData.Announcements = data.ListAnnouncements()
+	printAnnouncementsPage(w, pageData)
+}
+
+// announcementsData contains the data of the announcements page.
+type announcementsData struct {
+	Announcements []data.Announcement
+	Draft         *data.Announcement
+	Preview       string
+	Recipients    int
+	Error         *util.ValidationError
+	CSRFToken     string
+	Time          *util.TimeFormat
+}
+
+func printAnnouncementsPage(w http.ResponseWriter, pageData *announcementsData) {
+	if pageData.Draft == nil {
+		pageData.Draft = &data.Announcement{}
+	}
+
+	tmpl := conf.MakeTemplate("announcements.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// createAnnouncement stores an announcement for sending and records it in the audit log.
+func createAnnouncement(r *http.Request, announcement *data.Announcement, admin *data.Account) error {
+	err := announcement.Create()
+	if err != nil {
+		return err
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":      "announcement",
+		"login":      admin.Login,
+		"subject":    announcement.Subject,
+		"recipients": announcement.Recipients,
+		"ip":         remoteIP(r),
+	}).Info(fmt.Sprintf("Announcement to %d accounts queued", announcement.Recipients))
+	return nil
+}
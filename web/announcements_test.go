@@ -0,0 +1,112 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestCreateAnnouncement(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/api/announcements", strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// no admin scope
+	response := post(accessTokenAlice, `{"subject": "Hello", "body": "World"}`)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid announcement
+	response = post(accessTokenAliceAdmin, `{"subject": "Hello"}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// preview
+	response = post(accessTokenAliceAdmin, `{"subject": "Hello", "body": "World", "preview": true}`)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	preview := &struct {
+		Recipients int    `json:"recipients"`
+		Email      string `json:"email"`
+	}{}
+	json.Unmarshal(response.Body.Bytes(), preview)
+	if preview.Recipients != 2 || !strings.Contains(preview.Email, "To: bob@foo.com") {
+		t.Errorf("Unexpected preview: %+v", preview)
+	}
+	if len(data.ListAnnouncements()) != 0 {
+		t.Error("Preview should not create an announcement")
+	}
+
+	// all ok
+	response = post(accessTokenAliceAdmin, `{"subject": "Hello", "body": "World", "group": "lmu-neuro"}`)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `"recipients":1`) {
+		t.Errorf("Unexpected response: %s", response.Body.String())
+	}
+}
+
+func TestAnnouncementsAction(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	session := &data.Session{Token: sessionCookieBob}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/oauth/announcements", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(session.Token)})
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+	form := url.Values{"subject": {"Hello"}, "body": {"World"}, "csrf_token": {sessionCSRFToken(session)}}
+
+	// wrong form token
+	response := post(url.Values{"action": {"send"}, "subject": {"Hello"}, "body": {"World"}, "csrf_token": {"wrong"}})
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// preview
+	form.Set("action", "preview")
+	response = post(form)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "Send to 2 accounts") {
+		t.Error("Page expected to contain the number of recipients")
+	}
+
+	// send
+	form.Set("action", "send")
+	response = post(form)
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+	if len(data.ListAnnouncements()) != 1 {
+		t.Error("Announcement should have been created")
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/G-Node/gin-auth/util"
+	"github.com/gorilla/mux"
+)
+
+// Current version of the API. Its routes are registered with the prefix /api/v<version>.
+const apiVersion = "1"
+
+// Versions of the API which are still served.
+var apiVersions = util.NewStringSet(apiVersion)
+
+// The routes without version prefix are aliases of the current version, which are kept for
+// one release in order to give existing clients time to migrate. They are removed with the
+// release following the sunset date.
+var unversionedAPI = Deprecation{
+	Since:  time.Date(2016, 11, 1, 0, 0, 0, 0, time.UTC),
+	Sunset: time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC),
+}
+
+var apiVersionPrefix = regexp.MustCompile(`^/api/v[0-9]+/`)
+
+// unversionedPath removes the version prefix from the path of an API route, e.g.
+// "/api/v1/accounts/{account}" becomes "/api/accounts/{account}". Configuration and policies
+// refer to routes by their unversioned path.
+func unversionedPath(path string) string {
+	if loc := apiVersionPrefix.FindStringIndex(path); loc != nil {
+		return "/api/" + path[loc[1]:]
+	}
+	return path
+}
+
+// routePath returns the unversioned path template of the route of a request, or the unversioned
+// path of the URL if the request was not routed yet.
+func routePath(r *http.Request) string {
+	path := r.URL.Path
+	if current := mux.CurrentRoute(r); current != nil {
+		if tmpl, err := current.GetPathTemplate(); err == nil {
+			path = tmpl
+		}
+	}
+	return unversionedPath(path)
+}
+
+// APIVersionHandler negotiates the version of the API for routes of the given version, or for
+// routes without version prefix if the version is empty. Clients may request a version with the
+// header 'API-Version'. Requests for a version which is not served or which does not match the
+// version of the route are rejected with status 406, otherwise the response carries the version
+// in the header 'API-Version'.
+func APIVersionHandler(version string) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requested := r.Header.Get("API-Version")
+			if requested != "" && (!apiVersions.Contains(requested) || (version != "" && requested != version)) {
+				msg := fmt.Sprintf("API version '%s' is not available, supported versions: %v", requested, apiVersions.Strings())
+				PrintErrorJSON(w, r, msg, http.StatusNotAcceptable)
+				return
+			}
+
+			served := version
+			if served == "" {
+				served = requested
+			}
+			if served == "" {
+				served = apiVersion
+			}
+			w.Header().Set("API-Version", served)
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
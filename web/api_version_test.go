@@ -0,0 +1,102 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestUnversionedPath(t *testing.T) {
+	tests := map[string]string{
+		"/api/v1/accounts/{account}": "/api/accounts/{account}",
+		"/api/v12/maintenance":       "/api/maintenance",
+		"/api/accounts/v1/keys":      "/api/accounts/v1/keys",
+		"/api/v1":                    "/api/v1",
+		"/oauth/token":               "/oauth/token",
+	}
+	for path, expected := range tests {
+		if p := unversionedPath(path); p != expected {
+			t.Errorf("Path '%s' expected for '%s' but was '%s'", expected, path, p)
+		}
+	}
+}
+
+func TestAPIVersionHandler(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {}
+	router := mux.NewRouter()
+	NewRouteGroup(router.PathPrefix("/api/v1").Subrouter(), APIVersionHandler("1")).HandleFunc("/test", handler, "GET")
+	NewRouteGroup(router.PathPrefix("/api").Subrouter(), APIVersionHandler("")).HandleFunc("/test", handler, "GET")
+
+	get := func(path, version string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", path, strings.NewReader(""))
+		if version != "" {
+			request.Header.Set("API-Version", version)
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	tests := []struct {
+		path, requested string
+		code            int
+		served          string
+	}{
+		{"/api/v1/test", "", http.StatusOK, "1"},
+		{"/api/v1/test", "1", http.StatusOK, "1"},
+		{"/api/v1/test", "2", http.StatusNotAcceptable, ""},
+		{"/api/test", "", http.StatusOK, "1"},
+		{"/api/test", "1", http.StatusOK, "1"},
+		{"/api/test", "0", http.StatusNotAcceptable, ""},
+	}
+	for _, test := range tests {
+		response := get(test.path, test.requested)
+		if response.Code != test.code || response.Header().Get("API-Version") != test.served {
+			t.Errorf("Unexpected response for %s with version '%s': %d, '%s'", test.path, test.requested,
+				response.Code, response.Header().Get("API-Version"))
+		}
+	}
+}
+
+func TestVersionedRoutes(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", path, strings.NewReader(""))
+		request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	response := get("/api/v1/accounts/alice")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("Deprecation") != "" || !strings.Contains(response.Body.String(), "/api/v1/accounts/alice") {
+		t.Errorf("Current version expected: %s", response.Body.String())
+	}
+
+	// alias without version
+	response = get("/api/accounts/alice")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("Deprecation") == "" || response.Header().Get("API-Version") != apiVersion {
+		t.Error("Deprecation header expected for routes without version")
+	}
+	if response.Header().Get("Sunset") != "Mon, 01 May 2017 00:00:00 GMT" {
+		t.Errorf("Unexpected sunset header: %s", response.Header().Get("Sunset"))
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// authorizedApp describes an approved client on the authorized applications page.
+type authorizedApp struct {
+	Client    string
+	Scope     map[string]string
+	CreatedAt time.Time
+}
+
+// AuthorizedAppsPage lists all clients approved by the account logged in via session cookie
+// together with the consent receipts of these approvals.
+func AuthorizedAppsPage(w http.ResponseWriter, r *http.Request) {
+	_, account, ok := accountSession(w, r)
+	if !ok {
+		return
+	}
+
+	approvals := data.ListAccountClientApprovals(account.UUID)
+	apps := make([]authorizedApp, 0, len(approvals))
+	for _, approval := range approvals {
+		client, ok := data.GetClient(approval.ClientUUID)
+		if !ok {
+			continue
+		}
+		scope, ok := data.DescribeScope(approval.Scope)
+		if !ok {
+			scope = make(map[string]string)
+			for _, s := range approval.Scope.Strings() {
+				scope[s] = s
+			}
+		}
+		apps = append(apps, authorizedApp{client.Name, scope, approval.CreatedAt})
+	}
+
+	pageData := struct {
+		Login     string
+		Apps      []authorizedApp
+		Receipts  []data.ConsentReceipt
+		PolicyURL string
+		Time      *util.TimeFormat
+	}{account.Login, apps, data.ListConsentReceipts(account.UUID), conf.GetConsent().PolicyURL, account.TimeFormat()}
+
+	tmpl := conf.MakeTemplate("apps.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ConsentReceipts downloads the consent receipts of the account logged in via session cookie
+// as JSON or PDF file. If the query parameter 'receipt' is present only this receipt is downloaded.
+func ConsentReceipts(w http.ResponseWriter, r *http.Request) {
+	_, account, ok := accountSession(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "pdf" {
+		PrintErrorHTML(w, r, "Format must be one of 'json' or 'pdf'", http.StatusBadRequest)
+		return
+	}
+
+	filename := "consent-receipts-" + account.Login
+	receipts := data.ListConsentReceipts(account.UUID)
+	if id := query.Get("receipt"); id != "" {
+		receipt, ok := data.GetConsentReceipt(id)
+		if !ok || receipt.AccountUUID != account.UUID {
+			PrintErrorHTML(w, r, "The requested receipt does not exist", http.StatusNotFound)
+			return
+		}
+		filename = "consent-receipt-" + receipt.UUID
+		receipts = []data.ConsentReceipt{*receipt}
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.%s\"", filename, format))
+
+	if format == "pdf" {
+		w.Header().Add("Content-Type", "application/pdf")
+		w.Write(consentReceiptsPDF(account, receipts))
+		return
+	}
+
+	list := make([]*data.ConsentReceipt, len(receipts))
+	for i := range receipts {
+		list[i] = &receipts[i]
+	}
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	err := enc.Encode(&struct {
+		Login    string                 `json:"login"`
+		IssuedAt time.Time              `json:"issued_at"`
+		Receipts []*data.ConsentReceipt `json:"receipts"`
+	}{account.Login, time.Now(), list})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// consentReceiptsPDF renders consent receipts as PDF document.
+func consentReceiptsPDF(account *data.Account, receipts []data.ConsentReceipt) []byte {
+	lines := []string{
+		fmt.Sprintf("Account: %s", account.Login),
+		fmt.Sprintf("Issued: %s", time.Now().UTC().Format("2006-01-02 15:04 MST")),
+		"",
+	}
+	for _, receipt := range receipts {
+		lines = append(lines,
+			fmt.Sprintf("Receipt: %s", receipt.UUID),
+			fmt.Sprintf("Date: %s", receipt.CreatedAt.UTC().Format("2006-01-02 15:04 MST")),
+			fmt.Sprintf("Application: %s", receipt.ClientName),
+			fmt.Sprintf("Scope: %s", strings.Join(receipt.Scope.Strings(), ", ")),
+			fmt.Sprintf("Privacy policy version: %s", receipt.PolicyVersion),
+			"")
+	}
+	return util.TextPDF("GIN consent receipts", lines)
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func TestAuthorizedAppsPage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// not logged in
+	request, _ := http.NewRequest("GET", "/oauth/apps", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/oauth/apps", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken("DNM5RS3C")})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, "Write access to your repositories") || !strings.Contains(body, "2016-01") {
+		t.Error("Page expected to show approvals and receipts")
+	}
+}
+
+func TestConsentReceipts(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	get := func(query, cookie string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", "/oauth/consent_receipts?"+query, strings.NewReader(""))
+		request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(cookie)})
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// all receipts as JSON
+	response := get("format=json", "DNM5RS3C")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.HasPrefix(response.Header().Get("Content-Disposition"), "attachment") {
+		t.Error("Receipts expected to be downloaded as attachment")
+	}
+	result := &struct {
+		Login    string
+		Receipts []struct {
+			ClientName string `json:"client_name"`
+			Scope      []string
+		}
+	}{}
+	err := json.NewDecoder(response.Body).Decode(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Login != "alice" || len(result.Receipts) != 2 {
+		t.Errorf("Two receipts of alice expected but found %d", len(result.Receipts))
+	}
+
+	// single receipt as PDF
+	response = get("format=pdf&receipt=6a0b6a2e-3b2d-4d6b-9d53-0c1c2f6e8f01", "DNM5RS3C")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("Content-Type") != "application/pdf" || !bytes.HasPrefix(response.Body.Bytes(), []byte("%PDF")) {
+		t.Error("PDF document expected")
+	}
+
+	// receipt of another account
+	response = get("format=pdf&receipt=6a0b6a2e-3b2d-4d6b-9d53-0c1c2f6e8f01", "4KDNO8T0")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// invalid format
+	response = get("format=xml", "DNM5RS3C")
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+}
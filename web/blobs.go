@@ -0,0 +1,60 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/gorilla/mux"
+)
+
+// ServeBlob serves a blob of the local blob storage for a signed URL. Blobs stored with the
+// s3 driver are downloaded directly from the storage service.
+func ServeBlob(w http.ResponseWriter, r *http.Request) {
+	configured, err := util.GetBlobStore()
+	if err != nil {
+		conf.GetLogEnv().Err.Warnf("Blob storage not available: %s\n", err.Error())
+		http.NotFound(w, r)
+		return
+	}
+	store, ok := configured.(*util.LocalBlobStore)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	key := mux.Vars(r)["key"]
+	query := r.URL.Query()
+	if !store.VerifyURL(key, query.Get("expires"), query.Get("signature")) {
+		PrintErrorHTML(w, r, "The download link is invalid or expired", http.StatusForbidden)
+		return
+	}
+
+	blob, err := store.Get(key)
+	if err == util.ErrBlobNotFound {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		panic(err)
+	}
+	defer blob.Close()
+
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if content, ok := blob.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, path.Base(key), time.Time{}, content)
+		return
+	}
+	io.Copy(w, blob)
+}
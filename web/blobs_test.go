@@ -0,0 +1,67 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestServeBlob(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store, err := util.NewBlobStore(&conf.BlobStorage{Driver: conf.BlobStorageLocal, Path: dir, Secret: "secret"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer util.SetBlobStore(util.SetBlobStore(store))
+
+	err = store.Put("exports/alice.json", strings.NewReader(`{"login": "alice"}`), "application/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := store.SignedURL("exports/alice.json", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, _ := http.NewRequest("GET", signed, strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if response.Body.String() != `{"login": "alice"}` || !strings.HasPrefix(response.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("Stored JSON expected but was '%s'", response.Body.String())
+	}
+
+	u, _ := url.Parse(signed)
+	query := u.Query()
+	query.Set("signature", "invalid")
+	request, _ = http.NewRequest("GET", u.Path+"?"+query.Encode(), strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Assertion type of clients authenticating with a JWT (RFC 7523)
+const clientAssertionTypeJWT = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// authenticateClientAssertion returns the client which signed the assertion. If clientID is empty the
+// client is identified by the issuer of the assertion. The audience of the assertion must be the token
+// endpoint.
+func authenticateClientAssertion(r *http.Request, clientID, assertionType, assertion string) (*data.Client, error) {
+	if assertionType != clientAssertionTypeJWT {
+		return nil, errors.New("Unsupported client assertion type")
+	}
+
+	if clientID == "" {
+		jwt, err := util.ParseJWT(assertion)
+		if err != nil {
+			return nil, err
+		}
+		clientID = jwt.Claims.Issuer
+	}
+	client, ok := data.GetClientByName(clientID)
+	if !ok {
+		return nil, errors.New("Wrong client id or client assertion")
+	}
+
+	err := client.VerifyAssertion(assertion, conf.GetServerConfig().BaseURL+"/oauth/token")
+	if err != nil {
+		conf.GetLogEnv().Err.Errorf("Client assertion of '%s' rejected: %s\n", client.Name, err)
+		return nil, errors.New("Wrong client id or client assertion")
+	}
+	return client, nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestTokenClientAssertion(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// wb authenticates with assertions signed with its secret
+	clients, err := ioutil.TempFile("", "clients")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(clients.Name())
+	clients.WriteString(`
+- UUID: 177c56a4-57b4-4baf-a1a7-04f3d8e5b276
+  Name: wb
+  Secret: secret
+  AuthMethod: client_secret_jwt
+  ScopeWhitelist: [account-read, repo-read]
+`)
+	clients.Close()
+	data.InitClients(clients.Name())
+
+	mkAssertion := func(jti, aud string) string {
+		header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+		payload, _ := json.Marshal(map[string]interface{}{
+			"iss": "wb", "sub": "wb", "jti": jti, "aud": aud,
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+		mac := hmac.New(sha256.New, []byte("secret"))
+		mac.Write([]byte(input))
+		return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	}
+	mkRequest := func(assertion string) *http.Request {
+		body := &url.Values{}
+		body.Add("grant_type", "client_credentials")
+		body.Add("scope", "account-read repo-read")
+		if assertion != "" {
+			body.Add("client_assertion_type", clientAssertionTypeJWT)
+			body.Add("client_assertion", assertion)
+		}
+		request, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(body.Encode()))
+		request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		return request
+	}
+
+	// static secret is not accepted
+	request := mkRequest("")
+	request.SetBasicAuth("wb", "secret")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// only the token endpoint is accepted as audience
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, mkRequest(mkAssertion("a0", conf.GetServerConfig().BaseURL)))
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// valid assertion
+	assertion := mkAssertion("a1", conf.GetServerConfig().BaseURL+"/oauth/token")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, mkRequest(assertion))
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	// replayed assertion
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, mkRequest(assertion))
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// clientScopeRequestJSON is the JSON representation of a client scope request.
+type clientScopeRequestJSON struct {
+	UUID      string    `json:"uuid"`
+	ClientID  string    `json:"client_id"`
+	Scope     string    `json:"scope"`
+	Reason    string    `json:"reason"`
+	State     string    `json:"state"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// requestClient authenticates the client of a request via basic authentication with its
+// name (client id) and secret. The client must match the client given by the URL.
+func requestClient(w http.ResponseWriter, r *http.Request) (*data.Client, bool) {
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		PrintErrorJSON(w, r, "Client authentication required", http.StatusUnauthorized)
+		return nil, false
+	}
+	client, ok := data.GetClientByName(clientID)
+	if !ok || !client.AcceptsSecret(secret) {
+		PrintErrorJSON(w, r, "Wrong client id or client secret", http.StatusUnauthorized)
+		return nil, false
+	}
+	if id := mux.Vars(r)["id"]; id != client.Name && id != client.UUID {
+		PrintErrorJSON(w, r, "Access to the scope requests of other clients is not allowed", http.StatusForbidden)
+		return nil, false
+	}
+	return client, true
+}
+
+// ListClientScopeRequests is a handler which returns all scope requests of the authenticated
+// client together with their state as JSON.
+func ListClientScopeRequests(w http.ResponseWriter, r *http.Request) {
+	client, ok := requestClient(w, r)
+	if !ok {
+		return
+	}
+
+	marshal := clientScopeRequestsJSON(client.ListScopeRequests())
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// RequestClientScope is a handler which creates a request of the authenticated client for
+// an additional scope and notifies the administrators about it.
+func RequestClientScope(w http.ResponseWriter, r *http.Request) {
+	client, ok := requestClient(w, r)
+	if !ok {
+		return
+	}
+
+	body := &struct {
+		Scope  string `json:"scope"`
+		Reason string `json:"reason"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	request, err := client.RequestScope(body.Scope, body.Reason)
+	if err != nil {
+		if _, ok := err.(*util.ValidationError); ok {
+			PrintErrorJSON(w, r, err, http.StatusBadRequest)
+			return
+		}
+		panic(err)
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "client-scope-request",
+		"client": client.Name,
+		"scope":  request.Scope,
+		"ip":     remoteIP(r),
+	}).Info("Client scope requested")
+
+	subject := "GIN client scope request waiting for approval"
+	msg := fmt.Sprintf("The client %s requested the scope '%s':\n\n%s\n\nPlease grant or reject the request at %s/oauth/client_scope_requests",
+		client.Name, request.Scope, request.Reason, conf.GetServerConfig().BaseURL)
+	for _, login := range conf.GetRegistration().Administrators {
+		if admin, ok := data.GetAccountByLogin(login); ok {
+			err = admin.Notify(subject, msg)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	enc := json.NewEncoder(w)
+	enc.Encode(clientScopeRequestsJSON([]data.ClientScopeRequest{*request})[0])
+}
+
+// ClientScopeRequestsPage shows all client scope requests waiting for a decision to an
+// administrator logged in via session cookie.
+func ClientScopeRequestsPage(w http.ResponseWriter, r *http.Request) {
+	session, admin, ok := administratorSession(w, r)
+	if !ok {
+		return
+	}
+
+	pageData := struct {
+		Requests  []clientScopeRequestJSON
+		CSRFToken string
+		Time      *util.TimeFormat
+	}{clientScopeRequestsJSON(data.ListPendingClientScopeRequests()), sessionCSRFToken(session), admin.TimeFormat()}
+
+	tmpl := conf.MakeTemplate("clientscoperequests.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ClientScopeRequestsAction grants or rejects a client scope request submitted from the
+// client scope requests page.
+func ClientScopeRequestsAction(w http.ResponseWriter, r *http.Request) {
+	session, _, ok := administratorSession(w, r)
+	if !ok {
+		return
+	}
+
+	param := &struct {
+		Request   string
+		Action    string
+		CSRFToken string
+	}{}
+	err := util.ReadFormIntoStruct(r, param, false)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+	expected := sessionCSRFToken(session)
+	if !util.EqualTokens(param.CSRFToken, expected) {
+		PrintErrorHTML(w, r, "Invalid form token", http.StatusForbidden)
+		return
+	}
+
+	request, ok := data.GetClientScopeRequest(param.Request)
+	if !ok || request.State != data.ScopeRequestPending {
+		PrintErrorHTML(w, r, "The requested scope request does not exist or is not pending", http.StatusNotFound)
+		return
+	}
+
+	switch param.Action {
+	case "grant", "reject":
+		err = decideClientScopeRequest(request, param.Action == "grant", session.AccountUUID)
+	default:
+		PrintErrorHTML(w, r, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	http.Redirect(w, r, conf.MakePath("/oauth/client_scope_requests"), http.StatusFound)
+}
+
+// decideClientScopeRequest grants or rejects a client scope request and writes the decision
+// to the audit log.
+func decideClientScopeRequest(request *data.ClientScopeRequest, grant bool, adminUUID string) error {
+	client, ok := data.GetClient(request.ClientUUID)
+	if !ok {
+		return fmt.Errorf("Client of scope request %s does not exist", request.UUID)
+	}
+	adminLogin := ""
+	if admin, ok := data.GetAccount(adminUUID); ok {
+		adminLogin = admin.Login
+	}
+
+	var err error
+	var event string
+	if grant {
+		err = request.Grant(adminUUID)
+		event = "client-scope-granted"
+	} else {
+		err = request.Reject(adminUUID)
+		event = "client-scope-rejected"
+	}
+	if err != nil {
+		return err
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  event,
+		"client": client.Name,
+		"scope":  request.Scope,
+		"admin":  adminLogin,
+	}).Info("Client scope request decided")
+	return nil
+}
+
+// clientScopeRequestsJSON converts client scope requests into their JSON representation.
+func clientScopeRequestsJSON(requests []data.ClientScopeRequest) []clientScopeRequestJSON {
+	marshal := make([]clientScopeRequestJSON, 0, len(requests))
+	for _, req := range requests {
+		clientID := ""
+		if client, ok := data.GetClient(req.ClientUUID); ok {
+			clientID = client.Name
+		}
+		marshal = append(marshal, clientScopeRequestJSON{
+			UUID:      req.UUID,
+			ClientID:  clientID,
+			Scope:     req.Scope,
+			Reason:    req.Reason,
+			State:     req.State,
+			CreatedAt: req.CreatedAt,
+		})
+	}
+	return marshal
+}
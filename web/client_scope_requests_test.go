@@ -0,0 +1,143 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestRequestClientScope(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	post := func(path, clientID, secret, body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		if clientID != "" {
+			request.SetBasicAuth(clientID, secret)
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+	body := `{"scope": "account-create", "reason": "Registration of accounts"}`
+
+	// no client authentication
+	response := post("/api/clients/wb/scope_requests", "", "", body)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// wrong secret
+	response = post("/api/clients/wb/scope_requests", "wb", "wrong", body)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// other client
+	response = post("/api/clients/gin/scope_requests", "wb", "secret", body)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// invalid scope
+	response = post("/api/clients/wb/scope_requests", "wb", "secret", `{"scope": "account-admin", "reason": "Admin"}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	response = post("/api/clients/wb/scope_requests", "wb", "secret", body)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusCreated, response.Code)
+	}
+	created := &clientScopeRequestJSON{}
+	json.NewDecoder(response.Body).Decode(created)
+	if created.ClientID != "wb" || created.Scope != "account-create" || created.State != data.ScopeRequestPending {
+		t.Errorf("Unexpected scope request: %+v", created)
+	}
+
+	// list requests
+	request, _ := http.NewRequest("GET", "/api/clients/wb/scope_requests", strings.NewReader(""))
+	request.SetBasicAuth("wb", "secret")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	requests := make([]clientScopeRequestJSON, 0)
+	json.NewDecoder(response.Body).Decode(&requests)
+	if len(requests) != 2 {
+		t.Errorf("Two scope requests expected but were %d", len(requests))
+	}
+}
+
+func TestClientScopeRequestsAction(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	session := &data.Session{Token: sessionCookieBob}
+	const requestUUID = "c5f1e8a0-3b5d-4c1e-9f2a-7d6b8e4a1c01"
+
+	// page for administrators only
+	request, _ := http.NewRequest("GET", "/oauth/client_scope_requests", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken("DNM5RS3C")})
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	request, _ = http.NewRequest("GET", "/oauth/client_scope_requests", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(session.Token)})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "ssh-cert") {
+		t.Error("Page expected to show the pending request")
+	}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/oauth/client_scope_requests", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(session.Token)})
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// wrong form token
+	response = post(url.Values{"request": {requestUUID}, "action": {"grant"}, "csrf_token": {"wrong"}})
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// all ok
+	response = post(url.Values{"request": {requestUUID}, "action": {"grant"}, "csrf_token": {sessionCSRFToken(session)}})
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+	client, _ := data.GetClientByName("wb")
+	if !client.GrantedScope().Contains("ssh-cert") {
+		t.Error("Scope expected to be granted to the client")
+	}
+
+	// not pending
+	response = post(url.Values{"request": {requestUUID}, "action": {"reject"}, "csrf_token": {sessionCSRFToken(session)}})
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/gorilla/mux"
+)
+
+// clientStatsDaily is the JSON representation of the usage of a client on a single day.
+type clientStatsDaily struct {
+	Day              string `json:"day"`
+	Authorizations   int64  `json:"authorizations"`
+	TokensIssued     int64  `json:"tokens_issued"`
+	TokenValidations int64  `json:"token_validations"`
+	UniqueUsers      int64  `json:"unique_users"`
+}
+
+// clientStats is the JSON representation of the usage of a client.
+type clientStats struct {
+	ClientID         string             `json:"client_id"`
+	Authorizations   int64              `json:"authorizations"`
+	TokensIssued     int64              `json:"tokens_issued"`
+	TokenValidations int64              `json:"token_validations"`
+	UniqueUsers      int64              `json:"unique_users"`
+	Daily            []clientStatsDaily `json:"daily"`
+}
+
+// clientStatsBar is a client in the usage chart of the client statistics page.
+// The widths are percentages of the highest value of all clients.
+type clientStatsBar struct {
+	data.ClientUsageTotal
+	AuthorizationsWidth   int
+	TokensIssuedWidth     int
+	TokenValidationsWidth int
+	UniqueUsersWidth      int
+}
+
+// GetClientStats is a handler which returns the daily authorizations, issued tokens, token
+// validations and unique users of a client as JSON. The client is identified by its name
+// (client id) or UUID. The optional query parameter 'days' limits the period (default 30 days).
+func GetClientStats(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	client, ok := data.GetClientByName(id)
+	if !ok {
+		client, ok = data.GetClient(id)
+	}
+	if !ok {
+		PrintErrorJSON(w, r, "The requested client does not exist", http.StatusNotFound)
+		return
+	}
+
+	since, ok := usageSince(w, r)
+	if !ok {
+		return
+	}
+
+	marshal := &clientStats{ClientID: client.Name, Daily: make([]clientStatsDaily, 0)}
+	for _, total := range data.ListClientUsageTotals(since) {
+		if total.ClientName == client.Name {
+			marshal.Authorizations = total.Authorizations
+			marshal.TokensIssued = total.TokensIssued
+			marshal.TokenValidations = total.TokenValidations
+			marshal.UniqueUsers = total.UniqueUsers
+		}
+	}
+	for _, u := range data.ListClientUsage(client.UUID, since) {
+		marshal.Daily = append(marshal.Daily, clientStatsDaily{
+			Day:              u.Day.Format("2006-01-02"),
+			Authorizations:   u.Authorizations,
+			TokensIssued:     u.TokensIssued,
+			TokenValidations: u.TokenValidations,
+			UniqueUsers:      u.UniqueUsers,
+		})
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// ClientStatsPage shows the usage of all clients within the last 30 days as charts to an
+// administrator logged in via session cookie. Clients without any usage are marked as inactive.
+func ClientStatsPage(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := administratorSession(w, r); !ok {
+		return
+	}
+
+	totals := data.ListClientUsageTotals(time.Now().AddDate(0, 0, 1-defaultUsageDays))
+	var max data.ClientUsageTotal
+	for _, t := range totals {
+		max.Authorizations = maxInt64(max.Authorizations, t.Authorizations)
+		max.TokensIssued = maxInt64(max.TokensIssued, t.TokensIssued)
+		max.TokenValidations = maxInt64(max.TokenValidations, t.TokenValidations)
+		max.UniqueUsers = maxInt64(max.UniqueUsers, t.UniqueUsers)
+	}
+
+	bars := make([]clientStatsBar, 0, len(totals))
+	for _, t := range totals {
+		bars = append(bars, clientStatsBar{
+			ClientUsageTotal:      t,
+			AuthorizationsWidth:   percentOf(t.Authorizations, max.Authorizations),
+			TokensIssuedWidth:     percentOf(t.TokensIssued, max.TokensIssued),
+			TokenValidationsWidth: percentOf(t.TokenValidations, max.TokenValidations),
+			UniqueUsersWidth:      percentOf(t.UniqueUsers, max.UniqueUsers),
+		})
+	}
+
+	pageData := struct {
+		Days    int
+		Clients []clientStatsBar
+	}{defaultUsageDays, bars}
+
+	tmpl := conf.MakeTemplate("clientstats.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// percentOf returns value as percentage of max.
+func percentOf(value, max int64) int {
+	if max <= 0 {
+		return 0
+	}
+	return int(value * 100 / max)
+}
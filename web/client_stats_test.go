@@ -0,0 +1,84 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func TestGetClientStats(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no admin scope
+	request, _ := http.NewRequest("GET", "/api/clients/gin/stats", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// unknown client
+	request, _ = http.NewRequest("GET", "/api/clients/doesnotexist/stats", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/clients/gin/stats?days=7", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	stats := &clientStats{}
+	err := json.NewDecoder(response.Body).Decode(stats)
+	if err != nil {
+		t.Error(err)
+	}
+	if stats.ClientID != "gin" || stats.Authorizations != 24 || stats.UniqueUsers != 2 || len(stats.Daily) != 2 {
+		t.Errorf("Unexpected client stats: %v", stats)
+	}
+}
+
+func TestClientStatsPage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// not an administrator
+	request, _ := http.NewRequest("GET", "/oauth/client_stats", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken("DNM5RS3C")})
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/oauth/client_stats", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "wb") {
+		t.Error("Client statistics page expected to show all clients")
+	}
+}
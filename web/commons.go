@@ -10,15 +10,20 @@ package web
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 
+	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/data"
 	"github.com/G-Node/gin-auth/util"
 )
 
 // createGrantRequest creates a Grant Request for a client and redirects to a forwarding URI.
+// The optional return URL is kept with the request and passed back to the client once the
+// request is finished.
 func createGrantRequest(w http.ResponseWriter, r *http.Request, forwardURI string) {
 	param := &struct {
 		ResponseType string
@@ -26,6 +31,7 @@ func createGrantRequest(w http.ResponseWriter, r *http.Request, forwardURI strin
 		RedirectURI  string
 		State        string
 		Scope        string
+		ReturnTo     string
 	}{}
 
 	err := util.ReadQueryIntoStruct(r, param, false)
@@ -41,7 +47,7 @@ func createGrantRequest(w http.ResponseWriter, r *http.Request, forwardURI strin
 	}
 
 	scope := util.NewStringSet(strings.Split(param.Scope, " ")...)
-	request, err := client.CreateGrantRequest(param.ResponseType, param.RedirectURI, param.State, scope)
+	request, err := client.CreateGrantRequest(param.ResponseType, param.RedirectURI, param.State, scope, param.ReturnTo)
 	if err != nil {
 		PrintErrorHTML(w, r, err, http.StatusBadRequest)
 		return
@@ -64,3 +70,50 @@ func redirectionScript(redirectURI string, delay int) string {
 
 	return scriptBlock
 }
+
+// isInternalRequest checks whether the request comes from one of the configured internal networks.
+// Internal requests are not rate limited per address.
+func isInternalRequest(r *http.Request) bool {
+	return conf.GetServerConfig().IsInternal(net.ParseIP(remoteIP(r)))
+}
+
+// rateLimitFactor returns the factor by which per-account rate limits are raised for the request.
+func rateLimitFactor(r *http.Request) int {
+	if isInternalRequest(r) {
+		return conf.GetServerConfig().InternalRateFactor
+	}
+	return 1
+}
+
+// remoteIP returns the normalized IP address of the requester without the port.
+// See util.NormalizeIP for details.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := util.NormalizeIP(host); ip != nil {
+		return ip.String()
+	}
+	return host
+}
+
+// rateLimitKey returns the key under which the requester is rate limited per address.
+// IPv6 addresses are grouped by the network configured with IPv6RateLimitPrefix.
+func rateLimitKey(r *http.Request) string {
+	return util.IPBucket(net.ParseIP(remoteIP(r)), conf.GetServerConfig().IPv6RateLimitPrefix)
+}
+
+// isDryRun reads the query parameter 'dry_run' of destructive admin requests. In a dry run the
+// handler returns what would be affected without changing anything.
+func isDryRun(r *http.Request) (bool, error) {
+	param := r.URL.Query().Get("dry_run")
+	if param == "" {
+		return false, nil
+	}
+	dry, err := strconv.ParseBool(param)
+	if err != nil {
+		return false, fmt.Errorf("Parameter 'dry_run' must be 'true' or 'false'")
+	}
+	return dry, nil
+}
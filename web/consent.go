@@ -0,0 +1,168 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// clientPublisher describes who publishes a client and whether the publisher was verified, such
+// that users can judge a client before they grant it access.
+type clientPublisher struct {
+	Name     string `json:"name"`
+	Homepage string `json:"homepage"`
+	LogoURL  string `json:"logo_url"`
+	Verified bool   `json:"verified"`
+}
+
+// newClientPublisher returns the publisher of a client.
+func newClientPublisher(client *data.Client) *clientPublisher {
+	return &clientPublisher{
+		Name:     client.PublisherName,
+		Homepage: client.Homepage,
+		LogoURL:  client.LogoURL,
+		Verified: client.Verified,
+	}
+}
+
+// consentJSON is the JSON representation of a grant request awaiting the consent of the
+// account owner. The CSRF token must be sent with the decision.
+type consentJSON struct {
+	RequestID     string            `json:"request_id"`
+	Client        string            `json:"client"`
+	Publisher     *clientPublisher  `json:"publisher"`
+	GrantType     string            `json:"grant_type"`
+	Scope         map[string]string `json:"scope"`
+	ExistingScope map[string]string `json:"existing_scope"`
+	Renewal       bool              `json:"renewal"`
+	CSRFToken     string            `json:"csrf_token"`
+}
+
+// consentGrantRequest looks up the grant request of a consent API call. The request must
+// belong to the account of the session cookie. Errors are written to the response.
+func consentGrantRequest(w http.ResponseWriter, r *http.Request) (*data.GrantRequest, *sessionInfo, bool) {
+	info, ok := lookupSession(r)
+	if !ok {
+		PrintErrorJSON(w, r, "No valid session", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+
+	request, ok := data.GetGrantRequest(mux.Vars(r)["request_id"])
+	if !ok || !request.AccountUUID.Valid || request.AccountUUID.String != info.account.UUID {
+		PrintErrorJSON(w, r, "Grant request does not exist", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return request, info, true
+}
+
+// GetConsent returns a pending grant request of the session account as JSON, such that a
+// front end can render the approval screen.
+func GetConsent(w http.ResponseWriter, r *http.Request) {
+	request, info, ok := consentGrantRequest(w, r)
+	if !ok {
+		return
+	}
+
+	client := request.Client()
+	addScope, existScope, renewal := approvalScope(request, client)
+
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(&consentJSON{
+		RequestID:     request.Token,
+		Client:        client.Name,
+		Publisher:     newClientPublisher(client),
+		GrantType:     request.GrantType,
+		Scope:         addScope,
+		ExistingScope: existScope,
+		Renewal:       renewal,
+		CSRFToken:     sessionCSRFToken(info.session),
+	})
+}
+
+// DecideConsent approves or denies a pending grant request of the session account. The
+// CSRF token of GetConsent must be sent in the header 'X-CSRF-Token'. The response contains
+// the URI the front end has to redirect to, either with a code or access token, or with the
+// error 'access_denied'.
+func DecideConsent(w http.ResponseWriter, r *http.Request) {
+	request, info, ok := consentGrantRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if !util.EqualTokens(r.Header.Get("X-CSRF-Token"), sessionCSRFToken(info.session)) {
+		PrintErrorJSON(w, r, "Invalid CSRF token", http.StatusForbidden)
+		return
+	}
+
+	body := &struct {
+		Decision string `json:"decision"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil || (body.Decision != "approve" && body.Decision != "deny") {
+		PrintErrorJSON(w, r, "Decision must be either 'approve' or 'deny'", http.StatusBadRequest)
+		return
+	}
+
+	client := request.Client()
+	var redirect string
+	if body.Decision == "approve" {
+		if err = data.CheckScopeGrant(request.AccountUUID.String, client.UUID, request.ScopeRequested); err != nil {
+			PrintErrorJSON(w, r, err, http.StatusForbidden)
+			return
+		}
+
+		err = client.Approve(request.AccountUUID.String, request.ScopeRequested)
+		if err != nil {
+			panic(err)
+		}
+		if !request.IsApproved() {
+			panic("Requested scope should be approved but was not")
+		}
+
+		redirect, err = grantRedirectURL(r, request)
+		if err != nil {
+			PrintErrorJSON(w, r, err, http.StatusForbidden)
+			return
+		}
+	} else {
+		err = request.Delete()
+		if err != nil {
+			panic(err)
+		}
+		redirect = fmt.Sprintf("%s?error=access_denied&state=%s", request.RedirectURI, url.QueryEscape(request.State))
+		redirect = withReturnTo(redirect, request)
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "consent-" + body.Decision,
+		"login":  info.account.Login,
+		"client": client.Name,
+		"scope":  request.ScopeRequested.Strings(),
+		"ip":     remoteIP(r),
+	}).Info("Consent decision made")
+
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(&struct {
+		RedirectURI string `json:"redirect_uri"`
+	}{redirect})
+}
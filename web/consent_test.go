@@ -0,0 +1,112 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestConsentAPI(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	do := func(method, id, cookie, csrf, body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(method, "/api/consent/"+id, strings.NewReader(body))
+		if cookie != "" {
+			request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(cookie)})
+		}
+		if csrf != "" {
+			request.Header.Set("X-CSRF-Token", csrf)
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// no session
+	response := do("GET", "B4LIMIMB", "", "", "")
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+	// grant request of another account
+	response = do("GET", "U7JIKKYI", sessionCookieBob, "", "")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	response = do("GET", "B4LIMIMB", sessionCookieBob, "", "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	consent := &consentJSON{}
+	json.Unmarshal(response.Body.Bytes(), consent)
+	if consent.Client != "gin" || consent.CSRFToken == "" || len(consent.Scope) == 0 {
+		t.Errorf("Unexpected consent data: %s", response.Body.String())
+	}
+	if consent.Publisher == nil || consent.Publisher.Name != "G-Node" || !consent.Publisher.Verified {
+		t.Errorf("Publisher of the client expected: %s", response.Body.String())
+	}
+
+	// missing or wrong CSRF token
+	response = do("POST", "B4LIMIMB", sessionCookieBob, "", `{"decision": "approve"}`)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+	response = do("POST", "B4LIMIMB", sessionCookieBob, "wrong", `{"decision": "approve"}`)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+	// invalid decision
+	response = do("POST", "B4LIMIMB", sessionCookieBob, consent.CSRFToken, `{"decision": "maybe"}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// approve
+	response = do("POST", "B4LIMIMB", sessionCookieBob, consent.CSRFToken, `{"decision": "approve"}`)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	result := &struct {
+		RedirectURI string `json:"redirect_uri"`
+	}{}
+	json.Unmarshal(response.Body.Bytes(), result)
+	redirect, err := url.Parse(result.RedirectURI)
+	if err != nil || redirect.Query().Get("code") == "" {
+		t.Errorf("Code expected in redirect URI: %s", result.RedirectURI)
+	}
+}
+
+func TestConsentAPIDeny(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	session, _ := data.GetSession(sessionCookieBob)
+	request, _ := http.NewRequest("POST", "/api/consent/B4LIMIMB", strings.NewReader(`{"decision": "deny"}`))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
+	request.Header.Set("X-CSRF-Token", sessionCSRFToken(session))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "error=access_denied") {
+		t.Errorf("Error expected in redirect URI: %s", response.Body.String())
+	}
+	if _, ok := data.GetGrantRequest("B4LIMIMB"); ok {
+		t.Error("Grant request should be deleted")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+// cookieMAC returns the base64 encoded HMAC-SHA256 of a session token.
+func cookieMAC(key []byte, token string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// signSessionToken returns the cookie value of a session token, the token followed by its
+// HMAC using the first configured cookie key.
+func signSessionToken(token string) string {
+	return token + "." + cookieMAC(conf.GetCookieKeys().Keys[0], token)
+}
+
+// verifySessionToken checks the HMAC of a cookie value against all configured cookie keys
+// and returns the session token. If the value was signed with a key other than the first,
+// rotate is true and the cookie should be signed again.
+func verifySessionToken(value string) (token string, rotate bool, ok bool) {
+	i := strings.LastIndex(value, ".")
+	if i < 1 {
+		return "", false, false
+	}
+	token, mac := value[:i], value[i+1:]
+
+	for n, key := range conf.GetCookieKeys().Keys {
+		if hmac.Equal([]byte(mac), []byte(cookieMAC(key, token))) {
+			return token, n > 0, true
+		}
+	}
+	return "", false, false
+}
+
+// requestSessionToken returns the verified session token from the session cookie of a request.
+// Returns false if there is no cookie or if its signature is invalid.
+func requestSessionToken(r *http.Request) (token string, rotate bool, ok bool) {
+	cookie, err := r.Cookie(conf.GetServerConfig().CookieName)
+	if err != nil {
+		return "", false, false
+	}
+	return verifySessionToken(cookie.Value)
+}
+
+// requestSession returns the session of the session cookie of a request. A cookie signed with a
+// retired key is signed again with the first key, thus sessions survive the removal of the old key
+// once they were used.
+func requestSession(w http.ResponseWriter, r *http.Request) (*data.Session, bool) {
+	token, rotate, ok := requestSessionToken(r)
+	if !ok {
+		return nil, false
+	}
+	session, ok := data.GetSession(token)
+	if !ok {
+		return nil, false
+	}
+	if rotate {
+		http.SetCookie(w, sessionCookie(r, session.Token, session.Expires))
+	}
+	return session, true
+}
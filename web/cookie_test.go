@@ -0,0 +1,83 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func TestSignSessionToken(t *testing.T) {
+	value := signSessionToken(sessionCookieBob)
+	token, rotate, ok := verifySessionToken(value)
+	if !ok || rotate || token != sessionCookieBob {
+		t.Errorf("Token '%s' expected to be valid without rotation", sessionCookieBob)
+	}
+
+	// signed with an old key
+	old := sessionCookieBob + "." + cookieMAC(conf.GetCookieKeys().Keys[1], sessionCookieBob)
+	token, rotate, ok = verifySessionToken(old)
+	if !ok || !rotate || token != sessionCookieBob {
+		t.Error("Token signed with an old key expected to be valid and rotated")
+	}
+
+	// invalid values
+	for _, v := range []string{sessionCookieBob, "", "." + cookieMAC(conf.GetCookieKeys().Keys[0], ""), "DNM5RS3C" + value[len(sessionCookieBob):], value + "x"} {
+		if _, _, ok = verifySessionToken(v); ok {
+			t.Errorf("Cookie value '%s' expected to be invalid", v)
+		}
+	}
+
+	// from a request
+	request, _ := http.NewRequest("GET", "/", nil)
+	if _, _, ok = requestSessionToken(request); ok {
+		t.Error("Request without cookie expected to have no session token")
+	}
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: value})
+	if token, _, ok = requestSessionToken(request); !ok || token != sessionCookieBob {
+		t.Error("Session token of request expected to be valid")
+	}
+}
+
+func TestRequestSession(t *testing.T) {
+	InitTestHttpHandler(t)
+
+	mkRequest := func(value string) (*httptest.ResponseRecorder, bool) {
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: value})
+		response := httptest.NewRecorder()
+		_, ok := requestSession(response, request)
+		return response, ok
+	}
+
+	response, ok := mkRequest(signSessionToken(sessionCookieBob))
+	if !ok {
+		t.Fatal("Session expected")
+	}
+	if response.Header().Get("Set-Cookie") != "" {
+		t.Error("Cookie signed with the first key expected to be kept")
+	}
+
+	// signed with a retired key
+	response, ok = mkRequest(sessionCookieBob + "." + cookieMAC(conf.GetCookieKeys().Keys[1], sessionCookieBob))
+	if !ok {
+		t.Fatal("Session expected")
+	}
+	if !strings.Contains(response.Header().Get("Set-Cookie"), signSessionToken(sessionCookieBob)) {
+		t.Errorf("Cookie expected to be signed again: %s", response.Header().Get("Set-Cookie"))
+	}
+
+	if _, ok = mkRequest(signSessionToken("doesnotexist")); ok {
+		t.Error("No session expected for an unknown token")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+)
+
+// Deprecation describes when routes were deprecated, when they will be removed and where
+// their replacement is documented. Sunset and Link are optional.
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+	Link   string
+}
+
+// maxDeprecatedCallers bounds the number of callers remembered per day. When it is reached the
+// callers are forgotten and logged again on their next call.
+const maxDeprecatedCallers = 10000
+
+// Callers of deprecated routes which were already logged on the current day.
+var deprecatedCallers = struct {
+	sync.Mutex
+	day  string
+	seen map[string]bool
+}{seen: make(map[string]bool)}
+
+// DeprecationHandler marks routes as deprecated: responses carry the headers 'Deprecation' and,
+// if set, 'Sunset' and 'Link', and each caller is logged once a day with client ID and user
+// agent, such that the operators know whom to contact before the routes are removed.
+func DeprecationHandler(dep Deprecation) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", dep.Since.Unix()))
+			if !dep.Sunset.IsZero() {
+				w.Header().Set("Sunset", dep.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if dep.Link != "" {
+				w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"deprecation\"", dep.Link))
+			}
+
+			logDeprecatedCall(r, dep)
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// logDeprecatedCall logs the call of a deprecated route unless the client was already logged
+// for this route on the current day. The client is looked up only if it has to be logged.
+func logDeprecatedCall(r *http.Request, dep Deprecation) {
+	path := routePath(r)
+	client, isUUID := requestClientKey(r)
+
+	key := r.Method + " " + path + "\x00" + client
+	today := util.Now().Format("2006-01-02")
+
+	deprecatedCallers.Lock()
+	if deprecatedCallers.day != today || len(deprecatedCallers.seen) >= maxDeprecatedCallers {
+		deprecatedCallers.day = today
+		deprecatedCallers.seen = make(map[string]bool)
+	}
+	seen := deprecatedCallers.seen[key]
+	deprecatedCallers.seen[key] = true
+	deprecatedCallers.Unlock()
+
+	if seen {
+		return
+	}
+
+	if isUUID {
+		if c, ok := data.GetClient(client); ok {
+			client = c.Name
+		}
+	}
+	fields := logrus.Fields{
+		"method":     r.Method,
+		"path":       path,
+		"client_id":  client,
+		"user_agent": r.UserAgent(),
+		"ip":         remoteIP(r),
+	}
+	if !dep.Sunset.IsZero() {
+		fields["sunset"] = dep.Sunset.UTC().Format("2006-01-02")
+	}
+	conf.GetLogEnv().Err.WithFields(fields).Warn("Deprecated route called")
+}
+
+// requestClientKey identifies the client which sent a request without looking up the client:
+// either the UUID of the client of the bearer token, in which case isUUID is true, or the client ID
+// of the basic authentication or the query. An empty string is returned for unknown clients.
+func requestClientKey(r *http.Request) (key string, isUUID bool) {
+	if oauth, ok := OAuthToken(r); ok {
+		return oauth.Token.ClientUUID, true
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		if token, ok := data.GetAccessToken(strings.Trim(auth[6:], " ")); ok {
+			return token.ClientUUID, true
+		}
+		return "", false
+	}
+	if id, _, ok := r.BasicAuth(); ok {
+		return id, false
+	}
+	return r.URL.Query().Get("client_id"), false
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/gorilla/mux"
+)
+
+func TestDeprecationHandler(t *testing.T) {
+	log := &bytes.Buffer{}
+	errLog := conf.GetLogEnv().Err
+	out := errLog.Out
+	errLog.Out = log
+	defer func() { errLog.Out = out }()
+
+	dep := Deprecation{
+		Since:  time.Date(2016, 6, 1, 0, 0, 0, 0, time.UTC),
+		Sunset: time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC),
+		Link:   "https://example.com/migration",
+	}
+	router := mux.NewRouter()
+	NewRouteGroup(router, DeprecationHandler(dep)).HandleFunc("/old/{id}", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+	NewRouteGroup(router).HandleFunc("/new/{id}", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+
+	get := func(path, agent string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", path, strings.NewReader(""))
+		request.Header.Set("User-Agent", agent)
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	response := get("/old/1?client_id=wb", "wb-client/1.0")
+	if response.Header().Get("Deprecation") != "@1464739200" {
+		t.Errorf("Unexpected deprecation header: %s", response.Header().Get("Deprecation"))
+	}
+	if response.Header().Get("Sunset") != "Sat, 31 Dec 2016 00:00:00 GMT" {
+		t.Errorf("Unexpected sunset header: %s", response.Header().Get("Sunset"))
+	}
+	if response.Header().Get("Link") != `<https://example.com/migration>; rel="deprecation"` {
+		t.Errorf("Unexpected link header: %s", response.Header().Get("Link"))
+	}
+	if !strings.Contains(log.String(), "client_id=wb") || !strings.Contains(log.String(), "/old/{id}") {
+		t.Errorf("Caller expected to be logged: %s", log.String())
+	}
+
+	// same client is logged only once a day
+	log.Reset()
+	get("/old/2?client_id=wb", "wb-client/2.0")
+	if log.Len() != 0 {
+		t.Errorf("Client expected to be logged only once: %s", log.String())
+	}
+	get("/old/2?client_id=gin-cli", "wb-client/1.0")
+	if !strings.Contains(log.String(), "client_id=gin-cli") {
+		t.Errorf("New client expected to be logged: %s", log.String())
+	}
+
+	// the remembered callers are bounded
+	deprecatedCallers.Lock()
+	for i := len(deprecatedCallers.seen); i < maxDeprecatedCallers; i++ {
+		deprecatedCallers.seen[fmt.Sprintf("GET /old/{id}\x00client-%d", i)] = true
+	}
+	deprecatedCallers.Unlock()
+	log.Reset()
+	get("/old/3?client_id=wb", "wb-client/1.0")
+	deprecatedCallers.Lock()
+	size := len(deprecatedCallers.seen)
+	deprecatedCallers.Unlock()
+	if size != 1 || !strings.Contains(log.String(), "client_id=wb") {
+		t.Errorf("Callers expected to be forgotten when the bound is reached: %d", size)
+	}
+
+	// routes which are not deprecated
+	response = get("/new/1", "")
+	if response.Header().Get("Deprecation") != "" || response.Header().Get("Sunset") != "" {
+		t.Error("No deprecation headers expected")
+	}
+}
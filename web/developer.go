@@ -0,0 +1,41 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+// DeveloperScopesPage lists all scopes provided by registered clients together with their
+// descriptions and the clients which may request them, as documentation for integrators.
+func DeveloperScopesPage(w http.ResponseWriter, r *http.Request) {
+	pageData := struct {
+		Scopes []data.ScopeInfo
+	}{data.ListScopes()}
+
+	tmpl := conf.MakeTemplate("developerscopes.html")
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ListScopes is a handler which returns all scopes provided by registered clients as JSON.
+func ListScopes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(data.ListScopes())
+}
@@ -0,0 +1,54 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDeveloperScopesPage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("GET", "/developer/scopes", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "Read access to your repositories") {
+		t.Error("Page expected to contain scope descriptions")
+	}
+}
+
+func TestListScopes(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("GET", "/api/scopes", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	scopes := make([]struct {
+		Name          string   `json:"name"`
+		RequestableBy []string `json:"requestable_by"`
+	}, 0)
+	err := json.NewDecoder(response.Body).Decode(&scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scopes) != 6 || scopes[0].Name != "account-admin" || len(scopes[0].RequestableBy) != 0 {
+		t.Errorf("Unexpected scopes: %v", scopes)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/gorilla/mux"
+)
+
+// emailBounce is the JSON representation of a bouncing e-mail address.
+type emailBounce struct {
+	Email      string    `json:"email"`
+	Type       string    `json:"type"`
+	Reason     string    `json:"reason"`
+	Count      int       `json:"count,omitempty"`
+	Suppressed bool      `json:"suppressed"`
+	CreatedAt  time.Time `json:"created_at,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+}
+
+func newEmailBounce(bounce *data.EmailBounce) emailBounce {
+	return emailBounce{
+		Email:      bounce.Email,
+		Type:       bounce.BounceType,
+		Reason:     bounce.Reason,
+		Count:      bounce.Count,
+		Suppressed: bounce.IsSuppressed,
+		CreatedAt:  bounce.CreatedAt,
+		UpdatedAt:  bounce.UpdatedAt,
+	}
+}
+
+// ReportEmailBounces is the webhook for the mail provider, which reports bounces and complaints
+// as a JSON list. The request must be authorized by HTTP basic auth with the configured secret
+// as password. The webhook is disabled if no secret is configured.
+func ReportEmailBounces(w http.ResponseWriter, r *http.Request) {
+	secret := conf.GetEmailBounces().Secret
+	if secret == "" {
+		PrintErrorJSON(w, r, "Bounce processing is not enabled", http.StatusNotFound)
+		return
+	}
+	_, password, ok := r.BasicAuth()
+	if !ok || !util.EqualTokens(password, secret) {
+		PrintErrorJSON(w, r, "Invalid bounce webhook secret", http.StatusUnauthorized)
+		return
+	}
+
+	reports := make([]emailBounce, 0)
+	err := decodeJSON(r, &reports)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	recorded := make([]emailBounce, 0, len(reports))
+	for _, report := range reports {
+		bounce, err := data.RecordEmailBounce(report.Email, report.Type, report.Reason)
+		if err != nil {
+			PrintErrorJSON(w, r, err, http.StatusBadRequest)
+			return
+		}
+		recorded = append(recorded, newEmailBounce(bounce))
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(recorded)
+}
+
+// ListEmailBounces is a handler which streams all bouncing e-mail addresses as JSON.
+func ListEmailBounces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	stream := util.NewJSONArrayStream(w)
+	err := data.EachEmailBounce(func(bounce *data.EmailBounce) error {
+		return stream.Write(newEmailBounce(bounce))
+	})
+	if err != nil {
+		panic(err)
+	}
+	stream.Close()
+}
+
+// DeleteEmailBounce is a handler which removes the bounces of an e-mail address,
+// e.g. after the account owner fixed a full mailbox. E-mails are sent to the address again.
+func DeleteEmailBounce(w http.ResponseWriter, r *http.Request) {
+	bounce, ok := data.GetEmailBounce(mux.Vars(r)["email"])
+	if !ok {
+		PrintErrorJSON(w, r, "No bounces for this address", http.StatusNotFound)
+		return
+	}
+
+	err := bounce.Delete()
+	if err != nil {
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(newEmailBounce(bounce))
+}
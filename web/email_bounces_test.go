@@ -0,0 +1,114 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestReportEmailBounces(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	body := `[{"email": "aclic@foo.com", "type": "hard", "reason": "550 5.1.1 User unknown"}]`
+
+	// wrong secret
+	request, _ := http.NewRequest("POST", "/api/email_bounces", strings.NewReader(body))
+	request.SetBasicAuth("bounces", "wrong")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid type
+	request, _ = http.NewRequest("POST", "/api/email_bounces", strings.NewReader(`[{"email": "aclic@foo.com", "type": "x"}]`))
+	request.SetBasicAuth("bounces", "bouncesecret")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("POST", "/api/email_bounces", strings.NewReader(body))
+	request.SetBasicAuth("bounces", "bouncesecret")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	alice, _ := data.GetAccountByLogin("alice")
+	if !alice.IsEmailBouncing {
+		t.Error("Account of alice should be flagged as bouncing")
+	}
+}
+
+func TestListEmailBounces(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no admin scope
+	request, _ := http.NewRequest("GET", "/api/email_bounces", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/email_bounces", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	bounces := make([]emailBounce, 0)
+	err := json.NewDecoder(response.Body).Decode(&bounces)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(bounces) != 2 {
+		t.Errorf("Expected 2 bounces but got %d", len(bounces))
+	}
+}
+
+func TestDeleteEmailBounce(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// not bouncing
+	request, _ := http.NewRequest("DELETE", "/api/email_bounces/aclic@foo.com", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("DELETE", "/api/email_bounces/jj@example.com", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	if _, ok := data.GetEmailBounce("jj@example.com"); ok {
+		t.Error("Bounce should not exist")
+	}
+}
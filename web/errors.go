@@ -14,6 +14,7 @@ import (
 	"net/http"
 
 	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
 	"github.com/G-Node/gin-auth/util"
 )
 
@@ -80,3 +81,19 @@ func PrintErrorJSON(w http.ResponseWriter, r *http.Request, err interface{}, cod
 	enc := json.NewEncoder(w)
 	enc.Encode(errData)
 }
+
+// errorStatus maps an error returned by the data package to a HTTP status code.
+// If the error has no known kind the given fallback code is returned.
+func errorStatus(err error, fallback int) int {
+	switch data.KindOf(err) {
+	case data.ErrNotFound:
+		return http.StatusNotFound
+	case data.ErrConflict:
+		return http.StatusConflict
+	case data.ErrExpired:
+		return http.StatusGone
+	case data.ErrForbidden:
+		return http.StatusForbidden
+	}
+	return fallback
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// featureFlagJSON is the JSON representation of a feature flag. Override is true if the
+// flag was set by an administrator and replaces the configured flag.
+type featureFlagJSON struct {
+	Name       string     `json:"name"`
+	Enabled    bool       `json:"enabled"`
+	Percentage int        `json:"percentage"`
+	Accounts   []string   `json:"accounts"`
+	Override   bool       `json:"override"`
+	UpdatedBy  *string    `json:"updated_by,omitempty"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// requestAccount returns the account of a request, either the account of the OAuth token
+// registered by an OAuthHandler or the account of the session cookie.
+func requestAccount(r *http.Request) (*data.Account, bool) {
+	if oauth, ok := OAuthToken(r); ok && oauth.Token.AccountUUID.Valid {
+		return data.GetAccount(oauth.Token.AccountUUID.String)
+	}
+	if info, ok := lookupSession(r); ok {
+		return info.account, true
+	}
+	return nil, false
+}
+
+// featureEnabled checks whether the feature flag with the given name is on for the account
+// of a request. Handlers use it to switch between the current and a new implementation.
+func featureEnabled(r *http.Request, name string) bool {
+	account, _ := requestAccount(r)
+	return data.IsFeatureEnabled(name, account)
+}
+
+// FeatureHandler hides routes of a feature which is not yet launched: requests are answered
+// with status 404 unless the feature flag with the given name is on for the account of the request.
+func FeatureHandler(name string) Middleware {
+	return func(handler http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !featureEnabled(r, name) {
+				if strings.HasPrefix(r.URL.Path, "/api/") {
+					PrintErrorJSON(w, r, "Not found", http.StatusNotFound)
+				} else {
+					PrintErrorHTML(w, r, "Not found", http.StatusNotFound)
+				}
+				return
+			}
+
+			handler.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ListFeatureFlags is a handler which returns all configured feature flags and those set
+// by an administrator as JSON.
+func ListFeatureFlags(w http.ResponseWriter, r *http.Request) {
+	flags := data.ListFeatureFlags()
+	marshal := make([]*featureFlagJSON, 0, len(flags))
+	for i := range flags {
+		marshal = append(marshal, featureFlagMarshaler(&flags[i]))
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// UpdateFeatureFlag is a handler which sets a feature flag, overriding a configured flag
+// with the same name.
+func UpdateFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	body := &struct {
+		Enabled    bool     `json:"enabled"`
+		Percentage int      `json:"percentage"`
+		Accounts   []string `json:"accounts"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Invalid feature flag data", http.StatusBadRequest)
+		return
+	}
+
+	flag := &data.FeatureFlag{
+		Name:       mux.Vars(r)["name"],
+		Enabled:    body.Enabled,
+		Percentage: body.Percentage,
+		Accounts:   util.NewStringSet(body.Accounts...),
+	}
+	err = flag.SaveBy(oauth.Token.AccountUUID.String)
+	if err != nil {
+		if _, ok := err.(*util.ValidationError); ok {
+			PrintErrorJSON(w, r, err, http.StatusBadRequest)
+			return
+		}
+		panic(err)
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":      "feature-flag-updated",
+		"feature":    flag.Name,
+		"enabled":    flag.Enabled,
+		"percentage": flag.Percentage,
+		"accounts":   flag.Accounts.Strings(),
+		"admin":      featureFlagAdmin(flag),
+		"ip":         remoteIP(r),
+	}).Info("Feature flag updated")
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(featureFlagMarshaler(flag))
+}
+
+// DeleteFeatureFlag is a handler which removes a feature flag set by an administrator,
+// such that the configured flag with the same name applies again.
+func DeleteFeatureFlag(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	name := mux.Vars(r)["name"]
+	err := data.DeleteFeatureFlag(name)
+	if data.KindOf(err) == data.ErrNotFound {
+		PrintErrorJSON(w, r, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	admin := ""
+	if acc, ok := data.GetAccount(oauth.Token.AccountUUID.String); ok {
+		admin = acc.Login
+	}
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":   "feature-flag-deleted",
+		"feature": name,
+		"admin":   admin,
+		"ip":      remoteIP(r),
+	}).Info("Feature flag deleted")
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// featureFlagAdmin returns the login of the administrator who set a flag.
+func featureFlagAdmin(flag *data.FeatureFlag) string {
+	if flag.UpdatedBy.Valid {
+		if acc, ok := data.GetAccount(flag.UpdatedBy.String); ok {
+			return acc.Login
+		}
+	}
+	return ""
+}
+
+// featureFlagMarshaler prepares a feature flag for JSON output.
+func featureFlagMarshaler(flag *data.FeatureFlag) *featureFlagJSON {
+	marshal := &featureFlagJSON{
+		Name:       flag.Name,
+		Enabled:    flag.Enabled,
+		Percentage: flag.Percentage,
+		Accounts:   flag.Accounts.Strings(),
+		Override:   flag.IsOverride(),
+	}
+	if flag.IsOverride() {
+		updatedAt := flag.UpdatedAt
+		marshal.UpdatedAt = &updatedAt
+		if admin := featureFlagAdmin(flag); admin != "" {
+			marshal.UpdatedBy = &admin
+		}
+	}
+	return marshal
+}
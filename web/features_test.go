@@ -0,0 +1,145 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/gorilla/mux"
+)
+
+func TestFeatureHandler(t *testing.T) {
+	InitTestHttpHandler(t)
+
+	router := mux.NewRouter()
+	api := NewRouteGroup(router.PathPrefix("/api").Subrouter(), OAuthHandlerPermissive(), FeatureHandler("example-feature"))
+	api.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+	page := NewRouteGroup(router.PathPrefix("/oauth").Subrouter(), FeatureHandler("stored-feature"))
+	page.HandleFunc("/new", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+
+	get := func(path, token, cookie string) int {
+		request, _ := http.NewRequest("GET", path, strings.NewReader(""))
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		if cookie != "" {
+			request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(cookie)})
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	// flag on for alice
+	if code := get("/api/new", accessTokenAlice, ""); code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, code)
+	}
+	// anonymous request
+	if code := get("/api/new", "", ""); code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, code)
+	}
+
+	// flag on for bob
+	if code := get("/oauth/new", "", sessionCookieBob); code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, code)
+	}
+	if code := get("/oauth/new", "", ""); code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, code)
+	}
+}
+
+func TestListFeatureFlags(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("GET", "/api/features", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	request, _ = http.NewRequest("GET", "/api/features", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	flags := []featureFlagJSON{}
+	json.Unmarshal(response.Body.Bytes(), &flags)
+	if len(flags) != 3 || flags[0].Override || flags[1].Override || !flags[2].Override ||
+		flags[2].UpdatedBy == nil || *flags[2].UpdatedBy != "bob" {
+		t.Errorf("Unexpected feature flags: %s", response.Body.String())
+	}
+}
+
+func TestUpdateFeatureFlag(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	put := func(name, body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("PUT", "/api/features/"+name, strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// invalid flag
+	response := put("example-feature", `{"percentage": 200}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+	response = put("example-feature", `{"accounts": ["doesnotexist"]}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	response = put("new-hashing", `{"percentage": 5, "accounts": ["john"]}`)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	flag, ok := data.GetFeatureFlag("new-hashing")
+	if !ok || flag.Percentage != 5 || !flag.Accounts.Contains("john") || !flag.IsOverride() {
+		t.Errorf("Unexpected feature flag: %+v", flag)
+	}
+}
+
+func TestDeleteFeatureFlag(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	del := func(name string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("DELETE", "/api/features/"+name, strings.NewReader(""))
+		request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// configured flag
+	response := del("example-feature")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	response = del("stored-feature")
+	if response.Code != http.StatusNoContent {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNoContent, response.Code)
+	}
+	if _, ok := data.GetFeatureFlag("stored-feature"); ok {
+		t.Error("Feature flag 'stored-feature' should not exist")
+	}
+}
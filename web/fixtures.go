@@ -0,0 +1,36 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+// ResetFixtures is a handler which resets the database to the test fixtures and returns
+// the seeded accounts, clients and access tokens as JSON. It is only registered in test mode
+// and refuses to work unless the configured database is a test database.
+func ResetFixtures(w http.ResponseWriter, r *http.Request) {
+	if !data.IsTestDb() {
+		PrintErrorJSON(w, r, data.ErrNoTestDb.Error(), http.StatusForbidden)
+		return
+	}
+
+	creds, err := data.ResetFixtures()
+	if err != nil {
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(creds)
+}
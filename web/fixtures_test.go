@@ -0,0 +1,65 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestResetFixtures(t *testing.T) {
+	// not in test mode
+	handler := InitTestHttpHandler(t)
+	request, _ := http.NewRequest("POST", "/api/test/reset", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	conf.GetServerConfig().TestMode = true
+	defer func() { conf.GetServerConfig().TestMode = false }()
+	handler = InitTestHttpHandler(t)
+
+	bob, _ := data.GetAccountByLogin("bob")
+	err := bob.UpdatePassword("changedpassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request, _ = http.NewRequest("POST", "/api/test/reset", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	creds := &data.FixtureCredentials{}
+	err = json.NewDecoder(response.Body).Decode(creds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.Password != data.FixturePassword || len(creds.Accounts) == 0 || len(creds.Clients) != 2 {
+		t.Errorf("Unexpected credentials: %+v", creds)
+	}
+	if len(creds.AccessTokens) == 0 || len(creds.AccessTokens[0].Scopes) == 0 {
+		t.Errorf("Access tokens with scope expected: %+v", creds.AccessTokens)
+	}
+
+	bob, _ = data.GetAccountByLogin("bob")
+	if !bob.VerifyPassword(data.FixturePassword) {
+		t.Error("Password of bob expected to be reset")
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+// grantRequestStats is the JSON representation of the authorization flow statistics of a client.
+type grantRequestStats struct {
+	ClientID  string `json:"client_id"`
+	Started   int64  `json:"started"`
+	Completed int64  `json:"completed"`
+	Abandoned int64  `json:"abandoned"`
+	Pending   int64  `json:"pending"`
+}
+
+// ListGrantRequestStats is a handler which returns the number of started, completed, abandoned
+// and pending authorization flows of all clients as JSON. The optional query parameter 'days'
+// limits the period (default 30 days).
+func ListGrantRequestStats(w http.ResponseWriter, r *http.Request) {
+	since, ok := usageSince(w, r)
+	if !ok {
+		return
+	}
+
+	stats := data.ListGrantRequestStats(since)
+	marshal := make([]grantRequestStats, 0, len(stats))
+	for _, s := range stats {
+		marshal = append(marshal, grantRequestStats{
+			ClientID:  s.ClientName,
+			Started:   s.Started,
+			Completed: s.Completed,
+			Abandoned: s.Abandoned,
+			Pending:   s.Pending,
+		})
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
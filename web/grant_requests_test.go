@@ -0,0 +1,57 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListGrantRequestStats(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no admin scope
+	request, _ := http.NewRequest("GET", "/api/grant_requests/stats", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid days
+	request, _ = http.NewRequest("GET", "/api/grant_requests/stats?days=abc", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/grant_requests/stats?days=7", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	stats := make([]grantRequestStats, 0)
+	err := json.NewDecoder(response.Body).Decode(&stats)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(stats) != 2 || stats[1].ClientID != "wb" || stats[1].Started != 8 || stats[1].Abandoned != 6 {
+		t.Errorf("Unexpected grant request stats: %v", stats)
+	}
+}
@@ -0,0 +1,518 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/gorilla/mux"
+)
+
+// CreateGroup is a handler which creates a new group owned by the account of the
+// access token and returns the group as JSON.
+func CreateGroup(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	body := &struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	group := &data.Group{Name: body.Name, Description: body.Description}
+	err = group.Create(oauth.Token.AccountUUID.String)
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	printGroupJSON(w, group, http.StatusCreated)
+}
+
+// GetGroup is a handler which returns a group with its members and teams as JSON.
+// Groups are visible to their members, the owners of parent groups and administrators.
+func GetGroup(w http.ResponseWriter, r *http.Request) {
+	group, ok := groupAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	printGroupJSON(w, group, http.StatusOK)
+}
+
+// ListGroupMembers is a handler which returns all members of a group as JSON.
+func ListGroupMembers(w http.ResponseWriter, r *http.Request) {
+	group, ok := groupAccess(w, r, false)
+	if !ok {
+		return
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(group.Members())
+}
+
+// UpdateGroupMember is a handler which changes the role of a member or invites an account to
+// a group. Only owners of the group or its parent groups and administrators may change members.
+// Invited accounts are notified and become members once they accept the invitation.
+func UpdateGroupMember(w http.ResponseWriter, r *http.Request) {
+	group, ok := groupAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	body := &struct {
+		Role string `json:"role"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	oauth, _ := OAuthToken(r)
+	var result interface{}
+	code := http.StatusOK
+	if _, isMember := group.Member(account.UUID); isMember {
+		err = group.SetMember(account.UUID, body.Role)
+		result, _ = group.Member(account.UUID)
+	} else {
+		result, err = inviteGroupMember(group, account, body.Role, oauth.Token.AccountUUID.String)
+		code = http.StatusAccepted
+	}
+	if err != nil {
+		PrintErrorJSON(w, r, err, errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.Encode(result)
+}
+
+// RemoveGroupMember is a handler which removes an account from a group or revokes its invitation.
+func RemoveGroupMember(w http.ResponseWriter, r *http.Request) {
+	group, ok := groupAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	err := removeGroupMember(group, account)
+	if err != nil {
+		PrintErrorJSON(w, r, err, errorStatus(err, http.StatusBadRequest))
+		return
+	}
+}
+
+// AcceptGroupInvitation is a handler with which the account of the access token accepts its
+// invitation to a group. The group is returned as JSON.
+func AcceptGroupInvitation(w http.ResponseWriter, r *http.Request) {
+	group, accountUUID, ok := groupInvitationAccess(w, r)
+	if !ok {
+		return
+	}
+
+	err := group.AcceptInvitation(accountUUID)
+	if err != nil {
+		PrintErrorJSON(w, r, err, errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	printGroupJSON(w, group, http.StatusOK)
+}
+
+// DeclineGroupInvitation is a handler with which the account of the access token declines its
+// invitation to a group.
+func DeclineGroupInvitation(w http.ResponseWriter, r *http.Request) {
+	group, accountUUID, ok := groupInvitationAccess(w, r)
+	if !ok {
+		return
+	}
+
+	err := group.RemoveInvitation(accountUUID)
+	if err != nil {
+		PrintErrorJSON(w, r, err, errorStatus(err, http.StatusBadRequest))
+		return
+	}
+}
+
+// CreateGroupTeam is a handler which creates a sub-team of a group. The account of the
+// access token becomes the owner of the new team.
+func CreateGroupTeam(w http.ResponseWriter, r *http.Request) {
+	group, ok := groupAccess(w, r, true)
+	if !ok {
+		return
+	}
+
+	body := &struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	oauth, _ := OAuthToken(r)
+	team := &data.Group{
+		Name:        body.Name,
+		Description: body.Description,
+		ParentUUID:  sql.NullString{String: group.UUID, Valid: true},
+	}
+	err = team.Create(oauth.Token.AccountUUID.String)
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	printGroupJSON(w, team, http.StatusCreated)
+}
+
+// GroupPage shows members and teams of a group to an owner logged in via session cookie
+// and provides forms to invite and remove members and to create teams.
+func GroupPage(w http.ResponseWriter, r *http.Request) {
+	group, account, session, ok := groupOwnerSession(w, r)
+	if !ok {
+		return
+	}
+
+	pageData := struct {
+		Group       *data.Group
+		Members     []data.GroupMember
+		Invitations []data.GroupInvitation
+		Teams       []data.Group
+		CSRFToken   string
+		Time        *util.TimeFormat
+	}{group, group.Members(), group.Invitations(), group.Teams(), sessionCSRFToken(session), account.TimeFormat()}
+
+	tmpl := conf.MakeTemplate("group.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GroupAction changes members or creates a team as submitted from the group page.
+func GroupAction(w http.ResponseWriter, r *http.Request) {
+	group, owner, session, ok := groupOwnerSession(w, r)
+	if !ok {
+		return
+	}
+
+	param := &struct {
+		Action      string
+		Login       string
+		Role        string
+		Name        string
+		Description string
+		CSRFToken   string
+	}{}
+	err := util.ReadFormIntoStruct(r, param, true)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+	expected := sessionCSRFToken(session)
+	if !util.EqualTokens(param.CSRFToken, expected) {
+		PrintErrorHTML(w, r, "Invalid form token", http.StatusForbidden)
+		return
+	}
+
+	redirect := conf.MakePath("/oauth/groups/" + group.Name)
+	switch param.Action {
+	case "set", "remove":
+		account, ok := data.GetAccountByLogin(param.Login)
+		if !ok {
+			PrintErrorHTML(w, r, "The requested account does not exist", http.StatusNotFound)
+			return
+		}
+		if param.Action == "remove" {
+			err = removeGroupMember(group, account)
+		} else if _, isMember := group.Member(account.UUID); isMember {
+			err = group.SetMember(account.UUID, param.Role)
+		} else {
+			_, err = inviteGroupMember(group, account, param.Role, owner.UUID)
+		}
+	case "team":
+		team := &data.Group{
+			Name:        param.Name,
+			Description: param.Description,
+			ParentUUID:  sql.NullString{String: group.UUID, Valid: true},
+		}
+		err = team.Create(owner.UUID)
+		redirect = conf.MakePath("/oauth/groups/" + team.Name)
+	default:
+		PrintErrorHTML(w, r, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		PrintErrorHTML(w, r, err, errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	http.Redirect(w, r, redirect, http.StatusFound)
+}
+
+// GroupInvitationPage shows the pending invitation of the account logged in via session cookie
+// and provides forms to accept or decline it.
+func GroupInvitationPage(w http.ResponseWriter, r *http.Request) {
+	session, account, ok := accountSession(w, r)
+	if !ok {
+		return
+	}
+	group, invitation, ok := sessionInvitation(w, r, account)
+	if !ok {
+		return
+	}
+
+	pageData := struct {
+		Group      *data.Group
+		Invitation *data.GroupInvitation
+		CSRFToken  string
+		Time       *util.TimeFormat
+	}{group, invitation, sessionCSRFToken(session), account.TimeFormat()}
+
+	tmpl := conf.MakeTemplate("groupinvitation.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// GroupInvitationAction accepts or declines an invitation as submitted from the invitation page.
+func GroupInvitationAction(w http.ResponseWriter, r *http.Request) {
+	session, account, ok := accountSession(w, r)
+	if !ok {
+		return
+	}
+	group, _, ok := sessionInvitation(w, r, account)
+	if !ok {
+		return
+	}
+
+	param := &struct {
+		Action    string
+		CSRFToken string
+	}{}
+	err := util.ReadFormIntoStruct(r, param, true)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if !util.EqualTokens(param.CSRFToken, sessionCSRFToken(session)) {
+		PrintErrorHTML(w, r, "Invalid form token", http.StatusForbidden)
+		return
+	}
+
+	var head string
+	switch param.Action {
+	case "accept":
+		err = group.AcceptInvitation(account.UUID)
+		head = fmt.Sprintf("You joined the group %s", group.Name)
+	case "decline":
+		err = group.RemoveInvitation(account.UUID)
+		head = fmt.Sprintf("You declined the invitation to the group %s", group.Name)
+	default:
+		PrintErrorHTML(w, r, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		PrintErrorHTML(w, r, err, errorStatus(err, http.StatusBadRequest))
+		return
+	}
+
+	info := struct {
+		Header  string
+		Message string
+	}{head, ""}
+
+	tmpl := conf.MakeTemplate("success.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err = tmpl.ExecuteTemplate(w, "layout", info)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// groupAccess returns the group given by the name in the request URL if the account of the
+// token may access it: members may read a group, owners of the group or its parents may also
+// change it. Tokens with scope 'account-admin' may access all groups, with 'admin-read' or
+// 'admin-account-write' they may read or change all groups respectively. Tokens restricted to
+// certain groups may only access these groups and their teams.
+// Otherwise an error is written to the response.
+func groupAccess(w http.ResponseWriter, r *http.Request, write bool) (*data.Group, bool) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	group, ok := data.GetGroup(mux.Vars(r)["name"])
+	if !ok {
+		PrintErrorJSON(w, r, "The requested group does not exist", http.StatusNotFound)
+		return nil, false
+	}
+	if !group.AllowsToken(oauth.Token) {
+		PrintErrorJSON(w, r, "The token is restricted to other groups", http.StatusForbidden)
+		return nil, false
+	}
+	adminScope := data.ScopeAdminRead
+	if write {
+		adminScope = data.ScopeAdminAccountWrite
+	}
+	if data.HasAdminScope(oauth.Match, adminScope) {
+		return group, true
+	}
+
+	accountUUID := oauth.Token.AccountUUID.String
+	allowed := group.IsOwner(accountUUID)
+	if !allowed && !write {
+		_, allowed = group.Member(accountUUID)
+	}
+	if !allowed {
+		PrintErrorJSON(w, r, "Access to requested group forbidden", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return group, true
+}
+
+// groupOwnerSession returns the group given by the name in the request URL together with the
+// account and session of the request if the account owns the group or is one of the configured
+// group managers. Otherwise an error page is written.
+func groupOwnerSession(w http.ResponseWriter, r *http.Request) (*data.Group, *data.Account, *data.Session, bool) {
+	session, account, ok := accountSession(w, r)
+	if !ok {
+		return nil, nil, nil, false
+	}
+
+	group, ok := data.GetGroup(mux.Vars(r)["name"])
+	if !ok {
+		PrintErrorHTML(w, r, "The requested group does not exist", http.StatusNotFound)
+		return nil, nil, nil, false
+	}
+	if !group.IsOwner(account.UUID) && !conf.GetGroups().IsManager(account.Login) {
+		PrintErrorHTML(w, r, "Only owners can manage this group", http.StatusForbidden)
+		return nil, nil, nil, false
+	}
+
+	return group, account, session, true
+}
+
+// groupInvitationAccess returns the group given by the name in the request URL together with the
+// account of the access token, which may accept or decline its invitation to the group.
+// Otherwise an error is written to the response.
+func groupInvitationAccess(w http.ResponseWriter, r *http.Request) (*data.Group, string, bool) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	group, ok := data.GetGroup(mux.Vars(r)["name"])
+	if !ok {
+		PrintErrorJSON(w, r, "The requested group does not exist", http.StatusNotFound)
+		return nil, "", false
+	}
+	if !group.AllowsToken(oauth.Token) {
+		PrintErrorJSON(w, r, "The token is restricted to other groups", http.StatusForbidden)
+		return nil, "", false
+	}
+
+	return group, oauth.Token.AccountUUID.String, true
+}
+
+// sessionInvitation returns the group given by the name in the request URL together with the
+// pending invitation of the account. Otherwise an error page is written.
+func sessionInvitation(w http.ResponseWriter, r *http.Request, account *data.Account) (*data.Group, *data.GroupInvitation, bool) {
+	group, ok := data.GetGroup(mux.Vars(r)["name"])
+	if !ok {
+		PrintErrorHTML(w, r, "The requested group does not exist", http.StatusNotFound)
+		return nil, nil, false
+	}
+	invitation, ok := group.Invitation(account.UUID)
+	if !ok {
+		PrintErrorHTML(w, r, "You have no pending invitation to this group", http.StatusNotFound)
+		return nil, nil, false
+	}
+
+	return group, invitation, true
+}
+
+// inviteGroupMember invites an account to a group and notifies the account about the invitation.
+func inviteGroupMember(group *data.Group, account *data.Account, role, invitedBy string) (*data.GroupInvitation, error) {
+	invitation, err := group.Invite(account.UUID, role, invitedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	by := "An administrator"
+	if inviter, ok := data.GetAccount(invitedBy); ok {
+		by = inviter.Login
+	}
+	subject := fmt.Sprintf("You were invited to the group %s", group.Name)
+	body := fmt.Sprintf("%s invited you to join the GIN group %s as %s. Please accept or decline the invitation:\n\n%s",
+		by, group.Name, role, conf.MakeUrl("/oauth/groups/%s/invitation", group.Name))
+	return invitation, account.Notify(subject, body)
+}
+
+// removeGroupMember removes an account from a group or revokes its pending invitation.
+func removeGroupMember(group *data.Group, account *data.Account) error {
+	if _, isMember := group.Member(account.UUID); isMember {
+		return group.RemoveMember(account.UUID)
+	}
+	return group.RemoveInvitation(account.UUID)
+}
+
+// printGroupJSON writes a group together with its parent, members and teams as JSON.
+func printGroupJSON(w http.ResponseWriter, group *data.Group, code int) {
+	marshal := &data.GroupMarshaler{Group: group, Members: group.Members(), Teams: group.Teams()}
+	if parent, ok := group.Parent(); ok {
+		marshal.Parent = parent
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(code)
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
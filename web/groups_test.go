@@ -0,0 +1,250 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestGetGroup(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// owner of the parent group
+	request, _ := http.NewRequest("GET", "/api/groups/lmu-neuro-ephys/members", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	// unknown group
+	request, _ = http.NewRequest("GET", "/api/groups/doesnotexist", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/groups/lmu-neuro", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	group := &struct {
+		Name    string `json:"name"`
+		Members []struct {
+			Login string `json:"login"`
+			Role  string `json:"role"`
+		} `json:"members"`
+		Teams []string `json:"teams"`
+	}{}
+	err := json.NewDecoder(response.Body).Decode(group)
+	if err != nil {
+		t.Error(err)
+	}
+	if group.Name != "lmu-neuro" || len(group.Members) != 2 || len(group.Teams) != 1 {
+		t.Errorf("Unexpected group: %+v", group)
+	}
+}
+
+func TestUpdateGroupMember(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// invalid role
+	request, _ := http.NewRequest("PUT", "/api/groups/lmu-neuro/members/john", strings.NewReader(`{"role": "admin"}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// invitation of an account which is not a member
+	request, _ = http.NewRequest("PUT", "/api/groups/lmu-neuro/members/john", strings.NewReader(`{"role": "member"}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusAccepted {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusAccepted, response.Code)
+	}
+
+	john, _ := data.GetAccountByLogin("john")
+	if len(data.ListNotifications(john.UUID)) != 1 {
+		t.Error("Invitation notification for john expected")
+	}
+	group, _ := data.GetGroup("lmu-neuro")
+	if _, ok := group.Member(john.UUID); ok {
+		t.Error("John should not be a member before accepting the invitation")
+	}
+	if _, ok := group.Invitation(john.UUID); !ok {
+		t.Error("Invitation for john expected")
+	}
+
+	// role of a member
+	request, _ = http.NewRequest("PUT", "/api/groups/lmu-neuro/members/bob", strings.NewReader(`{"role": "owner"}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	bob, _ := data.GetAccountByLogin("bob")
+	if member, ok := group.Member(bob.UUID); !ok || member.Role != data.GroupRoleOwner {
+		t.Error("Bob expected to be an owner")
+	}
+}
+
+func TestAcceptGroupInvitation(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no invitation
+	request, _ := http.NewRequest("POST", "/api/groups/lmu-neuro/invitation", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("POST", "/api/groups/lmu-neuro-ephys/invitation", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	alice, _ := data.GetAccountByLogin("alice")
+	team, _ := data.GetGroup("lmu-neuro-ephys")
+	if _, ok := team.Member(alice.UUID); !ok {
+		t.Error("Alice expected to be a member")
+	}
+}
+
+func TestGroupInvitationPage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no invitation
+	request, _ := http.NewRequest("GET", "/oauth/groups/lmu-neuro-ephys/invitation", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/oauth/groups/lmu-neuro-ephys/invitation", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken("DNM5RS3C")})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `value="decline"`) {
+		t.Error("Invitation page expected to allow declining")
+	}
+}
+
+func TestRemoveGroupMember(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// last owner
+	request, _ := http.NewRequest("DELETE", "/api/groups/lmu-neuro/members/alice", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusConflict {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusConflict, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("DELETE", "/api/groups/lmu-neuro/members/bob", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	group, _ := data.GetGroup("lmu-neuro")
+	if len(group.Members()) != 1 {
+		t.Error("Bob expected to be removed")
+	}
+}
+
+func TestCreateGroupTeam(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("POST", "/api/groups/lmu-neuro/teams", strings.NewReader(`{"name": "lmu-neuro-imaging"}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusCreated {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusCreated, response.Code)
+	}
+
+	team, ok := data.GetGroup("lmu-neuro-imaging")
+	if !ok {
+		t.Fatal("Team expected to be created")
+	}
+	if parent, ok := team.Parent(); !ok || parent.Name != "lmu-neuro" {
+		t.Error("Team expected to be a sub-team of lmu-neuro")
+	}
+}
+
+func TestGroupPage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// not an owner
+	request, _ := http.NewRequest("GET", "/oauth/groups/lmu-neuro", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// group manager
+	conf.GetGroups().Managers = []string{"bob"}
+	defer func() { conf.GetGroups().Managers = nil }()
+	request, _ = http.NewRequest("GET", "/oauth/groups/lmu-neuro", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/oauth/groups/lmu-neuro", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken("DNM5RS3C")})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "lmu-neuro-ephys") {
+		t.Error("Group page expected to list the team")
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/gorilla/mux"
+)
+
+func init() {
+	conf.UseTestConfig()
+}
+
+// InitTestHttpHandler resets the test fixtures and returns a handler with all registered routes.
+// The tests of the package work on their own database schema, see data.InitTestDb.
+func InitTestHttpHandler(t *testing.T) http.Handler {
+	data.InitTestDb(t)
+	router := mux.NewRouter()
+	router.NotFoundHandler = &NotFoundHandler{}
+	RegisterRoutes(router)
+	return router
+}
+
+// InitTestServer serves all registered routes on a random port of the loopback interface, for
+// tests which need a real listener. The server has to be closed by the test.
+func InitTestServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(InitTestHttpHandler(t))
+}
+
+func TestInitTestServer(t *testing.T) {
+	server := InitTestServer(t)
+	defer server.Close()
+
+	// stop at redirects leaving the server
+	client := &http.Client{CheckRedirect: func(r *http.Request, via []*http.Request) error {
+		if !strings.HasPrefix(r.URL.String(), server.URL) {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}}
+
+	query := url.Values{}
+	query.Add("response_type", "code")
+	query.Add("client_id", "gin")
+	query.Add("redirect_uri", "https://localhost:8081/login")
+	query.Add("scope", "repo-read repo-write")
+	query.Add("state", "testcode")
+	query.Add("return_to", "https://localhost:8081/alice/repo")
+	response, err := client.Get(server.URL + "/oauth/authorize?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || response.Request.URL.Path != "/oauth/login_page" {
+		t.Fatalf("Login page expected but was '%d' at '%s'", response.StatusCode, response.Request.URL)
+	}
+
+	body := url.Values{}
+	body.Add("request_id", response.Request.URL.Query().Get("request_id"))
+	body.Add("login", "alice")
+	body.Add("password", data.FixturePassword)
+	response, err = client.PostForm(server.URL+"/oauth/login", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusFound {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusFound, response.StatusCode)
+	}
+
+	redirect, err := url.Parse(response.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redirect.Host != "localhost:8081" || redirect.Query().Get("code") == "" {
+		t.Errorf("Redirect to the client expected but was '%s'", redirect)
+	}
+	if redirect.Query().Get("return_to") != "https://localhost:8081/alice/repo" {
+		t.Errorf("Return URL expected in redirect '%s'", redirect)
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/G-Node/gin-core/gin"
+	"github.com/Sirupsen/logrus"
+)
+
+// The JSON login allows 10 requests per minute from one address and 5 failed logins
+// per 15 minutes for one account from one address. Failed logins are counted per
+// account and address, such that failures from elsewhere cannot lock out an account.
+var (
+	jsonLoginIPLimiter      = util.NewRateLimiter(10, time.Minute)
+	jsonLoginAccountLimiter = util.NewRateLimiter(5, 15*time.Minute)
+)
+
+// JSONLogin exchanges login and password of an account for an access and refresh token.
+// Only clients flagged as first-party clients (e.g. gin-cli) may use this handler.
+// Requests are rate limited per address and per account and all attempts are written
+// to the audit log.
+func JSONLogin(w http.ResponseWriter, r *http.Request) {
+	ip := remoteIP(r)
+	key := rateLimitKey(r)
+	if !jsonLoginIPLimiter.Allow(key) {
+		printTooManyRequests(w, r, jsonLoginIPLimiter.RetryAfter(key))
+		return
+	}
+
+	body := &struct {
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		Login        string `json:"login"`
+		Password     string `json:"password"`
+		Scope        string `json:"scope"`
+		Groups       string `json:"groups"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	// client credentials may also be sent via authorization header
+	if clientID, clientSecret, ok := r.BasicAuth(); ok {
+		body.ClientID = clientID
+		body.ClientSecret = clientSecret
+	}
+
+	audit := conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "json-login",
+		"client": body.ClientID,
+		"login":  body.Login,
+		"ip":     ip,
+		"device": util.ParseUserAgent(r.UserAgent()).String(),
+	})
+
+	client, ok := data.GetClientByName(body.ClientID)
+	if !ok || !client.AcceptsSecret(body.ClientSecret) {
+		audit.Warn("Wrong client id or client secret")
+		PrintErrorJSON(w, r, "Wrong client id or client secret", http.StatusUnauthorized)
+		return
+	}
+	if !client.FirstParty {
+		audit.Warn("Client is not a first-party client")
+		PrintErrorJSON(w, r, "The client is not allowed to use this login", http.StatusForbidden)
+		return
+	}
+
+	failureKey := jsonLoginFailureKey(body.Login, r)
+	if jsonLoginAccountLimiter.Blocked(failureKey) {
+		audit.Warn("Too many failed logins")
+		printTooManyRequests(w, r, jsonLoginAccountLimiter.RetryAfter(failureKey))
+		return
+	}
+
+	account, ok := data.GetAccountByLogin(body.Login)
+	valid := account.VerifyPassword(body.Password)
+	if !ok || !valid {
+		jsonLoginAccountLimiter.Record(failureKey)
+		util.RecordEvent(util.AlertFailedLogin, body.Login)
+		util.RecordFailedLogin(body.Login, remoteIP(r))
+		audit.Warn("Wrong login or password")
+		PrintErrorJSON(w, r, "Wrong login or password", http.StatusUnauthorized)
+		return
+	}
+
+	if account.IsPasswordExpired() {
+		audit.Warn("Password expired")
+		PrintErrorJSON(w, r, "Password expired, please log in via browser to change it", http.StatusForbidden)
+		return
+	}
+	if account.PendingReverification().IsPending() {
+		audit.Warn("Re-verification pending")
+		PrintErrorJSON(w, r, "Account requires re-verification, please log in via browser", http.StatusForbidden)
+		return
+	}
+
+	scope := util.NewStringSet(strings.Fields(body.Scope)...)
+	if scope.Len() == 0 || !client.ScopeWhitelist.IsSuperset(scope) {
+		audit.Warn("Invalid scope")
+		PrintErrorJSON(w, r, "Invalid scope", http.StatusBadRequest)
+		return
+	}
+	if err := data.CheckScopeGrant(account.UUID, client.UUID, scope); err != nil {
+		audit.Warn("Elevated scope not granted")
+		PrintErrorJSON(w, r, err, http.StatusForbidden)
+		return
+	}
+
+	groups := util.NewStringSet(strings.Fields(body.Groups)...)
+	if err := data.ValidateGroupRestriction(account.UUID, groups); err != nil {
+		audit.Warn("Invalid group restriction")
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	bound, err := client.BindNetwork(ip)
+	if err != nil {
+		audit.Warn("Token binding failed")
+		PrintErrorJSON(w, r, err, http.StatusForbidden)
+		return
+	}
+
+	access := &data.AccessToken{
+		Token:            util.RandomToken(),
+		AccountUUID:      sql.NullString{String: account.UUID, Valid: true},
+		ClientUUID:       client.UUID,
+		Scope:            scope,
+		BoundNetwork:     bound,
+		GroupRestriction: groups,
+	}
+	err = access.Create()
+	if err != nil {
+		panic(err)
+	}
+	refresh := &data.RefreshToken{
+		Token:            util.RandomToken(),
+		AccountUUID:      account.UUID,
+		ClientUUID:       client.UUID,
+		Scope:            scope,
+		GroupRestriction: groups,
+	}
+	err = refresh.Create()
+	if err != nil {
+		panic(err)
+	}
+	err = account.RecordLogin()
+	if err != nil {
+		panic(err)
+	}
+
+	jsonLoginAccountLimiter.Reset(failureKey)
+	audit.WithField("scope", body.Scope).Info("Login successful")
+
+	response := &struct {
+		*gin.TokenResponse
+		Groups []groupClaim `json:"groups,omitempty"`
+	}{
+		TokenResponse: &gin.TokenResponse{
+			TokenType:    "Bearer",
+			Scope:        strings.Join(scope.Strings(), " "),
+			AccessToken:  access.Token,
+			RefreshToken: &refresh.Token,
+		},
+		Groups: groupClaims(access),
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(response)
+}
+
+// jsonLoginFailureKey returns the key under which failed logins for an account are
+// counted: the login together with the address of the requester.
+func jsonLoginFailureKey(login string, r *http.Request) string {
+	return login + " " + rateLimitKey(r)
+}
+
+// printTooManyRequests writes a rate limit error with a Retry-After header in seconds.
+func printTooManyRequests(w http.ResponseWriter, r *http.Request, retry time.Duration) {
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", int(math.Ceil(retry.Seconds()))))
+	PrintErrorJSON(w, r, "Too many requests, please try again later", http.StatusTooManyRequests)
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/G-Node/gin-core/gin"
+)
+
+func TestJSONLogin(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	jsonLoginIPLimiter = util.NewRateLimiter(10, time.Minute)
+	jsonLoginAccountLimiter = util.NewRateLimiter(5, 15*time.Minute)
+
+	mkRequest := func(client, login, password, scope string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"client_id": "%s", "client_secret": "secret", "login": "%s", "password": "%s", "scope": "%s"}`,
+			client, login, password, scope)
+		request, _ := http.NewRequest("POST", "/oauth/json_login", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// not a first-party client
+	response := mkRequest("gin", "alice", "testtest", "account-create")
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// wrong password
+	response = mkRequest("wb", "alice", "wrongpassword", "account-read")
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid scope
+	response = mkRequest("wb", "alice", "testtest", "repo-write")
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	response = mkRequest("wb", "alice", "testtest", "account-read repo-read")
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	tokens := &gin.TokenResponse{}
+	err := json.NewDecoder(response.Body).Decode(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := data.GetAccessToken(tokens.AccessToken); !ok {
+		t.Error("Access token expected to exist")
+	}
+	if tokens.RefreshToken == nil {
+		t.Fatal("Refresh token expected in response")
+	}
+	if _, ok := data.GetRefreshToken(*tokens.RefreshToken); !ok {
+		t.Error("Refresh token expected to exist")
+	}
+
+	// too many failed logins
+	for i := 0; i < 5; i++ {
+		mkRequest("wb", "bob", "wrongpassword", "account-read")
+	}
+	response = mkRequest("wb", "bob", "testtest", "account-read")
+	if response.Code != http.StatusTooManyRequests {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusTooManyRequests, response.Code)
+	}
+	if response.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After header expected")
+	}
+
+	// too many requests from one address
+	response = mkRequest("wb", "alice", "testtest", "account-read")
+	if response.Code != http.StatusTooManyRequests {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusTooManyRequests, response.Code)
+	}
+
+	// failed logins from one address do not block the account elsewhere
+	mkRemoteRequest := func(addr, password string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"client_id": "wb", "client_secret": "secret", "login": "bob", "password": "%s", "scope": "account-read"}`,
+			password)
+		request, _ := http.NewRequest("POST", "/oauth/json_login", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.RemoteAddr = addr
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+	response = mkRemoteRequest("192.0.2.7:4321", "testtest")
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	// limits are not relaxed for internal networks
+	_, internal, _ := net.ParseCIDR("10.0.0.0/8")
+	conf.GetServerConfig().InternalNetworks = []*net.IPNet{internal}
+	defer func() { conf.GetServerConfig().InternalNetworks = nil }()
+	for i := 0; i < 5; i++ {
+		mkRemoteRequest("10.0.0.5:4321", "wrongpassword")
+	}
+	response = mkRemoteRequest("10.0.0.5:4321", "testtest")
+	if response.Code != http.StatusTooManyRequests {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusTooManyRequests, response.Code)
+	}
+}
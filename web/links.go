@@ -0,0 +1,58 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"sync"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/gorilla/mux"
+)
+
+// Routes of the current API version by their unversioned path template, e.g. "/api/accounts/{account}".
+// Links in API responses are generated from these routes, such that they follow the registered paths.
+var apiRoutes = struct {
+	sync.RWMutex
+	routes map[string]*mux.Route
+}{routes: make(map[string]*mux.Route)}
+
+// registerAPILinks collects the routes of the router of the current API version.
+func registerAPILinks(router *mux.Router) {
+	routes := make(map[string]*mux.Route)
+	router.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		if tmpl, err := route.GetPathTemplate(); err == nil {
+			routes[unversionedPath(tmpl)] = route
+		}
+		return nil
+	})
+
+	apiRoutes.Lock()
+	apiRoutes.routes = routes
+	apiRoutes.Unlock()
+}
+
+// apiLink returns the absolute URL of the route of the current API version with the given unversioned
+// path template. The pairs contain the names and values of the path variables. An empty string is
+// returned and the error is logged if the route does not exist or the variables don't match.
+func apiLink(tmpl string, pairs ...string) string {
+	apiRoutes.RLock()
+	route, ok := apiRoutes.routes[tmpl]
+	apiRoutes.RUnlock()
+	if !ok {
+		conf.GetLogEnv().Err.Errorf("No API route for link '%s'\n", tmpl)
+		return ""
+	}
+
+	u, err := route.URL(pairs...)
+	if err != nil {
+		conf.GetLogEnv().Err.Errorf("Error creating link for API route '%s': %s\n", tmpl, err.Error())
+		return ""
+	}
+	return conf.GetServerConfig().BaseURL + u.String()
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// localeSettings is the JSON representation of the timezone and locale preferences of an account.
+type localeSettings struct {
+	URL      string `json:"url"`
+	Timezone string `json:"timezone"`
+	Locale   string `json:"locale"`
+}
+
+// GetLocaleSettings is a handler which returns the timezone and locale of an account as JSON.
+func GetLocaleSettings(w http.ResponseWriter, r *http.Request) {
+	account, ok := ownAccount(w, r, "account-read")
+	if !ok {
+		return
+	}
+
+	writeLocaleSettings(w, account)
+}
+
+// UpdateLocaleSettings is a handler which sets the timezone and locale used to show timestamps
+// on pages and in e-mails to the account owner.
+func UpdateLocaleSettings(w http.ResponseWriter, r *http.Request) {
+	account, ok := ownAccount(w, r, "account-write")
+	if !ok {
+		return
+	}
+
+	body := &struct {
+		Timezone string `json:"timezone"`
+		Locale   string `json:"locale"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Error while processing locale settings", http.StatusBadRequest)
+		return
+	}
+
+	err = account.UpdateLocale(body.Timezone, body.Locale)
+	if err != nil {
+		if _, ok := err.(*util.ValidationError); ok {
+			PrintErrorJSON(w, r, err, http.StatusBadRequest)
+			return
+		}
+		panic(err)
+	}
+
+	writeLocaleSettings(w, account)
+}
+
+func writeLocaleSettings(w http.ResponseWriter, account *data.Account) {
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(&localeSettings{
+		URL:      conf.MakeUrl("/api/v1/accounts/%s/locale_settings", account.Login),
+		Timezone: account.Timezone,
+		Locale:   account.Locale,
+	})
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLocaleSettings(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	do := func(method, body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(method, "/api/accounts/alice/locale_settings", strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// defaults
+	response := do("GET", "")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	settings := &localeSettings{}
+	json.NewDecoder(response.Body).Decode(settings)
+	if settings.Timezone != "UTC" || settings.Locale != "en" {
+		t.Errorf("Default timezone and locale expected: %+v", settings)
+	}
+
+	// invalid timezone
+	response = do("PUT", `{"timezone": "Mars/Olympus", "locale": "de"}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	response = do("PUT", `{"timezone": "Europe/Berlin", "locale": "de"}`)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	settings = &localeSettings{}
+	json.NewDecoder(response.Body).Decode(settings)
+	if settings.Timezone != "Europe/Berlin" || settings.Locale != "de" {
+		t.Errorf("Updated timezone and locale expected: %+v", settings)
+	}
+}
@@ -0,0 +1,294 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+)
+
+// featureMagicLinkLogin is the feature flag of the login via magic link. The flag is on in the
+// shipped configuration and allows to switch the login via magic link off.
+const featureMagicLinkLogin = "magic-link-login"
+
+// Magic links can be requested 10 times per minute from one address
+// and 3 times per 15 minutes for one e-mail address. Requests from internal
+// networks are not limited per address and have a raised limit per e-mail address.
+var (
+	magicLinkIPLimiter    = util.NewRateLimiter(10, time.Minute)
+	magicLinkEmailLimiter = util.NewRateLimiter(3, 15*time.Minute)
+)
+
+type magicLinkData struct {
+	Email      string
+	RequestID  string
+	ErrMessage string
+}
+
+// MagicLinkPage shows a page where the user can request a login link by e-mail.
+func MagicLinkPage(w http.ResponseWriter, r *http.Request) {
+	requestID := r.URL.Query().Get("request_id")
+	if _, ok := data.GetGrantRequest(requestID); !ok {
+		PrintErrorHTML(w, r, "Grant request does not exist", http.StatusNotFound)
+		return
+	}
+
+	printMagicLinkPage(w, &magicLinkData{RequestID: requestID})
+}
+
+// MagicLinkInit sends a single-use login link to the e-mail address of an account which
+// enabled the login via magic link. In order to not reveal which addresses belong to
+// accounts, the response is the same whether a link was sent or not.
+func MagicLinkInit(w http.ResponseWriter, r *http.Request) {
+	ip := remoteIP(r)
+	key := rateLimitKey(r)
+	if !isInternalRequest(r) && !magicLinkIPLimiter.Allow(key) {
+		printTooManyRequests(w, r, magicLinkIPLimiter.RetryAfter(key))
+		return
+	}
+
+	param := &magicLinkData{}
+	err := util.ReadFormIntoStruct(r, param, true)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	request, ok := data.GetGrantRequest(param.RequestID)
+	if !ok {
+		PrintErrorHTML(w, r, "Grant request does not exist", http.StatusNotFound)
+		return
+	}
+
+	if param.Email == "" {
+		param.ErrMessage = "Please enter your e-mail address"
+		w.Header().Add("Warning", param.ErrMessage)
+		printMagicLinkPage(w, param)
+		return
+	}
+
+	audit := conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "magic-link",
+		"email":  param.Email,
+		"ip":     ip,
+		"device": util.ParseUserAgent(r.UserAgent()).String(),
+	})
+
+	factor := rateLimitFactor(r)
+	if !magicLinkEmailLimiter.AllowScaled(param.Email, factor) {
+		audit.Warn("Too many magic link requests")
+		printTooManyRequests(w, r, magicLinkEmailLimiter.RetryAfterScaled(param.Email, factor))
+		return
+	}
+
+	account, ok := data.GetAccountByCredential(param.Email)
+	if ok && account.Email == param.Email && account.IsMagicLinkEnabled {
+		link, err := data.CreateMagicLink(account.UUID, request.Token)
+		if err != nil {
+			panic(err)
+		}
+
+		tmplFields := &struct {
+			From    string
+			To      string
+			Subject string
+			BaseUrl string
+			Token   string
+			Device  string
+		}{conf.GetSmtpCredentials().From, account.Email, "Your GIN login link", conf.GetServerConfig().BaseURL, link.Token,
+			util.ParseUserAgent(r.UserAgent()).String()}
+
+		content := util.MakeEmailTemplate("emailmagiclink.txt", tmplFields)
+		email := &data.Email{}
+		err = email.Create(util.NewStringSet(account.Email), content.Bytes())
+		if err != nil {
+			PrintErrorHTML(w, r, "An error occurred trying to send the login e-mail. Please try again later.",
+				http.StatusInternalServerError)
+			return
+		}
+		audit.Info("Magic link sent")
+	} else {
+		audit.Warn("No account with enabled magic link login")
+	}
+
+	info := struct {
+		Header  string
+		Message template.HTML
+	}{"Check your e-mail", template.HTML("If the address belongs to an account which allows the login by e-mail, " +
+		"a login link has been sent to it. The link can be used once within 15 minutes.")}
+
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	tmpl := conf.MakeTemplate("success.html")
+	err = tmpl.ExecuteTemplate(w, "layout", info)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Name of the cookie which binds the confirmation form of a magic link to the browser
+const magicLoginCookie = "magic_login_csrf"
+
+// magicLoginCSRFCookie creates the cookie holding the CSRF token of the magic login form, an empty
+// token removes the cookie.
+func magicLoginCSRFCookie(r *http.Request, token string, expires time.Time) *http.Cookie {
+	return &http.Cookie{
+		Name:     magicLoginCookie,
+		Value:    token,
+		Path:     conf.MakePath("/oauth/magic_login"),
+		Expires:  expires,
+		Secure:   conf.GetServerConfig().CookieSecure || isSecureRequest(r),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	}
+}
+
+// MagicLoginPage shows the confirmation form for a magic link. The link is only used when the form
+// is submitted, thus links opened by e-mail scanners or prefetched by browsers stay valid.
+func MagicLoginPage(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if _, ok := data.GetMagicLink(token); !ok {
+		PrintErrorHTML(w, r, "The login link is invalid or expired", http.StatusNotFound)
+		return
+	}
+
+	// double submit token, the form can only be posted from the browser which opened the link
+	csrf := util.RandomToken()
+	http.SetCookie(w, magicLoginCSRFCookie(r, csrf, time.Now().Add(15*time.Minute)))
+
+	pageData := struct {
+		Token     string
+		CSRFToken string
+	}{token, csrf}
+
+	tmpl := conf.MakeTemplate("magiclogin.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// MagicLogin logs the account of a posted magic link in and continues the associated grant request.
+// Each link can only be used once and the CSRF token of the form must match its cookie.
+func MagicLogin(w http.ResponseWriter, r *http.Request) {
+	audit := conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "magic-login",
+		"ip":     remoteIP(r),
+		"device": util.ParseUserAgent(r.UserAgent()).String(),
+	})
+
+	err := r.ParseForm()
+	if err != nil {
+		PrintErrorHTML(w, r, "Request was malformed", http.StatusBadRequest)
+		return
+	}
+	cookie, err := r.Cookie(magicLoginCookie)
+	if err != nil || cookie.Value == "" || !util.EqualTokens(cookie.Value, r.PostForm.Get("csrf_token")) {
+		audit.Warn("Invalid CSRF token")
+		PrintErrorHTML(w, r, "Invalid CSRF token, please open the login link again", http.StatusForbidden)
+		return
+	}
+	http.SetCookie(w, magicLoginCSRFCookie(r, "", time.Now().Add(-24*time.Hour)))
+
+	link, err := data.UseMagicLink(r.PostForm.Get("token"))
+	if err != nil {
+		util.RecordEvent(util.AlertFailedLogin, "magic-link")
+		util.RecordFailedLogin("", remoteIP(r))
+		audit.Warn(err.Error())
+		PrintErrorHTML(w, r, "The login link is invalid or expired", errorStatus(err, http.StatusNotFound))
+		return
+	}
+
+	account, ok := data.GetAccount(link.AccountUUID)
+	if !ok || !account.IsMagicLinkEnabled {
+		util.RecordEvent(util.AlertFailedLogin, link.AccountUUID)
+		audit.WithField("account", link.AccountUUID).Warn("Account is not active or magic link login is disabled")
+		PrintErrorHTML(w, r, "The login link is invalid or expired", http.StatusNotFound)
+		return
+	}
+
+	request, ok := data.GetGrantRequest(link.GrantRequest)
+	if !ok {
+		PrintErrorHTML(w, r, "Grant request does not exist", http.StatusNotFound)
+		return
+	}
+
+	if pending := account.PendingReverification(); pending.IsPending() {
+		audit.WithField("login", account.Login).Warn("Re-verification pending")
+		reverify(w, r, request, account, pending)
+		return
+	}
+
+	audit.WithField("login", account.Login).Info("Login successful")
+	startSession(w, r, request, account)
+	continueGrantRequest(w, r, request)
+}
+
+// GetLoginSettings is a handler which returns the login settings of an account as JSON.
+func GetLoginSettings(w http.ResponseWriter, r *http.Request) {
+	account, ok := ownAccount(w, r, "account-read")
+	if !ok {
+		return
+	}
+
+	writeLoginSettings(w, account)
+}
+
+// UpdateLoginSettings is a handler which enables or disables the login via magic link for an account.
+func UpdateLoginSettings(w http.ResponseWriter, r *http.Request) {
+	account, ok := ownAccount(w, r, "account-write")
+	if !ok {
+		return
+	}
+
+	body := &loginSettings{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Error while processing login settings", http.StatusBadRequest)
+		return
+	}
+
+	err = account.UpdateMagicLinkEnabled(body.MagicLink)
+	if err != nil {
+		panic(err)
+	}
+
+	writeLoginSettings(w, account)
+}
+
+// loginSettings is the JSON representation of the login settings of an account.
+type loginSettings struct {
+	MagicLink bool `json:"magic_link"`
+}
+
+func writeLoginSettings(w http.ResponseWriter, account *data.Account) {
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(&loginSettings{MagicLink: account.IsMagicLinkEnabled})
+}
+
+func printMagicLinkPage(w http.ResponseWriter, pageData *magicLinkData) {
+	tmpl := conf.MakeTemplate("magiclink.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
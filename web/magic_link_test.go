@@ -0,0 +1,219 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestMagicLinkInit(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	magicLinkIPLimiter = util.NewRateLimiter(10, time.Minute)
+	magicLinkEmailLimiter = util.NewRateLimiter(3, 15*time.Minute)
+
+	mkRequest := func(email, requestID string) *httptest.ResponseRecorder {
+		body := url.Values{"email": {email}, "request_id": {requestID}}
+		request, _ := http.NewRequest("POST", "/oauth/magic_link", strings.NewReader(body.Encode()))
+		request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		request.Host = "evil.example.org"
+		request.Header.Set("X-Forwarded-Host", "evil.example.org")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	emails, _ := data.GetQueuedEmails()
+	num := len(emails)
+
+	// unknown grant request
+	response := mkRequest("aclic@foo.com", "doesNotExist")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// account without magic link login
+	response = mkRequest("bob@foo.com", "QH92T99D")
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	emails, _ = data.GetQueuedEmails()
+	if len(emails) != num {
+		t.Error("No e-mail expected for an account without magic link login")
+	}
+
+	// all ok
+	response = mkRequest("aclic@foo.com", "QH92T99D")
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	emails, _ = data.GetQueuedEmails()
+	if len(emails) != num+1 || !strings.Contains(string(emails[len(emails)-1].Content), "/oauth/magic_login?token=") {
+		t.Fatal("E-mail with magic link expected")
+	}
+	content := string(emails[len(emails)-1].Content)
+	if strings.Contains(content, "evil.example.org") || !strings.Contains(content, conf.GetServerConfig().BaseURL) {
+		t.Error("Magic link expected to use the configured base URL")
+	}
+
+	// rate limit per e-mail address
+	mkRequest("aclic@foo.com", "QH92T99D")
+	mkRequest("aclic@foo.com", "QH92T99D")
+	response = mkRequest("aclic@foo.com", "QH92T99D")
+	if response.Code != http.StatusTooManyRequests {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusTooManyRequests, response.Code)
+	}
+}
+
+func TestMagicLogin(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	openLink := func(token string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", "/oauth/magic_login?token="+token, nil)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+	mkRequest := func(token, csrf string, cookie *http.Cookie) *httptest.ResponseRecorder {
+		body := url.Values{"token": {token}, "csrf_token": {csrf}}
+		request, _ := http.NewRequest("POST", "/oauth/magic_login", strings.NewReader(body.Encode()))
+		request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// expired link
+	response := openLink("EXP1R3DL")
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// opening the link shows a confirmation form and does not use the link
+	response = openLink("M4G1CL1K")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	var csrf *http.Cookie
+	for _, c := range response.Result().Cookies() {
+		if c.Name == magicLoginCookie {
+			csrf = c
+		}
+	}
+	if csrf == nil || !strings.Contains(response.Body.String(), csrf.Value) {
+		t.Fatal("CSRF cookie and token in the form expected")
+	}
+	if response = openLink("M4G1CL1K"); response.Code != http.StatusOK {
+		t.Error("Link expected to stay valid when opened repeatedly")
+	}
+
+	// missing or wrong CSRF token
+	response = mkRequest("M4G1CL1K", csrf.Value, nil)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+	response = mkRequest("M4G1CL1K", "wrong", csrf)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// all ok, the grant request is approved
+	response = mkRequest("M4G1CL1K", csrf.Value, csrf)
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+	session := false
+	for _, c := range response.Result().Cookies() {
+		session = session || (c.Name == conf.GetServerConfig().CookieName && c.Value != "")
+	}
+	if !session {
+		t.Error("Session cookie expected")
+	}
+	if !strings.HasPrefix(response.Header().Get("Location"), "https://localhost:8081/login") {
+		t.Errorf("Redirect to client expected but was '%s'", response.Header().Get("Location"))
+	}
+
+	// link was used
+	response = mkRequest("M4G1CL1K", csrf.Value, csrf)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+}
+
+func TestUpdateLoginSettings(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("PUT", "/api/accounts/alice/login_settings", strings.NewReader(`{"magic_link": false}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	settings := &loginSettings{}
+	json.NewDecoder(response.Body).Decode(settings)
+	if settings.MagicLink {
+		t.Error("Magic link login expected to be disabled")
+	}
+
+	// other account
+	request, _ = http.NewRequest("GET", "/api/accounts/bob/login_settings", nil)
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+}
+
+func TestMagicLinkFeature(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", path, strings.NewReader(""))
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	response := get("/oauth/login_page?request_id=U7JIKKYI")
+	if !strings.Contains(response.Body.String(), "/oauth/magic_link_page") {
+		t.Error("Link to the magic link page expected")
+	}
+	if response = get("/oauth/magic_link_page?request_id=U7JIKKYI"); response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	flag := &data.FeatureFlag{Name: featureMagicLinkLogin}
+	err := flag.SaveBy("bf431618-f696-4dca-a95d-882618ce4ef9")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response = get("/oauth/login_page?request_id=U7JIKKYI")
+	if strings.Contains(response.Body.String(), "/oauth/magic_link_page") {
+		t.Error("No link to the magic link page expected")
+	}
+	if response = get("/oauth/magic_link_page?request_id=U7JIKKYI"); response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+}
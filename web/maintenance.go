@@ -0,0 +1,129 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/Sirupsen/logrus"
+)
+
+// GET requests to these paths start a login or change data and are therefore
+// rejected while in maintenance mode.
+var maintenanceBlockedPaths = []string{
+	"/oauth/authorize",
+	"/oauth/login",
+	"/oauth/logout/",
+	"/oauth/registration_init",
+	"/oauth/activation",
+	"/oauth/verify_email",
+}
+
+// maintenanceData is the JSON representation of the maintenance state.
+type maintenanceData struct {
+	Enabled    bool   `json:"enabled"`
+	Message    string `json:"message"`
+	RetryAfter int    `json:"retry_after"` // in seconds
+}
+
+// MaintenanceHandler rejects writes and logins with StatusServiceUnavailable while gin-auth
+// is in maintenance mode. Read access and changes of the maintenance state remain possible.
+func MaintenanceHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := conf.GetMaintenance()
+		if m.Enabled && isMaintenanceBlocked(r) {
+			w.Header().Add("Retry-After", strconv.Itoa(int(m.RetryAfter/time.Second)))
+			if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/oauth/token" {
+				PrintErrorJSON(w, r, m.Message, http.StatusServiceUnavailable)
+			} else {
+				PrintErrorHTML(w, r, m.Message, http.StatusServiceUnavailable)
+			}
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// isMaintenanceBlocked checks whether a request is not allowed in maintenance mode.
+func isMaintenanceBlocked(r *http.Request) bool {
+	path := unversionedPath(r.URL.Path)
+	if path == "/api/maintenance" {
+		return false
+	}
+
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		for _, p := range maintenanceBlockedPaths {
+			if strings.HasPrefix(path, p) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// GetMaintenance returns the current maintenance state as JSON.
+func GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	m := conf.GetMaintenance()
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(&maintenanceData{m.Enabled, m.Message, int(m.RetryAfter / time.Second)})
+}
+
+// UpdateMaintenance enables or disables the maintenance mode. The request must be authorized
+// by an OAuthHandler with scope 'account-admin'. Message and retry interval fall back to the configured defaults if omitted.
+// The state is stored in the database, in read-only mode it only applies to this instance until the next restart.
+func UpdateMaintenance(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	body := &maintenanceData{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Invalid maintenance data", http.StatusBadRequest)
+		return
+	}
+
+	m := conf.Maintenance{
+		Enabled:    body.Enabled,
+		Message:    body.Message,
+		RetryAfter: time.Duration(body.RetryAfter) * time.Second,
+	}
+	stored := !conf.GetReadOnly().Enabled
+	if stored {
+		err = data.SaveMaintenance(m, oauth.Token.AccountUUID.String)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		conf.SetMaintenance(m)
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":   "maintenance-changed",
+		"enabled": body.Enabled,
+		"stored":  stored,
+		"ip":      remoteIP(r),
+	}).Warn("Maintenance mode changed")
+
+	GetMaintenance(w, r)
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestMaintenanceHandler(t *testing.T) {
+	defer conf.SetMaintenance(conf.Maintenance{Enabled: false})
+
+	var called bool
+	handler := MaintenanceHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	// maintenance mode disabled
+	conf.SetMaintenance(conf.Maintenance{Enabled: false})
+	called = false
+	request, _ := http.NewRequest("POST", "/api/accounts/alice/keys", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if !called {
+		t.Error("Request should be handled")
+	}
+
+	conf.SetMaintenance(conf.Maintenance{Enabled: true, RetryAfter: 5 * time.Minute})
+
+	// read access
+	called = false
+	request, _ = http.NewRequest("GET", "/api/accounts/alice", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if !called {
+		t.Error("Read access should be handled")
+	}
+
+	// write access
+	called = false
+	request, _ = http.NewRequest("POST", "/api/accounts/alice/keys", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if called || response.Code != http.StatusServiceUnavailable {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusServiceUnavailable, response.Code)
+	}
+	if response.Header().Get("Retry-After") != "300" {
+		t.Errorf("Retry-After expected to be '300' but was '%s'", response.Header().Get("Retry-After"))
+	}
+
+	// login
+	called = false
+	request, _ = http.NewRequest("GET", "/oauth/login?request_id=foo", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if called || response.Code != http.StatusServiceUnavailable {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusServiceUnavailable, response.Code)
+	}
+
+	// change of the maintenance state
+	called = false
+	request, _ = http.NewRequest("PUT", "/api/maintenance", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if !called {
+		t.Error("Change of the maintenance state should be handled")
+	}
+}
+
+func TestUpdateMaintenance(t *testing.T) {
+	defer conf.SetMaintenance(conf.Maintenance{Enabled: false})
+	handler := InitTestHttpHandler(t)
+
+	// insufficient scope
+	request, _ := http.NewRequest("PUT", "/api/maintenance", strings.NewReader(`{"enabled": true}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid body
+	request, _ = http.NewRequest("PUT", "/api/maintenance", strings.NewReader("{"))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("PUT", "/api/maintenance", strings.NewReader(`{"enabled": true, "message": "Migration"}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if m := conf.GetMaintenance(); !m.Enabled || m.Message != "Migration" {
+		t.Error("Maintenance mode expected to be enabled")
+	}
+
+	// stored state applies after a restart
+	conf.SetMaintenance(conf.Maintenance{Enabled: false})
+	err := data.LoadMaintenance()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m := conf.GetMaintenance(); !m.Enabled || m.Message != "Migration" {
+		t.Error("Stored maintenance mode expected to be enabled")
+	}
+
+	// banner on html pages
+	request, _ = http.NewRequest("GET", "/oauth/login_page", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if !strings.Contains(response.Body.String(), "Migration") {
+		t.Error("Page expected to contain the maintenance banner")
+	}
+
+	// get state
+	request, _ = http.NewRequest("GET", "/api/maintenance", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	result := &maintenanceData{}
+	json.Unmarshal(response.Body.Bytes(), result)
+	if !result.Enabled || result.RetryAfter <= 0 {
+		t.Error("Maintenance state expected to be enabled with retry interval")
+	}
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// Content type of the OpenMetrics text format
+const openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+// metricsBacklog caches the backlog exposed by Metrics, since counting the expired rows
+// of all tables is expensive.
+var metricsBacklog = struct {
+	lock    sync.Mutex
+	backlog *data.Backlog
+	updated time.Time
+}{}
+
+// Metrics is a handler which exposes the backlogs of the cleaner and the e-mail queue as
+// gauges in the OpenMetrics text format. If a metrics token is configured, it must be sent
+// as bearer token, otherwise only requests from internal networks are served.
+func Metrics(w http.ResponseWriter, r *http.Request) {
+	if token := conf.GetMetrics().Token; token != "" {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") || !util.EqualTokens(strings.TrimPrefix(auth, "Bearer "), token) {
+			PrintErrorJSON(w, r, "Invalid metrics token", http.StatusUnauthorized)
+			return
+		}
+	} else if !isInternalRequest(r) {
+		PrintErrorJSON(w, r, "Metrics are only available from internal networks", http.StatusForbidden)
+		return
+	}
+
+	backlog := cachedBacklog()
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", openMetricsContentType)
+	writeBacklogMetrics(w, backlog)
+}
+
+// cachedBacklog returns the backlog of the cleaner and the e-mail queue, which is counted
+// again once the configured cache time has passed.
+func cachedBacklog() *data.Backlog {
+	metricsBacklog.lock.Lock()
+	defer metricsBacklog.lock.Unlock()
+
+	now := util.Now()
+	if metricsBacklog.backlog == nil || now.Sub(metricsBacklog.updated) >= conf.GetMetrics().CacheTime {
+		metricsBacklog.backlog = data.GetBacklog()
+		metricsBacklog.updated = now
+	}
+	return metricsBacklog.backlog
+}
+
+// writeBacklogMetrics writes the gauges of a backlog in the OpenMetrics text format.
+func writeBacklogMetrics(w io.Writer, backlog *data.Backlog) {
+	tables := make([]string, 0, len(backlog.ExpiredRows))
+	for table := range backlog.ExpiredRows {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	writeMetricHeader(w, "gin_auth_cleaner_expired_rows", "Expired rows which are not yet removed by the cleaner.")
+	for _, table := range tables {
+		fmt.Fprintf(w, "gin_auth_cleaner_expired_rows{table=\"%s\"} %d\n", strings.ToLower(table), backlog.ExpiredRows[table])
+	}
+	writeMetricHeader(w, "gin_auth_email_queue_depth", "E-mails waiting in the queue.")
+	fmt.Fprintf(w, "gin_auth_email_queue_depth %d\n", backlog.EmailQueueDepth)
+	writeMetricHeader(w, "gin_auth_email_queue_oldest_age_seconds", "Age of the oldest e-mail in the queue, 0 if the queue is empty.")
+	fmt.Fprintf(w, "gin_auth_email_queue_oldest_age_seconds %.3f\n", backlog.EmailQueueAge.Seconds())
+	fmt.Fprintf(w, "# EOF\n")
+}
+
+// writeMetricHeader writes the type and help of a gauge.
+func writeMetricHeader(w io.Writer, name, help string) {
+	fmt.Fprintf(w, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+func TestWriteBacklogMetrics(t *testing.T) {
+	backlog := &data.Backlog{
+		ExpiredRows:     map[string]int64{"Sessions": 3, "AccessTokens": 7},
+		EmailQueueDepth: 2,
+		EmailQueueAge:   90 * time.Second,
+	}
+	buff := &bytes.Buffer{}
+	writeBacklogMetrics(buff, backlog)
+
+	expected := []string{
+		"# TYPE gin_auth_cleaner_expired_rows gauge",
+		`gin_auth_cleaner_expired_rows{table="accesstokens"} 7` + "\n" + `gin_auth_cleaner_expired_rows{table="sessions"} 3`,
+		"gin_auth_email_queue_depth 2",
+		"gin_auth_email_queue_oldest_age_seconds 90.000",
+	}
+	for _, e := range expected {
+		if !strings.Contains(buff.String(), e) {
+			t.Errorf("Metrics expected to contain '%s':\n%s", e, buff.String())
+		}
+	}
+	if !strings.HasSuffix(buff.String(), "# EOF\n") {
+		t.Error("Metrics expected to end with '# EOF'")
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	metricsBacklog.backlog = nil
+
+	// only internal networks without token
+	request, _ := http.NewRequest("GET", "/metrics", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	_, internal, _ := net.ParseCIDR("192.0.2.0/24")
+	conf.GetServerConfig().InternalNetworks = []*net.IPNet{internal}
+	defer func() { conf.GetServerConfig().InternalNetworks = nil }()
+
+	request, _ = http.NewRequest("GET", "/metrics", strings.NewReader(""))
+	request.RemoteAddr = "192.0.2.1:1234"
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("Content-Type") != openMetricsContentType {
+		t.Errorf("Unexpected content type '%s'", response.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(response.Body.String(), "gin_auth_email_queue_depth 2") {
+		t.Errorf("Unexpected metrics: %s", response.Body.String())
+	}
+
+	// counts are cached
+	email := &data.Email{}
+	err := email.Create(util.NewStringSet("someone@example.com"), []byte("Test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, _ = http.NewRequest("GET", "/metrics", strings.NewReader(""))
+	request.RemoteAddr = "192.0.2.1:1234"
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if !strings.Contains(response.Body.String(), "gin_auth_email_queue_depth 2") {
+		t.Errorf("Cached metrics expected: %s", response.Body.String())
+	}
+
+	// token required
+	conf.GetMetrics().Token = "scraper"
+	defer func() { conf.GetMetrics().Token = "" }()
+
+	request, _ = http.NewRequest("GET", "/metrics", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	request, _ = http.NewRequest("GET", "/metrics", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer scraper")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/G-Node/gin-auth/data"
+	"github.com/gorilla/mux"
+)
+
+// Middleware wraps a handler with behaviour that is shared by many routes, such as
+// authentication, logging or rate limits.
+type Middleware func(http.Handler) http.Handler
+
+// Chain composes several middlewares into one. The first middleware is the outermost one
+// and therefore sees a request first.
+func Chain(middleware ...Middleware) Middleware {
+	return func(handler http.Handler) http.Handler {
+		for i := len(middleware) - 1; i >= 0; i-- {
+			handler = middleware[i](handler)
+		}
+		return handler
+	}
+}
+
+// RouteGroup registers routes on a router and wraps all handlers with the middleware
+// of the group.
+type RouteGroup struct {
+	router     *mux.Router
+	middleware []Middleware
+}
+
+// NewRouteGroup creates a route group for a router.
+func NewRouteGroup(router *mux.Router, middleware ...Middleware) *RouteGroup {
+	return &RouteGroup{router: router, middleware: middleware}
+}
+
+// With returns a new group for the same router, which applies the given middleware after
+// the middleware of the current group.
+func (g *RouteGroup) With(middleware ...Middleware) *RouteGroup {
+	chain := make([]Middleware, 0, len(g.middleware)+len(middleware))
+	chain = append(chain, g.middleware...)
+	chain = append(chain, middleware...)
+	return &RouteGroup{router: g.router, middleware: chain}
+}
+
+// Handle registers a handler for a path and the given methods.
+func (g *RouteGroup) Handle(path string, handler http.Handler, methods ...string) *mux.Route {
+	route := g.router.Handle(path, Chain(g.middleware...)(handler))
+	if len(methods) > 0 {
+		route = route.Methods(methods...)
+	}
+	return route
+}
+
+// HandleFunc registers a handler function for a path and the given methods.
+func (g *RouteGroup) HandleFunc(path string, f http.HandlerFunc, methods ...string) *mux.Route {
+	return g.Handle(path, f, methods...)
+}
+
+// sessionInfo holds the session and the account of a request authorized by a SessionHandler.
+type sessionInfo struct {
+	session *data.Session
+	account *data.Account
+}
+
+// Synchronized store for sessions of authorized requests.
+var sessionInfos = struct {
+	sync.Mutex
+	store map[*http.Request]*sessionInfo
+}{store: make(map[*http.Request]*sessionInfo)}
+
+// SessionHandler requires a valid session cookie for a request. Requests without a session
+// are answered with an error page, otherwise session and account can later be obtained
+// using accountSession without looking them up again.
+func SessionHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var info *sessionInfo
+		session, ok := requestSession(w, r)
+		if ok {
+			info, ok = sessionInfoOf(session)
+		}
+		if !ok {
+			PrintErrorHTML(w, r, "Please login first", http.StatusUnauthorized)
+			return
+		}
+
+		sessionInfos.Lock()
+		sessionInfos.store[r] = info
+		sessionInfos.Unlock()
+
+		defer func() {
+			sessionInfos.Lock()
+			delete(sessionInfos.store, r)
+			sessionInfos.Unlock()
+		}()
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// lookupSession finds session and account for the session cookie of a request.
+func lookupSession(r *http.Request) (*sessionInfo, bool) {
+	sessionInfos.Lock()
+	info, ok := sessionInfos.store[r]
+	sessionInfos.Unlock()
+	if ok {
+		return info, true
+	}
+
+	token, _, ok := requestSessionToken(r)
+	if !ok {
+		return nil, false
+	}
+	session, ok := data.GetSession(token)
+	if !ok {
+		return nil, false
+	}
+	return sessionInfoOf(session)
+}
+
+// sessionInfoOf finds the account of a session.
+func sessionInfoOf(session *data.Session) (*sessionInfo, bool) {
+	account, ok := data.GetAccount(session.AccountUUID)
+	if !ok {
+		return nil, false
+	}
+	return &sessionInfo{session: session, account: account}, true
+}
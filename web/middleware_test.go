@@ -0,0 +1,61 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRouteGroup(t *testing.T) {
+	var trace []string
+	middleware := func(name string) Middleware {
+		return func(handler http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				trace = append(trace, name)
+				handler.ServeHTTP(w, r)
+			})
+		}
+	}
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		trace = append(trace, "handler")
+	}
+
+	r := mux.NewRouter()
+	outer := NewRouteGroup(r, middleware("a"))
+	outer.HandleFunc("/outer", handler, "GET")
+	outer.With(middleware("b"), middleware("c")).HandleFunc("/inner", handler, "GET")
+
+	// middleware is applied in order
+	request, _ := http.NewRequest("GET", "/inner", nil)
+	r.ServeHTTP(httptest.NewRecorder(), request)
+	if len(trace) != 4 || trace[0] != "a" || trace[1] != "b" || trace[2] != "c" || trace[3] != "handler" {
+		t.Errorf("Unexpected call order: %v", trace)
+	}
+
+	// inner middleware is not applied to the outer group
+	trace = nil
+	request, _ = http.NewRequest("GET", "/outer", nil)
+	r.ServeHTTP(httptest.NewRecorder(), request)
+	if len(trace) != 2 || trace[0] != "a" || trace[1] != "handler" {
+		t.Errorf("Unexpected call order: %v", trace)
+	}
+
+	// methods are respected
+	trace = nil
+	request, _ = http.NewRequest("POST", "/outer", nil)
+	response := httptest.NewRecorder()
+	r.ServeHTTP(response, request)
+	if len(trace) != 0 || response.Code == http.StatusOK {
+		t.Error("Route should not match other methods")
+	}
+}
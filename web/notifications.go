@@ -0,0 +1,95 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+// notificationSettings is the JSON representation of the notification settings of an account.
+type notificationSettings struct {
+	URL           string                `json:"url"`
+	Mode          string                `json:"mode"`
+	Announcements bool                  `json:"announcements"`
+	Pending       []pendingNotification `json:"pending"`
+}
+
+// pendingNotification is the JSON representation of a notification waiting to be sent.
+type pendingNotification struct {
+	Subject   string    `json:"subject"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// GetNotificationSettings is a handler which returns the notification mode of an account
+// and the notifications waiting to be sent as JSON.
+func GetNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	account, ok := ownAccount(w, r, "account-read")
+	if !ok {
+		return
+	}
+
+	writeNotificationSettings(w, account)
+}
+
+// UpdateNotificationSettings is a handler which updates the notification mode of an account.
+// The mode is either 'immediate' (one e-mail per notification) or 'daily' (daily digest).
+// If the field 'announcements' is present it sets whether the account receives announcements.
+func UpdateNotificationSettings(w http.ResponseWriter, r *http.Request) {
+	account, ok := ownAccount(w, r, "account-write")
+	if !ok {
+		return
+	}
+
+	body := &struct {
+		Mode          string `json:"mode"`
+		Announcements *bool  `json:"announcements"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Error while processing notification settings", http.StatusBadRequest)
+		return
+	}
+
+	err = account.UpdateNotificationMode(body.Mode)
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+	if body.Announcements != nil {
+		err = account.UpdateAnnouncementOptOut(!*body.Announcements)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	writeNotificationSettings(w, account)
+}
+
+func writeNotificationSettings(w http.ResponseWriter, account *data.Account) {
+	notifications := data.ListNotifications(account.UUID)
+	marshal := &notificationSettings{
+		URL:           conf.MakeUrl("/api/v1/accounts/%s/notifications", account.Login),
+		Mode:          account.NotificationMode,
+		Announcements: !account.IsAnnouncementOptOut,
+		Pending:       make([]pendingNotification, 0, len(notifications)),
+	}
+	for _, n := range notifications {
+		marshal.Pending = append(marshal.Pending, pendingNotification{Subject: n.Subject, CreatedAt: n.CreatedAt})
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetNotificationSettings(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// other account
+	request, _ := http.NewRequest("GET", "/api/accounts/bob/notifications", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/accounts/alice/notifications", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	settings := &notificationSettings{}
+	err := json.NewDecoder(response.Body).Decode(settings)
+	if err != nil {
+		t.Error(err)
+	}
+	if settings.Mode != "immediate" || len(settings.Pending) != 1 {
+		t.Errorf("Unexpected notification settings: %v", settings)
+	}
+}
+
+func TestUpdateNotificationSettings(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// invalid mode
+	request, _ := http.NewRequest("PUT", "/api/accounts/alice/notifications", strings.NewReader(`{"mode": "weekly"}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("PUT", "/api/accounts/alice/notifications", strings.NewReader(`{"mode": "daily"}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	settings := &notificationSettings{}
+	err := json.NewDecoder(response.Body).Decode(settings)
+	if err != nil {
+		t.Error(err)
+	}
+	if settings.Mode != "daily" || !settings.Announcements {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+
+	// opt out of announcements
+	request, _ = http.NewRequest("PUT", "/api/accounts/alice/notifications",
+		strings.NewReader(`{"mode": "daily", "announcements": false}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `"announcements":false`) {
+		t.Errorf("Announcements expected to be disabled: %s", response.Body.String())
+	}
+}
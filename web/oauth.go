@@ -9,7 +9,9 @@
 package web
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -22,13 +24,31 @@ import (
 	"github.com/G-Node/gin-auth/data"
 	"github.com/G-Node/gin-auth/util"
 	"github.com/G-Node/gin-core/gin"
+	"github.com/Sirupsen/logrus"
+	"github.com/dchest/captcha"
 	"github.com/gorilla/mux"
 )
 
-const (
-	cookiePath = "/"
-	cookieName = "session"
-)
+// sessionCookie creates a session cookie with the name and attributes from the server configuration.
+// The value is the signed session token, an empty token removes the cookie. Cookies for requests sent
+// via https are always secure.
+func sessionCookie(r *http.Request, token string, expires time.Time) *http.Cookie {
+	config := conf.GetServerConfig()
+	value := ""
+	if token != "" {
+		value = signSessionToken(token)
+	}
+	return &http.Cookie{
+		Name:     config.CookieName,
+		Value:    value,
+		Path:     config.CookiePath,
+		Domain:   config.CookieDomain,
+		Expires:  expires,
+		Secure:   config.CookieSecure || isSecureRequest(r),
+		HttpOnly: config.CookieHttpOnly,
+		SameSite: config.CookieSameSite,
+	}
+}
 
 // OAuthInfo provides information about an authorized access token
 type OAuthInfo struct {
@@ -87,6 +107,11 @@ func (o oauth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		if token, ok := data.GetAccessToken(tokenStr); ok {
 			match := token.Scope
 
+			if !token.AllowsIP(remoteIP(r)) {
+				PrintErrorJSON(w, r, "Token is bound to a different network", http.StatusForbidden)
+				return
+			}
+
 			if !o.Permissive {
 				match = match.Intersect(o.scope)
 				if match.Len() < 1 {
@@ -95,6 +120,8 @@ func (o oauth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				}
 			}
 
+			data.RecordAPIRequest(token)
+
 			tokens.Lock()
 			tokens.store[r] = &OAuthInfo{Match: match, Token: token}
 			tokens.Unlock()
@@ -117,10 +144,30 @@ func (o oauth) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	o.handler.ServeHTTP(w, r)
 }
 
+// EmailVerifiedHandler ensures that the account associated with the OAuth token of a request
+// has a verified e-mail address. Requests without a verified e-mail address are rejected with
+// StatusForbidden. The handler must be wrapped by an OAuthHandler.
+func EmailVerifiedHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		oauth, ok := OAuthToken(r)
+		if !ok {
+			panic("Request was authorized but no OAuth token is available!") // this should never happen
+		}
+
+		account, ok := data.GetAccount(oauth.Token.AccountUUID.String)
+		if !ok || !account.IsEmailVerified {
+			PrintErrorJSON(w, r, "Verified e-mail address required", http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
 // Authorize handles the beginning of an OAuth grant request following the schema
 // of any of the 'implicit', 'code', 'owner' or 'client' grant types.
 func Authorize(w http.ResponseWriter, r *http.Request) {
-	createGrantRequest(w, r, "/oauth/login_page")
+	createGrantRequest(w, r, conf.MakePath("/oauth/login_page"))
 }
 
 type loginData struct {
@@ -129,7 +176,65 @@ type loginData struct {
 	RequestID string
 }
 
-// LoginPage shows a page where the user can enter his credentials.
+// loginPageData contains the data shown on the login page. If CaptchaId is set, the
+// login form asks for the solution of the CAPTCHA.
+type loginPageData struct {
+	*loginData
+	CaptchaId string
+	Message   string
+	MagicLink bool
+}
+
+// Failed logins per address and per account, which decide whether the login form requires a CAPTCHA
+var loginFailures = struct {
+	sync.Once
+	ip      *util.RateLimiter
+	account *util.RateLimiter
+}{}
+
+// loginFailureLimiters returns the counters of failed logins per address and per account.
+// A counter is nil if the respective CAPTCHA threshold is disabled.
+func loginFailureLimiters() (*util.RateLimiter, *util.RateLimiter) {
+	loginFailures.Do(func() {
+		config := conf.GetLoginCaptcha()
+		if config.IPFailures > 0 {
+			loginFailures.ip = util.NewRateLimiter(config.IPFailures, config.Window)
+		}
+		if config.AccountFailures > 0 {
+			loginFailures.account = util.NewRateLimiter(config.AccountFailures, config.Window)
+		}
+	})
+	return loginFailures.ip, loginFailures.account
+}
+
+// loginCaptchaRequired returns true if there were too many failed logins from the address of
+// the request or, if accountUUID is not empty, for the account. Failed logins are counted per
+// account uuid, thus logins with the e-mail address count for the same account. Internal
+// networks get no relaxed limits.
+func loginCaptchaRequired(r *http.Request, accountUUID string) bool {
+	ip, account := loginFailureLimiters()
+	if ip != nil && ip.Blocked(rateLimitKey(r)) {
+		return true
+	}
+	return account != nil && accountUUID != "" && account.Blocked(accountUUID)
+}
+
+// recordLoginFailure counts a failed login with the given credential for the address of the
+// request and, if accountUUID is not empty, for the account the credential belongs to.
+func recordLoginFailure(r *http.Request, login, accountUUID string) {
+	util.RecordEvent(util.AlertFailedLogin, login)
+	util.RecordFailedLogin(login, remoteIP(r))
+	ip, account := loginFailureLimiters()
+	if ip != nil {
+		ip.Record(rateLimitKey(r))
+	}
+	if account != nil && accountUUID != "" {
+		account.Record(accountUUID)
+	}
+}
+
+// LoginPage shows a page where the user can enter his credentials. After too many
+// failed logins from the address of the user the page contains a CAPTCHA.
 func LoginPage(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
 	if query == nil || len(query) == 0 {
@@ -145,28 +250,46 @@ func LoginPage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// if there is a session cookie redirect to Login
-	cookie, err := r.Cookie(cookieName)
-	if err == nil {
-		_, ok := data.GetSession(cookie.Value)
-		if ok {
-			w.Header().Add("Cache-Control", "no-store")
-			http.Redirect(w, r, "/oauth/login?request_id="+request.Token, http.StatusFound)
-			return
-		}
+	if _, ok := requestSession(w, r); ok {
+		w.Header().Add("Cache-Control", "no-store")
+		http.Redirect(w, r, conf.MakePath("/oauth/login")+"?request_id="+request.Token, http.StatusFound)
+		return
 	}
 
 	// show login page
+	pageData := &loginPageData{loginData: &loginData{RequestID: token}}
+	if loginCaptchaRequired(r, "") {
+		pageData.CaptchaId = captcha.New()
+	}
+	printLoginPage(w, r, pageData)
+}
+
+// printLoginPage renders the login page. The login via magic link is offered if its feature flag is on.
+func printLoginPage(w http.ResponseWriter, r *http.Request, pageData *loginPageData) {
+	pageData.MagicLink = featureEnabled(r, featureMagicLinkLogin)
 	tmpl := conf.MakeTemplate("login.html")
 	w.Header().Add("Cache-Control", "no-store")
 	w.Header().Add("Content-Type", "text/html")
-	err = tmpl.ExecuteTemplate(w, "layout", &loginData{RequestID: token})
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
 	if err != nil {
 		panic(err)
 	}
 }
 
-// LoginWithCredentials validates user credentials.
-func LoginWithCredentials(w http.ResponseWriter, r *http.Request) {
+type login struct {
+	verifyCaptcha func(string, string) bool
+}
+
+// LoginHandler provides an http handler which validates user credentials. The function f
+// verifies the CAPTCHA, which is required after too many failed logins.
+func LoginHandler(f func(string, string) bool) http.Handler {
+	return &login{verifyCaptcha: f}
+}
+
+// The http handler of the login class validates user credentials. After too many failed logins
+// from the address of the user or for the account, the login form is shown again with a CAPTCHA
+// until the user solved it.
+func (lh *login) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	param := &loginData{}
 	err := util.ReadFormIntoStruct(r, param, false)
 	if err != nil {
@@ -181,53 +304,168 @@ func LoginWithCredentials(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// verify login data
+	// failed logins are counted for the account the login or e-mail address belongs to
 	account, ok := data.GetAccountByCredential(param.Login)
-	if !ok {
-		w.Header().Add("Cache-Control", "no-store")
-		http.Redirect(w, r, "/oauth/login_page?request_id="+request.Token, http.StatusFound)
+	var accountUUID string
+	if ok {
+		accountUUID = account.UUID
+	}
+
+	if loginCaptchaRequired(r, accountUUID) &&
+		!lh.verifyCaptcha(r.PostForm.Get("captcha_id"), r.PostForm.Get("captcha_resolve")) {
+		printLoginPage(w, r, &loginPageData{
+			loginData: &loginData{Login: param.Login, RequestID: request.Token},
+			CaptchaId: captcha.New(),
+			Message:   "Please resolve the verification",
+		})
 		return
 	}
 
-	ok = account.VerifyPassword(param.Password)
-	if !ok {
+	// verify login data, the password is verified even for unknown accounts to prevent timing attacks
+	valid := account.VerifyPassword(param.Password)
+	if !ok || !valid {
+		recordLoginFailure(r, param.Login, accountUUID)
+		if loginCaptchaRequired(r, accountUUID) {
+			printLoginPage(w, r, &loginPageData{
+				loginData: &loginData{Login: param.Login, RequestID: request.Token},
+				CaptchaId: captcha.New(),
+				Message:   "Wrong login or password",
+			})
+			return
+		}
 		w.Header().Add("Cache-Control", "no-store")
-		http.Redirect(w, r, "/oauth/login_page?request_id="+request.Token, http.StatusFound)
+		http.Redirect(w, r, conf.MakePath("/oauth/login_page")+"?request_id="+request.Token, http.StatusFound)
 		return
 	}
-
-	// associate grant request with account
-	request.AccountUUID = sql.NullString{String: account.UUID, Valid: true}
-	err = request.Update()
-	if err != nil {
-		panic(err)
+	if _, accountFailures := loginFailureLimiters(); accountFailures != nil {
+		accountFailures.Reset(account.UUID)
 	}
 
-	// create session
-	session := &data.Session{AccountUUID: account.UUID}
-	err = session.Create()
-	if err != nil {
-		panic(err)
+	// accounts selected by a re-verification campaign first confirm their e-mail address
+	// and then change their password before a session is started
+	if pending := account.PendingReverification(); pending.IsPending() {
+		reverify(w, r, request, account, pending)
+		return
 	}
 
-	cookie := &http.Cookie{
-		Name:    cookieName,
-		Value:   session.Token,
-		Path:    cookiePath,
-		Expires: session.Expires,
+	// an expired password has to be changed using the password reset, which continues the grant request
+	if account.IsPasswordExpired() {
+		requirePasswordChange(w, r, request, account, "expired")
+		return
 	}
-	http.SetCookie(w, cookie)
 
-	// if approved finish the grant request, otherwise redirect to approve page
-	if request.IsApproved() {
-		if request.GrantType == "code" {
-			finishCodeRequest(w, r, request)
-		} else {
-			finishImplicitRequest(w, r, request)
+	startSession(w, r, request, account)
+
+	// warn once about a breached password which was accepted in mode warn, the login continues with the session
+	if account.IsPasswordBreached {
+		err = account.SetPasswordBreached(false)
+		if err != nil {
+			panic(err)
 		}
-	} else {
+		pageData := struct {
+			RequestID string
+			GinUiURL  string
+		}{request.Token, conf.GetExternals().GinUiURL}
+
+		tmpl := conf.MakeTemplate("passwordbreach.html")
 		w.Header().Add("Cache-Control", "no-store")
-		http.Redirect(w, r, "/oauth/approve_page?request_id="+request.Token, http.StatusFound)
+		w.Header().Add("Content-Type", "text/html")
+		err = tmpl.ExecuteTemplate(w, "layout", pageData)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	// warn about a password expiring soon, the login continues with the session
+	if expires, ok := account.IsPasswordExpiring(); ok {
+		pageData := struct {
+			RequestID string
+			Expires   string
+			GinUiURL  string
+		}{request.Token, account.TimeFormat().Date(expires), conf.GetExternals().GinUiURL}
+
+		tmpl := conf.MakeTemplate("passwordexpiry.html")
+		w.Header().Add("Cache-Control", "no-store")
+		w.Header().Add("Content-Type", "text/html")
+		err = tmpl.ExecuteTemplate(w, "layout", pageData)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	continueGrantRequest(w, r, request)
+}
+
+// reverify interrupts the login of an account with pending re-verification steps. A pending e-mail
+// confirmation sends a new verification e-mail and asks the user to login again afterwards. A pending
+// password change sends a password reset link, like for an expired password.
+func reverify(w http.ResponseWriter, r *http.Request, request *data.GrantRequest, account *data.Account,
+	pending *data.PendingReverification) {
+
+	w.Header().Add("Cache-Control", "no-store")
+	if pending.Email {
+		err := sendEmailVerification(account)
+		if err != nil && data.KindOf(err) != data.ErrConflict {
+			panic(err)
+		}
+		conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+			"event": "reverification-email",
+			"login": account.Login,
+			"ip":    remoteIP(r),
+		}).Info("Login requires e-mail confirmation")
+
+		printReverifyPage(w, request, account, "email")
+		return
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event": "reverification-password",
+		"login": account.Login,
+		"ip":    remoteIP(r),
+	}).Info("Login requires password change")
+	requirePasswordChange(w, r, request, account, "reverify")
+}
+
+// requirePasswordChange sends a password reset link to the e-mail address of the account. The reset
+// code is never part of the response, since the login may have been done with a leaked password.
+// The link continues the grant request and the reason ("expired" or "reverify") is shown on the reset page.
+func requirePasswordChange(w http.ResponseWriter, r *http.Request, request *data.GrantRequest, account *data.Account,
+	reason string) {
+
+	_, code, ok := data.SetPasswordReset(account.Login)
+	if !ok {
+		panic("Unable to reset password of " + account.Login)
+	}
+	params := url.Values{}
+	params.Add(reason, "true")
+	params.Add("request_id", request.Token)
+	err := sendPasswordReset(account.Email, code, params)
+	if err != nil {
+		PrintErrorHTML(w, r, "An error occurred trying to send password reset e-mail. Please try again later.",
+			http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	printReverifyPage(w, request, account, reason)
+}
+
+// printReverifyPage informs the user that the login can only continue after following the link in the
+// e-mail which was sent for the given step ("email", "reverify" or "expired").
+func printReverifyPage(w http.ResponseWriter, request *data.GrantRequest, account *data.Account, step string) {
+	pageData := struct {
+		RequestID string
+		Email     string
+		Step      string
+	}{request.Token, account.Email, step}
+
+	tmpl := conf.MakeTemplate("reverify.html")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
 	}
 }
 
@@ -247,28 +485,43 @@ func LoginWithSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// get session cookie
-	cookie, err := r.Cookie(cookieName)
-	if err != nil {
+	if _, err := r.Cookie(conf.GetServerConfig().CookieName); err != nil {
 		PrintErrorHTML(w, r, "No session cookie provided", http.StatusBadRequest)
 		return
 	}
 
 	// validate cookie
-	session, ok := data.GetSession(cookie.Value)
+	sessionToken, _, _ := requestSessionToken(r)
+	session, ok := data.GetSession(sessionToken)
 	if !ok {
 		PrintErrorHTML(w, r, "Invalid session cookie", http.StatusNotFound)
 		return
 	}
-	err = session.UpdateExpirationTime()
-	if err != nil {
-		panic(err)
-	}
 
 	account, ok := data.GetAccount(session.AccountUUID)
 	if !ok {
 		panic("Session has not account")
 	}
 
+	// neither pending re-verification steps nor an expired password are bypassed by a session
+	if pending := account.PendingReverification(); pending.IsPending() {
+		reverify(w, r, request, account, pending)
+		return
+	}
+	if account.IsPasswordExpired() {
+		requirePasswordChange(w, r, request, account, "expired")
+		return
+	}
+
+	err := session.UpdateExpirationTime()
+	if err != nil {
+		panic(err)
+	}
+	err = account.RecordLogin()
+	if err != nil {
+		panic(err)
+	}
+
 	// associate grant request with account
 	request.AccountUUID = sql.NullString{String: account.UUID, Valid: true}
 	err = request.Update()
@@ -276,53 +529,161 @@ func LoginWithSession(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-	cookie = &http.Cookie{
-		Name:    cookieName,
-		Value:   session.Token,
-		Path:    cookiePath,
-		Expires: session.Expires,
+	http.SetCookie(w, sessionCookie(r, session.Token, session.Expires))
+
+	continueGrantRequest(w, r, request)
+}
+
+// startSession associates the grant request with the account and creates a new session.
+func startSession(w http.ResponseWriter, r *http.Request, request *data.GrantRequest, account *data.Account) {
+	request.AccountUUID = sql.NullString{String: account.UUID, Valid: true}
+	err := request.Update()
+	if err != nil {
+		panic(err)
+	}
+
+	session := &data.Session{AccountUUID: account.UUID, UserAgent: r.UserAgent()}
+	err = session.Create()
+	if err != nil {
+		panic(err)
+	}
+	limitSessions(r, account)
+
+	err = account.RecordLogin()
+	if err != nil {
+		panic(err)
+	}
+
+	http.SetCookie(w, sessionCookie(r, session.Token, session.Expires))
+}
+
+// limitSessions signs out the oldest sessions of the account if it has more sessions than allowed
+// by the configuration and notifies the owner of the account, who may not know that the credentials
+// are used elsewhere.
+func limitSessions(r *http.Request, account *data.Account) {
+	max := conf.GetSessionStore().MaxPerAccount
+	evicted, err := data.LimitAccountSessions(account.UUID, max)
+	if err != nil {
+		panic(err)
+	}
+	if len(evicted) == 0 {
+		return
+	}
+
+	devices := make([]string, 0, len(evicted))
+	for i := range evicted {
+		devices = append(devices, fmt.Sprintf("- %s (signed in on %s)",
+			evicted[i].Device(), account.TimeFormat().Format(evicted[i].CreatedAt)))
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":   "sessions-evicted",
+		"login":   account.Login,
+		"evicted": len(evicted),
+		"ip":      remoteIP(r),
+	}).Info("Oldest sessions ended by the session limit")
+
+	body := fmt.Sprintf("There was a new sign in to your GIN account '%s'. Since an account may only have %d "+
+		"sessions at the same time, the following sessions were signed out:\n\n%s\n\n"+
+		"If you did not sign in yourself, please change your password.\n%s",
+		account.Login, max, strings.Join(devices, "\n"), conf.GetExternals().GinUiURL)
+	err = account.Notify("Sessions of your GIN account were signed out", body)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// continueGrantRequest finishes the grant request if it is approved, otherwise redirects to the approve page.
+// Requests of trusted clients are approved without the approve page.
+func continueGrantRequest(w http.ResponseWriter, r *http.Request, request *data.GrantRequest) {
+	if !request.IsApproved() {
+		approveTrustedClient(r, request)
 	}
-	http.SetCookie(w, cookie)
 
-	// if approved finish the grant request, otherwise redirect to approve page
 	if request.IsApproved() {
-		if request.GrantType == "code" {
-			finishCodeRequest(w, r, request)
-		} else {
-			finishImplicitRequest(w, r, request)
-		}
+		finishGrantRequest(w, r, request)
 	} else {
 		w.Header().Add("Cache-Control", "no-store")
-		http.Redirect(w, r, "/oauth/approve_page?request_id="+request.Token, http.StatusFound)
+		http.Redirect(w, r, conf.MakePath("/oauth/approve_page")+"?request_id="+request.Token, http.StatusFound)
 	}
 }
 
-func finishCodeRequest(w http.ResponseWriter, r *http.Request, request *data.GrantRequest) {
-	request.Code = sql.NullString{String: util.RandomToken(), Valid: true}
-	err := request.Update()
+// approveTrustedClient records the approval of the requested scope if the client is flagged to skip
+// the consent page. Scope which can not be approved is left to the approve page.
+func approveTrustedClient(r *http.Request, request *data.GrantRequest) {
+	client := request.Client()
+	if !client.SkipConsent || !request.AccountUUID.Valid {
+		return
+	}
+
+	err := client.Approve(request.AccountUUID.String, request.ScopeRequested)
 	if err != nil {
-		panic(err)
+		conf.GetLogEnv().Err.Warnf("Unable to approve trusted client '%s': %s", client.Name, err.Error())
+		return
 	}
 
-	scope := url.QueryEscape(strings.Join(request.ScopeRequested.Strings(), " "))
-	state := url.QueryEscape(request.State)
-	url := fmt.Sprintf("%s?scope=%s&state=%s&code=%s", request.RedirectURI, scope, state, request.Code.String)
+	login := ""
+	if acc, ok := data.GetAccount(request.AccountUUID.String); ok {
+		login = acc.Login
+	}
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "client-approved",
+		"client": client.Name,
+		"login":  login,
+		"scope":  request.ScopeRequested.Strings(),
+		"ip":     remoteIP(r),
+	}).Info("Trusted client approved without consent page")
+}
+
+// finishGrantRequest completes an approved grant request and redirects to the client.
+func finishGrantRequest(w http.ResponseWriter, r *http.Request, request *data.GrantRequest) {
+	redirect, err := grantRedirectURL(r, request)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusForbidden)
+		return
+	}
 
 	w.Header().Add("Cache-Control", "no-store")
-	http.Redirect(w, r, url, http.StatusFound)
+	http.Redirect(w, r, redirect, http.StatusFound)
 }
 
-func finishImplicitRequest(w http.ResponseWriter, r *http.Request, request *data.GrantRequest) {
-	err := request.Delete()
+// grantRedirectURL completes an approved grant request and returns the redirect URI of the client
+// with a code or, for implicit requests, with an access token. An error is returned if the requester
+// is not within the network the client binds its tokens to.
+func grantRedirectURL(r *http.Request, request *data.GrantRequest) (string, error) {
+	scope := url.QueryEscape(strings.Join(request.ScopeRequested.Strings(), " "))
+	state := url.QueryEscape(request.State)
+
+	if request.GrantType == "code" {
+		request.Code = sql.NullString{String: util.RandomToken(), Valid: true}
+		err := request.Update()
+		if err != nil {
+			panic(err)
+		}
+		redirect := fmt.Sprintf("%s?scope=%s&state=%s&code=%s", request.RedirectURI, scope, state, request.Code.String)
+		return withReturnTo(redirect, request), nil
+	}
+
+	err := request.Complete()
 	if err != nil {
 		panic(err)
 	}
 
+	client, ok := data.GetClient(request.ClientUUID)
+	if !ok {
+		panic("Grant request without a client!") // this should never happen
+	}
+	bound, err := client.BindNetwork(remoteIP(r))
+	if err != nil {
+		return "", err
+	}
+
 	token := &data.AccessToken{
-		Token:       util.RandomToken(),
-		ClientUUID:  request.ClientUUID,
-		AccountUUID: request.AccountUUID,
-		Scope:       request.ScopeRequested,
+		Token:        util.RandomToken(),
+		ClientUUID:   request.ClientUUID,
+		AccountUUID:  request.AccountUUID,
+		Scope:        request.ScopeRequested,
+		BoundNetwork: bound,
 	}
 
 	err = token.Create()
@@ -330,12 +691,18 @@ func finishImplicitRequest(w http.ResponseWriter, r *http.Request, request *data
 		panic(err)
 	}
 
-	scope := url.QueryEscape(strings.Join(token.Scope.Strings(), " "))
-	state := url.QueryEscape(request.State)
-	url := fmt.Sprintf("%s?token_type=bearer&scope=%s&state=%s&access_token=%s", request.RedirectURI, scope, state, token.Token)
+	scope = url.QueryEscape(strings.Join(token.Scope.Strings(), " "))
+	redirect := fmt.Sprintf("%s?token_type=bearer&scope=%s&state=%s&access_token=%s", request.RedirectURI, scope, state, token.Token)
+	return withReturnTo(redirect, request), nil
+}
 
-	w.Header().Add("Cache-Control", "no-store")
-	http.Redirect(w, r, url, http.StatusFound)
+// withReturnTo adds the return URL of the grant request to the redirect URI, such that the client
+// can send the user back to the page where the authorization was started.
+func withReturnTo(redirect string, request *data.GrantRequest) string {
+	if request.ReturnTo == "" {
+		return redirect
+	}
+	return redirect + "&return_to=" + url.QueryEscape(request.ReturnTo)
 }
 
 // Logout remove a valid token (and if present the session cookie too) so it can't be used any more.
@@ -350,15 +717,10 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cookie, err := r.Cookie(cookieName)
-	if err == nil {
-		delCookie := &http.Cookie{
-			Name:    cookieName,
-			Path:    cookiePath,
-			Expires: time.Now().Add(-24 * time.Hour),
-		}
-		http.SetCookie(w, delCookie)
-		if session, ok := data.GetSession(cookie.Value); ok {
+	if _, err := r.Cookie(conf.GetServerConfig().CookieName); err == nil {
+		http.SetCookie(w, sessionCookie(r, "", time.Now().Add(-24*time.Hour)))
+		sessionToken, _, _ := requestSessionToken(r)
+		if session, ok := data.GetSession(sessionToken); ok {
 			if err := session.Delete(); err != nil {
 				panic(err)
 			}
@@ -385,6 +747,84 @@ func Logout(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// EndSession implements the OpenID Connect RP-initiated logout. The session of the browser
+// is terminated and all clients with a front-channel logout URL, which were used by the
+// account, are notified by loading their URL in the logout page. The client is identified by
+// 'client_id'; 'id_token_hint' is not supported and rejected, since gin-auth issues no ID tokens. The
+// browser is redirected to 'post_logout_redirect_uri' if this URL is registered for the client.
+func EndSession(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		PrintErrorHTML(w, r, "Unable to parse logout request", http.StatusBadRequest)
+		return
+	}
+
+	if r.Form.Get("id_token_hint") != "" {
+		PrintErrorHTML(w, r, "Parameter 'id_token_hint' is not supported, please use 'client_id'", http.StatusBadRequest)
+		return
+	}
+
+	var client *data.Client
+	if clientID := r.Form.Get("client_id"); clientID != "" {
+		named, ok := data.GetClientByName(clientID)
+		if !ok {
+			PrintErrorHTML(w, r, "Invalid client_id", http.StatusBadRequest)
+			return
+		}
+		client = named
+	}
+
+	redirect := r.Form.Get("post_logout_redirect_uri")
+	if redirect != "" {
+		if client == nil || !client.PostLogoutRedirectURIs.Contains(redirect) {
+			PrintErrorHTML(w, r, "Invalid post_logout_redirect_uri", http.StatusBadRequest)
+			return
+		}
+		if state := r.Form.Get("state"); state != "" {
+			target, err := url.Parse(redirect)
+			if err != nil {
+				PrintErrorHTML(w, r, "Invalid post_logout_redirect_uri", http.StatusBadRequest)
+				return
+			}
+			query := target.Query()
+			query.Set("state", state)
+			target.RawQuery = query.Encode()
+			redirect = target.String()
+		}
+	}
+
+	// the account whose clients are notified is taken from the session
+	var frontChannel []string
+	if _, err := r.Cookie(conf.GetServerConfig().CookieName); err == nil {
+		http.SetCookie(w, sessionCookie(r, "", time.Now().Add(-24*time.Hour)))
+		sessionToken, _, _ := requestSessionToken(r)
+		if session, ok := data.GetSession(sessionToken); ok {
+			frontChannel = data.ListFrontChannelLogoutURIs(session.AccountUUID)
+			if err := session.Delete(); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	if redirect != "" && len(frontChannel) == 0 {
+		http.Redirect(w, r, redirect, http.StatusFound)
+		return
+	}
+
+	pageData := struct {
+		FrontChannel []string
+		Redirect     string
+	}{frontChannel, redirect}
+
+	tmpl := conf.MakeTemplate("logout.html")
+	w.Header().Add("Content-Type", "text/html")
+	err = tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
 // ApprovePage shows a page where the user can approve client access.
 func ApprovePage(w http.ResponseWriter, r *http.Request) {
 	query := r.URL.Query()
@@ -405,9 +845,34 @@ func ApprovePage(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := request.Client()
+	addScope, existScope, renewal := approvalScope(request, client)
+
+	pageData := struct {
+		Client        string
+		Publisher     *clientPublisher
+		AddScope      map[string]string
+		ExistingScope map[string]string
+		Renewal       bool
+		RequestID     string
+	}{client.Name, newClientPublisher(client), addScope, existScope, renewal, request.Token}
+
+	tmpl := conf.MakeTemplate("approve.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// approvalScope describes the scope of a grant request which needs approval and the scope approved
+// before. Renewal is true if the existing approval is stale and its scope has to be approved again.
+func approvalScope(request *data.GrantRequest, client *data.Client) (addScope, existScope map[string]string, renewal bool) {
 	scope := request.ScopeRequested.Difference(client.ScopeWhitelist)
-	var existScope, addScope map[string]string
-	if approval, ok := client.ApprovalForAccount(request.AccountUUID.String); ok && approval.Scope.Len() > 0 {
+	approval, ok := client.ApprovalForAccount(request.AccountUUID.String)
+	renewal = ok && approval.Stale
+	// the scope of a stale approval has to be approved again
+	if !renewal && approval.Scope.Len() > 0 {
 		existScope, ok = data.DescribeScope(approval.Scope)
 		if !ok {
 			panic("Invalid scope")
@@ -422,21 +887,7 @@ func ApprovePage(w http.ResponseWriter, r *http.Request) {
 			panic("Invalid scope")
 		}
 	}
-
-	pageData := struct {
-		Client        string
-		AddScope      map[string]string
-		ExistingScope map[string]string
-		RequestID     string
-	}{client.Name, addScope, existScope, request.Token}
-
-	tmpl := conf.MakeTemplate("approve.html")
-	w.Header().Add("Cache-Control", "no-store")
-	w.Header().Add("Content-Type", "text/html")
-	err := tmpl.ExecuteTemplate(w, "layout", pageData)
-	if err != nil {
-		panic(err)
-	}
+	return addScope, existScope, renewal
 }
 
 // Approve evaluates an access approval given to a certain client.
@@ -468,6 +919,11 @@ func Approve(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := data.CheckScopeGrant(request.AccountUUID.String, client.UUID, request.ScopeRequested); err != nil {
+		PrintErrorHTML(w, r, err, http.StatusForbidden)
+		return
+	}
+
 	// create approval
 	err := client.Approve(request.AccountUUID.String, request.ScopeRequested)
 	if err != nil {
@@ -479,11 +935,7 @@ func Approve(w http.ResponseWriter, r *http.Request) {
 		panic("Requested scope should be approved but was not")
 	}
 
-	if request.GrantType == "code" {
-		finishCodeRequest(w, r, request)
-	} else {
-		finishImplicitRequest(w, r, request)
-	}
+	finishGrantRequest(w, r, request)
 }
 
 // Token exchanges a grant code for an access and refresh token
@@ -501,6 +953,10 @@ func Token(w http.ResponseWriter, r *http.Request) {
 		RefreshToken string
 		Username     string
 		Password     string
+		Groups       string
+
+		ClientAssertionType string
+		ClientAssertion     string
 	}{}
 	err := util.ReadFormIntoStruct(r, body, true)
 	if err != nil {
@@ -514,19 +970,36 @@ func Token(w http.ResponseWriter, r *http.Request) {
 		clientSecret = body.ClientSecret
 	}
 
-	// Check client
-	client, ok := data.GetClientByName(clientId)
-	if !ok {
-		PrintErrorJSON(w, r, "Wrong client id or client secret", http.StatusUnauthorized)
-		return
+	// Check client, which authenticates either with its secret or with a signed assertion
+	var client *data.Client
+	if body.ClientAssertionType != "" || body.ClientAssertion != "" {
+		client, err = authenticateClientAssertion(r, body.ClientId, body.ClientAssertionType, body.ClientAssertion)
+		if err != nil {
+			PrintErrorJSON(w, r, err, http.StatusUnauthorized)
+			return
+		}
+	} else {
+		var ok bool
+		client, ok = data.GetClientByName(clientId)
+		if !ok || !client.AcceptsSecret(clientSecret) {
+			PrintErrorJSON(w, r, "Wrong client id or client secret", http.StatusUnauthorized)
+			return
+		}
 	}
-	if clientSecret != client.Secret {
-		PrintErrorJSON(w, r, "Wrong client id or client secret", http.StatusUnauthorized)
+
+	// Tokens of clients with token binding are restricted to the network of the requester
+	bound, err := client.BindNetwork(remoteIP(r))
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusForbidden)
 		return
 	}
 
+	// Tokens may be restricted to act only for some groups of the account
+	groups := util.NewStringSet(strings.Fields(body.Groups)...)
+
 	// Prepare a response depending on the grant type
 	var response *gin.TokenResponse
+	var claims *data.AccessToken
 	switch body.GrantType {
 
 	case "authorization_code":
@@ -541,7 +1014,14 @@ func Token(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		access, refresh, err := request.ExchangeCodeForTokens()
+		if request.AccountUUID.Valid {
+			if err := data.ValidateGroupRestriction(request.AccountUUID.String, groups); err != nil {
+				PrintErrorJSON(w, r, err, http.StatusBadRequest)
+				return
+			}
+		}
+
+		access, refresh, err := request.ExchangeCodeForTokens(bound, groups)
 		if err != nil {
 			PrintErrorJSON(w, r, "Invalid grant code", http.StatusUnauthorized)
 			return
@@ -553,6 +1033,7 @@ func Token(w http.ResponseWriter, r *http.Request) {
 			AccessToken:  access,
 			RefreshToken: &refresh,
 		}
+		claims = &data.AccessToken{AccountUUID: request.AccountUUID, GroupRestriction: groups}
 
 	case "refresh_token":
 		refresh, ok := data.GetRefreshToken(body.RefreshToken)
@@ -565,14 +1046,50 @@ func Token(w http.ResponseWriter, r *http.Request) {
 			PrintErrorJSON(w, r, "Invalid refresh token", http.StatusUnauthorized)
 			return
 		}
+		if refresh.IsExpired() {
+			refresh.Delete()
+			PrintErrorJSON(w, r, "Refresh token expired", http.StatusUnauthorized)
+			return
+		}
+		account, ok := data.GetAccount(refresh.AccountUUID)
+		if !ok {
+			PrintErrorJSON(w, r, "Invalid refresh token", http.StatusUnauthorized)
+			return
+		}
+		if account.IsPasswordExpired() {
+			PrintErrorJSON(w, r, "Password expired, please log in via browser to change it", http.StatusForbidden)
+			return
+		}
+		if account.PendingReverification().IsPending() {
+			PrintErrorJSON(w, r, "Account requires re-verification, please log in via browser", http.StatusForbidden)
+			return
+		}
+
+		groups, valErr := refresh.NarrowGroupRestriction(groups)
+		if valErr != nil {
+			PrintErrorJSON(w, r, valErr, http.StatusBadRequest)
+			return
+		}
+		if valErr = data.ValidateGroupRestriction(refresh.AccountUUID, groups); valErr != nil {
+			PrintErrorJSON(w, r, valErr, http.StatusBadRequest)
+			return
+		}
+
+		err := refresh.UpdateLastUse()
+		if err != nil {
+			PrintErrorJSON(w, r, err, http.StatusInternalServerError)
+			return
+		}
 
 		access := data.AccessToken{
-			Token:       util.RandomToken(),
-			AccountUUID: sql.NullString{String: refresh.AccountUUID, Valid: true},
-			ClientUUID:  refresh.ClientUUID,
-			Scope:       refresh.Scope,
+			Token:            util.RandomToken(),
+			AccountUUID:      sql.NullString{String: refresh.AccountUUID, Valid: true},
+			ClientUUID:       refresh.ClientUUID,
+			Scope:            refresh.Scope,
+			BoundNetwork:     bound,
+			GroupRestriction: groups,
 		}
-		err := access.Create()
+		err = access.Create()
 		if err != nil {
 			PrintErrorJSON(w, r, err, http.StatusInternalServerError)
 			return
@@ -583,15 +1100,23 @@ func Token(w http.ResponseWriter, r *http.Request) {
 			Scope:       strings.Join(refresh.Scope.Strings(), " "),
 			AccessToken: access.Token,
 		}
+		claims = &access
 
 	case "password":
 		account, ok := data.GetAccountByLogin(body.Username)
-		if !ok {
+		valid := account.VerifyPassword(body.Password)
+		if !ok || !valid {
+			util.RecordEvent(util.AlertFailedLogin, body.Username)
+			util.RecordFailedLogin(body.Username, remoteIP(r))
 			PrintErrorJSON(w, r, "Wrong username or password", http.StatusUnauthorized)
 			return
 		}
-		if !account.VerifyPassword(body.Password) {
-			PrintErrorJSON(w, r, "Wrong username or password", http.StatusUnauthorized)
+		if account.IsPasswordExpired() {
+			PrintErrorJSON(w, r, "Password expired, please log in via browser to change it", http.StatusForbidden)
+			return
+		}
+		if account.PendingReverification().IsPending() {
+			PrintErrorJSON(w, r, "Account requires re-verification, please log in via browser", http.StatusForbidden)
 			return
 		}
 
@@ -600,26 +1125,48 @@ func Token(w http.ResponseWriter, r *http.Request) {
 			PrintErrorJSON(w, r, "Invalid scope", http.StatusUnauthorized)
 			return
 		}
+		if err := data.CheckScopeGrant(account.UUID, client.UUID, scope); err != nil {
+			PrintErrorJSON(w, r, err, http.StatusForbidden)
+			return
+		}
+
+		if err := data.ValidateGroupRestriction(account.UUID, groups); err != nil {
+			PrintErrorJSON(w, r, err, http.StatusBadRequest)
+			return
+		}
 
 		access := data.AccessToken{
-			Token:       util.RandomToken(),
-			AccountUUID: sql.NullString{String: account.UUID, Valid: true},
-			ClientUUID:  client.UUID,
-			Scope:       scope,
+			Token:            util.RandomToken(),
+			AccountUUID:      sql.NullString{String: account.UUID, Valid: true},
+			ClientUUID:       client.UUID,
+			Scope:            scope,
+			BoundNetwork:     bound,
+			GroupRestriction: groups,
 		}
 		err := access.Create()
 		if err != nil {
 			PrintErrorJSON(w, r, err, http.StatusInternalServerError)
 			return
 		}
+		err = account.RecordLogin()
+		if err != nil {
+			PrintErrorJSON(w, r, err, http.StatusInternalServerError)
+			return
+		}
 
 		response = &gin.TokenResponse{
 			TokenType:   "Bearer",
 			Scope:       strings.Join(scope.Strings(), " "),
 			AccessToken: access.Token,
 		}
+		claims = &access
 
 	case "client_credentials":
+		if groups.Len() > 0 {
+			PrintErrorJSON(w, r, "Tokens without account can not be restricted to groups", http.StatusBadRequest)
+			return
+		}
+
 		scope := util.NewStringSet(strings.Split(body.Scope, " ")...)
 		if scope.Len() == 0 || !client.ScopeWhitelist.IsSuperset(scope) {
 			PrintErrorJSON(w, r, "Invalid scope", http.StatusUnauthorized)
@@ -627,9 +1174,10 @@ func Token(w http.ResponseWriter, r *http.Request) {
 		}
 
 		access := data.AccessToken{
-			Token:      util.RandomToken(),
-			ClientUUID: client.UUID,
-			Scope:      scope,
+			Token:        util.RandomToken(),
+			ClientUUID:   client.UUID,
+			Scope:        scope,
+			BoundNetwork: bound,
 		}
 		err := access.Create()
 		if err != nil {
@@ -648,13 +1196,26 @@ func Token(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	marshal := &struct {
+		*gin.TokenResponse
+		Groups []groupClaim `json:"groups,omitempty"`
+	}{TokenResponse: response}
+	if claims != nil {
+		marshal.Groups = groupClaims(claims)
+	}
+
 	w.Header().Add("Cache-Control", "no-cache")
 	w.Header().Add("Content-Type", "application/json")
 	enc := json.NewEncoder(w)
-	enc.Encode(response)
+	enc.Encode(marshal)
 }
 
-// Validate validates a token and returns information about it as JSON
+// Validate validates a token and returns information about it as JSON.
+// For tokens associated with an account the verification state of the accounts
+// e-mail address is added as field "email_verified" and the group memberships the
+// token acts for as field "groups". Tokens bound to a network contain this network
+// as field "bound_network". Responses may be cached by resource servers, see
+// validationCacheControl.
 func Validate(w http.ResponseWriter, r *http.Request) {
 	tokenStr := mux.Vars(r)["token"]
 	token, ok := data.GetAccessToken(tokenStr)
@@ -662,32 +1223,81 @@ func Validate(w http.ResponseWriter, r *http.Request) {
 		PrintErrorJSON(w, r, "The requested token does not exist", http.StatusNotFound)
 		return
 	}
+	data.RecordTokenValidation(token)
 
-	var login, accountUrl *string
+	info, ok := tokenInfo(token)
+	if !ok {
+		PrintErrorJSON(w, r, "Unable to find account associated with the request", http.StatusInternalServerError)
+		return
+	}
+
+	body, err := json.Marshal(info)
+	if err != nil {
+		panic(err)
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	w.Header().Add("Cache-Control", validationCacheControl(token))
+	w.Header().Add("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// tokenInfo returns the information about a valid token sent by Validate and ValidateBatch.
+// Returns false if the account of the token does not exist.
+func tokenInfo(token *data.AccessToken) (interface{}, bool) {
+	var login, accountUrl string
+	var emailVerified *bool
 	if token.AccountUUID.Valid {
-		if account, ok := data.GetAccount(token.AccountUUID.String); ok {
-			login = &account.Login
-			accountUrl = new(string)
-			(*accountUrl) = conf.MakeUrl("/api/accounts/%s", account.Login)
-		} else {
-			PrintErrorJSON(w, r, "Unable to find account associated with the request", http.StatusInternalServerError)
-			return
+		account, ok := data.GetAccount(token.AccountUUID.String)
+		if !ok {
+			return nil, false
 		}
+		login = account.Login
+		emailVerified = &account.IsEmailVerified
+		accountUrl = conf.MakeUrl("/api/v1/accounts/%s", account.Login)
 	}
 
 	scope := strings.Join(token.Scope.Strings(), " ")
-	response := &gin.TokenInfo{
+	info := &struct {
+		*gin.TokenInfo
+		EmailVerified   *bool        `json:"email_verified,omitempty"`
+		BoundNetwork    *string      `json:"bound_network,omitempty"`
+		Groups          []groupClaim `json:"groups,omitempty"`
+		GroupRestricted bool         `json:"group_restricted,omitempty"`
+	}{TokenInfo: &gin.TokenInfo{
 		URL:        conf.MakeUrl("/oauth/validate/%s", token.Token),
 		JTI:        token.Token,
 		EXP:        token.Expires,
 		ISS:        "gin-auth",
-		Login:      *login,
-		AccountURL: *accountUrl,
+		Login:      login,
+		AccountURL: accountUrl,
 		Scope:      scope,
+	}, EmailVerified: emailVerified, Groups: groupClaims(token), GroupRestricted: token.GroupRestriction.Len() > 0}
+	if token.BoundNetwork.Valid {
+		info.BoundNetwork = &token.BoundNetwork.String
 	}
+	return info, true
+}
 
-	w.Header().Add("Cache-Control", "no-cache")
-	w.Header().Add("Content-Type", "application/json")
-	enc := json.NewEncoder(w)
-	enc.Encode(response)
+// groupClaim is the JSON representation of a group membership in token responses and token info.
+type groupClaim struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// groupClaims returns the group memberships the token acts for, so that resource servers
+// don't need to look up the groups of an account.
+func groupClaims(token *data.AccessToken) []groupClaim {
+	members := data.ListTokenGroups(token)
+	claims := make([]groupClaim, 0, len(members))
+	for _, m := range members {
+		claims = append(claims, groupClaim{Name: m.GroupName, Role: m.Role})
+	}
+	return claims
 }
@@ -9,14 +9,19 @@
 package web
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
 	"github.com/G-Node/gin-core/gin"
 	"github.com/gorilla/mux"
 )
@@ -26,16 +31,6 @@ const (
 	sessionCookieExpired = "2MFZZUKI"
 )
 
-// InitTestHttpHandler initializes a handler with all registered routes and returns it
-// along with a response recorder.
-func InitTestHttpHandler(t *testing.T) http.Handler {
-	data.InitTestDb(t)
-	router := mux.NewRouter()
-	router.NotFoundHandler = &NotFoundHandler{}
-	RegisterRoutes(router)
-	return router
-}
-
 func TestOAuthHandler(t *testing.T) {
 	data.InitTestDb(t)
 
@@ -96,6 +91,28 @@ func TestOAuthHandler(t *testing.T) {
 	if ok {
 		t.Error("OAuth info should be removed")
 	}
+
+	// token bound to a different network
+	called, authorized = false, false
+	request, _ = http.NewRequest("GET", "/", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer B7NDW8TX")
+	request.RemoteAddr = "192.0.2.1:1234"
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if called || authorized || response.Code != http.StatusForbidden {
+		t.Error("Request should not be authorized")
+	}
+
+	// token bound to the requesters network
+	called, authorized = false, false
+	request, _ = http.NewRequest("GET", "/", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer B7NDW8TX")
+	request.RemoteAddr = "10.1.2.3:1234"
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if !called || !authorized || response.Code != http.StatusOK {
+		t.Error("Request should be authorized")
+	}
 }
 
 func TestAuthorize(t *testing.T) {
@@ -181,6 +198,116 @@ func TestAuthorize(t *testing.T) {
 	if redirect.Query().Get("request_id") == "" {
 		t.Errorf("Request id not found")
 	}
+
+	// foreign return URL
+	query = mkQuery()
+	query.Set("return_to", "https://example.com/alice/repo")
+	request, _ = http.NewRequest("GET", "/oauth/authorize", strings.NewReader(""))
+	request.URL.RawQuery = query.Encode()
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// return URL of the client
+	query = mkQuery()
+	query.Set("return_to", "https://localhost:8081/alice/repo?tab=files")
+	request, _ = http.NewRequest("GET", "/oauth/authorize", strings.NewReader(""))
+	request.URL.RawQuery = query.Encode()
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+	redirect, err = url.Parse(response.Header().Get("Location"))
+	if err != nil {
+		t.Error(err)
+	}
+	grantRequest, ok := data.GetGrantRequest(redirect.Query().Get("request_id"))
+	if !ok {
+		t.Fatal("Grant request expected")
+	}
+	if grantRequest.ReturnTo != "https://localhost:8081/alice/repo?tab=files" {
+		t.Errorf("Return URL expected with grant request but was '%s'", grantRequest.ReturnTo)
+	}
+}
+
+func TestLoginReturnTo(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	grantRequest, ok := data.GetGrantRequest("U7JIKKYI")
+	if !ok {
+		t.Fatal("Grant request expected")
+	}
+	err := grantRequest.SetReturnTo("https://localhost:8081/alice/repo?tab=files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := &url.Values{}
+	body.Add("request_id", "U7JIKKYI")
+	body.Add("login", "alice")
+	body.Add("password", "testtest")
+	request, _ := http.NewRequest("POST", "/oauth/login", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+
+	redirect, err := url.Parse(response.Header().Get("Location"))
+	if err != nil {
+		t.Error(err)
+	}
+	if redirect.Path != "/login" || redirect.Query().Get("code") == "" {
+		t.Errorf("Redirect to the client expected but was '%s'", redirect.String())
+	}
+	if redirect.Query().Get("return_to") != "https://localhost:8081/alice/repo?tab=files" {
+		t.Errorf("Return URL expected in redirect '%s'", redirect.String())
+	}
+}
+
+func TestLoginSessionLimit(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	conf.GetSessionStore().MaxPerAccount = 1
+	defer func() { conf.GetSessionStore().MaxPerAccount = 0 }()
+
+	alice, _ := data.GetAccountByLogin("alice")
+	sessions := data.ListAccountSessions(alice.UUID)
+	if len(sessions) != 1 {
+		t.Fatal("Exactly one session of alice expected")
+	}
+	notifications := len(data.ListNotifications(alice.UUID))
+
+	body := &url.Values{}
+	body.Add("request_id", "U7JIKKYI")
+	body.Add("login", "alice")
+	body.Add("password", "testtest")
+	request, _ := http.NewRequest("POST", "/oauth/login", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+
+	if _, ok := data.GetSession(sessions[0].Token); ok {
+		t.Error("Oldest session expected to be signed out")
+	}
+	current := data.ListAccountSessions(alice.UUID)
+	if len(current) != 1 || current[0].Token == sessions[0].Token {
+		t.Error("Only the new session expected to remain")
+	}
+	pending := data.ListNotifications(alice.UUID)
+	if len(pending) != notifications+1 {
+		t.Fatal("Notification about the signed out session expected")
+	}
+	if !strings.Contains(pending[len(pending)-1].Body, sessions[0].Device()) {
+		t.Errorf("Notification expected to name the device: %s", pending[len(pending)-1].Body)
+	}
 }
 
 func TestLoginPage(t *testing.T) {
@@ -209,6 +336,75 @@ func TestLoginPage(t *testing.T) {
 	if response.Code != http.StatusOK {
 		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
 	}
+	checkAccessibility(t, response.Body.String())
+}
+
+func TestLoginWithExpiredPassword(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	body := &url.Values{}
+	body.Add("request_id", "U7JIKKYI")
+	body.Add("login", "john")
+	body.Add("password", "testtest")
+	request, _ := http.NewRequest("POST", "/oauth/login", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	emails, _ := data.GetQueuedEmails()
+	num := len(emails)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("Location") != "" || strings.Contains(response.Body.String(), "reset_code") {
+		t.Error("Reset code must only be sent by e-mail")
+	}
+
+	// the reset link is sent to the address of the account and continues the grant request
+	emails, _ = data.GetQueuedEmails()
+	if len(emails) != num+1 {
+		t.Fatal("E-mail with a password reset link expected")
+	}
+	content := string(emails[len(emails)-1].Content)
+	if !strings.Contains(content, "expired=true") || !strings.Contains(content, "request_id=U7JIKKYI") {
+		t.Errorf("Reset link continuing the grant request expected:\n%s", content)
+	}
+	if len(response.Result().Cookies()) != 0 {
+		t.Error("No session expected for an expired password")
+	}
+}
+
+func TestLoginWithBreachedPassword(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	account, _ := data.GetAccountByLogin("bob")
+	err := account.SetPasswordBreached(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := &url.Values{}
+	body.Add("request_id", "B4LIMIMB")
+	body.Add("login", "bob")
+	body.Add("password", "testtest")
+	request, _ := http.NewRequest("POST", "/oauth/login", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "data breaches") {
+		t.Error("Page expected to warn about the breached password")
+	}
+	if len(response.Result().Cookies()) == 0 {
+		t.Error("Session expected to be started")
+	}
+
+	// the warning is only shown once
+	account, _ = data.GetAccountByLogin("bob")
+	if account.IsPasswordBreached {
+		t.Error("Breached password expected to be reset after the warning")
+	}
 }
 
 func TestLoginWithSession(t *testing.T) {
@@ -216,7 +412,7 @@ func TestLoginWithSession(t *testing.T) {
 
 	// no request id
 	request, _ := http.NewRequest("GET", "/oauth/login", strings.NewReader(""))
-	request.AddCookie(&http.Cookie{Name: cookieName, Value: sessionCookieBob})
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
 	response := httptest.NewRecorder()
 	handler.ServeHTTP(response, request)
 	if response.Code != http.StatusBadRequest {
@@ -226,7 +422,7 @@ func TestLoginWithSession(t *testing.T) {
 	// wrong request id
 	request, _ = http.NewRequest("GET", "/oauth/login", strings.NewReader(""))
 	request.URL.RawQuery = url.Values{"request_id": []string{"doesnotexist"}}.Encode()
-	request.AddCookie(&http.Cookie{Name: cookieName, Value: sessionCookieBob})
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
 	response = httptest.NewRecorder()
 	handler.ServeHTTP(response, request)
 	if response.Code != http.StatusNotFound {
@@ -245,7 +441,7 @@ func TestLoginWithSession(t *testing.T) {
 	// expired session
 	request, _ = http.NewRequest("GET", "/oauth/login", strings.NewReader(""))
 	request.URL.RawQuery = url.Values{"request_id": []string{"U7JIKKYI"}}.Encode()
-	request.AddCookie(&http.Cookie{Name: cookieName, Value: sessionCookieExpired})
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieExpired)})
 	response = httptest.NewRecorder()
 	handler.ServeHTTP(response, request)
 	if response.Code != http.StatusNotFound {
@@ -255,7 +451,7 @@ func TestLoginWithSession(t *testing.T) {
 	// all ok
 	request, _ = http.NewRequest("GET", "/oauth/login", strings.NewReader(""))
 	request.URL.RawQuery = url.Values{"request_id": []string{"U7JIKKYI"}}.Encode()
-	request.AddCookie(&http.Cookie{Name: cookieName, Value: sessionCookieBob})
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
 	response = httptest.NewRecorder()
 	handler.ServeHTTP(response, request)
 	if response.Code != http.StatusFound {
@@ -274,6 +470,37 @@ func TestLoginWithSession(t *testing.T) {
 	}
 }
 
+func TestLoginTrustedClient(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	client, _ := data.GetClientByName("wb")
+	grant, err := client.CreateGrantRequest("code", "https://localhost:8081/login", "state", util.NewStringSet("repo-write"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := &url.Values{}
+	body.Add("request_id", grant.Token)
+	body.Add("login", "bob")
+	body.Add("password", "testtest")
+	request, _ := http.NewRequest("POST", "/oauth/login", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusFound {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+	redirect, _ := url.Parse(response.Header().Get("Location"))
+	if redirect.Host != "localhost:8081" || redirect.Query().Get("code") == "" {
+		t.Errorf("Redirect to the client expected but was '%s'", redirect.String())
+	}
+
+	approval, ok := client.ApprovalForAccount("51f5ac36-d332-4889-8023-6e033fcd8e17")
+	if !ok || !approval.Scope.Contains("repo-write") {
+		t.Error("Approval of the trusted client expected to be recorded")
+	}
+}
+
 func TestLoginWithCredentials(t *testing.T) {
 	const validLogin = "bob"
 	const validLoginToken = "B4LIMIMB"
@@ -369,6 +596,71 @@ func TestLoginWithCredentials(t *testing.T) {
 	}
 }
 
+func TestLoginHandlerCaptcha(t *testing.T) {
+	data.InitTestDb(t)
+	handler := LoginHandler(func(id string, resolve string) bool {
+		return id != "" && id == resolve
+	})
+
+	mkRequest := func(password, captcha string) *http.Request {
+		body := &url.Values{}
+		body.Add("request_id", "B4LIMIMB")
+		body.Add("login", "bob")
+		body.Add("password", password)
+		body.Add("captcha_id", captcha)
+		body.Add("captcha_resolve", captcha)
+		request, _ := http.NewRequest("POST", "/oauth/login", strings.NewReader(body.Encode()))
+		request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		return request
+	}
+	ipFailures, accountFailures := loginFailureLimiters()
+	defer ipFailures.Reset(rateLimitKey(mkRequest("", "")))
+	defer accountFailures.Reset("51f5ac36-d332-4889-8023-6e033fcd8e17")
+
+	// failed logins below the threshold
+	for i := 0; i < 2; i++ {
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, mkRequest("wrong", ""))
+		if response.Code != http.StatusFound {
+			t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+		}
+	}
+
+	// failed login reaching the threshold
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, mkRequest("wrong", ""))
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "captcha_id") {
+		t.Error("Login page should contain a captcha")
+	}
+	checkAccessibility(t, response.Body.String())
+	if !accountFailures.Blocked("51f5ac36-d332-4889-8023-6e033fcd8e17") {
+		t.Error("Failed logins expected to be counted for the account of bob")
+	}
+
+	// correct password without captcha
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, mkRequest("testtest", ""))
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "Please resolve the verification") {
+		t.Error("Login page should ask to resolve the captcha")
+	}
+
+	// correct password with captcha
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, mkRequest("testtest", "captcha"))
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+	if accountFailures.Blocked("51f5ac36-d332-4889-8023-6e033fcd8e17") {
+		t.Error("Failed logins of bob should be reset")
+	}
+}
+
 func TestLogout(t *testing.T) {
 	handler := InitTestHttpHandler(t)
 
@@ -430,7 +722,7 @@ func TestLogoutWithSession(t *testing.T) {
 	handler := InitTestHttpHandler(t)
 
 	request, _ := http.NewRequest("GET", "/oauth/logout/3N7MP7M7", strings.NewReader(""))
-	request.AddCookie(&http.Cookie{Name: cookieName, Value: sessionCookieBob})
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
 	response := httptest.NewRecorder()
 	handler.ServeHTTP(response, request)
 	if response.Code != http.StatusOK {
@@ -448,6 +740,100 @@ func TestLogoutWithSession(t *testing.T) {
 	}
 }
 
+func TestEndSession(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// id_token_hint is not supported, access tokens are not accepted as hint
+	request, _ := http.NewRequest("GET", "/oauth/logout?id_token_hint=3N7MP7M7", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+	if _, ok := data.GetAccessToken("3N7MP7M7"); !ok {
+		t.Error("Token should still exist")
+	}
+
+	// unregistered post logout redirect uri
+	request, _ = http.NewRequest("GET", "/oauth/logout?client_id=gin&post_logout_redirect_uri=http%3A%2F%2Fexample.com", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// unknown client id
+	request, _ = http.NewRequest("GET", "/oauth/logout?client_id=doesnotexist", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// no token and no session
+	request, _ = http.NewRequest("GET", "/oauth/logout", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	// unknown account and registered redirect
+	request, _ = http.NewRequest("GET", "/oauth/logout?client_id=gin&post_logout_redirect_uri=http%3A%2F%2Flocalhost%3A8080%2Flogged_out&state=foo", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+	if loc := response.Header().Get("Location"); loc != "http://localhost:8080/logged_out?state=foo" {
+		t.Errorf("Wrong redirect uri '%s'", loc)
+	}
+
+	// session with front-channel logout
+	request, _ = http.NewRequest("GET", "/oauth/logout?client_id=gin&post_logout_redirect_uri=http%3A%2F%2Flocalhost%3A8080%2Flogged_out&state=a%26b", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken("DNM5RS3C")})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	body := response.Body.String()
+	if !strings.Contains(body, "http://localhost:8080/frontchannel_logout") {
+		t.Error("Front-channel logout of client gin expected")
+	}
+	if !strings.Contains(body, "https://localhost:8081/frontchannel_logout") {
+		t.Error("Front-channel logout of client wb expected")
+	}
+	if !strings.Contains(body, "logout-redirect") {
+		t.Error("Logout redirect expected")
+	}
+	if !strings.Contains(body, "logged_out?state=a%26b") {
+		t.Error("Encoded state expected in the logout redirect")
+	}
+
+	_, ok := data.GetSession("DNM5RS3C")
+	if ok {
+		t.Error("Session should not exist")
+	}
+}
+
+func TestEndSessionWithSession(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("POST", "/oauth/logout", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	_, ok := data.GetSession(sessionCookieBob)
+	if ok {
+		t.Error("Session should not exist")
+	}
+}
+
 func TestApprovePage(t *testing.T) {
 	handler := InitTestHttpHandler(t)
 
@@ -474,6 +860,38 @@ func TestApprovePage(t *testing.T) {
 	if response.Code != http.StatusOK {
 		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
 	}
+	checkAccessibility(t, response.Body.String())
+	if body := response.Body.String(); !strings.Contains(body, "Verified") || !strings.Contains(body, "G-Node") ||
+		!strings.Contains(body, "https://www.g-node.org/images/logo.png") || strings.Contains(body, "not been verified") {
+		t.Error("Publisher of the verified client expected on the page")
+	}
+
+	// unverified client
+	request, _ = http.NewRequest("GET", "/oauth/approve_page?request_id=QH92T99D", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	checkAccessibility(t, response.Body.String())
+	if body := response.Body.String(); !strings.Contains(body, "Unverified") || !strings.Contains(body, "not been verified") {
+		t.Error("Warning about the unverified client expected")
+	}
+
+	// stale approval
+	client, _ := data.GetClient("8b14d6bb-cae7-4163-bbd1-f3be46e43e31")
+	approval, _ := client.ApprovalForAccount("bf431618-f696-4dca-a95d-882618ce4ef9")
+	approval.Stale = true
+	approval.Update()
+	request, _ = http.NewRequest("GET", "/oauth/approve_page?request_id=U7JIKKYI", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if body := response.Body.String(); !strings.Contains(body, "have changed") || strings.Contains(body, "existing-scopes") {
+		t.Error("Scope of a stale approval expected to be requested again")
+	}
 }
 
 func TestApprove(t *testing.T) {
@@ -620,6 +1038,35 @@ func TestTokenAuthorizationCode(t *testing.T) {
 	}
 }
 
+func TestLoginWithSessionExpiredPassword(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// the password of john is expired
+	session := &data.Session{AccountUUID: "03dcd573-1cce-4eb1-8b33-73860575da65"}
+	err := session.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emails, _ := data.GetQueuedEmails()
+	num := len(emails)
+	request, _ := http.NewRequest("GET", "/oauth/login", strings.NewReader(""))
+	request.URL.RawQuery = url.Values{"request_id": []string{"U7JIKKYI"}}.Encode()
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(session.Token)})
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("Location") != "" {
+		t.Error("Grant request expected not to continue with an expired password")
+	}
+	emails, _ = data.GetQueuedEmails()
+	if len(emails) != num+1 || !strings.Contains(string(emails[len(emails)-1].Content), "expired=true") {
+		t.Error("E-mail with a password reset link expected")
+	}
+}
+
 func TestTokenRefreshToken(t *testing.T) {
 	const refreshTokenAlice = "YYPTDSVZ"
 
@@ -707,6 +1154,76 @@ func TestTokenRefreshToken(t *testing.T) {
 	}
 }
 
+func TestTokenRefreshTokenExpired(t *testing.T) {
+	const refreshTokenAlice = "YYPTDSVZ"
+	const refreshTokenBob = "4FKJVX3K" // last used yesterday
+
+	handler := InitTestHttpHandler(t)
+
+	conf.GetRefreshTokens().IdleTime = time.Hour
+	defer func() { conf.GetRefreshTokens().IdleTime = 30 * 24 * time.Hour }()
+
+	refresh := func(token string) int {
+		body := &url.Values{}
+		body.Add("refresh_token", token)
+		body.Add("grant_type", "refresh_token")
+		request, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(body.Encode()))
+		request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth("gin", "secret")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response.Code
+	}
+
+	// idle for longer than the idle time
+	if code := refresh(refreshTokenBob); code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, code)
+	}
+	if _, ok := data.GetRefreshToken(refreshTokenBob); ok {
+		t.Error("Expired refresh token expected to be deleted")
+	}
+
+	// recently issued
+	if code := refresh(refreshTokenAlice); code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, code)
+	}
+	tok, _ := data.GetRefreshToken(refreshTokenAlice)
+	if !tok.LastUsedAt.Valid {
+		t.Error("Use of the refresh token expected to be recorded")
+	}
+}
+
+func TestTokenPasswordTiming(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	measure := func(username string) time.Duration {
+		start := time.Now()
+		for i := 0; i < 5; i++ {
+			body := &url.Values{}
+			body.Add("password", "wrongpassword")
+			body.Add("username", username)
+			body.Add("scope", "account-read")
+			body.Add("grant_type", "password")
+			request, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(body.Encode()))
+			request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+			request.SetBasicAuth("wb", "secret")
+			response := httptest.NewRecorder()
+			handler.ServeHTTP(response, request)
+			if response.Code != http.StatusUnauthorized {
+				t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+			}
+		}
+		return time.Since(start)
+	}
+
+	measure("doesnotexist") // warm up
+	durExisting := measure("alice")
+	durUnknown := measure("doesnotexist")
+	if durUnknown < durExisting/2 || durUnknown > durExisting*2 {
+		t.Errorf("Failed login of unknown account took %s, of existing account %s", durUnknown, durExisting)
+	}
+}
+
 func TestTokenPassword(t *testing.T) {
 	mkBody := func(username, password, scope string) *url.Values {
 		body := &url.Values{}
@@ -935,4 +1452,204 @@ func TestValidate(t *testing.T) {
 	if result.Login != "alice" {
 		t.Errorf("Login expected to be 'alice' but was '%s'", result.Login)
 	}
+	if !strings.Contains(response.Body.String(), `"email_verified":true`) {
+		t.Error("Response expected to contain 'email_verified'")
+	}
+	if cc := response.Header().Get("Cache-Control"); cc != "no-cache" {
+		t.Errorf("Caching expected to be disabled by default but was '%s'", cc)
+	}
+
+	// caching enabled
+	validation := conf.GetTokenValidation()
+	defer func(maxAge time.Duration) { validation.MaxAge = maxAge }(validation.MaxAge)
+	validation.MaxAge = 30 * time.Second
+	request, _ = http.NewRequest("GET", "/oauth/validate/3N7MP7M7", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if cc := response.Header().Get("Cache-Control"); cc != "private, max-age=30, stale-while-revalidate=30" {
+		t.Errorf("Unexpected cache control '%s'", cc)
+	}
+
+	// not modified
+	etag := response.Header().Get("ETag")
+	request, _ = http.NewRequest("GET", "/oauth/validate/3N7MP7M7", strings.NewReader(""))
+	request.Header.Set("If-None-Match", etag)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if etag == "" || response.Code != http.StatusNotModified {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotModified, response.Code)
+	}
+}
+
+func TestValidateBatch(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// invalid body
+	request, _ := http.NewRequest("POST", "/oauth/validate", strings.NewReader("{"))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// too many tokens
+	tokens := make([]string, 101)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("token%d", i)
+	}
+	b, _ := json.Marshal(map[string][]string{"tokens": tokens})
+	request, _ = http.NewRequest("POST", "/oauth/validate", bytes.NewReader(b))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("POST", "/oauth/validate",
+		strings.NewReader(`{"tokens": ["3N7MP7M7", "LJ3W7ZFK", "doesnotexist"]}`))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	result := &struct {
+		Tokens map[string]*gin.TokenInfo
+	}{}
+	json.Unmarshal(response.Body.Bytes(), result)
+	if len(result.Tokens) != 3 || result.Tokens["LJ3W7ZFK"] != nil || result.Tokens["doesnotexist"] != nil {
+		t.Errorf("Unexpected result: %s", response.Body.String())
+	}
+	if info := result.Tokens["3N7MP7M7"]; info == nil || info.Login != "alice" {
+		t.Errorf("Unexpected result: %s", response.Body.String())
+	}
+}
+
+func TestTokenGroupRestriction(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	mkBody := func(groups string) *url.Values {
+		body := &url.Values{}
+		body.Add("password", "testtest")
+		body.Add("username", "alice")
+		body.Add("scope", "account-read repo-read")
+		body.Add("grant_type", "password")
+		body.Add("groups", groups)
+		return body
+	}
+
+	// not a member
+	body := mkBody("lmu-neuro-ephys")
+	request, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth("wb", "secret")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	body = mkBody("lmu-neuro")
+	request, _ = http.NewRequest("POST", "/oauth/token", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth("wb", "secret")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	responseBody := &gin.TokenResponse{}
+	json.Unmarshal(response.Body.Bytes(), responseBody)
+	if !strings.Contains(response.Body.String(), `"groups":[{"name":"lmu-neuro","role":"owner"}]`) {
+		t.Errorf("Response expected to contain group claims: %s", response.Body.String())
+	}
+
+	// the token info contains the claims
+	request, _ = http.NewRequest("GET", "/oauth/validate/"+responseBody.AccessToken, strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `"groups":[{"name":"lmu-neuro","role":"owner"}],"group_restricted":true`) {
+		t.Errorf("Token info expected to contain group claims: %s", response.Body.String())
+	}
+}
+
+func TestTokenRefreshTokenExpiredPassword(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// the password of john is expired
+	refresh := &data.RefreshToken{
+		Scope:       util.NewStringSet("repo-read"),
+		ClientUUID:  "8b14d6bb-cae7-4163-bbd1-f3be46e43e31",
+		AccountUUID: "03dcd573-1cce-4eb1-8b33-73860575da65",
+	}
+	err := refresh.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := &url.Values{}
+	body.Add("refresh_token", refresh.Token)
+	body.Add("grant_type", "refresh_token")
+	request, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth("gin", "secret")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+}
+
+func TestTokenRefreshTokenGroupRestriction(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	refresh := &data.RefreshToken{
+		Scope:            util.NewStringSet("repo-read"),
+		ClientUUID:       "8b14d6bb-cae7-4163-bbd1-f3be46e43e31",
+		AccountUUID:      "bf431618-f696-4dca-a95d-882618ce4ef9",
+		GroupRestriction: util.NewStringSet("lmu-neuro"),
+	}
+	err := refresh.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mkRequest := func(groups string) *http.Request {
+		body := &url.Values{}
+		body.Add("refresh_token", refresh.Token)
+		body.Add("grant_type", "refresh_token")
+		body.Add("groups", groups)
+		request, _ := http.NewRequest("POST", "/oauth/token", strings.NewReader(body.Encode()))
+		request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth("gin", "secret")
+		return request
+	}
+
+	// the restriction is inherited
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, mkRequest(""))
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	responseBody := &gin.TokenResponse{}
+	json.Unmarshal(response.Body.Bytes(), responseBody)
+	access, ok := data.GetAccessToken(responseBody.AccessToken)
+	if !ok {
+		t.Fatal("Access token expected to exist")
+	}
+	if access.GroupRestriction.Len() != 1 || !access.GroupRestriction.Contains("lmu-neuro") {
+		t.Errorf("Access token expected to be restricted to 'lmu-neuro': %v", access.GroupRestriction.Strings())
+	}
+
+	// the restriction can not be widened
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, mkRequest("lmu-neuro lmu-neuro-ephys"))
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
 }
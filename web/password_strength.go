@@ -0,0 +1,81 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// The password strength API allows 60 requests per minute from one address.
+var passwordStrengthLimiter = util.NewRateLimiter(60, time.Minute)
+
+// passwordStrength is the JSON representation of the estimated strength of a password.
+type passwordStrength struct {
+	Score       int      `json:"score"`
+	MinScore    int      `json:"min_score"`
+	MinLength   int      `json:"min_length"`
+	Acceptable  bool     `json:"acceptable"`
+	Message     string   `json:"message,omitempty"`
+	Warning     string   `json:"warning,omitempty"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// PasswordStrength is a handler which estimates the strength of the password in the JSON request body
+// and reports whether it complies with the password strength policy. Login, e-mail and name of the
+// account can be sent along, since passwords containing them are easy to guess.
+// Requests are rate limited per address, except requests from internal networks.
+func PasswordStrength(w http.ResponseWriter, r *http.Request) {
+	key := rateLimitKey(r)
+	if !isInternalRequest(r) && !passwordStrengthLimiter.Allow(key) {
+		printTooManyRequests(w, r, passwordStrengthLimiter.RetryAfter(key))
+		return
+	}
+
+	body := &struct {
+		Password  string `json:"password"`
+		Login     string `json:"login"`
+		Email     string `json:"email"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+	if len(body.Password) > 512 {
+		PrintErrorJSON(w, r, "Password too long", http.StatusBadRequest)
+		return
+	}
+
+	policy := conf.GetPasswordStrength()
+	strength, err := data.CheckPasswordStrength(body.Password, body.Login, body.Email, body.FirstName, body.LastName)
+	marshal := &passwordStrength{
+		Score:       strength.Score,
+		MinScore:    policy.MinScore,
+		MinLength:   policy.MinLength,
+		Acceptable:  err == nil,
+		Warning:     strength.Warning,
+		Suggestions: strength.Suggestions,
+	}
+	if err != nil {
+		marshal.Message = err.Error()
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
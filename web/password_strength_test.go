@@ -0,0 +1,57 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPasswordStrength(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	check := func(body string) (*httptest.ResponseRecorder, *passwordStrength) {
+		request, _ := http.NewRequest("POST", "/api/password-strength", strings.NewReader(body))
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		result := &passwordStrength{}
+		json.NewDecoder(response.Body).Decode(result)
+		return response, result
+	}
+
+	// invalid body
+	response, _ := check("{")
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// too short
+	response, result := check(`{"password": "abc"}`)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if result.Acceptable || result.Message == "" || result.MinLength != 6 {
+		t.Error("Short password expected to be not acceptable")
+	}
+
+	// weak password containing the login
+	_, result = check(`{"password": "alice1234", "login": "alice"}`)
+	if result.Score > 1 || result.Warning == "" || len(result.Suggestions) == 0 {
+		t.Errorf("Weak password expected to have a low score and feedback, score was %d", result.Score)
+	}
+
+	// strong password
+	_, result = check(`{"password": "correct horse battery staple"}`)
+	if !result.Acceptable || result.Score != 4 {
+		t.Errorf("Strong password expected to be acceptable with score 4, score was %d", result.Score)
+	}
+}
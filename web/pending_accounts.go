@@ -0,0 +1,270 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/gorilla/mux"
+)
+
+// ListPendingAccounts is a handler which returns all accounts waiting for approval as JSON.
+func ListPendingAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts := data.ListPendingAccounts()
+
+	marshal := make([]*data.AccountMarshaler, 0, len(accounts))
+	for i := range accounts {
+		marshal = append(marshal, accountMarshaler(r, &accounts[i]))
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// ApprovePendingAccount is a handler which approves an account waiting for approval
+// and returns the approved account as JSON.
+func ApprovePendingAccount(w http.ResponseWriter, r *http.Request) {
+	account, ok := data.GetPendingAccount(mux.Vars(r)["account"])
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist or is not pending", http.StatusNotFound)
+		return
+	}
+
+	err := approvePendingAccount(account)
+	if err != nil {
+		if code := errorStatus(err, 0); code != 0 {
+			PrintErrorJSON(w, r, err, code)
+			return
+		}
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	marshal := accountMarshaler(r, account)
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// RejectPendingAccount is a handler which removes an account waiting for approval.
+// With the query parameter 'dry_run' the removal is rolled back, no e-mail is sent and
+// the accounts which would be removed are returned as JSON.
+func RejectPendingAccount(w http.ResponseWriter, r *http.Request) {
+	dry, err := isDryRun(r)
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	account, ok := data.GetPendingAccount(mux.Vars(r)["account"])
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist or is not pending", http.StatusNotFound)
+		return
+	}
+
+	err = rejectPendingAccount(account, dry)
+	if err != nil {
+		if code := errorStatus(err, 0); code != 0 {
+			PrintErrorJSON(w, r, err, code)
+			return
+		}
+		panic(err)
+	}
+
+	if dry {
+		result := &struct {
+			DryRun    bool     `json:"dry_run"`
+			Accounts  int      `json:"accounts"`
+			SampleIDs []string `json:"sample_ids"`
+		}{true, 1, []string{account.Login}}
+
+		w.Header().Add("Cache-Control", "no-cache")
+		w.Header().Add("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.Encode(result)
+	}
+}
+
+// PendingAccountsPage shows all accounts waiting for approval to an administrator
+// logged in via session cookie.
+func PendingAccountsPage(w http.ResponseWriter, r *http.Request) {
+	session, admin, ok := administratorSession(w, r)
+	if !ok {
+		return
+	}
+
+	pageData := struct {
+		Accounts  []data.Account
+		CSRFToken string
+		Time      *util.TimeFormat
+	}{data.ListPendingAccounts(), sessionCSRFToken(session), admin.TimeFormat()}
+
+	tmpl := conf.MakeTemplate("pendingaccounts.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// PendingAccountsAction approves or rejects an account submitted from the pending accounts page.
+func PendingAccountsAction(w http.ResponseWriter, r *http.Request) {
+	session, _, ok := administratorSession(w, r)
+	if !ok {
+		return
+	}
+
+	param := &struct {
+		Login     string
+		Action    string
+		CSRFToken string
+	}{}
+	err := util.ReadFormIntoStruct(r, param, false)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+	expected := sessionCSRFToken(session)
+	if !util.EqualTokens(param.CSRFToken, expected) {
+		PrintErrorHTML(w, r, "Invalid form token", http.StatusForbidden)
+		return
+	}
+
+	account, ok := data.GetPendingAccount(param.Login)
+	if !ok {
+		PrintErrorHTML(w, r, "The requested account does not exist or is not pending", http.StatusNotFound)
+		return
+	}
+
+	switch param.Action {
+	case "approve":
+		err = approvePendingAccount(account)
+	case "reject":
+		err = rejectPendingAccount(account, false)
+	default:
+		PrintErrorHTML(w, r, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	http.Redirect(w, r, conf.MakePath("/oauth/pending_accounts"), http.StatusFound)
+}
+
+// administratorSession returns the session and account of the request if it belongs to an administrator
+// as configured in the registration settings. Otherwise an error page is written.
+func administratorSession(w http.ResponseWriter, r *http.Request) (*data.Session, *data.Account, bool) {
+	session, account, ok := accountSession(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	if !conf.GetRegistration().IsAdministrator(account.Login) {
+		PrintErrorHTML(w, r, "Access to this page is restricted to administrators", http.StatusForbidden)
+		return nil, nil, false
+	}
+	return session, account, true
+}
+
+// sessionCSRFToken derives a token for forms from the session token.
+func sessionCSRFToken(session *data.Session) string {
+	sum := sha256.Sum256([]byte("csrf:" + session.Token))
+	return hex.EncodeToString(sum[:])
+}
+
+// approvePendingAccount approves an account and informs the account owner.
+func approvePendingAccount(account *data.Account) error {
+	err := account.Approve()
+	if err != nil {
+		return err
+	}
+
+	tmplFields := &struct {
+		From     string
+		To       string
+		Subject  string
+		Login    string
+		GinUiUrl string
+	}{
+		conf.GetSmtpCredentials().From,
+		account.Email,
+		"GIN account approved",
+		account.Login,
+		conf.GetExternals().GinUiURL,
+	}
+	content := util.MakeEmailTemplate("emailapproved.txt", tmplFields)
+	email := &data.Email{}
+	return email.Create(util.NewStringSet(account.Email), content.Bytes())
+}
+
+// rejectPendingAccount removes an account and informs the account owner. A dry run neither
+// removes the account nor informs its owner.
+func rejectPendingAccount(account *data.Account, dryRun bool) error {
+	err := account.Reject(dryRun)
+	if err != nil || dryRun {
+		return err
+	}
+
+	tmplFields := &struct {
+		From    string
+		To      string
+		Subject string
+		Body    string
+	}{
+		conf.GetSmtpCredentials().From,
+		account.Email,
+		"GIN account registration",
+		fmt.Sprintf("Your registration of the GIN account %s was not approved by an administrator.", account.Login),
+	}
+	content := util.MakeEmailTemplate("emailplain.txt", tmplFields)
+	email := &data.Email{}
+	return email.Create(util.NewStringSet(account.Email), content.Bytes())
+}
+
+// notifyPendingAccount informs the configured address about an account waiting for approval.
+func notifyPendingAccount(account *data.Account) error {
+	notify := conf.GetRegistration().NotifyEmail
+	if notify == "" {
+		return nil
+	}
+
+	tmplFields := &struct {
+		From        string
+		To          string
+		Subject     string
+		BaseUrl     string
+		Login       string
+		Name        string
+		Email       string
+		Affiliation string
+	}{
+		conf.GetSmtpCredentials().From,
+		notify,
+		"GIN account waiting for approval",
+		conf.GetServerConfig().BaseURL,
+		account.Login,
+		account.FirstName + " " + account.LastName,
+		account.Email,
+		fmt.Sprintf("%s, %s, %s, %s", account.Institute, account.Department, account.City, account.Country),
+	}
+	content := util.MakeEmailTemplate("emailpending.txt", tmplFields)
+	email := &data.Email{}
+	return email.Create(util.NewStringSet(notify), content.Bytes())
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestListPendingAccounts(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no admin scope
+	request, _ := http.NewRequest("GET", "/api/pending_accounts", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/pending_accounts", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	accounts := []data.AccountMarshaler{}
+	err := json.Unmarshal(response.Body.Bytes(), &accounts)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(accounts) != 1 {
+		t.Fatalf("One pending account expected but was %d", len(accounts))
+	}
+	if strings.Contains(response.Body.String(), "_links") {
+		t.Error("No links expected for pending accounts")
+	}
+}
+
+func TestApprovePendingAccount(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// not pending
+	request, _ := http.NewRequest("POST", "/api/pending_accounts/alice/approve", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("POST", "/api/pending_accounts/pending/approve", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if _, ok := data.GetAccountByLogin("pending"); !ok {
+		t.Error("Approved account should be active")
+	}
+}
+
+func TestRejectPendingAccount(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// not pending
+	request, _ := http.NewRequest("DELETE", "/api/pending_accounts/alice", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// dry run
+	request, _ = http.NewRequest("DELETE", "/api/pending_accounts/pending?dry_run=true", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `"sample_ids":["pending"]`) {
+		t.Errorf("Pending account expected in dry run result: %s", response.Body.String())
+	}
+	if _, ok := data.GetPendingAccount("pending"); !ok {
+		t.Error("Account should not be removed by a dry run")
+	}
+
+	// all ok
+	request, _ = http.NewRequest("DELETE", "/api/pending_accounts/pending", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if _, ok := data.GetPendingAccount("pending"); ok {
+		t.Error("Rejected account should not exist")
+	}
+}
+
+func TestPendingAccountsPage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no session cookie
+	request, _ := http.NewRequest("GET", "/oauth/pending_accounts", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+}
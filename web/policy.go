@@ -0,0 +1,55 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// PolicyHandler asks the policy engine whether the account associated with the OAuth token
+// of a request may use an admin API. Denied requests are rejected with status 403. Without a
+// policy engine all requests are passed on without looking up the account.
+func PolicyHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !data.PolicyEngineEnabled() {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		oauth, ok := OAuthToken(r)
+		if !ok {
+			panic("Request was authorized but no OAuth token is available!") // this should never happen
+		}
+
+		input := data.NewPolicyInput(data.PolicyActionAdminAPI, oauth.Token.AccountUUID.String)
+		input.Scope = oauth.Token.Scope.Strings()
+		input.Method = r.Method
+		input.Path = routePath(r)
+		input.Vars = mux.Vars(r)
+
+		if err := data.CheckPolicy(input); err != nil {
+			conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+				"event":  "policy-denied",
+				"login":  input.Account,
+				"method": r.Method,
+				"path":   input.Path,
+				"ip":     remoteIP(r),
+			}).Warn(err.Error())
+			PrintErrorJSON(w, r, err, http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+type testPolicyEngine struct {
+	inputs []*data.PolicyInput
+}
+
+func (e *testPolicyEngine) Decide(input *data.PolicyInput) (bool, error) {
+	e.inputs = append(e.inputs, input)
+	return input.Path != "/api/usage", nil
+}
+
+func TestPolicyHandler(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	engine := &testPolicyEngine{}
+	data.RegisterPolicyEngine(engine)
+	defer data.RegisterPolicyEngine(nil)
+
+	// denied by policy
+	request, _ := http.NewRequest("GET", "/api/usage", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// allowed by policy
+	request, _ = http.NewRequest("GET", "/api/accounts/alice/notes", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code == http.StatusForbidden {
+		t.Error("Request expected to be allowed by policy")
+	}
+
+	if len(engine.inputs) != 2 {
+		t.Fatalf("Policy engine expected to be called twice but was called %d times", len(engine.inputs))
+	}
+	input := engine.inputs[1]
+	if input.Action != data.PolicyActionAdminAPI || input.Account != "bob" || input.Method != "GET" ||
+		input.Path != "/api/accounts/{account}/notes" || input.Vars["account"] != "alice" {
+		t.Errorf("Unexpected policy input: %+v", input)
+	}
+}
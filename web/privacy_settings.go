@@ -0,0 +1,83 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// privacySettings is the JSON representation of the privacy settings of an account.
+// PublicEmail is the e-mail address as shown in public responses or nil if it is not public.
+type privacySettings struct {
+	URL           string  `json:"url"`
+	EmailPublic   bool    `json:"email_public"`
+	EmailUnmasked bool    `json:"email_unmasked"`
+	PublicEmail   *string `json:"public_email"`
+}
+
+// GetPrivacySettings is a handler which returns the privacy settings of an account as JSON.
+func GetPrivacySettings(w http.ResponseWriter, r *http.Request) {
+	account, ok := ownAccount(w, r, "account-read")
+	if !ok {
+		return
+	}
+
+	writePrivacySettings(w, account)
+}
+
+// UpdatePrivacySettings is a handler which sets whether the public e-mail address of an
+// account is shown in full or masked. Whether the address is public at all is part of the
+// account itself.
+func UpdatePrivacySettings(w http.ResponseWriter, r *http.Request) {
+	account, ok := ownAccount(w, r, "account-write")
+	if !ok {
+		return
+	}
+
+	body := &struct {
+		EmailUnmasked bool `json:"email_unmasked"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Error while processing privacy settings", http.StatusBadRequest)
+		return
+	}
+
+	err = account.UpdateEmailUnmasked(body.EmailUnmasked)
+	if err != nil {
+		panic(err)
+	}
+
+	writePrivacySettings(w, account)
+}
+
+func writePrivacySettings(w http.ResponseWriter, account *data.Account) {
+	marshal := &privacySettings{
+		URL:           conf.MakeUrl("/api/v1/accounts/%s/privacy_settings", account.Login),
+		EmailPublic:   account.IsEmailPublic,
+		EmailUnmasked: account.IsEmailUnmasked,
+	}
+	if account.IsEmailPublic {
+		email := account.Email
+		if !account.IsEmailUnmasked {
+			email = util.MaskEmail(email)
+		}
+		marshal.PublicEmail = &email
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestPrivacySettings(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	publicEmail := func() string {
+		request, _ := http.NewRequest("GET", "/api/accounts/alice", strings.NewReader(""))
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		result := &struct {
+			Email struct {
+				Email string
+			}
+		}{}
+		json.NewDecoder(response.Body).Decode(result)
+		return result.Email.Email
+	}
+
+	// e-mail not public
+	request, _ := http.NewRequest("GET", "/api/accounts/alice/privacy_settings", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	settings := &privacySettings{}
+	json.NewDecoder(response.Body).Decode(settings)
+	if settings.EmailPublic || settings.EmailUnmasked || settings.PublicEmail != nil {
+		t.Error("E-mail expected not to be public")
+	}
+
+	// public e-mail is masked
+	account, _ := data.GetAccountByLogin("alice")
+	account.IsEmailPublic = true
+	err := account.Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email := publicEmail(); email != "a***@f***.com" {
+		t.Errorf("Masked e-mail expected but was '%s'", email)
+	}
+
+	// opt in to show the full address
+	request, _ = http.NewRequest("PUT", "/api/accounts/alice/privacy_settings", strings.NewReader(`{"email_unmasked": true}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	settings = &privacySettings{}
+	json.NewDecoder(response.Body).Decode(settings)
+	if !settings.EmailUnmasked || settings.PublicEmail == nil || *settings.PublicEmail != "aclic@foo.com" {
+		t.Error("Full e-mail address expected")
+	}
+	if email := publicEmail(); email != "aclic@foo.com" {
+		t.Errorf("Full e-mail expected but was '%s'", email)
+	}
+
+	// invalid body
+	request, _ = http.NewRequest("PUT", "/api/accounts/alice/privacy_settings", strings.NewReader(`{"email_unmasked": "yes"}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/util"
+)
+
+const (
+	headerForwardedFor   = "X-Forwarded-For"
+	headerForwardedProto = "X-Forwarded-Proto"
+	headerForwardedHost  = "X-Forwarded-Host"
+)
+
+// ProxyHandler makes gin-auth aware of reverse proxies. For requests from a trusted proxy the
+// remote address is replaced by the client address from the X-Forwarded-For header. For all
+// other requests X-Forwarded-* headers are removed, such that subsequent handlers can rely on them.
+func ProxyHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := conf.GetServerConfig()
+
+		if config.IsTrustedProxy(net.ParseIP(remoteIP(r))) {
+			if client := forwardedClient(config, r.Header.Get(headerForwardedFor)); client != "" {
+				r.RemoteAddr = net.JoinHostPort(client, "0")
+			}
+		} else {
+			r.Header.Del(headerForwardedFor)
+			r.Header.Del(headerForwardedProto)
+			r.Header.Del(headerForwardedHost)
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// forwardedClient returns the address of the client from an X-Forwarded-For header: this is
+// the right-most address which does not belong to a trusted proxy.
+func forwardedClient(config *conf.ServerConfig, header string) string {
+	if header == "" {
+		return ""
+	}
+
+	addrs := strings.Split(header, ",")
+	for i := len(addrs) - 1; i >= 0; i-- {
+		ip := util.NormalizeIP(addrs[i])
+		if ip == nil {
+			return ""
+		}
+		if i == 0 || !config.IsTrustedProxy(ip) {
+			return ip.String()
+		}
+	}
+	return ""
+}
+
+// isSecureRequest checks whether a request was sent via https, either directly or to a trusted proxy.
+func isSecureRequest(r *http.Request) bool {
+	return r.TLS != nil || strings.ToLower(r.Header.Get(headerForwardedProto)) == "https"
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func TestProxyHandler(t *testing.T) {
+	var ip string
+	var secure bool
+	handler := ProxyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip = remoteIP(r)
+		secure = isSecureRequest(r)
+	}))
+
+	// request from a trusted proxy
+	request, _ := http.NewRequest("GET", "/", strings.NewReader(""))
+	request.RemoteAddr = "127.0.0.1:4242"
+	request.Header.Set("X-Forwarded-For", "192.0.2.7, 127.0.0.1")
+	request.Header.Set("X-Forwarded-Proto", "https")
+	request.Header.Set("X-Forwarded-Host", "gin.example.org")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if ip != "192.0.2.7" {
+		t.Errorf("Remote IP expected to be '192.0.2.7' but was '%s'", ip)
+	}
+	if !secure {
+		t.Error("Request expected to be secure")
+	}
+
+	// request from an untrusted host
+	request, _ = http.NewRequest("GET", "/", strings.NewReader(""))
+	request.RemoteAddr = "198.51.100.3:4242"
+	request.Header.Set("X-Forwarded-For", "192.0.2.7")
+	request.Header.Set("X-Forwarded-Proto", "https")
+	request.Header.Set("X-Forwarded-Host", "evil.example.org")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if ip != "198.51.100.3" {
+		t.Errorf("Remote IP expected to be '198.51.100.3' but was '%s'", ip)
+	}
+	if secure {
+		t.Error("Request expected to be insecure")
+	}
+
+	// IPv6 addresses are normalized
+	request, _ = http.NewRequest("GET", "/", strings.NewReader(""))
+	request.RemoteAddr = "[2001:DB8:0::0001%eth0]:4242"
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if ip != "2001:db8::1" {
+		t.Errorf("Remote IP expected to be '2001:db8::1' but was '%s'", ip)
+	}
+
+	// request from a trusted peer on a unix domain socket
+	config := conf.GetServerConfig()
+	config.Socket = "/run/gin-auth.sock"
+	config.TrustSocketPeer = true
+	defer func() { config.Socket = ""; config.TrustSocketPeer = false }()
+	request, _ = http.NewRequest("GET", "/", strings.NewReader(""))
+	request.RemoteAddr = "@"
+	request.Header.Set("X-Forwarded-For", "192.0.2.7")
+	request.Header.Set("X-Forwarded-Proto", "https")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+	if ip != "192.0.2.7" {
+		t.Errorf("Remote IP expected to be '192.0.2.7' but was '%s'", ip)
+	}
+	if !secure {
+		t.Error("Request expected to be secure")
+	}
+}
+
+func TestRateLimitKey(t *testing.T) {
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.RemoteAddr = "[2001:db8:1:2:aaaa::1]:4242"
+	if key := rateLimitKey(request); key != "2001:db8:1:2::/64" {
+		t.Errorf("Rate limit key expected to be '2001:db8:1:2::/64' but was '%s'", key)
+	}
+	request.RemoteAddr = "192.0.2.1:4242"
+	if key := rateLimitKey(request); key != "192.0.2.1" {
+		t.Errorf("Rate limit key expected to be '192.0.2.1' but was '%s'", key)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/Sirupsen/logrus"
+)
+
+// Requests with these paths only read data and are therefore allowed in read-only mode
+// regardless of their method.
+var readOnlyAllowedPaths = []string{
+	"/oauth/validate",
+	"/api/password-strength",
+	"/api/authorize-access",
+	"/api/maintenance",
+	"/api/readonly",
+}
+
+// GET requests to these paths change data and are therefore rejected in read-only mode.
+var readOnlyBlockedPaths = []string{
+	"/oauth/registration_init",
+	"/oauth/activation",
+	"/oauth/verify_email",
+	"/oauth/confirm_scope",
+}
+
+// Requests to these paths create or end sessions, grant requests and tokens. They are
+// rejected in read-only mode unless logins are allowed.
+var readOnlyLoginPaths = []string{
+	"/oauth/authorize",
+	"/oauth/login",
+	"/oauth/json_login",
+	"/oauth/magic_link",
+	"/oauth/magic_login",
+	"/oauth/approve",
+	"/api/consent",
+	"/oauth/logout",
+	"/oauth/token",
+}
+
+// readOnlyData is the JSON representation of the read-only state.
+type readOnlyData struct {
+	Enabled     bool   `json:"enabled"`
+	AllowLogins bool   `json:"allow_logins"`
+	Message     string `json:"message"`
+	RetryAfter  int    `json:"retry_after"` // in seconds
+}
+
+// ReadOnlyHandler rejects writes with StatusServiceUnavailable while gin-auth is in read-only mode.
+// Token validation, read access and changes of the read-only state remain possible, logins only
+// if they are allowed.
+func ReadOnlyHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ro := conf.GetReadOnly()
+		if ro.Enabled && isReadOnlyBlocked(r, ro.AllowLogins) {
+			w.Header().Add("Retry-After", strconv.Itoa(int(ro.RetryAfter/time.Second)))
+			if strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/oauth/token" {
+				PrintErrorJSON(w, r, ro.Message, http.StatusServiceUnavailable)
+			} else {
+				PrintErrorHTML(w, r, ro.Message, http.StatusServiceUnavailable)
+			}
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// isReadOnlyBlocked checks whether a request is not allowed in read-only mode.
+func isReadOnlyBlocked(r *http.Request, allowLogins bool) bool {
+	path := unversionedPath(r.URL.Path)
+	for _, p := range readOnlyAllowedPaths {
+		if strings.HasPrefix(path, p) {
+			return false
+		}
+	}
+	for _, p := range readOnlyLoginPaths {
+		if strings.HasPrefix(path, p) {
+			return !allowLogins
+		}
+	}
+
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+		for _, p := range readOnlyBlockedPaths {
+			if strings.HasPrefix(path, p) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// GetReadOnly returns the current read-only state as JSON.
+func GetReadOnly(w http.ResponseWriter, r *http.Request) {
+	ro := conf.GetReadOnly()
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(&readOnlyData{ro.Enabled, ro.AllowLogins, ro.Message, int(ro.RetryAfter / time.Second)})
+}
+
+// UpdateReadOnly enables or disables the read-only mode. The request must be authorized by an
+// OAuthHandler with scope 'account-admin'. Message and retry interval fall back to the configured
+// defaults if omitted.
+func UpdateReadOnly(w http.ResponseWriter, r *http.Request) {
+	body := &readOnlyData{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Invalid read-only data", http.StatusBadRequest)
+		return
+	}
+
+	conf.SetReadOnly(conf.ReadOnly{
+		Enabled:     body.Enabled,
+		AllowLogins: body.AllowLogins,
+		Message:     body.Message,
+		RetryAfter:  time.Duration(body.RetryAfter) * time.Second,
+	})
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":        "read-only-changed",
+		"enabled":      body.Enabled,
+		"allow_logins": body.AllowLogins,
+		"ip":           remoteIP(r),
+	}).Warn("Read-only mode changed")
+
+	GetReadOnly(w, r)
+}
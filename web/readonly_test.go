@@ -0,0 +1,141 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func TestReadOnlyHandler(t *testing.T) {
+	defer conf.SetReadOnly(conf.ReadOnly{Enabled: false})
+
+	var called bool
+	handler := ReadOnlyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		called = false
+		request, _ := http.NewRequest(method, path, strings.NewReader(""))
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// read-only mode disabled
+	conf.SetReadOnly(conf.ReadOnly{Enabled: false})
+	serve("POST", "/api/accounts/alice/keys")
+	if !called {
+		t.Error("Request should be handled")
+	}
+
+	conf.SetReadOnly(conf.ReadOnly{Enabled: true, RetryAfter: 5 * time.Minute})
+
+	// read access and token validation
+	for _, req := range [][]string{{"GET", "/api/accounts/alice"}, {"GET", "/oauth/validate/LJ3W7ZFK"}, {"POST", "/oauth/validate"}} {
+		serve(req[0], req[1])
+		if !called {
+			t.Errorf("Request '%s %s' should be handled", req[0], req[1])
+		}
+	}
+
+	// writes
+	for _, req := range [][]string{{"POST", "/api/accounts/alice/keys"}, {"GET", "/oauth/activation?activation_code=foo"}} {
+		response := serve(req[0], req[1])
+		if called || response.Code != http.StatusServiceUnavailable {
+			t.Errorf("Response code '%d' expected but was '%d'", http.StatusServiceUnavailable, response.Code)
+		}
+	}
+	if response := serve("DELETE", "/api/keys"); response.Header().Get("Retry-After") != "300" {
+		t.Errorf("Retry-After expected to be '300' but was '%s'", response.Header().Get("Retry-After"))
+	}
+
+	// logins
+	for _, path := range []string{"/oauth/login", "/oauth/token"} {
+		response := serve("POST", path)
+		if called || response.Code != http.StatusServiceUnavailable {
+			t.Errorf("Response code '%d' expected but was '%d'", http.StatusServiceUnavailable, response.Code)
+		}
+	}
+	conf.SetReadOnly(conf.ReadOnly{Enabled: true, AllowLogins: true})
+	for _, path := range []string{"/oauth/login", "/oauth/token"} {
+		serve("POST", path)
+		if !called {
+			t.Errorf("Login via '%s' should be handled", path)
+		}
+	}
+
+	// change of the read-only state
+	serve("PUT", "/api/readonly")
+	if !called {
+		t.Error("Change of the read-only state should be handled")
+	}
+}
+
+func TestUpdateReadOnly(t *testing.T) {
+	defer conf.SetReadOnly(conf.ReadOnly{Enabled: false})
+	handler := InitTestHttpHandler(t)
+
+	// insufficient scope
+	request, _ := http.NewRequest("PUT", "/api/readonly", strings.NewReader(`{"enabled": true}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid body
+	request, _ = http.NewRequest("PUT", "/api/readonly", strings.NewReader("{"))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("PUT", "/api/readonly", strings.NewReader(`{"enabled": true, "message": "Failover"}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if ro := conf.GetReadOnly(); !ro.Enabled || ro.AllowLogins || ro.Message != "Failover" {
+		t.Error("Read-only mode expected to be enabled")
+	}
+
+	// banner on html pages
+	request, _ = http.NewRequest("GET", "/oauth/reset_init_page", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if !strings.Contains(response.Body.String(), "Failover") {
+		t.Error("Page expected to contain the read-only banner")
+	}
+
+	// get state
+	request, _ = http.NewRequest("GET", "/api/readonly", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	result := &readOnlyData{}
+	json.Unmarshal(response.Body.Bytes(), result)
+	if !result.Enabled || result.RetryAfter <= 0 {
+		t.Error("Read-only state expected to be enabled with retry interval")
+	}
+}
@@ -9,20 +9,25 @@
 package web
 
 import (
-	"database/sql"
 	"fmt"
 	"html/template"
 	"net/http"
 	"net/url"
+	"time"
 
 	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/data"
 	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
 	"github.com/dchest/captcha"
 )
 
 const redirectionDelay = 8000
 
+// Activation e-mails can be requested 10 times per minute from one address. Requests
+// from internal networks are not limited per address.
+var activationResendIPLimiter = util.NewRateLimiter(10, time.Minute)
+
 type validateAccount struct {
 	*data.Account
 	*util.ValidationError
@@ -39,7 +44,7 @@ func RegistrationInit(w http.ResponseWriter, r *http.Request) {
 		PrintErrorHTML(w, r, "Invalid response type", http.StatusBadRequest)
 		return
 	}
-	createGrantRequest(w, r, "/oauth/registration_page")
+	createGrantRequest(w, r, conf.MakePath("/oauth/registration_page"))
 }
 
 // RegistrationPage displays entry fields required for the creation of a new gin account
@@ -143,6 +148,13 @@ func (rh *registration) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			valAccount.Message = valAccount.FieldErrors["password"]
 		}
 	}
+	warning, err := data.ValidateNewPassword(pw.Password, account.PasswordInputs()...)
+	if err != nil && pw.Password != "" {
+		valAccount.FieldErrors["password"] = err.Error()
+		if valAccount.Message == "" {
+			valAccount.Message = valAccount.FieldErrors["password"]
+		}
+	}
 	if len(pw.Password) > 512 || len(pw.PasswordControl) > 512 {
 		valAccount.FieldErrors["password"] =
 			fmt.Sprintf("Entry too long, please shorten to %d characters", 512)
@@ -172,7 +184,8 @@ func (rh *registration) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	valAccount.Account.SetPassword(pw.Password)
-	valAccount.Account.ActivationCode = sql.NullString{String: util.RandomToken(), Valid: true}
+	code := valAccount.Account.SetActivationCode()
+	valAccount.Account.IsApprovalPending = conf.GetRegistration().RequireApproval
 
 	err = account.Create()
 	if err != nil {
@@ -183,7 +196,31 @@ func (rh *registration) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
+	if warning != "" {
+		err = account.SetPasswordBreached(true)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err = sendActivation(account, code)
+	if err == nil && account.IsApprovalPending {
+		err = notifyPendingAccount(account)
+	}
+	if err != nil {
+		msg := "An error occurred trying to send registration e-mail. Please contact an administrator."
+		PrintErrorHTML(w, r, msg, http.StatusInternalServerError)
+		return
+	}
 
+	w.Header().Add("Cache-Control", "no-store")
+	urlValue := &url.Values{}
+	urlValue.Add("request_id", valAccount.RequestId)
+	http.Redirect(w, r, conf.MakePath("/oauth/registered_page")+"?"+urlValue.Encode(), http.StatusFound)
+}
+
+// sendActivation queues an e-mail containing a link to activate the account with the given code.
+func sendActivation(account *data.Account, code string) error {
 	tmplFields := &struct {
 		From    string
 		To      string
@@ -195,21 +232,95 @@ func (rh *registration) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	tmplFields.To = account.Email
 	tmplFields.Subject = "GIN account activation"
 	tmplFields.BaseUrl = conf.GetServerConfig().BaseURL
-	tmplFields.Code = account.ActivationCode.String
+	tmplFields.Code = code
 
 	content := util.MakeEmailTemplate("emailactivate.txt", tmplFields)
 	email := &data.Email{}
-	err = email.Create(util.NewStringSet(account.Email), content.Bytes())
+	return email.Create(util.NewStringSet(account.Email), content.Bytes())
+}
+
+// ResendActivationPage provides an input form for requesting the activation e-mail again.
+func ResendActivationPage(w http.ResponseWriter, r *http.Request) {
+	tmpl := conf.MakeTemplate("resendactivation.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", &credentialData{})
 	if err != nil {
-		msg := "An error occurred trying to send registration e-mail. Please contact an administrator."
-		PrintErrorHTML(w, r, msg, http.StatusInternalServerError)
+		panic(err)
+	}
+}
+
+// ResendActivation sends a new activation link to an account which was registered but not yet
+// activated, identified by its login or e-mail address. Links are sent at most once per
+// ActivationResendInterval (see registration settings) for the same account. In order to not
+// reveal which addresses belong to accounts, the response is the same whether a link was sent or not.
+func ResendActivation(w http.ResponseWriter, r *http.Request) {
+	key := rateLimitKey(r)
+	if !isInternalRequest(r) && !activationResendIPLimiter.Allow(key) {
+		printTooManyRequests(w, r, activationResendIPLimiter.RetryAfter(key))
+		return
+	}
+
+	credData := &credentialData{}
+	err := util.ReadFormIntoStruct(r, credData, true)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
 		return
 	}
 
+	if credData.Credential == "" {
+		credData.ErrMessage = "Please enter your login or e-mail address"
+		tmpl := conf.MakeTemplate("resendactivation.html")
+		w.Header().Add("Cache-Control", "no-store")
+		w.Header().Add("Content-Type", "text/html")
+		w.Header().Add("Warning", credData.ErrMessage)
+		err = tmpl.ExecuteTemplate(w, "layout", credData)
+		if err != nil {
+			panic(err)
+		}
+		return
+	}
+
+	// the entered login or e-mail address is not logged, it may belong to nobody or be mistyped
+	audit := conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event": "activation-resend",
+		"ip":    remoteIP(r),
+	})
+
+	account, code, err := data.RenewActivationCode(credData.Credential, conf.GetRegistration().ActivationResendInterval)
+	if data.KindOf(err) == data.ErrNotFound {
+		audit.Warn("No account waiting for activation or activation e-mail sent recently")
+	} else if err != nil {
+		panic(err)
+	} else {
+		err = sendActivation(account, code)
+		if err != nil {
+			msg := "An error occurred trying to send the activation e-mail. Please try again later."
+			PrintErrorHTML(w, r, msg, http.StatusInternalServerError)
+			return
+		}
+		audit.WithField("account", account.UUID).Info("Activation e-mail sent")
+	}
+
+	head := "Please check your e-mail"
+	message := "If an account waiting for activation is registered for this login or e-mail address, "
+	message += "an e-mail with a new activation link has been sent to its address. "
+	message += "Previous activation links are no longer valid.<br/><br/>"
+	message += fmt.Sprintf("Activation e-mails are sent at most once every %d minutes.",
+		int(conf.GetRegistration().ActivationResendInterval/time.Minute))
+
+	info := struct {
+		Header  string
+		Message template.HTML
+	}{head, template.HTML(message)}
+
+	tmpl := conf.MakeTemplate("success.html")
 	w.Header().Add("Cache-Control", "no-store")
-	urlValue := &url.Values{}
-	urlValue.Add("request_id", valAccount.RequestId)
-	http.Redirect(w, r, "/oauth/registered_page?"+urlValue.Encode(), http.StatusFound)
+	w.Header().Add("Content-Type", "text/html")
+	err = tmpl.ExecuteTemplate(w, "layout", info)
+	if err != nil {
+		panic(err)
+	}
 }
 
 // RegisteredPage displays gin account activation information and
@@ -230,7 +341,13 @@ func RegisteredPage(w http.ResponseWriter, r *http.Request) {
 	head := "Your gin account has been successfully registered!"
 	message := "You are only one step away from using your gin account! <br/><br/>"
 	message += "An e-mail with an activation code has been sent to your e-mail address, "
-	message += "please use the link within the e-mail to activate your account. <br/><br/>"
+	message += "please use the link within the e-mail to activate your account. "
+	message += fmt.Sprintf("If the e-mail does not arrive, you can <a href=\"%s\">request a new link</a>. <br/><br/>",
+		conf.MakePath("/oauth/registration/resend"))
+	if conf.GetRegistration().RequireApproval {
+		message += "Your account also needs to be approved by an administrator, "
+		message += "you will be notified by e-mail as soon as this happened. <br/><br/>"
+	}
 	message += "You will be automatically redirected to the gin main page, "
 	message += fmt.Sprintf("you can also use <a href=\"%s\">this link</a> to return",
 		conf.GetExternals().GinUiURL)
@@ -281,7 +398,12 @@ func Activation(w http.ResponseWriter, r *http.Request) {
 
 	head := "Your gin account has been successfully activated!"
 	message := fmt.Sprintf("Congratulation %s %s! ", account.FirstName, account.LastName)
-	message = fmt.Sprintf("The account for %s has been activated and can now be used.<br/><br/>", account.Login)
+	if account.IsApprovalPending {
+		message = fmt.Sprintf("The account for %s has been activated, but it can only be used ", account.Login)
+		message += "after it was approved by an administrator.<br/><br/>"
+	} else {
+		message = fmt.Sprintf("The account for %s has been activated and can now be used.<br/><br/>", account.Login)
+	}
 	message += "You will be automatically redirected to the gin login page, "
 	message += fmt.Sprintf("you can also use <a href=\"%s\">this link</a> <br/>to return to the gin main page",
 		conf.GetExternals().GinUiURL)
@@ -307,3 +429,42 @@ func Activation(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 }
+
+// VerifyEmail marks the e-mail address of the account with a matching verification code as verified.
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("verification_code")
+	if code == "" {
+		PrintErrorHTML(w, r, "E-mail verification code was absent", http.StatusBadRequest)
+		return
+	}
+
+	account, exists := data.GetAccountByEmailVerificationCode(code)
+	if !exists {
+		PrintErrorHTML(w, r, "Your request is invalid or outdated.", http.StatusNotFound)
+		return
+	}
+
+	err := account.VerifyEmail()
+	if err != nil {
+		panic(err)
+	}
+
+	head := "Your e-mail address has been verified!"
+	message := fmt.Sprintf("The e-mail address %s of the account %s has been successfully verified.<br/><br/>",
+		template.HTMLEscapeString(account.Email), template.HTMLEscapeString(account.Login))
+	message += fmt.Sprintf("You can use <a href=\"%s\">this link</a> to return to the gin main page.",
+		conf.GetExternals().GinUiURL)
+
+	info := struct {
+		Header  string
+		Message template.HTML
+	}{head, template.HTML(message)}
+
+	tmpl := conf.MakeTemplate("success.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err = tmpl.ExecuteTemplate(w, "layout", info)
+	if err != nil {
+		panic(err)
+	}
+}
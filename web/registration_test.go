@@ -14,7 +14,9 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/data"
 )
 
@@ -107,6 +109,7 @@ func TestRegistrationPage(t *testing.T) {
 	if response.Code != http.StatusOK {
 		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
 	}
+	checkAccessibility(t, response.Body.String())
 }
 
 func TestRegistrationHandler(t *testing.T) {
@@ -132,8 +135,8 @@ func TestRegistrationHandler(t *testing.T) {
 	body.Add("City", "City")
 	body.Add("Country", "Country")
 	body.Add("IsAffiliationPublic", "true")
-	body.Add("Password", "pw")
-	body.Add("PasswordControl", "pw")
+	body.Add("Password", "pw-secret")
+	body.Add("PasswordControl", "pw-secret")
 
 	emails, _ := data.GetQueuedEmails()
 	num := len(emails)
@@ -262,8 +265,8 @@ func TestRegisteredPage(t *testing.T) {
 func TestActivation(t *testing.T) {
 	handler := InitTestHttpHandler(t)
 	const activationURL = "/oauth/activation"
-	const activationCodeDisabled = "ac_b"
-	const activationCode = "ac_a"
+	const activationCodeDisabled = "ac_b.4102444800"
+	const activationCode = "ac_a.4102444800"
 
 	// Test missing query
 	request, _ := http.NewRequest("GET", activationURL, strings.NewReader(""))
@@ -302,9 +305,8 @@ func TestActivation(t *testing.T) {
 	if !exists {
 		t.Errorf("Error on fetching account by activation code '%s'", activationCode)
 	}
-	if account.ActivationCode.String != activationCode {
-		t.Errorf("Expected activation code to be '%s' but got '%s'",
-			activationCode, account.ActivationCode.String)
+	if !account.ActivationCode.Valid || account.ActivationCode.String == activationCode {
+		t.Errorf("Expected activation code to be stored hashed but got '%s'", account.ActivationCode.String)
 	}
 	accountLogin := account.Login
 
@@ -328,3 +330,98 @@ func TestActivation(t *testing.T) {
 			account.ActivationCode.String, account.ActivationCode.Valid)
 	}
 }
+
+func TestVerifyEmail(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	const verifyURL = "/oauth/verify_email"
+	const verificationCode = "EV_JOHN.4102444800"
+
+	// Test missing query
+	request, _ := http.NewRequest("GET", verifyURL, strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected StatusBadRequest on empty verification code but got '%d'", response.Code)
+	}
+
+	// Test invalid verification code
+	request, _ = http.NewRequest("GET", verifyURL+"?verification_code=iDoNotExist", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected StatusNotFound on invalid verification code but got '%d'", response.Code)
+	}
+
+	// Test valid verification
+	request, _ = http.NewRequest("GET", verifyURL+"?verification_code="+verificationCode, strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected StatusOK on valid verification code but got '%d'", response.Code)
+	}
+
+	account, ok := data.GetAccountByLogin("john")
+	if !ok {
+		t.Error("Account does not exist")
+	}
+	if !account.IsEmailVerified {
+		t.Error("E-mail address should be verified")
+	}
+	if account.EmailVerificationCode.Valid {
+		t.Error("E-mail verification code should be empty")
+	}
+}
+
+func TestResendActivation(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	post := func(credential string) *httptest.ResponseRecorder {
+		body := url.Values{}
+		body.Set("credential", credential)
+		request, _ := http.NewRequest("POST", "/oauth/registration/resend", strings.NewReader(body.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// form page
+	request, _ := http.NewRequest("GET", "/oauth/registration/resend", nil)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	// missing credential
+	response = post("")
+	if response.Code != http.StatusOK || response.Header().Get("Warning") == "" {
+		t.Errorf("Form with warning expected but was '%d'", response.Code)
+	}
+
+	// unknown account and account registered recently look the same
+	emails, _ := data.GetQueuedEmails()
+	num := len(emails)
+	unknown := post("nobody@example.com")
+	recent := post("inact_log1")
+	if unknown.Code != http.StatusOK || recent.Code != http.StatusOK || unknown.Body.String() != recent.Body.String() {
+		t.Error("Same response expected for unknown and recently registered accounts")
+	}
+	if emails, _ = data.GetQueuedEmails(); len(emails) != num {
+		t.Error("No activation e-mail expected")
+	}
+
+	// interval passed
+	interval := conf.GetRegistration().ActivationResendInterval
+	conf.GetRegistration().ActivationResendInterval = -time.Minute
+	defer func() { conf.GetRegistration().ActivationResendInterval = interval }()
+
+	response = post("inact_log1")
+	if response.Code != http.StatusOK || response.Body.String() != unknown.Body.String() {
+		t.Errorf("Generic response expected but was '%d'", response.Code)
+	}
+	emails, _ = data.GetQueuedEmails()
+	if len(emails) != num+1 || !emails[len(emails)-1].Recipient.Contains("email1@example.com") {
+		t.Error("Activation e-mail expected to be sent to inact_log1")
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+// BodyLimitHandler limits the size of request bodies to the limit configured for the route.
+// Requests which announce a larger body are refused with status 413, larger bodies without
+// content length can not be read beyond the limit.
+func BodyLimitHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := conf.GetRequestLimits().BodySize(routePath(r))
+
+		if r.ContentLength > limit {
+			msg := fmt.Sprintf("Request body exceeds the limit of %d bytes", limit)
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+				PrintErrorJSON(w, r, msg, http.StatusRequestEntityTooLarge)
+			} else {
+				PrintErrorHTML(w, r, msg, http.StatusRequestEntityTooLarge)
+			}
+			return
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON decodes the JSON body of a request into v. Bodies which are nested deeper or contain
+// longer arrays than configured are rejected. If configured, objects with fields unknown to
+// the struct v points to are rejected too.
+func decodeJSON(r *http.Request, v interface{}) error {
+	content, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	limits := conf.GetRequestLimits()
+	err = checkJSON(content, limits.MaxJSONDepth, limits.MaxJSONArrayLength)
+	if err != nil {
+		return err
+	}
+
+	err = json.Unmarshal(content, v)
+	if err != nil {
+		return err
+	}
+
+	if limits.RejectUnknownFields {
+		return checkJSONFields(content, v)
+	}
+	return nil
+}
+
+// checkJSON scans a JSON document and checks its nesting depth and the length of its arrays.
+func checkJSON(content []byte, maxDepth, maxArrayLength int) error {
+	type level struct {
+		array bool
+		count int
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(content))
+	stack := make([]level, 0, 8)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		delim, isDelim := tok.(json.Delim)
+		if n := len(stack); n > 0 && stack[n-1].array && delim != ']' {
+			stack[n-1].count++
+			if stack[n-1].count > maxArrayLength {
+				return fmt.Errorf("JSON arrays must not contain more than %d elements", maxArrayLength)
+			}
+		}
+		if !isDelim {
+			continue
+		}
+		switch delim {
+		case '{', '[':
+			stack = append(stack, level{array: delim == '['})
+			if len(stack) > maxDepth {
+				return fmt.Errorf("JSON must not be nested deeper than %d levels", maxDepth)
+			}
+		default:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// checkJSONFields checks whether all fields of a JSON object are known to the struct v points to.
+// Types with their own UnmarshalJSON are not checked.
+func checkJSONFields(content []byte, v interface{}) error {
+	if _, ok := v.(json.Unmarshaler); ok {
+		return nil
+	}
+	t := reflect.TypeOf(v)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	t = t.Elem()
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(content, &fields); err != nil {
+		return nil
+	}
+
+	for name := range fields {
+		if !hasJSONField(t, name) {
+			return errors.New("Unknown field '" + name + "'")
+		}
+	}
+	return nil
+}
+
+// hasJSONField checks whether a struct type has a field which is decoded from the JSON name.
+// Like encoding/json names are matched case-insensitive.
+func hasJSONField(t reflect.Type, name string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = field.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return true
+		}
+	}
+	return false
+}
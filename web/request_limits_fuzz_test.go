@@ -0,0 +1,52 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+//go:build go1.18
+// +build go1.18
+
+package web
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func FuzzDecodeJSON(f *testing.F) {
+	f.Add([]byte(`{"first_name":"Alice","last_name":"Goodwin","email":{"email":"alice@example.com"}}`))
+	f.Add([]byte(`{"description":"x","email_verified":null,"created_days":30,"unknown":1}`))
+	f.Add([]byte(strings.Repeat("[", 100) + strings.Repeat("]", 100)))
+	f.Add([]byte(`[` + strings.Repeat("1,", 2000) + `1]`))
+	f.Add([]byte(`{"a":}`))
+	f.Add([]byte(`]`))
+
+	limits := conf.GetRequestLimits()
+	reject := limits.RejectUnknownFields
+	limits.RejectUnknownFields = true
+	defer func() { limits.RejectUnknownFields = reject }()
+
+	f.Fuzz(func(t *testing.T, content []byte) {
+		request, _ := http.NewRequest("PUT", "/api/v1/accounts/alice", bytes.NewReader(content))
+		decodeJSON(request, &data.AccountMarshaler{})
+
+		filter := &struct {
+			Description   string `json:"description"`
+			EmailVerified *bool  `json:"email_verified"`
+			CreatedDays   int    `json:"created_days"`
+		}{}
+		request, _ = http.NewRequest("PUT", "/api/admin/account_filters/x", bytes.NewReader(content))
+		err := decodeJSON(request, filter)
+		if err == nil && checkJSON(content, limits.MaxJSONDepth, limits.MaxJSONArrayLength) != nil {
+			t.Error("Content exceeding the limits was decoded")
+		}
+	})
+}
@@ -0,0 +1,125 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/gorilla/mux"
+)
+
+func TestBodyLimitHandler(t *testing.T) {
+	var read int
+	router := mux.NewRouter()
+	router.Handle("/api/email_bounces", BodyLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+		read = len(content)
+	})))
+	router.Handle("/api/{name}", BodyLimitHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	})))
+
+	post := func(path string, size int, chunked bool) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", path, strings.NewReader(strings.Repeat("x", size)))
+		request.Header.Set("Content-Type", "application/json")
+		if chunked {
+			request.ContentLength = -1
+		}
+		response := httptest.NewRecorder()
+		router.ServeHTTP(response, request)
+		return response
+	}
+
+	// within the limit
+	if response := post("/api/foo", 1024, false); response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	// content length exceeds the limit
+	if response := post("/api/foo", 1<<20+1, false); response.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusRequestEntityTooLarge, response.Code)
+	}
+
+	// body without content length exceeds the limit
+	if response := post("/api/foo", 1<<20+1, true); response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// route with a larger limit
+	if response := post("/api/email_bounces", 1<<20+1, false); response.Code != http.StatusOK || read != 1<<20+1 {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	decode := func(body string, v interface{}) error {
+		request, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		return decodeJSON(request, v)
+	}
+
+	type payload struct {
+		Name   string        `json:"name"`
+		Values []interface{} `json:"values"`
+	}
+
+	p := &payload{}
+	err := decode(`{"name": "foo", "values": [1, [2, 3], {"a": 4}], "unknown": true}`, p)
+	if err != nil {
+		t.Error(err)
+	}
+	if p.Name != "foo" || len(p.Values) != 3 {
+		t.Errorf("Unexpected payload: %+v", p)
+	}
+
+	// too deep
+	body := strings.Repeat(`[`, 33) + strings.Repeat(`]`, 33)
+	if err = decode(`{"values": `+body+`}`, &payload{}); err == nil || !strings.Contains(err.Error(), "nested") {
+		t.Error("Deeply nested JSON expected to be rejected")
+	}
+	body = strings.Repeat(`{"a":`, 30) + `1` + strings.Repeat(`}`, 30)
+	if err = decode(`{"values": [`+body+`]}`, &payload{}); err != nil {
+		t.Error(err)
+	}
+
+	// too long
+	body = "[" + strings.Repeat("1,", 1000) + "1]"
+	if err = decode(`{"values": `+body+`}`, &payload{}); err == nil || !strings.Contains(err.Error(), "elements") {
+		t.Error("Long arrays expected to be rejected")
+	}
+	body = "[" + strings.Repeat("[1, 2],", 999) + "[1, 2]]"
+	if err = decode(`{"values": `+body+`}`, &payload{}); err != nil {
+		t.Error(err)
+	}
+
+	// invalid
+	if err = decode(`{"name": "foo"`, &payload{}); err == nil {
+		t.Error("Invalid JSON expected to be rejected")
+	}
+
+	// unknown fields
+	conf.GetRequestLimits().RejectUnknownFields = true
+	defer func() { conf.GetRequestLimits().RejectUnknownFields = false }()
+	if err = decode(`{"name": "foo", "unknown": true}`, &payload{}); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Error("Unknown field expected to be rejected")
+	}
+	if err = decode(`{"Name": "foo", "values": []}`, &payload{}); err != nil {
+		t.Error(err)
+	}
+}
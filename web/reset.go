@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"net/url"
 
 	"github.com/G-Node/gin-auth/conf"
 	"github.com/G-Node/gin-auth/data"
@@ -65,7 +66,7 @@ func ResetInit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	account, ok := data.SetPasswordReset(credData.Credential)
+	account, code, ok := data.SetPasswordReset(credData.Credential)
 	if !ok {
 		credData.ErrMessage = "Invalid login or e-mail address"
 		tmpl := conf.MakeTemplate("resetinit.html")
@@ -77,22 +78,7 @@ func ResetInit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tmplFields := &struct {
-		From    string
-		To      string
-		Subject string
-		BaseUrl string
-		Code    string
-	}{}
-	tmplFields.From = conf.GetSmtpCredentials().From
-	tmplFields.To = account.Email
-	tmplFields.Subject = "Your GIN Account Password Reset Request"
-	tmplFields.BaseUrl = conf.GetServerConfig().BaseURL
-	tmplFields.Code = account.ResetPWCode.String
-
-	content := util.MakeEmailTemplate("emailreset.txt", tmplFields)
-	email := &data.Email{}
-	err = email.Create(util.NewStringSet(account.Email), content.Bytes())
+	err = sendPasswordReset(account.Email, code, nil)
 	if err != nil {
 		msg := "An error occurred trying to send password reset e-mail. Please try again later."
 		PrintErrorHTML(w, r, msg, http.StatusInternalServerError)
@@ -124,6 +110,29 @@ func ResetInit(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// sendPasswordReset queues an e-mail containing a link to reset the password with the given code.
+// Additional params are appended to the link, e.g. the grant request which continues after the reset.
+func sendPasswordReset(to, code string, params url.Values) error {
+	tmplFields := &struct {
+		From     string
+		To       string
+		Subject  string
+		BaseUrl  string
+		Code     string
+		Continue string
+	}{}
+	tmplFields.From = conf.GetSmtpCredentials().From
+	tmplFields.To = to
+	tmplFields.Subject = "Your GIN Account Password Reset Request"
+	tmplFields.BaseUrl = conf.GetServerConfig().BaseURL
+	tmplFields.Code = code
+	tmplFields.Continue = params.Encode()
+
+	content := util.MakeEmailTemplate("emailreset.txt", tmplFields)
+	email := &data.Email{}
+	return email.Create(util.NewStringSet(to), content.Bytes())
+}
+
 // ResetPage checks whether a password reset code submitted by request URI query exists and is still valid.
 // Display enter password form if valid, an error message otherwise.
 func ResetPage(w http.ResponseWriter, r *http.Request) {
@@ -148,8 +157,12 @@ func ResetPage(w http.ResponseWriter, r *http.Request) {
 
 	hidden := &struct {
 		ResetCode string
+		Expired   bool
+		Reverify  bool
+		RequestID string
 		*util.ValidationError
-	}{code, &util.ValidationError{}}
+	}{code, r.Form.Get("expired") == "true", r.Form.Get("reverify") == "true", r.Form.Get("request_id"),
+		&util.ValidationError{}}
 
 	tmpl := conf.MakeTemplate("reset.html")
 	w.Header().Add("Cache-Control", "no-store")
@@ -169,6 +182,9 @@ func Reset(w http.ResponseWriter, r *http.Request) {
 
 	formData := &struct {
 		ResetCode       string
+		Expired         bool
+		Reverify        bool
+		RequestID       string
 		Password        string
 		PasswordControl string
 		*util.ValidationError
@@ -187,6 +203,11 @@ func Reset(w http.ResponseWriter, r *http.Request) {
 	}
 
 	formData.ValidationError = &util.ValidationError{FieldErrors: make(map[string]string)}
+	warning, err := data.ValidateNewPassword(formData.Password, account.PasswordInputs()...)
+	if err != nil {
+		formData.FieldErrors["password"] = err.Error()
+		formData.Message = formData.FieldErrors["password"]
+	}
 	if formData.Password != formData.PasswordControl {
 		formData.FieldErrors["password"] = "Provided password did not match password control"
 		formData.Message = formData.FieldErrors["password"]
@@ -233,14 +254,27 @@ func Reset(w http.ResponseWriter, r *http.Request) {
 
 	head := "Success!"
 	message := "Your password has been reset, you can now login using your new password!<br/><br/>"
-	message += "You will be automatically redirected to the gin login page, "
-	message += fmt.Sprintf("you can also use <a href=\"%s\">this link</a> to return to the gin main page",
-		conf.GetExternals().GinUiURL)
-	message += " to login manually or continue browsing the available public repositories."
+	if warning != "" {
+		message += template.HTMLEscapeString(warning) + "<br/><br/>"
+	}
 
-	// Add java script block to start login redirection round trip to login via gin-ui.
-	// Round trip is required to ensure a proper grant request from the gin-ui client.
-	message += redirectionScript(conf.GetExternals().GinUiURL+"/oauth/authorize", redirectionDelay)
+	// The login interrupted by an expired password or a re-verification continues with its grant request, such that
+	// the user returns to the page where the login was started.
+	if request, ok := data.GetGrantRequest(formData.RequestID); ok {
+		loginURL := conf.MakePath("/oauth/login_page") + "?request_id=" + url.QueryEscape(request.Token)
+		message += "You will be automatically redirected to the login page, "
+		message += fmt.Sprintf("you can also use <a href=\"%s\">this link</a> to continue.", loginURL)
+		message += redirectionScript(loginURL, redirectionDelay)
+	} else {
+		message += "You will be automatically redirected to the gin login page, "
+		message += fmt.Sprintf("you can also use <a href=\"%s\">this link</a> to return to the gin main page",
+			conf.GetExternals().GinUiURL)
+		message += " to login manually or continue browsing the available public repositories."
+
+		// Add java script block to start login redirection round trip to login via gin-ui.
+		// Round trip is required to ensure a proper grant request from the gin-ui client.
+		message += redirectionScript(conf.GetExternals().GinUiURL+"/oauth/authorize", redirectionDelay)
+	}
 
 	safeMessage := template.HTML(message)
 
@@ -29,6 +29,7 @@ func TestResetInitPage(t *testing.T) {
 	if response.Code != http.StatusOK {
 		t.Errorf("Expected StatusOK but got '%d'", response.Code)
 	}
+	checkAccessibility(t, response.Body.String())
 }
 
 func TestResetInit(t *testing.T) {
@@ -134,8 +135,8 @@ func TestResetPage(t *testing.T) {
 	const resetURL = "/oauth/reset_page"
 	const codeKey = "reset_code"
 	const codeInvalid = "iDoNotExist"
-	const codeDisabled = "rc_c"
-	const codeValid = "rc_a"
+	const codeDisabled = "rc_c.4102444800"
+	const codeValid = "rc_a.4102444800"
 
 	// Test missing password reset code
 	request, _ := http.NewRequest("GET", resetURL, strings.NewReader(""))
@@ -181,6 +182,7 @@ func TestResetPage(t *testing.T) {
 	if response.Code != http.StatusOK {
 		t.Errorf("Expected StatusOK on valid reset code but got '%d'", response.Code)
 	}
+	checkAccessibility(t, response.Body.String())
 }
 
 func TestReset(t *testing.T) {
@@ -188,9 +190,9 @@ func TestReset(t *testing.T) {
 	const resetURL = "/oauth/reset"
 	const codeKey = "ResetCode"
 	const codeInvalid = "iDoNotExist"
-	const codeDisabled = "rc_c"
-	const codeValid = "rc_a"
-	const codeValidInactive = "rc_b"
+	const codeDisabled = "rc_c.4102444800"
+	const codeValid = "rc_a.4102444800"
+	const codeValidInactive = "rc_b.4102444800"
 
 	// Test empty body
 	request, _ := http.NewRequest("POST", resetURL, strings.NewReader(""))
@@ -288,8 +290,8 @@ func TestReset(t *testing.T) {
 	}
 	pwHash := account.PWHash
 
-	mkBody.Set("Password", "pw")
-	mkBody.Set("PasswordControl", "pw")
+	mkBody.Set("Password", "pw-secret")
+	mkBody.Set("PasswordControl", "pw-secret")
 	request, _ = http.NewRequest("POST", resetURL, strings.NewReader(mkBody.Encode()))
 	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 	response = httptest.NewRecorder()
@@ -339,7 +341,7 @@ func TestReset(t *testing.T) {
 	if account.PWHash == pwHash {
 		t.Errorf("Password of Account with id '%s' has not been updated", id)
 	}
-	if !account.VerifyPassword("pw") {
+	if !account.VerifyPassword("pw-secret") {
 		t.Error("Password has not been properly updated")
 	}
 	if account.ResetPWCode.String != "" {
@@ -349,3 +351,42 @@ func TestReset(t *testing.T) {
 		t.Errorf("Activation code of Account with id '%s' has not been removed", id)
 	}
 }
+
+func TestResetContinuesLogin(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	_, code, ok := data.SetPasswordReset("alice")
+	if !ok {
+		t.Fatal("Unable to set password reset code")
+	}
+
+	// the reset page keeps the grant request of an interrupted login
+	request, _ := http.NewRequest("GET", "/oauth/reset_page?expired=true&request_id=U7JIKKYI&reset_code="+
+		url.QueryEscape(code), strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), `name="request_id" value="U7JIKKYI"`) {
+		t.Error("Grant request expected in reset form")
+	}
+
+	// after the reset the login continues with the grant request
+	body := &url.Values{}
+	body.Add("ResetCode", code)
+	body.Add("Expired", "true")
+	body.Add("RequestID", "U7JIKKYI")
+	body.Add("Password", "pw-secret-alice")
+	body.Add("PasswordControl", "pw-secret-alice")
+	request, _ = http.NewRequest("POST", "/oauth/reset", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "/oauth/login_page?request_id=U7JIKKYI") {
+		t.Error("Redirect to the login page of the grant request expected")
+	}
+}
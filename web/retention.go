@@ -0,0 +1,63 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/Sirupsen/logrus"
+)
+
+// ApplyRetention is a handler which purges all data older than configured by the retention
+// policies and returns the number of purged rows per policy as JSON. With the query parameter
+// 'dry_run' the purge is rolled back and the rows which would be purged are returned.
+func ApplyRetention(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	dry, err := isDryRun(r)
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	reports, err := data.ApplyRetention(dry)
+	if err != nil {
+		panic(err)
+	}
+
+	if !dry {
+		for _, report := range reports {
+			if report.Rows > 0 {
+				conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+					"event":  "retention",
+					"policy": report.Policy,
+					"rows":   report.Rows,
+					"admin":  oauth.Token.AccountUUID.String,
+					"ip":     remoteIP(r),
+				}).Info("Purged data by retention policy")
+			}
+		}
+	}
+
+	result := &struct {
+		DryRun   bool                   `json:"dry_run"`
+		Policies []data.RetentionReport `json:"policies"`
+	}{dry, reports}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(result)
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestApplyRetention(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	config := conf.GetRetention()
+	policies := config.Policies
+	defer func() { config.Policies = policies }()
+	config.Policies = map[string]time.Duration{"account_history": 30 * 24 * time.Hour}
+
+	// no admin scope
+	request, _ := http.NewRequest("POST", "/api/retention?dry_run=true", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid dry run
+	request, _ = http.NewRequest("POST", "/api/retention?dry_run=maybe", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	result := &struct {
+		DryRun   bool                   `json:"dry_run"`
+		Policies []data.RetentionReport `json:"policies"`
+	}{}
+
+	// dry run
+	request, _ = http.NewRequest("POST", "/api/retention?dry_run=true", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	err := json.NewDecoder(response.Body).Decode(result)
+	if err != nil {
+		t.Error(err)
+	}
+	if !result.DryRun || len(result.Policies) != 1 || result.Policies[0].Rows != 2 {
+		t.Errorf("Two history entries expected to be purged by a dry run: %+v", result)
+	}
+	if len(data.ListAccountHistory("bf431618-f696-4dca-a95d-882618ce4ef9")) != 2 {
+		t.Error("Dry run should not purge data")
+	}
+
+	// all ok
+	request, _ = http.NewRequest("POST", "/api/retention", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if len(data.ListAccountHistory("bf431618-f696-4dca-a95d-882618ce4ef9")) != 0 {
+		t.Error("History of alice expected to be purged")
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// reverificationJSON is the JSON representation of a re-verification campaign and its progress.
+// Accounts is only contained in the response for a single campaign.
+type reverificationJSON struct {
+	UUID            string                     `json:"uuid"`
+	Reason          string                     `json:"reason"`
+	RequireEmail    bool                       `json:"email"`
+	RequirePassword bool                       `json:"password"`
+	Label           string                     `json:"label,omitempty"`
+	Group           string                     `json:"group,omitempty"`
+	Filter          string                     `json:"filter,omitempty"`
+	CreatedBy       *string                    `json:"created_by,omitempty"`
+	CreatedAt       time.Time                  `json:"created_at"`
+	Progress        reverificationProgressJSON `json:"progress"`
+	Accounts        []reverificationStatusJSON `json:"accounts,omitempty"`
+}
+
+type reverificationProgressJSON struct {
+	Accounts        int `json:"accounts"`
+	EmailVerified   int `json:"email_verified"`
+	PasswordChanged int `json:"password_changed"`
+	Completed       int `json:"completed"`
+}
+
+type reverificationStatusJSON struct {
+	Login             string     `json:"login"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at"`
+	PasswordChangedAt *time.Time `json:"password_changed_at"`
+	CompletedAt       *time.Time `json:"completed_at"`
+}
+
+// ListReverifications is a handler which returns all re-verification campaigns with their progress
+// as JSON, newest first.
+func ListReverifications(w http.ResponseWriter, r *http.Request) {
+	campaigns := data.ListReverificationCampaigns()
+	marshal := make([]*reverificationJSON, 0, len(campaigns))
+	for i := range campaigns {
+		marshal = append(marshal, reverificationMarshaler(&campaigns[i]))
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// GetReverification is a handler which returns a re-verification campaign together with the
+// progress of each selected account as JSON.
+func GetReverification(w http.ResponseWriter, r *http.Request) {
+	campaign, ok := data.GetReverificationCampaign(mux.Vars(r)["uuid"])
+	if !ok {
+		PrintErrorJSON(w, r, "The requested re-verification campaign does not exist", http.StatusNotFound)
+		return
+	}
+
+	marshal := reverificationMarshaler(campaign)
+	accounts := campaign.Accounts()
+	marshal.Accounts = make([]reverificationStatusJSON, len(accounts))
+	for i := range accounts {
+		status := &marshal.Accounts[i]
+		status.Login = accounts[i].Login
+		if accounts[i].EmailVerifiedAt.Valid {
+			status.EmailVerifiedAt = &accounts[i].EmailVerifiedAt.Time
+		}
+		if accounts[i].PasswordChangedAt.Valid {
+			status.PasswordChangedAt = &accounts[i].PasswordChangedAt.Time
+		}
+		if accounts[i].CompletedAt.Valid {
+			status.CompletedAt = &accounts[i].CompletedAt.Time
+		}
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// CreateReverification is a handler which starts a re-verification campaign. The selected accounts
+// have to confirm their e-mail address and/or change their password at their next login.
+func CreateReverification(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	body := &struct {
+		Reason         string   `json:"reason"`
+		Email          bool     `json:"email"`
+		Password       bool     `json:"password"`
+		Logins         []string `json:"logins"`
+		Label          string   `json:"label"`
+		Group          string   `json:"group"`
+		Filter         string   `json:"filter"`
+		RevokeSessions bool     `json:"revoke_sessions"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Invalid re-verification campaign data", http.StatusBadRequest)
+		return
+	}
+
+	campaign := &data.ReverificationCampaign{
+		Reason:          body.Reason,
+		RequireEmail:    body.Email,
+		RequirePassword: body.Password,
+		Logins:          body.Logins,
+		Label:           body.Label,
+		GroupName:       body.Group,
+		FilterName:      body.Filter,
+		CreatedBy:       sql.NullString{String: oauth.Token.AccountUUID.String, Valid: true},
+		RevokeSessions:  body.RevokeSessions,
+	}
+	err = campaign.Create()
+	if err != nil {
+		if _, ok := err.(*util.ValidationError); ok {
+			PrintErrorJSON(w, r, err, http.StatusBadRequest)
+			return
+		}
+		panic(err)
+	}
+
+	marshal := reverificationMarshaler(campaign)
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":    "reverification-started",
+		"campaign": campaign.UUID,
+		"accounts": marshal.Progress.Accounts,
+		"email":    campaign.RequireEmail,
+		"password": campaign.RequirePassword,
+		"revoked":  campaign.RevokeSessions,
+		"admin":    reverificationAdmin(campaign),
+		"ip":       remoteIP(r),
+	}).Warn("Re-verification campaign started")
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// reverificationAdmin returns the login of the administrator who started a campaign.
+func reverificationAdmin(campaign *data.ReverificationCampaign) string {
+	if campaign.CreatedBy.Valid {
+		if acc, ok := data.GetAccount(campaign.CreatedBy.String); ok {
+			return acc.Login
+		}
+	}
+	return ""
+}
+
+// reverificationMarshaler prepares a campaign and its progress for JSON output.
+func reverificationMarshaler(campaign *data.ReverificationCampaign) *reverificationJSON {
+	progress := campaign.Progress()
+	marshal := &reverificationJSON{
+		UUID:            campaign.UUID,
+		Reason:          campaign.Reason,
+		RequireEmail:    campaign.RequireEmail,
+		RequirePassword: campaign.RequirePassword,
+		Label:           campaign.Label,
+		Group:           campaign.GroupName,
+		Filter:          campaign.FilterName,
+		CreatedAt:       campaign.CreatedAt,
+		Progress: reverificationProgressJSON{
+			Accounts:        progress.Accounts,
+			EmailVerified:   progress.EmailVerified,
+			PasswordChanged: progress.PasswordChanged,
+			Completed:       progress.Completed,
+		},
+	}
+	if admin := reverificationAdmin(campaign); admin != "" {
+		marshal.CreatedBy = &admin
+	}
+	return marshal
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func createReverification(handler http.Handler, body string) *httptest.ResponseRecorder {
+	request, _ := http.NewRequest("POST", "/api/reverifications", strings.NewReader(body))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	request.Header.Set("Content-Type", "application/json")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	return response
+}
+
+func TestCreateReverification(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	request, _ := http.NewRequest("POST", "/api/reverifications", strings.NewReader(`{}`))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid campaign
+	response = createReverification(handler, `{"reason": "", "logins": ["bob"]}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	response = createReverification(handler, `{"reason": "Leaked password", "password": true, "logins": ["bob"]}`)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusCreated, response.Code)
+	}
+	campaign := &reverificationJSON{}
+	err := json.NewDecoder(response.Body).Decode(campaign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if campaign.Progress.Accounts != 1 || campaign.CreatedBy == nil || *campaign.CreatedBy != "alice" {
+		t.Errorf("Campaign for one account created by alice expected: %+v", campaign)
+	}
+
+	request, _ = http.NewRequest("GET", "/api/reverifications/"+campaign.UUID, strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	campaign = &reverificationJSON{}
+	err = json.NewDecoder(response.Body).Decode(campaign)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(campaign.Accounts) != 1 || campaign.Accounts[0].Login != "bob" || campaign.Accounts[0].CompletedAt != nil {
+		t.Errorf("Pending account bob expected: %+v", campaign.Accounts)
+	}
+
+	request, _ = http.NewRequest("GET", "/api/reverifications", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	campaigns := make([]reverificationJSON, 0)
+	err = json.NewDecoder(response.Body).Decode(&campaigns)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(campaigns) != 1 || campaigns[0].Reason != "Leaked password" {
+		t.Errorf("One campaign expected but was %+v", campaigns)
+	}
+}
+
+func TestLoginWithReverification(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	response := createReverification(handler, `{"reason": "Leaked password", "email": true, "password": true, "logins": ["alice"]}`)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusCreated, response.Code)
+	}
+
+	login := func() *httptest.ResponseRecorder {
+		body := &url.Values{}
+		body.Add("request_id", "U7JIKKYI")
+		body.Add("login", "alice")
+		body.Add("password", "testtest")
+		request, _ := http.NewRequest("POST", "/oauth/login", strings.NewReader(body.Encode()))
+		request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// the e-mail address is confirmed first
+	response = login()
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "confirm your e-mail address") {
+		t.Error("Page asking for an e-mail confirmation expected")
+	}
+	if len(response.Result().Cookies()) != 0 {
+		t.Error("No session expected for a pending re-verification")
+	}
+
+	alice, _ := data.GetAccountByLogin("alice")
+	err := alice.VerifyEmail()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// then the password is changed using a link sent by e-mail
+	emails, _ := data.GetQueuedEmails()
+	num := len(emails)
+	response = login()
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if response.Header().Get("Location") != "" || strings.Contains(response.Body.String(), "reset_code") {
+		t.Error("Reset code must only be sent by e-mail")
+	}
+	emails, _ = data.GetQueuedEmails()
+	if len(emails) != num+1 || !strings.Contains(string(emails[len(emails)-1].Content), "reverify=true") {
+		t.Error("E-mail with a password reset link expected")
+	}
+	if len(response.Result().Cookies()) != 0 {
+		t.Error("No session expected for a pending re-verification")
+	}
+}
+
+func TestReverificationWithoutRevokedSessions(t *testing.T) {
+	const refreshTokenBob = "4FKJVX3K"
+
+	handler := InitTestHttpHandler(t)
+
+	response := createReverification(handler, `{"reason": "Leaked password", "email": true, "logins": ["bob"]}`)
+	if response.Code != http.StatusCreated {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusCreated, response.Code)
+	}
+
+	// the session of bob does not continue the grant request
+	request, _ := http.NewRequest("GET", "/oauth/login", strings.NewReader(""))
+	request.URL.RawQuery = url.Values{"request_id": []string{"U7JIKKYI"}}.Encode()
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "confirm your e-mail address") {
+		t.Error("Page asking for an e-mail confirmation expected")
+	}
+
+	// the refresh token of bob is rejected
+	body := &url.Values{}
+	body.Add("refresh_token", refreshTokenBob)
+	body.Add("grant_type", "refresh_token")
+	request, _ = http.NewRequest("POST", "/oauth/token", strings.NewReader(body.Encode()))
+	request.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+	request.SetBasicAuth("gin", "secret")
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+}
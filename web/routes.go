@@ -11,65 +11,208 @@ package web
 import (
 	"net/http"
 
+	"github.com/G-Node/gin-auth/conf"
 	"github.com/dchest/captcha"
 	"github.com/gorilla/mux"
 )
 
 // RegisterRoutes adds all registered routes for this app to the
 // main router. This should make it easier to get a quick overview
-// over all routes.
+// over all routes. Routes are registered in groups, each group applies
+// the same middleware chain to all of its routes.
 func RegisterRoutes(r *mux.Router) {
 	// all for /oauth
-	oauth := r.PathPrefix("/oauth").Subrouter()
-	oauth.HandleFunc("/authorize", Authorize).
-		Methods("GET")
-	oauth.HandleFunc("/login_page", LoginPage).
-		Methods("GET")
-	oauth.HandleFunc("/login", LoginWithCredentials).
-		Methods("POST")
-	oauth.HandleFunc("/login", LoginWithSession).
-		Methods("GET")
-	oauth.HandleFunc("/approve_page", ApprovePage).
-		Methods("GET")
-	oauth.HandleFunc("/approve", Approve).
-		Methods("POST")
-	oauth.HandleFunc("/logout/{token}", Logout).
-		Methods("GET")
-	oauth.HandleFunc("/registration_init", RegistrationInit).Methods("GET")
-	oauth.HandleFunc("/registration_page", RegistrationPage).Methods("GET")
-	oauth.Handle("/registration", RegistrationHandler(captcha.VerifyString)).Methods("POST")
-	oauth.HandleFunc("/registered_page", RegisteredPage).Methods("GET")
-	oauth.HandleFunc("/activation", Activation).Methods("GET")
-	oauth.HandleFunc("/reset_init_page", ResetInitPage).Methods("GET")
-	oauth.HandleFunc("/reset_init", ResetInit).Methods("POST")
-	oauth.HandleFunc("/reset_page", ResetPage).Methods("GET")
-	oauth.HandleFunc("/reset", Reset).Methods("POST")
-	oauth.HandleFunc("/token", Token).
-		Methods("POST")
-	oauth.HandleFunc("/validate/{token}", Validate).
-		Methods("GET")
-	// all for /api
-	api := r.PathPrefix("/api").Subrouter()
-	api.Handle("/accounts", OAuthHandlerPermissive()(http.HandlerFunc(ListAccounts))).
-		Methods("GET")
-	api.Handle("/accounts/{login}", OAuthHandlerPermissive()(http.HandlerFunc(GetAccount))).
-		Methods("GET")
-	api.Handle("/accounts/{login}", OAuthHandler("account-write", "account-admin")(http.HandlerFunc(UpdateAccount))).
-		Methods("PUT")
-	api.Handle("/accounts/{login}/password", OAuthHandler("account-write")(http.HandlerFunc(UpdateAccountPassword))).
-		Methods("PUT")
-	api.Handle("/accounts/{login}/email", OAuthHandler("account-write")(http.HandlerFunc(UpdateAccountEmail))).
-		Methods("PUT")
-	api.Handle("/accounts/{login}/keys", OAuthHandler("account-read", "account-admin")(http.HandlerFunc(ListAccountKeys))).
-		Methods("GET")
-	api.Handle("/accounts/{login}/keys", OAuthHandler("account-write")(http.HandlerFunc(CreateKey))).
-		Methods("POST")
-	api.Handle("/keys", http.HandlerFunc(GetKey)).
-		Methods("GET")
-	api.Handle("/keys", OAuthHandler("account-write")(http.HandlerFunc(DeleteKey))).
-		Methods("DELETE")
+	oauth := NewRouteGroup(r.PathPrefix("/oauth").Subrouter(), BodyLimitHandler)
+	oauth.HandleFunc("/authorize", Authorize, "GET")
+	oauth.HandleFunc("/login_page", LoginPage, "GET")
+	oauth.Handle("/login", LoginHandler(captcha.VerifyString), "POST")
+	oauth.HandleFunc("/login", LoginWithSession, "GET")
+	oauth.HandleFunc("/json_login", JSONLogin, "POST")
+	magic := oauth.With(FeatureHandler(featureMagicLinkLogin))
+	magic.HandleFunc("/magic_link_page", MagicLinkPage, "GET")
+	magic.HandleFunc("/magic_link", MagicLinkInit, "POST")
+	magic.HandleFunc("/magic_login", MagicLoginPage, "GET")
+	magic.HandleFunc("/magic_login", MagicLogin, "POST")
+	oauth.HandleFunc("/approve_page", ApprovePage, "GET")
+	oauth.HandleFunc("/approve", Approve, "POST")
+	oauth.HandleFunc("/logout/{token}", Logout, "GET")
+	oauth.HandleFunc("/logout", EndSession, "GET", "POST")
+	oauth.HandleFunc("/registration_init", RegistrationInit, "GET")
+	oauth.HandleFunc("/registration_page", RegistrationPage, "GET")
+	oauth.Handle("/registration", RegistrationHandler(captcha.VerifyString), "POST")
+	oauth.HandleFunc("/registered_page", RegisteredPage, "GET")
+	oauth.HandleFunc("/registration/resend", ResendActivationPage, "GET")
+	oauth.HandleFunc("/registration/resend", ResendActivation, "POST")
+	oauth.HandleFunc("/activation", Activation, "GET")
+	oauth.HandleFunc("/verify_email", VerifyEmail, "GET")
+	oauth.HandleFunc("/reset_init_page", ResetInitPage, "GET")
+	oauth.HandleFunc("/reset_init", ResetInit, "POST")
+	oauth.HandleFunc("/reset_page", ResetPage, "GET")
+	oauth.HandleFunc("/reset", Reset, "POST")
+	oauth.HandleFunc("/recover_page", RecoverPage, "GET")
+	oauth.HandleFunc("/recover", Recover, "POST")
+	oauth.HandleFunc("/confirm_scope", ConfirmScopeRequest, "GET")
+	oauth.HandleFunc("/token", Token, "POST")
+	oauth.HandleFunc("/validate/{token}", Validate, "GET")
+	oauth.HandleFunc("/validate", ValidateBatch, "POST")
+
+	// pages which require a login via session cookie
+	session := oauth.With(SessionHandler)
+	session.HandleFunc("/pending_accounts", PendingAccountsPage, "GET")
+	session.HandleFunc("/pending_accounts", PendingAccountsAction, "POST")
+	session.HandleFunc("/groups/{name}", GroupPage, "GET")
+	session.HandleFunc("/groups/{name}", GroupAction, "POST")
+	session.HandleFunc("/groups/{name}/invitation", GroupInvitationPage, "GET")
+	session.HandleFunc("/groups/{name}/invitation", GroupInvitationAction, "POST")
+	session.HandleFunc("/sessions", SessionsPage, "GET")
+	session.HandleFunc("/sessions", SessionsAction, "POST")
+	session.HandleFunc("/apps", AuthorizedAppsPage, "GET")
+	session.HandleFunc("/consent_receipts", ConsentReceipts, "GET")
+	session.HandleFunc("/scopes", ScopesPage, "GET")
+	session.HandleFunc("/scopes", RequestScope, "POST")
+	session.HandleFunc("/scope_requests", ScopeRequestsPage, "GET")
+	session.HandleFunc("/scope_requests", ScopeRequestsAction, "POST")
+	session.HandleFunc("/client_scope_requests", ClientScopeRequestsPage, "GET")
+	session.HandleFunc("/client_scope_requests", ClientScopeRequestsAction, "POST")
+	session.HandleFunc("/client_stats", ClientStatsPage, "GET")
+	session.HandleFunc("/announcements", AnnouncementsPage, "GET")
+	session.HandleFunc("/announcements", AnnouncementsAction, "POST")
+
+	// all for /api, the current version and the deprecated routes without version prefix
+	v1Router := r.PathPrefix("/api/v" + apiVersion).Subrouter()
+	v1 := NewRouteGroup(v1Router, BodyLimitHandler, APIVersionHandler(apiVersion))
+	registerAPIRoutes(v1)
+	registerAPILinks(v1Router)
+	unversioned := NewRouteGroup(r.PathPrefix("/api").Subrouter(), BodyLimitHandler, APIVersionHandler(""),
+		DeprecationHandler(unversionedAPI))
+	registerAPIRoutes(unversioned)
+
+	// documentation for developers of clients
+	developer := NewRouteGroup(r.PathPrefix("/developer").Subrouter())
+	developer.HandleFunc("/scopes", DeveloperScopesPage, "GET")
+
+	// backlogs of background jobs for monitoring
+	r.HandleFunc("/metrics", Metrics).Methods("GET")
+
+	// downloads of the local blob storage via signed URLs
+	r.HandleFunc("/blobs/{key:.+}", ServeBlob).Methods("GET", "HEAD")
+
+	// static files
+	r.PathPrefix(conf.StaticPath).Handler(http.HandlerFunc(StaticFiles)).Methods("GET", "HEAD")
 
 	// captcha service
 	cpt := r.PathPrefix("/captcha").Subrouter()
 	cpt.Handle("/{id}", captcha.Server(captcha.StdWidth, captcha.StdHeight)).Methods("GET")
 }
+
+// registerAPIRoutes adds all routes of the API to a group, which is registered
+// once for each path prefix of the API.
+func registerAPIRoutes(api *RouteGroup) {
+	api.HandleFunc("/accounts/check", CheckAccount, "GET")
+	api.HandleFunc("/password-strength", PasswordStrength, "POST")
+	api.HandleFunc("/ssh_certificates/ca", GetSSHCertificateAuthority, "GET")
+	api.HandleFunc("/authorize-access", AuthorizeAccess, "POST")
+	api.HandleFunc("/token_revocations", ListTokenRevocations, "GET")
+	api.HandleFunc("/keys", GetKey, "GET")
+	api.HandleFunc("/email_bounces", ReportEmailBounces, "POST")
+	api.HandleFunc("/maintenance", GetMaintenance, "GET")
+	api.HandleFunc("/readonly", GetReadOnly, "GET")
+	api.HandleFunc("/consent/{request_id}", GetConsent, "GET")
+	api.HandleFunc("/consent/{request_id}", DecideConsent, "POST")
+	api.HandleFunc("/scopes", ListScopes, "GET")
+	api.HandleFunc("/clients/{id}/scope_requests", ListClientScopeRequests, "GET")
+	api.HandleFunc("/clients/{id}/scope_requests", RequestClientScope, "POST")
+	if conf.GetServerConfig().TestMode {
+		api.HandleFunc("/test/reset", ResetFixtures, "POST")
+	}
+
+	// optional bearer token
+	permissive := api.With(OAuthHandlerPermissive())
+	permissive.HandleFunc("/accounts", ListAccounts, "GET")
+	permissive.HandleFunc("/accounts/{account}", GetAccount, "GET")
+
+	// bearer token for the own account or with admin scope
+	read := api.With(OAuthHandler("account-read", "account-admin", "admin-read"))
+	read.HandleFunc("/accounts/{account}/login_settings", GetLoginSettings, "GET")
+	read.HandleFunc("/accounts/{account}/privacy_settings", GetPrivacySettings, "GET")
+	read.HandleFunc("/accounts/{account}/locale_settings", GetLocaleSettings, "GET")
+	read.HandleFunc("/accounts/{account}/notifications", GetNotificationSettings, "GET")
+	read.HandleFunc("/accounts/{account}/usage", GetAccountUsage, "GET")
+	read.HandleFunc("/accounts/{account}/keys", ListAccountKeys, "GET")
+	read.HandleFunc("/groups/{name}", GetGroup, "GET")
+	read.HandleFunc("/groups/{name}/members", ListGroupMembers, "GET")
+
+	write := api.With(OAuthHandler("account-write", "account-admin", "admin-account-write"))
+	write.HandleFunc("/accounts/{account}", UpdateAccount, "PUT")
+	write.HandleFunc("/accounts/{account}/login_settings", UpdateLoginSettings, "PUT")
+	write.HandleFunc("/accounts/{account}/privacy_settings", UpdatePrivacySettings, "PUT")
+	write.HandleFunc("/accounts/{account}/locale_settings", UpdateLocaleSettings, "PUT")
+	write.HandleFunc("/accounts/{account}/notifications", UpdateNotificationSettings, "PUT")
+	write.HandleFunc("/groups/{name}/members/{account}", UpdateGroupMember, "PUT")
+	write.HandleFunc("/groups/{name}/members/{account}", RemoveGroupMember, "DELETE")
+	write.HandleFunc("/groups/{name}/teams", CreateGroupTeam, "POST")
+
+	// bearer token for the own account only
+	own := api.With(OAuthHandler("account-write"))
+	own.HandleFunc("/accounts/{account}/password", UpdateAccountPassword, "PUT")
+	own.HandleFunc("/accounts/{account}/email", UpdateAccountEmail, "PUT")
+	own.HandleFunc("/accounts/{account}/email/verification", ResendEmailVerification, "POST")
+	own.HandleFunc("/accounts/{account}/keys", CreateKey, "POST")
+	own.HandleFunc("/keys", DeleteKey, "DELETE")
+	own.HandleFunc("/groups", CreateGroup, "POST")
+	own.HandleFunc("/groups/{name}/invitation", AcceptGroupInvitation, "POST")
+	own.HandleFunc("/groups/{name}/invitation", DeclineGroupInvitation, "DELETE")
+
+	sshCert := api.With(OAuthHandler("ssh-cert"), EmailVerifiedHandler)
+	sshCert.HandleFunc("/ssh_certificates", IssueSSHCertificate, "POST")
+
+	// bearer token with admin scope: 'account-admin' grants all admin routes, the finer admin
+	// scopes (see data.ScopeAdminRead) grant parts of them
+	adminRead := api.With(OAuthHandler("account-admin", "admin-read"), PolicyHandler)
+	adminRead.HandleFunc("/accounts/{account}/notes", GetAccountNotes, "GET")
+	adminRead.HandleFunc("/pending_accounts", ListPendingAccounts, "GET")
+	adminRead.HandleFunc("/scope_requests", ListScopeRequests, "GET")
+	adminRead.HandleFunc("/usage", ListUsage, "GET")
+	adminRead.HandleFunc("/email_bounces", ListEmailBounces, "GET")
+	adminRead.HandleFunc("/admin/schema", GetSchema, "GET")
+	adminRead.HandleFunc("/announcements", ListAnnouncements, "GET")
+	adminRead.HandleFunc("/features", ListFeatureFlags, "GET")
+	adminRead.HandleFunc("/account_filters", ListAccountFilters, "GET")
+	adminRead.HandleFunc("/account_filters/{name}/accounts", ListFilteredAccounts, "GET")
+	adminRead.HandleFunc("/reverifications", ListReverifications, "GET")
+	adminRead.HandleFunc("/reverifications/{uuid}", GetReverification, "GET")
+
+	adminWrite := api.With(OAuthHandler("account-admin", "admin-account-write"), PolicyHandler)
+	adminWrite.HandleFunc("/accounts/{account}/history/{id}/revert", RevertAccountChange, "POST")
+	adminWrite.HandleFunc("/accounts/{account}/notes", UpdateAccountNotes, "PUT")
+	adminWrite.HandleFunc("/pending_accounts/{account}/approve", ApprovePendingAccount, "POST")
+	adminWrite.HandleFunc("/pending_accounts/{account}", RejectPendingAccount, "DELETE")
+	adminWrite.HandleFunc("/scope_requests/{uuid}/grant", GrantScopeRequest, "POST")
+	adminWrite.HandleFunc("/scope_requests/{uuid}", RejectScopeRequest, "DELETE")
+	adminWrite.HandleFunc("/email_bounces/{email}", DeleteEmailBounce, "DELETE")
+	adminWrite.HandleFunc("/account_filters/{name}", UpdateAccountFilter, "PUT")
+	adminWrite.HandleFunc("/account_filters/{name}", DeleteAccountFilter, "DELETE")
+	adminWrite.HandleFunc("/reverifications", CreateReverification, "POST")
+
+	tokenAdmin := api.With(OAuthHandler("account-admin", "token-admin"), PolicyHandler)
+	tokenAdmin.HandleFunc("/accounts/{account}/tokens", ListAccountTokens, "GET")
+	tokenAdmin.HandleFunc("/accounts/{account}/tokens/{id}", RevokeAccountToken, "DELETE")
+	tokenAdmin.HandleFunc("/tokens", RevokeTokens, "DELETE")
+
+	clientAdmin := api.With(OAuthHandler("account-admin", "client-admin", "admin-read"), PolicyHandler)
+	clientAdmin.HandleFunc("/scopes/usage", ListScopeUsage, "GET")
+	clientAdmin.HandleFunc("/grant_requests/stats", ListGrantRequestStats, "GET")
+	clientAdmin.HandleFunc("/clients/{id}/stats", GetClientStats, "GET")
+
+	audit := api.With(OAuthHandler("account-admin", "audit-read"), PolicyHandler)
+	audit.HandleFunc("/accounts/{account}/history", ListAccountHistory, "GET")
+
+	// full control only with 'account-admin'
+	admin := api.With(OAuthHandler("account-admin"), PolicyHandler)
+	admin.HandleFunc("/maintenance", UpdateMaintenance, "PUT")
+	admin.HandleFunc("/readonly", UpdateReadOnly, "PUT")
+	admin.HandleFunc("/announcements", CreateAnnouncement, "POST")
+	admin.HandleFunc("/retention", ApplyRetention, "POST")
+	admin.HandleFunc("/features/{name}", UpdateFeatureFlag, "PUT")
+	admin.HandleFunc("/features/{name}", DeleteFeatureFlag, "DELETE")
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+// schemaTable is the JSON representation of a database table.
+type schemaTable struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+}
+
+// schemaInfo is the JSON representation of the database schema state.
+type schemaInfo struct {
+	Version       int64         `json:"version"`
+	LatestVersion int64         `json:"latest_version"`
+	UpToDate      bool          `json:"up_to_date"`
+	Tables        []schemaTable `json:"tables"`
+}
+
+// GetSchema is a handler which returns the applied migration version of the database
+// and the number of rows of each table as JSON.
+func GetSchema(w http.ResponseWriter, r *http.Request) {
+	info := data.GetSchemaInfo()
+
+	marshal := &schemaInfo{
+		Version:       info.Version,
+		LatestVersion: info.LatestVersion,
+		UpToDate:      info.IsUpToDate(),
+		Tables:        make([]schemaTable, 0, len(info.Tables)),
+	}
+	for _, table := range info.Tables {
+		marshal.Tables = append(marshal.Tables, schemaTable{Name: table.Name, Rows: table.Rows})
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
@@ -0,0 +1,409 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// elevatedScope describes an elevated scope and its state for an account on the scopes page.
+type elevatedScope struct {
+	Name        string
+	Description string
+	State       string
+}
+
+// scopeRequestJSON is the JSON representation of a scope request.
+type scopeRequestJSON struct {
+	UUID       string    `json:"uuid"`
+	URL        string    `json:"url"`
+	Login      string    `json:"login"`
+	AccountURL string    `json:"account_url"`
+	Scope      string    `json:"scope"`
+	Reason     string    `json:"reason"`
+	State      string    `json:"state"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ScopesPage lists the elevated scopes to the account logged in via session cookie,
+// together with the state of the requests for these scopes.
+func ScopesPage(w http.ResponseWriter, r *http.Request) {
+	session, account, ok := accountSession(w, r)
+	if !ok {
+		return
+	}
+
+	states := make(map[string]string)
+	requests := data.ListAccountScopeRequests(account.UUID)
+	for i := len(requests) - 1; i >= 0; i-- {
+		if requests[i].State != data.ScopeRequestUnconfirmed {
+			states[requests[i].Scope] = requests[i].State
+		}
+	}
+	for _, s := range account.ElevatedScope().Strings() {
+		states[s] = data.ScopeRequestGranted
+	}
+
+	elevated := conf.GetRegistration().ElevatedScopes
+	names := make([]string, 0, len(elevated))
+	for name := range elevated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	scopes := make([]elevatedScope, 0, len(names))
+	for _, name := range names {
+		scopes = append(scopes, elevatedScope{name, elevated[name], states[name]})
+	}
+
+	pageData := struct {
+		Login     string
+		Scopes    []elevatedScope
+		CSRFToken string
+	}{account.Login, scopes, sessionCSRFToken(session)}
+
+	tmpl := conf.MakeTemplate("scopes.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// RequestScope creates a request for an elevated scope as submitted from the scopes page
+// and sends a confirmation link to the e-mail address of the account.
+func RequestScope(w http.ResponseWriter, r *http.Request) {
+	session, account, ok := accountSession(w, r)
+	if !ok {
+		return
+	}
+
+	param := &struct {
+		Scope     string
+		Reason    string
+		CSRFToken string
+	}{}
+	err := util.ReadFormIntoStruct(r, param, true)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+	expected := sessionCSRFToken(session)
+	if !util.EqualTokens(param.CSRFToken, expected) {
+		PrintErrorHTML(w, r, "Invalid form token", http.StatusForbidden)
+		return
+	}
+
+	request, err := account.RequestScope(param.Scope, param.Reason)
+	if err != nil {
+		if _, ok := err.(*util.ValidationError); ok {
+			PrintErrorHTML(w, r, err, http.StatusBadRequest)
+			return
+		}
+		panic(err)
+	}
+
+	tmplFields := &struct {
+		From    string
+		To      string
+		Subject string
+		BaseUrl string
+		Scope   string
+		Code    string
+	}{
+		conf.GetSmtpCredentials().From,
+		account.Email,
+		"Confirm your GIN scope request",
+		conf.GetServerConfig().BaseURL,
+		request.Scope,
+		request.ConfirmationCode.String,
+	}
+	content := util.MakeEmailTemplate("emailscoperequest.txt", tmplFields)
+	email := &data.Email{}
+	err = email.Create(util.NewStringSet(account.Email), content.Bytes())
+	if err != nil {
+		panic(err)
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event": "scope-request",
+		"login": account.Login,
+		"scope": request.Scope,
+		"ip":    remoteIP(r),
+	}).Info("Elevated scope requested")
+
+	head := "Please confirm your request"
+	message := fmt.Sprintf("We have sent a link to confirm your request for the scope '%s' to your e-mail address. "+
+		"Your request will be forwarded to an administrator as soon as you confirmed it.",
+		template.HTMLEscapeString(request.Scope))
+
+	info := struct {
+		Header  string
+		Message template.HTML
+	}{head, template.HTML(message)}
+
+	tmpl := conf.MakeTemplate("success.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err = tmpl.ExecuteTemplate(w, "layout", info)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ConfirmScopeRequest confirms a scope request via the code sent by e-mail and
+// notifies the administrators about the request.
+func ConfirmScopeRequest(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		PrintErrorHTML(w, r, "Confirmation code was absent", http.StatusBadRequest)
+		return
+	}
+
+	request, ok := data.GetScopeRequestByCode(code)
+	if !ok {
+		PrintErrorHTML(w, r, "Your request is invalid or outdated.", http.StatusNotFound)
+		return
+	}
+	account, ok := data.GetAccount(request.AccountUUID)
+	if !ok {
+		PrintErrorHTML(w, r, "Your request is invalid or outdated.", http.StatusNotFound)
+		return
+	}
+
+	err := request.Confirm()
+	if err != nil {
+		PrintErrorHTML(w, r, err, errorStatus(err, http.StatusNotFound))
+		return
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event": "scope-request-confirmed",
+		"login": account.Login,
+		"scope": request.Scope,
+		"ip":    remoteIP(r),
+	}).Info("Elevated scope request confirmed")
+
+	subject := "GIN scope request waiting for approval"
+	body := fmt.Sprintf("The account %s requested the scope '%s':\n\n%s\n\nPlease grant or reject the request at %s/oauth/scope_requests",
+		account.Login, request.Scope, request.Reason, conf.GetServerConfig().BaseURL)
+	for _, login := range conf.GetRegistration().Administrators {
+		if admin, ok := data.GetAccountByLogin(login); ok {
+			err = admin.Notify(subject, body)
+			if err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	head := "Your request has been confirmed!"
+	message := fmt.Sprintf("Your request for the scope '%s' has been forwarded to an administrator. "+
+		"You will be notified as soon as it has been decided.", template.HTMLEscapeString(request.Scope))
+
+	info := struct {
+		Header  string
+		Message template.HTML
+	}{head, template.HTML(message)}
+
+	tmpl := conf.MakeTemplate("success.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err = tmpl.ExecuteTemplate(w, "layout", info)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ScopeRequestsPage shows all scope requests waiting for a decision to an administrator
+// logged in via session cookie.
+func ScopeRequestsPage(w http.ResponseWriter, r *http.Request) {
+	session, admin, ok := administratorSession(w, r)
+	if !ok {
+		return
+	}
+
+	pageData := struct {
+		Requests  []scopeRequestJSON
+		CSRFToken string
+		Time      *util.TimeFormat
+	}{scopeRequestsJSON(data.ListPendingScopeRequests()), sessionCSRFToken(session), admin.TimeFormat()}
+
+	tmpl := conf.MakeTemplate("scoperequests.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// ScopeRequestsAction grants or rejects a scope request submitted from the scope requests page.
+func ScopeRequestsAction(w http.ResponseWriter, r *http.Request) {
+	session, _, ok := administratorSession(w, r)
+	if !ok {
+		return
+	}
+
+	param := &struct {
+		Request   string
+		Action    string
+		CSRFToken string
+	}{}
+	err := util.ReadFormIntoStruct(r, param, false)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+	expected := sessionCSRFToken(session)
+	if !util.EqualTokens(param.CSRFToken, expected) {
+		PrintErrorHTML(w, r, "Invalid form token", http.StatusForbidden)
+		return
+	}
+
+	request, ok := data.GetScopeRequest(param.Request)
+	if !ok || request.State != data.ScopeRequestPending {
+		PrintErrorHTML(w, r, "The requested scope request does not exist or is not pending", http.StatusNotFound)
+		return
+	}
+
+	switch param.Action {
+	case "grant", "reject":
+		err = decideScopeRequest(request, param.Action == "grant", session.AccountUUID)
+	default:
+		PrintErrorHTML(w, r, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	http.Redirect(w, r, conf.MakePath("/oauth/scope_requests"), http.StatusFound)
+}
+
+// ListScopeRequests is a handler which returns all scope requests waiting for a decision as JSON.
+func ListScopeRequests(w http.ResponseWriter, r *http.Request) {
+	marshal := scopeRequestsJSON(data.ListPendingScopeRequests())
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// GrantScopeRequest is a handler which grants a pending scope request and returns it as JSON.
+func GrantScopeRequest(w http.ResponseWriter, r *http.Request) {
+	decideScopeRequestJSON(w, r, true)
+}
+
+// RejectScopeRequest is a handler which rejects a pending scope request and returns it as JSON.
+func RejectScopeRequest(w http.ResponseWriter, r *http.Request) {
+	decideScopeRequestJSON(w, r, false)
+}
+
+// decideScopeRequestJSON grants or rejects the pending scope request given by the URL and
+// writes it as JSON.
+func decideScopeRequestJSON(w http.ResponseWriter, r *http.Request, grant bool) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	request, ok := data.GetScopeRequest(mux.Vars(r)["uuid"])
+	if !ok || request.State != data.ScopeRequestPending {
+		PrintErrorJSON(w, r, "The requested scope request does not exist or is not pending", http.StatusNotFound)
+		return
+	}
+
+	err := decideScopeRequest(request, grant, oauth.Token.AccountUUID.String)
+	if err != nil {
+		if code := errorStatus(err, 0); code != 0 {
+			PrintErrorJSON(w, r, err, code)
+			return
+		}
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(scopeRequestsJSON([]data.ScopeRequest{*request})[0])
+}
+
+// decideScopeRequest grants or rejects a scope request, informs the account owner and
+// writes the decision to the audit log.
+func decideScopeRequest(request *data.ScopeRequest, grant bool, adminUUID string) error {
+	account, ok := data.GetAccount(request.AccountUUID)
+	if !ok {
+		return fmt.Errorf("Account of scope request %s does not exist", request.UUID)
+	}
+	adminLogin := ""
+	if admin, ok := data.GetAccount(adminUUID); ok {
+		adminLogin = admin.Login
+	}
+
+	var err error
+	var subject, body, event string
+	if grant {
+		err = request.Grant(adminUUID)
+		subject = "GIN scope request granted"
+		body = fmt.Sprintf("Your request for the scope '%s' was granted by an administrator.", request.Scope)
+		event = "scope-granted"
+	} else {
+		err = request.Reject(adminUUID)
+		subject = "GIN scope request rejected"
+		body = fmt.Sprintf("Your request for the scope '%s' was rejected by an administrator.", request.Scope)
+		event = "scope-rejected"
+	}
+	if err != nil {
+		return err
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event": event,
+		"login": account.Login,
+		"scope": request.Scope,
+		"admin": adminLogin,
+	}).Info("Elevated scope request decided")
+
+	return account.Notify(subject, body)
+}
+
+// scopeRequestsJSON converts scope requests into their JSON representation.
+func scopeRequestsJSON(requests []data.ScopeRequest) []scopeRequestJSON {
+	marshal := make([]scopeRequestJSON, 0, len(requests))
+	for _, req := range requests {
+		login := ""
+		if account, ok := data.GetAccount(req.AccountUUID); ok {
+			login = account.Login
+		}
+		marshal = append(marshal, scopeRequestJSON{
+			UUID:       req.UUID,
+			URL:        conf.MakeUrl("/api/v1/scope_requests/%s", req.UUID),
+			Login:      login,
+			AccountURL: conf.MakeUrl("/api/v1/accounts/%s", login),
+			Scope:      req.Scope,
+			Reason:     req.Reason,
+			State:      req.State,
+			CreatedAt:  req.CreatedAt,
+		})
+	}
+	return marshal
+}
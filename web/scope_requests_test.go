@@ -0,0 +1,219 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestScopesPage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// not logged in
+	request, _ := http.NewRequest("GET", "/oauth/scopes", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/oauth/scopes", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken("DNM5RS3C")})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "curator") {
+		t.Error("Scopes page expected to show the elevated scope")
+	}
+}
+
+func TestRequestScope(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	session := &data.Session{Token: "DNM5RS3C"}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/oauth/scopes", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(session.Token)})
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// wrong form token
+	response := post(url.Values{"scope": {"curator"}, "reason": {"Datasets"}, "csrf_token": {"wrong"}})
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// invalid scope
+	response = post(url.Values{"scope": {"account-admin"}, "reason": {"Datasets"}, "csrf_token": {sessionCSRFToken(session)}})
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	response = post(url.Values{"scope": {"curator"}, "reason": {"Datasets"}, "csrf_token": {sessionCSRFToken(session)}})
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	alice, _ := data.GetAccountByLogin("alice")
+	requests := data.ListAccountScopeRequests(alice.UUID)
+	if len(requests) != 1 || requests[0].State != data.ScopeRequestUnconfirmed {
+		t.Error("Unconfirmed scope request expected")
+	}
+}
+
+func TestConfirmScopeRequest(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	alice, _ := data.GetAccountByLogin("alice")
+	req, _ := alice.RequestScope("curator", "Datasets")
+
+	// wrong code
+	request, _ := http.NewRequest("GET", "/oauth/confirm_scope?code=doesnotexist", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/oauth/confirm_scope?code="+req.ConfirmationCode.String, strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if len(data.ListPendingScopeRequests()) != 1 {
+		t.Error("Confirmed request expected to be pending")
+	}
+	bob, _ := data.GetAccountByLogin("bob")
+	if len(data.ListNotifications(bob.UUID)) != 3 {
+		t.Error("Administrator expected to be notified")
+	}
+}
+
+func TestListScopeRequests(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	alice, _ := data.GetAccountByLogin("alice")
+	req, _ := alice.RequestScope("curator", "Datasets")
+	req.Confirm()
+
+	// no admin scope
+	request, _ := http.NewRequest("GET", "/api/scope_requests", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/scope_requests", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	requests := []scopeRequestJSON{}
+	err := json.NewDecoder(response.Body).Decode(&requests)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(requests) != 1 || requests[0].Login != "alice" || requests[0].Scope != "curator" {
+		t.Error("Pending request of alice expected")
+	}
+}
+
+func TestGrantScopeRequest(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	alice, _ := data.GetAccountByLogin("alice")
+	req, _ := alice.RequestScope("curator", "Datasets")
+
+	// not pending
+	request, _ := http.NewRequest("POST", "/api/scope_requests/"+req.UUID+"/grant", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	req.Confirm()
+	request, _ = http.NewRequest("POST", "/api/scope_requests/"+req.UUID+"/grant", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !alice.ElevatedScope().Contains("curator") {
+		t.Error("Scope 'curator' expected to be granted")
+	}
+	if len(data.ListNotifications(alice.UUID)) != 2 {
+		t.Error("Account owner expected to be notified")
+	}
+}
+
+func TestRejectScopeRequest(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	alice, _ := data.GetAccountByLogin("alice")
+	req, _ := alice.RequestScope("curator", "Datasets")
+	req.Confirm()
+
+	request, _ := http.NewRequest("DELETE", "/api/scope_requests/"+req.UUID, strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if alice.ElevatedScope().Len() != 0 {
+		t.Error("Scope should not be granted")
+	}
+	if check, _ := data.GetScopeRequest(req.UUID); check.State != data.ScopeRequestRejected {
+		t.Error("Request expected to be rejected")
+	}
+}
+
+func TestScopeRequestsPage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// not an administrator
+	request, _ := http.NewRequest("GET", "/oauth/scope_requests", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken("DNM5RS3C")})
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/oauth/scope_requests", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(sessionCookieBob)})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+}
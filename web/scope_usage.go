@@ -0,0 +1,69 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+// Default and limit for the query parameter 'months' of the scope usage report
+const (
+	defaultScopeUsageMonths = 6
+	maxScopeUsageMonths     = 24
+)
+
+// scopeUsage is the JSON representation of the usage of a scope.
+type scopeUsage struct {
+	Name             string  `json:"name"`
+	Provider         string  `json:"provider"`
+	TokenValidations int64   `json:"token_validations"`
+	LastUsed         *string `json:"last_used"`
+	Unused           bool    `json:"unused"`
+}
+
+// ListScopeUsage is a handler which returns how often tokens were validated for each registered
+// scope as JSON. Scopes without any validation within the last months given by the optional query
+// parameter 'months' (default 6) are flagged as unused and are candidates for removal.
+func ListScopeUsage(w http.ResponseWriter, r *http.Request) {
+	months := defaultScopeUsageMonths
+	if param := r.URL.Query().Get("months"); param != "" {
+		var err error
+		months, err = strconv.Atoi(param)
+		if err != nil || months < 1 || months > maxScopeUsageMonths {
+			PrintErrorJSON(w, r, "Query parameter 'months' must be a number between 1 and 24", http.StatusBadRequest)
+			return
+		}
+	}
+
+	scopes := data.ListScopeUsage(time.Now().AddDate(0, -months, 0))
+	marshal := make([]scopeUsage, 0, len(scopes))
+	for _, s := range scopes {
+		u := scopeUsage{
+			Name:             s.Name,
+			Provider:         s.Provider,
+			TokenValidations: s.TokenValidations,
+			Unused:           s.Unused,
+		}
+		if s.LastUsed.Valid {
+			day := s.LastUsed.Time.Format("2006-01-02")
+			u.LastUsed = &day
+		}
+		marshal = append(marshal, u)
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
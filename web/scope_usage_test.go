@@ -0,0 +1,61 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListScopeUsage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	get := func(query, token string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("GET", "/api/scopes/usage"+query, strings.NewReader(""))
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// no admin scope
+	response := get("", accessTokenAlice)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid period
+	response = get("?months=100", accessTokenAliceAdmin)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	response = get("?months=1", accessTokenAliceAdmin)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	scopes := []scopeUsage{}
+	err := json.NewDecoder(response.Body).Decode(&scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused := make([]string, 0)
+	for _, s := range scopes {
+		if s.Unused {
+			unused = append(unused, s.Name)
+		}
+	}
+	if len(scopes) != 6 || len(unused) != 5 {
+		t.Errorf("All scopes except repo-read expected to be unused: %v", unused)
+	}
+}
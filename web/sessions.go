@@ -0,0 +1,108 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// SessionsPage lists all sessions of the account logged in via session cookie
+// together with the recognized or user given device names.
+func SessionsPage(w http.ResponseWriter, r *http.Request) {
+	session, account, ok := accountSession(w, r)
+	if !ok {
+		return
+	}
+
+	pageData := struct {
+		Login     string
+		Sessions  []data.Session
+		CurrentID string
+		CSRFToken string
+		Time      *util.TimeFormat
+	}{account.Login, data.ListAccountSessions(account.UUID), session.ID(), sessionCSRFToken(session), account.TimeFormat()}
+
+	tmpl := conf.MakeTemplate("sessions.html")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "text/html")
+	err := tmpl.ExecuteTemplate(w, "layout", pageData)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// SessionsAction renames the device of a session or ends a session as submitted
+// from the sessions page.
+func SessionsAction(w http.ResponseWriter, r *http.Request) {
+	session, account, ok := accountSession(w, r)
+	if !ok {
+		return
+	}
+
+	param := &struct {
+		Action    string
+		Session   string
+		Name      string
+		CSRFToken string
+	}{}
+	err := util.ReadFormIntoStruct(r, param, true)
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+	expected := sessionCSRFToken(session)
+	if !util.EqualTokens(param.CSRFToken, expected) {
+		PrintErrorHTML(w, r, "Invalid form token", http.StatusForbidden)
+		return
+	}
+
+	var target *data.Session
+	sessions := data.ListAccountSessions(account.UUID)
+	for i := range sessions {
+		if sessions[i].ID() == param.Session {
+			target = &sessions[i]
+		}
+	}
+	if target == nil {
+		PrintErrorHTML(w, r, "The requested session does not exist", http.StatusNotFound)
+		return
+	}
+
+	switch param.Action {
+	case "rename":
+		err = target.Rename(param.Name)
+	case "end":
+		err = target.Delete()
+	default:
+		PrintErrorHTML(w, r, "Invalid action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		PrintErrorHTML(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	http.Redirect(w, r, conf.MakePath("/oauth/sessions"), http.StatusFound)
+}
+
+// accountSession returns the session and the account of a request with a valid session cookie.
+// Otherwise an error page is written.
+func accountSession(w http.ResponseWriter, r *http.Request) (*data.Session, *data.Account, bool) {
+	info, ok := lookupSession(r)
+	if !ok {
+		PrintErrorHTML(w, r, "Please login first", http.StatusUnauthorized)
+		return nil, nil, false
+	}
+	return info.session, info.account, true
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestSessionsPage(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// not logged in
+	request, _ := http.NewRequest("GET", "/oauth/sessions", strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/oauth/sessions", strings.NewReader(""))
+	request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken("DNM5RS3C")})
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if !strings.Contains(response.Body.String(), "Firefox on Linux") {
+		t.Error("Sessions page expected to show the recognized device")
+	}
+}
+
+func TestSessionsAction(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+	session := &data.Session{Token: "DNM5RS3C"}
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/oauth/sessions", strings.NewReader(form.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(&http.Cookie{Name: conf.GetServerConfig().CookieName, Value: signSessionToken(session.Token)})
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// wrong form token
+	response := post(url.Values{"action": {"rename"}, "session": {session.ID()}, "name": {"Laptop"}, "csrf_token": {"wrong"}})
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusForbidden, response.Code)
+	}
+
+	// session of another account
+	other := &data.Session{Token: "4KDNO8T0"}
+	response = post(url.Values{"action": {"end"}, "session": {other.ID()}, "csrf_token": {sessionCSRFToken(session)}})
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// rename
+	response = post(url.Values{"action": {"rename"}, "session": {session.ID()}, "name": {"Laptop"}, "csrf_token": {sessionCSRFToken(session)}})
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+	check, _ := data.GetSession(session.Token)
+	if check.Device() != "Laptop" {
+		t.Errorf("Device expected to be 'Laptop' but was '%s'", check.Device())
+	}
+
+	// end session
+	response = post(url.Values{"action": {"end"}, "session": {session.ID()}, "csrf_token": {sessionCSRFToken(session)}})
+	if response.Code != http.StatusFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusFound, response.Code)
+	}
+	if _, ok := data.GetSession(session.Token); ok {
+		t.Error("Session expected to be ended")
+	}
+}
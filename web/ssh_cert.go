@@ -0,0 +1,103 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshCertificate is the JSON representation of an issued ssh certificate.
+type sshCertificate struct {
+	Certificate string    `json:"certificate"`
+	KeyID       string    `json:"key_id"`
+	Serial      uint64    `json:"serial"`
+	Principals  []string  `json:"principals"`
+	ValidAfter  time.Time `json:"valid_after"`
+	ValidBefore time.Time `json:"valid_before"`
+}
+
+// IssueSSHCertificate is a handler which signs the public key in the request body with the
+// ssh certificate authority. The certificate is issued for the account of the access token,
+// which must have the scope 'ssh-cert'.
+func IssueSSHCertificate(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	account, ok := data.GetAccount(oauth.Token.AccountUUID.String)
+	if !oauth.Token.AccountUUID.Valid || !ok {
+		PrintErrorJSON(w, r, "The access token does not belong to an active account", http.StatusForbidden)
+		return
+	}
+
+	body := &struct {
+		Key string `json:"key"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+
+	cert, err := account.IssueSSHCertificate(body.Key)
+	if err == data.ErrSSHCANotConfigured {
+		PrintErrorJSON(w, r, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":       "ssh-cert",
+		"login":       account.Login,
+		"key_id":      cert.KeyId,
+		"fingerprint": ssh.FingerprintSHA256(cert.Key),
+		"ip":          remoteIP(r),
+	}).Info("SSH certificate issued")
+
+	marshal := &sshCertificate{
+		Certificate: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(cert))),
+		KeyID:       cert.KeyId,
+		Serial:      cert.Serial,
+		Principals:  cert.ValidPrincipals,
+		ValidAfter:  time.Unix(int64(cert.ValidAfter), 0).UTC(),
+		ValidBefore: time.Unix(int64(cert.ValidBefore), 0).UTC(),
+	}
+
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// GetSSHCertificateAuthority is a handler which returns the public key of the ssh certificate
+// authority in authorized_keys format, e.g. for TrustedUserCAKeys of an ssh server.
+func GetSSHCertificateAuthority(w http.ResponseWriter, r *http.Request) {
+	signer, err := data.GetSSHCASigner()
+	if err == data.ErrSSHCANotConfigured {
+		PrintErrorJSON(w, r, err, http.StatusNotFound)
+		return
+	} else if err != nil {
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "text/plain")
+	w.Write(ssh.MarshalAuthorizedKey(signer.PublicKey()))
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestIssueSSHCertificate(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	token := &data.AccessToken{
+		AccountUUID: sql.NullString{String: "bf431618-f696-4dca-a95d-882618ce4ef9", Valid: true},
+		ClientUUID:  "8b14d6bb-cae7-4163-bbd1-f3be46e43e31",
+		Scope:       util.NewStringSet("ssh-cert"),
+	}
+	err := token.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	userKey, _ := ssh.NewPublicKey(userPub)
+	body := `{"key": "` + strings.TrimSpace(string(ssh.MarshalAuthorizedKey(userKey))) + `"}`
+
+	mkRequest := func(token, body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest("POST", "/api/ssh_certificates", strings.NewReader(body))
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		return response
+	}
+
+	// CA not configured
+	conf.GetSSHCertificateAuthority().KeyFile = ""
+	response := mkRequest(token.Token, body)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	dir, err := ioutil.TempDir("", "gin-auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	caPEM, err := ssh.MarshalPrivateKey(caKey, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "ca")
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(caPEM), 0600)
+	conf.GetSSHCertificateAuthority().KeyFile = keyFile
+	defer func() { conf.GetSSHCertificateAuthority().KeyFile = "" }()
+
+	// insufficient scope
+	response = mkRequest(accessTokenAlice, body)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// invalid key
+	response = mkRequest(token.Token, `{"key": "ssh-ed25519 invalid"}`)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// all ok
+	response = mkRequest(token.Token, body)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	result := &sshCertificate{}
+	json.NewDecoder(response.Body).Decode(result)
+	pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(result.Certificate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, ok := pub.(*ssh.Certificate)
+	if !ok || len(cert.ValidPrincipals) != 1 || cert.ValidPrincipals[0] != "alice" {
+		t.Error("Certificate for principal 'alice' expected")
+	}
+
+	// CA public key
+	request, _ := http.NewRequest("GET", "/api/ssh_certificates/ca", nil)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	caPub, _, _, _, err := ssh.ParseAuthorizedKey(response.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(caPub.Marshal()) != string(cert.SignatureKey.Marshal()) {
+		t.Error("Certificate expected to be signed by the CA")
+	}
+}
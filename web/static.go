@@ -0,0 +1,41 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+// Cache-Control header values for static files
+const (
+	cacheControlImmutable = "public, max-age=31536000, immutable"
+	cacheControlStatic    = "public, max-age=300"
+)
+
+// StaticFiles serves files from the static resources directory. Files requested by their
+// fingerprinted name (see conf.StaticAssetPath) are cached for one year, files requested
+// by their plain name only for a few minutes.
+func StaticFiles(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, conf.StaticPath)
+	file, immutable, ok := conf.ResolveStaticAsset(name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if immutable {
+		w.Header().Set("Cache-Control", cacheControlImmutable)
+	} else {
+		w.Header().Set("Cache-Control", cacheControlStatic)
+	}
+	http.ServeFile(w, r, file)
+}
@@ -0,0 +1,58 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/conf"
+)
+
+func TestStaticFiles(t *testing.T) {
+	handler := http.HandlerFunc(StaticFiles)
+
+	// fingerprinted name
+	request, _ := http.NewRequest("GET", conf.StaticAssetPath("css/gin-auth.css"), strings.NewReader(""))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if cc := response.Header().Get("Cache-Control"); cc != cacheControlImmutable {
+		t.Errorf("Cache-Control '%s' expected but was '%s'", cacheControlImmutable, cc)
+	}
+	if !strings.HasPrefix(response.Header().Get("Content-Type"), "text/css") {
+		t.Error("Content type expected to be 'text/css'")
+	}
+	if !strings.Contains(response.Body.String(), ".glyph-blue") {
+		t.Error("Response body should contain the style sheet")
+	}
+
+	// plain name
+	request, _ = http.NewRequest("GET", "/static/css/gin-auth.css", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if cc := response.Header().Get("Cache-Control"); cc != cacheControlStatic {
+		t.Errorf("Cache-Control '%s' expected but was '%s'", cacheControlStatic, cc)
+	}
+
+	// outdated fingerprint
+	request, _ = http.NewRequest("GET", "/static/css/gin-auth.000000000000.css", strings.NewReader(""))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+// Maximum number of revoked tokens sent with a single response of the revocation feed
+const revocationFeedLimit = 500
+
+// Interval in which a waiting request to the revocation feed looks for new revocations
+var revocationPollInterval = time.Second
+
+// revokedTokenJSON is the JSON representation of a revoked token in the revocation feed.
+type revokedTokenJSON struct {
+	TokenHash string    `json:"token_hash"`
+	RevokedAt time.Time `json:"revoked_at"`
+	Expires   time.Time `json:"expires"`
+}
+
+// ListTokenRevocations is the revocation feed for resource servers which cache token validations.
+// It returns the SHA-256 hashes of access tokens revoked since the position given by the
+// parameter 'cursor', or waits up to 'wait' seconds for new revocations (long polling). Without
+// cursor the feed starts at the current position. Resource servers authenticate with their
+// client credentials via basic authentication.
+func ListTokenRevocations(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	client, exists := data.GetClientByName(clientID)
+	if !ok || !exists || !client.AcceptsSecret(clientSecret) {
+		PrintErrorJSON(w, r, "Wrong client id or client secret", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	var cursor int64
+	if param := query.Get("cursor"); param != "" {
+		var err error
+		cursor, err = strconv.ParseInt(param, 10, 64)
+		if err != nil || cursor < 0 {
+			PrintErrorJSON(w, r, "Parameter 'cursor' must be a positive number", http.StatusBadRequest)
+			return
+		}
+	} else {
+		cursor = data.LatestTokenRevocation()
+	}
+
+	var wait time.Duration
+	if param := query.Get("wait"); param != "" {
+		seconds, err := strconv.Atoi(param)
+		if err != nil || seconds < 0 {
+			PrintErrorJSON(w, r, "Parameter 'wait' must be a positive number", http.StatusBadRequest)
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+		if max := conf.GetTokenValidation().RevocationWait; wait > max {
+			wait = max
+		}
+	}
+
+	deadline := time.Now().Add(wait)
+	revoked, next, more := data.ListRevokedTokens(cursor, revocationFeedLimit)
+	for len(revoked) == 0 && time.Now().Before(deadline) {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(revocationPollInterval):
+		}
+		revoked, next, more = data.ListRevokedTokens(cursor, revocationFeedLimit)
+	}
+
+	tokens := make([]revokedTokenJSON, 0, len(revoked))
+	for _, rev := range revoked {
+		tokens = append(tokens, revokedTokenJSON{TokenHash: rev.TokenHash, RevokedAt: rev.CreatedAt, Expires: rev.Expires})
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(&struct {
+		Cursor  int64              `json:"cursor"`
+		Revoked []revokedTokenJSON `json:"revoked"`
+		More    bool               `json:"more"`
+	}{next, tokens, more})
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestListTokenRevocations(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	type feed struct {
+		Cursor  int64              `json:"cursor"`
+		Revoked []revokedTokenJSON `json:"revoked"`
+	}
+	get := func(query, secret string) (*httptest.ResponseRecorder, *feed) {
+		request, _ := http.NewRequest("GET", "/api/v1/token_revocations"+query, nil)
+		request.SetBasicAuth("gin", secret)
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, request)
+		result := &feed{}
+		json.Unmarshal(response.Body.Bytes(), result)
+		return response, result
+	}
+
+	// wrong client secret
+	response, _ := get("", "wrongsecret")
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+	// invalid parameters
+	for _, query := range []string{"?cursor=foo", "?cursor=-1", "?wait=foo"} {
+		response, _ = get(query, "secret")
+		if response.Code != http.StatusBadRequest {
+			t.Errorf("Response code '%d' expected for '%s' but was '%d'", http.StatusBadRequest, query, response.Code)
+		}
+	}
+
+	// no revocations yet
+	response, result := get("", "secret")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if result.Cursor == 0 || len(result.Revoked) != 0 {
+		t.Errorf("Empty feed expected but was %s", response.Body.String())
+	}
+	start := result.Cursor
+
+	// wait for revocations
+	revocationPollInterval = 10 * time.Millisecond
+	defer func() { revocationPollInterval = time.Second }()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		token, _ := data.GetAccessToken("3N7MP7M7")
+		token.Delete()
+	}()
+	response, result = get("?wait=5&cursor="+strconv.FormatInt(start, 10), "secret")
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if len(result.Revoked) != 1 || result.Revoked[0].TokenHash != "912fdeb08647f5743ff0ebb4aa4954908abee5fdf387717d72eb486b09603d79" {
+		t.Fatalf("Revoked token '3N7MP7M7' expected but was %s", response.Body.String())
+	}
+	if result.Cursor <= start {
+		t.Error("Cursor expected to advance")
+	}
+
+	// nothing new after the cursor
+	waitStart := time.Now()
+	_, next := get("?wait=1&cursor="+strconv.FormatInt(result.Cursor, 10), "secret")
+	if len(next.Revoked) != 0 || next.Cursor < result.Cursor {
+		t.Errorf("No revocations expected after cursor %d", result.Cursor)
+	}
+	if time.Since(waitStart) < time.Second {
+		t.Error("Request expected to wait for revocations")
+	}
+}
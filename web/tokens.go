@@ -0,0 +1,112 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/mux"
+)
+
+// RevokeTokens is a handler which removes all access and refresh tokens issued to the client
+// given by the query parameter 'client_id' and/or containing the scope given by the query
+// parameter 'scope'. The number of revoked tokens is returned as JSON. With the query parameter
+// 'dry_run' no token is removed and the number and some IDs of the matching tokens are returned.
+func RevokeTokens(w http.ResponseWriter, r *http.Request) {
+	dry, err := isDryRun(r)
+	if err != nil {
+		PrintErrorJSON(w, r, err, http.StatusBadRequest)
+		return
+	}
+
+	clientName := r.URL.Query().Get("client_id")
+	scope := r.URL.Query().Get("scope")
+	if clientName == "" && scope == "" {
+		PrintErrorJSON(w, r, "Query parameter 'client_id' or 'scope' required", http.StatusBadRequest)
+		return
+	}
+
+	var clientUUID string
+	if clientName != "" {
+		client, ok := data.GetClientByName(clientName)
+		if !ok {
+			PrintErrorJSON(w, r, "The requested client does not exist", http.StatusNotFound)
+			return
+		}
+		clientUUID = client.UUID
+	}
+
+	revocation, err := data.RevokeTokens(clientUUID, scope, dry)
+	if err != nil {
+		panic(err)
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(revocation)
+}
+
+// ListAccountTokens is a handler which returns all valid access and refresh tokens of an account
+// with the client they were issued to and the time of their last use as JSON.
+func ListAccountTokens(w http.ResponseWriter, r *http.Request) {
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(data.ListAccountTokens(account.UUID))
+}
+
+// RevokeAccountToken is a handler which removes a single token of an account identified by
+// the id returned by ListAccountTokens. The revoked token is returned as JSON.
+func RevokeAccountToken(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	token, ok := data.GetAccountToken(account.UUID, mux.Vars(r)["id"])
+	if !ok {
+		PrintErrorJSON(w, r, "The requested token does not exist", http.StatusNotFound)
+		return
+	}
+
+	err := token.Revoke()
+	if err != nil {
+		panic(err)
+	}
+
+	conf.GetLogEnv().Audit.WithFields(logrus.Fields{
+		"event":  "token-revoked",
+		"login":  account.Login,
+		"client": token.ClientName,
+		"admin":  oauth.Token.AccountUUID.String,
+		"ip":     remoteIP(r),
+	}).Info("Token of account revoked")
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(token)
+}
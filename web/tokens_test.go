@@ -0,0 +1,172 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/G-Node/gin-auth/data"
+)
+
+func TestRevokeTokens(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no admin scope
+	request, _ := http.NewRequest("DELETE", "/api/tokens?scope=repo-read", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// no client or scope
+	request, _ = http.NewRequest("DELETE", "/api/tokens", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// client does not exist
+	request, _ = http.NewRequest("DELETE", "/api/tokens?client_id=doesnotexist", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// invalid dry run
+	request, _ = http.NewRequest("DELETE", "/api/tokens?scope=account-write&dry_run=maybe", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusBadRequest, response.Code)
+	}
+
+	// dry run
+	request, _ = http.NewRequest("DELETE", "/api/tokens?client_id=gin&scope=account-write&dry_run=true", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	preview := &data.TokenRevocation{}
+	err := json.NewDecoder(response.Body).Decode(preview)
+	if err != nil {
+		t.Error(err)
+	}
+	if !preview.DryRun || preview.AccessTokens != 2 || len(preview.SampleIDs) != 2 {
+		t.Errorf("Two matching access tokens expected but was %d", preview.AccessTokens)
+	}
+	if _, ok := data.GetAccessToken(accessTokenAlice); !ok {
+		t.Error("Access token of alice should not be revoked by a dry run")
+	}
+
+	// all ok
+	request, _ = http.NewRequest("DELETE", "/api/tokens?client_id=gin&scope=account-write", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	revocation := &data.TokenRevocation{}
+	err = json.NewDecoder(response.Body).Decode(revocation)
+	if err != nil {
+		t.Error(err)
+	}
+	if revocation.AccessTokens != 2 || revocation.RefreshTokens != 0 {
+		t.Errorf("Two access tokens expected to be revoked but was %d", revocation.AccessTokens)
+	}
+	if _, ok := data.GetAccessToken(accessTokenAlice); ok {
+		t.Error("Access token of alice should be revoked")
+	}
+}
+
+func TestListAccountTokens(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	// no admin scope
+	request, _ := http.NewRequest("GET", "/api/accounts/alice/tokens", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAlice)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusUnauthorized, response.Code)
+	}
+
+	// account does not exist
+	request, _ = http.NewRequest("GET", "/api/accounts/doesnotexist/tokens", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("GET", "/api/accounts/alice/tokens", strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Fatalf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+
+	tokens := make([]struct {
+		ID         string `json:"id"`
+		Kind       string `json:"kind"`
+		ClientName string `json:"client_name"`
+	}, 0)
+	err := json.NewDecoder(response.Body).Decode(&tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 3 || tokens[0].ID == "" || tokens[0].ClientName != "gin" {
+		t.Errorf("Unexpected tokens: %v", tokens)
+	}
+}
+
+func TestRevokeAccountToken(t *testing.T) {
+	handler := InitTestHttpHandler(t)
+
+	alice, _ := data.GetAccountByLogin("alice")
+	id := data.ListAccountTokens(alice.UUID)[0].ID()
+
+	// token of another account
+	request, _ := http.NewRequest("DELETE", "/api/accounts/bob/tokens/"+id, strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusNotFound, response.Code)
+	}
+
+	// all ok
+	request, _ = http.NewRequest("DELETE", "/api/accounts/alice/tokens/"+id, strings.NewReader(""))
+	request.Header.Set("Authorization", "Bearer "+accessTokenAliceAdmin)
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	if response.Code != http.StatusOK {
+		t.Errorf("Response code '%d' expected but was '%d'", http.StatusOK, response.Code)
+	}
+	if len(data.ListAccountTokens(alice.UUID)) != 2 {
+		t.Error("Token should have been revoked")
+	}
+}
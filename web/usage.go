@@ -0,0 +1,142 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+)
+
+// Defaults and limits for the query parameters of the usage handlers
+const (
+	defaultUsageDays  = 30
+	maxUsageDays      = 366
+	defaultUsageLimit = 100
+)
+
+// usageDaily is the JSON representation of a daily usage counter.
+type usageDaily struct {
+	Day              string `json:"day"`
+	ClientID         string `json:"client_id"`
+	APIRequests      int64  `json:"api_requests"`
+	TokenValidations int64  `json:"token_validations"`
+}
+
+// usageTotal is the JSON representation of the usage of an account.
+type usageTotal struct {
+	Login            string       `json:"login"`
+	AccountURL       string       `json:"account_url"`
+	APIRequests      int64        `json:"api_requests"`
+	TokenValidations int64        `json:"token_validations"`
+	Daily            []usageDaily `json:"daily,omitempty"`
+}
+
+// GetAccountUsage is a handler which returns the daily API requests and token validations
+// of an account as JSON. The optional query parameter 'days' limits the period (default 30 days).
+func GetAccountUsage(w http.ResponseWriter, r *http.Request) {
+	oauth, ok := OAuthToken(r)
+	if !ok {
+		panic("Request was authorized but no OAuth token is available!") // this should never happen
+	}
+
+	account, ok := pathAccount(r)
+	if !ok {
+		PrintErrorJSON(w, r, "The requested account does not exist", http.StatusNotFound)
+		return
+	}
+
+	isOwner := oauth.Token.AccountUUID.String == account.UUID && oauth.Match.Contains("account-read")
+	if !isOwner && !data.HasAdminScope(oauth.Match, data.ScopeAdminRead) {
+		PrintErrorJSON(w, r, "Access to requested account forbidden", http.StatusUnauthorized)
+		return
+	}
+
+	since, ok := usageSince(w, r)
+	if !ok {
+		return
+	}
+
+	counters := data.ListAccountUsage(account.UUID, since)
+	marshal := &usageTotal{
+		Login:      account.Login,
+		AccountURL: conf.MakeUrl("/api/v1/accounts/%s", account.Login),
+		Daily:      make([]usageDaily, 0, len(counters)),
+	}
+	for _, c := range counters {
+		marshal.APIRequests += c.APIRequests
+		marshal.TokenValidations += c.TokenValidations
+		marshal.Daily = append(marshal.Daily, usageDaily{
+			Day:              c.Day.Format("2006-01-02"),
+			ClientID:         c.ClientName,
+			APIRequests:      c.APIRequests,
+			TokenValidations: c.TokenValidations,
+		})
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// ListUsage is a handler which returns the accounts with the most API requests as JSON.
+// The optional query parameters 'days' (default 30) and 'limit' (default 100) restrict
+// the period and the number of accounts.
+func ListUsage(w http.ResponseWriter, r *http.Request) {
+	since, ok := usageSince(w, r)
+	if !ok {
+		return
+	}
+
+	limit := defaultUsageLimit
+	if param := r.URL.Query().Get("limit"); param != "" {
+		var err error
+		limit, err = strconv.Atoi(param)
+		if err != nil || limit < 1 {
+			PrintErrorJSON(w, r, "Query parameter 'limit' must be a positive number", http.StatusBadRequest)
+			return
+		}
+	}
+
+	totals := data.ListUsageTotals(since, limit)
+	marshal := make([]usageTotal, 0, len(totals))
+	for _, t := range totals {
+		marshal = append(marshal, usageTotal{
+			Login:            t.Login,
+			AccountURL:       conf.MakeUrl("/api/v1/accounts/%s", t.Login),
+			APIRequests:      t.APIRequests,
+			TokenValidations: t.TokenValidations,
+		})
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(marshal)
+}
+
+// usageSince returns the first day of the period given by the query parameter 'days'.
+// If the parameter is invalid an error is written to the response.
+func usageSince(w http.ResponseWriter, r *http.Request) (time.Time, bool) {
+	days := defaultUsageDays
+	if param := r.URL.Query().Get("days"); param != "" {
+		var err error
+		days, err = strconv.Atoi(param)
+		if err != nil || days < 1 || days > maxUsageDays {
+			PrintErrorJSON(w, r, "Query parameter 'days' must be a number between 1 and 366", http.StatusBadRequest)
+			return time.Time{}, false
+		}
+	}
+	return time.Now().AddDate(0, 0, 1-days), true
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2016, German Neuroinformatics Node (G-Node)
+//
+// All rights reserved.
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted under the terms of the BSD License. See
+// LICENSE file in the root of the Project.
+
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/G-Node/gin-auth/conf"
+	"github.com/G-Node/gin-auth/data"
+	"github.com/G-Node/gin-auth/util"
+)
+
+// validationCacheControl returns the Cache-Control header for the validation of a token.
+// Responses may be cached for the configured max age, but never beyond the expiration of
+// the token. Without a max age caching is disabled.
+func validationCacheControl(token *data.AccessToken) string {
+	config := conf.GetTokenValidation()
+	maxAge := config.MaxAge
+	if remaining := token.Expires.Sub(util.Now()); remaining < maxAge {
+		maxAge = remaining
+	}
+	if maxAge < time.Second {
+		return "no-cache"
+	}
+
+	control := fmt.Sprintf("private, max-age=%d", int64(maxAge/time.Second))
+	if config.StaleWhileRevalidate >= time.Second {
+		control += fmt.Sprintf(", stale-while-revalidate=%d", int64(config.StaleWhileRevalidate/time.Second))
+	}
+	return control
+}
+
+// ValidateBatch validates several tokens with a single request. The body contains the tokens
+// as field "tokens", the response maps each token to the same information returned by Validate.
+// Tokens which are invalid or expired are mapped to null.
+func ValidateBatch(w http.ResponseWriter, r *http.Request) {
+	body := &struct {
+		Tokens []string `json:"tokens"`
+	}{}
+	err := decodeJSON(r, body)
+	if err != nil {
+		PrintErrorJSON(w, r, "Unable to parse request body", http.StatusBadRequest)
+		return
+	}
+	if max := conf.GetTokenValidation().MaxBatchSize; len(body.Tokens) > max {
+		PrintErrorJSON(w, r, fmt.Sprintf("At most %d tokens can be validated at once", max), http.StatusBadRequest)
+		return
+	}
+
+	result := make(map[string]interface{}, len(body.Tokens))
+	for _, str := range body.Tokens {
+		result[str] = nil
+	}
+	for _, token := range data.GetAccessTokens(body.Tokens) {
+		token := token
+		data.RecordTokenValidation(&token)
+		if info, ok := tokenInfo(&token); ok {
+			result[token.Token] = info
+		}
+	}
+
+	w.Header().Add("Cache-Control", "no-cache")
+	w.Header().Add("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.Encode(&struct {
+		Tokens map[string]interface{} `json:"tokens"`
+	}{result})
+}